@@ -0,0 +1,117 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"bright/models"
+	"bright/store"
+
+	"go.uber.org/zap"
+)
+
+func TestValidate(t *testing.T) {
+	valid := models.AlertRule{Metric: MetricDocCount, Index: "products", Operator: "<", Threshold: 1, For: "2m", Webhooks: []string{"https://hooks.example.com/bright"}}
+	if err := Validate(&valid, false); err != nil {
+		t.Fatalf("Expected a valid rule, got %v", err)
+	}
+
+	invalid := []models.AlertRule{
+		{Metric: "index.size", Index: "products", Operator: ">", Webhooks: valid.Webhooks},
+		{Metric: MetricDocCount, Operator: ">", Webhooks: valid.Webhooks},
+		{Metric: MetricIngressErrorCount, Index: "products", Operator: ">", Webhooks: valid.Webhooks},
+		{Metric: MetricDocCount, Index: "products", Operator: "!=", Webhooks: valid.Webhooks},
+		{Metric: MetricDocCount, Index: "products", Operator: ">", For: "soon", Webhooks: valid.Webhooks},
+		{Metric: MetricDocCount, Index: "products", Operator: ">"},
+		{Metric: MetricDocCount, Index: "products", Operator: ">", Webhooks: []string{"ftp://example.com"}},
+		{Metric: MetricDocCount, Index: "products", Operator: ">", Emails: []string{"ops@example.com"}},
+	}
+	for i, rule := range invalid {
+		if err := Validate(&rule, false); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("Rule %d: expected an invalid rule, got %v", i, err)
+		}
+	}
+}
+
+func TestSchedulerNotifies(t *testing.T) {
+	var mu sync.Mutex
+	var received []Notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n Notification
+		json.NewDecoder(r.Body).Decode(&n)
+		mu.Lock()
+		received = append(received, n)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	s, err := store.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer s.Close()
+	if err := s.CreateIndex(&models.IndexConfig{ID: "orders", PrimaryKey: "id"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	rule := &models.AlertRule{ID: "empty-orders", Metric: MetricDocCount, Index: "orders", Operator: "<", Threshold: 1, For: "2m", Webhooks: []string{server.URL}}
+	if err := s.AlertRules().Put(rule); err != nil {
+		t.Fatalf("Failed to store rule: %v", err)
+	}
+
+	scheduler := NewScheduler(s, nil, nil, Options{NodeID: "node-1"}, zap.NewNop())
+	start := time.Now()
+
+	scheduler.Evaluate(context.Background(), start)
+	if status := scheduler.Status(rule.ID); status.State != StatePending {
+		t.Fatalf("Expected the rule to be pending, got %s", status.State)
+	}
+
+	scheduler.Evaluate(context.Background(), start.Add(2*time.Minute))
+	if status := scheduler.Status(rule.ID); status.State != StateFiring {
+		t.Fatalf("Expected the rule to fire after 2m, got %s", status.State)
+	}
+
+	if err := s.AddDocuments("orders", "", []map[string]any{{"id": "1"}}); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+	scheduler.Evaluate(context.Background(), start.Add(3*time.Minute))
+	if status := scheduler.Status(rule.ID); status.State != StateInactive {
+		t.Fatalf("Expected the rule to resolve, got %s", status.State)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 2 || received[0].Status != StatusFiring || received[1].Status != StatusResolved {
+		t.Fatalf("Expected a firing and a resolved notification, got %+v", received)
+	}
+	if received[0].Value != 0 || received[0].Node != "node-1" || !received[0].ActiveSince.Equal(start) {
+		t.Errorf("Unexpected firing notification: %+v", received[0])
+	}
+}
+
+func TestDocCountChange(t *testing.T) {
+	state := &ruleState{}
+	start := time.Now()
+
+	counts := []float64{1000, 900, 400}
+	var change float64
+	for i, count := range counts {
+		change = docCountChange(state, count, "1h", start.Add(time.Duration(i)*30*time.Minute))
+	}
+	if change != -60 {
+		t.Errorf("Expected a 60%% drop over the window, got %v", change)
+	}
+
+	// An hour later the baseline is the count one window ago
+	change = docCountChange(state, 200, "1h", start.Add(2*time.Hour))
+	if change != -50 {
+		t.Errorf("Expected the baseline to move with the window, got %v", change)
+	}
+}
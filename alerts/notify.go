@@ -0,0 +1,75 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"bright/models"
+	"bright/notify"
+)
+
+// Notification statuses
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// Notification is the JSON body posted to webhooks
+type Notification struct {
+	RuleID      string    `json:"ruleId"`
+	Name        string    `json:"name,omitempty"`
+	Status      string    `json:"status"`
+	Metric      string    `json:"metric"`
+	Index       string    `json:"index,omitempty"`
+	Ingress     string    `json:"ingress,omitempty"`
+	Operator    string    `json:"operator"`
+	Threshold   float64   `json:"threshold"`
+	Value       float64   `json:"value"`
+	ActiveSince time.Time `json:"activeSince"`
+	Node        string    `json:"node,omitempty"`
+	Message     string    `json:"message"`
+}
+
+// send delivers a notification to every channel of a rule and returns the
+// failures
+func send(ctx context.Context, sender *notify.Sender, rule *models.AlertRule, notification Notification) error {
+	var errs []error
+
+	for _, webhook := range rule.Webhooks {
+		if err := sender.PostJSON(ctx, webhook, notification); err != nil {
+			errs = append(errs, fmt.Errorf("webhook %s: %w", webhook, err))
+		}
+	}
+
+	if len(rule.Emails) > 0 {
+		subject := fmt.Sprintf("[Bright] %s: %s", strings.ToUpper(notification.Status), ruleTitle(notification))
+		var body strings.Builder
+		body.WriteString(notification.Message + "\n")
+		fmt.Fprintf(&body, "\nRule: %s\nActive since: %s\n", notification.RuleID, notification.ActiveSince.Format(time.RFC3339))
+		if notification.Node != "" {
+			fmt.Fprintf(&body, "Node: %s\n", notification.Node)
+		}
+		if err := sender.Email(rule.Emails, subject, body.String()); err != nil {
+			errs = append(errs, fmt.Errorf("email: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ruleTitle names a rule by its name, or by its metric and subject
+func ruleTitle(n Notification) string {
+	if n.Name != "" {
+		return n.Name
+	}
+	switch {
+	case n.Index != "":
+		return n.Metric + " of " + n.Index
+	case n.Ingress != "":
+		return n.Metric + " of " + n.Ingress
+	}
+	return n.Metric
+}
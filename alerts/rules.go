@@ -0,0 +1,104 @@
+package alerts
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"bright/models"
+	"bright/notify"
+)
+
+// Metrics rules can watch
+const (
+	// MetricDocCount is the number of documents in an index
+	MetricDocCount = "index.doc_count"
+
+	// MetricDocCountChange is the change of an index's document count over
+	// the rule's window, in percent ("drops by more than 50%" is < -50)
+	MetricDocCountChange = "index.doc_count_change"
+
+	// MetricIngressErrorCount is the number of sync errors of an ingress
+	MetricIngressErrorCount = "ingress.error_count"
+
+	// MetricIngressFailed is 1 while an ingress has failed, 0 otherwise
+	MetricIngressFailed = "ingress.failed"
+
+	// MetricIngressLagSeconds is how far an ingress trails its source, in
+	// seconds
+	MetricIngressLagSeconds = "ingress.lag_seconds"
+
+	// MetricClusterHasLeader is 1 while a node knows the Raft leader, 0
+	// otherwise. It is evaluated by every node, since a cluster without a
+	// leader has no node to evaluate the other rules.
+	MetricClusterHasLeader = "cluster.has_leader"
+)
+
+// DefaultWindow is the window of rate metrics when a rule sets none
+const DefaultWindow = time.Hour
+
+// ErrInvalidRule is returned for rules that cannot be evaluated
+var ErrInvalidRule = errors.New("invalid alert rule")
+
+// Validate checks a rule. Rules with emails need an SMTP server, so
+// emailEnabled reports whether one is configured.
+func Validate(rule *models.AlertRule, emailEnabled bool) error {
+	switch rule.Metric {
+	case MetricDocCount, MetricDocCountChange:
+		if rule.Index == "" {
+			return fmt.Errorf("%w: %s needs an index", ErrInvalidRule, rule.Metric)
+		}
+	case MetricIngressErrorCount, MetricIngressFailed, MetricIngressLagSeconds:
+		if rule.Ingress == "" {
+			return fmt.Errorf("%w: %s needs an ingress", ErrInvalidRule, rule.Metric)
+		}
+	case MetricClusterHasLeader:
+	default:
+		return fmt.Errorf("%w: unknown metric %q", ErrInvalidRule, rule.Metric)
+	}
+
+	switch rule.Operator {
+	case ">", ">=", "<", "<=":
+	default:
+		return fmt.Errorf("%w: operator must be >, >=, < or <=", ErrInvalidRule)
+	}
+
+	if _, err := parseDuration(rule.Window, "window"); err != nil {
+		return err
+	}
+	if _, err := parseDuration(rule.For, "for"); err != nil {
+		return err
+	}
+
+	if err := notify.ValidateChannels(rule.Webhooks, rule.Emails, emailEnabled); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidRule, err)
+	}
+	return nil
+}
+
+// parseDuration parses an optional non-negative duration setting
+func parseDuration(value, setting string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%w: %s must be a non-negative duration such as 5m", ErrInvalidRule, setting)
+	}
+	return d, nil
+}
+
+// breached compares a metric value with a rule's threshold
+func breached(rule *models.AlertRule, value float64) bool {
+	switch rule.Operator {
+	case ">":
+		return value > rule.Threshold
+	case ">=":
+		return value >= rule.Threshold
+	case "<":
+		return value < rule.Threshold
+	case "<=":
+		return value <= rule.Threshold
+	}
+	return false
+}
@@ -0,0 +1,347 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"bright/ingresses"
+	"bright/models"
+	"bright/notify"
+	"bright/raft"
+	"bright/store"
+
+	"go.uber.org/zap"
+)
+
+// DefaultInterval is how often rules are evaluated when no interval is set
+const DefaultInterval = 30 * time.Second
+
+// Rule states
+const (
+	StateInactive = "inactive"
+	StatePending  = "pending"
+	StateFiring   = "firing"
+)
+
+// IngressSource looks up ingresses for ingress metrics
+type IngressSource interface {
+	Get(id string) (ingresses.Ingress, error)
+}
+
+// Options configure a Scheduler
+type Options struct {
+	// Interval between evaluations (DefaultInterval if zero)
+	Interval time.Duration
+
+	// NodeID identifies this node in notifications
+	NodeID string
+
+	// Sender delivers notifications (one without email support if nil)
+	Sender *notify.Sender
+}
+
+// Status is the evaluation state of a rule on this node
+type Status struct {
+	State           string     `json:"state"`
+	Value           *float64   `json:"value,omitempty"`
+	ActiveSince     *time.Time `json:"activeSince,omitempty"`
+	LastEvaluatedAt *time.Time `json:"lastEvaluatedAt,omitempty"`
+	LastError       string     `json:"lastError,omitempty"`
+}
+
+// sample is a document count seen at an evaluation
+type sample struct {
+	at    time.Time
+	count float64
+}
+
+// ruleState tracks a rule between evaluations
+type ruleState struct {
+	Status
+
+	// subject resets the state when a rule is changed to watch something else
+	subject string
+	samples []sample
+}
+
+// Scheduler evaluates alerting rules periodically and notifies their
+// channels when they start and stop firing. In Raft mode only the leader
+// evaluates rules, except cluster.has_leader rules which every node
+// evaluates for itself.
+type Scheduler struct {
+	store     *store.IndexStore
+	raftNode  *raft.RaftNode
+	ingresses IngressSource
+	sender    *notify.Sender
+	interval  time.Duration
+	nodeID    string
+	logger    *zap.Logger
+
+	states map[string]*ruleState
+	mu     sync.Mutex
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewScheduler creates a scheduler. raftNode and ingressSource may be nil.
+func NewScheduler(store *store.IndexStore, raftNode *raft.RaftNode, ingressSource IngressSource, options Options, logger *zap.Logger) *Scheduler {
+	interval := options.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	sender := options.Sender
+	if sender == nil {
+		sender = notify.NewSender(notify.SMTPConfig{})
+	}
+	return &Scheduler{
+		store:     store,
+		raftNode:  raftNode,
+		ingresses: ingressSource,
+		sender:    sender,
+		interval:  interval,
+		nodeID:    options.NodeID,
+		logger:    logger,
+		states:    make(map[string]*ruleState),
+	}
+}
+
+// EmailEnabled returns true if email notifications can be sent
+func (s *Scheduler) EmailEnabled() bool {
+	return s.sender.EmailEnabled()
+}
+
+// Start evaluates rules every interval until Stop is called
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.Evaluate(ctx, now)
+			}
+		}
+	}()
+}
+
+// Stop halts evaluation and waits for a running one to finish
+func (s *Scheduler) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+}
+
+// Status returns the state of a rule as last evaluated on this node
+func (s *Scheduler) Status(id string) Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if state, ok := s.states[id]; ok {
+		return state.Status
+	}
+	return Status{State: StateInactive}
+}
+
+// Evaluate runs one round of rule evaluation and sends the resulting
+// notifications
+func (s *Scheduler) Evaluate(ctx context.Context, now time.Time) {
+	leader := s.raftNode == nil || s.raftNode.IsLeader()
+
+	var notifications []pendingNotification
+	evaluated := make(map[string]bool)
+
+	for _, rule := range s.store.AlertRules().List() {
+		if rule.Disabled {
+			continue
+		}
+		if rule.Metric == MetricClusterHasLeader {
+			if s.raftNode == nil {
+				continue
+			}
+		} else if !leader {
+			continue
+		}
+
+		evaluated[rule.ID] = true
+		if n := s.evaluateRule(rule, now); n != nil {
+			notifications = append(notifications, *n)
+		}
+	}
+
+	// Rules that were deleted, disabled or are evaluated elsewhere start over
+	s.mu.Lock()
+	for id := range s.states {
+		if !evaluated[id] {
+			delete(s.states, id)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, n := range notifications {
+		if err := send(ctx, s.sender, n.rule, n.notification); err != nil {
+			s.logger.Warn("Failed to send alert notification",
+				zap.String("rule", n.rule.ID),
+				zap.String("status", n.notification.Status),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// pendingNotification is a notification to send once evaluation is done
+type pendingNotification struct {
+	rule         *models.AlertRule
+	notification Notification
+}
+
+// evaluateRule measures a rule's metric and advances its state, returning
+// the notification to send, if any
+func (s *Scheduler) evaluateRule(rule *models.AlertRule, now time.Time) *pendingNotification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subject := rule.Metric + "\x00" + rule.Index + "\x00" + rule.Ingress
+	state, ok := s.states[rule.ID]
+	if !ok || state.subject != subject {
+		state = &ruleState{Status: Status{State: StateInactive}, subject: subject}
+		s.states[rule.ID] = state
+	}
+
+	evaluatedAt := now
+	state.LastEvaluatedAt = &evaluatedAt
+
+	value, err := s.measure(rule, state, now)
+	if err != nil {
+		// A metric that cannot be read keeps the rule as it was
+		state.LastError = err.Error()
+		return nil
+	}
+	state.LastError = ""
+	state.Value = &value
+
+	if !breached(rule, value) {
+		var n *pendingNotification
+		if state.State == StateFiring {
+			n = s.notification(rule, state, StatusResolved, value)
+		}
+		state.State = StateInactive
+		state.ActiveSince = nil
+		return n
+	}
+
+	if state.State == StateInactive {
+		state.State = StatePending
+		state.ActiveSince = &evaluatedAt
+	}
+
+	holdFor, _ := parseDuration(rule.For, "for")
+	if state.State == StatePending && now.Sub(*state.ActiveSince) >= holdFor {
+		state.State = StateFiring
+		return s.notification(rule, state, StatusFiring, value)
+	}
+	return nil
+}
+
+// measure reads the current value of a rule's metric
+func (s *Scheduler) measure(rule *models.AlertRule, state *ruleState, now time.Time) (float64, error) {
+	switch rule.Metric {
+	case MetricDocCount, MetricDocCountChange:
+		index, _, err := s.store.GetIndex(rule.Index)
+		if err != nil {
+			return 0, err
+		}
+		count, err := index.DocCount()
+		if err != nil {
+			return 0, err
+		}
+		if rule.Metric == MetricDocCount {
+			return float64(count), nil
+		}
+		return docCountChange(state, float64(count), rule.Window, now), nil
+
+	case MetricIngressErrorCount, MetricIngressFailed, MetricIngressLagSeconds:
+		if s.ingresses == nil {
+			return 0, fmt.Errorf("ingresses are not available")
+		}
+		ingress, err := s.ingresses.Get(rule.Ingress)
+		if err != nil {
+			return 0, err
+		}
+		switch rule.Metric {
+		case MetricIngressErrorCount:
+			return float64(ingress.Statistics().ErrorCount), nil
+		case MetricIngressLagSeconds:
+			return ingress.Statistics().LagSeconds, nil
+		}
+		if ingress.Status() == ingresses.StatusFailed {
+			return 1, nil
+		}
+		return 0, nil
+
+	case MetricClusterHasLeader:
+		if s.raftNode.LeaderAddr() == "" {
+			return 0, nil
+		}
+		return 1, nil
+	}
+	return 0, fmt.Errorf("unknown metric %q", rule.Metric)
+}
+
+// docCountChange records a document count and returns its change in
+// percent from the count one window ago (or the oldest count seen, until a
+// window has passed). A change from zero documents counts as none.
+func docCountChange(state *ruleState, count float64, window string, now time.Time) float64 {
+	period, _ := parseDuration(window, "window")
+	if period == 0 {
+		period = DefaultWindow
+	}
+
+	state.samples = append(state.samples, sample{at: now, count: count})
+	// Keep the newest sample that is at least a window old as the baseline
+	for len(state.samples) > 1 && now.Sub(state.samples[1].at) >= period {
+		state.samples = state.samples[1:]
+	}
+
+	baseline := state.samples[0].count
+	if baseline == 0 {
+		return 0
+	}
+	return (count - baseline) / baseline * 100
+}
+
+// notification builds the notification of a state change
+func (s *Scheduler) notification(rule *models.AlertRule, state *ruleState, status string, value float64) *pendingNotification {
+	n := Notification{
+		RuleID:      rule.ID,
+		Name:        rule.Name,
+		Status:      status,
+		Metric:      rule.Metric,
+		Index:       rule.Index,
+		Ingress:     rule.Ingress,
+		Operator:    rule.Operator,
+		Threshold:   rule.Threshold,
+		Value:       value,
+		ActiveSince: *state.ActiveSince,
+		Node:        s.nodeID,
+	}
+
+	title := ruleTitle(n)
+	if status == StatusFiring {
+		n.Message = fmt.Sprintf("%s is %g (%s %g)", title, value, rule.Operator, rule.Threshold)
+	} else {
+		n.Message = fmt.Sprintf("%s is back to %g", title, value)
+	}
+	return &pendingNotification{rule: rule, notification: n}
+}
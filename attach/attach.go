@@ -0,0 +1,234 @@
+// Package attach extracts searchable text from file attachments (PDF, DOCX,
+// HTML and plain text) embedded in documents as base64 or referenced by URL.
+package attach
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"bright/models"
+)
+
+// Defaults for unset attachment settings
+const (
+	DefaultMaxBytes = 10 << 20
+	DefaultTimeout  = 10 * time.Second
+)
+
+// Content types the processor can extract text from
+const (
+	TypePDF  = "application/pdf"
+	TypeDOCX = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	TypeHTML = "text/html"
+	TypeText = "text/plain"
+)
+
+// ErrUnsupportedType is returned for attachments of an unknown format
+var ErrUnsupportedType = errors.New("unsupported attachment type")
+
+// client fetches URL attachments; the timeout comes from each request's context
+var client = &http.Client{}
+
+// Validate checks the attachment settings of an index
+func Validate(settings []models.AttachmentSettings) error {
+	targets := make(map[string]bool, len(settings))
+	for _, s := range settings {
+		if s.Field == "" || strings.HasPrefix(s.Field, "_") {
+			return fmt.Errorf("invalid attachment field %q", s.Field)
+		}
+		if strings.HasPrefix(s.TargetField, "_") {
+			return fmt.Errorf("invalid attachment targetField %q", s.TargetField)
+		}
+		if s.MaxBytes < 0 || s.TimeoutMs < 0 {
+			return fmt.Errorf("attachment maxBytes and timeoutMs must not be negative")
+		}
+		target := targetField(s)
+		if targets[target] {
+			return fmt.Errorf("attachment targetField %q is used twice", target)
+		}
+		targets[target] = true
+	}
+	return nil
+}
+
+// Process replaces the attachments of every document with their text. A
+// base64 attachment is removed once extracted; a URL is kept when the text
+// goes to another field. Documents without the field are left as they are.
+// It stops at the first attachment that cannot be read.
+func Process(ctx context.Context, settings []models.AttachmentSettings, documents []map[string]any) error {
+	for i, doc := range documents {
+		for _, s := range settings {
+			value, ok := doc[s.Field]
+			if !ok || value == nil {
+				continue
+			}
+			source, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("document %d: attachment %s must be a base64 string or URL, got %T", i, s.Field, value)
+			}
+
+			text, isURL, err := extract(ctx, s, source)
+			if err != nil {
+				return fmt.Errorf("document %d: attachment %s: %w", i, s.Field, err)
+			}
+
+			target := targetField(s)
+			if target != s.Field && !isURL {
+				delete(doc, s.Field)
+			}
+			doc[target] = text
+		}
+	}
+	return nil
+}
+
+// targetField is where the text of an attachment goes; by default it
+// replaces the attachment
+func targetField(s models.AttachmentSettings) string {
+	if s.TargetField != "" {
+		return s.TargetField
+	}
+	return s.Field
+}
+
+// extract loads an attachment from a URL, data URI or plain base64 and
+// returns its text
+func extract(ctx context.Context, s models.AttachmentSettings, source string) (string, bool, error) {
+	maxBytes := DefaultMaxBytes
+	if s.MaxBytes > 0 {
+		maxBytes = s.MaxBytes
+	}
+
+	var data []byte
+	var contentType string
+	isURL := strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+
+	switch {
+	case isURL:
+		if !s.AllowURLs {
+			return "", true, fmt.Errorf("URL attachments are not enabled")
+		}
+		timeout := DefaultTimeout
+		if s.TimeoutMs > 0 {
+			timeout = time.Duration(s.TimeoutMs) * time.Millisecond
+		}
+		var err error
+		data, contentType, err = fetch(ctx, source, maxBytes, timeout)
+		if err != nil {
+			return "", true, err
+		}
+	case strings.HasPrefix(source, "data:"):
+		header, encoded, found := strings.Cut(source[len("data:"):], ",")
+		if !found || !strings.HasSuffix(header, ";base64") {
+			return "", false, fmt.Errorf("data URI must be base64 encoded")
+		}
+		contentType = strings.TrimSuffix(header, ";base64")
+		var err error
+		if data, err = decodeBase64(encoded, maxBytes); err != nil {
+			return "", false, err
+		}
+	default:
+		var err error
+		if data, err = decodeBase64(source, maxBytes); err != nil {
+			return "", false, err
+		}
+	}
+
+	text, err := Extract(data, contentType, maxBytes)
+	return text, isURL, err
+}
+
+// decodeBase64 decodes an attachment, with or without padding
+func decodeBase64(encoded string, maxBytes int) ([]byte, error) {
+	encoded = strings.TrimRight(strings.TrimSpace(encoded), "=")
+	if base64.RawStdEncoding.DecodedLen(len(encoded)) > maxBytes {
+		return nil, fmt.Errorf("attachment exceeds %d bytes", maxBytes)
+	}
+	data, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64: %w", err)
+	}
+	return data, nil
+}
+
+// fetch downloads a URL attachment and returns it with its content type
+func fetch(ctx context.Context, url string, maxBytes int, timeout time.Duration) ([]byte, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch attachment: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to fetch attachment: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxBytes)+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch attachment: %w", err)
+	}
+	if len(data) > maxBytes {
+		return nil, "", fmt.Errorf("attachment exceeds %d bytes", maxBytes)
+	}
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+// Extract returns the text of a file. The content type is sniffed when it is
+// empty or generic. maxBytes (DefaultMaxBytes if zero) bounds what
+// compressed formats may inflate to.
+func Extract(data []byte, contentType string, maxBytes int) (string, error) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	switch detectType(data, contentType) {
+	case TypePDF:
+		return extractPDF(data, maxBytes)
+	case TypeDOCX:
+		return extractDOCX(data, maxBytes)
+	case TypeHTML:
+		return extractHTML(data), nil
+	case TypeText:
+		return string(data), nil
+	}
+	return "", ErrUnsupportedType
+}
+
+// detectType resolves the content type of an attachment
+func detectType(data []byte, contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case TypePDF, TypeDOCX, TypeHTML, TypeText:
+		return mediaType
+	case "application/xhtml+xml":
+		return TypeHTML
+	}
+
+	switch {
+	case bytes.HasPrefix(data, []byte("%PDF-")):
+		return TypePDF
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return TypeDOCX
+	}
+
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	switch sniffed {
+	case TypeHTML, TypeText:
+		return sniffed
+	}
+	return ""
+}
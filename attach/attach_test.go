@@ -0,0 +1,147 @@
+package attach
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bright/models"
+)
+
+// buildPDF returns a one-page PDF with a Flate-compressed content stream
+func buildPDF(content string) []byte {
+	var compressed bytes.Buffer
+	w := zlib.NewWriter(&compressed)
+	w.Write([]byte(content))
+	w.Close()
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	pdf.WriteString("2 0 obj\n<< /Type /Page /Subtype /Fake >>\nendobj\n")
+	fmt.Fprintf(&pdf, "3 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", compressed.Len())
+	pdf.Write(compressed.Bytes())
+	pdf.WriteString("\nendstream\nendobj\n%%EOF\n")
+	return pdf.Bytes()
+}
+
+// buildDOCX returns a minimal Word document with the given paragraphs
+func buildDOCX(t *testing.T, paragraphs ...string) []byte {
+	var body strings.Builder
+	for _, p := range paragraphs {
+		fmt.Fprintf(&body, `<w:p><w:r><w:t>%s</w:t></w:r></w:p>`, p)
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	file, err := archive.Create("word/document.xml")
+	if err != nil {
+		t.Fatalf("Failed to build docx: %v", err)
+	}
+	fmt.Fprintf(file, `<?xml version="1.0"?><w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>%s</w:body></w:document>`, body.String())
+	archive.Close()
+	return buf.Bytes()
+}
+
+func TestExtractFormats(t *testing.T) {
+	cases := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"pdf", buildPDF("BT /F1 12 Tf 72 712 Td (Quarterly ) Tj [(re) -20 (port) -300 (2024)] TJ T* (caf\\351 \\(draft\\)) Tj ET"), "Quarterly report 2024\ncafé (draft)"},
+		{"docx", buildDOCX(t, "Employment contract", "Signed &amp; dated"), "Employment contract\nSigned & dated"},
+		{"html", []byte("<html><head><style>p{}</style><script>alert(1)</script></head><body><h1>Title</h1><p>Fish &amp; chips</p></body></html>"), "Title\nFish & chips"},
+		{"text", []byte("plain notes"), "plain notes"},
+	}
+
+	for _, tc := range cases {
+		got, err := Extract(tc.data, "", 0)
+		if err != nil {
+			t.Errorf("%s: extract failed: %v", tc.name, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	if _, err := Extract([]byte{0x89, 'P', 'N', 'G', 0, 0, 0, 0}, "", 0); err == nil {
+		t.Error("Expected binary data to be rejected")
+	}
+}
+
+// TestExtractBoundsDecompression tests that compressed attachments can't
+// inflate past their size limit
+func TestExtractBoundsDecompression(t *testing.T) {
+	const maxBytes = 1 << 10
+
+	// 64 MB of shown text compresses to a few dozen kilobytes
+	pdf := buildPDF("BT " + strings.Repeat("(bomb) Tj ", 64<<20/10) + "ET")
+	text, err := Extract(pdf, "", maxBytes)
+	if err != nil {
+		t.Fatalf("Failed to extract pdf: %v", err)
+	}
+	if len(text) > maxExpansion*maxBytes || !strings.HasPrefix(text, "bomb") {
+		t.Errorf("Expected at most %d bytes of text, got %d", maxExpansion*maxBytes, len(text))
+	}
+
+	docx := buildDOCX(t, strings.Repeat("bomb ", 64<<20/5))
+	text, err = Extract(docx, "", maxBytes)
+	if err != nil {
+		t.Fatalf("Failed to extract docx: %v", err)
+	}
+	if len(text) > maxBytes || !strings.HasPrefix(text, "bomb") {
+		t.Errorf("Expected at most %d bytes of text, got %d", maxBytes, len(text))
+	}
+}
+
+func TestProcessSources(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<p>fetched page</p>"))
+	}))
+	defer server.Close()
+
+	encoded := base64.StdEncoding.EncodeToString(buildDOCX(t, "invoice"))
+	docs := []map[string]any{
+		{"id": "1", "file": encoded},
+		{"id": "2", "file": "data:text/plain;base64," + base64.StdEncoding.EncodeToString([]byte("memo"))},
+		{"id": "3", "file": server.URL},
+		{"id": "4"},
+	}
+
+	settings := []models.AttachmentSettings{{Field: "file", TargetField: "content", AllowURLs: true}}
+	if err := Process(context.Background(), settings, docs); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+
+	if docs[0]["content"] != "invoice" || docs[0]["file"] != nil {
+		t.Errorf("Expected base64 replaced by its text, got %v", docs[0])
+	}
+	if docs[1]["content"] != "memo" {
+		t.Errorf("Expected data URI text, got %v", docs[1])
+	}
+	if docs[2]["content"] != "fetched page" || docs[2]["file"] != server.URL {
+		t.Errorf("Expected fetched text with the URL kept, got %v", docs[2])
+	}
+	if len(docs[3]) != 1 {
+		t.Errorf("Expected documents without attachments untouched, got %v", docs[3])
+	}
+
+	disabled := []models.AttachmentSettings{{Field: "file"}}
+	if err := Process(context.Background(), disabled, []map[string]any{{"file": server.URL}}); err == nil {
+		t.Error("Expected URL attachments to need allowUrls")
+	}
+
+	limited := []models.AttachmentSettings{{Field: "file", MaxBytes: 4}}
+	if err := Process(context.Background(), limited, []map[string]any{{"file": encoded}}); err == nil {
+		t.Error("Expected attachments over maxBytes to be rejected")
+	}
+}
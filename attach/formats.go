@@ -0,0 +1,345 @@
+package attach
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	scriptPattern     = regexp.MustCompile(`(?is)<script\b.*?</script\s*>`)
+	stylePattern      = regexp.MustCompile(`(?is)<style\b.*?</style\s*>`)
+	commentPattern    = regexp.MustCompile(`(?s)<!--.*?-->`)
+	blockPattern      = regexp.MustCompile(`(?i)</?(p|div|br|li|tr|h[1-6]|section|article|header|footer|title)\b[^>]*>`)
+	tagPattern        = regexp.MustCompile(`(?s)<[^>]*>`)
+	spacePattern      = regexp.MustCompile(`[ \t\r\f\v]+`)
+	blankLinesPattern = regexp.MustCompile(`\n\s*\n+`)
+)
+
+// extractHTML strips markup, scripts and styles and decodes entities
+func extractHTML(data []byte) string {
+	text := string(data)
+	text = scriptPattern.ReplaceAllString(text, " ")
+	text = stylePattern.ReplaceAllString(text, " ")
+	text = commentPattern.ReplaceAllString(text, " ")
+	text = blockPattern.ReplaceAllString(text, "\n")
+	text = tagPattern.ReplaceAllString(text, " ")
+	return normalizeSpace(html.UnescapeString(text))
+}
+
+// normalizeSpace collapses runs of spaces and blank lines
+func normalizeSpace(text string) string {
+	text = spacePattern.ReplaceAllString(text, " ")
+	text = blankLinesPattern.ReplaceAllString(text, "\n")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// maxExpansion bounds the content decompressed from an attachment, relative
+// to the size attachments are limited to, so that a small compressed file
+// can't inflate to gigabytes
+const maxExpansion = 4
+
+// extractDOCX reads the text runs of a Word document, one line per
+// paragraph, up to maxBytes of text
+func extractDOCX(data []byte, maxBytes int) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("invalid docx: %w", err)
+	}
+
+	for _, file := range archive.File {
+		if file.Name != "word/document.xml" {
+			continue
+		}
+		reader, err := file.Open()
+		if err != nil {
+			return "", fmt.Errorf("invalid docx: %w", err)
+		}
+		defer reader.Close()
+		return readWordXML(reader, maxBytes)
+	}
+	return "", fmt.Errorf("%w: zip archive is not a docx document", ErrUnsupportedType)
+}
+
+// readWordXML collects the w:t text of a document.xml. At most
+// maxExpansion*maxBytes of XML and maxBytes of text are read; longer
+// documents are truncated.
+func readWordXML(r io.Reader, maxBytes int) (string, error) {
+	var b strings.Builder
+	limited := &io.LimitedReader{R: r, N: int64(maxExpansion) * int64(maxBytes)}
+	decoder := xml.NewDecoder(limited)
+	inText := false
+	for b.Len() < maxBytes {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if limited.N <= 0 {
+				break
+			}
+			return "", fmt.Errorf("invalid docx: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				b.WriteByte(' ')
+			case "br", "cr":
+				b.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				b.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				b.Write(t[:min(len(t), max(maxBytes-b.Len(), 0))])
+			}
+		}
+	}
+	return normalizeSpace(b.String()), nil
+}
+
+var (
+	streamPattern = regexp.MustCompile(`>>\s*stream\r?\n`)
+	filterPattern = regexp.MustCompile(`/Filter\s*\[?\s*/(\w+)`)
+)
+
+// extractPDF reads the text shown by the content streams of a PDF. Only
+// uncompressed and Flate-compressed streams with simple font encodings are
+// understood; scanned documents have no text to extract. Streams are
+// inflated up to maxExpansion*maxBytes in total, and the text after that is
+// left out.
+func extractPDF(data []byte, maxBytes int) (string, error) {
+	var b strings.Builder
+	inflateBudget := int64(maxExpansion) * int64(maxBytes)
+	for _, match := range streamPattern.FindAllIndex(data, -1) {
+		// The stream dictionary follows the "N 0 obj" header of its object
+		header := max(bytes.LastIndex(data[:match[0]], []byte("obj")), 0)
+		dict := data[header:match[0]]
+		start := match[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		stream := data[start : start+end]
+
+		// Images, fonts and embedded files are not page content
+		if bytes.Contains(dict, []byte("/Subtype")) || bytes.Contains(dict, []byte("/Length1")) {
+			continue
+		}
+
+		if filter := filterPattern.FindSubmatch(dict); filter != nil {
+			if string(filter[1]) != "FlateDecode" {
+				continue
+			}
+			reader, err := zlib.NewReader(bytes.NewReader(stream))
+			if err != nil {
+				continue
+			}
+			if inflateBudget <= 0 {
+				reader.Close()
+				break
+			}
+			// Streams are often followed by padding, so a truncated read still counts
+			stream, _ = io.ReadAll(io.LimitReader(reader, inflateBudget))
+			reader.Close()
+			inflateBudget -= int64(len(stream))
+		}
+
+		readContentStream(&b, stream)
+	}
+
+	if b.Len() == 0 && !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return "", fmt.Errorf("invalid pdf")
+	}
+	return normalizeSpace(b.String()), nil
+}
+
+// readContentStream appends the strings shown by the text operators of a
+// content stream (Tj, TJ, ' and ")
+func readContentStream(b *strings.Builder, content []byte) {
+	var operands []string
+	inArray := false
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == '(':
+			s, next := readLiteralString(content, i)
+			operands = append(operands, s)
+			i = next
+		case c == '<' && i+1 < len(content) && content[i+1] != '<':
+			s, next := readHexString(content, i)
+			operands = append(operands, s)
+			i = next
+		case c == '[':
+			inArray = true
+			operands = operands[:0]
+			i++
+		case c == ']':
+			inArray = false
+			i++
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case isPDFSpace(c):
+			i++
+		default:
+			start := i
+			for i < len(content) && !isPDFSpace(content[i]) && !isPDFDelimiter(content[i]) {
+				i++
+			}
+			if i == start {
+				i++
+				continue
+			}
+			token := string(content[start:i])
+
+			// Large negative kerning in a TJ array separates words
+			if inArray {
+				if kerning, err := strconv.ParseFloat(token, 64); err == nil && kerning <= -200 {
+					operands = append(operands, " ")
+				}
+				continue
+			}
+
+			switch token {
+			case "Tj", "TJ":
+				b.WriteString(strings.Join(operands, ""))
+			case "'", "\"":
+				b.WriteByte('\n')
+				b.WriteString(strings.Join(operands, ""))
+			case "T*", "Td", "TD", "ET":
+				b.WriteByte('\n')
+			case "Tm":
+				b.WriteByte(' ')
+			}
+			if !isNumber(token) && !strings.HasPrefix(token, "/") {
+				operands = operands[:0]
+			}
+		}
+	}
+}
+
+// readLiteralString reads a (...) string starting at i, with its escapes
+// and balanced parentheses
+func readLiteralString(content []byte, i int) (string, int) {
+	var b strings.Builder
+	depth := 0
+	for i < len(content) {
+		c := content[i]
+		switch {
+		case c == '\\' && i+1 < len(content):
+			i++
+			switch e := content[i]; e {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// Line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					value := 0
+					for n := 0; n < 3 && i < len(content) && content[i] >= '0' && content[i] <= '7'; n++ {
+						value = value*8 + int(content[i]-'0')
+						i++
+					}
+					b.WriteRune(rune(value))
+					continue
+				}
+				b.WriteRune(rune(e))
+			}
+			i++
+			continue
+		case c == '(':
+			depth++
+			if depth > 1 {
+				b.WriteRune(rune(c))
+			}
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return b.String(), i + 1
+			}
+			b.WriteRune(rune(c))
+		default:
+			// Bytes of simple font encodings are read as Latin-1
+			b.WriteRune(rune(c))
+		}
+		i++
+	}
+	return b.String(), i
+}
+
+// readHexString reads a <...> string starting at i. Two-byte glyph IDs of
+// composite fonts cannot be mapped without the font, so only printable
+// single-byte strings are kept.
+func readHexString(content []byte, i int) (string, int) {
+	end := bytes.IndexByte(content[i:], '>')
+	if end < 0 {
+		return "", len(content)
+	}
+	digits := make([]byte, 0, end)
+	for _, c := range content[i+1 : i+end] {
+		if !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	decoded, err := hex.DecodeString(string(digits))
+	if err != nil {
+		return "", i + end + 1
+	}
+	var b strings.Builder
+	for _, value := range decoded {
+		if value < 0x20 && value != '\n' && value != '\t' {
+			return "", i + end + 1
+		}
+		b.WriteRune(rune(value))
+	}
+	return b.String(), i + end + 1
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func isNumber(token string) bool {
+	for _, c := range token {
+		if (c < '0' || c > '9') && c != '.' && c != '-' && c != '+' {
+			return false
+		}
+	}
+	return token != ""
+}
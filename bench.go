@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bright/bench"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+)
+
+type BenchCmd struct {
+	Target      string   `help:"Base URL of the Bright server" default:"http://localhost:3000"`
+	MasterKey   string   `help:"Master key for authentication" env:"BRIGHT_MASTER_KEY"`
+	Index       string   `help:"Index used for the benchmark" default:"bench"`
+	Documents   int      `help:"Number of synthetic documents to index" default:"10000"`
+	BatchSize   int      `help:"Documents per indexing request" default:"1000"`
+	Concurrency int      `help:"Number of concurrent clients" default:"4"`
+	Searches    int      `help:"Number of search requests to issue" default:"1000"`
+	Query       []string `help:"Search terms to use (repeatable, defaults to a built-in set)"`
+	KeepIndex   bool     `help:"Keep the benchmark index after the run"`
+
+	Generate []int  `help:"Only generate JSON Lines datasets of the given sizes and exit" placeholder:"SIZE"`
+	Output   string `help:"Output directory for generated datasets" default:"benchmarks" type:"path"`
+}
+
+func (b *BenchCmd) Run() error {
+	if len(b.Generate) > 0 {
+		for _, size := range b.Generate {
+			filename, err := bench.WriteDataset(b.Output, size)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Generated %d products to %s\n", size, filename)
+		}
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	runner := bench.NewRunner(bench.Config{
+		Target:      b.Target,
+		MasterKey:   b.MasterKey,
+		Index:       b.Index,
+		Documents:   b.Documents,
+		BatchSize:   b.BatchSize,
+		Concurrency: b.Concurrency,
+		Searches:    b.Searches,
+		Queries:     b.Query,
+		KeepIndex:   b.KeepIndex,
+	})
+
+	fmt.Printf("Benchmarking %s (index %q, %d documents, %d searches, concurrency %d)\n\n",
+		b.Target, b.Index, b.Documents, b.Searches, b.Concurrency)
+
+	report, err := runner.Run(ctx)
+	if report != nil {
+		report.Print(os.Stdout)
+	}
+	return err
+}
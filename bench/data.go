@@ -0,0 +1,165 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+
+	"github.com/bytedance/sonic"
+)
+
+// Product is a synthetic catalog document used by benchmark workloads
+type Product struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Price       float64  `json:"price"`
+	Category    string   `json:"category"`
+	Tags        []string `json:"tags"`
+	InStock     bool     `json:"inStock"`
+}
+
+var (
+	categories = []string{
+		"Electronics", "Clothing", "Books", "Home & Garden", "Sports", "Toys",
+		"Beauty & Health", "Food & Beverage", "Automotive", "Pet Supplies",
+		"Office Supplies", "Music & Instruments", "Tools & Hardware", "Jewelry",
+		"Baby Products", "Art & Craft", "Outdoor & Camping", "Furniture",
+	}
+
+	productNames = []string{
+		// Electronics
+		"Laptop", "Computer", "Smartphone", "Tablet", "Headphones",
+		"Monitor", "Keyboard", "Mouse", "Webcam", "Microphone",
+		"Speaker", "Camera", "Smartwatch", "TV", "Gaming Console",
+		"Router", "Hard Drive", "USB Cable", "Power Bank", "Charger",
+		// Clothing
+		"T-Shirt", "Jeans", "Sneakers", "Jacket", "Hat",
+		"Dress", "Sweater", "Shorts", "Boots", "Sandals",
+		"Scarf", "Gloves", "Socks", "Belt", "Sunglasses",
+		// Books & Media
+		"Novel", "Textbook", "Magazine", "Comic", "Dictionary",
+		"Cookbook", "Biography", "Guidebook", "Workbook", "Atlas",
+		// Home & Garden
+		"Chair", "Table", "Lamp", "Plant", "Curtains",
+		"Vase", "Mirror", "Rug", "Pillow", "Blanket",
+		"Clock", "Frame", "Candle", "Pot", "Garden Tool",
+		// Sports & Fitness
+		"Basketball", "Soccer Ball", "Tennis Racket", "Yoga Mat", "Dumbbell",
+		"Bicycle", "Skateboard", "Running Shoes", "Gym Bag", "Water Bottle",
+		"Resistance Band", "Jump Rope", "Foam Roller", "Protein Powder", "Fitness Tracker",
+		// Toys & Games
+		"Action Figure", "Board Game", "Puzzle", "Doll", "LEGO Set",
+		"RC Car", "Building Blocks", "Plush Toy", "Card Game", "Video Game",
+		// Kitchen & Dining
+		"Blender", "Coffee Maker", "Toaster", "Mixer", "Knife Set",
+		"Pan", "Pot", "Cutting Board", "Spatula", "Measuring Cup",
+		// Beauty & Health
+		"Shampoo", "Conditioner", "Face Cream", "Lipstick", "Perfume",
+		"Hair Dryer", "Straightener", "Nail Polish", "Makeup Brush", "Skincare Set",
+		// Tools & Hardware
+		"Drill", "Hammer", "Screwdriver", "Wrench", "Pliers",
+		"Saw", "Level", "Tape Measure", "Toolbox", "Ladder",
+	}
+
+	adjectives = []string{
+		"Premium", "Professional", "Deluxe", "Standard", "Basic",
+		"Wireless", "Portable", "Compact", "Ergonomic", "Modern",
+		"Classic", "Vintage", "Limited Edition", "Heavy-Duty", "Lightweight",
+		"Ultra", "Pro", "Advanced", "Elite", "Supreme",
+		"Smart", "Digital", "Automatic", "Manual", "Rechargeable",
+		"Waterproof", "Durable", "Flexible", "Adjustable", "Multi-Purpose",
+		"High-Performance", "Energy-Efficient", "Eco-Friendly", "Luxury", "Designer",
+		"Industrial", "Commercial", "Residential", "Outdoor", "Indoor",
+		"Universal", "Custom", "Personalized", "Handmade", "Artisan",
+	}
+
+	tags = []string{
+		"sale", "new", "popular", "trending", "bestseller",
+		"eco-friendly", "premium", "budget", "featured", "clearance",
+		"limited-time", "exclusive", "top-rated", "staff-pick", "customer-favorite",
+		"free-shipping", "on-sale", "deal", "hot", "must-have",
+		"seasonal", "gift-idea", "bundle", "value-pack", "certified",
+		"warranty", "guaranteed", "authentic", "imported", "local",
+		"handpicked", "recommended", "award-winning", "innovative", "upgraded",
+	}
+)
+
+// DefaultQueries returns a set of representative search terms for search workloads
+func DefaultQueries() []string {
+	return []string{
+		"laptop", "wireless", "premium", "shoes", "coffee",
+		"Electronics", "game", "portable", "smart", "vintage",
+	}
+}
+
+func randomString(arr []string) string {
+	return arr[rand.Intn(len(arr))]
+}
+
+func randomTags() []string {
+	count := rand.Intn(3) + 1
+	result := make([]string, 0, count)
+	used := make(map[string]bool)
+
+	for i := 0; i < count; i++ {
+		tag := randomString(tags)
+		if !used[tag] {
+			result = append(result, tag)
+			used[tag] = true
+		}
+	}
+
+	return result
+}
+
+// GenerateProduct returns a random product with the given numeric ID
+func GenerateProduct(id int) Product {
+	return Product{
+		ID:          fmt.Sprintf("%d", id),
+		Name:        fmt.Sprintf("%s %s", randomString(adjectives), randomString(productNames)),
+		Description: fmt.Sprintf("High-quality %s for all your needs. Perfect for everyday use.", randomString(productNames)),
+		Price:       float64(rand.Intn(500)+10) + rand.Float64(),
+		Category:    randomString(categories),
+		Tags:        randomTags(),
+		InStock:     rand.Float32() > 0.2, // 80% in stock
+	}
+}
+
+// WriteJSONL writes count products (IDs starting at 1) as JSON Lines to w
+func WriteJSONL(w io.Writer, count int) error {
+	for i := 1; i <= count; i++ {
+		data, err := sonic.Marshal(GenerateProduct(i))
+		if err != nil {
+			return fmt.Errorf("failed to marshal product: %w", err)
+		}
+		data = append(data, '\n')
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteDataset generates a JSON Lines dataset of the given size into dir
+// and returns the path of the written file
+func WriteDataset(dir string, size int) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	filename := filepath.Join(dir, fmt.Sprintf("test_data_%d.jsonl", size))
+	file, err := os.Create(filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	if err := WriteJSONL(file, size); err != nil {
+		return "", err
+	}
+
+	return filename, nil
+}
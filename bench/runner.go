@@ -0,0 +1,273 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// Config holds the workload settings for a benchmark run
+type Config struct {
+	Target      string   // Base URL of the Bright server
+	MasterKey   string   // Master key sent as a Bearer token (optional)
+	Index       string   // Index used for the run
+	Documents   int      // Number of documents to index
+	BatchSize   int      // Documents per indexing request
+	Concurrency int      // Concurrent clients per phase
+	Searches    int      // Number of search requests to issue
+	Queries     []string // Search terms, picked round-robin
+	KeepIndex   bool     // Keep the index after the run
+}
+
+// PhaseResult contains measurements for a single workload phase
+type PhaseResult struct {
+	Name      string
+	Requests  int
+	Errors    int
+	Items     int // Documents indexed or queries served
+	Duration  time.Duration
+	latencies []time.Duration
+}
+
+// Percentile returns the latency at the given percentile (0-100)
+func (p *PhaseResult) Percentile(pct float64) time.Duration {
+	if len(p.latencies) == 0 {
+		return 0
+	}
+	idx := int(float64(len(p.latencies)-1) * pct / 100)
+	return p.latencies[idx]
+}
+
+// Throughput returns processed items per second
+func (p *PhaseResult) Throughput() float64 {
+	if p.Duration <= 0 {
+		return 0
+	}
+	return float64(p.Items) / p.Duration.Seconds()
+}
+
+// Report contains the results of all workload phases
+type Report struct {
+	Phases []*PhaseResult
+}
+
+// Print writes a human readable summary of the report
+func (r *Report) Print(w io.Writer) {
+	for _, p := range r.Phases {
+		fmt.Fprintf(w, "%s\n", p.Name)
+		fmt.Fprintf(w, "  requests:   %d (%d errors)\n", p.Requests, p.Errors)
+		fmt.Fprintf(w, "  duration:   %s\n", p.Duration.Round(time.Millisecond))
+		fmt.Fprintf(w, "  throughput: %.1f/s\n", p.Throughput())
+		fmt.Fprintf(w, "  latency:    p50=%s p90=%s p99=%s max=%s\n",
+			p.Percentile(50).Round(time.Microsecond),
+			p.Percentile(90).Round(time.Microsecond),
+			p.Percentile(99).Round(time.Microsecond),
+			p.Percentile(100).Round(time.Microsecond))
+	}
+}
+
+// Runner executes benchmark workloads against a Bright server
+type Runner struct {
+	config     Config
+	httpClient *http.Client
+}
+
+// NewRunner creates a new Runner, applying defaults to unset settings
+func NewRunner(cfg Config) *Runner {
+	if cfg.Target == "" {
+		cfg.Target = "http://localhost:3000"
+	}
+	cfg.Target = strings.TrimRight(cfg.Target, "/")
+	if cfg.Index == "" {
+		cfg.Index = "bench"
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1000
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 4
+	}
+	if len(cfg.Queries) == 0 {
+		cfg.Queries = DefaultQueries()
+	}
+
+	return &Runner{
+		config:     cfg,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Run executes the indexing and search phases and returns the report
+func (r *Runner) Run(ctx context.Context) (*Report, error) {
+	// Start from an empty index; a missing index is not an error
+	if _, err := r.do(ctx, http.MethodDelete, "/indexes/"+r.config.Index, nil); err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+
+	if r.config.Documents > 0 {
+		phase, err := r.runIndexing(ctx)
+		if err != nil {
+			return nil, err
+		}
+		report.Phases = append(report.Phases, phase)
+	}
+
+	if r.config.Searches > 0 {
+		report.Phases = append(report.Phases, r.runSearches(ctx))
+	}
+
+	if !r.config.KeepIndex {
+		if _, err := r.do(ctx, http.MethodDelete, "/indexes/"+r.config.Index, nil); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// runIndexing indexes the configured number of documents in concurrent batches
+func (r *Runner) runIndexing(ctx context.Context) (*PhaseResult, error) {
+	// Create the index up front so concurrent batches don't race on auto-creation
+	status, err := r.do(ctx, http.MethodPost, "/indexes?id="+url.QueryEscape(r.config.Index)+"&primaryKey=id", nil)
+	if err != nil {
+		return nil, err
+	}
+	if status >= 300 {
+		return nil, fmt.Errorf("failed to create index %s: status %d", r.config.Index, status)
+	}
+
+	batches := make(chan []byte)
+	go func() {
+		defer close(batches)
+		var buf bytes.Buffer
+		count := 0
+		for i := 1; i <= r.config.Documents; i++ {
+			data, _ := sonic.Marshal(GenerateProduct(i))
+			buf.Write(data)
+			buf.WriteByte('\n')
+			count++
+			if count == r.config.BatchSize || i == r.config.Documents {
+				select {
+				case batches <- bytes.Clone(buf.Bytes()):
+				case <-ctx.Done():
+					return
+				}
+				buf.Reset()
+				count = 0
+			}
+		}
+	}()
+
+	path := "/indexes/" + r.config.Index + "/documents?format=jsoneachrow"
+	phase := r.measure(ctx, "indexing", func(submit func(func() (int, error))) {
+		for batch := range batches {
+			submit(func() (int, error) {
+				status, err := r.do(ctx, http.MethodPost, path, batch)
+				if err == nil && status >= 300 {
+					err = fmt.Errorf("status %d", status)
+				}
+				return bytes.Count(batch, []byte{'\n'}), err
+			})
+		}
+	})
+
+	return phase, nil
+}
+
+// runSearches issues the configured number of search requests
+func (r *Runner) runSearches(ctx context.Context) *PhaseResult {
+	path := "/indexes/" + r.config.Index + "/searches?q="
+
+	return r.measure(ctx, "search", func(submit func(func() (int, error))) {
+		for i := 0; i < r.config.Searches; i++ {
+			q := r.config.Queries[i%len(r.config.Queries)]
+			submit(func() (int, error) {
+				status, err := r.do(ctx, http.MethodPost, path+url.QueryEscape(q), nil)
+				if err == nil && status >= 300 {
+					err = fmt.Errorf("status %d", status)
+				}
+				return 1, err
+			})
+		}
+	})
+}
+
+// measure runs the operations produced by generate on the configured number
+// of workers and records per-request latencies
+func (r *Runner) measure(ctx context.Context, name string, generate func(submit func(func() (int, error)))) *PhaseResult {
+	phase := &PhaseResult{Name: name}
+	ops := make(chan func() (int, error))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	for range r.config.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for op := range ops {
+				opStart := time.Now()
+				items, err := op()
+				latency := time.Since(opStart)
+
+				mu.Lock()
+				phase.Requests++
+				phase.latencies = append(phase.latencies, latency)
+				if err != nil {
+					phase.Errors++
+				} else {
+					phase.Items += items
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	generate(func(op func() (int, error)) {
+		select {
+		case ops <- op:
+		case <-ctx.Done():
+		}
+	})
+	close(ops)
+	wg.Wait()
+
+	phase.Duration = time.Since(start)
+	sort.Slice(phase.latencies, func(i, j int) bool {
+		return phase.latencies[i] < phase.latencies[j]
+	})
+
+	return phase
+}
+
+// do performs an HTTP request against the target and returns the status code
+func (r *Runner) do(ctx context.Context, method, path string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, r.config.Target+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	if r.config.MasterKey != "" {
+		req.Header.Set("Authorization", "Bearer "+r.config.MasterKey)
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import "net/http"
+
+type ClusterCmd struct {
+	Status         ClusterStatusCmd         `cmd:"" help:"Show cluster status of the target node"`
+	Nodes          ClusterNodesCmd          `cmd:"" help:"List cluster members"`
+	Join           ClusterJoinCmd           `cmd:"" help:"Add a node to the cluster"`
+	Remove         ClusterRemoveCmd         `cmd:"" help:"Remove a node from the cluster"`
+	TransferLeader ClusterTransferLeaderCmd `cmd:"" help:"Transfer leadership to another node"`
+}
+
+type ClusterStatusCmd struct {
+	RemoteFlags `embed:""`
+}
+
+func (c *ClusterStatusCmd) Run() error {
+	body, err := c.call(http.MethodGet, "/cluster/status", nil)
+	if err != nil {
+		return err
+	}
+	printJSON(body)
+	return nil
+}
+
+type ClusterNodesCmd struct {
+	RemoteFlags `embed:""`
+}
+
+func (c *ClusterNodesCmd) Run() error {
+	body, err := c.call(http.MethodGet, "/cluster/nodes", nil)
+	if err != nil {
+		return err
+	}
+	printJSON(body)
+	return nil
+}
+
+type ClusterJoinCmd struct {
+	RemoteFlags `embed:""`
+
+	NodeID string `arg:"" help:"ID of the node to add"`
+	Addr   string `arg:"" help:"Raft address of the node to add (host:port)"`
+}
+
+func (c *ClusterJoinCmd) Run() error {
+	body, err := c.call(http.MethodPost, "/cluster/join", map[string]string{
+		"node_id": c.NodeID,
+		"addr":    c.Addr,
+	})
+	if err != nil {
+		return err
+	}
+	printJSON(body)
+	return nil
+}
+
+type ClusterRemoveCmd struct {
+	RemoteFlags `embed:""`
+
+	NodeID string `arg:"" help:"ID of the node to remove"`
+}
+
+func (c *ClusterRemoveCmd) Run() error {
+	body, err := c.call(http.MethodPost, "/cluster/remove", map[string]string{
+		"node_id": c.NodeID,
+	})
+	if err != nil {
+		return err
+	}
+	printJSON(body)
+	return nil
+}
+
+type ClusterTransferLeaderCmd struct {
+	RemoteFlags `embed:""`
+
+	NodeID string `arg:"" optional:"" help:"ID of the node to promote (defaults to the most up-to-date follower)"`
+}
+
+func (c *ClusterTransferLeaderCmd) Run() error {
+	body, err := c.call(http.MethodPost, "/cluster/transfer-leader", map[string]string{
+		"node_id": c.NodeID,
+	})
+	if err != nil {
+		return err
+	}
+	printJSON(body)
+	return nil
+}
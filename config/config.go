@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 )
@@ -14,9 +15,91 @@ type Config struct {
 	LogLevel  string `env:"BRIGHT_LOG_LEVEL" envDefault:"info"`
 	DataPath  string `env:"BRIGHT_DATA_PATH" envDefault:"./data"`
 
+	// Largest accepted request body in bytes, uploaded index archives included
+	BodyLimit int `env:"BRIGHT_BODY_LIMIT" envDefault:"4194304"`
+
 	// Auto-create indexes on first document insert
 	AutoCreateIndex bool `env:"BRIGHT_AUTO_CREATE_INDEX" envDefault:"true"`
 
+	// Primary key of auto-created indexes: a fixed default, or detection
+	// trying preferred names, then exact id/_id, then a single *id attribute,
+	// then a fallback attribute filled with generated UUIDs
+	DefaultPrimaryKey    string   `env:"BRIGHT_DEFAULT_PRIMARY_KEY"`
+	PrimaryKeyCandidates []string `env:"BRIGHT_PRIMARY_KEY_CANDIDATES" envSeparator:","`
+	PreferExactIDKey     bool     `env:"BRIGHT_PREFER_EXACT_ID_KEY" envDefault:"true"`
+	PrimaryKeyFallback   string   `env:"BRIGHT_PRIMARY_KEY_FALLBACK"`
+
+	// Journal accepted document batches before indexing (single-node mode only)
+	WALEnabled bool `env:"BRIGHT_WAL_ENABLED" envDefault:"true"`
+
+	// Parallel indexing of large document batches (0 workers = number of CPUs)
+	IndexWorkers      int `env:"BRIGHT_INDEX_WORKERS" envDefault:"0"`
+	IndexSubBatchSize int `env:"BRIGHT_INDEX_SUB_BATCH_SIZE" envDefault:"1000"`
+
+	// Search responses cached until their index is written to (0 disables)
+	SearchCacheSize int `env:"BRIGHT_SEARCH_CACHE_SIZE" envDefault:"1000"`
+
+	// Bleve index type (scorch, upside_down) and key/value store (boltdb,
+	// memory) of indexes created without their own; scorch on disk if empty
+	DefaultIndexType string `env:"BRIGHT_DEFAULT_INDEX_TYPE"`
+	DefaultKVStore   string `env:"BRIGHT_DEFAULT_KV_STORE"`
+
+	// Warm up indexes (page cache and configured warmup queries) before serving
+	WarmupEnabled bool          `env:"BRIGHT_WARMUP_ENABLED" envDefault:"false"`
+	WarmupTimeout time.Duration `env:"BRIGHT_WARMUP_TIMEOUT" envDefault:"5m"`
+
+	// Index sizes are sampled every interval for the growth rates of /storage (0 disables)
+	StorageSampleInterval time.Duration `env:"BRIGHT_STORAGE_SAMPLE_INTERVAL" envDefault:"10m"`
+
+	// Indexes are checked every interval (0 disables) and their segments
+	// merged when the deleted documents or the segment count exceed these
+	// thresholds, within the daily window ("01:00-05:00", any time if empty).
+	// Indexes can override them in their compaction settings.
+	CompactionInterval        time.Duration `env:"BRIGHT_COMPACTION_INTERVAL" envDefault:"5m"`
+	CompactionMaxDeletedRatio float64       `env:"BRIGHT_COMPACTION_MAX_DELETED_RATIO" envDefault:"0.2"`
+	CompactionMaxSegments     int           `env:"BRIGHT_COMPACTION_MAX_SEGMENTS" envDefault:"20"`
+	CompactionWindow          string        `env:"BRIGHT_COMPACTION_WINDOW"`
+	CompactionTimezone        string        `env:"BRIGHT_COMPACTION_TIMEZONE"`
+
+	// Disk watermarks in percent of used space (0 disables): above the low one
+	// document writes are refused, above the high one ingresses and Raft
+	// snapshots are paused as well
+	DiskWatermarkLow  float64       `env:"BRIGHT_DISK_WATERMARK_LOW" envDefault:"90"`
+	DiskWatermarkHigh float64       `env:"BRIGHT_DISK_WATERMARK_HIGH" envDefault:"95"`
+	DiskCheckInterval time.Duration `env:"BRIGHT_DISK_CHECK_INTERVAL" envDefault:"10s"`
+
+	// Background jobs (reindex, compaction, resync, snapshot, import) run at
+	// a priority (low, normal, high) per kind ("compaction=low,resync=high")
+	// and yield to searches and document requests for up to the maximum
+	// yield between units of work
+	JobPriorities map[string]string `env:"BRIGHT_JOB_PRIORITIES" envSeparator:"," envKeyValSeparator:"="`
+	JobMaxYield   time.Duration     `env:"BRIGHT_JOB_MAX_YIELD" envDefault:"1s"`
+
+	// Connection profiles of ingresses are encrypted with this secret (the
+	// master key if empty; profiles are unavailable without either)
+	ProfileKey string `env:"BRIGHT_PROFILE_KEY"`
+
+	// Alerting rules are evaluated every interval; emails go through the SMTP server
+	AlertsInterval time.Duration `env:"BRIGHT_ALERTS_INTERVAL" envDefault:"30s"`
+	SMTPAddr       string        `env:"BRIGHT_SMTP_ADDR"` // host:port
+	SMTPUsername   string        `env:"BRIGHT_SMTP_USERNAME"`
+	SMTPPassword   string        `env:"BRIGHT_SMTP_PASSWORD"`
+	SMTPFrom       string        `env:"BRIGHT_SMTP_FROM"`
+
+	// Prometheus request metrics: duration buckets in seconds, labels
+	// (status_code, status_class, method, path) and constant labels (k=v,...)
+	MetricsBuckets     []float64         `env:"BRIGHT_METRICS_BUCKETS" envSeparator:","`
+	MetricsLabels      []string          `env:"BRIGHT_METRICS_LABELS" envSeparator:","`
+	MetricsConstLabels map[string]string `env:"BRIGHT_METRICS_CONST_LABELS" envSeparator:"," envKeyValSeparator:"=" envDefault:"service=bright"`
+
+	// Cross-cluster replication: a standby follows the change feed of the
+	// source cluster at this URL with the key (the source's master key or an
+	// admin API key), polling every interval once caught up, and serves
+	// reads only
+	ReplicateFrom     string        `env:"BRIGHT_REPLICATE_FROM"`
+	ReplicateKey      string        `env:"BRIGHT_REPLICATE_KEY"`
+	ReplicateInterval time.Duration `env:"BRIGHT_REPLICATE_INTERVAL" envDefault:"1s"`
+
 	// Raft configuration
 	RaftEnabled   bool   `env:"RAFT_ENABLED" envDefault:"false"`
 	RaftNodeID    string `env:"RAFT_NODE_ID"`
@@ -25,6 +108,25 @@ type Config struct {
 	RaftAdvertise string `env:"RAFT_ADVERTISE"` // Advertisable address for Raft
 	RaftBootstrap bool   `env:"RAFT_BOOTSTRAP" envDefault:"false"`
 	RaftPeers     string `env:"RAFT_PEERS"` // Comma-separated peer addresses
+
+	// Mirror Raft snapshots to an S3 bucket, from which a node without Raft
+	// state restores (disabled without a bucket)
+	RaftSnapshotS3Bucket    string `env:"RAFT_SNAPSHOT_S3_BUCKET"`
+	RaftSnapshotS3Prefix    string `env:"RAFT_SNAPSHOT_S3_PREFIX" envDefault:"raft-snapshots"`
+	RaftSnapshotS3Endpoint  string `env:"RAFT_SNAPSHOT_S3_ENDPOINT"`
+	RaftSnapshotS3Region    string `env:"RAFT_SNAPSHOT_S3_REGION" envDefault:"us-east-1"`
+	RaftSnapshotS3AccessKey string `env:"RAFT_SNAPSHOT_S3_ACCESS_KEY"`
+	RaftSnapshotS3SecretKey string `env:"RAFT_SNAPSHOT_S3_SECRET_KEY"`
+	RaftSnapshotS3PathStyle bool   `env:"RAFT_SNAPSHOT_S3_PATH_STYLE" envDefault:"false"`
+	RaftSnapshotS3Retain    int    `env:"RAFT_SNAPSHOT_S3_RETAIN" envDefault:"3"`
+
+	// How often a node polls the load and lag of its peers while the
+	// cluster's routing policy routes searches
+	RoutingInterval time.Duration `env:"BRIGHT_ROUTING_INTERVAL" envDefault:"1s"`
+
+	// Tags describing this node (zone=eu-west-1a,disk=ssd), reported in the
+	// cluster status and matched by the routing policy's prefer_tags
+	NodeTags map[string]string `env:"BRIGHT_NODE_TAGS" envSeparator:"," envKeyValSeparator:"="`
 }
 
 // Load reads configuration from environment variables
@@ -47,6 +149,10 @@ func Load() (*Config, error) {
 		cfg.MasterKey = getEnvWithFallback("BRIGHT_MASTER_KEY", "MASTER_KEY")
 	}
 
+	if cfg.ProfileKey == "" {
+		cfg.ProfileKey = cfg.MasterKey
+	}
+
 	if cfg.LogLevel == "" || cfg.LogLevel == "info" {
 		if logLevel := getEnvWithFallback("BRIGHT_LOG_LEVEL", "LOG_LEVEL"); logLevel != "" {
 			cfg.LogLevel = logLevel
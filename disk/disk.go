@@ -0,0 +1,251 @@
+// Package disk watches the free space of the data directory, so writes stop
+// before a full disk corrupts index segments or Bolt files.
+package disk
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// DefaultInterval is how often disk usage is checked when no interval is set
+const DefaultInterval = 10 * time.Second
+
+// Level is how full the disk is relative to the watermarks
+type Level string
+
+const (
+	// LevelOK is below the low watermark
+	LevelOK Level = "ok"
+
+	// LevelLow is at or above the low watermark: document writes are refused
+	LevelLow Level = "low"
+
+	// LevelHigh is at or above the high watermark: ingresses and snapshots
+	// are paused as well
+	LevelHigh Level = "high"
+)
+
+// severity orders levels for the watermark level gauge
+func (l Level) severity() float64 {
+	switch l {
+	case LevelLow:
+		return 1
+	case LevelHigh:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// Options configure a Monitor
+type Options struct {
+	// Low and High are the used space percentages of the watermarks
+	// (0 disables a watermark)
+	Low  float64
+	High float64
+
+	// Interval between checks (DefaultInterval if zero)
+	Interval time.Duration
+}
+
+// Status is the disk usage at the last check
+type Status struct {
+	Level       Level     `json:"level"`
+	UsedPercent float64   `json:"usedPercent"`
+	FreeBytes   uint64    `json:"freeBytes"`
+	TotalBytes  uint64    `json:"totalBytes"`
+	Low         float64   `json:"lowWatermark,omitempty"`
+	High        float64   `json:"highWatermark,omitempty"`
+	CheckedAt   time.Time `json:"checkedAt"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// Monitor checks the usage of the disk holding a path every interval and
+// notifies listeners when the watermark level changes
+type Monitor struct {
+	path     string
+	low      float64
+	high     float64
+	interval time.Duration
+	logger   *zap.Logger
+
+	// usage is replaced in tests
+	usage func(path string) (total, free uint64, err error)
+
+	status    Status
+	listeners []func(previous, current Level)
+	mu        sync.RWMutex
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewMonitor creates a monitor of the disk holding path and registers its
+// gauges. registerer may be nil.
+func NewMonitor(path string, options Options, registerer prometheus.Registerer, logger *zap.Logger) (*Monitor, error) {
+	for _, watermark := range []float64{options.Low, options.High} {
+		if watermark < 0 || watermark > 100 {
+			return nil, fmt.Errorf("disk watermarks must be between 0 and 100, got %g", watermark)
+		}
+	}
+	if options.Low > 0 && options.High > 0 && options.Low > options.High {
+		return nil, fmt.Errorf("low disk watermark %g is above the high watermark %g", options.Low, options.High)
+	}
+
+	interval := options.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	m := &Monitor{
+		path:     path,
+		low:      options.Low,
+		high:     options.High,
+		interval: interval,
+		logger:   logger,
+		usage:    usage,
+		status:   Status{Level: LevelOK, Low: options.Low, High: options.High},
+	}
+
+	if registerer != nil {
+		collectors := []prometheus.Collector{
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Name: "disk_used_percent",
+				Help: "Used space of the disk holding the data directory, in percent.",
+			}, func() float64 { return m.Status().UsedPercent }),
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Name: "disk_free_bytes",
+				Help: "Free space of the disk holding the data directory.",
+			}, func() float64 { return float64(m.Status().FreeBytes) }),
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Name: "disk_watermark_level",
+				Help: "Disk watermark level: 0 ok, 1 low (writes refused), 2 high (ingresses and snapshots paused).",
+			}, func() float64 { return m.Status().Level.severity() }),
+		}
+		for _, collector := range collectors {
+			if err := registerer.Register(collector); err != nil {
+				return nil, fmt.Errorf("failed to register disk metrics: %w", err)
+			}
+		}
+	}
+	return m, nil
+}
+
+// Enabled returns true if a watermark is set
+func (m *Monitor) Enabled() bool {
+	return m.low > 0 || m.high > 0
+}
+
+// OnChange adds a listener called after each change of the level
+func (m *Monitor) OnChange(listener func(previous, current Level)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, listener)
+}
+
+// Status returns the disk usage at the last check
+func (m *Monitor) Status() Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.status
+}
+
+// Level returns the watermark level at the last check
+func (m *Monitor) Level() Level {
+	return m.Status().Level
+}
+
+// Check measures the disk usage now and notifies listeners if the level
+// changed. A failed measurement keeps the previous level.
+func (m *Monitor) Check(now time.Time) Status {
+	total, free, err := m.usage(m.path)
+
+	m.mu.Lock()
+	previous := m.status.Level
+	status := m.status
+	status.CheckedAt = now
+	status.Error = ""
+	if err != nil {
+		status.Error = err.Error()
+	} else {
+		status.TotalBytes = total
+		status.FreeBytes = free
+		status.UsedPercent = 0
+		if total > 0 {
+			status.UsedPercent = float64(total-free) / float64(total) * 100
+		}
+		status.Level = m.level(status.UsedPercent)
+	}
+	m.status = status
+	listeners := m.listeners
+	m.mu.Unlock()
+
+	if status.Level != previous {
+		m.logger.Warn("Disk watermark level changed",
+			zap.String("from", string(previous)),
+			zap.String("to", string(status.Level)),
+			zap.Float64("used_percent", status.UsedPercent),
+			zap.Uint64("free_bytes", status.FreeBytes),
+		)
+		for _, listener := range listeners {
+			listener(previous, status.Level)
+		}
+	}
+	return status
+}
+
+// level returns the level of a used space percentage
+func (m *Monitor) level(used float64) Level {
+	switch {
+	case m.high > 0 && used >= m.high:
+		return LevelHigh
+	case m.low > 0 && used >= m.low:
+		return LevelLow
+	default:
+		return LevelOK
+	}
+}
+
+// Start checks the disk now and then every interval until Stop is called.
+// It does nothing when no watermark is set.
+func (m *Monitor) Start() {
+	if !m.Enabled() {
+		return
+	}
+
+	if status := m.Check(time.Now()); status.Error != "" {
+		m.logger.Warn("Failed to check disk usage", zap.String("path", m.path), zap.String("error", status.Error))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.done = make(chan struct{})
+
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				m.Check(now)
+			}
+		}
+	}()
+}
+
+// Stop halts the checks
+func (m *Monitor) Stop() {
+	if m.cancel == nil {
+		return
+	}
+	m.cancel()
+	<-m.done
+}
@@ -0,0 +1,75 @@
+package disk
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+func TestMonitorLevels(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m, err := NewMonitor(t.TempDir(), Options{Low: 80, High: 90}, registry, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create monitor: %v", err)
+	}
+
+	var free uint64
+	var usageErr error
+	m.usage = func(string) (uint64, uint64, error) {
+		return 1000, free, usageErr
+	}
+
+	var changes []Level
+	m.OnChange(func(previous, current Level) {
+		changes = append(changes, current)
+	})
+
+	steps := []struct {
+		free uint64
+		err  error
+		want Level
+	}{
+		{free: 500, want: LevelOK},
+		{free: 200, want: LevelLow},
+		{free: 50, want: LevelHigh},
+		// A failed check keeps the level
+		{free: 900, err: errors.New("statfs failed"), want: LevelHigh},
+		{free: 150, want: LevelLow},
+		{free: 900, want: LevelOK},
+	}
+	for i, step := range steps {
+		free, usageErr = step.free, step.err
+		if status := m.Check(time.Now()); status.Level != step.want {
+			t.Fatalf("Step %d: expected %s, got %+v", i, step.want, status)
+		}
+	}
+
+	want := []Level{LevelLow, LevelHigh, LevelLow, LevelOK}
+	if len(changes) != len(want) {
+		t.Fatalf("Expected changes %v, got %v", want, changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("Expected changes %v, got %v", want, changes)
+		}
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	if len(families) != 3 {
+		t.Errorf("Expected three disk gauges, got %d", len(families))
+	}
+}
+
+func TestNewMonitorValidatesWatermarks(t *testing.T) {
+	for _, options := range []Options{{Low: -1}, {High: 101}, {Low: 95, High: 90}} {
+		if _, err := NewMonitor(t.TempDir(), options, nil, zap.NewNop()); err == nil {
+			t.Errorf("Options %+v: expected an error", options)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package disk
+
+import "errors"
+
+// usage is not supported on this platform, so watermarks are never reached
+func usage(path string) (total, free uint64, err error) {
+	return 0, 0, errors.ErrUnsupported
+}
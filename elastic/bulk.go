@@ -0,0 +1,150 @@
+package elastic
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/bytedance/sonic"
+)
+
+// Bulk operation types
+const (
+	OpIndex  = "index"
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// BulkAction is one parsed operation of a _bulk request
+type BulkAction struct {
+	Op    string
+	Index string
+	ID    string
+
+	// Document is the source for index/create and the partial document for update
+	Document map[string]any
+
+	// Upsert indexes the partial document when an updated document is missing
+	Upsert bool
+}
+
+// BulkItem is the per-action result of a _bulk request
+type BulkItem struct {
+	Index  string      `json:"_index"`
+	ID     string      `json:"_id"`
+	Status int         `json:"status"`
+	Result string      `json:"result,omitempty"`
+	Error  *ErrorCause `json:"error,omitempty"`
+}
+
+// BulkResponse mirrors the Elasticsearch _bulk response
+type BulkResponse struct {
+	Took   int64                 `json:"took"`
+	Errors bool                  `json:"errors"`
+	Items  []map[string]BulkItem `json:"items"`
+}
+
+// ErrorCause describes a failure in Elasticsearch's error format
+type ErrorCause struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+// ErrorResponse is the body of a failed Elasticsearch request
+type ErrorResponse struct {
+	Error  ErrorCause `json:"error"`
+	Status int        `json:"status"`
+}
+
+// NewError builds an error response
+func NewError(status int, errorType, reason string) ErrorResponse {
+	return ErrorResponse{
+		Error:  ErrorCause{Type: errorType, Reason: reason},
+		Status: status,
+	}
+}
+
+// ParseBulk parses an NDJSON _bulk body. defaultIndex is used for actions
+// without an _index (the /:index/_bulk form).
+func ParseBulk(body []byte, defaultIndex string) ([]BulkAction, error) {
+	lines := bytes.Split(body, []byte("\n"))
+	actions := []BulkAction{}
+
+	for i := 0; i < len(lines); i++ {
+		line := bytes.TrimSpace(lines[i])
+		if len(line) == 0 {
+			continue
+		}
+
+		var header map[string]struct {
+			Index string `json:"_index"`
+			ID    string `json:"_id"`
+		}
+		if err := sonic.Unmarshal(line, &header); err != nil {
+			return nil, fmt.Errorf("malformed action on line %d: %w", i+1, err)
+		}
+		if len(header) != 1 {
+			return nil, fmt.Errorf("malformed action on line %d: expected a single operation", i+1)
+		}
+
+		var action BulkAction
+		for op, meta := range header {
+			action = BulkAction{Op: op, Index: meta.Index, ID: meta.ID}
+		}
+		if action.Index == "" {
+			action.Index = defaultIndex
+		}
+		if action.Index == "" {
+			return nil, fmt.Errorf("action on line %d has no _index", i+1)
+		}
+
+		switch action.Op {
+		case OpDelete:
+			if action.ID == "" {
+				return nil, fmt.Errorf("delete on line %d has no _id", i+1)
+			}
+			actions = append(actions, action)
+			continue
+		case OpIndex, OpCreate, OpUpdate:
+		default:
+			return nil, fmt.Errorf("unknown action [%s] on line %d", action.Op, i+1)
+		}
+
+		// The next non-empty line is the source
+		i++
+		for i < len(lines) && len(bytes.TrimSpace(lines[i])) == 0 {
+			i++
+		}
+		if i == len(lines) {
+			return nil, fmt.Errorf("%s action is missing its source", action.Op)
+		}
+		source := bytes.TrimSpace(lines[i])
+
+		if action.Op != OpUpdate {
+			if err := sonic.Unmarshal(source, &action.Document); err != nil || action.Document == nil {
+				return nil, fmt.Errorf("malformed source on line %d: expected a JSON object", i+1)
+			}
+			actions = append(actions, action)
+			continue
+		}
+
+		if action.ID == "" {
+			return nil, fmt.Errorf("update on line %d has no _id", i+1)
+		}
+		var update struct {
+			Doc         map[string]any `json:"doc"`
+			DocAsUpsert bool           `json:"doc_as_upsert"`
+		}
+		if err := sonic.Unmarshal(source, &update); err != nil {
+			return nil, fmt.Errorf("malformed update on line %d: %w", i+1, err)
+		}
+		if update.Doc == nil {
+			return nil, fmt.Errorf("update on line %d needs a doc (scripts are not supported)", i+1)
+		}
+		action.Document = update.Doc
+		action.Upsert = update.DocAsUpsert
+		actions = append(actions, action)
+	}
+
+	return actions, nil
+}
@@ -0,0 +1,105 @@
+package elastic
+
+import (
+	"testing"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/bytedance/sonic"
+)
+
+func TestParseBulk(t *testing.T) {
+	body := []byte(`{"index":{"_index":"logs","_id":"1"}}
+{"msg":"first"}
+
+{"create":{}}
+{"msg":"second"}
+{"update":{"_id":"1"}}
+{"doc":{"msg":"changed"},"doc_as_upsert":true}
+{"delete":{"_index":"other","_id":"9"}}
+`)
+
+	actions, err := ParseBulk(body, "default")
+	if err != nil {
+		t.Fatalf("Failed to parse bulk body: %v", err)
+	}
+	if len(actions) != 4 {
+		t.Fatalf("Expected 4 actions, got %d", len(actions))
+	}
+
+	if actions[0].Op != OpIndex || actions[0].Index != "logs" || actions[0].Document["msg"] != "first" {
+		t.Errorf("Unexpected index action: %+v", actions[0])
+	}
+	if actions[1].Op != OpCreate || actions[1].Index != "default" || actions[1].ID != "" {
+		t.Errorf("Unexpected create action: %+v", actions[1])
+	}
+	if actions[2].Op != OpUpdate || !actions[2].Upsert || actions[2].Document["msg"] != "changed" {
+		t.Errorf("Unexpected update action: %+v", actions[2])
+	}
+	if actions[3].Op != OpDelete || actions[3].Index != "other" || actions[3].Document != nil {
+		t.Errorf("Unexpected delete action: %+v", actions[3])
+	}
+
+	if _, err := ParseBulk([]byte(`{"index":{"_index":"logs"}}`), ""); err == nil {
+		t.Error("Expected an error for an action without a source")
+	}
+}
+
+func TestSearchTranslatesQueryDSL(t *testing.T) {
+	index, err := bleve.NewMemOnly(bleve.NewIndexMapping())
+	if err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	defer index.Close()
+
+	docs := map[string]map[string]any{
+		"1": {"msg": "disk full on host alpha", "level": "error", "code": 500.0},
+		"2": {"msg": "disk warning on host beta", "level": "warn", "code": 300.0},
+		"3": {"msg": "user login", "level": "info", "code": 200.0},
+	}
+	for id, doc := range docs {
+		if err := index.Index(id, doc); err != nil {
+			t.Fatalf("Failed to index document: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"match all sorted", `{"sort":[{"code":"asc"}]}`, []string{"3", "2", "1"}},
+		{"bool", `{"query":{"bool":{"must":{"match":{"msg":"disk"}},"must_not":[{"term":{"level":"warn"}}]}}}`, []string{"1"}},
+		{"range", `{"query":{"range":{"code":{"gt":200,"lte":300}}}}`, []string{"2"}},
+		{"should only", `{"query":{"bool":{"should":[{"term":{"level":"info"}},{"term":{"code":500}}]}},"sort":["_id"]}`, []string{"1", "3"}},
+		{"only must_not", `{"query":{"bool":{"must_not":{"match":{"msg":"disk"}}}}}`, []string{"3"}},
+		{"terms", `{"query":{"terms":{"level.keyword":["warn","info"]}},"sort":["_id"]}`, []string{"2", "3"}},
+		{"paging", `{"sort":[{"code":{"order":"desc"}}],"from":1,"size":1}`, []string{"2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req SearchRequest
+			if err := sonic.Unmarshal([]byte(tt.body), &req); err != nil {
+				t.Fatalf("Failed to parse request: %v", err)
+			}
+
+			response, err := Search(index, nil, req)
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+
+			got := make([]string, 0, len(response.Hits.Hits))
+			for _, hit := range response.Hits.Hits {
+				got = append(got, hit.ID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected hits %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Expected hits %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,511 @@
+// Package elastic implements a best-effort Elasticsearch compatibility layer:
+// a subset of the query DSL, the _search response shape and the _bulk
+// NDJSON format. It exists so log shippers and existing tooling can target
+// Bright during migrations; it does not aim for full fidelity.
+package elastic
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// TranslateQuery converts an Elasticsearch query DSL object into a bleve
+// query. A nil or empty object matches all documents.
+func TranslateQuery(dsl map[string]any) (query.Query, error) {
+	if len(dsl) == 0 {
+		return bleve.NewMatchAllQuery(), nil
+	}
+	if len(dsl) != 1 {
+		return nil, fmt.Errorf("query must have exactly one clause, got %d", len(dsl))
+	}
+
+	for kind, body := range dsl {
+		q, err := translateClause(kind, body)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] %w", kind, err)
+		}
+		return q, nil
+	}
+
+	return nil, nil
+}
+
+func translateClause(kind string, body any) (query.Query, error) {
+	switch kind {
+	case "match_all":
+		q := bleve.NewMatchAllQuery()
+		applyBoost(q, body)
+		return q, nil
+	case "match_none":
+		return bleve.NewMatchNoneQuery(), nil
+	case "bool":
+		return translateBool(body)
+	case "constant_score":
+		params, ok := body.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected an object")
+		}
+		filter, ok := params["filter"].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("filter is required")
+		}
+		return TranslateQuery(filter)
+	case "match":
+		return translateMatch(body, false)
+	case "match_phrase":
+		return translateMatch(body, true)
+	case "multi_match":
+		return translateMultiMatch(body)
+	case "query_string", "simple_query_string":
+		params, ok := body.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected an object")
+		}
+		text, ok := params["query"].(string)
+		if !ok {
+			return nil, fmt.Errorf("query is required")
+		}
+		q := bleve.NewQueryStringQuery(text)
+		applyBoost(q, params)
+		return q, nil
+	case "term":
+		return translateFieldValue(body, termQuery)
+	case "terms":
+		return translateTerms(body)
+	case "prefix":
+		return translateFieldValue(body, func(value any) (query.Query, error) {
+			return bleve.NewPrefixQuery(fmt.Sprint(value)), nil
+		})
+	case "wildcard":
+		return translateFieldValue(body, func(value any) (query.Query, error) {
+			return bleve.NewWildcardQuery(fmt.Sprint(value)), nil
+		})
+	case "regexp":
+		return translateFieldValue(body, func(value any) (query.Query, error) {
+			return bleve.NewRegexpQuery(fmt.Sprint(value)), nil
+		})
+	case "fuzzy":
+		return translateFieldValue(body, func(value any) (query.Query, error) {
+			return bleve.NewFuzzyQuery(fmt.Sprint(value)), nil
+		})
+	case "range":
+		return translateRange(body)
+	case "exists":
+		return translateExists(body)
+	case "ids":
+		params, ok := body.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected an object")
+		}
+		values, ok := params["values"].([]any)
+		if !ok {
+			return nil, fmt.Errorf("values must be an array")
+		}
+		ids := make([]string, 0, len(values))
+		for _, value := range values {
+			ids = append(ids, fmt.Sprint(value))
+		}
+		return bleve.NewDocIDQuery(ids), nil
+	default:
+		return nil, fmt.Errorf("unsupported query type")
+	}
+}
+
+// translateBool maps must/filter to required clauses, should to optional
+// ones and must_not to exclusions. Filters are scored like must clauses.
+func translateBool(body any) (query.Query, error) {
+	params, ok := body.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("expected an object")
+	}
+
+	q := bleve.NewBooleanQuery()
+	required := 0
+	for _, occur := range []string{"must", "filter", "should", "must_not"} {
+		clauses, err := translateClauses(params[occur])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", occur, err)
+		}
+		switch occur {
+		case "must", "filter":
+			q.AddMust(clauses...)
+			required += len(clauses)
+		case "should":
+			q.AddShould(clauses...)
+		case "must_not":
+			q.AddMustNot(clauses...)
+		}
+	}
+
+	// Like Elasticsearch, should clauses are required when nothing else is
+	if params["should"] != nil {
+		minShould := 0.0
+		if required == 0 {
+			minShould = 1
+		}
+		if value, ok := params["minimum_should_match"]; ok {
+			parsed, err := strconv.ParseFloat(fmt.Sprint(value), 64)
+			if err != nil {
+				return nil, fmt.Errorf("minimum_should_match must be a number")
+			}
+			minShould = parsed
+		}
+		q.SetMinShould(minShould)
+	}
+
+	// A bool query with only must_not clauses matches everything else
+	if required == 0 && params["should"] == nil {
+		q.AddMust(bleve.NewMatchAllQuery())
+	}
+
+	applyBoost(q, params)
+	return q, nil
+}
+
+// translateClauses accepts a single clause object or an array of them
+func translateClauses(value any) ([]query.Query, error) {
+	var objects []any
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case map[string]any:
+		objects = []any{v}
+	case []any:
+		objects = v
+	default:
+		return nil, fmt.Errorf("expected an object or array")
+	}
+
+	clauses := make([]query.Query, 0, len(objects))
+	for _, object := range objects {
+		dsl, ok := object.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected a query object")
+		}
+		clause, err := TranslateQuery(dsl)
+		if err != nil {
+			return nil, err
+		}
+		clauses = append(clauses, clause)
+	}
+	return clauses, nil
+}
+
+func translateMatch(body any, phrase bool) (query.Query, error) {
+	field, value, err := singleField(body)
+	if err != nil {
+		return nil, err
+	}
+
+	params, _ := value.(map[string]any)
+	if params == nil {
+		params = map[string]any{"query": value}
+	}
+	text := fmt.Sprint(params["query"])
+
+	if phrase {
+		q := bleve.NewMatchPhraseQuery(text)
+		q.SetField(field)
+		applyBoost(q, params)
+		return q, nil
+	}
+
+	q := bleve.NewMatchQuery(text)
+	q.SetField(field)
+	if operator, ok := params["operator"].(string); ok && strings.EqualFold(operator, "and") {
+		q.SetOperator(query.MatchQueryOperatorAnd)
+	}
+	if fuzziness, ok := params["fuzziness"]; ok {
+		q.SetFuzziness(parseFuzziness(fuzziness, text))
+	}
+	applyBoost(q, params)
+	return q, nil
+}
+
+// parseFuzziness maps Elasticsearch fuzziness values (a number or "AUTO")
+// to an edit distance
+func parseFuzziness(value any, text string) int {
+	if s, ok := value.(string); ok && strings.HasPrefix(strings.ToUpper(s), "AUTO") {
+		switch n := len([]rune(text)); {
+		case n <= 2:
+			return 0
+		case n <= 5:
+			return 1
+		default:
+			return 2
+		}
+	}
+	distance, _ := strconv.Atoi(fmt.Sprint(value))
+	return min(max(distance, 0), 2)
+}
+
+func translateMultiMatch(body any) (query.Query, error) {
+	params, ok := body.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("expected an object")
+	}
+	text := fmt.Sprint(params["query"])
+
+	fields, _ := params["fields"].([]any)
+	if len(fields) == 0 {
+		q := bleve.NewMatchQuery(text)
+		applyBoost(q, params)
+		return q, nil
+	}
+
+	phrase := params["type"] == "phrase"
+	disjuncts := make([]query.Query, 0, len(fields))
+	for _, value := range fields {
+		field, boost := splitFieldBoost(fmt.Sprint(value))
+		var q query.BoostableQuery
+		if phrase {
+			mq := bleve.NewMatchPhraseQuery(text)
+			mq.SetField(field)
+			q = mq
+		} else {
+			mq := bleve.NewMatchQuery(text)
+			mq.SetField(field)
+			if operator, ok := params["operator"].(string); ok && strings.EqualFold(operator, "and") {
+				mq.SetOperator(query.MatchQueryOperatorAnd)
+			}
+			q = mq
+		}
+		if boost != 1 {
+			q.SetBoost(boost)
+		}
+		disjuncts = append(disjuncts, q)
+	}
+
+	q := bleve.NewDisjunctionQuery(disjuncts...)
+	applyBoost(q, params)
+	return q, nil
+}
+
+// splitFieldBoost parses the "field^2" notation
+func splitFieldBoost(spec string) (string, float64) {
+	field, boostText, found := strings.Cut(spec, "^")
+	if !found {
+		return normalizeField(field), 1
+	}
+	boost, err := strconv.ParseFloat(boostText, 64)
+	if err != nil {
+		return normalizeField(field), 1
+	}
+	return normalizeField(field), boost
+}
+
+// translateFieldValue handles the {"field": value} and
+// {"field": {"value": value, "boost": n}} forms shared by term-level queries
+func translateFieldValue(body any, build func(value any) (query.Query, error)) (query.Query, error) {
+	field, value, err := singleField(body)
+	if err != nil {
+		return nil, err
+	}
+
+	params, _ := value.(map[string]any)
+	if params != nil {
+		value = params["value"]
+		if value == nil {
+			return nil, fmt.Errorf("value is required")
+		}
+	}
+
+	q, err := build(value)
+	if err != nil {
+		return nil, err
+	}
+	if fq, ok := q.(query.FieldableQuery); ok {
+		fq.SetField(field)
+	}
+	applyBoost(q, params)
+	return q, nil
+}
+
+// termQuery matches an exact value: numbers and booleans use the matching
+// typed query since bleve does not index them as terms
+func termQuery(value any) (query.Query, error) {
+	switch v := value.(type) {
+	case bool:
+		return bleve.NewBoolFieldQuery(v), nil
+	case float64:
+		inclusive := true
+		return bleve.NewNumericRangeInclusiveQuery(&v, &v, &inclusive, &inclusive), nil
+	case string:
+		return bleve.NewTermQuery(v), nil
+	default:
+		return nil, fmt.Errorf("unsupported term value %T", value)
+	}
+}
+
+func translateTerms(body any) (query.Query, error) {
+	params, ok := body.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("expected an object")
+	}
+
+	for field, value := range params {
+		if field == "boost" {
+			continue
+		}
+		values, ok := value.([]any)
+		if !ok {
+			return nil, fmt.Errorf("values of %s must be an array", field)
+		}
+
+		disjuncts := make([]query.Query, 0, len(values))
+		for _, value := range values {
+			q, err := termQuery(value)
+			if err != nil {
+				return nil, err
+			}
+			q.(query.FieldableQuery).SetField(normalizeField(field))
+			disjuncts = append(disjuncts, q)
+		}
+
+		q := bleve.NewDisjunctionQuery(disjuncts...)
+		applyBoost(q, params)
+		return q, nil
+	}
+
+	return nil, fmt.Errorf("a field is required")
+}
+
+func translateRange(body any) (query.Query, error) {
+	field, value, err := singleField(body)
+	if err != nil {
+		return nil, err
+	}
+	params, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("expected an object for %s", field)
+	}
+
+	lower, lowerInclusive := params["gte"], true
+	if lower == nil {
+		lower, lowerInclusive = params["gt"], false
+	}
+	upper, upperInclusive := params["lte"], true
+	if upper == nil {
+		upper, upperInclusive = params["lt"], false
+	}
+	if lower == nil && upper == nil {
+		return nil, fmt.Errorf("one of gt, gte, lt or lte is required")
+	}
+
+	var q query.FieldableQuery
+	switch {
+	case isNumber(lower) && isNumber(upper):
+		var minValue, maxValue *float64
+		if lower != nil {
+			v := lower.(float64)
+			minValue = &v
+		}
+		if upper != nil {
+			v := upper.(float64)
+			maxValue = &v
+		}
+		q = bleve.NewNumericRangeInclusiveQuery(minValue, maxValue, &lowerInclusive, &upperInclusive)
+	case isDate(lower) && isDate(upper):
+		q = bleve.NewDateRangeInclusiveStringQuery(stringOrEmpty(lower), stringOrEmpty(upper), &lowerInclusive, &upperInclusive)
+	default:
+		q = bleve.NewTermRangeInclusiveQuery(stringOrEmpty(lower), stringOrEmpty(upper), &lowerInclusive, &upperInclusive)
+	}
+
+	q.SetField(field)
+	applyBoost(q, params)
+	return q, nil
+}
+
+// translateExists matches documents with any text term or numeric value in a field
+func translateExists(body any) (query.Query, error) {
+	params, ok := body.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("expected an object")
+	}
+	field, ok := params["field"].(string)
+	if !ok || field == "" {
+		return nil, fmt.Errorf("field is required")
+	}
+	field = normalizeField(field)
+
+	text := bleve.NewWildcardQuery("*")
+	text.SetField(field)
+
+	lowest, highest := -math.MaxFloat64, math.MaxFloat64
+	numeric := bleve.NewNumericRangeQuery(&lowest, &highest)
+	numeric.SetField(field)
+
+	return bleve.NewDisjunctionQuery(text, numeric), nil
+}
+
+// singleField unpacks the {"field": value} form
+func singleField(body any) (string, any, error) {
+	params, ok := body.(map[string]any)
+	if !ok {
+		return "", nil, fmt.Errorf("expected an object")
+	}
+	for field, value := range params {
+		if field == "boost" || field == "_name" {
+			continue
+		}
+		return normalizeField(field), value, nil
+	}
+	return "", nil, fmt.Errorf("a field is required")
+}
+
+// normalizeField drops the ".keyword" multi-field suffix used by
+// Elasticsearch tooling; Bright indexes each field once
+func normalizeField(field string) string {
+	return strings.TrimSuffix(field, ".keyword")
+}
+
+func applyBoost(q query.Query, params any) {
+	values, ok := params.(map[string]any)
+	if !ok {
+		return
+	}
+	boost, ok := values["boost"].(float64)
+	if !ok {
+		return
+	}
+	if bq, ok := q.(query.BoostableQuery); ok {
+		bq.SetBoost(boost)
+	}
+}
+
+func isNumber(value any) bool {
+	if value == nil {
+		return true
+	}
+	_, ok := value.(float64)
+	return ok
+}
+
+func isDate(value any) bool {
+	if value == nil {
+		return true
+	}
+	s, ok := value.(string)
+	if !ok {
+		return false
+	}
+	for _, layout := range []string{time.RFC3339Nano, time.DateTime, time.DateOnly} {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func stringOrEmpty(value any) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
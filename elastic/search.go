@@ -0,0 +1,248 @@
+package elastic
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// DefaultSize is the number of hits returned when no size is given
+const DefaultSize = 10
+
+// MaxResultWindow bounds from+size like Elasticsearch's index.max_result_window
+const MaxResultWindow = 10000
+
+// SearchRequest is the supported subset of an Elasticsearch search body
+type SearchRequest struct {
+	Query  map[string]any `json:"query"`
+	From   *int           `json:"from"`
+	Size   *int           `json:"size"`
+	Sort   any            `json:"sort"`
+	Source any            `json:"_source"`
+}
+
+// SearchResponse mirrors the Elasticsearch search response
+type SearchResponse struct {
+	Took     int64     `json:"took"`
+	TimedOut bool      `json:"timed_out"`
+	Shards   Shards    `json:"_shards"`
+	Hits     HitsBlock `json:"hits"`
+}
+
+// Shards reports a single successful shard
+type Shards struct {
+	Total      int `json:"total"`
+	Successful int `json:"successful"`
+	Skipped    int `json:"skipped"`
+	Failed     int `json:"failed"`
+}
+
+// HitsBlock holds the total and the returned hits
+type HitsBlock struct {
+	Total    Total    `json:"total"`
+	MaxScore *float64 `json:"max_score"`
+	Hits     []Hit    `json:"hits"`
+}
+
+// Total is the hit count; Bright always counts exactly
+type Total struct {
+	Value    uint64 `json:"value"`
+	Relation string `json:"relation"`
+}
+
+// Hit is a single search result
+type Hit struct {
+	Index  string         `json:"_index"`
+	ID     string         `json:"_id"`
+	Score  float64        `json:"_score"`
+	Source map[string]any `json:"_source,omitempty"`
+}
+
+// Search runs an Elasticsearch search request against an index (or an
+// alias over several). indexNames maps bleve index names to Bright index
+// IDs for the _index field of hits.
+func Search(index bleve.Index, indexNames map[string]string, req SearchRequest) (*SearchResponse, error) {
+	start := time.Now()
+
+	from, size := 0, DefaultSize
+	if req.From != nil {
+		from = *req.From
+	}
+	if req.Size != nil {
+		size = *req.Size
+	}
+	if from < 0 || size < 0 {
+		return nil, fmt.Errorf("from and size must not be negative")
+	}
+	if from+size > MaxResultWindow {
+		return nil, fmt.Errorf("result window is too large, from + size must be less than or equal to: [%d] but was [%d]", MaxResultWindow, from+size)
+	}
+
+	q, err := TranslateQuery(req.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	sortOrder, err := parseSort(req.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	includes, excludes, withSource, err := parseSource(req.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	searchRequest := bleve.NewSearchRequestOptions(q, size, from, false)
+	if withSource {
+		searchRequest.Fields = []string{"*"}
+		if len(includes) > 0 {
+			searchRequest.Fields = includes
+		}
+	}
+	if len(sortOrder) > 0 {
+		searchRequest.SortBy(sortOrder)
+	}
+
+	result, err := index.Search(searchRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]Hit, 0, len(result.Hits))
+	for _, match := range result.Hits {
+		hit := Hit{
+			Index: match.Index,
+			ID:    match.ID,
+			Score: match.Score,
+		}
+		if name, ok := indexNames[match.Index]; ok {
+			hit.Index = name
+		}
+		if withSource {
+			hit.Source = make(map[string]any, len(match.Fields))
+			for field, value := range match.Fields {
+				hit.Source[field] = value
+			}
+			for _, field := range excludes {
+				delete(hit.Source, field)
+			}
+		}
+		hits = append(hits, hit)
+	}
+
+	response := &SearchResponse{
+		Took:   time.Since(start).Milliseconds(),
+		Shards: Shards{Total: 1, Successful: 1},
+		Hits: HitsBlock{
+			Total: Total{Value: result.Total, Relation: "eq"},
+			Hits:  hits,
+		},
+	}
+	if len(hits) > 0 {
+		maxScore := result.MaxScore
+		response.Hits.MaxScore = &maxScore
+	}
+
+	return response, nil
+}
+
+// parseSort converts the sort forms "field", {"field": "desc"} and
+// {"field": {"order": "desc"}} (alone or in an array) into bleve sort
+// strings. _score sorts descending unless asked otherwise.
+func parseSort(value any) ([]string, error) {
+	var entries []any
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		entries = v
+	default:
+		entries = []any{v}
+	}
+
+	order := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		switch v := entry.(type) {
+		case string:
+			order = append(order, sortField(v, ""))
+		case map[string]any:
+			for field, spec := range v {
+				direction := ""
+				switch s := spec.(type) {
+				case string:
+					direction = s
+				case map[string]any:
+					direction, _ = s["order"].(string)
+				}
+				order = append(order, sortField(field, direction))
+			}
+		default:
+			return nil, fmt.Errorf("unsupported sort entry %v", entry)
+		}
+	}
+	return order, nil
+}
+
+func sortField(field, direction string) string {
+	field = normalizeField(field)
+	if direction == "" && field == "_score" {
+		direction = "desc"
+	}
+	if strings.EqualFold(direction, "desc") {
+		return "-" + field
+	}
+	return field
+}
+
+// ParseSortParam parses the "field:desc,other" URL form of sort
+func ParseSortParam(param string) []any {
+	if param == "" {
+		return nil
+	}
+	var entries []any
+	for _, part := range strings.Split(param, ",") {
+		field, direction, _ := strings.Cut(strings.TrimSpace(part), ":")
+		entries = append(entries, map[string]any{field: direction})
+	}
+	return entries
+}
+
+// parseSource interprets _source: a bool, a field, a list of fields, or an
+// object with includes/excludes
+func parseSource(value any) (includes, excludes []string, enabled bool, err error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil, true, nil
+	case bool:
+		return nil, nil, v, nil
+	case string:
+		return []string{v}, nil, true, nil
+	case []any:
+		return toStrings(v), nil, true, nil
+	case map[string]any:
+		for _, key := range []string{"includes", "include"} {
+			if list, ok := v[key].([]any); ok {
+				includes = toStrings(list)
+			}
+		}
+		for _, key := range []string{"excludes", "exclude"} {
+			if list, ok := v[key].([]any); ok {
+				excludes = toStrings(list)
+			}
+		}
+		return includes, excludes, true, nil
+	default:
+		return nil, nil, false, fmt.Errorf("unsupported _source value")
+	}
+}
+
+func toStrings(values []any) []string {
+	strs := make([]string, 0, len(values))
+	for _, value := range values {
+		strs = append(strs, fmt.Sprint(value))
+	}
+	return strs
+}
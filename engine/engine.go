@@ -0,0 +1,241 @@
+// Package engine embeds Bright in-process: indexes, document ingestion and
+// search without running the HTTP server. The data directory layout is the
+// same as the server's, so a directory can be served later with
+// `bright serve` (but never by both at once).
+package engine
+
+import (
+	"bright/formats"
+	"bright/migrate"
+	"bright/models"
+	"bright/search"
+	"bright/store"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// ErrIndexNotFound is returned when an operation targets an unknown index
+var ErrIndexNotFound = errors.New("index not found")
+
+// Options configures an Engine
+type Options struct {
+	// DataDir is the directory holding index data. Required.
+	DataDir string
+
+	// AutoCreateIndex creates missing indexes on the first AddDocuments call,
+	// detecting the primary key like the server does
+	AutoCreateIndex bool
+
+	// PrimaryKey configures primary key detection of auto-created indexes
+	PrimaryKey store.PrimaryKeyHeuristics
+
+	// DisableWAL turns off write-ahead journaling of document batches
+	DisableWAL bool
+
+	// IndexType and KVStore are the defaults of indexes created without
+	// their own, such as store.KVStoreMemory for ephemeral indexes
+	IndexType string
+	KVStore   string
+}
+
+// Engine is an embedded Bright instance. It is safe for concurrent use.
+type Engine struct {
+	store   *store.IndexStore
+	options Options
+}
+
+// Open opens (or creates) an engine on the given data directory
+func Open(options Options) (*Engine, error) {
+	if options.DataDir == "" {
+		return nil, fmt.Errorf("data directory is required")
+	}
+
+	if err := migrate.Check(options.DataDir); err != nil {
+		return nil, err
+	}
+
+	indexStore, err := store.New(options.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := indexStore.SetIndexTypeDefaults(options.IndexType, options.KVStore); err != nil {
+		indexStore.Close()
+		return nil, err
+	}
+
+	if !options.DisableWAL {
+		if _, err := indexStore.EnableWAL(); err != nil {
+			indexStore.Close()
+			return nil, err
+		}
+	}
+
+	return &Engine{store: indexStore, options: options}, nil
+}
+
+// Close flushes and closes all indexes
+func (e *Engine) Close() error {
+	return e.store.Close()
+}
+
+// Store returns the underlying index store for advanced use
+func (e *Engine) Store() *store.IndexStore {
+	return e.store
+}
+
+// CreateIndex creates a new index
+func (e *Engine) CreateIndex(config models.IndexConfig) error {
+	if config.ID == "" {
+		return fmt.Errorf("index id is required")
+	}
+	if config.PrimaryKey == "" {
+		config.PrimaryKey = "id"
+	}
+	return e.store.CreateIndex(&config)
+}
+
+// GetIndex returns the configuration of an index
+func (e *Engine) GetIndex(id string) (*models.IndexConfig, error) {
+	_, config, err := e.store.GetIndex(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrIndexNotFound, id)
+	}
+	return config, nil
+}
+
+// ListIndexes returns all index configurations ordered by ID
+func (e *Engine) ListIndexes() []*models.IndexConfig {
+	configs := e.store.GetAllConfigs()
+
+	list := make([]*models.IndexConfig, 0, len(configs))
+	for _, config := range configs {
+		list = append(list, config)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// DeleteIndex deletes an index and all its documents
+func (e *Engine) DeleteIndex(id string) error {
+	return e.store.DeleteIndex(id)
+}
+
+// AddDocuments indexes documents, replacing documents with the same primary
+// key. Documents without a primary key are assigned a UUIDv7. Batches with
+// invalid keys fail with store.ErrInvalidPrimaryKey or
+// store.ErrDuplicatePrimaryKey.
+func (e *Engine) AddDocuments(indexID string, documents []map[string]any) (int, error) {
+	_, config, err := e.store.GetIndex(indexID)
+	if err != nil {
+		if !e.options.AutoCreateIndex {
+			return 0, fmt.Errorf("%w: %s", ErrIndexNotFound, indexID)
+		}
+
+		primaryKey, err := store.DetectPrimaryKey(documents, e.options.PrimaryKey)
+		if err != nil {
+			return 0, fmt.Errorf("cannot auto-create index: %w", err)
+		}
+		if err := store.ValidatePrimaryKeys(primaryKey, documents, false); err != nil {
+			return 0, err
+		}
+		config = &models.IndexConfig{ID: indexID, PrimaryKey: primaryKey, PrimaryKeyInferred: true}
+		if err := e.store.CreateIndex(config); err != nil {
+			return 0, err
+		}
+	} else if err := store.ValidatePrimaryKeys(config.PrimaryKey, documents, false); err != nil {
+		return 0, err
+	}
+
+	for _, doc := range documents {
+		if id, ok := doc[config.PrimaryKey]; !ok || id == nil {
+			uuidV7, err := uuid.NewV7()
+			if err != nil {
+				return 0, fmt.Errorf("failed to generate UUID: %w", err)
+			}
+			doc[config.PrimaryKey] = uuidV7.String()
+		}
+	}
+
+	if err := e.store.AddDocuments(indexID, config.PrimaryKey, documents); err != nil {
+		return 0, err
+	}
+
+	return len(documents), nil
+}
+
+// AddDocumentsFrom parses data in the given format ("jsoneachrow" or
+// "msgpack") and indexes the documents
+func (e *Engine) AddDocumentsFrom(indexID, format string, data []byte) (int, error) {
+	parser, err := formats.GetParser(format)
+	if err != nil {
+		return 0, err
+	}
+
+	documents, err := parser.Parse(data)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse documents: %w", err)
+	}
+
+	return e.AddDocuments(indexID, documents)
+}
+
+// DeleteDocuments deletes documents by ID
+func (e *Engine) DeleteDocuments(indexID string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if _, _, err := e.store.GetIndex(indexID); err != nil {
+		return fmt.Errorf("%w: %s", ErrIndexNotFound, indexID)
+	}
+	return e.store.DeleteDocumentsInternal(indexID, "", ids)
+}
+
+// DeleteDocumentsByFilter deletes all documents matching a query string
+func (e *Engine) DeleteDocumentsByFilter(indexID, filter string) error {
+	if filter == "" {
+		return fmt.Errorf("filter is required")
+	}
+	if _, _, err := e.store.GetIndex(indexID); err != nil {
+		return fmt.Errorf("%w: %s", ErrIndexNotFound, indexID)
+	}
+	return e.store.DeleteDocumentsInternal(indexID, filter, nil)
+}
+
+// Search runs a search request against an index
+func (e *Engine) Search(indexID string, req models.SearchRequest) (*models.SearchResponse, error) {
+	index, config, err := e.store.GetIndex(indexID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrIndexNotFound, indexID)
+	}
+	req.SortableAttributes = config.SortableAttributes
+	req.FilterableAttributes = config.FilterableAttributes
+	req.GeoAttributes = config.GeoAttributes
+	req.Vectors = config.Vectors
+	req.Synonyms = config.Synonyms
+	req.SearchableAttributes = config.SearchableAttributes
+	if err := search.ApplyMatchingStrategy(&req, search.AsYouTypeFields(config)); err != nil {
+		return nil, err
+	}
+	search.ApplyDefaults(&req, config.SearchDefaults)
+	if req.Cursor != "" {
+		if index, err = e.store.CursorIndex(config.ID, &req); err != nil {
+			return nil, err
+		}
+	}
+	response, err := search.Execute(index, req)
+	if err != nil {
+		return nil, err
+	}
+	if response.NextCursor == "" && req.PointInTime != "" {
+		e.store.ClosePointInTime(req.PointInTime)
+	}
+	if err := search.ApplyLookups(response.Hits, req.Lookups, e.store.GetIndex, true); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
@@ -0,0 +1,70 @@
+package engine_test
+
+import (
+	"bright/engine"
+	"bright/models"
+	"fmt"
+	"log"
+	"os"
+)
+
+func Example() {
+	dataDir, err := os.MkdirTemp("", "bright-example")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	e, err := engine.Open(engine.Options{DataDir: dataDir})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer e.Close()
+
+	if err := e.CreateIndex(models.IndexConfig{ID: "products", PrimaryKey: "id"}); err != nil {
+		log.Fatal(err)
+	}
+
+	if _, err := e.AddDocuments("products", []map[string]any{
+		{"id": "1", "title": "Mechanical keyboard"},
+		{"id": "2", "title": "Wireless mouse"},
+	}); err != nil {
+		log.Fatal(err)
+	}
+
+	result, err := e.Search("products", models.SearchRequest{Query: "keyboard"})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(result.TotalHits, result.Hits[0]["title"])
+	// Output: 1 Mechanical keyboard
+}
+
+func ExampleEngine_AddDocumentsFrom() {
+	dataDir, err := os.MkdirTemp("", "bright-example")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	e, err := engine.Open(engine.Options{DataDir: dataDir, AutoCreateIndex: true})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer e.Close()
+
+	data := []byte("{\"id\": 1, \"name\": \"Ada\"}\n{\"id\": 2, \"name\": \"Grace\"}\n")
+	indexed, err := e.AddDocumentsFrom("people", "jsoneachrow", data)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	config, err := e.GetIndex("people")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(indexed, config.PrimaryKey)
+	// Output: 2 id
+}
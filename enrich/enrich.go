@@ -0,0 +1,259 @@
+package enrich
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"bright/models"
+
+	"github.com/bytedance/sonic"
+)
+
+// Defaults for unset enrichment settings
+const (
+	DefaultBatchSize = 100
+	DefaultTimeout   = 2 * time.Second
+	DefaultCacheTTL  = 5 * time.Minute
+)
+
+// The circuit opens after breakerThreshold consecutive failed lookups and
+// stays open for breakerCooldown before the service is tried again
+const (
+	breakerThreshold = 5
+	breakerCooldown  = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned while the enrichment service is considered down
+var ErrCircuitOpen = errors.New("enrichment service unavailable, circuit open")
+
+// lookupRequest is sent to the enrichment service
+type lookupRequest struct {
+	Keys []string `json:"keys"`
+}
+
+// lookupResponse maps each known key to the fields to add to its documents
+type lookupResponse struct {
+	Results map[string]map[string]any `json:"results"`
+}
+
+type cacheEntry struct {
+	fields  map[string]any
+	expires time.Time
+}
+
+// Enricher adds fields from an HTTP lookup service to documents. Lookups are
+// batched, cached, and skipped while the service keeps failing.
+type Enricher struct {
+	settings models.EnrichmentSettings
+	client   *http.Client
+	now      func() time.Time
+
+	mu        sync.Mutex
+	cache     map[string]cacheEntry
+	failures  int
+	openUntil time.Time
+}
+
+// New creates an enricher for the given settings
+func New(settings models.EnrichmentSettings) *Enricher {
+	timeout := DefaultTimeout
+	if settings.TimeoutMs > 0 {
+		timeout = time.Duration(settings.TimeoutMs) * time.Millisecond
+	}
+
+	return &Enricher{
+		settings: settings,
+		client:   &http.Client{Timeout: timeout},
+		now:      time.Now,
+		cache:    make(map[string]cacheEntry),
+	}
+}
+
+// Validate checks enrichment settings
+func Validate(settings *models.EnrichmentSettings) error {
+	if settings.URL == "" || settings.KeyField == "" {
+		return fmt.Errorf("enrichment needs a url and a keyField")
+	}
+	if settings.BatchSize < 0 || settings.TimeoutMs < 0 || settings.CacheTTLSeconds < 0 {
+		return fmt.Errorf("enrichment batchSize, timeoutMs and cacheTtlSeconds must not be negative")
+	}
+	return nil
+}
+
+// Enrich looks up the key field of every document and adds the returned
+// fields the document does not already have. Documents without the key field
+// or unknown to the service are left as they are. On error, documents of
+// lookups that succeeded are still enriched.
+func (e *Enricher) Enrich(ctx context.Context, documents []map[string]any) error {
+	keys := make([]string, 0, len(documents))
+	for _, doc := range documents {
+		if value, ok := doc[e.settings.KeyField]; ok && value != nil {
+			keys = append(keys, fmt.Sprint(value))
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	found, missing := e.cached(keys)
+
+	var err error
+	batchSize := e.settings.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	for batch := range slices.Chunk(missing, batchSize) {
+		var results map[string]map[string]any
+		results, err = e.lookup(ctx, batch)
+		if err != nil {
+			break
+		}
+		for _, key := range batch {
+			found[key] = results[key]
+		}
+	}
+
+	for _, doc := range documents {
+		value, ok := doc[e.settings.KeyField]
+		if !ok || value == nil {
+			continue
+		}
+		for field, fieldValue := range found[fmt.Sprint(value)] {
+			if len(e.settings.Fields) > 0 && !slices.Contains(e.settings.Fields, field) {
+				continue
+			}
+			if _, exists := doc[field]; !exists {
+				doc[field] = fieldValue
+			}
+		}
+	}
+
+	return err
+}
+
+// cached splits keys into cached results and unique keys still to look up
+func (e *Enricher) cached(keys []string) (map[string]map[string]any, []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	found := make(map[string]map[string]any, len(keys))
+	missing := make([]string, 0, len(keys))
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if entry, ok := e.cache[key]; ok && now.Before(entry.expires) {
+			found[key] = entry.fields
+			continue
+		}
+		delete(e.cache, key)
+		missing = append(missing, key)
+	}
+	return found, missing
+}
+
+// lookup queries the service for one batch of keys, caching the results
+// (including keys it does not know) and tracking failures for the breaker
+func (e *Enricher) lookup(ctx context.Context, keys []string) (map[string]map[string]any, error) {
+	e.mu.Lock()
+	if e.now().Before(e.openUntil) {
+		e.mu.Unlock()
+		return nil, ErrCircuitOpen
+	}
+	e.mu.Unlock()
+
+	results, err := e.fetch(ctx, keys)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err != nil {
+		e.failures++
+		if e.failures >= breakerThreshold {
+			e.openUntil = e.now().Add(breakerCooldown)
+		}
+		return nil, err
+	}
+
+	e.failures = 0
+	ttl := DefaultCacheTTL
+	if e.settings.CacheTTLSeconds > 0 {
+		ttl = time.Duration(e.settings.CacheTTLSeconds) * time.Second
+	}
+	expires := e.now().Add(ttl)
+	for _, key := range keys {
+		e.cache[key] = cacheEntry{fields: results[key], expires: expires}
+	}
+	return results, nil
+}
+
+// fetch performs the HTTP lookup of a batch
+func (e *Enricher) fetch(ctx context.Context, keys []string) (map[string]map[string]any, error) {
+	body, err := sonic.Marshal(lookupRequest{Keys: keys})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.settings.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("enrichment lookup failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("enrichment lookup failed: status %d", resp.StatusCode)
+	}
+
+	var response lookupResponse
+	if err := sonic.ConfigDefault.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("invalid enrichment response: %w", err)
+	}
+	return response.Results, nil
+}
+
+// Registry keeps one enricher per index, so caches and circuit state survive
+// across batches and are reset when the index's settings change
+type Registry struct {
+	mu        sync.Mutex
+	enrichers map[string]*Enricher
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{enrichers: make(map[string]*Enricher)}
+}
+
+// Get returns the enricher of an index, or nil when enrichment is not configured
+func (r *Registry) Get(indexID string, settings *models.EnrichmentSettings) *Enricher {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if settings == nil {
+		delete(r.enrichers, indexID)
+		return nil
+	}
+
+	enricher, ok := r.enrichers[indexID]
+	if !ok || !reflect.DeepEqual(enricher.settings, *settings) {
+		enricher = New(*settings)
+		r.enrichers[indexID] = enricher
+	}
+	return enricher
+}
@@ -0,0 +1,90 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"bright/models"
+
+	"github.com/bytedance/sonic"
+)
+
+func TestEnrichBatchesAndCaches(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var req lookupRequest
+		if err := sonic.ConfigDefault.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode lookup: %v", err)
+		}
+		results := map[string]map[string]any{}
+		for _, key := range req.Keys {
+			if key != "unknown" {
+				results[key] = map[string]any{"category": "cat-" + key, "secret": true, "name": "ignored"}
+			}
+		}
+		body, _ := sonic.Marshal(lookupResponse{Results: results})
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	enricher := New(models.EnrichmentSettings{URL: server.URL, KeyField: "sku", Fields: []string{"category", "name"}, BatchSize: 2})
+	docs := []map[string]any{
+		{"sku": "a", "name": "lamp"},
+		{"sku": "b"},
+		{"sku": "a"},
+		{"sku": "unknown"},
+		{"title": "no key"},
+	}
+	if err := enricher.Enrich(context.Background(), docs); err != nil {
+		t.Fatalf("Enrich failed: %v", err)
+	}
+
+	if docs[0]["category"] != "cat-a" || docs[0]["name"] != "lamp" || docs[0]["secret"] != nil {
+		t.Errorf("Expected only new whitelisted fields, got %v", docs[0])
+	}
+	if docs[1]["category"] != "cat-b" || docs[2]["category"] != "cat-a" {
+		t.Errorf("Expected every keyed document to be enriched, got %v", docs)
+	}
+	if len(docs[3]) != 1 || len(docs[4]) != 1 {
+		t.Errorf("Expected unknown and unkeyed documents untouched, got %v", docs)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("Expected 3 unique keys in 2 batches, got %d requests", n)
+	}
+
+	if err := enricher.Enrich(context.Background(), []map[string]any{{"sku": "b"}, {"sku": "unknown"}}); err != nil {
+		t.Fatalf("Enrich failed: %v", err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("Expected cached keys to skip the service, got %d requests", n)
+	}
+}
+
+func TestEnrichCircuitBreaker(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	enricher := New(models.EnrichmentSettings{URL: server.URL, KeyField: "id"})
+	for i := range breakerThreshold {
+		doc := []map[string]any{{"id": i}}
+		if err := enricher.Enrich(context.Background(), doc); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("Expected a lookup error, got %v", err)
+		}
+	}
+
+	if err := enricher.Enrich(context.Background(), []map[string]any{{"id": "next"}}); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if n := requests.Load(); n != breakerThreshold {
+		t.Errorf("Expected no request while the circuit is open, got %d", n)
+	}
+}
@@ -15,20 +15,71 @@ const (
 	ErrorCodeConflictingParameters ErrorCode = "CONFLICTING_PARAMETERS"
 	ErrorCodeInvalidFormat         ErrorCode = "INVALID_FORMAT"
 	ErrorCodeParseError            ErrorCode = "PARSE_ERROR"
+	ErrorCodeInvalidPrimaryKey     ErrorCode = "INVALID_PRIMARY_KEY"
+	ErrorCodeInvalidIndexSettings  ErrorCode = "INVALID_INDEX_SETTINGS"
+	ErrorCodeUnsortableAttribute   ErrorCode = "UNSORTABLE_ATTRIBUTE"
+	ErrorCodeUnfilterableAttribute ErrorCode = "UNFILTERABLE_ATTRIBUTE"
+	ErrorCodeInvalidFilter         ErrorCode = "INVALID_FILTER"
+	ErrorCodeLimitExceeded         ErrorCode = "LIMIT_EXCEEDED"
+	ErrorCodeMaxTotalHitsExceeded  ErrorCode = "MAX_TOTAL_HITS_EXCEEDED"
+	ErrorCodeInvalidRequest        ErrorCode = "INVALID_REQUEST"
+	ErrorCodeInvalidIngressConfig  ErrorCode = "INVALID_INGRESS_CONFIG"
+	ErrorCodeInvalidIngressState   ErrorCode = "INVALID_INGRESS_STATE"
+	ErrorCodeUnknownIngressType    ErrorCode = "UNKNOWN_INGRESS_TYPE"
+	ErrorCodeInvalidAttachment     ErrorCode = "INVALID_ATTACHMENT"
+	ErrorCodeInvalidArchive        ErrorCode = "INVALID_ARCHIVE"
+
+	// Authentication errors (401)
+	ErrorCodeMissingAuthorization ErrorCode = "MISSING_AUTHORIZATION"
+	ErrorCodeInvalidAuthorization ErrorCode = "INVALID_AUTHORIZATION"
 
 	// Not found errors (404)
 	ErrorCodeIndexNotFound    ErrorCode = "INDEX_NOT_FOUND"
 	ErrorCodeDocumentNotFound ErrorCode = "DOCUMENT_NOT_FOUND"
-
-	// Cluster errors (503)
-	ErrorCodeClusterUnavailable ErrorCode = "CLUSTER_UNAVAILABLE"
+	ErrorCodeKeyNotFound      ErrorCode = "KEY_NOT_FOUND"
+	ErrorCodeIngressNotFound  ErrorCode = "INGRESS_NOT_FOUND"
+	ErrorCodeProfileNotFound  ErrorCode = "PROFILE_NOT_FOUND"
+	ErrorCodeQueryNotFound    ErrorCode = "QUERY_NOT_FOUND"
+	ErrorCodeRuleNotFound     ErrorCode = "RULE_NOT_FOUND"
+	ErrorCodeAlertNotFound    ErrorCode = "ALERT_NOT_FOUND"
+	ErrorCodeScheduleNotFound ErrorCode = "SCHEDULE_NOT_FOUND"
+	ErrorCodeAliasNotFound    ErrorCode = "ALIAS_NOT_FOUND"
+	ErrorCodeReindexNotFound  ErrorCode = "REINDEX_NOT_FOUND"
+	ErrorCodeJobNotFound      ErrorCode = "JOB_NOT_FOUND"
+	ErrorCodeRouteNotFound    ErrorCode = "ROUTE_NOT_FOUND"
+
+	// Method errors (405)
+	ErrorCodeMethodNotAllowed ErrorCode = "METHOD_NOT_ALLOWED"
+
+	// Payload errors (413)
+	ErrorCodeRequestTooLarge ErrorCode = "REQUEST_TOO_LARGE"
+
+	// Unavailable errors (503)
+	ErrorCodeClusterUnavailable   ErrorCode = "CLUSTER_UNAVAILABLE"
+	ErrorCodeIngressesUnavailable ErrorCode = "INGRESSES_UNAVAILABLE"
+	ErrorCodeProfilesUnavailable  ErrorCode = "PROFILES_UNAVAILABLE"
+
+	// Storage errors (507)
+	ErrorCodeDiskWatermarkExceeded ErrorCode = "DISK_WATERMARK_EXCEEDED"
 
 	// Authorization errors (403)
 	ErrorCodeInsufficientPermissions ErrorCode = "INSUFFICIENT_PERMISSIONS"
 	ErrorCodeLeaderOnlyOperation     ErrorCode = "LEADER_ONLY_OPERATION"
+	ErrorCodeReadOnlyStandby         ErrorCode = "READ_ONLY_STANDBY"
+	ErrorCodeFeatureDisabled         ErrorCode = "FEATURE_DISABLED"
 
 	// Resource conflict errors (409)
 	ErrorCodeResourceAlreadyExists ErrorCode = "RESOURCE_ALREADY_EXISTS"
+	ErrorCodeIdempotencyKeyInUse   ErrorCode = "IDEMPOTENCY_KEY_IN_USE"
+	ErrorCodeDuplicatePrimaryKey   ErrorCode = "DUPLICATE_PRIMARY_KEY"
+	ErrorCodeIngressAlreadyExists  ErrorCode = "INGRESS_ALREADY_EXISTS"
+	ErrorCodeProfileInUse          ErrorCode = "PROFILE_IN_USE"
+
+	// Gone errors (410)
+	ErrorCodeChangesCompacted ErrorCode = "CHANGES_COMPACTED"
+
+	// Rate limit errors (429)
+	ErrorCodeRateLimited ErrorCode = "RATE_LIMITED"
 
 	// Internal errors (500)
 	ErrorCodeUUIDGenerationFailed    ErrorCode = "UUID_GENERATION_FAILED"
@@ -38,80 +89,121 @@ const (
 	ErrorCodeDocumentOperationFailed ErrorCode = "DOCUMENT_OPERATION_FAILED"
 	ErrorCodeBatchOperationFailed    ErrorCode = "BATCH_OPERATION_FAILED"
 	ErrorCodeSearchFailed            ErrorCode = "SEARCH_FAILED"
+	ErrorCodeIngressOperationFailed  ErrorCode = "INGRESS_OPERATION_FAILED"
 	ErrorCodeInternalError           ErrorCode = "INTERNAL_ERROR"
 )
 
-// ErrorResponse represents a structured error response
+// ErrorResponse represents a structured error response. Every API error
+// except the Elasticsearch and GraphQL compatible endpoints has this shape.
 type ErrorResponse struct {
-	Code    ErrorCode `json:"code"`
-	Message string    `json:"message"`
-	Details string    `json:"details,omitempty"`
+	Code      ErrorCode `json:"code"`
+	Message   string    `json:"message"`
+	Details   string    `json:"details,omitempty"`
+	RequestID string    `json:"requestId,omitempty"`
 }
 
 // ClusterErrorResponse extends ErrorResponse with cluster information
 type ClusterErrorResponse struct {
-	Code    ErrorCode `json:"code"`
-	Message string    `json:"message"`
-	Leader  string    `json:"leader,omitempty"`
+	Code      ErrorCode `json:"code"`
+	Message   string    `json:"message"`
+	Leader    string    `json:"leader,omitempty"`
+	RequestID string    `json:"requestId,omitempty"`
+}
+
+// RequestID returns the ID the request ID middleware assigned to the request
+func RequestID(c *fiber.Ctx) string {
+	return c.GetRespHeader(fiber.HeaderXRequestID)
+}
+
+// CodeForStatus returns the generic error code of an HTTP status, for errors
+// raised outside the handlers (unknown routes, oversized bodies, panics)
+func CodeForStatus(status int) ErrorCode {
+	switch {
+	case status == fiber.StatusNotFound:
+		return ErrorCodeRouteNotFound
+	case status == fiber.StatusMethodNotAllowed:
+		return ErrorCodeMethodNotAllowed
+	case status == fiber.StatusRequestEntityTooLarge:
+		return ErrorCodeRequestTooLarge
+	case status == fiber.StatusServiceUnavailable:
+		return ErrorCodeClusterUnavailable
+	case status < fiber.StatusInternalServerError:
+		return ErrorCodeInvalidRequest
+	default:
+		return ErrorCodeInternalError
+	}
+}
+
+// Respond writes an error response with any status
+func Respond(c *fiber.Ctx, status int, code ErrorCode, message, details string) error {
+	return c.Status(status).JSON(ErrorResponse{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: RequestID(c),
+	})
 }
 
 // Error helper functions
 
 func BadRequest(c *fiber.Ctx, code ErrorCode, message string) error {
-	return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
-		Code:    code,
-		Message: message,
-	})
+	return Respond(c, fiber.StatusBadRequest, code, message, "")
 }
 
 func BadRequestWithDetails(c *fiber.Ctx, code ErrorCode, message, details string) error {
-	return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
-		Code:    code,
-		Message: message,
-		Details: details,
-	})
+	return Respond(c, fiber.StatusBadRequest, code, message, details)
+}
+
+func Unauthorized(c *fiber.Ctx, code ErrorCode, message string) error {
+	return Respond(c, fiber.StatusUnauthorized, code, message, "")
 }
 
 func NotFound(c *fiber.Ctx, code ErrorCode, message string) error {
-	return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
-		Code:    code,
-		Message: message,
-	})
+	return Respond(c, fiber.StatusNotFound, code, message, "")
 }
 
 func Forbidden(c *fiber.Ctx, code ErrorCode, message string) error {
-	return c.Status(fiber.StatusForbidden).JSON(ErrorResponse{
-		Code:    code,
-		Message: message,
-	})
+	return Respond(c, fiber.StatusForbidden, code, message, "")
 }
 
 func ForbiddenWithLeader(c *fiber.Ctx, code ErrorCode, message, leader string) error {
 	return c.Status(fiber.StatusForbidden).JSON(ClusterErrorResponse{
-		Code:    code,
-		Message: message,
-		Leader:  leader,
+		Code:      code,
+		Message:   message,
+		Leader:    leader,
+		RequestID: RequestID(c),
 	})
 }
 
 func Conflict(c *fiber.Ctx, code ErrorCode, message string) error {
-	return c.Status(fiber.StatusConflict).JSON(ErrorResponse{
-		Code:    code,
-		Message: message,
+	return Respond(c, fiber.StatusConflict, code, message, "")
+}
+
+func TooManyRequests(c *fiber.Ctx, code ErrorCode, message string) error {
+	return Respond(c, fiber.StatusTooManyRequests, code, message, "")
+}
+
+func ServiceUnavailable(c *fiber.Ctx, code ErrorCode, message string) error {
+	return Respond(c, fiber.StatusServiceUnavailable, code, message, "")
+}
+
+func ServiceUnavailableWithLeader(c *fiber.Ctx, code ErrorCode, message, leader string) error {
+	return c.Status(fiber.StatusServiceUnavailable).JSON(ClusterErrorResponse{
+		Code:      code,
+		Message:   message,
+		Leader:    leader,
+		RequestID: RequestID(c),
 	})
 }
 
+func InsufficientStorage(c *fiber.Ctx, code ErrorCode, message string) error {
+	return Respond(c, fiber.StatusInsufficientStorage, code, message, "")
+}
+
 func InternalError(c *fiber.Ctx, code ErrorCode, message string) error {
-	return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
-		Code:    code,
-		Message: message,
-	})
+	return Respond(c, fiber.StatusInternalServerError, code, message, "")
 }
 
 func InternalErrorWithDetails(c *fiber.Ctx, code ErrorCode, message, details string) error {
-	return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
-		Code:    code,
-		Message: message,
-		Details: details,
-	})
+	return Respond(c, fiber.StatusInternalServerError, code, message, details)
 }
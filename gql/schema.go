@@ -0,0 +1,381 @@
+// Package gql serves a read-only GraphQL API over the index store: index
+// listing, document fetching and search. Every index gets its own document
+// type whose fields are generated from the index's field mapping, plus
+// generic untyped entry points for indexes whose documents vary.
+package gql
+
+import (
+	"bright/models"
+	"bright/search"
+	"bright/store"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// schemaTTL is how long a generated schema is reused before field mappings
+// are inspected again. Creating or deleting an index rebuilds it immediately.
+const schemaTTL = 30 * time.Second
+
+// Request is a GraphQL request body
+type Request struct {
+	Query         string         `json:"query" query:"query"`
+	Variables     map[string]any `json:"variables"`
+	OperationName string         `json:"operationName" query:"operationName"`
+
+	// ShowHiddenAttributes includes hidden attributes in fetched documents
+	// and search hits. It is set by the caller after checking permissions.
+	ShowHiddenAttributes bool `json:"-" query:"-"`
+}
+
+// showHiddenKey is the context key carrying Request.ShowHiddenAttributes
+type showHiddenKey struct{}
+
+// Service executes GraphQL requests against a store
+type Service struct {
+	store *store.IndexStore
+
+	mu       sync.Mutex
+	schema   *graphql.Schema
+	indexKey string
+	builtAt  time.Time
+}
+
+// NewService creates a GraphQL service for a store
+func NewService(s *store.IndexStore) *Service {
+	return &Service{store: s}
+}
+
+// Execute runs a GraphQL request
+func (s *Service) Execute(ctx context.Context, req Request) *graphql.Result {
+	schema, err := s.currentSchema()
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	return graphql.Do(graphql.Params{
+		Schema:         *schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        context.WithValue(ctx, showHiddenKey{}, req.ShowHiddenAttributes),
+	})
+}
+
+// currentSchema returns the cached schema, rebuilding it when it expired or
+// the set of indexes or their hidden attributes changed
+func (s *Service) currentSchema() (*graphql.Schema, error) {
+	configs := s.store.GetAllConfigs()
+	ids := make([]string, 0, len(configs))
+	for id := range configs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, id+"\x01"+strings.Join(configs[id].HiddenAttributes, "\x01"))
+	}
+	key := strings.Join(parts, "\x00")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.schema != nil && s.indexKey == key && time.Since(s.builtAt) < schemaTTL {
+		return s.schema, nil
+	}
+
+	schema, err := s.build(ids, configs)
+	if err != nil {
+		return nil, err
+	}
+	s.schema, s.indexKey, s.builtAt = schema, key, time.Now()
+	return schema, nil
+}
+
+// build generates the schema for the given indexes
+func (s *Service) build(ids []string, configs map[string]*models.IndexConfig) (*graphql.Schema, error) {
+	indexType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Index",
+		Description: "An index and its configuration",
+		Fields: graphql.Fields{
+			"id":                 &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"primaryKey":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"primaryKeyInferred": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"documentCount": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					config := p.Source.(*models.IndexConfig)
+					index, _, err := s.store.GetIndex(config.ID)
+					if err != nil {
+						return nil, err
+					}
+					count, err := index.DocCount()
+					return int(count), err
+				},
+			},
+		},
+	})
+
+	searchResultType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "SearchResult",
+		Description: "Untyped search results",
+		Fields: graphql.Fields{
+			"hits":       &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(JSON)))},
+			"totalHits":  &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"totalPages": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+
+	fields := graphql.Fields{
+		"indexes": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(indexType))),
+			Description: "All indexes ordered by ID",
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				list := make([]*models.IndexConfig, 0, len(ids))
+				for _, id := range ids {
+					list = append(list, configs[id])
+				}
+				return list, nil
+			},
+		},
+		"index": &graphql.Field{
+			Type: indexType,
+			Args: graphql.FieldConfigArgument{
+				"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+			},
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				_, config, err := s.store.GetIndex(p.Args["id"].(string))
+				if err != nil {
+					return nil, nil
+				}
+				return config, nil
+			},
+		},
+		"document": &graphql.Field{
+			Type:        JSON,
+			Description: "Fetch a document of any index by ID",
+			Args: graphql.FieldConfigArgument{
+				"index": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				"id":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+			},
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				return s.fetchDocument(p.Context, p.Args["index"].(string), p.Args["id"].(string))
+			},
+		},
+		"search": &graphql.Field{
+			Type:        graphql.NewNonNull(searchResultType),
+			Description: "Search any index",
+			Args: withSearchArgs(graphql.FieldConfigArgument{
+				"index": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+			}),
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				return s.search(p.Context, p.Args["index"].(string), p.Args)
+			},
+		},
+	}
+
+	// Typed entry points per index
+	typeNames := map[string]bool{"Index": true, "SearchResult": true, "JSON": true, "Query": true}
+	for _, id := range ids {
+		index, config, err := s.store.GetIndex(id)
+		if err != nil {
+			continue
+		}
+
+		typeName := uniqueName(typeNames, pascalCase(id))
+		documentType := documentObject(typeName, id, index, config.HiddenAttributes)
+
+		resultName := uniqueName(typeNames, typeName+"SearchResult")
+		resultType := graphql.NewObject(graphql.ObjectConfig{
+			Name:        resultName,
+			Description: fmt.Sprintf("Search results of index %q", id),
+			Fields: graphql.Fields{
+				"hits":       &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(documentType)))},
+				"totalHits":  &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"totalPages": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			},
+		})
+
+		indexID := id
+		getName := uniqueName(fieldNames(fields), lowerFirst(typeName))
+		fields[getName] = &graphql.Field{
+			Type:        documentType,
+			Description: fmt.Sprintf("Fetch a document of index %q by ID", id),
+			Args: graphql.FieldConfigArgument{
+				"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+			},
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				return s.fetchDocument(p.Context, indexID, p.Args["id"].(string))
+			},
+		}
+
+		searchName := uniqueName(fieldNames(fields), "search"+typeName)
+		fields[searchName] = &graphql.Field{
+			Type:        graphql.NewNonNull(resultType),
+			Description: fmt.Sprintf("Search index %q", id),
+			Args:        withSearchArgs(graphql.FieldConfigArgument{}),
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				return s.search(p.Context, indexID, p.Args)
+			},
+		}
+	}
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: fields}),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build GraphQL schema: %w", err)
+	}
+	return &schema, nil
+}
+
+// withSearchArgs adds the search parameters shared by all search fields
+func withSearchArgs(args graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	args["q"] = &graphql.ArgumentConfig{Type: graphql.String}
+	args["limit"] = &graphql.ArgumentConfig{Type: graphql.Int}
+	args["offset"] = &graphql.ArgumentConfig{Type: graphql.Int}
+	args["page"] = &graphql.ArgumentConfig{Type: graphql.Int}
+	args["sort"] = &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))}
+	args["attributesToRetrieve"] = &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))}
+	args["filter"] = &graphql.ArgumentConfig{Type: graphql.String}
+	args["excludeTerms"] = &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))}
+	return args
+}
+
+// hiddenAttributes returns the attributes to strip from results of an index
+func hiddenAttributes(ctx context.Context, config *models.IndexConfig) []string {
+	if show, _ := ctx.Value(showHiddenKey{}).(bool); show {
+		return nil
+	}
+	return config.HiddenAttributes
+}
+
+// search runs a search from GraphQL arguments
+func (s *Service) search(ctx context.Context, indexID string, args map[string]any) (*models.SearchResponse, error) {
+	index, config, err := s.store.GetIndex(indexID)
+	if err != nil {
+		return nil, err
+	}
+
+	req := models.SearchRequest{}
+	req.Query, _ = args["q"].(string)
+	req.Limit, _ = args["limit"].(int)
+	req.Offset, _ = args["offset"].(int)
+	req.Page, _ = args["page"].(int)
+	req.Sort = stringList(args["sort"])
+	req.AttributesToRetrieve = stringList(args["attributesToRetrieve"])
+	req.Filter, _ = args["filter"].(string)
+	req.ExcludeTerms = stringList(args["excludeTerms"])
+	req.HiddenAttributes = hiddenAttributes(ctx, config)
+	req.SortableAttributes = config.SortableAttributes
+	req.FilterableAttributes = config.FilterableAttributes
+	req.GeoAttributes = config.GeoAttributes
+	req.Vectors = config.Vectors
+	req.MaxLimit = config.MaxLimit
+	req.MaxTotalHits = config.MaxTotalHits
+	req.Synonyms = config.Synonyms
+	req.SearchableAttributes = config.SearchableAttributes
+	search.ApplyDefaults(&req, config.SearchDefaults)
+
+	response, err := search.Execute(index, req)
+	if err != nil {
+		return nil, err
+	}
+	s.store.Stats().RecordSearch(config.ID)
+	return response, nil
+}
+
+// fetchDocument returns a document's stored fields, or nil if it does not exist
+func (s *Service) fetchDocument(ctx context.Context, indexID, documentID string) (any, error) {
+	index, config, err := s.store.GetIndex(indexID)
+	if err != nil {
+		return nil, err
+	}
+
+	request := bleve.NewSearchRequest(bleve.NewDocIDQuery([]string{documentID}))
+	request.Fields = []string{"*"}
+	result, err := index.Search(request)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Hits) == 0 {
+		return nil, nil
+	}
+
+	document := make(map[string]any, len(result.Hits[0].Fields))
+	for field, value := range result.Hits[0].Fields {
+		document[field] = value
+	}
+	search.HideAttributes(document, hiddenAttributes(ctx, config))
+	return document, nil
+}
+
+func stringList(value any) []string {
+	values, _ := value.([]any)
+	list := make([]string, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// pascalCase turns an index ID into a GraphQL type name ("order-items" -> "OrderItems")
+func pascalCase(id string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range id {
+		if !isNameRune(r) || r == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+
+	name := b.String()
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "Index" + name
+	}
+	return name
+}
+
+func lowerFirst(name string) string {
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// isNameRune reports whether r may appear in a GraphQL name
+func isNameRune(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+// uniqueName appends a numeric suffix until name is unused, then reserves it
+func uniqueName(used map[string]bool, name string) string {
+	candidate := name
+	for i := 2; used[candidate]; i++ {
+		candidate = fmt.Sprintf("%s%d", name, i)
+	}
+	used[candidate] = true
+	return candidate
+}
+
+func fieldNames(fields graphql.Fields) map[string]bool {
+	names := make(map[string]bool, len(fields))
+	for name := range fields {
+		names[name] = true
+	}
+	return names
+}
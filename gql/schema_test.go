@@ -0,0 +1,89 @@
+package gql
+
+import (
+	"bright/models"
+	"bright/store"
+	"context"
+	"testing"
+)
+
+func TestTypedIndexQueries(t *testing.T) {
+	s, err := store.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.CreateIndex(&models.IndexConfig{ID: "order-items", PrimaryKey: "id"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	docs := []map[string]any{
+		{"id": "1", "title": "red shoe", "price": 12.5, "meta": map[string]any{"color": "red"}},
+		{"id": "2", "title": "blue hat", "price": 3.0},
+	}
+	if err := s.AddDocuments("order-items", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	service := NewService(s)
+	result := service.Execute(context.Background(), Request{
+		Query: `{ orderItems(id: "1") { title price meta_color } searchOrderItems(q: "hat") { totalHits hits { id } } }`,
+	})
+	if len(result.Errors) > 0 {
+		t.Fatalf("Query failed: %v", result.Errors)
+	}
+
+	data := result.Data.(map[string]any)
+	item := data["orderItems"].(map[string]any)
+	if item["title"] != "red shoe" || item["price"] != 12.5 || item["meta_color"] != "red" {
+		t.Errorf("Unexpected document: %v", item)
+	}
+
+	found := data["searchOrderItems"].(map[string]any)
+	hits := found["hits"].([]any)
+	if found["totalHits"] != 1 || len(hits) != 1 || hits[0].(map[string]any)["id"] != "2" {
+		t.Errorf("Unexpected search result: %v", found)
+	}
+}
+
+func TestHiddenAttributes(t *testing.T) {
+	s, err := store.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	config := &models.IndexConfig{ID: "users", PrimaryKey: "id", HiddenAttributes: []string{"email"}}
+	if err := s.CreateIndex(config); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	if err := s.AddDocuments("users", "", []map[string]any{{"id": "1", "name": "ada", "email": "ada@example.com"}}); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	service := NewService(s)
+	result := service.Execute(context.Background(), Request{Query: `{ users(id: "1") { email } }`})
+	if len(result.Errors) == 0 {
+		t.Error("Expected hidden attribute to have no field")
+	}
+
+	// Hidden attributes are searchable but not returned
+	query := `{ searchUsers(q: "email:ada@example.com") { hits { _source } } }`
+	result = service.Execute(context.Background(), Request{Query: query})
+	if len(result.Errors) > 0 {
+		t.Fatalf("Query failed: %v", result.Errors)
+	}
+	hits := result.Data.(map[string]any)["searchUsers"].(map[string]any)["hits"].([]any)
+	if len(hits) != 1 {
+		t.Fatalf("Expected 1 hit, got %d", len(hits))
+	}
+	if _, ok := hits[0].(map[string]any)["_source"].(map[string]any)["email"]; ok {
+		t.Error("Expected email to be hidden")
+	}
+
+	result = service.Execute(context.Background(), Request{Query: query, ShowHiddenAttributes: true})
+	hits = result.Data.(map[string]any)["searchUsers"].(map[string]any)["hits"].([]any)
+	if hits[0].(map[string]any)["_source"].(map[string]any)["email"] != "ada@example.com" {
+		t.Errorf("Expected email when requested, got %v", hits[0])
+	}
+}
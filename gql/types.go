@@ -0,0 +1,196 @@
+package gql
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// sampleSize is the number of documents inspected to infer field types of
+// dynamically mapped fields
+const sampleSize = 100
+
+// JSON is a scalar holding any JSON value
+var JSON = graphql.NewScalar(graphql.ScalarConfig{
+	Name:         "JSON",
+	Description:  "Any JSON value",
+	Serialize:    func(value any) any { return value },
+	ParseValue:   func(value any) any { return value },
+	ParseLiteral: parseLiteral,
+})
+
+func parseLiteral(value ast.Value) any {
+	switch v := value.(type) {
+	case *ast.ObjectValue:
+		object := make(map[string]any, len(v.Fields))
+		for _, field := range v.Fields {
+			object[field.Name.Value] = parseLiteral(field.Value)
+		}
+		return object
+	case *ast.ListValue:
+		list := make([]any, 0, len(v.Values))
+		for _, item := range v.Values {
+			list = append(list, parseLiteral(item))
+		}
+		return list
+	default:
+		return v.GetValue()
+	}
+}
+
+// fieldKind accumulates what was seen for one document field
+type fieldKind struct {
+	kinds map[string]bool
+	list  bool
+}
+
+func (k *fieldKind) add(kind string) {
+	if k.kinds == nil {
+		k.kinds = make(map[string]bool)
+	}
+	k.kinds[kind] = true
+}
+
+// outputType maps the observed kinds to a GraphQL type
+func (k *fieldKind) outputType() graphql.Output {
+	var scalar graphql.Output = JSON
+	if len(k.kinds) == 1 {
+		for kind := range k.kinds {
+			switch kind {
+			case "text", "datetime":
+				scalar = graphql.String
+			case "number":
+				scalar = graphql.Float
+			case "boolean":
+				scalar = graphql.Boolean
+			}
+		}
+	}
+	if k.list {
+		return graphql.NewList(scalar)
+	}
+	return scalar
+}
+
+// documentObject generates the GraphQL type of an index's documents. Fields
+// come from the explicit mapping and, for dynamic fields, from a sample of
+// stored documents. Hidden attributes get no field. Every type also has a
+// _source field with the whole document.
+func documentObject(typeName, indexID string, index bleve.Index, hidden []string) *graphql.Object {
+	kinds := make(map[string]*fieldKind)
+	kindOf := func(field string) *fieldKind {
+		if kinds[field] == nil {
+			kinds[field] = &fieldKind{}
+		}
+		return kinds[field]
+	}
+
+	if impl, ok := index.Mapping().(*mapping.IndexMappingImpl); ok && impl.DefaultMapping != nil {
+		collectMappedFields(impl.DefaultMapping, "", kindOf)
+	}
+
+	request := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), sampleSize, 0, false)
+	request.Fields = []string{"*"}
+	if result, err := index.Search(request); err == nil {
+		for _, hit := range result.Hits {
+			for field, value := range hit.Fields {
+				kind := kindOf(field)
+				if values, ok := value.([]any); ok {
+					kind.list = true
+					for _, v := range values {
+						kind.add(valueKind(v))
+					}
+					continue
+				}
+				kind.add(valueKind(value))
+			}
+		}
+	}
+
+	for _, name := range hidden {
+		delete(kinds, name)
+	}
+
+	names := make([]string, 0, len(kinds))
+	for name := range kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := graphql.Fields{
+		"_source": &graphql.Field{
+			Type:        JSON,
+			Description: "The whole document",
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source, nil
+			},
+		},
+	}
+	used := map[string]bool{"_source": true}
+	for _, name := range names {
+		original := name
+		fields[uniqueName(used, fieldName(name))] = &graphql.Field{
+			Type: kinds[name].outputType(),
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				document, _ := p.Source.(map[string]any)
+				return document[original], nil
+			},
+		}
+	}
+
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name:        typeName,
+		Description: fmt.Sprintf("A document of index %q", indexID),
+		Fields:      fields,
+	})
+}
+
+// collectMappedFields walks explicit document mappings
+func collectMappedFields(doc *mapping.DocumentMapping, prefix string, kindOf func(string) *fieldKind) {
+	for name, property := range doc.Properties {
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		for _, field := range property.Fields {
+			kindOf(path).add(field.Type)
+		}
+		collectMappedFields(property, path, kindOf)
+	}
+}
+
+func valueKind(value any) string {
+	switch value.(type) {
+	case string:
+		return "text"
+	case float64, int, int64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "json"
+	}
+}
+
+// fieldName turns a document field into a GraphQL field name ("address.city" -> "address_city")
+func fieldName(field string) string {
+	var b strings.Builder
+	for _, r := range field {
+		if isNameRune(r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+
+	name := b.String()
+	if name == "" || (name[0] >= '0' && name[0] <= '9') || strings.HasPrefix(name, "__") {
+		name = "f_" + name
+	}
+	return name
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"bright/alerts"
+	"bright/errors"
+	"bright/models"
+	"bright/raft"
+	"bright/rpc"
+	"bright/store"
+	"encoding/json"
+	stderrors "errors"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/google/uuid"
+)
+
+// AlertInfo is the API representation of a rule with its state on this node
+type AlertInfo struct {
+	*models.AlertRule
+	Status alerts.Status `json:"status"`
+}
+
+func toAlertInfo(c *fiber.Ctx, rule *models.AlertRule) AlertInfo {
+	info := AlertInfo{AlertRule: rule, Status: alerts.Status{State: alerts.StateInactive}}
+	if scheduler := GetContext(c).Alerts; scheduler != nil {
+		info.Status = scheduler.Status(rule.ID)
+	}
+	return info
+}
+
+// ListAlerts handles GET /alerts
+func ListAlerts(c *fiber.Ctx) error {
+	items := make([]AlertInfo, 0)
+	for _, rule := range GetContext(c).Store.AlertRules().List() {
+		items = append(items, toAlertInfo(c, rule))
+	}
+
+	return c.JSON(fiber.Map{
+		"items": items,
+	})
+}
+
+// GetAlert handles GET /alerts/:id
+func GetAlert(c *fiber.Ctx) error {
+	rule, err := GetContext(c).Store.AlertRules().Get(c.Params("id"))
+	if err != nil {
+		return errors.NotFound(c, errors.ErrorCodeAlertNotFound, err.Error())
+	}
+
+	return c.JSON(toAlertInfo(c, rule))
+}
+
+// CreateAlert handles POST /alerts
+func CreateAlert(c *fiber.Ctx) error {
+	var rule models.AlertRule
+	if err := c.BodyParser(&rule); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "invalid request body")
+	}
+
+	ctx := GetContext(c)
+	if IsRaftEnabled(c) && !IsLeader(c) {
+		return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return errors.InternalError(c, errors.ErrorCodeUUIDGenerationFailed, "failed to generate UUID")
+	}
+	rule.ID = id.String()
+	rule.CreatedAt = time.Now().UTC()
+
+	return putAlert(c, &rule, fiber.StatusCreated)
+}
+
+// UpdateAlert handles PUT /alerts/:id
+// Replaces a rule; its state starts over if it watches something else.
+func UpdateAlert(c *fiber.Ctx) error {
+	var rule models.AlertRule
+	if err := c.BodyParser(&rule); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "invalid request body")
+	}
+
+	ctx := GetContext(c)
+	if IsRaftEnabled(c) && !IsLeader(c) {
+		return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
+	}
+
+	existing, err := ctx.Store.AlertRules().Get(c.Params("id"))
+	if err != nil {
+		return errors.NotFound(c, errors.ErrorCodeAlertNotFound, err.Error())
+	}
+	rule.ID = existing.ID
+	rule.CreatedAt = existing.CreatedAt
+
+	return putAlert(c, &rule, fiber.StatusOK)
+}
+
+// putAlert validates and stores a rule
+func putAlert(c *fiber.Ctx, rule *models.AlertRule, status int) error {
+	ctx := GetContext(c)
+
+	// Make copies of the strings to avoid Fiber buffer reuse issues
+	rule.Index = utils.CopyString(rule.Index)
+	rule.Ingress = utils.CopyString(rule.Ingress)
+
+	emailEnabled := ctx.Alerts != nil && ctx.Alerts.EmailEnabled()
+	if err := alerts.Validate(rule, emailEnabled); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, err.Error())
+	}
+
+	// If Raft is enabled, apply command through consensus
+	if IsRaftEnabled(c) {
+		ruleJSON, err := sonic.Marshal(rule)
+		if err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeSerializationFailed, "failed to serialize alert rule", err.Error())
+		}
+
+		cmd := raft.Command{
+			Type: raft.CommandPutAlert,
+			Data: json.RawMessage(ruleJSON),
+		}
+
+		if err := ctx.RaftNode.Apply(cmd, 10*time.Second); err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeRaftApplyFailed, "failed to store alert rule via Raft", err.Error())
+		}
+	} else if err := ctx.Store.AlertRules().Put(rule); err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to store alert rule", err.Error())
+	}
+
+	return c.Status(status).JSON(toAlertInfo(c, rule))
+}
+
+// DeleteAlert handles DELETE /alerts/:id
+func DeleteAlert(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	ctx := GetContext(c)
+
+	// If Raft is enabled, apply command through consensus
+	if IsRaftEnabled(c) {
+		if !IsLeader(c) {
+			return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
+		}
+
+		if _, err := ctx.Store.AlertRules().Get(id); err != nil {
+			return errors.NotFound(c, errors.ErrorCodeAlertNotFound, err.Error())
+		}
+
+		payloadData, err := sonic.Marshal(raft.DeleteAlertPayload{ID: id})
+		if err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeSerializationFailed, "failed to serialize payload", err.Error())
+		}
+
+		cmd := raft.Command{
+			Type: raft.CommandDeleteAlert,
+			Data: json.RawMessage(payloadData),
+		}
+
+		if err := ctx.RaftNode.Apply(cmd, 10*time.Second); err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeRaftApplyFailed, "failed to delete alert rule via Raft", err.Error())
+		}
+
+		return c.Status(fiber.StatusNoContent).Send(nil)
+	}
+
+	if err := ctx.Store.AlertRules().Delete(id); err != nil {
+		if stderrors.Is(err, store.ErrAlertRuleNotFound) {
+			return errors.NotFound(c, errors.ErrorCodeAlertNotFound, err.Error())
+		}
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to delete alert rule", err.Error())
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
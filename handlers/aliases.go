@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"bright/errors"
+	"bright/models"
+	"bright/raft"
+	"bright/rpc"
+	"bright/store"
+	"encoding/json"
+	stderrors "errors"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// ListAliases handles GET /aliases
+func ListAliases(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"items": GetContext(c).Store.Aliases().List(),
+	})
+}
+
+// GetAlias handles GET /aliases/:name
+func GetAlias(c *fiber.Ctx) error {
+	alias, err := GetContext(c).Store.Aliases().Get(c.Params("name"))
+	if err != nil {
+		return errors.NotFound(c, errors.ErrorCodeAliasNotFound, err.Error())
+	}
+
+	return c.JSON(alias)
+}
+
+// Rollover handles POST /indexes/:id/rollover
+// Checks the write index of the alias :id against the request's conditions
+// and, when one is met, creates the next partition with the same settings,
+// points the alias's writes at it and deletes partitions beyond maxIndexes.
+// The first rollover of an alias creates it from the index <alias>-000001.
+func Rollover(c *fiber.Ctx) error {
+	var req models.RolloverRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "invalid request body")
+		}
+	}
+	if req.MaxIndexes < 0 || req.Conditions.MaxSizeBytes < 0 {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, "maxIndexes and maxSizeBytes can't be negative")
+	}
+	if req.Conditions.MaxAge != "" {
+		if _, err := store.ParseRolloverAge(req.Conditions.MaxAge); err != nil {
+			return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, err.Error())
+		}
+	}
+
+	ctx := GetContext(c)
+	if IsRaftEnabled(c) && !IsLeader(c) {
+		return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
+	}
+
+	now := time.Now().UTC()
+	name := utils.CopyString(c.Params("id"))
+	alias, err := ctx.Store.ResolveAlias(name, now)
+	if err != nil {
+		if stderrors.Is(err, store.ErrAliasNotFound) {
+			return errors.NotFound(c, errors.ErrorCodeAliasNotFound, err.Error())
+		}
+		return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, err.Error())
+	}
+
+	met, rollover, err := ctx.Store.RolloverConditionsMet(alias, req.Conditions, now)
+	if err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeIndexOperationFailed, "failed to check rollover conditions", err.Error())
+	}
+
+	response := models.RolloverResponse{
+		Alias:      alias.Name,
+		OldIndex:   alias.WriteIndex,
+		DryRun:     req.DryRun,
+		Conditions: met,
+	}
+	if !rollover {
+		return c.JSON(response)
+	}
+
+	newIndex, err := store.NextPartition(alias.WriteIndex)
+	if err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, err.Error())
+	}
+	_, writeConfig, err := ctx.Store.GetIndex(alias.WriteIndex)
+	if err != nil {
+		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
+	}
+	if _, _, err := ctx.Store.GetIndex(newIndex); err == nil {
+		return errors.Conflict(c, errors.ErrorCodeResourceAlreadyExists, "index "+newIndex+" already exists")
+	}
+
+	indexes := append(append([]string(nil), alias.Indexes...), newIndex)
+	var deleted []string
+	if req.MaxIndexes > 0 && len(indexes) > req.MaxIndexes {
+		deleted = indexes[:len(indexes)-req.MaxIndexes]
+		indexes = indexes[len(indexes)-req.MaxIndexes:]
+	}
+
+	response.NewIndex = newIndex
+	response.RolledOver = !req.DryRun
+	response.DeletedIndexes = deleted
+	if req.DryRun {
+		return c.JSON(response)
+	}
+
+	// The new partition copies the settings of the current write index
+	config := *writeConfig
+	config.ID = newIndex
+	if err := applyAliasCommand(c, raft.CommandCreateIndex, &config, func() error {
+		return ctx.Store.CreateIndex(&config)
+	}); err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeIndexOperationFailed, "failed to create index "+newIndex, err.Error())
+	}
+
+	rolled := &models.IndexAlias{
+		Name:         alias.Name,
+		WriteIndex:   newIndex,
+		Indexes:      indexes,
+		RolledOverAt: now,
+	}
+	if err := applyAliasCommand(c, raft.CommandPutAlias, rolled, func() error {
+		return ctx.Store.Aliases().Put(rolled)
+	}); err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to update alias "+alias.Name, err.Error())
+	}
+
+	for _, id := range deleted {
+		if err := applyAliasCommand(c, raft.CommandDeleteIndex, raft.DeleteIndexPayload{ID: id}, func() error {
+			return ctx.Store.DeleteIndex(id)
+		}); err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeIndexOperationFailed, "failed to delete index "+id, err.Error())
+		}
+	}
+
+	return c.JSON(response)
+}
+
+// applyAliasCommand applies a rollover step through Raft when it is
+// enabled, and directly otherwise
+func applyAliasCommand(c *fiber.Ctx, commandType raft.CommandType, payload any, direct func() error) error {
+	if !IsRaftEnabled(c) {
+		return direct()
+	}
+
+	data, err := sonic.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	cmd := raft.Command{
+		Type: commandType,
+		Data: json.RawMessage(data),
+	}
+	return GetContext(c).RaftNode.Apply(cmd, 10*time.Second)
+}
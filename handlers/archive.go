@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"bright/errors"
+	"bright/jobs"
+	"bright/raft"
+	"bright/rpc"
+	"bright/store"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// archiveReplicationBatch is the number of archived documents per Raft
+// command when an archive is imported into a cluster
+const archiveReplicationBatch = 1000
+
+// GetIndexSnapshot handles GET /indexes/:id/snapshot
+// Streams a gzipped tar of the index's configuration and data as they are on
+// this node when the request arrives.
+func GetIndexSnapshot(c *fiber.Ctx) error {
+	id := utils.CopyString(c.Params("id"))
+
+	ctx := GetContext(c)
+	if _, _, err := ctx.Store.GetIndex(id); err != nil {
+		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
+	}
+
+	archive, err := ctx.Store.ArchiveIndex(id)
+	if err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to snapshot index", err.Error())
+	}
+
+	filename := fmt.Sprintf("%s-%s.tar.gz", id, archive.Manifest.CreatedAt.Format("20060102T150405Z"))
+	c.Set(fiber.HeaderContentType, "application/gzip")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	c.Set("X-Bright-Documents", fmt.Sprint(archive.Manifest.Documents))
+
+	// The status is sent before the archive is written, so a failure can
+	// only cut the download short, leaving a truncated gzip stream
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer archive.Close()
+		if archive.Write(w) == nil {
+			w.Flush()
+		}
+	})
+	return nil
+}
+
+// importIndexArchive handles POST /indexes?fromArchive=true
+// The archive's settings and documents become a new index. A single node
+// installs the archived index files as they are; in a cluster the leader
+// replicates the index creation and then the documents in batches, so every
+// node builds its copy from the Raft log.
+func importIndexArchive(c *fiber.Ctx, id, primaryKey string) error {
+	ctx := GetContext(c)
+
+	if diskWritesRefused(c) {
+		return diskFullError(c)
+	}
+	if IsRaftEnabled(c) && !IsLeader(c) {
+		return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
+	}
+	if _, _, err := ctx.Store.GetIndex(id); err == nil {
+		return errors.Conflict(c, errors.ErrorCodeResourceAlreadyExists, fmt.Sprintf("index %s already exists", id))
+	}
+
+	archive, err := ctx.Store.ReadArchive(bytes.NewReader(c.Body()))
+	if err != nil {
+		if stderrors.Is(err, store.ErrInvalidArchive) {
+			return errors.BadRequest(c, errors.ErrorCodeInvalidArchive, err.Error())
+		}
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeIndexOperationFailed, "failed to read archive", err.Error())
+	}
+	defer archive.Close()
+
+	config := archive.Config
+	config.ID = id
+	if primaryKey != "" && primaryKey != config.PrimaryKey {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidIndexSettings, fmt.Sprintf("the archived index has primary key %s", config.PrimaryKey))
+	}
+	if err := store.ValidateIndexSettings(config); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidIndexSettings, err.Error())
+	}
+	c.Set("X-Bright-Documents", fmt.Sprint(archive.Manifest.Documents))
+
+	if !IsRaftEnabled(c) {
+		if err := ctx.Store.InstallArchive(archive, config); err != nil {
+			if stderrors.Is(err, store.ErrInvalidArchive) {
+				return errors.BadRequest(c, errors.ErrorCodeInvalidArchive, err.Error())
+			}
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeIndexOperationFailed, "failed to import index", err.Error())
+		}
+		return c.Status(fiber.StatusCreated).JSON(config)
+	}
+
+	configJSON, err := sonic.Marshal(config)
+	if err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeSerializationFailed, "failed to serialize payload", err.Error())
+	}
+	cmd := raft.Command{
+		Type: raft.CommandCreateIndex,
+		Data: json.RawMessage(configJSON),
+	}
+	if err := ctx.RaftNode.Apply(cmd, 10*time.Second); err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeRaftApplyFailed, "failed to create index via Raft", err.Error())
+	}
+
+	job := ctx.Store.Jobs().Start(jobs.KindImport, id)
+	defer job.Done()
+
+	err = archive.ScanDocuments(archiveReplicationBatch, func(documents []map[string]any) error {
+		payloadData, err := sonic.Marshal(raft.AddDocumentsPayload{IndexID: id, Documents: documents})
+		if err != nil {
+			return err
+		}
+		err = ctx.RaftNode.Apply(raft.Command{
+			Type: raft.CommandAddDocuments,
+			Data: json.RawMessage(payloadData),
+		}, 10*time.Second)
+		if err != nil {
+			return err
+		}
+		return job.Wait(context.Background(), len(documents))
+	})
+	if err != nil {
+		// The index is left with the documents replicated so far
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeRaftApplyFailed, "failed to replicate archived documents via Raft", err.Error())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(config)
+}
@@ -2,6 +2,14 @@ package handlers
 
 import (
 	"bright/errors"
+	"bright/models"
+	"bright/raft"
+	"bright/rpc"
+	"bright/store"
+	"encoding/json"
+	"time"
+
+	"github.com/bytedance/sonic"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -17,11 +25,19 @@ func ClusterStatus(c *fiber.Ctx) error {
 		})
 	}
 
+	inFlight := ctx.Store.Jobs().Foregrounds()
+	if ctx.Router != nil {
+		inFlight = ctx.Router.InFlight()
+	}
+
 	return c.JSON(fiber.Map{
-		"mode":      "clustered",
-		"node_id":   ctx.RaftNode.GetConfig().NodeID,
-		"is_leader": IsLeader(c),
-		"leader":    ctx.RaftNode.LeaderAddr(),
+		"mode":          "clustered",
+		"node_id":       ctx.RaftNode.GetConfig().NodeID,
+		"is_leader":     IsLeader(c),
+		"leader":        ctx.RaftNode.LeaderAddr(),
+		"applied_index": ctx.RaftNode.AppliedIndex(),
+		"in_flight":     inFlight,
+		"tags":          ctx.Config.NodeTags,
 	})
 }
 
@@ -55,3 +71,113 @@ func JoinCluster(c *fiber.Ctx) error {
 		"node_id": req.NodeID,
 	})
 }
+
+// ListClusterNodes returns the current cluster membership
+func ListClusterNodes(c *fiber.Ctx) error {
+	ctx := GetContext(c)
+
+	nodes, err := ctx.RaftNode.Nodes()
+	if err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeClusterUnavailable, "failed to read cluster configuration", err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"nodes":  nodes,
+		"leader": ctx.RaftNode.LeaderAddr(),
+	})
+}
+
+// RemoveClusterNode removes a node from the Raft cluster
+func RemoveClusterNode(c *fiber.Ctx) error {
+	var req struct {
+		NodeID string `json:"node_id"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "invalid request body")
+	}
+
+	if req.NodeID == "" {
+		return errors.BadRequest(c, errors.ErrorCodeMissingParameter, "node_id is required")
+	}
+
+	ctx := GetContext(c)
+
+	if !IsLeader(c) {
+		return errors.ForbiddenWithLeader(c, errors.ErrorCodeLeaderOnlyOperation, "only leader can remove nodes", ctx.RaftNode.LeaderAddr())
+	}
+
+	if err := ctx.RaftNode.Remove(req.NodeID); err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeClusterUnavailable, "failed to remove node from cluster", err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "removed",
+		"node_id": req.NodeID,
+	})
+}
+
+// TransferLeadership hands leadership over to another node
+func TransferLeadership(c *fiber.Ctx) error {
+	var req struct {
+		NodeID string `json:"node_id"`
+	}
+
+	// Body is optional: without a node_id Raft picks the best follower
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "invalid request body")
+		}
+	}
+
+	ctx := GetContext(c)
+
+	if !IsLeader(c) {
+		return errors.ForbiddenWithLeader(c, errors.ErrorCodeLeaderOnlyOperation, "only leader can transfer leadership", ctx.RaftNode.LeaderAddr())
+	}
+
+	if err := ctx.RaftNode.TransferLeadership(req.NodeID); err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeClusterUnavailable, "failed to transfer leadership", err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "transferred",
+		"leader": ctx.RaftNode.LeaderAddr(),
+	})
+}
+
+// GetRouting returns the search routing policy of the cluster with the load
+// and lag of its nodes as this node sees them
+func GetRouting(c *fiber.Ctx) error {
+	ctx := GetContext(c)
+	if ctx.Router == nil {
+		return errors.ServiceUnavailable(c, errors.ErrorCodeClusterUnavailable, "search routing is not running")
+	}
+	return c.JSON(ctx.Router.Table())
+}
+
+// PutRouting sets the search routing policy of the cluster
+func PutRouting(c *fiber.Ctx) error {
+	var policy models.RoutingPolicy
+	if err := c.BodyParser(&policy); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "invalid request body")
+	}
+	if err := store.ValidateRoutingPolicy(&policy); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, err.Error())
+	}
+
+	ctx := GetContext(c)
+	if !IsLeader(c) {
+		return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
+	}
+
+	data, err := sonic.Marshal(policy)
+	if err != nil {
+		return errors.InternalError(c, errors.ErrorCodeInternalError, "failed to encode routing policy")
+	}
+	if err := ctx.RaftNode.Apply(raft.Command{Type: raft.CommandSetRoutingPolicy, Data: json.RawMessage(data)}, 10*time.Second); err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeClusterUnavailable, "failed to set routing policy", err.Error())
+	}
+
+	return c.JSON(policy)
+}
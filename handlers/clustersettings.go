@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"bright/errors"
+	"bright/raft"
+	"bright/rpc"
+	"bright/store"
+	"encoding/json"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetSettings handles GET /cluster/settings
+// Returns the value of every cluster setting, and the settings that were set.
+func GetSettings(c *fiber.Ctx) error {
+	settings := GetContext(c).Store.Settings()
+	return c.JSON(fiber.Map{
+		"settings":  settings.Effective(),
+		"overrides": settings.Overrides(),
+	})
+}
+
+// UpdateSettings handles PATCH /cluster/settings
+// Sets the cluster settings in the body, resetting those set to null.
+func UpdateSettings(c *fiber.Ctx) error {
+	var changes map[string]any
+	if err := sonic.Unmarshal(c.Body(), &changes); err != nil || changes == nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "invalid request body")
+	}
+	if err := store.ValidateSettings(changes); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, err.Error())
+	}
+
+	ctx := GetContext(c)
+	if IsRaftEnabled(c) {
+		if !IsLeader(c) {
+			return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
+		}
+		data, err := sonic.Marshal(changes)
+		if err != nil {
+			return errors.InternalError(c, errors.ErrorCodeInternalError, "failed to encode cluster settings")
+		}
+		if err := ctx.RaftNode.Apply(raft.Command{Type: raft.CommandUpdateSettings, Data: json.RawMessage(data)}, 10*time.Second); err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeClusterUnavailable, "failed to update cluster settings", err.Error())
+		}
+	} else if err := ctx.Store.Settings().Update(changes); err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to update cluster settings", err.Error())
+	}
+
+	return GetSettings(c)
+}
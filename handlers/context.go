@@ -1,9 +1,16 @@
 package handlers
 
 import (
+	"bright/alerts"
 	"bright/config"
+	"bright/disk"
+	"bright/gql"
+	"bright/keys"
 	"bright/raft"
+	"bright/replication"
+	"bright/routing"
 	"bright/rpc"
+	"bright/schedules"
 	"bright/store"
 
 	"github.com/gofiber/fiber/v2"
@@ -16,6 +23,20 @@ type HandlerContext struct {
 	Config         *config.Config
 	RPCClient      rpc.RPCClient
 	IngressManager IngressManager
+	Keys           *keys.Store
+	GraphQL        *gql.Service
+	Alerts         *alerts.Scheduler
+	Schedules      *schedules.Runner
+	Disk           *disk.Monitor
+
+	// Replication follows the source cluster of a standby, nil otherwise
+	Replication *replication.Follower
+
+	// Router routes searches across the cluster, nil without Raft
+	Router *routing.Router
+
+	// Version is the server's build version
+	Version string
 }
 
 const contextKey = "handler_context"
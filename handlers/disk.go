@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"bright/disk"
+	"bright/errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// diskWritesRefused returns true if the disk of this node is above the low
+// watermark. Document writes are refused then, while deletes that free space
+// are still accepted.
+func diskWritesRefused(c *fiber.Ctx) bool {
+	monitor := GetContext(c).Disk
+	return monitor != nil && monitor.Level() != disk.LevelOK
+}
+
+// diskFullMessage describes why a document write was refused
+func diskFullMessage(c *fiber.Ctx) string {
+	status := GetContext(c).Disk.Status()
+	watermark := status.Low
+	if watermark == 0 || status.Level == disk.LevelHigh {
+		watermark = status.High
+	}
+	return fmt.Sprintf("disk usage %.1f%% exceeds the %s watermark of %g%%, document writes are refused", status.UsedPercent, status.Level, watermark)
+}
+
+// diskFullError responds to a refused document write
+func diskFullError(c *fiber.Ctx) error {
+	return errors.InsufficientStorage(c, errors.ErrorCodeDiskWatermarkExceeded, diskFullMessage(c))
+}
@@ -6,25 +6,35 @@ import (
 	"bright/models"
 	"bright/raft"
 	"bright/rpc"
+	"bright/search"
 	"bright/store"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/blevesearch/bleve/v2"
 	"github.com/bytedance/sonic"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
 	"github.com/google/uuid"
 )
 
 // handleRaftAutoCreate handles automatic index creation in Raft mode
-func handleRaftAutoCreate(c *fiber.Ctx, indexID string, config *models.IndexConfig, documents []map[string]any) error {
+func handleRaftAutoCreate(c *fiber.Ctx, indexID string, config *models.IndexConfig, documents []map[string]any, key string) error {
 	ctx := GetContext(c)
 
 	if !IsLeader(c) {
 		return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
 	}
 
+	if record, applied := appliedIdempotencyKey(c, indexID, key); applied {
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"indexed": record.Count,
+		})
+	}
+
 	// Generate UUIDs for documents missing primary key
 	for _, doc := range documents {
 		if id, ok := doc[config.PrimaryKey]; !ok || id == nil {
@@ -38,9 +48,11 @@ func handleRaftAutoCreate(c *fiber.Ctx, indexID string, config *models.IndexConf
 
 	// Serialize payload
 	payloadData, err := sonic.Marshal(raft.AutoCreateAndAddDocumentsPayload{
-		IndexID:    indexID,
-		PrimaryKey: config.PrimaryKey,
-		Documents:  documents,
+		IndexID:            indexID,
+		PrimaryKey:         config.PrimaryKey,
+		PrimaryKeyInferred: config.PrimaryKeyInferred,
+		Documents:          documents,
+		IdempotencyKey:     key,
 	})
 	if err != nil {
 		return errors.InternalErrorWithDetails(c, errors.ErrorCodeSerializationFailed, "failed to serialize payload", err.Error())
@@ -57,15 +69,21 @@ func handleRaftAutoCreate(c *fiber.Ctx, indexID string, config *models.IndexConf
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"indexed":      len(documents),
-		"auto_created": true,
-		"primary_key":  config.PrimaryKey,
+		"indexed":              len(documents),
+		"auto_created":         true,
+		"primary_key":          config.PrimaryKey,
+		"primary_key_inferred": config.PrimaryKeyInferred,
 	})
 }
 
 // AddDocuments handles POST /indexes/:id/documents
 func AddDocuments(c *fiber.Ctx) error {
-	indexID := c.Params("id")
+	if diskWritesRefused(c) {
+		return diskFullError(c)
+	}
+
+	indexID := GetContext(c).Store.WriteIndex(c.Params("id"))
+	defer recordIngest(c, indexID)
 	format := c.Query("format", "jsoneachrow")
 	primaryKey := c.Query("primaryKey")
 
@@ -83,8 +101,33 @@ func AddDocuments(c *fiber.Ctx) error {
 		return errors.BadRequestWithDetails(c, errors.ErrorCodeParseError, "failed to parse documents", err.Error())
 	}
 
-	s := store.GetStore()
-	index, config, err := s.GetIndex(indexID)
+	key, err := idempotencyKey(c)
+	if err != nil {
+		return errors.BadRequestWithDetails(c, errors.ErrorCodeInvalidParameter, "invalid idempotency key", err.Error())
+	}
+
+	refresh := c.Query("refresh")
+	if err := store.ValidateRefresh(refresh); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, err.Error())
+	}
+
+	// Single-node mode: skip writes that were already applied under this key
+	if !IsRaftEnabled(c) {
+		record, err := reserveIdempotencyKey(c, indexID, key)
+		if err != nil {
+			return errors.Conflict(c, errors.ErrorCodeIdempotencyKeyInUse, err.Error())
+		}
+		if record != nil {
+			return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+				"indexed": record.Count,
+			})
+		}
+		// Released on failure; a successful write completes the key below
+		defer releaseIdempotencyKey(c, indexID, key)
+	}
+
+	s := GetContext(c).Store
+	_, config, err := s.GetIndex(indexID)
 
 	// If index doesn't exist, attempt auto-creation if enabled
 	if err != nil {
@@ -99,15 +142,26 @@ func AddDocuments(c *fiber.Ctx) error {
 			detectedPrimaryKey = primaryKey
 		} else {
 			var err error
-			detectedPrimaryKey, err = store.DetectPrimaryKey(documents)
+			detectedPrimaryKey, err = store.DetectPrimaryKey(documents, store.PrimaryKeyHeuristics{
+				Default:        ctx.Config.DefaultPrimaryKey,
+				PreferredNames: ctx.Config.PrimaryKeyCandidates,
+				PreferExactID:  ctx.Config.PreferExactIDKey,
+				Fallback:       ctx.Config.PrimaryKeyFallback,
+			})
 			if err != nil {
 				return errors.BadRequestWithDetails(c, errors.ErrorCodeInvalidParameter, "cannot auto-create index", err.Error())
 			}
 		}
 
+		// Validate before creating so a bad batch leaves no empty index behind
+		if err := store.ValidatePrimaryKeys(detectedPrimaryKey, documents, key != ""); err != nil {
+			return primaryKeyError(c, err)
+		}
+
 		autoConfig := &models.IndexConfig{
-			ID:         indexID,
-			PrimaryKey: detectedPrimaryKey,
+			ID:                 utils.CopyString(indexID),
+			PrimaryKey:         utils.CopyString(detectedPrimaryKey),
+			PrimaryKeyInferred: primaryKey == "",
 		}
 
 		// Single-node mode: create directly
@@ -116,13 +170,13 @@ func AddDocuments(c *fiber.Ctx) error {
 				return errors.InternalErrorWithDetails(c, errors.ErrorCodeIndexOperationFailed, "failed to auto-create index", err.Error())
 			}
 			// Get the newly created index
-			index, config, err = s.GetIndex(indexID)
+			_, config, err = s.GetIndex(indexID)
 			if err != nil {
 				return errors.InternalError(c, errors.ErrorCodeIndexOperationFailed, err.Error())
 			}
 		} else {
 			// Raft mode: use compound command
-			return handleRaftAutoCreate(c, indexID, autoConfig, documents)
+			return handleRaftAutoCreate(c, indexID, autoConfig, documents, key)
 		}
 	}
 
@@ -132,6 +186,10 @@ func AddDocuments(c *fiber.Ctx) error {
 		effectivePrimaryKey = primaryKey
 	}
 
+	if err := store.ValidatePrimaryKeys(effectivePrimaryKey, documents, key != ""); err != nil {
+		return primaryKeyError(c, err)
+	}
+
 	// Generate document IDs for documents that don't have one
 	for _, doc := range documents {
 		if id, ok := doc[effectivePrimaryKey]; !ok || id == nil {
@@ -153,10 +211,22 @@ func AddDocuments(c *fiber.Ctx) error {
 			return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
 		}
 
+		if record, applied := appliedIdempotencyKey(c, indexID, key); applied {
+			return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+				"indexed": record.Count,
+			})
+		}
+
+		if err := ctx.Store.ExtractAttachments(c.Context(), indexID, documents); err != nil {
+			return errors.BadRequestWithDetails(c, errors.ErrorCodeInvalidAttachment, "failed to extract attachment", err.Error())
+		}
+		response := enrichDocuments(c, indexID, documents)
+
 		// Serialize payload
 		payloadData, err := sonic.Marshal(raft.AddDocumentsPayload{
-			IndexID:   indexID,
-			Documents: documents,
+			IndexID:        indexID,
+			Documents:      documents,
+			IdempotencyKey: key,
 		})
 		if err != nil {
 			return errors.InternalErrorWithDetails(c, errors.ErrorCodeSerializationFailed, "failed to serialize payload", err.Error())
@@ -172,149 +242,536 @@ func AddDocuments(c *fiber.Ctx) error {
 			return errors.InternalErrorWithDetails(c, errors.ErrorCodeRaftApplyFailed, "failed to add documents via Raft", err.Error())
 		}
 
-		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-			"indexed": len(documents),
-		})
-	}
-
-	// Single-node mode: process each document in a batch
-	batch := index.NewBatch()
-	for _, doc := range documents {
-		var docID string
-		if id, ok := doc[effectivePrimaryKey]; ok && id != nil {
-			docID = fmt.Sprintf("%v", id)
-		} else {
-			return errors.InternalError(c, errors.ErrorCodeDocumentOperationFailed, "document missing primary key")
+		if err := applyRefresh(c, indexID, refresh); err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeBatchOperationFailed, "failed to refresh index", err.Error())
 		}
 
-		// Index or update the document
-		if err := batch.Index(docID, doc); err != nil {
-			return errors.InternalErrorWithDetails(c, errors.ErrorCodeDocumentOperationFailed, "failed to index document", err.Error())
-		}
+		return c.Status(fiber.StatusCreated).JSON(response)
 	}
 
-	if err := index.Batch(batch); err != nil {
+	if err := s.ExtractAttachments(c.Context(), indexID, documents); err != nil {
+		return errors.BadRequestWithDetails(c, errors.ErrorCodeInvalidAttachment, "failed to extract attachment", err.Error())
+	}
+	response := enrichDocuments(c, indexID, documents)
+
+	// Single-node mode: journal and index the batch
+	if err := s.AddDocuments(indexID, effectivePrimaryKey, documents); err != nil {
 		return errors.InternalErrorWithDetails(c, errors.ErrorCodeBatchOperationFailed, "failed to commit batch", err.Error())
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"indexed": len(documents),
-	})
+	if err := completeIdempotencyKey(c, indexID, key, store.OperationAddDocuments, len(documents)); err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to record idempotency key", err.Error())
+	}
+
+	if err := applyRefresh(c, indexID, refresh); err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeBatchOperationFailed, "failed to refresh index", err.Error())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(response)
 }
 
+// applyRefresh makes a write to an index with a refresh interval searchable
+// as its refresh policy asks. Other indexes refresh on every write anyway.
+func applyRefresh(c *fiber.Ctx, indexID, policy string) error {
+	s := GetContext(c).Store
+	switch policy {
+	case store.RefreshTrue:
+		return s.Refresh(indexID)
+	case store.RefreshWaitFor:
+		return s.WaitForRefresh(indexID)
+	}
+	return nil
+}
+
+// enrichDocuments runs the index's enrichment on a batch about to be written
+// and returns the response body. A failed lookup does not fail the write; it
+// is reported as enrichmentError.
+func enrichDocuments(c *fiber.Ctx, indexID string, documents []map[string]any) fiber.Map {
+	response := fiber.Map{"indexed": len(documents)}
+	if err := GetContext(c).Store.EnrichDocuments(c.Context(), indexID, documents); err != nil {
+		response["enrichmentError"] = err.Error()
+	}
+	return response
+}
+
+// deleteCommandSize is the number of document IDs per Raft command of a delete
+const deleteCommandSize = 10000
+
 // DeleteDocuments handles DELETE /indexes/:id/documents
 func DeleteDocuments(c *fiber.Ctx) error {
-	indexID := c.Params("id")
+	indexID := GetContext(c).Store.WriteIndex(c.Params("id"))
 
-	// Parse query parameters
+	// Parse query parameters; ids may be repeated, as ids or ids[], or list
+	// several IDs separated by commas
 	var params struct {
 		Filter string   `query:"filter"`
-		IDs    []string `query:"ids[]"`
+		IDs    []string `query:"ids"`
 	}
 
 	if err := c.QueryParser(&params); err != nil {
 		return errors.BadRequestWithDetails(c, errors.ErrorCodeInvalidParameter, "invalid query parameters", err.Error())
 	}
+	var queryIDs []string
+	for _, id := range params.IDs {
+		queryIDs = append(queryIDs, strings.Split(id, ",")...)
+	}
+	params.IDs = queryIDs
+
+	// Large deletes send {"ids": [...]} or {"filter": "..."} as the body
+	if body := c.Body(); len(body) > 0 {
+		var bodyParams struct {
+			Filter string `json:"filter"`
+			IDs    []any  `json:"ids"`
+		}
+		if err := sonic.Unmarshal(body, &bodyParams); err != nil {
+			return errors.BadRequestWithDetails(c, errors.ErrorCodeInvalidRequestBody, "invalid request body", err.Error())
+		}
+		for _, id := range bodyParams.IDs {
+			switch v := id.(type) {
+			case string:
+				params.IDs = append(params.IDs, v)
+			case float64:
+				params.IDs = append(params.IDs, store.DocumentID(v))
+			default:
+				return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "ids must be strings or numbers")
+			}
+		}
+		if bodyParams.Filter != "" {
+			params.Filter = bodyParams.Filter
+		}
+	}
 
 	filter := params.Filter
 	idsStr := params.IDs
 
-	s := store.GetStore()
-	index, _, err := s.GetIndex(indexID)
+	key, err := idempotencyKey(c)
 	if err != nil {
-		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
+		return errors.BadRequestWithDetails(c, errors.ErrorCodeInvalidParameter, "invalid idempotency key", err.Error())
+	}
+
+	if len(idsStr) == 0 && filter == "" {
+		return errors.BadRequest(c, errors.ErrorCodeMissingParameter, "must provide ids or filter to delete documents")
 	}
 
-	batch := index.NewBatch()
+	ctx := GetContext(c)
+
+	// If Raft is enabled, apply command through consensus
+	if IsRaftEnabled(c) {
+		if !IsLeader(c) {
+			// Forward to leader
+			return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
+		}
+
+		if _, _, err := ctx.Store.GetIndex(indexID); err != nil {
+			return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
+		}
 
-	// If specific IDs are provided
-	if len(idsStr) > 0 {
-		for _, id := range idsStr {
-			batch.Delete(id)
+		if _, applied := appliedIdempotencyKey(c, indexID, key); applied {
+			return c.Status(fiber.StatusNoContent).Send(nil)
 		}
-	} else if filter != "" {
-		// Search with filter and delete matching documents
-		query := bleve.NewQueryStringQuery(filter)
-		searchRequest := bleve.NewSearchRequest(query)
-		searchRequest.Size = 10000 // Limit for safety
 
-		searchResult, err := index.Search(searchRequest)
+		// Resolve the filter on the leader so every node deletes the same documents
+		ids, err := resolveDeleteIDs(ctx.Store, indexID, filter, idsStr)
 		if err != nil {
-			return errors.BadRequestWithDetails(c, errors.ErrorCodeSearchFailed, "failed to search documents", err.Error())
+			return resolveDeleteError(c, err)
+		}
+		if len(ids) == 0 && key == "" {
+			return c.Status(fiber.StatusNoContent).Send(nil)
 		}
 
-		for _, hit := range searchResult.Hits {
-			batch.Delete(hit.ID)
+		// Large deletes are split so no log entry grows without bound. The
+		// last command carries the idempotency key, so a retry after a
+		// partial delete resolves and deletes what is left. It is applied
+		// even without IDs, to record the key.
+		for start := 0; start == 0 || start < len(ids); start += deleteCommandSize {
+			end := min(start+deleteCommandSize, len(ids))
+			payload := raft.DeleteDocumentsPayload{
+				IndexID: indexID,
+				IDs:     ids[start:end],
+			}
+			if end == len(ids) {
+				payload.IdempotencyKey = key
+				payload.Count = len(ids)
+			}
+
+			payloadData, err := sonic.Marshal(payload)
+			if err != nil {
+				return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to marshal payload", err.Error())
+			}
+
+			cmd := raft.Command{
+				Type: raft.CommandDeleteDocuments,
+				Data: payloadData,
+			}
+
+			if err := ctx.RaftNode.Apply(cmd, 30*time.Second); err != nil {
+				return errors.InternalErrorWithDetails(c, errors.ErrorCodeRaftApplyFailed, "failed to delete documents via Raft", err.Error())
+			}
+		}
+
+		return c.Status(fiber.StatusNoContent).Send(nil)
+	}
+
+	// Single-node mode: apply directly
+	if _, _, err := ctx.Store.GetIndex(indexID); err != nil {
+		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
+	}
+
+	record, err := reserveIdempotencyKey(c, indexID, key)
+	if err != nil {
+		return errors.Conflict(c, errors.ErrorCodeIdempotencyKeyInUse, err.Error())
+	}
+	if record != nil {
+		return c.Status(fiber.StatusNoContent).Send(nil)
+	}
+	defer releaseIdempotencyKey(c, indexID, key)
+
+	ids, err := resolveDeleteIDs(ctx.Store, indexID, filter, idsStr)
+	if err != nil {
+		return resolveDeleteError(c, err)
+	}
+
+	if len(ids) > 0 {
+		if err := ctx.Store.DeleteDocumentsInternal(indexID, "", ids); err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeBatchOperationFailed, "failed to delete documents", err.Error())
 		}
-	} else {
-		// Delete all documents - recreate the index
-		return errors.BadRequest(c, errors.ErrorCodeMissingParameter, "must provide ids[] or filter parameter to delete documents")
 	}
 
-	if err := index.Batch(batch); err != nil {
-		return errors.InternalErrorWithDetails(c, errors.ErrorCodeBatchOperationFailed, "failed to delete documents", err.Error())
+	if err := completeIdempotencyKey(c, indexID, key, store.OperationDeleteDocuments, len(ids)); err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to record idempotency key", err.Error())
 	}
 
 	return c.Status(fiber.StatusNoContent).Send(nil)
 }
 
+// primaryKeyError responds to a failed primary key validation
+func primaryKeyError(c *fiber.Ctx, err error) error {
+	if stderrors.Is(err, store.ErrDuplicatePrimaryKey) {
+		return errors.Conflict(c, errors.ErrorCodeDuplicatePrimaryKey, err.Error())
+	}
+	return errors.BadRequestWithDetails(c, errors.ErrorCodeInvalidPrimaryKey, "invalid primary key", err.Error())
+}
+
+// resolveDeleteIDs returns the documents targeted by a delete request: the
+// explicit IDs, or every document matching the filter
+func resolveDeleteIDs(s *store.IndexStore, indexID, filter string, ids []string) ([]string, error) {
+	if len(ids) > 0 {
+		return ids, nil
+	}
+
+	_, config, err := s.GetIndex(indexID)
+	if err != nil {
+		return nil, err
+	}
+	if err := search.ValidateFilter(filter, config.FilterableAttributes); err != nil {
+		return nil, err
+	}
+	return s.MatchingDocumentIDs(indexID, filter)
+}
+
+// resolveDeleteError responds to a failed resolveDeleteIDs
+func resolveDeleteError(c *fiber.Ctx, err error) error {
+	if stderrors.Is(err, search.ErrUnfilterableAttribute) {
+		return errors.BadRequest(c, errors.ErrorCodeUnfilterableAttribute, err.Error())
+	}
+	if stderrors.Is(err, search.ErrInvalidFilter) {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidFilter, err.Error())
+	}
+	return errors.BadRequestWithDetails(c, errors.ErrorCodeSearchFailed, "failed to search documents", err.Error())
+}
+
 // DeleteDocument handles DELETE /indexes/:id/documents/:documentid
 func DeleteDocument(c *fiber.Ctx) error {
-	indexID := c.Params("id")
+	indexID := GetContext(c).Store.WriteIndex(c.Params("id"))
 	documentID := c.Params("documentid")
 
-	s := store.GetStore()
-	index, _, err := s.GetIndex(indexID)
+	key, err := idempotencyKey(c)
 	if err != nil {
+		return errors.BadRequestWithDetails(c, errors.ErrorCodeInvalidParameter, "invalid idempotency key", err.Error())
+	}
+
+	ctx := GetContext(c)
+
+	// If Raft is enabled, apply command through consensus
+	if IsRaftEnabled(c) {
+		if !IsLeader(c) {
+			// Forward to leader
+			return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
+		}
+
+		if _, _, err := ctx.Store.GetIndex(indexID); err != nil {
+			return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
+		}
+
+		if _, applied := appliedIdempotencyKey(c, indexID, key); applied {
+			return c.Status(fiber.StatusNoContent).Send(nil)
+		}
+
+		payloadData, err := sonic.Marshal(raft.DeleteDocumentPayload{
+			IndexID:        indexID,
+			DocumentID:     documentID,
+			IdempotencyKey: key,
+		})
+		if err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to marshal payload", err.Error())
+		}
+
+		cmd := raft.Command{
+			Type: raft.CommandDeleteDocument,
+			Data: payloadData,
+		}
+
+		if err := ctx.RaftNode.Apply(cmd, 10*time.Second); err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeRaftApplyFailed, "failed to delete document via Raft", err.Error())
+		}
+
+		return c.Status(fiber.StatusNoContent).Send(nil)
+	}
+
+	// Single-node mode: apply directly
+	if _, _, err := ctx.Store.GetIndex(indexID); err != nil {
 		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
 	}
 
-	if err := index.Delete(documentID); err != nil {
+	record, err := reserveIdempotencyKey(c, indexID, key)
+	if err != nil {
+		return errors.Conflict(c, errors.ErrorCodeIdempotencyKeyInUse, err.Error())
+	}
+	if record != nil {
+		return c.Status(fiber.StatusNoContent).Send(nil)
+	}
+	defer releaseIdempotencyKey(c, indexID, key)
+
+	if err := ctx.Store.DeleteDocumentInternal(indexID, documentID); err != nil {
 		return errors.InternalErrorWithDetails(c, errors.ErrorCodeDocumentOperationFailed, "failed to delete document", err.Error())
 	}
 
+	if err := completeIdempotencyKey(c, indexID, key, store.OperationDeleteDocument, 1); err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to record idempotency key", err.Error())
+	}
+
 	return c.Status(fiber.StatusNoContent).Send(nil)
 }
 
 // UpdateDocument handles PATCH /indexes/:id/documents/:documentid
 func UpdateDocument(c *fiber.Ctx) error {
-	indexID := c.Params("id")
-	documentID := c.Params("documentid")
+	if diskWritesRefused(c) {
+		return diskFullError(c)
+	}
 
-	s := store.GetStore()
-	index, _, err := s.GetIndex(indexID)
+	indexID := GetContext(c).Store.WriteIndex(c.Params("id"))
+	documentID := utils.CopyString(c.Params("documentid"))
+	defer recordIngest(c, indexID)
+
+	ctx := GetContext(c)
+
+	// Non-leaders forward before touching local state
+	if IsRaftEnabled(c) && !IsLeader(c) {
+		return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
+	}
+
+	index, config, err := ctx.Store.GetIndex(indexID)
 	if err != nil {
 		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
 	}
 
+	hidden, err := hiddenAttributes(c, config)
+	if err != nil {
+		return errors.Forbidden(c, errors.ErrorCodeInsufficientPermissions, err.Error())
+	}
+
 	var updates map[string]any
 	if err := c.BodyParser(&updates); err != nil {
 		return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "invalid request body")
 	}
 
-	// Get existing document
-	query := bleve.NewDocIDQuery([]string{documentID})
-	searchRequest := bleve.NewSearchRequest(query)
-	searchRequest.Fields = []string{"*"}
-	searchResult, err := index.Search(searchRequest)
-	if err != nil || len(searchResult.Hits) == 0 {
+	// Buffered documents must be found too
+	ctx.Store.Refresh(indexID)
+	if _, found := fetchDocument(index, documentID); !found {
 		return errors.NotFound(c, errors.ErrorCodeDocumentNotFound, "document not found")
 	}
 
-	// Merge updates with existing document
-	existingData := make(map[string]any)
-	for fieldName, fieldValue := range searchResult.Hits[0].Fields {
-		existingData[fieldName] = fieldValue
+	// If Raft is enabled, apply command through consensus
+	if IsRaftEnabled(c) {
+		payloadData, err := sonic.Marshal(raft.UpdateDocumentPayload{
+			IndexID:    indexID,
+			DocumentID: documentID,
+			Updates:    updates,
+		})
+		if err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to marshal payload", err.Error())
+		}
+
+		cmd := raft.Command{
+			Type: raft.CommandUpdateDocument,
+			Data: payloadData,
+		}
+
+		if err := ctx.RaftNode.Apply(cmd, 10*time.Second); err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeRaftApplyFailed, "failed to update document via Raft", err.Error())
+		}
+	} else if err := ctx.Store.UpdateDocumentInternal(indexID, documentID, updates); err != nil {
+		// Single-node mode: apply directly
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeDocumentOperationFailed, "failed to update document", err.Error())
 	}
 
-	for key, value := range updates {
-		existingData[key] = value
+	// The leader has applied the update locally, so read back the merged document
+	document, found := fetchDocument(index, documentID)
+	if !found {
+		return errors.NotFound(c, errors.ErrorCodeDocumentNotFound, "document not found")
 	}
+	search.HideAttributes(document, hidden)
 
-	// Re-index the document
-	if err := index.Index(documentID, existingData); err != nil {
-		return errors.InternalErrorWithDetails(c, errors.ErrorCodeDocumentOperationFailed, "failed to update document", err.Error())
+	return c.JSON(document)
+}
+
+// UpdateDocuments handles PATCH /indexes/:id/documents
+// The body is an array of partial documents identified by their primary key.
+func UpdateDocuments(c *fiber.Ctx) error {
+	if diskWritesRefused(c) {
+		return diskFullError(c)
+	}
+
+	indexID := GetContext(c).Store.WriteIndex(c.Params("id"))
+	defer recordIngest(c, indexID)
+
+	ctx := GetContext(c)
+
+	// Non-leaders forward before touching local state
+	if IsRaftEnabled(c) && !IsLeader(c) {
+		return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
+	}
+
+	index, config, err := ctx.Store.GetIndex(indexID)
+	if err != nil {
+		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
 	}
 
-	return c.JSON(existingData)
+	var updates []map[string]any
+	if err := sonic.Unmarshal(c.Body(), &updates); err != nil {
+		return errors.BadRequestWithDetails(c, errors.ErrorCodeInvalidRequestBody, "request body must be an array of documents", err.Error())
+	}
+	if len(updates) == 0 {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "no documents to update")
+	}
+
+	key, err := idempotencyKey(c)
+	if err != nil {
+		return errors.BadRequestWithDetails(c, errors.ErrorCodeInvalidParameter, "invalid idempotency key", err.Error())
+	}
+
+	ids := make([]string, 0, len(updates))
+	for i, update := range updates {
+		id, ok := update[config.PrimaryKey]
+		if !ok || id == nil {
+			return errors.BadRequest(c, errors.ErrorCodeInvalidPrimaryKey, fmt.Sprintf("document %d is missing primary key %s", i, config.PrimaryKey))
+		}
+		ids = append(ids, store.DocumentID(id))
+	}
+	// Several updates of a document are merged in order, with or without an
+	// idempotency key, so duplicate keys are always allowed
+	if err := store.ValidatePrimaryKeys(config.PrimaryKey, updates, true); err != nil {
+		return primaryKeyError(c, err)
+	}
+
+	// Reject the whole batch if any document, buffered ones included, is missing
+	ctx.Store.Refresh(indexID)
+	if missing := missingDocuments(index, ids); len(missing) > 0 {
+		return errors.NotFound(c, errors.ErrorCodeDocumentNotFound, fmt.Sprintf("documents not found: %s", strings.Join(missing, ", ")))
+	}
+
+	// If Raft is enabled, apply command through consensus
+	if IsRaftEnabled(c) {
+		if record, applied := appliedIdempotencyKey(c, indexID, key); applied {
+			return c.JSON(fiber.Map{
+				"updated": record.Count,
+			})
+		}
+
+		payloadData, err := sonic.Marshal(raft.UpdateDocumentsPayload{
+			IndexID:        indexID,
+			Updates:        updates,
+			IdempotencyKey: key,
+		})
+		if err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeSerializationFailed, "failed to serialize payload", err.Error())
+		}
+
+		cmd := raft.Command{
+			Type: raft.CommandUpdateDocuments,
+			Data: payloadData,
+		}
+
+		if err := ctx.RaftNode.Apply(cmd, 30*time.Second); err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeRaftApplyFailed, "failed to update documents via Raft", err.Error())
+		}
+
+		return c.JSON(fiber.Map{
+			"updated": len(updates),
+		})
+	}
+
+	// Single-node mode: apply directly
+	record, err := reserveIdempotencyKey(c, indexID, key)
+	if err != nil {
+		return errors.Conflict(c, errors.ErrorCodeIdempotencyKeyInUse, err.Error())
+	}
+	if record != nil {
+		return c.JSON(fiber.Map{
+			"updated": record.Count,
+		})
+	}
+	defer releaseIdempotencyKey(c, indexID, key)
+
+	if _, err := ctx.Store.UpdateDocumentsInternal(indexID, updates); err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeBatchOperationFailed, "failed to update documents", err.Error())
+	}
+
+	if err := completeIdempotencyKey(c, indexID, key, store.OperationUpdateDocuments, len(updates)); err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to record idempotency key", err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"updated": len(updates),
+	})
+}
+
+// missingDocuments returns the IDs that do not exist in an index
+func missingDocuments(index bleve.Index, ids []string) []string {
+	searchRequest := bleve.NewSearchRequestOptions(bleve.NewDocIDQuery(ids), len(ids), 0, false)
+	searchResult, err := index.Search(searchRequest)
+	if err != nil {
+		return ids
+	}
+
+	found := make(map[string]bool, len(searchResult.Hits))
+	for _, hit := range searchResult.Hits {
+		found[hit.ID] = true
+	}
+
+	missing := []string{}
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+			found[id] = true
+		}
+	}
+	return missing
+}
+
+// fetchDocument returns the stored fields of a document
+func fetchDocument(index bleve.Index, documentID string) (map[string]any, bool) {
+	query := bleve.NewDocIDQuery([]string{documentID})
+	searchRequest := bleve.NewSearchRequest(query)
+	searchRequest.Fields = []string{"*"}
+	searchResult, err := index.Search(searchRequest)
+	if err != nil || len(searchResult.Hits) == 0 {
+		return nil, false
+	}
+
+	document := make(map[string]any, len(searchResult.Hits[0].Fields))
+	for fieldName, fieldValue := range searchResult.Hits[0].Fields {
+		document[fieldName] = fieldValue
+	}
+	return document, true
 }
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"bright/config"
+	"bright/keys"
+	"bright/models"
+	"bright/store"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/bytedance/sonic"
+	"github.com/gofiber/fiber/v2"
+)
+
+// newTestApp serves the document routes of a single-node store
+func newTestApp(t *testing.T) (*fiber.App, *store.IndexStore) {
+	t.Helper()
+
+	s, err := store.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	ctx := &HandlerContext{Store: s, Config: &config.Config{}}
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		SetContext(c, ctx)
+		return c.Next()
+	})
+	app.Delete("/indexes/:id/documents", DeleteDocuments)
+	return app, s
+}
+
+// TestDeleteDocumentsNumericIDs tests that numeric IDs, in the body or the
+// query string, delete the documents whose numeric primary keys they name
+func TestDeleteDocumentsNumericIDs(t *testing.T) {
+	app, s := newTestApp(t)
+
+	if err := s.CreateIndex(&models.IndexConfig{ID: "orders", PrimaryKey: "id"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	documents := []map[string]any{}
+	for _, id := range []float64{7, 1000000, 25000000, 123456789012, 8, 9} {
+		documents = append(documents, map[string]any{"id": id, "total": 10})
+	}
+	if err := s.AddDocuments("orders", "", documents); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	requests := []*struct {
+		target string
+		body   string
+	}{
+		{"/indexes/orders/documents", `{"ids": [1000000, 8]}`},
+		{"/indexes/orders/documents?ids=25000000,404", ""},
+		{"/indexes/orders/documents?ids[]=123456789012&ids[]=9", ""},
+	}
+	for _, r := range requests {
+		req := httptest.NewRequest(fiber.MethodDelete, r.target, strings.NewReader(r.body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request %s failed: %v", r.target, err)
+		}
+		if resp.StatusCode != fiber.StatusNoContent {
+			t.Fatalf("Expected 204 from %s, got %d", r.target, resp.StatusCode)
+		}
+	}
+
+	index, _, err := s.GetIndex("orders")
+	if err != nil {
+		t.Fatalf("Failed to get index: %v", err)
+	}
+	count, err := index.DocCount()
+	if err != nil {
+		t.Fatalf("Failed to count documents: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected only document 7 to be left, got %d documents", count)
+	}
+	if doc, err := index.Document("7"); err != nil || doc == nil {
+		t.Errorf("Expected document 7 to be left: %v", err)
+	}
+}
+
+// TestUpdateDocumentsMergesDuplicates tests that several updates of a
+// document are merged in order, with or without an idempotency key
+func TestUpdateDocumentsMergesDuplicates(t *testing.T) {
+	app, s := newTestApp(t)
+	app.Patch("/indexes/:id/documents", UpdateDocuments)
+
+	if err := s.CreateIndex(&models.IndexConfig{ID: "products", PrimaryKey: "id"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	if err := s.AddDocuments("products", "", []map[string]any{{"id": "1", "name": "Shoe", "stock": 1}}); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	for _, key := range []string{"", "retry-1"} {
+		body := `[{"id": "1", "stock": 2, "color": "red"}, {"id": "1", "stock": 3}]`
+		req := httptest.NewRequest(fiber.MethodPatch, "/indexes/products/documents", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("Expected 200 with idempotency key %q, got %d", key, resp.StatusCode)
+		}
+	}
+
+	index, _, err := s.GetIndex("products")
+	if err != nil {
+		t.Fatalf("Failed to get index: %v", err)
+	}
+	request := bleve.NewSearchRequest(bleve.NewDocIDQuery([]string{"1"}))
+	request.Fields = []string{"*"}
+	result, err := index.Search(request)
+	if err != nil || len(result.Hits) != 1 {
+		t.Fatalf("Failed to load document: %v", err)
+	}
+	fields := result.Hits[0].Fields
+	if fields["stock"] != float64(3) || fields["color"] != "red" || fields["name"] != "Shoe" {
+		t.Errorf("Expected updates merged in order, got %v", fields)
+	}
+}
+
+// TestDeleteDocumentsRaft tests that a delete through Raft spanning several
+// commands removes every document and records its idempotency key once, and
+// that a filter matching nothing still records its key
+func TestDeleteDocumentsRaft(t *testing.T) {
+	dataDir := t.TempDir()
+	s, err := store.New(dataDir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	keyStore, err := keys.NewStore(dataDir)
+	if err != nil {
+		t.Fatalf("Failed to open key store: %v", err)
+	}
+
+	ctx := &HandlerContext{Store: s, Keys: keyStore, Config: &config.Config{}}
+	ctx.RaftNode = newTestRaftNode(t, s, keyStore)
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		SetContext(c, ctx)
+		return c.Next()
+	})
+	app.Delete("/indexes/:id/documents", DeleteDocuments)
+
+	if err := s.CreateIndex(&models.IndexConfig{ID: "orders", PrimaryKey: "id", FilterableAttributes: []string{"status"}}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	if err := s.AddDocuments("orders", "", []map[string]any{
+		{"id": "first", "status": "open"},
+		{"id": "last", "status": "open"},
+	}); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	ids := []string{"first"}
+	for i := 0; i < deleteCommandSize; i++ {
+		ids = append(ids, fmt.Sprintf("missing-%d", i))
+	}
+	ids = append(ids, "last")
+	body, err := sonic.Marshal(map[string]any{"ids": ids})
+	if err != nil {
+		t.Fatalf("Failed to marshal body: %v", err)
+	}
+
+	requests := []*struct {
+		key  string
+		body string
+	}{
+		{"bulk", string(body)},
+		{"nothing", `{"filter": "status = closed"}`},
+	}
+	for _, r := range requests {
+		req := httptest.NewRequest(fiber.MethodDelete, "/indexes/orders/documents", strings.NewReader(r.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(IdempotencyKeyHeader, r.key)
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("Request %s failed: %v", r.key, err)
+		}
+		if resp.StatusCode != fiber.StatusNoContent {
+			t.Fatalf("Expected 204 from %s, got %d", r.key, resp.StatusCode)
+		}
+	}
+
+	index, _, err := s.GetIndex("orders")
+	if err != nil {
+		t.Fatalf("Failed to get index: %v", err)
+	}
+	if count, err := index.DocCount(); err != nil || count != 0 {
+		t.Errorf("Expected every document deleted, got %d (%v)", count, err)
+	}
+
+	for key, count := range map[string]int{"bulk": len(ids), "nothing": 0} {
+		record, ok := s.Idempotency().Lookup("orders", key, time.Now())
+		if !ok {
+			t.Errorf("Expected idempotency key %s to be recorded", key)
+		} else if record.Count != count {
+			t.Errorf("Expected idempotency key %s to count %d documents, got %d", key, count, record.Count)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"bright/errors"
+	"bright/models"
+	"bright/search"
+	stderrors "errors"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// FindDuplicates handles POST /indexes/:id/duplicates
+// Returns clusters of documents that look like duplicates of each other.
+func FindDuplicates(c *fiber.Ctx) error {
+	indexID := c.Params("id")
+
+	var req models.DuplicatesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "invalid request body")
+	}
+
+	s := GetContext(c).Store
+	index, config, err := s.GetIndex(indexID)
+	if err != nil {
+		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
+	}
+
+	hidden, err := hiddenAttributes(c, config)
+	if err != nil {
+		return errors.Forbidden(c, errors.ErrorCodeInsufficientPermissions, err.Error())
+	}
+
+	// Comparing on a hidden attribute would reveal its values
+	for _, field := range append(append([]string{}, req.Fields...), req.FuzzyField) {
+		if slices.Contains(hidden, field) {
+			return errors.Forbidden(c, errors.ErrorCodeInsufficientPermissions, "cannot compare hidden attribute "+field)
+		}
+	}
+
+	if err := search.ValidateFilter(req.Filter, config.FilterableAttributes); err != nil {
+		if stderrors.Is(err, search.ErrInvalidFilter) {
+			return errors.BadRequest(c, errors.ErrorCodeInvalidFilter, err.Error())
+		}
+		return errors.BadRequest(c, errors.ErrorCodeUnfilterableAttribute, err.Error())
+	}
+
+	response, err := search.FindDuplicates(index, req)
+	if err != nil {
+		if stderrors.Is(err, search.ErrInvalidDuplicatesRequest) {
+			return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, err.Error())
+		}
+		return errors.BadRequestWithDetails(c, errors.ErrorCodeSearchFailed, "duplicate detection failed", err.Error())
+	}
+
+	return c.JSON(response)
+}
@@ -0,0 +1,456 @@
+package handlers
+
+import (
+	"bright/elastic"
+	"bright/models"
+	"bright/raft"
+	"bright/rpc"
+	"bright/search"
+	"bright/store"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/bytedance/sonic"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/google/uuid"
+)
+
+// elasticCompatVersion is the Elasticsearch version reported to clients.
+// Shippers such as Filebeat refuse to talk to clusters they do not recognize.
+const elasticCompatVersion = "8.11.0"
+
+// elasticPrimaryKey is the primary key of indexes auto-created by _bulk
+const elasticPrimaryKey = "id"
+
+// elasticError writes an error in Elasticsearch's format
+func elasticError(c *fiber.Ctx, status int, errorType, reason string) error {
+	c.Set("X-Elastic-Product", "Elasticsearch")
+	return c.Status(status).JSON(elastic.NewError(status, errorType, reason))
+}
+
+// ElasticInfo handles GET / for Elasticsearch clients probing the cluster
+func ElasticInfo(c *fiber.Ctx) error {
+	c.Set("X-Elastic-Product", "Elasticsearch")
+	return c.JSON(fiber.Map{
+		"name":         "bright",
+		"cluster_name": "bright",
+		"version": fiber.Map{
+			"number":                              elasticCompatVersion,
+			"build_flavor":                        "default",
+			"minimum_wire_compatibility_version":  "7.17.0",
+			"minimum_index_compatibility_version": "7.0.0",
+		},
+		"tagline": "You Know, for Search",
+	})
+}
+
+// ElasticSearch handles GET/POST /_search and /:index/_search
+// The index may be a comma-separated list of names or wildcard patterns.
+func ElasticSearch(c *fiber.Ctx) error {
+	c.Set("X-Elastic-Product", "Elasticsearch")
+
+	var req elastic.SearchRequest
+	if len(c.Body()) > 0 {
+		if err := sonic.Unmarshal(c.Body(), &req); err != nil {
+			return elasticError(c, fiber.StatusBadRequest, "parsing_exception", err.Error())
+		}
+	}
+
+	// URL parameters override the body, as in Elasticsearch
+	if q := c.Query("q"); q != "" {
+		req.Query = map[string]any{"query_string": map[string]any{"query": q}}
+	}
+	if from := c.QueryInt("from", -1); from >= 0 {
+		req.From = &from
+	}
+	if size := c.QueryInt("size", -1); size >= 0 {
+		req.Size = &size
+	}
+	if sortParam := c.Query("sort"); sortParam != "" {
+		req.Sort = elastic.ParseSortParam(sortParam)
+	}
+
+	s := GetContext(c).Store
+	ids, err := resolveElasticIndexes(s, c.Params("index"))
+	if err != nil {
+		return elasticError(c, fiber.StatusNotFound, "index_not_found_exception", err.Error())
+	}
+
+	showHidden, err := showHiddenAttributes(c)
+	if err != nil {
+		return elasticError(c, fiber.StatusForbidden, "security_exception", err.Error())
+	}
+
+	names := make(map[string]string, len(ids))
+	hidden := make(map[string][]string, len(ids))
+	indexes := make([]bleve.Index, 0, len(ids))
+	for _, id := range ids {
+		index, config, err := s.GetIndex(id)
+		if err != nil {
+			return elasticError(c, fiber.StatusNotFound, "index_not_found_exception", err.Error())
+		}
+		names[index.Name()] = id
+		if !showHidden {
+			hidden[id] = config.HiddenAttributes
+		}
+		indexes = append(indexes, index)
+	}
+
+	var target bleve.Index
+	if len(indexes) == 1 {
+		target = indexes[0]
+	} else {
+		target = bleve.NewIndexAlias(indexes...)
+	}
+
+	response, err := elastic.Search(target, names, req)
+	if err != nil {
+		return elasticError(c, fiber.StatusBadRequest, "search_phase_execution_exception", err.Error())
+	}
+	for _, hit := range response.Hits.Hits {
+		search.HideAttributes(hit.Source, hidden[hit.Index])
+	}
+	s.Stats().RecordSearch(ids...)
+
+	return c.JSON(response)
+}
+
+// resolveElasticIndexes expands a comma-separated index expression
+func resolveElasticIndexes(s *store.IndexStore, expression string) ([]string, error) {
+	if expression == "" {
+		expression = "*"
+	}
+
+	patterns := strings.Split(expression, ",")
+	for i, pattern := range patterns {
+		patterns[i] = strings.TrimSpace(pattern)
+		if patterns[i] == "_all" {
+			patterns[i] = "*"
+		}
+	}
+
+	ids, missing := matchIndexes(s.GetAllConfigs(), s.Aliases().List(), patterns)
+	if missing != "" {
+		return nil, fmt.Errorf("no such index [%s]", missing)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no such index [%s]", expression)
+	}
+	return ids, nil
+}
+
+// ElasticBulk handles POST /_bulk and /:index/_bulk
+// Consecutive writes to the same index are applied as one batch; deletes
+// and updates are applied in request order between them.
+func ElasticBulk(c *fiber.Ctx) error {
+	c.Set("X-Elastic-Product", "Elasticsearch")
+	start := time.Now()
+	ctx := GetContext(c)
+
+	if IsRaftEnabled(c) && !IsLeader(c) {
+		return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
+	}
+
+	actions, err := elastic.ParseBulk(c.Body(), utils.CopyString(c.Params("index")))
+	if err != nil {
+		return elasticError(c, fiber.StatusBadRequest, "illegal_argument_exception", err.Error())
+	}
+	// Bulk requests may write to several indexes, so only the node's total
+	// counts their bytes
+	defer recordIngest(c, "")
+
+	// A bare ?refresh means true
+	refresh := c.Query("refresh")
+	if refresh == "" && c.Context().QueryArgs().Has("refresh") {
+		refresh = store.RefreshTrue
+	}
+	if err := store.ValidateRefresh(refresh); err != nil {
+		return elasticError(c, fiber.StatusBadRequest, "illegal_argument_exception", err.Error())
+	}
+
+	items := make([]elastic.BulkItem, len(actions))
+	for i := 0; i < len(actions); {
+		// Group a run of the same kind of action on the same index
+		end := i + 1
+		for end < len(actions) && actions[end].Index == actions[i].Index && bulkKind(actions[end].Op) == bulkKind(actions[i].Op) {
+			end++
+		}
+
+		run := actions[i:end]
+		switch bulkKind(run[0].Op) {
+		case elastic.OpIndex:
+			applyBulkWrites(c, run, items[i:end])
+		case elastic.OpDelete:
+			applyBulkDeletes(c, run, items[i:end])
+		case elastic.OpUpdate:
+			for j := range run {
+				items[i+j] = applyBulkUpdate(c, run[j])
+			}
+		}
+		i = end
+	}
+
+	refreshed := make(map[string]bool)
+	for _, action := range actions {
+		if !refreshed[action.Index] {
+			refreshed[action.Index] = true
+			if err := applyRefresh(c, action.Index, refresh); err != nil {
+				return elasticError(c, fiber.StatusInternalServerError, "exception", err.Error())
+			}
+		}
+	}
+
+	response := elastic.BulkResponse{
+		Items: make([]map[string]elastic.BulkItem, len(actions)),
+	}
+	for i, item := range items {
+		response.Items[i] = map[string]elastic.BulkItem{actions[i].Op: item}
+		if item.Error != nil {
+			response.Errors = true
+		}
+	}
+	response.Took = time.Since(start).Milliseconds()
+
+	return c.JSON(response)
+}
+
+// bulkKind groups index and create actions, which are applied together
+func bulkKind(op string) string {
+	if op == elastic.OpCreate {
+		return elastic.OpIndex
+	}
+	return op
+}
+
+// failBulkItems marks every item of a run as failed
+func failBulkItems(run []elastic.BulkAction, items []elastic.BulkItem, status int, errorType, reason string) {
+	for i, action := range run {
+		items[i] = elastic.BulkItem{
+			Index:  action.Index,
+			ID:     action.ID,
+			Status: status,
+			Error:  &elastic.ErrorCause{Type: errorType, Reason: reason},
+		}
+	}
+}
+
+// applyBulkWrites indexes a run of index/create actions on one index
+func applyBulkWrites(c *fiber.Ctx, run []elastic.BulkAction, items []elastic.BulkItem) {
+	ctx := GetContext(c)
+	indexID := run[0].Index
+
+	if diskWritesRefused(c) {
+		failBulkItems(run, items, fiber.StatusInsufficientStorage, "cluster_block_exception", diskFullMessage(c))
+		return
+	}
+
+	index, config, err := ctx.Store.GetIndex(indexID)
+	autoCreate := err != nil
+	if autoCreate && !ctx.Config.AutoCreateIndex {
+		failBulkItems(run, items, fiber.StatusNotFound, "index_not_found_exception", fmt.Sprintf("no such index [%s]", indexID))
+		return
+	}
+
+	primaryKey := elasticPrimaryKey
+	if !autoCreate {
+		primaryKey = config.PrimaryKey
+	}
+
+	documents := make([]map[string]any, 0, len(run))
+	seen := make(map[string]bool, len(run))
+	for i, action := range run {
+		// An explicit _id wins over the document's own primary key
+		doc := action.Document
+		id := action.ID
+		if value, ok := doc[primaryKey]; id == "" && ok && value != nil {
+			id = store.DocumentID(value)
+		} else {
+			if id == "" {
+				uuidV7, err := uuid.NewV7()
+				if err != nil {
+					failBulkItems(run[i:i+1], items[i:i+1], fiber.StatusInternalServerError, "exception", "failed to generate id")
+					continue
+				}
+				id = uuidV7.String()
+			}
+			doc[primaryKey] = id
+		}
+
+		items[i] = elastic.BulkItem{Index: indexID, ID: id, Status: fiber.StatusCreated, Result: "created"}
+		exists := seen[id]
+		if !exists && !autoCreate && action.ID != "" {
+			existing, _ := index.Document(id)
+			exists = existing != nil
+		}
+		seen[id] = true
+		if exists {
+			if action.Op == elastic.OpCreate {
+				items[i].Status, items[i].Result = fiber.StatusConflict, ""
+				items[i].Error = &elastic.ErrorCause{
+					Type:   "version_conflict_engine_exception",
+					Reason: fmt.Sprintf("[%s]: version conflict, document already exists", id),
+				}
+				continue
+			}
+			items[i].Status, items[i].Result = fiber.StatusOK, "updated"
+		}
+		documents = append(documents, doc)
+	}
+
+	if len(documents) == 0 {
+		return
+	}
+
+	if err := store.ValidatePrimaryKeys(primaryKey, documents, true); err != nil {
+		failBulkItems(run, items, fiber.StatusBadRequest, "mapper_parsing_exception", err.Error())
+		return
+	}
+
+	// Auto-created indexes have no attachment or enrichment settings yet
+	if !autoCreate {
+		if err := ctx.Store.ExtractAttachments(c.Context(), indexID, documents); err != nil {
+			failBulkItems(run, items, fiber.StatusBadRequest, "document_parsing_exception", err.Error())
+			return
+		}
+		// Enrichment is best effort; bulk items have no place to report it
+		_ = ctx.Store.EnrichDocuments(c.Context(), indexID, documents)
+	}
+
+	if IsRaftEnabled(c) {
+		var cmd raft.Command
+		if autoCreate {
+			cmd, err = newCommand(raft.CommandAutoCreateAndAddDocuments, raft.AutoCreateAndAddDocumentsPayload{
+				IndexID:    indexID,
+				PrimaryKey: primaryKey,
+				Documents:  documents,
+			})
+		} else {
+			cmd, err = newCommand(raft.CommandAddDocuments, raft.AddDocumentsPayload{
+				IndexID:   indexID,
+				Documents: documents,
+			})
+		}
+		if err == nil {
+			err = ctx.RaftNode.Apply(cmd, 30*time.Second)
+		}
+	} else {
+		if autoCreate {
+			err = ctx.Store.CreateIndex(&models.IndexConfig{ID: indexID, PrimaryKey: primaryKey})
+		}
+		if err == nil {
+			err = ctx.Store.AddDocuments(indexID, primaryKey, documents)
+		}
+	}
+
+	if err != nil {
+		failBulkItems(run, items, fiber.StatusInternalServerError, "exception", err.Error())
+	}
+}
+
+// applyBulkDeletes deletes a run of documents from one index
+func applyBulkDeletes(c *fiber.Ctx, run []elastic.BulkAction, items []elastic.BulkItem) {
+	ctx := GetContext(c)
+	indexID := run[0].Index
+
+	index, _, err := ctx.Store.GetIndex(indexID)
+	if err != nil {
+		failBulkItems(run, items, fiber.StatusNotFound, "index_not_found_exception", fmt.Sprintf("no such index [%s]", indexID))
+		return
+	}
+
+	// Buffered documents must be found too
+	ctx.Store.Refresh(indexID)
+
+	ids := make([]string, 0, len(run))
+	for i, action := range run {
+		items[i] = elastic.BulkItem{Index: indexID, ID: action.ID, Status: fiber.StatusNotFound, Result: "not_found"}
+		if existing, _ := index.Document(action.ID); existing != nil {
+			items[i].Status, items[i].Result = fiber.StatusOK, "deleted"
+			ids = append(ids, action.ID)
+		}
+	}
+
+	if len(ids) == 0 {
+		return
+	}
+
+	if IsRaftEnabled(c) {
+		var cmd raft.Command
+		cmd, err = newCommand(raft.CommandDeleteDocuments, raft.DeleteDocumentsPayload{
+			IndexID: indexID,
+			IDs:     ids,
+		})
+		if err == nil {
+			err = ctx.RaftNode.Apply(cmd, 30*time.Second)
+		}
+	} else {
+		err = ctx.Store.DeleteDocumentsInternal(indexID, "", ids)
+	}
+
+	if err != nil {
+		failBulkItems(run, items, fiber.StatusInternalServerError, "exception", err.Error())
+	}
+}
+
+// applyBulkUpdate merges a partial document into an existing one
+func applyBulkUpdate(c *fiber.Ctx, action elastic.BulkAction) elastic.BulkItem {
+	ctx := GetContext(c)
+	item := elastic.BulkItem{Index: action.Index, ID: action.ID, Status: fiber.StatusOK, Result: "updated"}
+	fail := func(status int, errorType, reason string) elastic.BulkItem {
+		item.Status, item.Result = status, ""
+		item.Error = &elastic.ErrorCause{Type: errorType, Reason: reason}
+		return item
+	}
+
+	if diskWritesRefused(c) {
+		return fail(fiber.StatusInsufficientStorage, "cluster_block_exception", diskFullMessage(c))
+	}
+
+	index, _, err := ctx.Store.GetIndex(action.Index)
+	if err != nil {
+		return fail(fiber.StatusNotFound, "index_not_found_exception", fmt.Sprintf("no such index [%s]", action.Index))
+	}
+
+	ctx.Store.Refresh(action.Index)
+	if existing, _ := index.Document(action.ID); existing == nil {
+		if !action.Upsert {
+			return fail(fiber.StatusNotFound, "document_missing_exception", fmt.Sprintf("[%s]: document missing", action.ID))
+		}
+		items := make([]elastic.BulkItem, 1)
+		upsert := action
+		upsert.Op = elastic.OpIndex
+		applyBulkWrites(c, []elastic.BulkAction{upsert}, items)
+		return items[0]
+	}
+
+	if IsRaftEnabled(c) {
+		var cmd raft.Command
+		cmd, err = newCommand(raft.CommandUpdateDocument, raft.UpdateDocumentPayload{
+			IndexID:    action.Index,
+			DocumentID: action.ID,
+			Updates:    action.Document,
+		})
+		if err == nil {
+			err = ctx.RaftNode.Apply(cmd, 10*time.Second)
+		}
+	} else {
+		err = ctx.Store.UpdateDocumentInternal(action.Index, action.ID, action.Document)
+	}
+
+	if err != nil {
+		return fail(fiber.StatusInternalServerError, "exception", err.Error())
+	}
+	return item
+}
+
+// newCommand serializes a Raft command payload
+func newCommand(cmdType raft.CommandType, payload any) (raft.Command, error) {
+	data, err := sonic.Marshal(payload)
+	if err != nil {
+		return raft.Command{}, err
+	}
+	return raft.Command{Type: cmdType, Data: data}, nil
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"bright/errors"
+	"bright/models"
+	"bright/search"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RecordClick handles POST /indexes/:id/clicks
+// Counts a click on a hit of a search served by a ranking variant, the
+// variant being the one tagged on the search response.
+func RecordClick(c *fiber.Ctx) error {
+	var event models.ClickEvent
+	if err := c.BodyParser(&event); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "invalid request body")
+	}
+
+	s := GetContext(c).Store
+	_, config, err := s.GetIndex(c.Params("id"))
+	if err != nil {
+		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
+	}
+	if config.Experiment == nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, "index has no experiment")
+	}
+	if event.Variant == "" {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, "clicks need the variant of their search")
+	}
+	if _, err := search.ChooseVariant(config.Experiment, event.Variant, ""); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, err.Error())
+	}
+
+	s.Analytics().RecordClick(config.ID, event.Variant)
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// GetExperiment handles GET /indexes/:id/experiment
+// Reports the searches, clicks and click-through rate of each variant of the
+// index's ranking experiment, as counted by this node.
+func GetExperiment(c *fiber.Ctx) error {
+	s := GetContext(c).Store
+	_, config, err := s.GetIndex(c.Params("id"))
+	if err != nil {
+		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
+	}
+
+	return c.JSON(s.Analytics().Report(config.ID, config.Experiment))
+}
+
+// ResetExperiment handles DELETE /indexes/:id/experiment/stats
+// Clears the counts of the index's variants on this node.
+func ResetExperiment(c *fiber.Ctx) error {
+	s := GetContext(c).Store
+	_, config, err := s.GetIndex(c.Params("id"))
+	if err != nil {
+		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
+	}
+
+	s.Analytics().Reset(config.ID)
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"bright/errors"
+	"bright/gql"
+	"bright/store"
+
+	"github.com/bytedance/sonic"
+	"github.com/gofiber/fiber/v2"
+)
+
+// GraphQL handles GET and POST /graphql
+func GraphQL(c *fiber.Ctx) error {
+	if !GetContext(c).Store.Settings().Bool(store.SettingFeatureGraphQL) {
+		return errors.Forbidden(c, errors.ErrorCodeFeatureDisabled, "GraphQL is disabled by the cluster settings")
+	}
+
+	var req gql.Request
+
+	if c.Method() == fiber.MethodGet {
+		if err := c.QueryParser(&req); err != nil {
+			return errors.BadRequestWithDetails(c, errors.ErrorCodeInvalidParameter, "invalid query parameters", err.Error())
+		}
+		if variables := c.Query("variables"); variables != "" {
+			if err := sonic.UnmarshalString(variables, &req.Variables); err != nil {
+				return errors.BadRequestWithDetails(c, errors.ErrorCodeInvalidParameter, "invalid variables parameter", err.Error())
+			}
+		}
+	} else if err := c.BodyParser(&req); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "invalid request body")
+	}
+
+	if req.Query == "" {
+		return errors.BadRequest(c, errors.ErrorCodeMissingParameter, "query is required")
+	}
+
+	show, err := showHiddenAttributes(c)
+	if err != nil {
+		return errors.Forbidden(c, errors.ErrorCodeInsufficientPermissions, err.Error())
+	}
+	req.ShowHiddenAttributes = show
+
+	result := GetContext(c).GraphQL.Execute(c.UserContext(), req)
+	return c.JSON(result)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"bright/disk"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -16,6 +17,16 @@ func Health(c *fiber.Ctx) error {
 		"status": "ok",
 	}
 
+	// Past a disk watermark the node serves reads but refuses writes, so it
+	// stays up (200) and reports itself degraded
+	if ctx.Disk != nil && ctx.Disk.Enabled() {
+		status := ctx.Disk.Status()
+		health["disk"] = status
+		if status.Level != disk.LevelOK {
+			health["status"] = "degraded"
+		}
+	}
+
 	if IsRaftEnabled(c) {
 		hasLeader := ctx.RaftNode.LeaderAddr() != ""
 		health["raft"] = fiber.Map{
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"bright/store"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+const (
+	// IdempotencyKeyHeader carries a client-supplied key that makes a write safe to retry
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// IdempotentReplayedHeader is set on responses to writes that were already applied
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// idempotencyKey returns the validated Idempotency-Key header ("" if absent)
+func idempotencyKey(c *fiber.Ctx) (string, error) {
+	key := c.Get(IdempotencyKeyHeader)
+	if key == "" {
+		return "", nil
+	}
+	if err := store.ValidateIdempotencyKey(key); err != nil {
+		return "", err
+	}
+	return utils.CopyString(key), nil
+}
+
+// reserveIdempotencyKey reserves a key for a single-node write. A non-nil
+// record means the write was already applied and must be skipped; an error
+// means the same key is in flight.
+func reserveIdempotencyKey(c *fiber.Ctx, indexID, key string) (*store.IdempotencyRecord, error) {
+	if key == "" {
+		return nil, nil
+	}
+
+	record, err := GetContext(c).Store.Idempotency().Reserve(indexID, key)
+	if err != nil {
+		return nil, err
+	}
+	if record != nil {
+		c.Set(IdempotentReplayedHeader, "true")
+	}
+	return record, nil
+}
+
+// releaseIdempotencyKey frees a reservation after a failed write
+func releaseIdempotencyKey(c *fiber.Ctx, indexID, key string) {
+	if key != "" {
+		GetContext(c).Store.Idempotency().Release(indexID, key)
+	}
+}
+
+// completeIdempotencyKey records a successful single-node write
+func completeIdempotencyKey(c *fiber.Ctx, indexID, key, operation string, count int) error {
+	if key == "" {
+		return nil
+	}
+
+	return GetContext(c).Store.Idempotency().Complete(store.IdempotencyRecord{
+		IndexID:   utils.CopyString(indexID),
+		Key:       key,
+		Operation: operation,
+		Count:     count,
+		AppliedAt: time.Now().UTC(),
+	})
+}
+
+// appliedIdempotencyKey reports whether a Raft write was already applied, so
+// the leader can answer retries without another log entry
+func appliedIdempotencyKey(c *fiber.Ctx, indexID, key string) (*store.IdempotencyRecord, bool) {
+	if key == "" {
+		return nil, false
+	}
+
+	record, ok := GetContext(c).Store.Idempotency().Lookup(indexID, key, time.Now())
+	if ok {
+		c.Set(IdempotentReplayedHeader, "true")
+	}
+	return record, ok
+}
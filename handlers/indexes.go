@@ -7,7 +7,9 @@ import (
 	"bright/rpc"
 	"bright/store"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
+	"reflect"
 	"time"
 
 	"github.com/bytedance/sonic"
@@ -15,6 +17,17 @@ import (
 	"github.com/gofiber/fiber/v2/utils"
 )
 
+// IndexSettings is the API representation of an index configuration with
+// the estimated index growth of its n-gram fields
+type IndexSettings struct {
+	*models.IndexConfig
+	FieldCosts map[string]models.FieldIndexCost `json:"fieldCosts,omitempty"`
+}
+
+func toIndexSettings(config *models.IndexConfig) IndexSettings {
+	return IndexSettings{IndexConfig: config, FieldCosts: store.FieldIndexCosts(config)}
+}
+
 // ListIndexes handles GET /indexes
 func ListIndexes(c *fiber.Ctx) error {
 	limit := c.QueryInt("limit", 20)
@@ -26,7 +39,7 @@ func ListIndexes(c *fiber.Ctx) error {
 		offset = (page - 1) * limit
 	}
 
-	s := store.GetStore()
+	s := GetContext(c).Store
 	items := s.ListIndexes(limit, offset)
 
 	return c.JSON(fiber.Map{
@@ -35,6 +48,7 @@ func ListIndexes(c *fiber.Ctx) error {
 }
 
 // CreateIndex handles POST /indexes
+// With fromArchive=true the body is an index archive installed under id.
 func CreateIndex(c *fiber.Ctx) error {
 	id := c.Query("id")
 	primaryKey := c.Query("primaryKey")
@@ -42,16 +56,28 @@ func CreateIndex(c *fiber.Ctx) error {
 	if id == "" {
 		return errors.BadRequest(c, errors.ErrorCodeMissingParameter, "id parameter is required")
 	}
+	if _, err := GetContext(c).Store.Aliases().Get(id); err == nil {
+		return errors.Conflict(c, errors.ErrorCodeResourceAlreadyExists, fmt.Sprintf("%s is an alias", id))
+	}
+
+	if c.QueryBool("fromArchive") {
+		return importIndexArchive(c, utils.CopyString(id), utils.CopyString(primaryKey))
+	}
+
+	// Parse request body for additional options (the query string sets id and primary key)
+	var config models.IndexConfig
+	c.BodyParser(&config)
 
 	// Make copies of the strings to avoid Fiber buffer reuse issues
-	id = utils.CopyString(id)
-	primaryKey = utils.CopyString(primaryKey)
+	config.ID = utils.CopyString(id)
+	config.PrimaryKeyInferred = false
+	if primaryKey != "" {
+		config.PrimaryKey = utils.CopyString(primaryKey)
+	}
 
-	// Parse request body for additional options
-	var reqBody struct {
-		ExcludeAttributes []string `json:"excludeAttributes"`
+	if err := store.ValidateIndexSettings(&config); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidIndexSettings, err.Error())
 	}
-	c.BodyParser(&reqBody)
 
 	ctx := GetContext(c)
 
@@ -62,12 +88,6 @@ func CreateIndex(c *fiber.Ctx) error {
 			return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
 		}
 
-		// Build config JSON with exclude attributes
-		config := &models.IndexConfig{
-			ID:                id,
-			PrimaryKey:        primaryKey,
-			ExcludeAttributes: reqBody.ExcludeAttributes,
-		}
 		configJSON, _ := sonic.Marshal(config)
 
 		// Apply command via Raft
@@ -80,18 +100,12 @@ func CreateIndex(c *fiber.Ctx) error {
 			return errors.InternalErrorWithDetails(c, errors.ErrorCodeRaftApplyFailed, "failed to create index via Raft", err.Error())
 		}
 
-		return c.Status(fiber.StatusCreated).JSON(config)
+		return c.Status(fiber.StatusCreated).JSON(toIndexSettings(&config))
 	}
 
 	// Single-node mode: apply directly
-	config := &models.IndexConfig{
-		ID:                id,
-		PrimaryKey:        primaryKey,
-		ExcludeAttributes: reqBody.ExcludeAttributes,
-	}
-
-	s := store.GetStore()
-	if err := s.CreateIndex(config); err != nil {
+	s := GetContext(c).Store
+	if err := s.CreateIndex(&config); err != nil {
 		// Check if it's a duplicate index error
 		if err.Error() == fmt.Sprintf("index %s already exists", id) {
 			return errors.Conflict(c, errors.ErrorCodeResourceAlreadyExists, err.Error())
@@ -99,20 +113,20 @@ func CreateIndex(c *fiber.Ctx) error {
 		return errors.InternalErrorWithDetails(c, errors.ErrorCodeIndexOperationFailed, "failed to create index", err.Error())
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(config)
+	return c.Status(fiber.StatusCreated).JSON(toIndexSettings(&config))
 }
 
 // GetIndex handles GET /indexes/:id
 func GetIndex(c *fiber.Ctx) error {
 	id := c.Params("id")
 
-	s := store.GetStore()
+	s := GetContext(c).Store
 	_, config, err := s.GetIndex(id)
 	if err != nil {
 		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
 	}
 
-	return c.JSON(config)
+	return c.JSON(toIndexSettings(config))
 }
 
 // DeleteIndex handles DELETE /indexes/:id
@@ -142,7 +156,7 @@ func DeleteIndex(c *fiber.Ctx) error {
 	}
 
 	// Single-node mode: apply directly
-	s := store.GetStore()
+	s := GetContext(c).Store
 	if err := s.DeleteIndex(id); err != nil {
 		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
 	}
@@ -152,7 +166,7 @@ func DeleteIndex(c *fiber.Ctx) error {
 
 // UpdateIndex handles PATCH /indexes/:id
 func UpdateIndex(c *fiber.Ctx) error {
-	id := c.Params("id")
+	id := utils.CopyString(c.Params("id"))
 
 	var config models.IndexConfig
 	if err := c.BodyParser(&config); err != nil {
@@ -161,6 +175,16 @@ func UpdateIndex(c *fiber.Ctx) error {
 
 	ctx := GetContext(c)
 
+	if _, current, err := ctx.Store.GetIndex(id); err == nil {
+		if err := keepFixedSettings(&config, current); err != nil {
+			return errors.BadRequest(c, errors.ErrorCodeInvalidIndexSettings, err.Error())
+		}
+	}
+
+	if err := store.ValidateIndexSettings(&config); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidIndexSettings, err.Error())
+	}
+
 	// If Raft is enabled, apply command through consensus
 	if IsRaftEnabled(c) {
 		if !IsLeader(c) {
@@ -186,10 +210,68 @@ func UpdateIndex(c *fiber.Ctx) error {
 	}
 
 	// Single-node mode: apply directly
-	s := store.GetStore()
+	s := GetContext(c).Store
 	if err := s.UpdateIndex(id, &config); err != nil {
 		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
 	}
 
 	return c.JSON(config)
 }
+
+// keepFixedSettings fills the settings fixed when an index is created (field
+// analysis, geo attributes, vector fields and the index type) that config
+// leaves unset from the current config, and rejects changes to them, which
+// take a reindex
+func keepFixedSettings(config, current *models.IndexConfig) error {
+	if config.Fields == nil {
+		config.Fields = current.Fields
+	} else if !reflect.DeepEqual(config.Fields, current.Fields) {
+		return stderrors.New("fields cannot be changed after the index is created, use POST /indexes/:id/reindex")
+	}
+	if config.TextAnalysis == nil {
+		config.TextAnalysis = current.TextAnalysis
+	} else if !reflect.DeepEqual(config.TextAnalysis, current.TextAnalysis) {
+		return stderrors.New("textAnalysis cannot be changed after the index is created, use POST /indexes/:id/reindex")
+	}
+	if config.Analysis == nil {
+		config.Analysis = current.Analysis
+	} else if !reflect.DeepEqual(config.Analysis, current.Analysis) {
+		return stderrors.New("analysis cannot be changed after the index is created, use POST /indexes/:id/reindex")
+	}
+	if config.Language == nil {
+		config.Language = current.Language
+	} else if !reflect.DeepEqual(config.Language, current.Language) {
+		return stderrors.New("language cannot be changed after the index is created, use POST /indexes/:id/reindex")
+	}
+	if config.GeoAttributes == nil {
+		config.GeoAttributes = current.GeoAttributes
+	} else if !reflect.DeepEqual(config.GeoAttributes, current.GeoAttributes) {
+		return stderrors.New("geoAttributes cannot be changed after the index is created, use POST /indexes/:id/reindex")
+	}
+	if config.Vectors == nil {
+		config.Vectors = current.Vectors
+	} else if !reflect.DeepEqual(config.Vectors, current.Vectors) {
+		return stderrors.New("vectors cannot be changed after the index is created, use POST /indexes/:id/reindex")
+	}
+	if (config.IndexType != "" && config.IndexType != current.IndexType) || (config.KVStore != "" && config.KVStore != current.KVStore) {
+		return stderrors.New("indexType and kvStore cannot be changed after the index is created, use POST /indexes/:id/reindex")
+	}
+	return nil
+}
+
+// RefreshIndex handles POST /indexes/:id/refresh
+// Buffered documents of the index become searchable on this node.
+func RefreshIndex(c *fiber.Ctx) error {
+	indexID := c.Params("id")
+
+	s := GetContext(c).Store
+	if _, _, err := s.GetIndex(indexID); err != nil {
+		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
+	}
+
+	if err := s.Refresh(indexID); err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeBatchOperationFailed, "failed to refresh index", err.Error())
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"bright/errors"
 	"bright/ingresses"
 	"encoding/json"
+	stderrors "errors"
+	"sort"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -12,7 +15,14 @@ type IngressManager interface {
 	Create(indexID string, ingressType string, id string, rawConfig json.RawMessage) (ingresses.Ingress, error)
 	Get(id string) (ingresses.Ingress, error)
 	List(indexID string) []ingresses.Ingress
+	ListAll() []ingresses.Ingress
+	Leading() bool
 	Delete(id string) error
+
+	ListProfiles() []ingresses.ProfileInfo
+	GetProfile(id string) (ingresses.ProfileInfo, error)
+	PutProfile(id, ingressType string, config json.RawMessage) (ingresses.ProfileInfo, error)
+	DeleteProfile(id string) error
 }
 
 // CreateIngressRequest is the request body for creating an ingress
@@ -22,23 +32,46 @@ type CreateIngressRequest struct {
 	Config json.RawMessage `json:"config"`
 }
 
+// ingressManagerUnavailable responds when the node runs without ingresses
+func ingressManagerUnavailable(c *fiber.Ctx) error {
+	return errors.ServiceUnavailable(c, errors.ErrorCodeIngressesUnavailable, "ingress manager not available")
+}
+
+// ingressError responds to a failed ingress manager call
+func ingressError(c *fiber.Ctx, err error) error {
+	switch {
+	case stderrors.Is(err, ingresses.ErrIngressNotFound):
+		return errors.NotFound(c, errors.ErrorCodeIngressNotFound, err.Error())
+	case stderrors.Is(err, ingresses.ErrIngressExists):
+		return errors.Conflict(c, errors.ErrorCodeIngressAlreadyExists, err.Error())
+	case stderrors.Is(err, ingresses.ErrUnknownIngressType):
+		return errors.BadRequest(c, errors.ErrorCodeUnknownIngressType, err.Error())
+	case stderrors.Is(err, ingresses.ErrInvalidConfig):
+		return errors.BadRequest(c, errors.ErrorCodeInvalidIngressConfig, err.Error())
+	case stderrors.Is(err, ingresses.ErrProfileNotFound):
+		return errors.NotFound(c, errors.ErrorCodeProfileNotFound, err.Error())
+	case stderrors.Is(err, ingresses.ErrProfileInUse):
+		return errors.Conflict(c, errors.ErrorCodeProfileInUse, err.Error())
+	case stderrors.Is(err, ingresses.ErrProfilesUnavailable):
+		return errors.ServiceUnavailable(c, errors.ErrorCodeProfilesUnavailable, err.Error())
+	default:
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeIngressOperationFailed, "ingress operation failed", err.Error())
+	}
+}
+
 // ListIngresses returns all ingresses for an index
 // GET /indexes/:id/ingresses
 func ListIngresses(c *fiber.Ctx) error {
 	ctx := GetContext(c)
 	if ctx.IngressManager == nil {
-		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
-			"error": "ingress manager not available",
-		})
+		return ingressManagerUnavailable(c)
 	}
 
 	indexID := c.Params("id")
 
 	// Verify index exists
 	if _, _, err := ctx.Store.GetIndex(indexID); err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
 	}
 
 	ingressList := ctx.IngressManager.List(indexID)
@@ -53,57 +86,83 @@ func ListIngresses(c *fiber.Ctx) error {
 	})
 }
 
+// ListAllIngresses returns the ingresses of every index, ordered by index
+// and ID. The status, type and index query parameters filter them, so
+// failing syncs are found without going through each index.
+// GET /ingresses
+func ListAllIngresses(c *fiber.Ctx) error {
+	ctx := GetContext(c)
+	if ctx.IngressManager == nil {
+		return ingressManagerUnavailable(c)
+	}
+
+	status := c.Query("status")
+	ingressType := c.Query("type")
+	indexID := c.Query("index")
+
+	result := []ingresses.IngressInfo{}
+	for _, ing := range ctx.IngressManager.ListAll() {
+		if status != "" && string(ing.Status()) != status {
+			continue
+		}
+		if ingressType != "" && ing.Type() != ingressType {
+			continue
+		}
+		if indexID != "" && ing.IndexID() != indexID {
+			continue
+		}
+		result = append(result, ingresses.ToInfo(ing))
+	}
+	sort.Slice(result, func(a, b int) bool {
+		if result[a].IndexID != result[b].IndexID {
+			return result[a].IndexID < result[b].IndexID
+		}
+		return result[a].ID < result[b].ID
+	})
+
+	return c.JSON(fiber.Map{
+		"ingresses": result,
+	})
+}
+
 // CreateIngress creates a new ingress for an index
 // POST /indexes/:id/ingresses
 func CreateIngress(c *fiber.Ctx) error {
 	ctx := GetContext(c)
 	if ctx.IngressManager == nil {
-		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
-			"error": "ingress manager not available",
-		})
+		return ingressManagerUnavailable(c)
 	}
 
 	indexID := c.Params("id")
 
 	// Verify index exists
 	if _, _, err := ctx.Store.GetIndex(indexID); err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
 	}
 
 	var req CreateIngressRequest
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid request body",
-		})
+		return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "invalid request body")
 	}
 
 	if req.ID == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "id is required",
-		})
+		return errors.BadRequest(c, errors.ErrorCodeMissingParameter, "id is required")
 	}
 
 	if req.Type == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "type is required",
-		})
+		return errors.BadRequest(c, errors.ErrorCodeMissingParameter, "type is required")
 	}
 
 	ing, err := ctx.IngressManager.Create(indexID, req.Type, req.ID, req.Config)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return ingressError(c, err)
 	}
 
-	// Auto-start the ingress
-	if err := ing.Start(c.Context()); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   "ingress created but failed to start",
-			"details": err.Error(),
-		})
+	// Auto-start the ingress, unless it waits for this node to lead
+	if ctx.IngressManager.Leading() {
+		if err := ing.Start(c.Context()); err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeIngressOperationFailed, "ingress created but failed to start", err.Error())
+		}
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(ingresses.ToInfo(ing))
@@ -114,18 +173,14 @@ func CreateIngress(c *fiber.Ctx) error {
 func GetIngress(c *fiber.Ctx) error {
 	ctx := GetContext(c)
 	if ctx.IngressManager == nil {
-		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
-			"error": "ingress manager not available",
-		})
+		return ingressManagerUnavailable(c)
 	}
 
 	ingressID := c.Params("ingressId")
 
 	ing, err := ctx.IngressManager.Get(ingressID)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return ingressError(c, err)
 	}
 
 	return c.JSON(ingresses.ToInfo(ing))
@@ -136,17 +191,13 @@ func GetIngress(c *fiber.Ctx) error {
 func DeleteIngress(c *fiber.Ctx) error {
 	ctx := GetContext(c)
 	if ctx.IngressManager == nil {
-		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
-			"error": "ingress manager not available",
-		})
+		return ingressManagerUnavailable(c)
 	}
 
 	ingressID := c.Params("ingressId")
 
 	if err := ctx.IngressManager.Delete(ingressID); err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return ingressError(c, err)
 	}
 
 	return c.SendStatus(fiber.StatusNoContent)
@@ -162,50 +213,36 @@ type UpdateIngressRequest struct {
 func UpdateIngress(c *fiber.Ctx) error {
 	ctx := GetContext(c)
 	if ctx.IngressManager == nil {
-		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
-			"error": "ingress manager not available",
-		})
+		return ingressManagerUnavailable(c)
 	}
 
 	ingressID := c.Params("ingressId")
 
 	ing, err := ctx.IngressManager.Get(ingressID)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return ingressError(c, err)
 	}
 
 	var req UpdateIngressRequest
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid request body",
-		})
+		return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "invalid request body")
 	}
 
 	switch req.State {
 	case "resyncing":
 		if err := ing.Resync(); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": err.Error(),
-			})
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeIngressOperationFailed, "failed to resync ingress", err.Error())
 		}
 	case "paused":
 		if err := ing.Pause(); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": err.Error(),
-			})
+			return errors.BadRequest(c, errors.ErrorCodeInvalidIngressState, err.Error())
 		}
 	case "running":
 		if err := ing.Resume(); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": err.Error(),
-			})
+			return errors.BadRequest(c, errors.ErrorCodeInvalidIngressState, err.Error())
 		}
 	default:
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid state, must be one of: resyncing, paused, running",
-		})
+		return errors.BadRequest(c, errors.ErrorCodeInvalidIngressState, "invalid state, must be one of: resyncing, paused, running")
 	}
 
 	return c.JSON(ingresses.ToInfo(ing))
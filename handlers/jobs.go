@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"bright/errors"
+	"bright/jobs"
+	stderrors "errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ListBackgroundJobs handles GET /admin/background-jobs
+// Jobs are local to each node.
+func ListBackgroundJobs(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"items": GetContext(c).Store.Jobs().List(),
+	})
+}
+
+// GetBackgroundJob handles GET /admin/background-jobs/:id
+func GetBackgroundJob(c *fiber.Ctx) error {
+	info, err := GetContext(c).Store.Jobs().Get(c.Params("id"))
+	if err != nil {
+		return errors.NotFound(c, errors.ErrorCodeJobNotFound, err.Error())
+	}
+
+	return c.JSON(info)
+}
+
+// UpdateBackgroundJob handles PATCH /admin/background-jobs/:id
+// Changes the priority, rate limit or pause state of a running job.
+func UpdateBackgroundJob(c *fiber.Ctx) error {
+	var update jobs.Update
+	if err := c.BodyParser(&update); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "invalid request body")
+	}
+
+	info, err := GetContext(c).Store.Jobs().Update(c.Params("id"), update)
+	if err != nil {
+		if stderrors.Is(err, jobs.ErrNotFound) {
+			return errors.NotFound(c, errors.ErrorCodeJobNotFound, err.Error())
+		}
+		return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, err.Error())
+	}
+
+	return c.JSON(info)
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"bright/errors"
+	"bright/keys"
+	"bright/raft"
+	"bright/rpc"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gofiber/fiber/v2"
+)
+
+// KeyInfo is the API representation of a key (without its hash)
+type KeyInfo struct {
+	ID        string       `json:"id"`
+	Name      string       `json:"name,omitempty"`
+	Prefix    string       `json:"prefix"`
+	Scopes    []keys.Scope `json:"scopes"`
+	CreatedAt time.Time    `json:"createdAt"`
+	Key       string       `json:"key,omitempty"` // Only returned on creation
+}
+
+func toKeyInfo(key *keys.Key) KeyInfo {
+	return KeyInfo{
+		ID:        key.ID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+	}
+}
+
+// ListKeys handles GET /keys
+func ListKeys(c *fiber.Ctx) error {
+	ctx := GetContext(c)
+
+	items := make([]KeyInfo, 0)
+	for _, key := range ctx.Keys.List() {
+		items = append(items, toKeyInfo(key))
+	}
+
+	return c.JSON(fiber.Map{
+		"items": items,
+	})
+}
+
+// CreateKey handles POST /keys
+func CreateKey(c *fiber.Ctx) error {
+	var req struct {
+		Name   string       `json:"name"`
+		Scopes []keys.Scope `json:"scopes"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "invalid request body")
+	}
+
+	ctx := GetContext(c)
+
+	if IsRaftEnabled(c) && !IsLeader(c) {
+		return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
+	}
+
+	key, secret, err := keys.NewKey(req.Name, req.Scopes)
+	if err != nil {
+		return errors.BadRequestWithDetails(c, errors.ErrorCodeInvalidParameter, "invalid key parameters", err.Error())
+	}
+
+	// If Raft is enabled, apply command through consensus
+	if IsRaftEnabled(c) {
+		keyJSON, err := sonic.Marshal(key)
+		if err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeSerializationFailed, "failed to serialize key", err.Error())
+		}
+
+		cmd := raft.Command{
+			Type: raft.CommandCreateKey,
+			Data: json.RawMessage(keyJSON),
+		}
+
+		if err := ctx.RaftNode.Apply(cmd, 10*time.Second); err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeRaftApplyFailed, "failed to create key via Raft", err.Error())
+		}
+	} else if err := ctx.Keys.Add(key); err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to create key", err.Error())
+	}
+
+	info := toKeyInfo(key)
+	info.Key = secret
+
+	return c.Status(fiber.StatusCreated).JSON(info)
+}
+
+// RevokeKey handles DELETE /keys/:id
+func RevokeKey(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	ctx := GetContext(c)
+
+	// If Raft is enabled, apply command through consensus
+	if IsRaftEnabled(c) {
+		if !IsLeader(c) {
+			return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
+		}
+
+		// The FSM cannot report a missing key back as such, so check here
+		if _, ok := ctx.Keys.Get(id); !ok {
+			return errors.NotFound(c, errors.ErrorCodeKeyNotFound, fmt.Sprintf("key %s not found", id))
+		}
+
+		payloadData, err := sonic.Marshal(raft.RevokeKeyPayload{ID: id})
+		if err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeSerializationFailed, "failed to serialize payload", err.Error())
+		}
+
+		cmd := raft.Command{
+			Type: raft.CommandRevokeKey,
+			Data: json.RawMessage(payloadData),
+		}
+
+		if err := ctx.RaftNode.Apply(cmd, 10*time.Second); err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeRaftApplyFailed, "failed to revoke key via Raft", err.Error())
+		}
+
+		return c.Status(fiber.StatusNoContent).Send(nil)
+	}
+
+	if err := ctx.Keys.Revoke(id); err != nil {
+		return errors.NotFound(c, errors.ErrorCodeKeyNotFound, err.Error())
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bright/config"
+	"bright/keys"
+	"bright/raft"
+	"bright/store"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// newTestRaftNode bootstraps a single-node cluster over the given stores and
+// waits until it leads
+func newTestRaftNode(t *testing.T, s *store.IndexStore, keyStore *keys.Store) *raft.RaftNode {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve a port: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	node, err := raft.NewRaftNode(&raft.RaftConfig{
+		NodeID:    "node-0",
+		RaftDir:   filepath.Join(t.TempDir(), "raft"),
+		RaftBind:  addr,
+		Bootstrap: true,
+	}, s, keyStore, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to start raft: %v", err)
+	}
+	t.Cleanup(func() { node.Shutdown() })
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !node.IsLeader() {
+		if time.Now().After(deadline) {
+			t.Fatal("Raft node did not become leader")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return node
+}
+
+// TestRevokeKeyNotFound tests that revoking an unknown key answers 404 with
+// and without Raft, and that a known key is still revoked through Raft
+func TestRevokeKeyNotFound(t *testing.T) {
+	for _, withRaft := range []bool{false, true} {
+		dataDir := t.TempDir()
+		s, err := store.New(dataDir)
+		if err != nil {
+			t.Fatalf("Failed to open store: %v", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		keyStore, err := keys.NewStore(dataDir)
+		if err != nil {
+			t.Fatalf("Failed to open key store: %v", err)
+		}
+		key, _, err := keys.NewKey("search", []keys.Scope{keys.ScopeRead})
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		if err := keyStore.Add(key); err != nil {
+			t.Fatalf("Failed to add key: %v", err)
+		}
+
+		ctx := &HandlerContext{Store: s, Keys: keyStore, Config: &config.Config{}}
+		if withRaft {
+			ctx.RaftNode = newTestRaftNode(t, s, keyStore)
+		}
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			SetContext(c, ctx)
+			return c.Next()
+		})
+		app.Delete("/keys/:id", RevokeKey)
+
+		for _, tc := range []struct {
+			id     string
+			status int
+		}{
+			{"missing", fiber.StatusNotFound},
+			{key.ID, fiber.StatusNoContent},
+			{key.ID, fiber.StatusNotFound},
+		} {
+			resp, err := app.Test(httptest.NewRequest("DELETE", "/keys/"+tc.id, nil), -1)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			if resp.StatusCode != tc.status {
+				t.Errorf("raft=%v: DELETE /keys/%s answered %d, want %d", withRaft, tc.id, resp.StatusCode, tc.status)
+			}
+		}
+		if _, ok := keyStore.Get(key.ID); ok {
+			t.Errorf("raft=%v: key was not revoked", withRaft)
+		}
+	}
+}
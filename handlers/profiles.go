@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"bright/errors"
+	"encoding/json"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// PutProfileRequest is the request body for creating or replacing a
+// connection profile
+type PutProfileRequest struct {
+	Type   string          `json:"type"`
+	Config json.RawMessage `json:"config"`
+}
+
+// ListProfiles returns the connection profiles of ingresses
+// GET /ingress-profiles
+func ListProfiles(c *fiber.Ctx) error {
+	ctx := GetContext(c)
+	if ctx.IngressManager == nil {
+		return ingressManagerUnavailable(c)
+	}
+
+	return c.JSON(fiber.Map{
+		"profiles": ctx.IngressManager.ListProfiles(),
+	})
+}
+
+// GetProfile returns a connection profile, without its values
+// GET /ingress-profiles/:id
+func GetProfile(c *fiber.Ctx) error {
+	ctx := GetContext(c)
+	if ctx.IngressManager == nil {
+		return ingressManagerUnavailable(c)
+	}
+
+	profile, err := ctx.IngressManager.GetProfile(c.Params("id"))
+	if err != nil {
+		return ingressError(c, err)
+	}
+
+	return c.JSON(profile)
+}
+
+// PutProfile creates or replaces a connection profile, restarting the
+// ingresses using it
+// PUT /ingress-profiles/:id
+func PutProfile(c *fiber.Ctx) error {
+	ctx := GetContext(c)
+	if ctx.IngressManager == nil {
+		return ingressManagerUnavailable(c)
+	}
+
+	var req PutProfileRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "invalid request body")
+	}
+	if req.Type == "" {
+		return errors.BadRequest(c, errors.ErrorCodeMissingParameter, "type is required")
+	}
+
+	profile, err := ctx.IngressManager.PutProfile(utils.CopyString(c.Params("id")), req.Type, req.Config)
+	if err != nil {
+		return ingressError(c, err)
+	}
+
+	return c.JSON(profile)
+}
+
+// DeleteProfile removes a connection profile no ingress uses
+// DELETE /ingress-profiles/:id
+func DeleteProfile(c *fiber.Ctx) error {
+	ctx := GetContext(c)
+	if ctx.IngressManager == nil {
+		return ingressManagerUnavailable(c)
+	}
+
+	if err := ctx.IngressManager.DeleteProfile(c.Params("id")); err != nil {
+		return ingressError(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"bright/errors"
+	"bright/models"
+	"bright/raft"
+	"bright/rpc"
+	"bright/search"
+	"bright/store"
+	"encoding/json"
+	stderrors "errors"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// ListQueries handles GET /indexes/:id/queries
+func ListQueries(c *fiber.Ctx) error {
+	indexID := c.Params("id")
+
+	s := GetContext(c).Store
+	if _, _, err := s.GetIndex(indexID); err != nil {
+		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"items": s.Queries().List(indexID),
+	})
+}
+
+// GetQuery handles GET /indexes/:id/queries/:queryId
+func GetQuery(c *fiber.Ctx) error {
+	query, err := GetContext(c).Store.Queries().Get(c.Params("id"), c.Params("queryId"))
+	if err != nil {
+		return errors.NotFound(c, errors.ErrorCodeQueryNotFound, err.Error())
+	}
+
+	return c.JSON(query)
+}
+
+// PutQuery handles PUT /indexes/:id/queries/:queryId
+// Creates or replaces a stored query.
+func PutQuery(c *fiber.Ctx) error {
+	indexID := c.Params("id")
+
+	var query models.StoredQuery
+	if err := c.BodyParser(&query); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "invalid request body")
+	}
+
+	// Make copies of the strings to avoid Fiber buffer reuse issues
+	query.IndexID = utils.CopyString(indexID)
+	query.ID = utils.CopyString(c.Params("queryId"))
+	query.CreatedAt = time.Now().UTC()
+
+	ctx := GetContext(c)
+	_, config, err := ctx.Store.GetIndex(indexID)
+	if err != nil {
+		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
+	}
+
+	if err := search.ValidateStoredQuery(&query, config); err != nil {
+		if stderrors.Is(err, search.ErrUnfilterableAttribute) {
+			return errors.BadRequest(c, errors.ErrorCodeUnfilterableAttribute, err.Error())
+		}
+		return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, err.Error())
+	}
+
+	// A replaced query keeps its creation time
+	if existing, err := ctx.Store.Queries().Get(indexID, query.ID); err == nil {
+		query.CreatedAt = existing.CreatedAt
+	}
+
+	// If Raft is enabled, apply command through consensus
+	if IsRaftEnabled(c) {
+		if !IsLeader(c) {
+			return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
+		}
+
+		queryJSON, err := sonic.Marshal(query)
+		if err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeSerializationFailed, "failed to serialize query", err.Error())
+		}
+
+		cmd := raft.Command{
+			Type: raft.CommandPutQuery,
+			Data: json.RawMessage(queryJSON),
+		}
+
+		if err := ctx.RaftNode.Apply(cmd, 10*time.Second); err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeRaftApplyFailed, "failed to store query via Raft", err.Error())
+		}
+
+		return c.JSON(query)
+	}
+
+	if err := ctx.Store.Queries().Put(&query); err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to store query", err.Error())
+	}
+
+	return c.JSON(query)
+}
+
+// DeleteQuery handles DELETE /indexes/:id/queries/:queryId
+func DeleteQuery(c *fiber.Ctx) error {
+	indexID := c.Params("id")
+	queryID := c.Params("queryId")
+
+	ctx := GetContext(c)
+
+	// If Raft is enabled, apply command through consensus
+	if IsRaftEnabled(c) {
+		if !IsLeader(c) {
+			return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
+		}
+
+		if _, err := ctx.Store.Queries().Get(indexID, queryID); err != nil {
+			return errors.NotFound(c, errors.ErrorCodeQueryNotFound, err.Error())
+		}
+
+		payloadData, err := sonic.Marshal(raft.DeleteQueryPayload{IndexID: indexID, ID: queryID})
+		if err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeSerializationFailed, "failed to serialize payload", err.Error())
+		}
+
+		cmd := raft.Command{
+			Type: raft.CommandDeleteQuery,
+			Data: json.RawMessage(payloadData),
+		}
+
+		if err := ctx.RaftNode.Apply(cmd, 10*time.Second); err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeRaftApplyFailed, "failed to delete query via Raft", err.Error())
+		}
+
+		return c.Status(fiber.StatusNoContent).Send(nil)
+	}
+
+	if err := ctx.Store.Queries().Delete(indexID, queryID); err != nil {
+		if stderrors.Is(err, store.ErrQueryNotFound) {
+			return errors.NotFound(c, errors.ErrorCodeQueryNotFound, err.Error())
+		}
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to delete query", err.Error())
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// Percolate handles POST /indexes/:id/percolate
+// Returns the stored queries each of the given documents matches. The
+// documents are not indexed.
+func Percolate(c *fiber.Ctx) error {
+	indexID := c.Params("id")
+
+	var req models.PercolateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "invalid request body")
+	}
+	if len(req.Documents) > store.MaxPercolateDocuments {
+		return errors.BadRequest(c, errors.ErrorCodeLimitExceeded, "too many documents to percolate at once")
+	}
+
+	s := GetContext(c).Store
+	if _, _, err := s.GetIndex(indexID); err != nil {
+		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
+	}
+
+	// Queries see documents as they would be indexed; enrichment stays best effort
+	if err := s.ExtractAttachments(c.Context(), indexID, req.Documents); err != nil {
+		return errors.BadRequestWithDetails(c, errors.ErrorCodeInvalidAttachment, "failed to extract attachment", err.Error())
+	}
+	s.EnrichDocuments(c.Context(), indexID, req.Documents)
+
+	results, err := s.Percolate(indexID, req.Documents)
+	if err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeSearchFailed, "percolation failed", err.Error())
+	}
+
+	return c.JSON(models.PercolateResponse{Results: results})
+}
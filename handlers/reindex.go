@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"bright/errors"
+	"bright/models"
+	"bright/raft"
+	"bright/rpc"
+	"bright/store"
+	"encoding/json"
+	stderrors "errors"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// StartReindex handles POST /indexes/:id/reindex
+// The index is rebuilt with the settings of the body in a shadow index while
+// it keeps serving, then swapped with it. Unlike PATCH, fields and language
+// may change.
+func StartReindex(c *fiber.Ctx) error {
+	id := utils.CopyString(c.Params("id"))
+
+	var config models.IndexConfig
+	if err := c.BodyParser(&config); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "invalid request body")
+	}
+
+	ctx := GetContext(c)
+
+	_, current, err := ctx.Store.GetIndex(id)
+	if err != nil {
+		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
+	}
+	if config.PrimaryKey == "" {
+		config.PrimaryKey = current.PrimaryKey
+	} else if config.PrimaryKey != current.PrimaryKey {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidIndexSettings, "primaryKey cannot be changed by a reindex")
+	}
+	config.ID = id
+
+	if err := store.ValidateIndexSettings(&config); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidIndexSettings, err.Error())
+	}
+
+	// If Raft is enabled, apply command through consensus
+	if IsRaftEnabled(c) {
+		if !IsLeader(c) {
+			return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
+		}
+
+		configJSON, err := sonic.Marshal(config)
+		if err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeSerializationFailed, "failed to serialize payload", err.Error())
+		}
+
+		cmd := raft.Command{
+			Type: raft.CommandStartReindex,
+			Data: json.RawMessage(configJSON),
+		}
+
+		if err := ctx.RaftNode.Apply(cmd, 10*time.Second); err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeRaftApplyFailed, "failed to start reindex via Raft", err.Error())
+		}
+	} else if err := ctx.Store.StartReindex(id, &config); err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to start reindex", err.Error())
+	}
+
+	status, err := ctx.Store.Reindex(id)
+	if err != nil {
+		return errors.NotFound(c, errors.ErrorCodeReindexNotFound, err.Error())
+	}
+	return c.Status(fiber.StatusAccepted).JSON(status)
+}
+
+// GetReindex handles GET /indexes/:id/reindex
+// Reports the progress of the last reindex of the index on this node.
+func GetReindex(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	ctx := GetContext(c)
+	if _, _, err := ctx.Store.GetIndex(id); err != nil {
+		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
+	}
+
+	status, err := ctx.Store.Reindex(id)
+	if err != nil {
+		return errors.NotFound(c, errors.ErrorCodeReindexNotFound, err.Error())
+	}
+	return c.JSON(status)
+}
+
+// CancelReindex handles DELETE /indexes/:id/reindex
+// A running reindex is stopped and its shadow index dropped; the index keeps
+// its current settings.
+func CancelReindex(c *fiber.Ctx) error {
+	id := utils.CopyString(c.Params("id"))
+
+	ctx := GetContext(c)
+
+	// If Raft is enabled, apply command through consensus
+	if IsRaftEnabled(c) {
+		if !IsLeader(c) {
+			return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
+		}
+
+		if _, err := ctx.Store.Reindex(id); err != nil {
+			return errors.NotFound(c, errors.ErrorCodeReindexNotFound, err.Error())
+		}
+
+		payloadData, err := sonic.Marshal(raft.CancelReindexPayload{IndexID: id})
+		if err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeSerializationFailed, "failed to serialize payload", err.Error())
+		}
+
+		cmd := raft.Command{
+			Type: raft.CommandCancelReindex,
+			Data: json.RawMessage(payloadData),
+		}
+
+		if err := ctx.RaftNode.Apply(cmd, 10*time.Second); err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeRaftApplyFailed, "failed to cancel reindex via Raft", err.Error())
+		}
+
+		return c.Status(fiber.StatusNoContent).Send(nil)
+	}
+
+	if err := ctx.Store.CancelReindex(id); err != nil {
+		if stderrors.Is(err, store.ErrReindexNotFound) {
+			return errors.NotFound(c, errors.ErrorCodeReindexNotFound, err.Error())
+		}
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to cancel reindex", err.Error())
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
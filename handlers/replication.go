@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"bright/errors"
+	"bright/raft"
+	stderrors "errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetChanges handles GET /cluster/changes
+// Returns the commands this node applied after the log index given, for
+// standby clusters replicating this one.
+func GetChanges(c *fiber.Ctx) error {
+	after, err := strconv.ParseUint(c.Query("after", "0"), 10, 64)
+	if err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, "after must be a log index")
+	}
+	limit := c.QueryInt("limit", raft.DefaultChangesLimit)
+	if limit < 1 || limit > raft.MaxChangesLimit {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, fmt.Sprintf("limit must be between 1 and %d", raft.MaxChangesLimit))
+	}
+
+	feed, err := GetContext(c).RaftNode.Changes(after, limit)
+	if err != nil {
+		if stderrors.Is(err, raft.ErrChangesCompacted) {
+			return errors.Respond(c, fiber.StatusGone, errors.ErrorCodeChangesCompacted, err.Error(), "")
+		}
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeClusterUnavailable, "failed to read changes", err.Error())
+	}
+	return c.JSON(feed)
+}
+
+// GetReplication handles GET /replication
+// Returns the replication state of a standby.
+func GetReplication(c *fiber.Ctx) error {
+	follower := GetContext(c).Replication
+	if follower == nil {
+		return c.JSON(fiber.Map{"state": "disabled"})
+	}
+	return c.JSON(follower.Status())
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"bright/errors"
+	"bright/models"
+	"bright/raft"
+	"bright/rpc"
+	"bright/search"
+	"bright/store"
+	"encoding/json"
+	stderrors "errors"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// ListRules handles GET /indexes/:id/rules
+func ListRules(c *fiber.Ctx) error {
+	indexID := c.Params("id")
+
+	s := GetContext(c).Store
+	if _, _, err := s.GetIndex(indexID); err != nil {
+		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"items": s.Curation().List(indexID),
+	})
+}
+
+// GetRule handles GET /indexes/:id/rules/:ruleId
+func GetRule(c *fiber.Ctx) error {
+	rule, err := GetContext(c).Store.Curation().Get(c.Params("id"), c.Params("ruleId"))
+	if err != nil {
+		return errors.NotFound(c, errors.ErrorCodeRuleNotFound, err.Error())
+	}
+
+	return c.JSON(rule)
+}
+
+// PutRule handles PUT /indexes/:id/rules/:ruleId
+// Creates or replaces a curation rule.
+func PutRule(c *fiber.Ctx) error {
+	indexID := c.Params("id")
+
+	var rule models.CurationRule
+	if err := c.BodyParser(&rule); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "invalid request body")
+	}
+
+	// Make copies of the strings to avoid Fiber buffer reuse issues
+	rule.IndexID = utils.CopyString(indexID)
+	rule.ID = utils.CopyString(c.Params("ruleId"))
+	rule.CreatedAt = time.Now().UTC()
+
+	ctx := GetContext(c)
+	if _, _, err := ctx.Store.GetIndex(indexID); err != nil {
+		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
+	}
+
+	if err := search.ValidateCurationRule(&rule); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, err.Error())
+	}
+
+	// A replaced rule keeps its creation time
+	if existing, err := ctx.Store.Curation().Get(indexID, rule.ID); err == nil {
+		rule.CreatedAt = existing.CreatedAt
+	}
+
+	// If Raft is enabled, apply command through consensus
+	if IsRaftEnabled(c) {
+		if !IsLeader(c) {
+			return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
+		}
+
+		ruleJSON, err := sonic.Marshal(rule)
+		if err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeSerializationFailed, "failed to serialize rule", err.Error())
+		}
+
+		cmd := raft.Command{
+			Type: raft.CommandPutRule,
+			Data: json.RawMessage(ruleJSON),
+		}
+
+		if err := ctx.RaftNode.Apply(cmd, 10*time.Second); err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeRaftApplyFailed, "failed to store rule via Raft", err.Error())
+		}
+
+		return c.JSON(rule)
+	}
+
+	if err := ctx.Store.Curation().Put(&rule); err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to store rule", err.Error())
+	}
+
+	return c.JSON(rule)
+}
+
+// DeleteRule handles DELETE /indexes/:id/rules/:ruleId
+func DeleteRule(c *fiber.Ctx) error {
+	indexID := c.Params("id")
+	ruleID := c.Params("ruleId")
+
+	ctx := GetContext(c)
+
+	// If Raft is enabled, apply command through consensus
+	if IsRaftEnabled(c) {
+		if !IsLeader(c) {
+			return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
+		}
+
+		if _, err := ctx.Store.Curation().Get(indexID, ruleID); err != nil {
+			return errors.NotFound(c, errors.ErrorCodeRuleNotFound, err.Error())
+		}
+
+		payloadData, err := sonic.Marshal(raft.DeleteRulePayload{IndexID: indexID, ID: ruleID})
+		if err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeSerializationFailed, "failed to serialize payload", err.Error())
+		}
+
+		cmd := raft.Command{
+			Type: raft.CommandDeleteRule,
+			Data: json.RawMessage(payloadData),
+		}
+
+		if err := ctx.RaftNode.Apply(cmd, 10*time.Second); err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeRaftApplyFailed, "failed to delete rule via Raft", err.Error())
+		}
+
+		return c.Status(fiber.StatusNoContent).Send(nil)
+	}
+
+	if err := ctx.Store.Curation().Delete(indexID, ruleID); err != nil {
+		if stderrors.Is(err, store.ErrRuleNotFound) {
+			return errors.NotFound(c, errors.ErrorCodeRuleNotFound, err.Error())
+		}
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to delete rule", err.Error())
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
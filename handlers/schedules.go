@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"bright/errors"
+	"bright/models"
+	"bright/raft"
+	"bright/rpc"
+	"bright/schedules"
+	"bright/store"
+	"encoding/json"
+	stderrors "errors"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/google/uuid"
+)
+
+// ScheduleInfo is the API representation of a scheduled search with its run
+// state on this node
+type ScheduleInfo struct {
+	*models.ScheduledSearch
+	Status schedules.Status `json:"status"`
+}
+
+func toScheduleInfo(c *fiber.Ctx, schedule *models.ScheduledSearch) ScheduleInfo {
+	info := ScheduleInfo{ScheduledSearch: schedule}
+	if runner := GetContext(c).Schedules; runner != nil {
+		info.Status = runner.Status(schedule.ID)
+	}
+	return info
+}
+
+// ListSchedules handles GET /schedules
+func ListSchedules(c *fiber.Ctx) error {
+	items := make([]ScheduleInfo, 0)
+	for _, schedule := range GetContext(c).Store.Schedules().List() {
+		items = append(items, toScheduleInfo(c, schedule))
+	}
+
+	return c.JSON(fiber.Map{
+		"items": items,
+	})
+}
+
+// GetSchedule handles GET /schedules/:id
+func GetSchedule(c *fiber.Ctx) error {
+	schedule, err := GetContext(c).Store.Schedules().Get(c.Params("id"))
+	if err != nil {
+		return errors.NotFound(c, errors.ErrorCodeScheduleNotFound, err.Error())
+	}
+
+	return c.JSON(toScheduleInfo(c, schedule))
+}
+
+// CreateSchedule handles POST /schedules
+func CreateSchedule(c *fiber.Ctx) error {
+	var schedule models.ScheduledSearch
+	if err := c.BodyParser(&schedule); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "invalid request body")
+	}
+
+	ctx := GetContext(c)
+	if IsRaftEnabled(c) && !IsLeader(c) {
+		return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return errors.InternalError(c, errors.ErrorCodeUUIDGenerationFailed, "failed to generate UUID")
+	}
+	schedule.ID = id.String()
+	schedule.CreatedAt = time.Now().UTC()
+
+	return putSchedule(c, &schedule, fiber.StatusCreated)
+}
+
+// UpdateSchedule handles PUT /schedules/:id
+// Replaces a scheduled search; its next run is planned again if its timing
+// changed.
+func UpdateSchedule(c *fiber.Ctx) error {
+	var schedule models.ScheduledSearch
+	if err := c.BodyParser(&schedule); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "invalid request body")
+	}
+
+	ctx := GetContext(c)
+	if IsRaftEnabled(c) && !IsLeader(c) {
+		return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
+	}
+
+	existing, err := ctx.Store.Schedules().Get(c.Params("id"))
+	if err != nil {
+		return errors.NotFound(c, errors.ErrorCodeScheduleNotFound, err.Error())
+	}
+	schedule.ID = existing.ID
+	schedule.CreatedAt = existing.CreatedAt
+
+	return putSchedule(c, &schedule, fiber.StatusOK)
+}
+
+// putSchedule validates and stores a scheduled search
+func putSchedule(c *fiber.Ctx, schedule *models.ScheduledSearch, status int) error {
+	ctx := GetContext(c)
+
+	// Make copies of the strings to avoid Fiber buffer reuse issues
+	schedule.Index = utils.CopyString(schedule.Index)
+
+	emailEnabled := ctx.Schedules != nil && ctx.Schedules.EmailEnabled()
+	if err := schedules.Validate(schedule, emailEnabled); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, err.Error())
+	}
+
+	// If Raft is enabled, apply command through consensus
+	if IsRaftEnabled(c) {
+		scheduleJSON, err := sonic.Marshal(schedule)
+		if err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeSerializationFailed, "failed to serialize scheduled search", err.Error())
+		}
+
+		cmd := raft.Command{
+			Type: raft.CommandPutSchedule,
+			Data: json.RawMessage(scheduleJSON),
+		}
+
+		if err := ctx.RaftNode.Apply(cmd, 10*time.Second); err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeRaftApplyFailed, "failed to store scheduled search via Raft", err.Error())
+		}
+	} else if err := ctx.Store.Schedules().Put(schedule); err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to store scheduled search", err.Error())
+	}
+
+	return c.Status(status).JSON(toScheduleInfo(c, schedule))
+}
+
+// RunSchedule handles POST /schedules/:id/run
+// Runs a scheduled search immediately and delivers its hits. Failures are
+// reported in the returned status's lastError.
+func RunSchedule(c *fiber.Ctx) error {
+	ctx := GetContext(c)
+	if IsRaftEnabled(c) && !IsLeader(c) {
+		return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
+	}
+
+	schedule, err := ctx.Store.Schedules().Get(c.Params("id"))
+	if err != nil {
+		return errors.NotFound(c, errors.ErrorCodeScheduleNotFound, err.Error())
+	}
+	if ctx.Schedules == nil {
+		return errors.InternalError(c, errors.ErrorCodeInternalError, "scheduled searches are not running")
+	}
+
+	// Failures are recorded in the schedule's status
+	_ = ctx.Schedules.Run(c.UserContext(), schedule, time.Now().UTC())
+
+	return c.JSON(toScheduleInfo(c, schedule))
+}
+
+// DeleteSchedule handles DELETE /schedules/:id
+func DeleteSchedule(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	ctx := GetContext(c)
+
+	// If Raft is enabled, apply command through consensus
+	if IsRaftEnabled(c) {
+		if !IsLeader(c) {
+			return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
+		}
+
+		if _, err := ctx.Store.Schedules().Get(id); err != nil {
+			return errors.NotFound(c, errors.ErrorCodeScheduleNotFound, err.Error())
+		}
+
+		payloadData, err := sonic.Marshal(raft.DeleteSchedulePayload{ID: id})
+		if err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeSerializationFailed, "failed to serialize payload", err.Error())
+		}
+
+		cmd := raft.Command{
+			Type: raft.CommandDeleteSchedule,
+			Data: json.RawMessage(payloadData),
+		}
+
+		if err := ctx.RaftNode.Apply(cmd, 10*time.Second); err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeRaftApplyFailed, "failed to delete scheduled search via Raft", err.Error())
+		}
+
+		return c.Status(fiber.StatusNoContent).Send(nil)
+	}
+
+	if err := ctx.Store.Schedules().Delete(id); err != nil {
+		if stderrors.Is(err, store.ErrScheduleNotFound) {
+			return errors.NotFound(c, errors.ErrorCodeScheduleNotFound, err.Error())
+		}
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to delete scheduled search", err.Error())
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
@@ -2,33 +2,97 @@ package handlers
 
 import (
 	"bright/errors"
+	"bright/keys"
+	middleware "bright/middlewares"
 	"bright/models"
+	"bright/search"
 	"bright/store"
-	"math"
+	stderrors "errors"
+	"path"
+	"sort"
 	"strings"
 
-	"github.com/blevesearch/bleve/v2"
-	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/bytedance/sonic"
 	"github.com/gofiber/fiber/v2"
 )
 
+// errHiddenAttributesForbidden is returned when a key without the hidden scope
+// asks for hidden attributes
+var errHiddenAttributesForbidden = stderrors.New("api key lacks required scope: " + string(keys.ScopeHidden))
+
+// showHiddenAttributes reports whether the request asked for hidden attributes
+// with ?showHiddenAttributes=true. Only the master key and API keys with the
+// hidden scope may do so.
+func showHiddenAttributes(c *fiber.Ctx) (bool, error) {
+	if !c.QueryBool("showHiddenAttributes") {
+		return false, nil
+	}
+	if key := middleware.APIKey(c); key != nil && !key.Allows(keys.ScopeHidden) {
+		return false, errHiddenAttributesForbidden
+	}
+	return true, nil
+}
+
+// hiddenAttributes returns the attributes of an index to strip from a response
+func hiddenAttributes(c *fiber.Ctx, config *models.IndexConfig) ([]string, error) {
+	show, err := showHiddenAttributes(c)
+	if err != nil || show {
+		return nil, err
+	}
+	return config.HiddenAttributes, nil
+}
+
+// ExplainSearch handles GET /indexes/:id/searches/explain, a search taking
+// its parameters from the query string that always explains its scores
+func ExplainSearch(c *fiber.Ctx) error {
+	c.Request().URI().QueryArgs().Set("explain", "true")
+	return Search(c)
+}
+
 // Search handles POST /indexes/:id/searches
 func Search(c *fiber.Ctx) error {
 	indexID := c.Params("id")
 
 	// Parse query parameters using struct
 	var params struct {
-		Q                    string   `query:"q"`
-		Offset               int      `query:"offset"`
-		Limit                int      `query:"limit"`
-		Page                 int      `query:"page"`
-		Sort                 []string `query:"sort[]"`
-		AttributesToRetrieve []string `query:"attributesToRetrieve[]"`
-		AttributesToExclude  []string `query:"attributesToExclude[]"`
+		Q                    string                         `query:"q"`
+		Offset               int                            `query:"offset"`
+		Limit                int                            `query:"limit"`
+		Page                 int                            `query:"page"`
+		Cursor               string                         `query:"cursor"`
+		GroupBy              string                         `query:"groupBy"`
+		GroupSize            int                            `query:"groupSize"`
+		Sort                 []string                       `query:"sort"`
+		AttributesToRetrieve []string                       `query:"attributesToRetrieve"`
+		AttributesToExclude  []string                       `query:"attributesToExclude"`
+		Filter               string                         `query:"filter"`
+		ExcludeTerms         []string                       `query:"excludeTerms"`
+		InjectID             *bool                          `query:"injectId"`
+		IDAttribute          string                         `query:"idAttribute"`
+		ShowMeta             bool                           `query:"showMeta"`
+		ShowMatches          bool                           `query:"showMatches"`
+		Explain              bool                           `query:"explain"`
+		TimeoutMs            int                            `query:"timeoutMs"`
+		RecencyField         string                         `query:"recencyField"`
+		RecencyHalfLife      string                         `query:"recencyHalfLife"`
+		ScoreScript          string                         `query:"scoreScript"`
+		AroundLatLng         string                         `query:"aroundLatLng"`
+		AroundRadius         string                         `query:"aroundRadius"`
+		InsideBoundingBox    string                         `query:"insideBoundingBox"`
+		GeoField             string                         `query:"geoField"`
+		MatchingStrategy     string                         `query:"matchingStrategy"`
+		UserToken            string                         `query:"userToken"`
+		Variant              string                         `query:"variant"`
+		Pretty               bool                           `query:"pretty"`
+		Lookups              []models.Lookup                `query:"-"`
+		Facets               map[string]models.FacetRequest `query:"-"`
+		Ranges               map[string]models.RangeFilter  `query:"-"`
+		SortOptions          map[string]models.SortOptions  `query:"-"`
+		Vector               *models.VectorQuery            `query:"-"`
+		Indexes              []string                       `query:"-"`
 	}
 
-	// Set defaults
-	params.Limit = 20
+	// Set defaults (the limit defaults in search.Normalize, within the index's maxLimit)
 	params.Page = 1
 
 	if err := c.QueryParser(&params); err != nil {
@@ -51,6 +115,15 @@ func Search(c *fiber.Ctx) error {
 		if bodyParams.Page > 0 {
 			params.Page = bodyParams.Page
 		}
+		if bodyParams.Cursor != "" {
+			params.Cursor = bodyParams.Cursor
+		}
+		if bodyParams.GroupBy != "" {
+			params.GroupBy = bodyParams.GroupBy
+		}
+		if bodyParams.GroupSize != 0 {
+			params.GroupSize = bodyParams.GroupSize
+		}
 		if len(bodyParams.Sort) > 0 {
 			params.Sort = bodyParams.Sort
 		}
@@ -60,121 +133,294 @@ func Search(c *fiber.Ctx) error {
 		if len(bodyParams.AttributesToExclude) > 0 {
 			params.AttributesToExclude = bodyParams.AttributesToExclude
 		}
+		if bodyParams.Filter != "" {
+			params.Filter = bodyParams.Filter
+		}
+		if len(bodyParams.ExcludeTerms) > 0 {
+			params.ExcludeTerms = bodyParams.ExcludeTerms
+		}
+		if bodyParams.InjectID != nil {
+			params.InjectID = bodyParams.InjectID
+		}
+		if bodyParams.IDAttribute != "" {
+			params.IDAttribute = bodyParams.IDAttribute
+		}
+		if bodyParams.ShowMeta {
+			params.ShowMeta = true
+		}
+		if bodyParams.ShowMatches {
+			params.ShowMatches = true
+		}
+		if bodyParams.Explain {
+			params.Explain = true
+		}
+		if bodyParams.TimeoutMs != 0 {
+			params.TimeoutMs = bodyParams.TimeoutMs
+		}
+		if bodyParams.RecencyField != "" {
+			params.RecencyField = bodyParams.RecencyField
+		}
+		if bodyParams.RecencyHalfLife != "" {
+			params.RecencyHalfLife = bodyParams.RecencyHalfLife
+		}
+		if bodyParams.ScoreScript != "" {
+			params.ScoreScript = bodyParams.ScoreScript
+		}
+		if bodyParams.AroundLatLng != "" {
+			params.AroundLatLng = bodyParams.AroundLatLng
+		}
+		if bodyParams.AroundRadius != "" {
+			params.AroundRadius = bodyParams.AroundRadius
+		}
+		if bodyParams.InsideBoundingBox != "" {
+			params.InsideBoundingBox = bodyParams.InsideBoundingBox
+		}
+		if bodyParams.GeoField != "" {
+			params.GeoField = bodyParams.GeoField
+		}
+		if bodyParams.MatchingStrategy != "" {
+			params.MatchingStrategy = bodyParams.MatchingStrategy
+		}
+		if bodyParams.UserToken != "" {
+			params.UserToken = bodyParams.UserToken
+		}
+		if bodyParams.Variant != "" {
+			params.Variant = bodyParams.Variant
+		}
+		// Lookups, facets, ranges, sort options, vectors and indexes are
+		// structured, so they are only read from the body
+		params.Lookups = bodyParams.Lookups
+		params.Facets = bodyParams.Facets
+		params.Ranges = bodyParams.Ranges
+		params.SortOptions = bodyParams.SortOptions
+		params.Vector = bodyParams.Vector
+		params.Indexes = bodyParams.Indexes
 	}
 
-	queryStr := params.Q
-	offset := params.Offset
-	limit := params.Limit
-	sortFields := params.Sort
-	page := params.Page
-	attributesToRetrieve := params.AttributesToRetrieve
-	attributesToExclude := params.AttributesToExclude
-
-	// Validate that both attributesToRetrieve and attributesToExclude are not provided
-	if len(attributesToRetrieve) > 0 && len(attributesToExclude) > 0 {
-		return errors.BadRequest(c, errors.ErrorCodeConflictingParameters, "cannot use both attributesToRetrieve and attributesToExclude at the same time")
-	}
-
-	// Calculate offset from page if page is provided
-	if page > 1 {
-		offset = (page - 1) * limit
+	if err := search.ValidateLookups(params.Lookups); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, err.Error())
 	}
 
-	s := store.GetStore()
-	index, _, err := s.GetIndex(indexID)
+	showHidden, err := showHiddenAttributes(c)
 	if err != nil {
-		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
+		return errors.Forbidden(c, errors.ErrorCodeInsufficientPermissions, err.Error())
 	}
 
-	// Create search query
-	var searchQuery query.Query
-	if queryStr == "" {
-		searchQuery = bleve.NewMatchAllQuery()
-	} else {
-		searchQuery = bleve.NewQueryStringQuery(queryStr)
+	req := models.SearchRequest{
+		Query:                params.Q,
+		Offset:               params.Offset,
+		Limit:                params.Limit,
+		Page:                 params.Page,
+		Cursor:               params.Cursor,
+		GroupBy:              params.GroupBy,
+		GroupSize:            params.GroupSize,
+		Sort:                 params.Sort,
+		SortOptions:          params.SortOptions,
+		AttributesToRetrieve: params.AttributesToRetrieve,
+		AttributesToExclude:  params.AttributesToExclude,
+		Filter:               params.Filter,
+		ExcludeTerms:         params.ExcludeTerms,
+		Facets:               params.Facets,
+		Ranges:               params.Ranges,
+		Vector:               params.Vector,
+		InjectID:             params.InjectID,
+		IDAttribute:          params.IDAttribute,
+		ShowMeta:             params.ShowMeta,
+		ShowMatches:          params.ShowMatches,
+		Explain:              params.Explain,
+		TimeoutMs:            params.TimeoutMs,
+		RecencyField:         params.RecencyField,
+		RecencyHalfLife:      params.RecencyHalfLife,
+		ScoreScript:          params.ScoreScript,
+		AroundLatLng:         params.AroundLatLng,
+		AroundRadius:         params.AroundRadius,
+		InsideBoundingBox:    params.InsideBoundingBox,
+		GeoField:             params.GeoField,
+		MatchingStrategy:     params.MatchingStrategy,
 	}
 
-	searchRequest := bleve.NewSearchRequest(searchQuery)
-	searchRequest.From = offset
-	searchRequest.Size = limit
+	s := GetContext(c).Store
+	if req.Explain && !s.Settings().Bool(store.SettingFeatureExplain) {
+		return errors.Forbidden(c, errors.ErrorCodeFeatureDisabled, "explain is disabled by the cluster settings")
+	}
+	req.DefaultLimit = int(s.Settings().Number(store.SettingSearchDefaultLimit))
+	if req.TimeoutMs == 0 {
+		req.TimeoutMs = int(s.Settings().Number(store.SettingSearchDefaultTimeoutMs))
+	}
 
-	// Optimize field retrieval: only request fields we need
-	if len(attributesToRetrieve) > 0 {
-		// Request only specified fields
-		searchRequest.Fields = attributesToRetrieve
-	} else if len(attributesToExclude) > 0 {
-		// Request all fields (we'll exclude in post-processing)
-		searchRequest.Fields = []string{"*"}
+	var response *models.SearchResponse
+	var searched []string
+	_, aliasErr := s.Aliases().Get(indexID)
+	if len(params.Indexes) > 0 || strings.ContainsAny(indexID, "*?") || aliasErr == nil {
+		// Several indexes are searched together, without their defaults
+		ids, missing := matchIndexes(s.GetAllConfigs(), s.Aliases().List(), append([]string{indexID}, params.Indexes...))
+		if missing != "" {
+			return errors.NotFound(c, errors.ErrorCodeIndexNotFound, "index not found: "+missing)
+		}
+		targets := make([]search.Target, 0, len(ids))
+		for _, id := range ids {
+			index, config, err := s.GetIndex(id)
+			if err != nil {
+				return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
+			}
+			targets = append(targets, search.Target{ID: id, Index: index, Config: config})
+		}
+		if len(targets) == 0 {
+			return errors.NotFound(c, errors.ErrorCodeIndexNotFound, "no index matches "+indexID)
+		}
+		searched = ids
+		response, err = search.ExecuteAcross(targets, req, showHidden)
 	} else {
-		// Default: request all fields
-		searchRequest.Fields = []string{"*"}
-	}
-
-	// Apply sorting if provided
-	if len(sortFields) > 0 {
-		sortOrder := make([]string, 0, len(sortFields))
-		for _, sortField := range sortFields {
-			sortField = strings.TrimSpace(sortField)
-			if sortField != "" {
-				// Check if field has descending order (starts with -)
-				if strings.HasPrefix(sortField, "-") {
-					// Descending order
-					fieldName := strings.TrimPrefix(sortField, "-")
-					sortOrder = append(sortOrder, "-"+fieldName)
-				} else {
-					// Ascending order (default)
-					sortOrder = append(sortOrder, sortField)
-				}
+		index, config, lookupErr := s.GetIndex(indexID)
+		if lookupErr != nil {
+			return errors.NotFound(c, errors.ErrorCodeIndexNotFound, lookupErr.Error())
+		}
+		searched = []string{config.ID}
+
+		req.IndexID = config.ID
+		if !showHidden {
+			req.HiddenAttributes = config.HiddenAttributes
+		}
+		req.SortableAttributes = config.SortableAttributes
+		req.FilterableAttributes = config.FilterableAttributes
+		req.GeoAttributes = config.GeoAttributes
+		req.Vectors = config.Vectors
+		req.MaxLimit = config.MaxLimit
+		req.MaxTotalHits = config.MaxTotalHits
+		req.Synonyms = config.Synonyms
+		req.SearchableAttributes = config.SearchableAttributes
+		if err := search.ApplyMatchingStrategy(&req, search.AsYouTypeFields(config)); err != nil {
+			return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, err.Error())
+		}
+		if config.Experiment != nil {
+			variant, err := search.ChooseVariant(config.Experiment, params.Variant, params.UserToken)
+			if err != nil {
+				return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, err.Error())
 			}
+			search.ApplyVariant(&req, variant)
 		}
+		search.ApplyDefaults(&req, config.SearchDefaults)
+		req.PinnedDocuments, req.HiddenDocuments = search.Curate(s.Curation().List(config.ID), req.Query)
 
-		if len(sortOrder) > 0 {
-			searchRequest.SortBy(sortOrder)
+		// Cursor searches page through the index as it was at their first page
+		if req.Cursor != "" {
+			index, err = s.CursorIndex(config.ID, &req)
 		}
-	} else {
-		// Default sorting by score (relevance)
-		searchRequest.SortBy([]string{"-_score"})
-	}
 
-	// Execute search
-	searchResult, err := index.Search(searchRequest)
+		// Other searches may be answered from the cache until the index is
+		// written to, except those boosted by recency, whose scores change
+		// with time
+		cache := s.SearchCache()
+		var cacheKey string
+		var generation uint64
+		if err == nil && req.Cursor == "" && req.RecencyField == "" && cache.Enabled() {
+			generation = cache.Generation(config.ID)
+			if cacheKey, err = store.SearchCacheKey(&req); err == nil {
+				response, _ = cache.Get(config.ID, cacheKey)
+			}
+		}
+		if err == nil && response == nil {
+			response, err = search.Execute(index, req)
+			if err == nil && cacheKey != "" && !response.TimedOut && response.NextCursor == "" {
+				cache.Put(config.ID, cacheKey, generation, response)
+			}
+		}
+		if err == nil && response.NextCursor == "" && req.PointInTime != "" {
+			s.ClosePointInTime(req.PointInTime)
+		}
+	}
 	if err != nil {
+		if err == search.ErrConflictingAttributes {
+			return errors.BadRequest(c, errors.ErrorCodeConflictingParameters, err.Error())
+		}
+		if stderrors.Is(err, search.ErrUnsortableAttribute) {
+			return errors.BadRequest(c, errors.ErrorCodeUnsortableAttribute, err.Error())
+		}
+		if stderrors.Is(err, search.ErrUnfilterableAttribute) {
+			return errors.BadRequest(c, errors.ErrorCodeUnfilterableAttribute, err.Error())
+		}
+		if stderrors.Is(err, search.ErrInvalidFilter) {
+			return errors.BadRequest(c, errors.ErrorCodeInvalidFilter, err.Error())
+		}
+		if stderrors.Is(err, search.ErrInvalidRecency) || stderrors.Is(err, search.ErrInvalidFacet) || stderrors.Is(err, search.ErrInvalidGeo) ||
+			stderrors.Is(err, search.ErrInvalidVector) || stderrors.Is(err, search.ErrVectorsUnsupported) || stderrors.Is(err, search.ErrInvalidScript) ||
+			stderrors.Is(err, search.ErrInvalidMatchingStrategy) || stderrors.Is(err, search.ErrInvalidSort) ||
+			stderrors.Is(err, search.ErrInvalidCursor) || stderrors.Is(err, search.ErrInvalidGroup) || stderrors.Is(err, search.ErrInvalidTimeout) {
+			return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, err.Error())
+		}
+		if stderrors.Is(err, search.ErrLimitExceeded) {
+			return errors.BadRequest(c, errors.ErrorCodeLimitExceeded, err.Error())
+		}
+		if stderrors.Is(err, search.ErrMaxTotalHitsExceeded) {
+			return errors.BadRequest(c, errors.ErrorCodeMaxTotalHitsExceeded, err.Error())
+		}
 		return errors.BadRequestWithDetails(c, errors.ErrorCodeSearchFailed, "search failed", err.Error())
 	}
+	s.Stats().RecordSearch(searched...)
 
-	// Process results
-	hits := make([]map[string]any, 0, len(searchResult.Hits))
-	for _, hit := range searchResult.Hits {
-		doc := make(map[string]any)
+	if req.Variant != "" {
+		response.Variant = req.Variant
+		s.Analytics().RecordSearch(req.IndexID, req.Variant)
+	}
 
-		// Add all fields from the hit
-		for fieldName, fieldValue := range hit.Fields {
-			doc[fieldName] = fieldValue
+	if err := search.ApplyLookups(response.Hits, params.Lookups, s.GetIndex, showHidden); err != nil {
+		if stderrors.Is(err, search.ErrInvalidLookup) {
+			return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, err.Error())
 		}
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeSearchFailed, "lookup failed", err.Error())
+	}
 
-		// Add the document ID
-		if _, ok := doc["id"]; !ok {
-			doc["id"] = hit.ID
+	if params.Pretty {
+		body, err := sonic.ConfigDefault.MarshalIndent(response, "", "  ")
+		if err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeSerializationFailed, "failed to serialize response", err.Error())
 		}
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		return c.Send(body)
+	}
 
-		// Apply attributesToExclude if specified (only needed when not using attributesToRetrieve)
-		if len(attributesToExclude) > 0 {
-			for _, attr := range attributesToExclude {
-				delete(doc, attr)
-			}
-		}
+	return c.JSON(response)
+}
 
-		hits = append(hits, doc)
+// matchIndexes expands index IDs, alias names and wildcard patterns
+// ("logs-*") into the sorted IDs of the indexes they match. An alias matches
+// all its partitions. A pattern may match none, but an ID must exist;
+// missing is the first one that doesn't.
+func matchIndexes(configs map[string]*models.IndexConfig, aliases []*models.IndexAlias, patterns []string) (ids []string, missing string) {
+	partitions := make(map[string][]string, len(aliases))
+	for _, alias := range aliases {
+		partitions[alias.Name] = alias.Indexes
 	}
 
-	// Calculate total pages
-	totalPages := int(math.Ceil(float64(searchResult.Total) / float64(limit)))
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		if indexes, ok := partitions[pattern]; ok {
+			for _, id := range indexes {
+				if !seen[id] {
+					seen[id] = true
+					ids = append(ids, id)
+				}
+			}
+			continue
+		}
+		if !strings.ContainsAny(pattern, "*?") {
+			if _, ok := configs[pattern]; !ok {
+				return nil, pattern
+			}
+			if !seen[pattern] {
+				seen[pattern] = true
+				ids = append(ids, pattern)
+			}
+			continue
+		}
 
-	response := models.SearchResponse{
-		Hits:       hits,
-		TotalHits:  searchResult.Total,
-		TotalPages: totalPages,
+		for id := range configs {
+			if matched, _ := path.Match(pattern, id); matched && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
 	}
-
-	return c.JSON(response)
+	sort.Strings(ids)
+	return ids, ""
 }
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"bright/errors"
+	"bright/models"
+	"bright/search"
+	stderrors "errors"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SearchAsYouType handles GET and POST /indexes/:id/search-as-you-type
+// Matches the text typed so far against the index's search-as-you-type
+// fields, the last word as a prefix. It skips query parsing, synonyms,
+// search defaults, facets and lookups to answer each keystroke quickly.
+func SearchAsYouType(c *fiber.Ctx) error {
+	var params struct {
+		Q                    string   `query:"q" json:"q"`
+		Limit                int      `query:"limit" json:"limit"`
+		Fields               []string `query:"fields" json:"fields"`
+		Filter               string   `query:"filter" json:"filter"`
+		AttributesToRetrieve []string `query:"attributesToRetrieve" json:"attributesToRetrieve"`
+		ShowMatches          bool     `query:"showMatches" json:"showMatches"`
+	}
+	if err := c.QueryParser(&params); err != nil {
+		return errors.BadRequestWithDetails(c, errors.ErrorCodeInvalidParameter, "invalid query parameters", err.Error())
+	}
+	if c.Method() == fiber.MethodPost && len(c.Body()) > 0 {
+		if err := c.BodyParser(&params); err != nil {
+			return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "invalid request body")
+		}
+	}
+
+	index, config, err := GetContext(c).Store.GetIndex(c.Params("id"))
+	if err != nil {
+		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
+	}
+
+	fields := search.AsYouTypeFields(config)
+	if len(fields) == 0 {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, "index has no searchAsYouType fields")
+	}
+	if len(params.Fields) > 0 {
+		for _, field := range params.Fields {
+			if !slices.Contains(fields, field) {
+				return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, field+" is not a searchAsYouType field")
+			}
+		}
+		fields = params.Fields
+	}
+
+	showHidden, err := showHiddenAttributes(c)
+	if err != nil {
+		return errors.Forbidden(c, errors.ErrorCodeInsufficientPermissions, err.Error())
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = search.DefaultAsYouTypeLimit
+		if config.MaxLimit > 0 {
+			limit = min(limit, config.MaxLimit)
+		}
+	}
+
+	req := models.SearchRequest{
+		Query:                params.Q,
+		Limit:                limit,
+		Filter:               params.Filter,
+		AttributesToRetrieve: params.AttributesToRetrieve,
+		IndexID:              config.ID,
+		FilterableAttributes: config.FilterableAttributes,
+		MaxLimit:             config.MaxLimit,
+		MaxTotalHits:         config.MaxTotalHits,
+		AsYouTypeFields:      fields,
+		ShowMatches:          params.ShowMatches,
+	}
+	if !showHidden {
+		req.HiddenAttributes = config.HiddenAttributes
+	}
+
+	response, err := search.Execute(index, req)
+	if err != nil {
+		if stderrors.Is(err, search.ErrUnfilterableAttribute) {
+			return errors.BadRequest(c, errors.ErrorCodeUnfilterableAttribute, err.Error())
+		}
+		if stderrors.Is(err, search.ErrInvalidFilter) {
+			return errors.BadRequest(c, errors.ErrorCodeInvalidFilter, err.Error())
+		}
+		if stderrors.Is(err, search.ErrLimitExceeded) {
+			return errors.BadRequest(c, errors.ErrorCodeLimitExceeded, err.Error())
+		}
+		return errors.BadRequestWithDetails(c, errors.ErrorCodeSearchFailed, "search failed", err.Error())
+	}
+	GetContext(c).Store.Stats().RecordSearch(config.ID)
+
+	return c.JSON(response)
+}
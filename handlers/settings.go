@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"bright/errors"
+	"bright/models"
+	"bright/raft"
+	"bright/rpc"
+	"bright/search"
+	"bright/store"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// ExportSettings handles GET /indexes/:id/settings
+// Returns the settings of the index and its curation rules as a JSON file.
+func ExportSettings(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	export, err := GetContext(c).Store.ExportSettings(id)
+	if err != nil {
+		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
+	}
+
+	c.Attachment(id + "-settings.json")
+	return c.JSON(export)
+}
+
+// ImportSettings handles PUT /indexes/:id/settings
+// Applies a settings export to the index, creating the index when it
+// doesn't exist. The export's curation rules replace the index's. Settings
+// fixed when the index was created must be left unset or unchanged.
+func ImportSettings(c *fiber.Ctx) error {
+	id := utils.CopyString(c.Params("id"))
+
+	var export models.SettingsExport
+	if err := c.BodyParser(&export); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidRequestBody, "invalid request body")
+	}
+	if export.Settings == nil {
+		return errors.BadRequest(c, errors.ErrorCodeMissingParameter, "settings are required")
+	}
+	if export.Version > models.SettingsExportVersion {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, fmt.Sprintf("unsupported settings version %d", export.Version))
+	}
+
+	ctx := GetContext(c)
+	if IsRaftEnabled(c) && !IsLeader(c) {
+		return rpc.ForwardToLeader(c, ctx.RPCClient, ctx.RaftNode.LeaderAddr())
+	}
+	if _, err := ctx.Store.Aliases().Get(id); err == nil {
+		return errors.Conflict(c, errors.ErrorCodeResourceAlreadyExists, fmt.Sprintf("%s is an alias", id))
+	}
+
+	config := *export.Settings
+	config.ID = id
+	config.PrimaryKeyInferred = false
+	_, current, err := ctx.Store.GetIndex(id)
+	exists := err == nil
+	if exists {
+		if err := keepFixedSettings(&config, current); err != nil {
+			return errors.BadRequest(c, errors.ErrorCodeInvalidIndexSettings, err.Error())
+		}
+	}
+	if err := store.ValidateIndexSettings(&config); err != nil {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidIndexSettings, err.Error())
+	}
+
+	// Rules keep their creation time, and the index's rules missing from
+	// the export are deleted
+	imported := make(map[string]bool, len(export.Rules))
+	now := time.Now().UTC()
+	for _, rule := range export.Rules {
+		if rule == nil || rule.ID == "" {
+			return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, "curation rules need an id")
+		}
+		if imported[rule.ID] {
+			return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, "duplicate curation rule "+rule.ID)
+		}
+		imported[rule.ID] = true
+		rule.IndexID = id
+		if err := search.ValidateCurationRule(rule); err != nil {
+			return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, fmt.Sprintf("rule %s: %s", rule.ID, err))
+		}
+		if existing, err := ctx.Store.Curation().Get(id, rule.ID); err == nil {
+			rule.CreatedAt = existing.CreatedAt
+		} else if rule.CreatedAt.IsZero() {
+			rule.CreatedAt = now
+		}
+	}
+	var stale []string
+	for _, rule := range ctx.Store.Curation().List(id) {
+		if !imported[rule.ID] {
+			stale = append(stale, rule.ID)
+		}
+	}
+
+	// If Raft is enabled, apply commands through consensus
+	if IsRaftEnabled(c) {
+		configJSON, err := sonic.Marshal(config)
+		if err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeSerializationFailed, "failed to serialize settings", err.Error())
+		}
+		commands := []raft.Command{{Type: raft.CommandCreateIndex, Data: json.RawMessage(configJSON)}}
+		if exists {
+			commands[0].Type = raft.CommandUpdateIndex
+		}
+		for _, rule := range export.Rules {
+			ruleJSON, err := sonic.Marshal(rule)
+			if err != nil {
+				return errors.InternalErrorWithDetails(c, errors.ErrorCodeSerializationFailed, "failed to serialize rule", err.Error())
+			}
+			commands = append(commands, raft.Command{Type: raft.CommandPutRule, Data: json.RawMessage(ruleJSON)})
+		}
+		for _, ruleID := range stale {
+			payloadData, err := sonic.Marshal(raft.DeleteRulePayload{IndexID: id, ID: ruleID})
+			if err != nil {
+				return errors.InternalErrorWithDetails(c, errors.ErrorCodeSerializationFailed, "failed to serialize payload", err.Error())
+			}
+			commands = append(commands, raft.Command{Type: raft.CommandDeleteRule, Data: json.RawMessage(payloadData)})
+		}
+
+		for _, cmd := range commands {
+			if err := ctx.RaftNode.Apply(cmd, 10*time.Second); err != nil {
+				return errors.InternalErrorWithDetails(c, errors.ErrorCodeRaftApplyFailed, "failed to import settings via Raft", err.Error())
+			}
+		}
+	} else {
+		// Single-node mode: apply directly
+		if exists {
+			err = ctx.Store.UpdateIndex(id, &config)
+		} else {
+			err = ctx.Store.CreateIndex(&config)
+		}
+		if err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to import settings", err.Error())
+		}
+		for _, rule := range export.Rules {
+			if err := ctx.Store.Curation().Put(rule); err != nil {
+				return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to store rule", err.Error())
+			}
+		}
+		for _, ruleID := range stale {
+			if err := ctx.Store.Curation().Delete(id, ruleID); err != nil {
+				return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to delete rule", err.Error())
+			}
+		}
+	}
+
+	result, err := ctx.Store.ExportSettings(id)
+	if err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to read imported settings", err.Error())
+	}
+	status := fiber.StatusOK
+	if !exists {
+		status = fiber.StatusCreated
+	}
+	return c.Status(status).JSON(result)
+}
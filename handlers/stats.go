@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"time"
+
+	"bright/store"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// NodeStats is what this node did since it started, with the documents its
+// indexes hold, for status pages and capacity checks
+type NodeStats struct {
+	Version       string    `json:"version"`
+	StartedAt     time.Time `json:"startedAt"`
+	UptimeSeconds int64     `json:"uptimeSeconds"`
+	Indexes       int       `json:"indexes"`
+	Documents     uint64    `json:"documents"`
+	store.OperationStats
+	ByIndex map[string]IndexStats `json:"byIndex"`
+}
+
+// IndexStats is the documents of an index with the operations of this node
+// on it
+type IndexStats struct {
+	Documents uint64 `json:"documents"`
+	store.OperationStats
+}
+
+// GetStats handles GET /stats
+func GetStats(c *fiber.Ctx) error {
+	ctx := GetContext(c)
+	s := ctx.Store
+
+	stats := NodeStats{
+		Version:        ctx.Version,
+		StartedAt:      startTime.UTC(),
+		UptimeSeconds:  int64(time.Since(startTime).Seconds()),
+		OperationStats: s.Stats().Total(),
+		ByIndex:        make(map[string]IndexStats),
+	}
+	for id := range s.GetAllConfigs() {
+		index, _, err := s.GetIndex(id)
+		if err != nil {
+			continue
+		}
+		documents, _ := index.DocCount()
+		stats.Indexes++
+		stats.Documents += documents
+		stats.ByIndex[id] = IndexStats{Documents: documents, OperationStats: s.Stats().Index(id)}
+	}
+
+	return c.JSON(stats)
+}
+
+// recordIngest counts the body of a document write among the bytes this
+// node ingested, unless the write failed or was forwarded to the leader.
+// Handlers defer it.
+func recordIngest(c *fiber.Ctx, indexID string) {
+	if c.Response().StatusCode() >= fiber.StatusMultipleChoices || (IsRaftEnabled(c) && !IsLeader(c)) {
+		return
+	}
+	GetContext(c).Store.Stats().RecordIngest(indexID, len(c.Body()))
+}
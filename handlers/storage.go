@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"bright/errors"
+	"bright/raft"
+	"bright/store"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// StorageInfo is the disk usage of an index on this node, with the share of
+// the Raft log its commands take in cluster mode
+type StorageInfo struct {
+	*store.StorageReport
+	RaftLog *raft.LogUsage `json:"raftLog,omitempty"`
+}
+
+// GetIndexStorage handles GET /indexes/:id/storage
+func GetIndexStorage(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	ctx := GetContext(c)
+	report, err := ctx.Store.Storage(id)
+	if err != nil {
+		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
+	}
+
+	info := StorageInfo{StorageReport: report}
+	if IsRaftEnabled(c) {
+		usage, err := ctx.RaftNode.IndexLogUsage(id)
+		if err != nil {
+			return errors.InternalErrorWithDetails(c, errors.ErrorCodeInternalError, "failed to read raft log", err.Error())
+		}
+		info.RaftLog = &usage
+	}
+
+	return c.JSON(info)
+}
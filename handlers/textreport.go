@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"bright/errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetTextReport handles GET /indexes/:id/text-report
+// Samples up to ?sample= documents (1000 by default) and reports their
+// languages, candidate stop words and noisy tokens, with suggested
+// textAnalysis settings. Hidden attributes are left out of the sample
+// unless ?showHiddenAttributes=true.
+func GetTextReport(c *fiber.Ctx) error {
+	sample := c.QueryInt("sample", 0)
+	if sample < 0 {
+		return errors.BadRequest(c, errors.ErrorCodeInvalidParameter, "sample can't be negative")
+	}
+
+	ctx := GetContext(c)
+	_, config, err := ctx.Store.GetIndex(c.Params("id"))
+	if err != nil {
+		return errors.NotFound(c, errors.ErrorCodeIndexNotFound, err.Error())
+	}
+	exclude, err := hiddenAttributes(c, config)
+	if err != nil {
+		return errors.Forbidden(c, errors.ErrorCodeInsufficientPermissions, err.Error())
+	}
+
+	report, err := ctx.Store.TextReport(config.ID, sample, exclude)
+	if err != nil {
+		return errors.InternalErrorWithDetails(c, errors.ErrorCodeIndexOperationFailed, "failed to sample documents", err.Error())
+	}
+	return c.JSON(report)
+}
@@ -1,6 +1,7 @@
 package ingresses
 
 import (
+	"bright/store"
 	"context"
 	"encoding/json"
 	"time"
@@ -26,6 +27,37 @@ type Statistics struct {
 	FullSyncComplete bool      `json:"full_sync_complete"`
 	LastError        string    `json:"last_error,omitempty"`
 	ErrorCount       int       `json:"error_count"`
+
+	// LagSeconds is how far the index trails the source: the newest change
+	// in the source minus the last synced one
+	LagSeconds float64 `json:"lag_seconds"`
+
+	// PendingDeletes is the number of source deletes not yet synced
+	PendingDeletes int64 `json:"pending_deletes"`
+
+	// DeleteBacklog is the number of tracked deletes kept by the source,
+	// synced or not, as of the last cleanup
+	DeleteBacklog int64 `json:"delete_backlog"`
+
+	// LagMeasuredAt is when lag was last measured (zero if never)
+	LagMeasuredAt time.Time `json:"lag_measured_at,omitempty"`
+
+	// SchemaDrift lists the source columns that changed since the last full
+	// sync (nil if none)
+	SchemaDrift *SchemaDrift `json:"schema_drift,omitempty"`
+
+	// Conflicts counts the writes to documents last written by another
+	// ingress, under the index's conflict policy
+	Conflicts store.ConflictStats `json:"conflicts"`
+}
+
+// SchemaDrift describes columns of a source that were added, removed or
+// changed type since the last full sync
+type SchemaDrift struct {
+	Added      []string  `json:"added,omitempty"`
+	Removed    []string  `json:"removed,omitempty"`
+	Changed    []string  `json:"changed,omitempty"`
+	DetectedAt time.Time `json:"detected_at"`
 }
 
 // Ingress represents a data source that syncs to an index
@@ -82,14 +114,15 @@ type IngressInfo struct {
 	Statistics   Statistics           `json:"statistics"`
 }
 
-// ToInfo converts an Ingress to IngressInfo for API responses
+// ToInfo converts an Ingress to IngressInfo for API responses. Credentials
+// are redacted from the config, since read-scoped keys may list ingresses.
 func ToInfo(i Ingress) IngressInfo {
 	return IngressInfo{
 		ID:      i.ID(),
 		IndexID: i.IndexID(),
 		Type:    i.Type(),
 		Status:  i.Status(),
-		Config:  i.Config(),
+		Config:  RedactConfig(i.Config()),
 		Statistics:   i.Statistics(),
 	}
 }
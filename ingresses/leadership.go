@@ -0,0 +1,64 @@
+package ingresses
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// WatchLeadership makes ingresses run on the Raft leader only. leaderCh
+// delivers true when this node gains leadership and false when it loses
+// it. Ingresses are stopped on losing it, which saves their sync state in
+// the source, and started from that state on gaining it, so syncing moves
+// to a new leader as soon as it is elected. Watching ends when ctx is done
+// or leaderCh is closed.
+func (m *Manager) WatchLeadership(ctx context.Context, leaderCh <-chan bool) {
+	m.mu.Lock()
+	m.leaderOnly = true
+	m.mu.Unlock()
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case leader, ok := <-leaderCh:
+				if !ok {
+					return
+				}
+				m.setLeading(ctx, leader)
+			}
+		}
+	}()
+}
+
+// Leading reports whether ingresses may run on this node
+func (m *Manager) Leading() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return !m.leaderOnly || m.leading
+}
+
+// setLeading starts or stops the ingresses on a leadership change
+func (m *Manager) setLeading(ctx context.Context, leader bool) {
+	m.mu.Lock()
+	changed := m.leading != leader
+	m.leading = leader
+	m.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	if leader {
+		m.logger.Info("Gained leadership, starting ingresses")
+		if err := m.StartAll(ctx); err != nil {
+			m.logger.Warn("Some ingresses failed to start", zap.Error(err))
+		}
+		return
+	}
+
+	m.logger.Info("Lost leadership, stopping ingresses")
+	if err := m.StopAll(); err != nil {
+		m.logger.Warn("Some ingresses failed to stop", zap.Error(err))
+	}
+}
@@ -0,0 +1,85 @@
+package ingresses
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fakeIngress records whether it runs
+type fakeIngress struct {
+	id     string
+	mu     sync.Mutex
+	status Status
+}
+
+func (f *fakeIngress) ID() string              { return f.id }
+func (f *fakeIngress) IndexID() string         { return "items" }
+func (f *fakeIngress) Type() string            { return "fake" }
+func (f *fakeIngress) Pause() error            { return nil }
+func (f *fakeIngress) Resume() error           { return nil }
+func (f *fakeIngress) Resync() error           { return nil }
+func (f *fakeIngress) Statistics() Statistics  { return Statistics{} }
+func (f *fakeIngress) Config() json.RawMessage { return nil }
+
+func (f *fakeIngress) Status() Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.status
+}
+
+func (f *fakeIngress) Start(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status = StatusRunning
+	return nil
+}
+
+func (f *fakeIngress) Stop() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status = StatusStopped
+	return nil
+}
+
+func TestWatchLeadership(t *testing.T) {
+	m := NewManager(t.TempDir(), nil, nil, zap.NewNop())
+	ingress := &fakeIngress{id: "orders", status: StatusStopped}
+	m.ingresses[ingress.id] = ingress
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	leaderCh := make(chan bool, 1)
+	m.WatchLeadership(ctx, leaderCh)
+
+	if err := m.StartAll(ctx); err != nil || ingress.Status() != StatusStopped || m.Leading() {
+		t.Fatalf("Expected ingresses to wait for leadership, got %s (%v)", ingress.Status(), err)
+	}
+
+	waitFor := func(want Status) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for ingress.Status() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected the ingress to be %s, got %s", want, ingress.Status())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	leaderCh <- true
+	waitFor(StatusRunning)
+	if !m.Leading() {
+		t.Error("Expected the manager to lead")
+	}
+
+	leaderCh <- false
+	waitFor(StatusStopped)
+
+	leaderCh <- true
+	waitFor(StatusRunning)
+}
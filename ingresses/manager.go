@@ -1,10 +1,13 @@
 package ingresses
 
 import (
+	"bright/persist"
 	"bright/raft"
 	"bright/store"
 	"context"
+	"crypto/cipher"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -14,6 +17,14 @@ import (
 	"go.uber.org/zap"
 )
 
+// Errors returned by the manager, for callers that map them to responses
+var (
+	ErrIngressNotFound    = errors.New("ingress not found")
+	ErrIngressExists      = errors.New("ingress already exists")
+	ErrUnknownIngressType = errors.New("unknown ingress type")
+	ErrInvalidConfig      = errors.New("invalid ingress config")
+)
+
 // Factory is a function that creates an Ingress from configuration
 type Factory func(cfg Config, store *store.IndexStore, raftNode *raft.RaftNode, logger *zap.Logger) (Ingress, error)
 
@@ -26,7 +37,18 @@ type Manager struct {
 	raftNode   *raft.RaftNode
 	logger     *zap.Logger
 	configFile string
-	mu         sync.RWMutex
+
+	// Connection profiles, encrypted at rest with profileCipher
+	profiles      map[string]Profile
+	profileFile   string
+	profileCipher cipher.AEAD
+
+	// With leaderOnly set, ingresses run only while this node is the Raft
+	// leader, as reported by leading
+	leaderOnly bool
+	leading    bool
+
+	mu sync.RWMutex
 }
 
 // NewManager creates a new ingress manager
@@ -39,6 +61,9 @@ func NewManager(dataDir string, store *store.IndexStore, raftNode *raft.RaftNode
 		raftNode:   raftNode,
 		logger:     logger,
 		configFile: filepath.Join(dataDir, "ingresses.json"),
+
+		profiles:    make(map[string]Profile),
+		profileFile: filepath.Join(dataDir, "profiles.json"),
 	}
 }
 
@@ -49,37 +74,57 @@ func (m *Manager) RegisterFactory(ingressType string, factory Factory) {
 	m.factories[ingressType] = factory
 }
 
-// Load loads ingress configurations from disk and creates ingresses
+// Load loads connection profiles and ingress configurations from disk and
+// creates ingresses
 func (m *Manager) Load() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	data, err := os.ReadFile(m.configFile)
+	// Ingresses without a profile load regardless. Profiles stay
+	// unavailable, so the unreadable ones aren't overwritten.
+	if err := m.loadProfiles(); err != nil {
+		m.logger.Error("Failed to load connection profiles", zap.Error(err))
+		m.profileCipher = nil
+	}
+
+	data, recovered, err := persist.ReadFile(m.configFile)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil // No config file yet
 		}
 		return fmt.Errorf("failed to read ingress config: %w", err)
 	}
+	if recovered {
+		m.logger.Warn("Ingress config was corrupt, recovered the previous generation",
+			zap.String("file", m.configFile))
+	}
 
 	var configs map[string]Config
 	if err := sonic.Unmarshal(data, &configs); err != nil {
 		return fmt.Errorf("failed to parse ingress config: %w", err)
 	}
 
+	if configs == nil {
+		configs = make(map[string]Config)
+	}
 	m.configs = configs
 
+	if recovered {
+		if err := m.save(); err != nil {
+			return err
+		}
+	}
+
 	// Create ingresses from loaded configs
 	for id, cfg := range configs {
-		factory, ok := m.factories[cfg.Type]
-		if !ok {
+		if _, ok := m.factories[cfg.Type]; !ok {
 			m.logger.Warn("Unknown ingress type, skipping",
 				zap.String("id", id),
 				zap.String("type", cfg.Type))
 			continue
 		}
 
-		ingress, err := factory(cfg, m.store, m.raftNode, m.logger)
+		ingress, err := m.build(cfg)
 		if err != nil {
 			m.logger.Error("Failed to create ingress",
 				zap.String("id", id),
@@ -93,14 +138,14 @@ func (m *Manager) Load() error {
 	return nil
 }
 
-// save persists ingress configurations to disk
+// save atomically persists ingress configurations to disk
 func (m *Manager) save() error {
 	data, err := sonic.ConfigDefault.MarshalIndent(m.configs, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal ingress config: %w", err)
 	}
 
-	if err := os.WriteFile(m.configFile, data, 0644); err != nil {
+	if err := persist.WriteFile(m.configFile, data, 0644); err != nil {
 		return fmt.Errorf("failed to write ingress config: %w", err)
 	}
 
@@ -114,7 +159,7 @@ func (m *Manager) Create(indexID string, ingressType string, id string, rawConfi
 
 	// Check if ingress already exists
 	if _, exists := m.ingresses[id]; exists {
-		return nil, fmt.Errorf("ingress %s already exists", id)
+		return nil, fmt.Errorf("%w: %s", ErrIngressExists, id)
 	}
 
 	// Check if index exists
@@ -122,10 +167,9 @@ func (m *Manager) Create(indexID string, ingressType string, id string, rawConfi
 		return nil, fmt.Errorf("index %s not found", indexID)
 	}
 
-	// Get factory for type
-	factory, ok := m.factories[ingressType]
-	if !ok {
-		return nil, fmt.Errorf("unknown ingress type: %s", ingressType)
+	// Check the type is known
+	if _, ok := m.factories[ingressType]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownIngressType, ingressType)
 	}
 
 	cfg := Config{
@@ -136,9 +180,9 @@ func (m *Manager) Create(indexID string, ingressType string, id string, rawConfi
 	}
 
 	// Create ingress
-	ingress, err := factory(cfg, m.store, m.raftNode, m.logger)
+	ingress, err := m.build(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create ingress: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
 	}
 
 	m.ingresses[id] = ingress
@@ -158,7 +202,7 @@ func (m *Manager) Get(id string) (Ingress, error) {
 
 	ingress, ok := m.ingresses[id]
 	if !ok {
-		return nil, fmt.Errorf("ingress %s not found", id)
+		return nil, fmt.Errorf("%w: %s", ErrIngressNotFound, id)
 	}
 
 	return ingress, nil
@@ -199,7 +243,7 @@ func (m *Manager) Delete(id string) error {
 
 	ingress, ok := m.ingresses[id]
 	if !ok {
-		return fmt.Errorf("ingress %s not found", id)
+		return fmt.Errorf("%w: %s", ErrIngressNotFound, id)
 	}
 
 	// Stop the ingress first
@@ -219,7 +263,36 @@ func (m *Manager) Delete(id string) error {
 	return nil
 }
 
-// StartAll starts all ingresses
+// RemoveIndexIngresses stops and removes the ingresses of a deleted index in
+// the background. Stopping waits for in-flight batches, which may wait for
+// the Raft FSM that deleted the index.
+func (m *Manager) RemoveIndexIngresses(indexID string) {
+	m.mu.RLock()
+	var ids []string
+	for id, ingress := range m.ingresses {
+		if ingress.IndexID() == indexID {
+			ids = append(ids, id)
+		}
+	}
+	m.mu.RUnlock()
+
+	for _, id := range ids {
+		go func(id string) {
+			if err := m.Delete(id); err != nil && !errors.Is(err, ErrIngressNotFound) {
+				m.logger.Error("Failed to remove ingress of deleted index",
+					zap.String("id", id),
+					zap.String("index_id", indexID),
+					zap.Error(err))
+				return
+			}
+			m.logger.Info("Removed ingress of deleted index",
+				zap.String("id", id),
+				zap.String("index_id", indexID))
+		}(id)
+	}
+}
+
+// StartAll starts all ingresses, unless they wait for this node to lead
 func (m *Manager) StartAll(ctx context.Context) error {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -227,6 +300,9 @@ func (m *Manager) StartAll(ctx context.Context) error {
 	if ctx == nil {
 		ctx = context.Background()
 	}
+	if m.leaderOnly && !m.leading {
+		return nil
+	}
 
 	var firstErr error
 	for id, ingress := range m.ingresses {
@@ -262,3 +338,44 @@ func (m *Manager) StopAll() error {
 
 	return firstErr
 }
+
+// PauseAll pauses all running ingresses and returns the IDs of those it
+// paused, so only they are resumed by ResumeAll
+func (m *Manager) PauseAll() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var paused []string
+	for id, ingress := range m.ingresses {
+		if ingress.Status() != StatusRunning {
+			continue
+		}
+		if err := ingress.Pause(); err != nil {
+			m.logger.Error("Failed to pause ingress",
+				zap.String("id", id),
+				zap.Error(err))
+			continue
+		}
+		paused = append(paused, id)
+	}
+
+	return paused
+}
+
+// ResumeAll resumes the given ingresses if they are still paused
+func (m *Manager) ResumeAll(ids []string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, id := range ids {
+		ingress, ok := m.ingresses[id]
+		if !ok || ingress.Status() != StatusPaused {
+			continue
+		}
+		if err := ingress.Resume(); err != nil {
+			m.logger.Error("Failed to resume ingress",
+				zap.String("id", id),
+				zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package ingresses
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Labels of ingress metrics
+var metricLabels = []string{"ingress", "index", "type"}
+
+var (
+	lagDesc = prometheus.NewDesc(
+		"ingress_lag_seconds",
+		"How far an ingress trails its source: the newest source change minus the last synced one.",
+		metricLabels, nil,
+	)
+	pendingDeletesDesc = prometheus.NewDesc(
+		"ingress_pending_deletes",
+		"Source deletes an ingress has not synced yet.",
+		metricLabels, nil,
+	)
+	deleteBacklogDesc = prometheus.NewDesc(
+		"ingress_delete_backlog",
+		"Tracked deletes kept by the source of an ingress, synced or not.",
+		metricLabels, nil,
+	)
+	lastSyncDesc = prometheus.NewDesc(
+		"ingress_last_sync_timestamp_seconds",
+		"Unix time of the last successful sync of an ingress.",
+		metricLabels, nil,
+	)
+)
+
+// collector reports the statistics of the ingresses of a manager
+type collector struct {
+	manager *Manager
+}
+
+// RegisterMetrics registers the lag, delete and last sync gauges of every
+// ingress
+func (m *Manager) RegisterMetrics(registerer prometheus.Registerer) error {
+	if err := registerer.Register(&collector{manager: m}); err != nil {
+		return fmt.Errorf("failed to register ingress metrics: %w", err)
+	}
+	return nil
+}
+
+// Describe implements prometheus.Collector
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- lagDesc
+	ch <- pendingDeletesDesc
+	ch <- deleteBacklogDesc
+	ch <- lastSyncDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	for _, ingress := range c.manager.ListAll() {
+		stats := ingress.Statistics()
+		labels := []string{ingress.ID(), ingress.IndexID(), ingress.Type()}
+
+		ch <- prometheus.MustNewConstMetric(lagDesc, prometheus.GaugeValue, stats.LagSeconds, labels...)
+		ch <- prometheus.MustNewConstMetric(pendingDeletesDesc, prometheus.GaugeValue, float64(stats.PendingDeletes), labels...)
+		ch <- prometheus.MustNewConstMetric(deleteBacklogDesc, prometheus.GaugeValue, float64(stats.DeleteBacklog), labels...)
+		if !stats.LastSyncAt.IsZero() {
+			ch <- prometheus.MustNewConstMetric(lastSyncDesc, prometheus.GaugeValue, float64(stats.LastSyncAt.Unix()), labels...)
+		}
+	}
+}
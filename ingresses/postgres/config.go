@@ -2,9 +2,11 @@ package postgres
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/bytedance/sonic"
+	"github.com/jackc/pgx/v5"
 )
 
 // SyncMode defines how the ingress synchronizes data
@@ -15,6 +17,20 @@ const (
 	SyncModeListen  SyncMode = "listen"
 )
 
+// PauseNotifications defines what a paused ingress in listen mode does with
+// notifications
+type PauseNotifications string
+
+const (
+	// PauseNotificationsBuffer keeps listening and holds the latest
+	// notification of each row, applied on resume
+	PauseNotificationsBuffer PauseNotifications = "buffer"
+
+	// PauseNotificationsDrop stops listening; resuming catches up with an
+	// incremental sync
+	PauseNotificationsDrop PauseNotifications = "drop"
+)
+
 // Config holds the configuration for a PostgreSQL ingress
 type Config struct {
 	// Connection settings
@@ -25,12 +41,22 @@ type Config struct {
 	Table   string   `json:"table"`             // Table name to sync
 	Columns []string `json:"columns,omitempty"` // Columns to sync (empty = all)
 
+	// AutoIncludeColumns syncs columns added to the table after the full
+	// sync, on top of Columns
+	AutoIncludeColumns bool `json:"auto_include_columns,omitempty"`
+
 	// Primary key settings
 	PrimaryKey string `json:"primary_key"` // Primary key column name
 
 	// Column mapping: source column -> document field
 	ColumnMapping map[string]string `json:"column_mapping,omitempty"`
 
+	// Target fields, so ingresses feeding one index keep apart: FieldPrefix
+	// is prepended to the document fields (pg_title), and FieldNamespace
+	// nests them under an object (pg.title). The primary key is left as is.
+	FieldPrefix    string `json:"field_prefix,omitempty"`
+	FieldNamespace string `json:"field_namespace,omitempty"`
+
 	// Sync settings
 	UpdatedAtColumn string   `json:"updated_at_column,omitempty"` // Column for incremental sync
 	WhereClause     string   `json:"where_clause,omitempty"`      // Additional WHERE filter
@@ -38,9 +64,32 @@ type Config struct {
 	PollInterval    Duration `json:"poll_interval,omitempty"`     // Polling interval (default: 30s)
 	BatchSize       int      `json:"batch_size,omitempty"`        // Documents per batch (default: 1000)
 
+	// Soft deletes: rows whose SoftDeleteColumn is set (not NULL), or equal
+	// to SoftDeleteValue if given, are removed from the index
+	SoftDeleteColumn string `json:"soft_delete_column,omitempty"`
+	SoftDeleteValue  string `json:"soft_delete_value,omitempty"`
+
 	// Trigger settings
 	AutoTriggers  bool   `json:"auto_triggers"`            // Auto-create triggers
 	NotifyChannel string `json:"notify_channel,omitempty"` // LISTEN/NOTIFY channel name
+
+	// Notification batching: rows notified within NotifyFlushInterval are
+	// fetched together, up to NotifyBatchSize per query, by NotifyWorkers
+	// concurrent workers
+	NotifyBatchSize     int      `json:"notify_batch_size,omitempty"`     // default: 1000
+	NotifyFlushInterval Duration `json:"notify_flush_interval,omitempty"` // default: 100ms
+	NotifyWorkers       int      `json:"notify_workers,omitempty"`        // default: 4
+
+	// Pausing in listen mode: buffered notifications are held for up to
+	// PauseBufferSize rows; past that they are dropped and resuming catches
+	// up with an incremental sync
+	PauseNotifications PauseNotifications `json:"pause_notifications,omitempty"` // buffer or drop (default: buffer)
+	PauseBufferSize    int                `json:"pause_buffer_size,omitempty"`   // default: 100000
+
+	// Delete tracking cleanup: tracked deletes older than the persisted sync
+	// cursor minus DeleteRetention are pruned every CleanupInterval
+	DeleteRetention Duration `json:"delete_retention,omitempty"` // default: 24h
+	CleanupInterval Duration `json:"cleanup_interval,omitempty"` // default: 1h
 }
 
 // Duration is a time.Duration that can be unmarshaled from JSON
@@ -95,6 +144,64 @@ func (c *Config) Validate() error {
 	if c.SyncMode == SyncModePolling && c.UpdatedAtColumn == "" {
 		return fmt.Errorf("updated_at_column is required for polling mode")
 	}
+	if c.NotifyBatchSize < 0 || c.NotifyFlushInterval < 0 || c.NotifyWorkers < 0 {
+		return fmt.Errorf("notify_batch_size, notify_flush_interval and notify_workers must not be negative")
+	}
+	switch c.PauseNotifications {
+	case "", PauseNotificationsBuffer:
+	case PauseNotificationsDrop:
+		if c.UpdatedAtColumn == "" {
+			return fmt.Errorf("updated_at_column is required to drop notifications while paused")
+		}
+	default:
+		return fmt.Errorf("pause_notifications must be 'buffer' or 'drop'")
+	}
+	if c.PauseBufferSize < 0 {
+		return fmt.Errorf("pause_buffer_size must not be negative")
+	}
+	if c.DeleteRetention < 0 || c.CleanupInterval < 0 {
+		return fmt.Errorf("delete_retention and cleanup_interval must not be negative")
+	}
+	if strings.Contains(c.FieldPrefix, ".") || strings.Contains(c.FieldNamespace, ".") {
+		return fmt.Errorf("field_prefix and field_namespace must not contain dots")
+	}
+	if c.FieldNamespace != "" && c.FieldNamespace == c.primaryKeyField() {
+		return fmt.Errorf("field_namespace must differ from the primary key field")
+	}
+	if c.SoftDeleteValue != "" && c.SoftDeleteColumn == "" {
+		return fmt.Errorf("soft_delete_value requires soft_delete_column")
+	}
+
+	identifiers := map[string]string{
+		"table":       c.Table,
+		"primary_key": c.PrimaryKey,
+	}
+	optional := map[string]string{
+		"schema":             c.Schema,
+		"updated_at_column":  c.UpdatedAtColumn,
+		"soft_delete_column": c.SoftDeleteColumn,
+		"notify_channel":     c.NotifyChannel,
+	}
+	for field, name := range optional {
+		if name != "" {
+			identifiers[field] = name
+		}
+	}
+	for field, name := range identifiers {
+		if err := validateIdentifier(field, name); err != nil {
+			return err
+		}
+	}
+	for _, column := range c.Columns {
+		if err := validateIdentifier("columns", column); err != nil {
+			return err
+		}
+	}
+	if c.WhereClause != "" {
+		if err := validateWhereClause(c.WhereClause); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -113,12 +220,70 @@ func (c *Config) WithDefaults() *Config {
 	if cfg.BatchSize == 0 {
 		cfg.BatchSize = 1000
 	}
+	if cfg.NotifyBatchSize == 0 {
+		cfg.NotifyBatchSize = 1000
+	}
+	if cfg.NotifyFlushInterval == 0 {
+		cfg.NotifyFlushInterval = Duration(100 * time.Millisecond)
+	}
+	if cfg.NotifyWorkers == 0 {
+		cfg.NotifyWorkers = 4
+	}
+	if cfg.DeleteRetention == 0 {
+		cfg.DeleteRetention = Duration(24 * time.Hour)
+	}
+	if cfg.PauseNotifications == "" {
+		cfg.PauseNotifications = PauseNotificationsBuffer
+	}
+	if cfg.PauseBufferSize == 0 {
+		cfg.PauseBufferSize = 100000
+	}
+	if cfg.CleanupInterval == 0 {
+		cfg.CleanupInterval = Duration(time.Hour)
+	}
 	if cfg.NotifyChannel == "" {
 		cfg.NotifyChannel = fmt.Sprintf("bright_%s", cfg.Table)
 	}
 	return &cfg
 }
 
+// softDeleteConditions returns the SQL conditions matching rows marked
+// deleted and rows that are not
+func (c *Config) softDeleteConditions() (deleted, live string) {
+	column := quoteIdentifier(c.SoftDeleteColumn)
+	if c.SoftDeleteValue == "" {
+		return column + " IS NOT NULL", column + " IS NULL"
+	}
+	value := quoteLiteral(c.SoftDeleteValue)
+	return fmt.Sprintf("%s::TEXT = %s", column, value),
+		fmt.Sprintf("%s::TEXT IS DISTINCT FROM %s", column, value)
+}
+
+// primaryKeyField returns the document field of the primary key
+func (c *Config) primaryKeyField() string {
+	if mapped, ok := c.ColumnMapping[c.PrimaryKey]; ok {
+		return mapped
+	}
+	return c.PrimaryKey
+}
+
+// quotedTableName returns the quoted schema and table, for queries
+func (c *Config) quotedTableName() string {
+	return pgx.Identifier{c.Schema, c.Table}.Sanitize()
+}
+
+// selectColumns returns the quoted columns to select
+func (c *Config) selectColumns() string {
+	if len(c.Columns) == 0 {
+		return "*"
+	}
+	quoted := make([]string, len(c.Columns))
+	for i, column := range c.Columns {
+		quoted[i] = quoteIdentifier(column)
+	}
+	return strings.Join(quoted, ", ")
+}
+
 // FullTableName returns schema.table
 func (c *Config) FullTableName() string {
 	return fmt.Sprintf("%s.%s", c.Schema, c.Table)
@@ -7,6 +7,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -29,11 +30,24 @@ type Ingress struct {
 	listener  *Listener
 	mapper    *Mapper
 
+	// columns are the table columns seen by the last full sync, and
+	// configuredColumns the columns of the config before new ones are
+	// included
+	columns           []Column
+	configuredColumns []string
+
 	store    *store.IndexStore
 	raftNode *raft.RaftNode
 	logger   *zap.Logger
 
 	status atomic.Value // ingresses.Status
+
+	// startedAt and batchSeq build unique idempotency keys for Raft batches
+	startedAt time.Time
+	batchSeq  atomic.Uint64
+
+	// pausedAt is when the ingress was last paused
+	pausedAt time.Time
 	stats  struct {
 		sync.RWMutex
 		lastSyncAt       time.Time
@@ -42,6 +56,11 @@ type Ingress struct {
 		fullSyncComplete bool
 		lastError        string
 		errorCount       int
+		lag              time.Duration
+		pendingDeletes   int64
+		lagMeasuredAt    time.Time
+		schemaDrift      *ingresses.SchemaDrift
+		deleteBacklog    int64
 	}
 
 	ctx    context.Context
@@ -73,6 +92,9 @@ func NewIngress(cfg ingresses.Config, store *store.IndexStore, raftNode *raft.Ra
 		raftNode:  raftNode,
 		logger:    logger.With(zap.String("ingress_id", cfg.ID), zap.String("index_id", cfg.IndexID)),
 		mapper:    NewMapper(pgConfigWithDefaults),
+		startedAt: time.Now(),
+
+		configuredColumns: slices.Clone(pgConfigWithDefaults.Columns),
 	}
 
 	ing.status.Store(ingresses.StatusStopped)
@@ -122,6 +144,12 @@ func (i *Ingress) Statistics() ingresses.Statistics {
 		FullSyncComplete: i.stats.fullSyncComplete,
 		LastError:        i.stats.lastError,
 		ErrorCount:       i.stats.errorCount,
+		SchemaDrift:      i.stats.schemaDrift,
+		LagSeconds:       i.stats.lag.Seconds(),
+		PendingDeletes:   i.stats.pendingDeletes,
+		DeleteBacklog:    i.stats.deleteBacklog,
+		LagMeasuredAt:    i.stats.lagMeasuredAt,
+		Conflicts:        i.store.ConflictStats(i.indexID, i.id),
 	}
 }
 
@@ -188,6 +216,12 @@ func (i *Ingress) Start(ctx context.Context) error {
 		i.startPollingMode()
 	}
 
+	i.wg.Add(1)
+	go func() {
+		defer i.wg.Done()
+		i.cleanupLoop()
+	}()
+
 	i.status.Store(ingresses.StatusRunning)
 	i.logger.Info("PostgreSQL ingress started",
 		zap.String("sync_mode", string(i.config.SyncMode)))
@@ -229,21 +263,41 @@ func (i *Ingress) Stop() error {
 	return nil
 }
 
-// Pause temporarily pauses synchronization
+// Pause temporarily pauses synchronization. Polls and cleanups are skipped,
+// a running full sync waits between batches, and in listen mode
+// notifications are held or dropped per pause_notifications, so the source
+// database is left alone until Resume.
 func (i *Ingress) Pause() error {
 	i.mu.Lock()
 	defer i.mu.Unlock()
 
-	if i.Status() != ingresses.StatusRunning {
+	status := i.Status()
+	if status != ingresses.StatusRunning && status != ingresses.StatusSyncing {
 		return fmt.Errorf("ingress is not running")
 	}
 
 	i.status.Store(ingresses.StatusPaused)
+	i.pausedAt = time.Now()
+
+	if i.poller != nil {
+		i.poller.SetPaused(true)
+	}
+	if i.listener != nil {
+		if i.config.PauseNotifications == PauseNotificationsDrop {
+			i.listener.Stop()
+			i.listener = nil
+		} else {
+			i.listener.Hold(i.config.PauseBufferSize)
+		}
+	}
+
 	i.logger.Info("PostgreSQL ingress paused")
 	return nil
 }
 
-// Resume resumes a paused synchronization
+// Resume resumes a paused synchronization. In listen mode, held
+// notifications are applied; dropped ones are caught up with an incremental
+// sync of the changes since the pause.
 func (i *Ingress) Resume() error {
 	i.mu.Lock()
 	defer i.mu.Unlock()
@@ -252,6 +306,36 @@ func (i *Ingress) Resume() error {
 		return fmt.Errorf("ingress is not paused")
 	}
 
+	if i.poller != nil {
+		i.poller.SetPaused(false)
+	}
+
+	if i.config.SyncMode == SyncModeListen {
+		catchUp := false
+		if i.listener == nil {
+			// Listen again before catching up, so no change falls in between
+			i.listener = NewListener(i.connector.Pool(), i.config, i.logger)
+			i.listener.SetCallback(i.handleNotifications)
+			if err := i.listener.Start(i.ctx); err != nil {
+				i.listener = nil
+				i.setError(fmt.Sprintf("failed to listen on resume: %v", err))
+				return err
+			}
+			catchUp = true
+		} else {
+			catchUp = !i.listener.Release()
+		}
+
+		if catchUp {
+			since := i.pausedAt
+			i.wg.Add(1)
+			go func() {
+				defer i.wg.Done()
+				i.catchUp(since)
+			}()
+		}
+	}
+
 	i.status.Store(ingresses.StatusRunning)
 	i.logger.Info("PostgreSQL ingress resumed")
 	return nil
@@ -269,8 +353,16 @@ func (i *Ingress) Resync() error {
 	i.stats.fullSyncComplete = false
 	i.stats.documentsSynced = 0
 	i.stats.documentsDeleted = 0
+	i.stats.lag = 0
+	i.stats.pendingDeletes = 0
+	i.stats.lagMeasuredAt = time.Time{}
+	i.stats.schemaDrift = nil
 	i.stats.Unlock()
 
+	// The full sync takes the table's columns as they are then
+	i.columns = nil
+	i.config.Columns = slices.Clone(i.configuredColumns)
+
 	if i.poller != nil {
 		i.poller.ResetState()
 	}
@@ -292,6 +384,7 @@ func (i *Ingress) Resync() error {
 func (i *Ingress) startPollingMode() {
 	i.poller = NewPoller(i.connector.Pool(), i.config, i.logger)
 	i.poller.SetCallbacks(i.handleDocuments, i.handleDeletes)
+	i.poller.SetJobs(i.store.Jobs(), i.id)
 
 	// Set initial state
 	i.stats.RLock()
@@ -335,6 +428,11 @@ func (i *Ingress) doPoll() {
 		}
 	}()
 
+	if err := i.checkSchema(); err != nil {
+		i.setError(err.Error())
+		return
+	}
+
 	if err := i.poller.Poll(i.ctx); err != nil {
 		i.setError(fmt.Sprintf("poll failed: %v", err))
 		return
@@ -349,6 +447,121 @@ func (i *Ingress) doPoll() {
 
 	// Persist state
 	i.saveState()
+
+	i.measureLag()
+}
+
+// measureLag updates the replication lag statistics. A failed measurement
+// keeps the previous values, since the sync itself succeeded.
+func (i *Ingress) measureLag() {
+	lag, pendingDeletes, err := i.poller.MeasureLag(i.ctx)
+	if err != nil {
+		i.logger.Warn("Failed to measure replication lag", zap.Error(err))
+		return
+	}
+
+	i.stats.Lock()
+	i.stats.lag = lag
+	i.stats.pendingDeletes = pendingDeletes
+	i.stats.lagMeasuredAt = time.Now()
+	i.stats.Unlock()
+}
+
+// cleanupLoop prunes synced rows from the delete tracking table
+func (i *Ingress) cleanupLoop() {
+	ticker := time.NewTicker(i.config.CleanupInterval.Duration())
+	defer ticker.Stop()
+
+	i.cleanup()
+
+	for {
+		select {
+		case <-i.ctx.Done():
+			return
+		case <-ticker.C:
+			if i.Status() == ingresses.StatusPaused {
+				continue
+			}
+			i.cleanup()
+		}
+	}
+}
+
+// cleanup prunes the delete tracking table once and records its backlog
+func (i *Ingress) cleanup() {
+	pruned, remaining, err := i.schema.PruneDeletes(i.ctx, i.config.DeleteRetention.Duration())
+	if err != nil {
+		if i.ctx.Err() == nil {
+			i.logger.Warn("Failed to clean up tracked deletes", zap.Error(err))
+		}
+		return
+	}
+	if pruned > 0 {
+		i.logger.Debug("Pruned tracked deletes",
+			zap.Int64("pruned", pruned),
+			zap.Int64("remaining", remaining))
+	}
+
+	i.stats.Lock()
+	i.stats.deleteBacklog = remaining
+	i.stats.Unlock()
+}
+
+// checkSchema compares the columns of the table with the columns seen by the
+// last full sync. Added columns are synced if auto_include_columns is set;
+// synced columns that were removed or changed type stop the sync until the
+// ingress is resynced, rather than indexing documents without them.
+func (i *Ingress) checkSchema() error {
+	current, err := i.schema.Columns(i.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read table columns: %v", err)
+	}
+	if len(current) == 0 {
+		return fmt.Errorf("table %s not found", i.config.FullTableName())
+	}
+
+	if i.columns == nil {
+		i.columns = current
+		return nil
+	}
+
+	drift := detectDrift(i.columns, current)
+
+	i.stats.Lock()
+	previous := i.stats.schemaDrift
+	if drift != nil {
+		drift.DetectedAt = time.Now()
+		if previous != nil {
+			drift.DetectedAt = previous.DetectedAt
+		}
+	}
+	i.stats.schemaDrift = drift
+	i.stats.Unlock()
+
+	if drift == nil {
+		return nil
+	}
+	if previous == nil {
+		i.logger.Warn("Source table schema changed since the last full sync",
+			zap.Strings("added", drift.Added),
+			zap.Strings("removed", drift.Removed),
+			zap.Strings("changed", drift.Changed))
+	}
+
+	if i.config.AutoIncludeColumns && len(i.configuredColumns) > 0 {
+		i.config.Columns = append(slices.Clone(i.configuredColumns), drift.Added...)
+	}
+
+	var broken []string
+	for _, column := range slices.Concat(drift.Removed, drift.Changed) {
+		if len(i.configuredColumns) == 0 || slices.Contains(i.configuredColumns, column) {
+			broken = append(broken, column)
+		}
+	}
+	if len(broken) > 0 {
+		return fmt.Errorf("schema drift: synced columns %s were removed or changed type, resync the ingress to sync the table as it is now", strings.Join(broken, ", "))
+	}
+	return nil
 }
 
 // startListenMode starts the LISTEN/NOTIFY sync
@@ -356,6 +569,7 @@ func (i *Ingress) startListenMode() error {
 	// Create poller for sync operations
 	i.poller = NewPoller(i.connector.Pool(), i.config, i.logger)
 	i.poller.SetCallbacks(i.handleDocuments, i.handleDeletes)
+	i.poller.SetJobs(i.store.Jobs(), i.id)
 
 	i.stats.RLock()
 	fullSyncComplete := i.stats.fullSyncComplete
@@ -365,6 +579,10 @@ func (i *Ingress) startListenMode() error {
 	// Set poller state from saved state
 	i.poller.SetState(lastSyncAt, "", fullSyncComplete)
 
+	if err := i.checkSchema(); err != nil {
+		return err
+	}
+
 	// Always do a catch-up sync on startup to handle changes that occurred
 	// while the service was offline. This will be:
 	// - Full sync if fullSyncComplete is false (first run)
@@ -382,25 +600,78 @@ func (i *Ingress) startListenMode() error {
 	i.stats.Unlock()
 	i.saveState()
 
+	// Notifications are applied as they arrive, so lag is only measured
+	// after the catch-up
+	i.measureLag()
+
 	// Start listener for real-time updates
 	i.listener = NewListener(i.connector.Pool(), i.config, i.logger)
-	i.listener.SetCallback(i.handleNotify)
+	i.listener.SetCallback(i.handleNotifications)
 
 	return i.listener.Start(i.ctx)
 }
 
+// catchUp syncs the changes made since a pause whose notifications were
+// dropped
+func (i *Ingress) catchUp(since time.Time) {
+	if i.config.UpdatedAtColumn == "" {
+		i.logger.Error("Notifications were dropped while paused and updated_at_column is not set; resync to pick up the missed changes")
+		return
+	}
+
+	i.logger.Info("Catching up with changes made while paused",
+		zap.Time("since", since))
+
+	_, _, fullSyncComplete := i.poller.GetState()
+	i.poller.SetState(since, "", fullSyncComplete)
+	if err := i.poller.Poll(i.ctx); err != nil {
+		i.setError(fmt.Sprintf("catch-up sync failed: %v", err))
+		return
+	}
+
+	lastSyncAt, _, _ := i.poller.GetState()
+	i.stats.Lock()
+	i.stats.lastSyncAt = lastSyncAt
+	i.stats.Unlock()
+	i.saveState()
+
+	i.measureLag()
+}
+
 // handleDocuments processes synced documents
 func (i *Ingress) handleDocuments(docs []map[string]any) error {
 	if len(docs) == 0 {
 		return nil
 	}
 
+	if err := i.store.ExtractAttachments(i.ctx, i.indexID, docs); err != nil {
+		return err
+	}
+
+	// Documents carry their origin only to indexes with a conflict policy
+	_, indexConfig, err := i.store.GetIndex(i.indexID)
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		if indexConfig.Conflicts != nil {
+			doc[store.OriginField] = i.id
+		} else {
+			delete(doc, store.OriginTimestampField)
+		}
+	}
+
+	// Enrichment is best effort; unenriched rows are still synced
+	if err := i.store.EnrichDocuments(i.ctx, i.indexID, docs); err != nil {
+		i.logger.Warn("Document enrichment failed", zap.String("index", i.indexID), zap.Error(err))
+	}
+
 	// Use Raft if enabled, otherwise direct store access
 	if i.raftNode != nil && i.raftNode.IsLeader() {
 		return i.applyDocumentsViaRaft(docs)
 	}
 
-	err := i.store.AddDocumentsInternal(i.indexID, docs)
+	err = i.store.AddDocumentsInternal(i.indexID, docs)
 	if err != nil {
 		return err
 	}
@@ -430,52 +701,95 @@ func (i *Ingress) handleDeletes(ids []string) error {
 	return nil
 }
 
-// handleNotify processes a LISTEN/NOTIFY event
-func (i *Ingress) handleNotify(op string, id string) error {
-	switch op {
-	case "INSERT", "UPDATE":
-		// Fetch the document and sync it
-		doc, err := i.fetchDocument(id)
+// handleNotifications processes a batch of LISTEN/NOTIFY events. Only the
+// last event of each row counts, and the rows that changed are fetched with
+// one query.
+func (i *Ingress) handleNotifications(ops []NotifyPayload) error {
+	latest := make(map[string]string, len(ops))
+	ids := make([]string, 0, len(ops))
+	for _, op := range ops {
+		if _, seen := latest[op.ID]; !seen {
+			ids = append(ids, op.ID)
+		}
+		latest[op.ID] = op.Op
+	}
+
+	var changed, deleted []string
+	for _, id := range ids {
+		switch latest[id] {
+		case "INSERT", "UPDATE":
+			changed = append(changed, id)
+		case "DELETE":
+			deleted = append(deleted, id)
+		}
+	}
+
+	if len(changed) > 0 {
+		docs, missing, err := i.fetchDocuments(changed)
 		if err != nil {
 			return err
 		}
-		if doc != nil {
-			return i.handleDocuments([]map[string]any{doc})
+		if err := i.handleDocuments(docs); err != nil {
+			return err
+		}
+		// Rows marked deleted are not fetched
+		if i.config.SoftDeleteColumn != "" {
+			deleted = append(deleted, missing...)
 		}
-	case "DELETE":
-		return i.handleDeletes([]string{id})
 	}
-	return nil
-}
 
-// fetchDocument fetches a single document by primary key
-func (i *Ingress) fetchDocument(id string) (map[string]any, error) {
-	columns := "*"
-	if len(i.config.Columns) > 0 {
-		columns = strings.Join(i.config.Columns, ", ")
-	}
+	return i.handleDeletes(deleted)
+}
 
-	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = $1",
-		columns, i.config.FullTableName(), i.config.PrimaryKey)
+// fetchDocuments fetches documents by primary key, and returns the IDs of
+// the rows that no longer match
+func (i *Ingress) fetchDocuments(ids []string) ([]map[string]any, []string, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = ANY($1) %s",
+		i.config.selectColumns(), i.config.quotedTableName(), quoteIdentifier(i.config.PrimaryKey), i.poller.andWhereClause())
 
-	rows, err := i.connector.Pool().Query(i.ctx, query, id)
+	rows, err := i.connector.Pool().Query(i.ctx, query, arrayLiteral(ids))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
-	if rows.Next() {
-		return i.mapper.RowToDocument(rows)
+	docs := make([]map[string]any, 0, len(ids))
+	found := make(map[string]bool, len(ids))
+	for rows.Next() {
+		doc, err := i.mapper.RowToDocument(rows)
+		if err != nil {
+			i.logger.Warn("Failed to map row", zap.Error(err))
+			continue
+		}
+		if id, err := i.mapper.GetPrimaryKeyValue(doc); err == nil {
+			found[id] = true
+		}
+		docs = append(docs, doc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("rows iteration error: %w", err)
 	}
 
-	return nil, nil
+	var missing []string
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	return docs, missing, nil
 }
 
+// raftApplyAttempts is how often a batch is applied before giving up
+const raftApplyAttempts = 3
+
 // applyDocumentsViaRaft applies documents through Raft consensus
+// The batch carries an idempotency key, so an attempt that timed out but was
+// committed anyway is not indexed twice when retried.
 func (i *Ingress) applyDocumentsViaRaft(docs []map[string]any) error {
 	payload := raft.AddDocumentsPayload{
-		IndexID:   i.indexID,
-		Documents: docs,
+		IndexID:        i.indexID,
+		Documents:      docs,
+		IdempotencyKey: fmt.Sprintf("ingress:%s:%d:%d", i.id, i.startedAt.UnixNano(), i.batchSeq.Add(1)),
 	}
 
 	payloadData, err := sonic.Marshal(payload)
@@ -488,8 +802,15 @@ func (i *Ingress) applyDocumentsViaRaft(docs []map[string]any) error {
 		Data: payloadData,
 	}
 
-	if err := i.raftNode.Apply(cmd, 30*time.Second); err != nil {
-		return err
+	for attempt := 1; ; attempt++ {
+		err = i.raftNode.Apply(cmd, 30*time.Second)
+		if err == nil {
+			break
+		}
+		if attempt == raftApplyAttempts || !i.raftNode.IsLeader() {
+			return err
+		}
+		i.logger.Warn("Retrying Raft apply", zap.Int("attempt", attempt), zap.Error(err))
 	}
 
 	i.stats.Lock()
@@ -508,16 +829,23 @@ func (i *Ingress) loadState() {
 	var lastSyncAt *time.Time
 	var lastID *string
 	var fullSyncComplete bool
+	var columns *string
 
 	err := i.connector.Pool().QueryRow(i.ctx,
-		"SELECT last_sync_at, last_id, full_sync_complete FROM __bright_synchronization WHERE table_name = $1",
-		i.config.Table).Scan(&lastSyncAt, &lastID, &fullSyncComplete)
+		"SELECT last_sync_at, last_id, full_sync_complete, columns FROM __bright_synchronization WHERE table_name = $1",
+		i.config.Table).Scan(&lastSyncAt, &lastID, &fullSyncComplete, &columns)
 
 	if err != nil {
 		// No state found, start fresh
 		return
 	}
 
+	if columns != nil {
+		if err := sonic.UnmarshalString(*columns, &i.columns); err != nil {
+			i.logger.Warn("Failed to parse synced columns", zap.Error(err))
+		}
+	}
+
 	i.stats.Lock()
 	if lastSyncAt != nil {
 		i.stats.lastSyncAt = *lastSyncAt
@@ -537,14 +865,25 @@ func (i *Ingress) saveState() {
 	fullSyncComplete := i.stats.fullSyncComplete
 	i.stats.RUnlock()
 
+	var columns *string
+	if i.columns != nil {
+		encoded, err := sonic.MarshalString(i.columns)
+		if err != nil {
+			i.logger.Warn("Failed to encode synced columns", zap.Error(err))
+		} else {
+			columns = &encoded
+		}
+	}
+
 	_, err := i.connector.Pool().Exec(i.ctx, `
-		INSERT INTO __bright_synchronization (table_name, last_sync_at, full_sync_complete, updated_at)
-		VALUES ($1, $2, $3, NOW())
+		INSERT INTO __bright_synchronization (table_name, last_sync_at, full_sync_complete, columns, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
 		ON CONFLICT (table_name) DO UPDATE SET
 			last_sync_at = EXCLUDED.last_sync_at,
 			full_sync_complete = EXCLUDED.full_sync_complete,
+			columns = EXCLUDED.columns,
 			updated_at = NOW()
-	`, i.config.Table, lastSyncAt, fullSyncComplete)
+	`, i.config.Table, lastSyncAt, fullSyncComplete, columns)
 
 	if err != nil {
 		i.logger.Warn("Failed to save sync state", zap.Error(err))
@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"hash/fnv"
 	"sync"
 	"time"
 
@@ -17,14 +18,16 @@ type NotifyPayload struct {
 	ID string `json:"id"` // Primary key value
 }
 
-// Listener handles LISTEN/NOTIFY based synchronization
+// Listener handles LISTEN/NOTIFY based synchronization. Notifications are
+// collected into batches, which a fixed number of workers process; each row
+// always goes to the same worker, so its changes are applied in order.
 type Listener struct {
 	pool   *pgxpool.Pool
 	config *Config
 	logger *zap.Logger
 
 	// Callbacks
-	onNotify func(op string, id string) error
+	onBatch func(ops []NotifyPayload) error
 
 	// Batching
 	batchMu      sync.Mutex
@@ -32,6 +35,19 @@ type Listener struct {
 	batchTimeout time.Duration
 	batchSize    int
 
+	// Workers receive batches of their rows; a full queue blocks the
+	// listener until a worker catches up
+	workers []chan []NotifyPayload
+	closed  bool
+
+	// While holding, the latest notification of each row is kept instead
+	// of processed, up to holdLimit rows
+	holding    bool
+	holdLimit  int
+	held       map[string]NotifyPayload
+	heldOrder  []string
+	overflowed bool
+
 	// Control
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
@@ -43,14 +59,14 @@ func NewListener(pool *pgxpool.Pool, config *Config, logger *zap.Logger) *Listen
 		pool:         pool,
 		config:       config,
 		logger:       logger,
-		batchTimeout: 100 * time.Millisecond,
-		batchSize:    100,
+		batchTimeout: config.NotifyFlushInterval.Duration(),
+		batchSize:    config.NotifyBatchSize,
 	}
 }
 
-// SetCallback sets the callback for notification processing
-func (l *Listener) SetCallback(onNotify func(op string, id string) error) {
-	l.onNotify = onNotify
+// SetCallback sets the callback for batches of notifications
+func (l *Listener) SetCallback(onBatch func(ops []NotifyPayload) error) {
+	l.onBatch = onBatch
 }
 
 // Start begins listening for notifications
@@ -65,14 +81,26 @@ func (l *Listener) Start(ctx context.Context) error {
 
 	// Subscribe to the channel
 	channel := l.config.NotifyChannel
-	_, err = conn.Exec(ctx, fmt.Sprintf("LISTEN %s", channel))
+	_, err = conn.Exec(ctx, "LISTEN "+quoteIdentifier(channel))
 	if err != nil {
 		conn.Release()
 		return fmt.Errorf("failed to LISTEN on channel %s: %w", channel, err)
 	}
 
 	l.logger.Info("Listening for notifications",
-		zap.String("channel", channel))
+		zap.String("channel", channel),
+		zap.Int("workers", l.config.NotifyWorkers))
+
+	// Start the workers
+	l.workers = make([]chan []NotifyPayload, l.config.NotifyWorkers)
+	for n := range l.workers {
+		l.workers[n] = make(chan []NotifyPayload, 1)
+		l.wg.Add(1)
+		go func(batches <-chan []NotifyPayload) {
+			defer l.wg.Done()
+			l.worker(ctx, batches)
+		}(l.workers[n])
+	}
 
 	// Start the listener goroutine
 	l.wg.Add(1)
@@ -137,6 +165,13 @@ func (l *Listener) addToBatch(payload NotifyPayload) {
 	l.batchMu.Lock()
 	defer l.batchMu.Unlock()
 
+	if l.closed {
+		return
+	}
+	if l.holding {
+		l.holdLocked(payload)
+		return
+	}
 	l.pendingOps = append(l.pendingOps, payload)
 
 	// If batch is full, process immediately
@@ -145,6 +180,63 @@ func (l *Listener) addToBatch(payload NotifyPayload) {
 	}
 }
 
+// holdLocked keeps a notification received while holding (must be called
+// with lock held)
+func (l *Listener) holdLocked(payload NotifyPayload) {
+	if l.overflowed {
+		return
+	}
+	if _, ok := l.held[payload.ID]; !ok {
+		if len(l.held) >= l.holdLimit {
+			l.logger.Warn("Too many rows changed while paused, dropping notifications",
+				zap.Int("limit", l.holdLimit))
+			l.overflowed = true
+			l.held = nil
+			l.heldOrder = nil
+			return
+		}
+		l.heldOrder = append(l.heldOrder, payload.ID)
+	}
+	l.held[payload.ID] = payload
+}
+
+// Hold stops processing notifications and keeps the latest one of each row,
+// for up to limit rows, until Release
+func (l *Listener) Hold(limit int) {
+	l.batchMu.Lock()
+	defer l.batchMu.Unlock()
+
+	l.holding = true
+	l.holdLimit = limit
+	l.held = make(map[string]NotifyPayload)
+	l.heldOrder = nil
+	l.overflowed = false
+}
+
+// Release processes the held notifications and resumes processing. It
+// returns false if notifications were dropped because more than the limit
+// of rows changed.
+func (l *Listener) Release() bool {
+	l.batchMu.Lock()
+	defer l.batchMu.Unlock()
+
+	complete := !l.overflowed
+	if !l.closed {
+		for _, id := range l.heldOrder {
+			l.pendingOps = append(l.pendingOps, l.held[id])
+			if len(l.pendingOps) >= l.batchSize {
+				l.processBatchLocked()
+			}
+		}
+	}
+
+	l.holding = false
+	l.held = nil
+	l.heldOrder = nil
+	l.overflowed = false
+	return complete
+}
+
 // batchProcessor periodically processes pending batches
 func (l *Listener) batchProcessor(ctx context.Context) {
 	ticker := time.NewTicker(l.batchTimeout)
@@ -153,10 +245,13 @@ func (l *Listener) batchProcessor(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
-			// Process any remaining items
+			// Pending notifications are dropped: the catch-up sync picks
+			// their rows up on the next start
 			l.batchMu.Lock()
-			if len(l.pendingOps) > 0 {
-				l.processBatchLocked()
+			l.closed = true
+			l.pendingOps = nil
+			for _, batches := range l.workers {
+				close(batches)
 			}
 			l.batchMu.Unlock()
 			return
@@ -170,24 +265,40 @@ func (l *Listener) batchProcessor(ctx context.Context) {
 	}
 }
 
-// processBatchLocked processes the pending batch (must be called with lock held)
+// processBatchLocked hands the pending batch to the workers (must be called
+// with lock held)
 func (l *Listener) processBatchLocked() {
-	if l.onNotify == nil || len(l.pendingOps) == 0 {
+	if l.onBatch == nil || len(l.pendingOps) == 0 {
 		return
 	}
 
 	ops := l.pendingOps
 	l.pendingOps = nil
 
-	// Process outside the lock
-	go func() {
-		for _, op := range ops {
-			if err := l.onNotify(op.Op, op.ID); err != nil {
-				l.logger.Error("Failed to process notification",
-					zap.String("op", op.Op),
-					zap.String("id", op.ID),
-					zap.Error(err))
-			}
+	parts := make([][]NotifyPayload, len(l.workers))
+	for _, op := range ops {
+		hash := fnv.New32a()
+		hash.Write([]byte(op.ID))
+		n := hash.Sum32() % uint32(len(l.workers))
+		parts[n] = append(parts[n], op)
+	}
+	for n, part := range parts {
+		if len(part) > 0 {
+			l.workers[n] <- part
 		}
-	}()
+	}
+}
+
+// worker processes the batches of its rows in order
+func (l *Listener) worker(ctx context.Context, batches <-chan []NotifyPayload) {
+	for ops := range batches {
+		if ctx.Err() != nil {
+			continue
+		}
+		if err := l.onBatch(ops); err != nil {
+			l.logger.Error("Failed to process notifications",
+				zap.Int("count", len(ops)),
+				zap.Error(err))
+		}
+	}
 }
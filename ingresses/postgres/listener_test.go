@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestListenerHold(t *testing.T) {
+	config := (&Config{Table: "products"}).WithDefaults()
+	listener := NewListener(nil, config, zap.NewNop())
+
+	listener.Hold(10)
+	listener.addToBatch(NotifyPayload{Op: "INSERT", ID: "1"})
+	listener.addToBatch(NotifyPayload{Op: "INSERT", ID: "2"})
+	listener.addToBatch(NotifyPayload{Op: "DELETE", ID: "1"})
+	if len(listener.pendingOps) != 0 {
+		t.Fatalf("Expected no pending notifications while holding, got %v", listener.pendingOps)
+	}
+
+	if !listener.Release() {
+		t.Error("Expected every held notification to be kept")
+	}
+	expected := []NotifyPayload{{Op: "DELETE", ID: "1"}, {Op: "INSERT", ID: "2"}}
+	if !reflect.DeepEqual(listener.pendingOps, expected) {
+		t.Errorf("Expected %v, got %v", expected, listener.pendingOps)
+	}
+
+	listener.pendingOps = nil
+	listener.Hold(2)
+	for _, id := range []string{"1", "2", "3", "4"} {
+		listener.addToBatch(NotifyPayload{Op: "UPDATE", ID: id})
+	}
+	if listener.Release() {
+		t.Error("Expected notifications past the limit to be dropped")
+	}
+	if len(listener.pendingOps) != 0 {
+		t.Errorf("Expected dropped notifications not to be processed, got %v", listener.pendingOps)
+	}
+
+	listener.addToBatch(NotifyPayload{Op: "UPDATE", ID: "5"})
+	if len(listener.pendingOps) != 1 {
+		t.Errorf("Expected notifications to be processed after release, got %v", listener.pendingOps)
+	}
+}
@@ -1,6 +1,7 @@
 package postgres
 
 import (
+	"bright/store"
 	"fmt"
 	"time"
 
@@ -30,24 +31,52 @@ func (m *Mapper) RowToDocument(rows pgx.Rows) (map[string]any, error) {
 	for i, fd := range fieldDescs {
 		colName := string(fd.Name)
 
+		// The source time of the change, for conflict policies
+		if colName == m.config.UpdatedAtColumn {
+			if timestamp, ok := values[i].(time.Time); ok {
+				doc[store.OriginTimestampField] = float64(timestamp.UnixMilli())
+			}
+		}
+
 		// Skip if we have a column filter and this column isn't in it
 		if len(m.config.Columns) > 0 && !contains(m.config.Columns, colName) {
 			continue
 		}
 
-		// Apply column mapping if configured
-		docField := colName
-		if mapped, ok := m.config.ColumnMapping[colName]; ok {
-			docField = mapped
-		}
-
 		// Convert PostgreSQL types to Go types
-		doc[docField] = m.convertValue(values[i])
+		m.setColumn(doc, colName, m.convertValue(values[i]))
 	}
 
 	return doc, nil
 }
 
+// setColumn sets the document field of a column, applying the column
+// mapping, then the field prefix and namespace. The primary key stays at the
+// top level as it is, since it identifies the document.
+func (m *Mapper) setColumn(doc map[string]any, column string, value any) {
+	field := column
+	if mapped, ok := m.config.ColumnMapping[column]; ok {
+		field = mapped
+	}
+	if column == m.config.PrimaryKey {
+		doc[field] = value
+		return
+	}
+
+	field = m.config.FieldPrefix + field
+	if m.config.FieldNamespace == "" {
+		doc[field] = value
+		return
+	}
+
+	nested, ok := doc[m.config.FieldNamespace].(map[string]any)
+	if !ok {
+		nested = make(map[string]any)
+		doc[m.config.FieldNamespace] = nested
+	}
+	nested[field] = value
+}
+
 // convertValue converts PostgreSQL values to JSON-compatible types
 func (m *Mapper) convertValue(v any) any {
 	if v == nil {
@@ -153,13 +182,7 @@ func (m *Mapper) convertValue(v any) any {
 
 // GetPrimaryKeyValue extracts the primary key value from a document
 func (m *Mapper) GetPrimaryKeyValue(doc map[string]any) (string, error) {
-	pk := m.config.PrimaryKey
-
-	// Check if column mapping applies
-	docField := pk
-	if mapped, ok := m.config.ColumnMapping[pk]; ok {
-		docField = mapped
-	}
+	docField := m.config.primaryKeyField()
 
 	val, ok := doc[docField]
 	if !ok {
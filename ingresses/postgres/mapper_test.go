@@ -0,0 +1,41 @@
+package postgres
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMapperFieldPrefix(t *testing.T) {
+	columns := map[string]any{"sku": "a1", "title": "Lamp", "price": 10.5}
+
+	tests := []struct {
+		name   string
+		config Config
+		expect map[string]any
+	}{
+		{
+			name:   "prefix",
+			config: Config{PrimaryKey: "sku", FieldPrefix: "pg_"},
+			expect: map[string]any{"sku": "a1", "pg_title": "Lamp", "pg_price": 10.5},
+		},
+		{
+			name:   "namespace with mapped primary key",
+			config: Config{PrimaryKey: "sku", FieldNamespace: "pg", ColumnMapping: map[string]string{"sku": "id", "title": "name"}},
+			expect: map[string]any{"id": "a1", "pg": map[string]any{"name": "Lamp", "price": 10.5}},
+		},
+	}
+
+	for _, tt := range tests {
+		mapper := NewMapper(&tt.config)
+		doc := make(map[string]any)
+		for column, value := range columns {
+			mapper.setColumn(doc, column, value)
+		}
+		if !reflect.DeepEqual(doc, tt.expect) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expect, doc)
+		}
+		if id, err := mapper.GetPrimaryKeyValue(doc); err != nil || id != "a1" {
+			t.Errorf("%s: unexpected primary key %q (%v)", tt.name, id, err)
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package postgres
 
 import (
+	"bright/jobs"
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -25,6 +27,15 @@ type Poller struct {
 	lastSyncAt       time.Time
 	lastID           string
 	fullSyncComplete bool
+
+	// Full syncs run as background jobs of the ingress
+	jobs   *jobs.Manager
+	target string
+
+	// paused holds the running full sync job between batches
+	pauseMu sync.Mutex
+	paused  bool
+	job     *jobs.Job
 }
 
 // NewPoller creates a new Poller
@@ -43,6 +54,13 @@ func (p *Poller) SetCallbacks(onDocuments func(docs []map[string]any) error, onD
 	p.onDeletes = onDeletes
 }
 
+// SetJobs sets the manager throttling full syncs, which are registered as
+// resync jobs of target
+func (p *Poller) SetJobs(manager *jobs.Manager, target string) {
+	p.jobs = manager
+	p.target = target
+}
+
 // SetState sets the initial sync state
 func (p *Poller) SetState(lastSyncAt time.Time, lastID string, fullSyncComplete bool) {
 	p.lastSyncAt = lastSyncAt
@@ -50,6 +68,18 @@ func (p *Poller) SetState(lastSyncAt time.Time, lastID string, fullSyncComplete
 	p.fullSyncComplete = fullSyncComplete
 }
 
+// SetPaused pauses or resumes the running full sync, and the ones started
+// while paused, between batches
+func (p *Poller) SetPaused(paused bool) {
+	p.pauseMu.Lock()
+	defer p.pauseMu.Unlock()
+
+	p.paused = paused
+	if p.job != nil {
+		p.jobs.Update(p.job.Info().ID, jobs.Update{Paused: &paused})
+	}
+}
+
 // GetState returns the current sync state
 func (p *Poller) GetState() (time.Time, string, bool) {
 	return p.lastSyncAt, p.lastID, p.fullSyncComplete
@@ -68,6 +98,24 @@ func (p *Poller) fullSync(ctx context.Context) error {
 	p.logger.Info("Starting full sync",
 		zap.String("table", p.config.FullTableName()))
 
+	var job *jobs.Job
+	if p.jobs != nil {
+		job = p.jobs.Start(jobs.KindResync, p.target)
+		defer job.Done()
+
+		p.pauseMu.Lock()
+		p.job = job
+		if p.paused {
+			p.jobs.Update(job.Info().ID, jobs.Update{Paused: &p.paused})
+		}
+		p.pauseMu.Unlock()
+		defer func() {
+			p.pauseMu.Lock()
+			p.job = nil
+			p.pauseMu.Unlock()
+		}()
+	}
+
 	totalDocs := 0
 	for {
 		docs, lastID, err := p.fetchBatch(ctx, p.lastID)
@@ -96,6 +144,12 @@ func (p *Poller) fullSync(ctx context.Context) error {
 		if len(docs) < p.config.BatchSize {
 			break
 		}
+
+		if job != nil {
+			if err := job.Wait(ctx, len(docs)); err != nil {
+				return err
+			}
+		}
 	}
 
 	p.fullSyncComplete = true
@@ -130,6 +184,11 @@ func (p *Poller) incrementalSync(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to fetch deletes: %w", err)
 	}
+	softDeleteIDs, err := p.fetchSoftDeletes(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch soft deletes: %w", err)
+	}
+	deleteIDs = append(deleteIDs, softDeleteIDs...)
 
 	if len(deleteIDs) > 0 && p.onDeletes != nil {
 		if err := p.onDeletes(deleteIDs); err != nil {
@@ -145,10 +204,9 @@ func (p *Poller) incrementalSync(ctx context.Context) error {
 
 // fetchBatch fetches a batch of documents for full sync
 func (p *Poller) fetchBatch(ctx context.Context, afterID string) ([]map[string]any, string, error) {
-	columns := "*"
-	if len(p.config.Columns) > 0 {
-		columns = strings.Join(p.config.Columns, ", ")
-	}
+	columns := p.config.selectColumns()
+	table := p.config.quotedTableName()
+	primaryKey := quoteIdentifier(p.config.PrimaryKey)
 
 	var query string
 	var args []any
@@ -159,7 +217,7 @@ func (p *Poller) fetchBatch(ctx context.Context, afterID string) ([]map[string]a
 			%s
 			ORDER BY %s
 			LIMIT $1
-		`, columns, p.config.FullTableName(), p.whereClause(), p.config.PrimaryKey)
+		`, columns, table, p.whereClause(), primaryKey)
 		args = []any{p.config.BatchSize}
 	} else {
 		query = fmt.Sprintf(`
@@ -167,7 +225,7 @@ func (p *Poller) fetchBatch(ctx context.Context, afterID string) ([]map[string]a
 			WHERE %s > $1 %s
 			ORDER BY %s
 			LIMIT $2
-		`, columns, p.config.FullTableName(), p.config.PrimaryKey, p.andWhereClause(), p.config.PrimaryKey)
+		`, columns, table, primaryKey, p.andWhereClause(), primaryKey)
 		args = []any{afterID, p.config.BatchSize}
 	}
 
@@ -206,17 +264,13 @@ func (p *Poller) fetchBatch(ctx context.Context, afterID string) ([]map[string]a
 
 // fetchChanges fetches documents changed since last sync
 func (p *Poller) fetchChanges(ctx context.Context) ([]map[string]any, error) {
-	columns := "*"
-	if len(p.config.Columns) > 0 {
-		columns = strings.Join(p.config.Columns, ", ")
-	}
-
+	updatedAt := quoteIdentifier(p.config.UpdatedAtColumn)
 	query := fmt.Sprintf(`
 		SELECT %s FROM %s
 		WHERE %s > $1 %s
 		ORDER BY %s
 		LIMIT $2
-	`, columns, p.config.FullTableName(), p.config.UpdatedAtColumn, p.andWhereClause(), p.config.UpdatedAtColumn)
+	`, p.config.selectColumns(), p.config.quotedTableName(), updatedAt, p.andWhereClause(), updatedAt)
 
 	rows, err := p.pool.Query(ctx, query, p.lastSyncAt, p.config.BatchSize)
 	if err != nil {
@@ -273,20 +327,113 @@ func (p *Poller) fetchDeletes(ctx context.Context) ([]string, error) {
 	return ids, nil
 }
 
+// MeasureLag returns how far the sync trails the source: the newest
+// updated_at in the source minus the last sync, and the number of tracked
+// deletes not yet synced. Lag is only measured once the full sync completed
+// and when an updated_at column is configured.
+func (p *Poller) MeasureLag(ctx context.Context) (time.Duration, int64, error) {
+	if !p.fullSyncComplete {
+		return 0, 0, nil
+	}
+
+	var lag time.Duration
+	if p.config.UpdatedAtColumn != "" {
+		query := fmt.Sprintf(`SELECT MAX(%s) FROM %s %s`,
+			quoteIdentifier(p.config.UpdatedAtColumn), p.config.quotedTableName(), p.whereClause())
+
+		var newest *time.Time
+		if err := p.pool.QueryRow(ctx, query).Scan(&newest); err != nil {
+			return 0, 0, fmt.Errorf("failed to query newest change: %w", err)
+		}
+		if newest != nil && newest.After(p.lastSyncAt) {
+			lag = newest.Sub(p.lastSyncAt)
+		}
+	}
+
+	var pendingDeletes int64
+	err := p.pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM __bright_synchronization_deletes
+		WHERE source_table = $1 AND deleted_at > $2
+	`, p.config.Table, p.lastSyncAt).Scan(&pendingDeletes)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count pending deletes: %w", err)
+	}
+
+	return lag, pendingDeletes, nil
+}
+
+// fetchSoftDeletes fetches the IDs of rows marked deleted since last sync
+func (p *Poller) fetchSoftDeletes(ctx context.Context) ([]string, error) {
+	if p.config.SoftDeleteColumn == "" {
+		return nil, nil
+	}
+
+	deleted, _ := p.config.softDeleteConditions()
+	updatedAt := quoteIdentifier(p.config.UpdatedAtColumn)
+	filter := ""
+	if p.config.WhereClause != "" {
+		filter = "AND (" + p.config.WhereClause + ")"
+	}
+	query := fmt.Sprintf(`
+		SELECT %s::TEXT FROM %s
+		WHERE %s > $1 AND %s %s
+		ORDER BY %s
+		LIMIT $2
+	`, quoteIdentifier(p.config.PrimaryKey), p.config.quotedTableName(), updatedAt, deleted, filter, updatedAt)
+
+	rows, err := p.pool.Query(ctx, query, p.lastSyncAt, p.config.BatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make([]string, 0, p.config.BatchSize)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			p.logger.Warn("Failed to scan soft deleted ID", zap.Error(err))
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return ids, nil
+}
+
 // whereClause returns the WHERE clause for queries
 func (p *Poller) whereClause() string {
-	if p.config.WhereClause == "" {
+	conditions := p.conditions()
+	if len(conditions) == 0 {
 		return ""
 	}
-	return "WHERE " + p.config.WhereClause
+	return "WHERE " + strings.Join(conditions, " AND ")
 }
 
 // andWhereClause returns an AND clause for additional conditions
 func (p *Poller) andWhereClause() string {
-	if p.config.WhereClause == "" {
+	conditions := p.conditions()
+	if len(conditions) == 0 {
 		return ""
 	}
-	return "AND " + p.config.WhereClause
+	return "AND " + strings.Join(conditions, " AND ")
+}
+
+// conditions returns the conditions of synced rows: the configured filter,
+// and rows not marked deleted
+func (p *Poller) conditions() []string {
+	var conditions []string
+	if p.config.WhereClause != "" {
+		conditions = append(conditions, "("+p.config.WhereClause+")")
+	}
+	if p.config.SoftDeleteColumn != "" {
+		_, live := p.config.softDeleteConditions()
+		conditions = append(conditions, live)
+	}
+	return conditions
 }
 
 // ResetState resets the sync state for a full resync
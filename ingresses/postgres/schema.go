@@ -1,8 +1,10 @@
 package postgres
 
 import (
+	"bright/ingresses"
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -34,6 +36,14 @@ func (s *Schema) CreateSyncTables(ctx context.Context) error {
 		return fmt.Errorf("failed to create __bright_synchronization table: %w", err)
 	}
 
+	// Columns seen by the last full sync, added after the table
+	_, err = s.pool.Exec(ctx, `
+		ALTER TABLE __bright_synchronization ADD COLUMN IF NOT EXISTS columns TEXT
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add columns to __bright_synchronization table: %w", err)
+	}
+
 	// Create delete tracking table
 	_, err = s.pool.Exec(ctx, `
 		CREATE TABLE IF NOT EXISTS __bright_synchronization_deletes (
@@ -59,30 +69,121 @@ func (s *Schema) CreateSyncTables(ctx context.Context) error {
 	return nil
 }
 
+// PruneDeletes removes the tracked deletes of the table older than the
+// persisted sync cursor minus retention, and returns how many were removed
+// and how many are left. Nothing is pruned before the full sync completed.
+func (s *Schema) PruneDeletes(ctx context.Context, retention time.Duration) (int64, int64, error) {
+	tag, err := s.pool.Exec(ctx, `
+		DELETE FROM __bright_synchronization_deletes
+		WHERE source_table = $1 AND deleted_at < (
+			SELECT last_sync_at - $2 * INTERVAL '1 second' FROM __bright_synchronization
+			WHERE table_name = $1 AND full_sync_complete
+		)
+	`, s.config.Table, retention.Seconds())
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to prune tracked deletes: %w", err)
+	}
+
+	var remaining int64
+	err = s.pool.QueryRow(ctx,
+		"SELECT COUNT(*) FROM __bright_synchronization_deletes WHERE source_table = $1",
+		s.config.Table).Scan(&remaining)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count tracked deletes: %w", err)
+	}
+	return tag.RowsAffected(), remaining, nil
+}
+
+// Column is a column of the source table
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Columns returns the columns of the source table in table order
+func (s *Schema) Columns(ctx context.Context) ([]Column, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT column_name, data_type FROM information_schema.columns
+		WHERE table_schema = $1 AND table_name = $2
+		ORDER BY ordinal_position
+	`, s.config.Schema, s.config.Table)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var columns []Column
+	for rows.Next() {
+		var column Column
+		if err := rows.Scan(&column.Name, &column.Type); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return columns, nil
+}
+
+// detectDrift compares the columns of the table with the columns seen by the
+// last full sync, returning nil if they match
+func detectDrift(baseline, current []Column) *ingresses.SchemaDrift {
+	types := make(map[string]string, len(baseline))
+	for _, column := range baseline {
+		types[column.Name] = column.Type
+	}
+
+	drift := &ingresses.SchemaDrift{}
+	seen := make(map[string]bool, len(current))
+	for _, column := range current {
+		seen[column.Name] = true
+		previous, ok := types[column.Name]
+		switch {
+		case !ok:
+			drift.Added = append(drift.Added, column.Name)
+		case previous != column.Type:
+			drift.Changed = append(drift.Changed, column.Name)
+		}
+	}
+	for _, column := range baseline {
+		if !seen[column.Name] {
+			drift.Removed = append(drift.Removed, column.Name)
+		}
+	}
+
+	if len(drift.Added) == 0 && len(drift.Removed) == 0 && len(drift.Changed) == 0 {
+		return nil
+	}
+	return drift
+}
+
 // CreateDeleteTrigger creates the trigger for tracking hard deletes
 func (s *Schema) CreateDeleteTrigger(ctx context.Context) error {
 	tableName := s.config.Table
-	fullTable := s.config.FullTableName()
-	primaryKey := s.config.PrimaryKey
+	fullTable := s.config.quotedTableName()
+	primaryKey := quoteIdentifier(s.config.PrimaryKey)
 
-	// Create trigger function
-	funcName := fmt.Sprintf("__bright_track_deletes_%s", tableName)
-	_, err := s.pool.Exec(ctx, fmt.Sprintf(`
-		CREATE OR REPLACE FUNCTION %s()
-		RETURNS TRIGGER AS $$
+	// Create trigger function. The body is a string constant, so names
+	// inside it are quoted twice.
+	funcName := quoteIdentifier("__bright_track_deletes_" + tableName)
+	body := fmt.Sprintf(`
 		BEGIN
 			INSERT INTO __bright_synchronization_deletes (source_table, deleted_id)
-			VALUES ('%s', OLD.%s::TEXT);
+			VALUES (%s, OLD.%s::TEXT);
 			RETURN OLD;
 		END;
-		$$ LANGUAGE plpgsql
-	`, funcName, tableName, primaryKey))
+	`, quoteLiteral(tableName), primaryKey)
+	_, err := s.pool.Exec(ctx, fmt.Sprintf(`
+		CREATE OR REPLACE FUNCTION %s()
+		RETURNS TRIGGER LANGUAGE plpgsql AS %s
+	`, funcName, quoteLiteral(body)))
 	if err != nil {
 		return fmt.Errorf("failed to create delete tracking function: %w", err)
 	}
 
 	// Create trigger
-	triggerName := fmt.Sprintf("__bright_delete_trigger_%s", tableName)
+	triggerName := quoteIdentifier("__bright_delete_trigger_" + tableName)
 	_, err = s.pool.Exec(ctx, fmt.Sprintf(`
 		DROP TRIGGER IF EXISTS %s ON %s;
 		CREATE TRIGGER %s
@@ -99,17 +200,14 @@ func (s *Schema) CreateDeleteTrigger(ctx context.Context) error {
 // CreateNotifyTrigger creates the trigger for LISTEN/NOTIFY mode
 func (s *Schema) CreateNotifyTrigger(ctx context.Context) error {
 	tableName := s.config.Table
-	fullTable := s.config.FullTableName()
-	primaryKey := s.config.PrimaryKey
-	channel := s.config.NotifyChannel
+	fullTable := s.config.quotedTableName()
+	primaryKey := quoteIdentifier(s.config.PrimaryKey)
 
 	// Create trigger function
-	funcName := fmt.Sprintf("__bright_notify_%s", tableName)
-	_, err := s.pool.Exec(ctx, fmt.Sprintf(`
-		CREATE OR REPLACE FUNCTION %s()
-		RETURNS TRIGGER AS $$
+	funcName := quoteIdentifier("__bright_notify_" + tableName)
+	body := fmt.Sprintf(`
 		BEGIN
-			PERFORM pg_notify('%s',
+			PERFORM pg_notify(%s,
 				json_build_object(
 					'op', TG_OP,
 					'id', COALESCE(NEW.%s, OLD.%s)::TEXT
@@ -117,14 +215,17 @@ func (s *Schema) CreateNotifyTrigger(ctx context.Context) error {
 			);
 			RETURN COALESCE(NEW, OLD);
 		END;
-		$$ LANGUAGE plpgsql
-	`, funcName, channel, primaryKey, primaryKey))
+	`, quoteLiteral(s.config.NotifyChannel), primaryKey, primaryKey)
+	_, err := s.pool.Exec(ctx, fmt.Sprintf(`
+		CREATE OR REPLACE FUNCTION %s()
+		RETURNS TRIGGER LANGUAGE plpgsql AS %s
+	`, funcName, quoteLiteral(body)))
 	if err != nil {
 		return fmt.Errorf("failed to create notify function: %w", err)
 	}
 
 	// Create trigger
-	triggerName := fmt.Sprintf("__bright_notify_trigger_%s", tableName)
+	triggerName := quoteIdentifier("__bright_notify_trigger_" + tableName)
 	_, err = s.pool.Exec(ctx, fmt.Sprintf(`
 		DROP TRIGGER IF EXISTS %s ON %s;
 		CREATE TRIGGER %s
@@ -141,21 +242,21 @@ func (s *Schema) CreateNotifyTrigger(ctx context.Context) error {
 // DropTriggers removes all bright triggers from the table
 func (s *Schema) DropTriggers(ctx context.Context) error {
 	tableName := s.config.Table
-	fullTable := s.config.FullTableName()
+	fullTable := s.config.quotedTableName()
 
 	// Drop delete trigger
-	deleteTrigger := fmt.Sprintf("__bright_delete_trigger_%s", tableName)
+	deleteTrigger := quoteIdentifier("__bright_delete_trigger_" + tableName)
 	_, _ = s.pool.Exec(ctx, fmt.Sprintf(`DROP TRIGGER IF EXISTS %s ON %s`, deleteTrigger, fullTable))
 
 	// Drop notify trigger
-	notifyTrigger := fmt.Sprintf("__bright_notify_trigger_%s", tableName)
+	notifyTrigger := quoteIdentifier("__bright_notify_trigger_" + tableName)
 	_, _ = s.pool.Exec(ctx, fmt.Sprintf(`DROP TRIGGER IF EXISTS %s ON %s`, notifyTrigger, fullTable))
 
 	// Drop functions
-	deleteFunc := fmt.Sprintf("__bright_track_deletes_%s", tableName)
+	deleteFunc := quoteIdentifier("__bright_track_deletes_" + tableName)
 	_, _ = s.pool.Exec(ctx, fmt.Sprintf(`DROP FUNCTION IF EXISTS %s()`, deleteFunc))
 
-	notifyFunc := fmt.Sprintf("__bright_notify_%s", tableName)
+	notifyFunc := quoteIdentifier("__bright_notify_" + tableName)
 	_, _ = s.pool.Exec(ctx, fmt.Sprintf(`DROP FUNCTION IF EXISTS %s()`, notifyFunc))
 
 	return nil
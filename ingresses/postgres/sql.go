@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// maxIdentifierLength is the longest identifier PostgreSQL keeps
+// (NAMEDATALEN - 1); longer ones are silently truncated
+const maxIdentifierLength = 63
+
+// quoteIdentifier quotes a table, column or channel name, so mixed case
+// and reserved names are used as they are
+func quoteIdentifier(name string) string {
+	return pgx.Identifier{name}.Sanitize()
+}
+
+// quoteLiteral quotes a string constant, for statements that cannot take
+// parameters such as function bodies
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// arrayLiteral returns a text array constant of values, such as
+// {"1","2"}. Sent as text, PostgreSQL reads it as an array of whatever type
+// the query expects, so IDs can be compared with keys of any type.
+func arrayLiteral(values []string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for n, value := range values {
+		if n > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('"')
+		for _, r := range value {
+			if r == '"' || r == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		}
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// validateIdentifier checks a name from the config before it is quoted
+func validateIdentifier(field, name string) error {
+	if name == "" {
+		return fmt.Errorf("%s must not be empty", field)
+	}
+	if len(name) > maxIdentifierLength {
+		return fmt.Errorf("%s %q is longer than %d bytes", field, name, maxIdentifierLength)
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("%s %q contains control characters", field, name)
+		}
+	}
+	return nil
+}
+
+// validateWhereClause checks that a where clause is a single expression. It
+// is wrapped in parentheses by the queries, so it must not close more
+// parentheses than it opens, end the statement or comment out the rest.
+// Dollar quotes and backslashes are refused, as they change where strings
+// end.
+func validateWhereClause(clause string) error {
+	depth := 0
+	quote := rune(0)
+	previous := rune(0)
+	for _, r := range clause {
+		// Escape strings (E'\'') would end where the check doesn't expect
+		if r == '\\' {
+			return fmt.Errorf("where_clause must not contain backslashes")
+		}
+		if quote != 0 {
+			// Doubled quotes reopen the string right away
+			if r == quote {
+				quote = 0
+			}
+			previous = r
+			continue
+		}
+
+		switch r {
+		case '\'', '"':
+			quote = r
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return fmt.Errorf("where_clause has unbalanced parentheses")
+			}
+		case ';':
+			return fmt.Errorf("where_clause must be a single expression")
+		case '$':
+			return fmt.Errorf("where_clause must not contain dollar quotes")
+		case '-':
+			if previous == '-' {
+				return fmt.Errorf("where_clause must not contain comments")
+			}
+		case '*':
+			if previous == '/' {
+				return fmt.Errorf("where_clause must not contain comments")
+			}
+		}
+		previous = r
+	}
+
+	if quote != 0 {
+		return fmt.Errorf("where_clause has an unterminated quote")
+	}
+	if depth != 0 {
+		return fmt.Errorf("where_clause has unbalanced parentheses")
+	}
+	return nil
+}
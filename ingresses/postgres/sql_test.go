@@ -0,0 +1,40 @@
+package postgres
+
+import "testing"
+
+func TestValidateWhereClause(t *testing.T) {
+	valid := []string{
+		"status = 'active'",
+		"price > 10 AND (stock > 0 OR backorder)",
+		"name <> 'it''s; -- not a comment'",
+		`"Category" IN ('a', 'b')`,
+	}
+	for _, clause := range valid {
+		if err := validateWhereClause(clause); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", clause, err)
+		}
+	}
+
+	invalid := []string{
+		"1=1) UNION SELECT password FROM users WHERE (1=1",
+		"1=1; DROP TABLE products",
+		"1=1 --",
+		"1=1 /* comment */",
+		"name = $$)$$",
+		`name = E'\'') OR (1=1'`,
+		"name = 'unterminated",
+		"(price > 10",
+	}
+	for _, clause := range invalid {
+		if err := validateWhereClause(clause); err == nil {
+			t.Errorf("Expected %q to be refused", clause)
+		}
+	}
+
+	if got := quoteIdentifier(`Order"Items`); got != `"Order""Items"` {
+		t.Errorf("Unexpected quoted identifier %s", got)
+	}
+	if got := arrayLiteral([]string{"1", `a"b\c`}); got != `{"1","a\"b\\c"}` {
+		t.Errorf("Unexpected array literal %s", got)
+	}
+}
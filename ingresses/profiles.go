@@ -0,0 +1,399 @@
+package ingresses
+
+import (
+	"bright/persist"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"go.uber.org/zap"
+)
+
+// Errors returned for connection profiles
+var (
+	ErrProfileNotFound     = errors.New("connection profile not found")
+	ErrProfileInUse        = errors.New("connection profile in use")
+	ErrProfilesUnavailable = errors.New("connection profiles need an encryption key")
+)
+
+// profileField is the ingress config field referencing a profile
+const profileField = "profile"
+
+// Profile is a named part of the config of ingresses of one type, typically
+// the connection string and credentials of a database. Ingresses reference
+// it with "profile" in their config, and their own fields override the
+// profile's, so rotating a password means updating a single profile.
+type Profile struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Config    json.RawMessage `json:"config"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// ProfileInfo describes a profile for API responses. The values of its
+// fields are never returned.
+type ProfileInfo struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Fields    []string  `json:"fields"`
+	Ingresses []string  `json:"ingresses"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// sealedProfile is a profile as persisted, with its config encrypted
+type sealedProfile struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Sealed    []byte    `json:"sealed"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// profiledIngress is an ingress created from a config merged with a
+// profile. It reports the config it was given, which leaves the profile's
+// secrets out of responses.
+type profiledIngress struct {
+	Ingress
+	config json.RawMessage
+}
+
+// Config returns the ingress config as given, referencing its profile
+func (p *profiledIngress) Config() json.RawMessage {
+	return p.config
+}
+
+// SetProfileKey sets the secret connection profiles are encrypted with. It
+// must be called before Load; without it profiles are unavailable.
+func (m *Manager) SetProfileKey(secret string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if secret == "" {
+		m.profileCipher = nil
+		return nil
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return fmt.Errorf("failed to create profile cipher: %w", err)
+	}
+	m.profileCipher, err = cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("failed to create profile cipher: %w", err)
+	}
+	return nil
+}
+
+// loadProfiles reads and decrypts the persisted profiles
+func (m *Manager) loadProfiles() error {
+	data, recovered, err := persist.ReadFile(m.profileFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read connection profiles: %w", err)
+	}
+	if recovered {
+		m.logger.Warn("Connection profiles were corrupt, recovered the previous generation",
+			zap.String("file", m.profileFile))
+	}
+	if m.profileCipher == nil {
+		return fmt.Errorf("failed to read connection profiles: %w", ErrProfilesUnavailable)
+	}
+
+	var sealed map[string]sealedProfile
+	if err := sonic.Unmarshal(data, &sealed); err != nil {
+		return fmt.Errorf("failed to parse connection profiles: %w", err)
+	}
+
+	profiles := make(map[string]Profile, len(sealed))
+	for id, profile := range sealed {
+		config, err := m.openProfile(profile)
+		if err != nil {
+			return err
+		}
+		profiles[id] = Profile{ID: id, Type: profile.Type, Config: config, UpdatedAt: profile.UpdatedAt}
+	}
+	m.profiles = profiles
+
+	if recovered {
+		return m.saveProfiles()
+	}
+	return nil
+}
+
+// saveProfiles encrypts and atomically persists the profiles
+func (m *Manager) saveProfiles() error {
+	sealed := make(map[string]sealedProfile, len(m.profiles))
+	for id, profile := range m.profiles {
+		nonce := make([]byte, m.profileCipher.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return fmt.Errorf("failed to read random bytes: %w", err)
+		}
+		sealed[id] = sealedProfile{
+			ID:   id,
+			Type: profile.Type,
+			// The ID is authenticated along, so sealed configs can't be swapped
+			Sealed:    m.profileCipher.Seal(nonce, nonce, profile.Config, []byte(id)),
+			UpdatedAt: profile.UpdatedAt,
+		}
+	}
+
+	data, err := sonic.ConfigDefault.MarshalIndent(sealed, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal connection profiles: %w", err)
+	}
+	if err := persist.WriteFile(m.profileFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write connection profiles: %w", err)
+	}
+	return nil
+}
+
+// openProfile decrypts the config of a persisted profile
+func (m *Manager) openProfile(profile sealedProfile) (json.RawMessage, error) {
+	size := m.profileCipher.NonceSize()
+	if len(profile.Sealed) < size {
+		return nil, fmt.Errorf("connection profile %s is truncated", profile.ID)
+	}
+	config, err := m.profileCipher.Open(nil, profile.Sealed[:size], profile.Sealed[size:], []byte(profile.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt connection profile %s, was the key changed?", profile.ID)
+	}
+	return config, nil
+}
+
+// profileOf returns the ID of the profile an ingress config references
+func profileOf(cfg Config) string {
+	var fields struct {
+		Profile string `json:"profile"`
+	}
+	if sonic.Unmarshal(cfg.Config, &fields) != nil {
+		return ""
+	}
+	return fields.Profile
+}
+
+// resolve returns cfg with the config of the profile it references merged
+// under its own fields
+func (m *Manager) resolve(cfg Config) (Config, error) {
+	id := profileOf(cfg)
+	if id == "" {
+		return cfg, nil
+	}
+	profile, ok := m.profiles[id]
+	if !ok {
+		return cfg, fmt.Errorf("%w: %s", ErrProfileNotFound, id)
+	}
+	if profile.Type != cfg.Type {
+		return cfg, fmt.Errorf("connection profile %s is for %s ingresses", id, profile.Type)
+	}
+
+	var merged, fields map[string]json.RawMessage
+	if err := sonic.Unmarshal(profile.Config, &merged); err != nil {
+		return cfg, fmt.Errorf("invalid connection profile %s: %w", id, err)
+	}
+	if err := sonic.Unmarshal(cfg.Config, &fields); err != nil {
+		return cfg, err
+	}
+	for name, value := range fields {
+		if name != profileField {
+			merged[name] = value
+		}
+	}
+
+	config, err := sonic.Marshal(merged)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Config = config
+	return cfg, nil
+}
+
+// build creates the ingress of a config, merged with its profile
+func (m *Manager) build(cfg Config) (Ingress, error) {
+	factory, ok := m.factories[cfg.Type]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownIngressType, cfg.Type)
+	}
+
+	resolved, err := m.resolve(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ingress, err := factory(resolved, m.store, m.raftNode, m.logger)
+	if err != nil {
+		return nil, err
+	}
+	if profileOf(cfg) != "" {
+		return &profiledIngress{Ingress: ingress, config: cfg.Config}, nil
+	}
+	return ingress, nil
+}
+
+// info describes a profile (must be called with lock held)
+func (m *Manager) info(profile Profile) ProfileInfo {
+	var fields map[string]json.RawMessage
+	sonic.Unmarshal(profile.Config, &fields)
+
+	info := ProfileInfo{
+		ID:        profile.ID,
+		Type:      profile.Type,
+		Fields:    make([]string, 0, len(fields)),
+		Ingresses: []string{},
+		UpdatedAt: profile.UpdatedAt,
+	}
+	for name := range fields {
+		info.Fields = append(info.Fields, name)
+	}
+	for id, cfg := range m.configs {
+		if profileOf(cfg) == profile.ID {
+			info.Ingresses = append(info.Ingresses, id)
+		}
+	}
+	sort.Strings(info.Fields)
+	sort.Strings(info.Ingresses)
+	return info
+}
+
+// ListProfiles returns the connection profiles, ordered by ID
+func (m *Manager) ListProfiles() []ProfileInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]ProfileInfo, 0, len(m.profiles))
+	for _, profile := range m.profiles {
+		result = append(result, m.info(profile))
+	}
+	sort.Slice(result, func(a, b int) bool {
+		return result[a].ID < result[b].ID
+	})
+	return result
+}
+
+// GetProfile returns a connection profile
+func (m *Manager) GetProfile(id string) (ProfileInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	profile, ok := m.profiles[id]
+	if !ok {
+		return ProfileInfo{}, fmt.Errorf("%w: %s", ErrProfileNotFound, id)
+	}
+	return m.info(profile), nil
+}
+
+// PutProfile creates or replaces a connection profile. The ingresses using
+// it are recreated with the new config, and restarted unless they were
+// stopped.
+func (m *Manager) PutProfile(id, ingressType string, config json.RawMessage) (ProfileInfo, error) {
+	m.mu.Lock()
+
+	if m.profileCipher == nil {
+		m.mu.Unlock()
+		return ProfileInfo{}, ErrProfilesUnavailable
+	}
+	if _, ok := m.factories[ingressType]; !ok {
+		m.mu.Unlock()
+		return ProfileInfo{}, fmt.Errorf("%w: %s", ErrUnknownIngressType, ingressType)
+	}
+	var fields map[string]json.RawMessage
+	if err := sonic.Unmarshal(config, &fields); err != nil || fields == nil {
+		m.mu.Unlock()
+		return ProfileInfo{}, fmt.Errorf("%w: profile config must be an object", ErrInvalidConfig)
+	}
+	if _, ok := fields[profileField]; ok {
+		m.mu.Unlock()
+		return ProfileInfo{}, fmt.Errorf("%w: profiles cannot reference profiles", ErrInvalidConfig)
+	}
+
+	previous, existed := m.profiles[id]
+	m.profiles[id] = Profile{ID: id, Type: ingressType, Config: config, UpdatedAt: time.Now().UTC()}
+
+	// Build every replacement first, so an invalid profile changes nothing
+	replacements := make(map[string]Ingress)
+	for ingressID, cfg := range m.configs {
+		if profileOf(cfg) != id {
+			continue
+		}
+		ingress, err := m.build(cfg)
+		if err != nil {
+			if existed {
+				m.profiles[id] = previous
+			} else {
+				delete(m.profiles, id)
+			}
+			m.mu.Unlock()
+			return ProfileInfo{}, fmt.Errorf("%w: ingress %s: %w", ErrInvalidConfig, ingressID, err)
+		}
+		replacements[ingressID] = ingress
+	}
+
+	if err := m.saveProfiles(); err != nil {
+		if existed {
+			m.profiles[id] = previous
+		} else {
+			delete(m.profiles, id)
+		}
+		m.mu.Unlock()
+		return ProfileInfo{}, err
+	}
+
+	var restart []Ingress
+	for ingressID, ingress := range replacements {
+		current := m.ingresses[ingressID]
+		if current != nil {
+			if current.Status() != StatusStopped {
+				restart = append(restart, ingress)
+			}
+			if err := current.Stop(); err != nil {
+				m.logger.Warn("Error stopping ingress for a profile change",
+					zap.String("id", ingressID),
+					zap.Error(err))
+			}
+		}
+		m.ingresses[ingressID] = ingress
+	}
+	info := m.info(m.profiles[id])
+	m.mu.Unlock()
+
+	// Connecting may take a while, so ingresses start without the lock
+	for _, ingress := range restart {
+		if err := ingress.Start(context.Background()); err != nil {
+			m.logger.Error("Failed to restart ingress with changed profile",
+				zap.String("id", ingress.ID()),
+				zap.String("profile", id),
+				zap.Error(err))
+		}
+	}
+
+	return info, nil
+}
+
+// DeleteProfile removes a connection profile no ingress uses
+func (m *Manager) DeleteProfile(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	profile, ok := m.profiles[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrProfileNotFound, id)
+	}
+	if users := m.info(profile).Ingresses; len(users) > 0 {
+		return fmt.Errorf("%w: %s is used by %v", ErrProfileInUse, id, users)
+	}
+
+	delete(m.profiles, id)
+	if err := m.saveProfiles(); err != nil {
+		m.profiles[id] = profile
+		return err
+	}
+	return nil
+}
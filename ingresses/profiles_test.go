@@ -0,0 +1,82 @@
+package ingresses
+
+import (
+	"bright/raft"
+	"bright/store"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestProfiles(t *testing.T) {
+	dir := t.TempDir()
+	factory := func(cfg Config, store *store.IndexStore, raftNode *raft.RaftNode, logger *zap.Logger) (Ingress, error) {
+		return nil, nil
+	}
+	newManager := func(key string) *Manager {
+		m := NewManager(dir, nil, nil, zap.NewNop())
+		m.RegisterFactory("postgres", factory)
+		if err := m.SetProfileKey(key); err != nil {
+			t.Fatalf("Failed to set profile key: %v", err)
+		}
+		if err := m.Load(); err != nil {
+			t.Fatalf("Failed to load: %v", err)
+		}
+		return m
+	}
+
+	m := newManager("")
+	if _, err := m.PutProfile("main", "postgres", json.RawMessage(`{"dsn":"postgres://u:p@db/shop"}`)); !errors.Is(err, ErrProfilesUnavailable) {
+		t.Fatalf("Expected profiles to need a key, got %v", err)
+	}
+
+	m = newManager("secret")
+	info, err := m.PutProfile("main", "postgres", json.RawMessage(`{"dsn":"postgres://u:p@db/shop","batch_size":500}`))
+	if err != nil {
+		t.Fatalf("Failed to put profile: %v", err)
+	}
+	if strings.Join(info.Fields, ",") != "batch_size,dsn" {
+		t.Errorf("Unexpected profile fields %v", info.Fields)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "profiles.json"))
+	if err != nil || strings.Contains(string(data), "u:p@db") {
+		t.Fatalf("Expected the profile to be stored encrypted: %s (%v)", data, err)
+	}
+
+	// Profiles survive a restart with the same key, and the ingress's own
+	// fields win
+	m = newManager("secret")
+	resolved, err := m.resolve(Config{Type: "postgres", Config: json.RawMessage(`{"profile":"main","table":"products","batch_size":100}`)})
+	if err != nil {
+		t.Fatalf("Failed to resolve: %v", err)
+	}
+	var fields map[string]any
+	json.Unmarshal(resolved.Config, &fields)
+	if fields["dsn"] != "postgres://u:p@db/shop" || fields["batch_size"] != float64(100) || fields["table"] != "products" || fields["profile"] != nil {
+		t.Errorf("Unexpected resolved config %s", resolved.Config)
+	}
+
+	if _, err := m.resolve(Config{Type: "postgres", Config: json.RawMessage(`{"profile":"missing"}`)}); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("Expected a missing profile error, got %v", err)
+	}
+
+	m.configs["products-pg"] = Config{ID: "products-pg", Type: "postgres", Config: json.RawMessage(`{"profile":"main"}`)}
+	if err := m.DeleteProfile("main"); !errors.Is(err, ErrProfileInUse) {
+		t.Errorf("Expected a used profile to be kept, got %v", err)
+	}
+
+	// A different key can't read them, and doesn't overwrite them
+	m = newManager("other")
+	if len(m.ListProfiles()) != 0 {
+		t.Error("Expected profiles to be unreadable with another key")
+	}
+	if _, err := m.PutProfile("main", "postgres", json.RawMessage(`{}`)); !errors.Is(err, ErrProfilesUnavailable) {
+		t.Errorf("Expected profiles to be unavailable, got %v", err)
+	}
+}
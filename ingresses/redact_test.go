@@ -0,0 +1,66 @@
+package ingresses
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// configuredIngress is a fake ingress with a config
+type configuredIngress struct {
+	fakeIngress
+	config json.RawMessage
+}
+
+func (c *configuredIngress) Config() json.RawMessage { return c.config }
+
+// TestToInfoRedactsCredentials tests that ingress responses, which read
+// keys may list, never carry database credentials
+func TestToInfoRedactsCredentials(t *testing.T) {
+	ingress := &configuredIngress{
+		fakeIngress: fakeIngress{id: "orders"},
+		config: json.RawMessage(`{"dsn": "postgres://bright:s3cret@db:5432/shop?sslmode=disable", "table": "orders",
+			"tls": {"key_password": "hunter2"}}`),
+	}
+
+	info := ToInfo(ingress)
+	data, err := json.Marshal(info)
+	if err != nil {
+		t.Fatalf("Failed to encode info: %v", err)
+	}
+	if strings.Contains(string(data), "s3cret") || strings.Contains(string(data), "hunter2") {
+		t.Fatalf("Expected credentials to be redacted, got %s", data)
+	}
+
+	var config struct {
+		DSN   string `json:"dsn"`
+		Table string `json:"table"`
+	}
+	if err := json.Unmarshal(info.Config, &config); err != nil {
+		t.Fatalf("Failed to decode config: %v", err)
+	}
+	if config.DSN != "postgres://bright:REDACTED@db:5432/shop?sslmode=disable" || config.Table != "orders" {
+		t.Errorf("Expected the rest of the config to be kept, got %+v", config)
+	}
+	if string(ingress.Config()) == string(info.Config) {
+		t.Error("Expected the ingress's own config to be left as it is")
+	}
+}
+
+func TestRedactDSN(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{"postgres://bright:s3cret@db/shop", "postgres://bright:REDACTED@db/shop"},
+		{"postgres://db/shop?password=s3cret&user=bright", "postgres://db/shop?password=REDACTED&user=bright"},
+		{"postgres://bright@db/shop", "postgres://bright@db/shop"},
+		{"host=db user=bright password=s3cret dbname=shop", "host=db user=bright password=REDACTED dbname=shop"},
+		{"host=db password='s3 cret' dbname=shop", "host=db password=REDACTED dbname=shop"},
+	}
+	for _, tt := range tests {
+		if got := RedactDSN(tt.dsn); got != tt.want {
+			t.Errorf("RedactDSN(%q) = %q, want %q", tt.dsn, got, tt.want)
+		}
+	}
+}
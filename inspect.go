@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bright/migrate"
+	"bright/persist"
+	"bright/raft"
+	"bright/store"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/bytedance/sonic"
+)
+
+type InspectCmd struct {
+	Data    string `help:"Path to data directory" default:"./data" type:"path"`
+	RaftDir string `help:"Path to Raft directory (defaults to <data>/raft)" type:"path"`
+	JSON    bool   `help:"Print the report as JSON"`
+}
+
+// inspectReport is the combined offline report of a data directory
+type inspectReport struct {
+	Data             *store.DataDirReport `json:"data"`
+	FormatVersion    int                  `json:"formatVersion"`
+	FormatError      string               `json:"formatError,omitempty"`
+	IngressConfig    string               `json:"ingressConfig"`
+	IngressError     string               `json:"ingressError,omitempty"`
+	IngressRecovered bool                 `json:"ingressRecovered,omitempty"`
+	IngressCount     int                  `json:"ingressCount"`
+	Raft             *raft.StoreReport    `json:"raft"`
+	Problems         []string             `json:"problems"`
+}
+
+func (i *InspectCmd) Run() error {
+	return i.run(os.Stdout)
+}
+
+// run inspects the data directory and writes the report to out
+func (i *InspectCmd) run(out io.Writer) error {
+	dataReport, err := store.Inspect(i.Data)
+	if err != nil {
+		return fmt.Errorf("failed to inspect data directory: %w", err)
+	}
+
+	raftDir := i.RaftDir
+	if raftDir == "" {
+		raftDir = filepath.Join(i.Data, "raft")
+	}
+
+	report := &inspectReport{
+		Data:          dataReport,
+		IngressConfig: filepath.Join(i.Data, "ingresses.json"),
+		Raft:          raft.InspectDir(raftDir),
+		Problems:      []string{},
+	}
+
+	if version, _, err := migrate.ReadVersion(i.Data); err != nil {
+		report.FormatError = err.Error()
+	} else {
+		report.FormatVersion = version
+	}
+
+	if data, recovered, err := persist.ReadFile(report.IngressConfig); err == nil {
+		report.IngressRecovered = recovered
+		var ingressConfigs map[string]json.RawMessage
+		if err := sonic.Unmarshal(data, &ingressConfigs); err != nil {
+			report.IngressError = err.Error()
+		}
+		report.IngressCount = len(ingressConfigs)
+	} else if !os.IsNotExist(err) {
+		report.IngressError = err.Error()
+	}
+
+	report.collectProblems()
+
+	if i.JSON {
+		data, err := sonic.ConfigDefault.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(data))
+	} else {
+		report.print(out)
+	}
+
+	if len(report.Problems) > 0 {
+		return fmt.Errorf("found %d problem(s)", len(report.Problems))
+	}
+	return nil
+}
+
+// collectProblems derives the list of problems found in the report
+func (r *inspectReport) collectProblems() {
+	if r.FormatError != "" {
+		r.Problems = append(r.Problems, fmt.Sprintf("format.json is unreadable: %s", r.FormatError))
+	} else if r.FormatVersion != migrate.CurrentVersion {
+		r.Problems = append(r.Problems, fmt.Sprintf("format version %d is not the supported version %d; run bright migrate", r.FormatVersion, migrate.CurrentVersion))
+	}
+	if r.Data.ConfigError != "" {
+		r.Problems = append(r.Problems, fmt.Sprintf("configs.json is unreadable: %s", r.Data.ConfigError))
+	}
+	for _, index := range r.Data.Indexes {
+		switch index.Status {
+		case store.InspectStatusMissing:
+			r.Problems = append(r.Problems, fmt.Sprintf("index %s is configured but its directory is missing", index.ID))
+		case store.InspectStatusUnreadable:
+			r.Problems = append(r.Problems, fmt.Sprintf("index %s cannot be opened: %s", index.ID, index.Error))
+		}
+		for _, mismatch := range index.Mismatches {
+			r.Problems = append(r.Problems, fmt.Sprintf("index %s does not match configs.json: %s", index.ID, mismatch))
+		}
+	}
+	for _, dir := range r.Data.OrphanDirectories {
+		r.Problems = append(r.Problems, fmt.Sprintf("directory %s contains an index with no configuration", dir))
+	}
+	if r.Data.ConfigRecovered {
+		r.Problems = append(r.Problems, "configs.json failed its checksum; the previous generation will be used")
+	}
+	if r.IngressRecovered {
+		r.Problems = append(r.Problems, "ingresses.json failed its checksum; the previous generation will be used")
+	}
+	if r.IngressError != "" {
+		r.Problems = append(r.Problems, fmt.Sprintf("ingresses.json is unreadable: %s", r.IngressError))
+	}
+	if r.Raft.Present && !r.Raft.Healthy() {
+		r.Problems = append(r.Problems, "raft stores are unhealthy")
+	}
+}
+
+// print writes the report to out in a human readable form
+func (r *inspectReport) print(out io.Writer) {
+	fmt.Fprintf(out, "Data directory: %s (format version %d)\n\n", r.Data.DataDir, r.FormatVersion)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INDEX\tPRIMARY KEY\tDOCS\tSIZE\tSTATUS")
+	for _, index := range r.Data.Indexes {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", index.ID, index.PrimaryKey, index.DocCount, formatBytes(index.SizeBytes), index.Status)
+	}
+	w.Flush()
+
+	fmt.Fprintf(out, "\nIngresses: %d configured\n", r.IngressCount)
+
+	fmt.Fprintf(out, "\nRaft directory: %s\n", r.Raft.Dir)
+	if !r.Raft.Present {
+		fmt.Fprintln(out, "  not present (single-node mode)")
+	} else {
+		fmt.Fprintf(out, "  log store:    %s (%s) entries %d..%d\n", r.Raft.LogStore.Path, formatBytes(r.Raft.LogStore.SizeBytes), r.Raft.FirstIndex, r.Raft.LastIndex)
+		if r.Raft.LogStore.Error != "" {
+			fmt.Fprintf(out, "    error: %s\n", r.Raft.LogStore.Error)
+		}
+		fmt.Fprintf(out, "  stable store: %s (%s) term %d\n", r.Raft.StableStore.Path, formatBytes(r.Raft.StableStore.SizeBytes), r.Raft.CurrentTerm)
+		if r.Raft.StableStore.Error != "" {
+			fmt.Fprintf(out, "    error: %s\n", r.Raft.StableStore.Error)
+		}
+		fmt.Fprintf(out, "  snapshots:    %d\n", len(r.Raft.Snapshots))
+		for _, snapshot := range r.Raft.Snapshots {
+			fmt.Fprintf(out, "    %s index=%d term=%d size=%s\n", snapshot.ID, snapshot.Index, snapshot.Term, formatBytes(snapshot.Size))
+		}
+		if r.Raft.Error != "" {
+			fmt.Fprintf(out, "  error: %s\n", r.Raft.Error)
+		}
+	}
+
+	if len(r.Problems) > 0 {
+		fmt.Fprintln(out, "\nProblems:")
+		for _, problem := range r.Problems {
+			fmt.Fprintf(out, "  - %s\n", problem)
+		}
+	}
+}
+
+// formatBytes renders a byte count using binary units
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bright/migrate"
+	"bright/models"
+	"bright/persist"
+	"bright/store"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// newInspectFixture creates a data directory holding one small index
+func newInspectFixture(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	s, err := store.New(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	if err := s.CreateIndex(&models.IndexConfig{ID: "products", PrimaryKey: "sku"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	documents := []map[string]any{{"sku": "a"}, {"sku": "b"}, {"sku": "c"}}
+	if err := s.AddDocuments("products", "", documents); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+	if err := migrate.WriteVersion(dir, migrate.CurrentVersion); err != nil {
+		t.Fatalf("Failed to write format version: %v", err)
+	}
+	return dir
+}
+
+// TestInspect tests the report of a healthy data directory
+func TestInspect(t *testing.T) {
+	dir := newInspectFixture(t)
+
+	var out bytes.Buffer
+	if err := (&InspectCmd{Data: dir}).run(&out); err != nil {
+		t.Fatalf("Expected no problems, got %v\n%s", err, out.String())
+	}
+	report := out.String()
+	if !strings.Contains(report, "Data directory: "+dir) {
+		t.Errorf("Expected the data directory in the report:\n%s", report)
+	}
+	if !regexp.MustCompile(`(?m)^products\s+sku\s+3\s+.+\s+ok$`).MatchString(report) {
+		t.Errorf("Expected a row for products with 3 documents:\n%s", report)
+	}
+	if !strings.Contains(report, "Ingresses: 0 configured") || !strings.Contains(report, "not present (single-node mode)") {
+		t.Errorf("Expected no ingresses and no Raft directory:\n%s", report)
+	}
+	if strings.Contains(report, "Problems:") {
+		t.Errorf("Expected no problems:\n%s", report)
+	}
+
+	out.Reset()
+	if err := (&InspectCmd{Data: dir, JSON: true}).run(&out); err != nil {
+		t.Fatalf("Expected no problems, got %v", err)
+	}
+	var parsed inspectReport
+	if err := json.Unmarshal(out.Bytes(), &parsed); err != nil {
+		t.Fatalf("Failed to parse JSON report: %v\n%s", err, out.String())
+	}
+	if len(parsed.Data.Indexes) != 1 || parsed.Data.Indexes[0].DocCount != 3 || parsed.FormatVersion != migrate.CurrentVersion {
+		t.Errorf("Unexpected JSON report: %s", out.String())
+	}
+}
+
+// TestInspectReportsProblems tests that a missing index directory is
+// reported and fails the command
+func TestInspectReportsProblems(t *testing.T) {
+	dir := newInspectFixture(t)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to list data directory: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && strings.Contains(entry.Name(), "products") {
+			os.RemoveAll(filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	var out bytes.Buffer
+	err = (&InspectCmd{Data: dir}).run(&out)
+	if err == nil || !strings.Contains(err.Error(), "1 problem") {
+		t.Errorf("Expected one problem, got %v", err)
+	}
+	if !strings.Contains(out.String(), "index products is configured but its directory is missing") {
+		t.Errorf("Expected the missing index in the report:\n%s", out.String())
+	}
+}
+
+// TestInspectReportsConfigMismatch tests that an index whose configuration
+// no longer matches its index type and mapping on disk is reported
+func TestInspectReportsConfigMismatch(t *testing.T) {
+	dir := newInspectFixture(t)
+	configFile := filepath.Join(dir, "configs.json")
+	data, _, err := persist.ReadFile(configFile)
+	if err != nil {
+		t.Fatalf("Failed to read configs.json: %v", err)
+	}
+	var configs map[string]*models.IndexConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		t.Fatalf("Failed to parse configs.json: %v", err)
+	}
+	configs["products"].IndexType = store.IndexTypeUpsideDown
+	configs["products"].Fields = map[string]models.FieldSettings{"sku": {Exact: true}}
+	if data, err = json.Marshal(configs); err != nil {
+		t.Fatalf("Failed to marshal configs.json: %v", err)
+	}
+	if err := persist.WriteFile(configFile, data, 0644); err != nil {
+		t.Fatalf("Failed to write configs.json: %v", err)
+	}
+
+	var out bytes.Buffer
+	err = (&InspectCmd{Data: dir}).run(&out)
+	if err == nil || !strings.Contains(err.Error(), "2 problem") {
+		t.Errorf("Expected two problems, got %v\n%s", err, out.String())
+	}
+	report := out.String()
+	if !regexp.MustCompile(`(?m)^products\s+sku\s+3\s+.+\s+mismatch$`).MatchString(report) {
+		t.Errorf("Expected products to be reported as mismatched:\n%s", report)
+	}
+	for _, problem := range []string{
+		"index products does not match configs.json: index type is scorch, configured upside_down",
+		"index products does not match configs.json: mapping differs from the configured one in [analysis default_mapping]",
+	} {
+		if !strings.Contains(report, problem) {
+			t.Errorf("Expected %q in the report:\n%s", problem, report)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+// Package jobs throttles background work (reindexes, resyncs, compactions,
+// archives) relative to foreground searches and writes.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Priority is how much a background job yields to foreground requests
+type Priority string
+
+const (
+	// PriorityLow waits up to the maximum yield while foreground requests
+	// are in flight
+	PriorityLow Priority = "low"
+
+	// PriorityNormal waits up to a tenth of the maximum yield
+	PriorityNormal Priority = "normal"
+
+	// PriorityHigh never waits for foreground requests
+	PriorityHigh Priority = "high"
+)
+
+// Kinds of background jobs
+const (
+	KindReindex    = "reindex"
+	KindCompaction = "compaction"
+	KindResync     = "resync"
+	KindSnapshot   = "snapshot"
+	KindImport     = "import"
+)
+
+// DefaultMaxYield is how long a low priority job waits for foreground
+// requests between units of work when no maximum is set
+const DefaultMaxYield = time.Second
+
+// yieldPoll is how often a yielding job checks for foreground requests
+const yieldPoll = 5 * time.Millisecond
+
+// DefaultPriorities are the priorities of job kinds that are not normal
+var DefaultPriorities = map[string]Priority{
+	KindCompaction: PriorityLow,
+	KindSnapshot:   PriorityLow,
+}
+
+// ErrNotFound is returned for unknown job IDs
+var ErrNotFound = errors.New("background job not found")
+
+// ParsePriority checks a priority name
+func ParsePriority(name string) (Priority, error) {
+	switch priority := Priority(name); priority {
+	case PriorityLow, PriorityNormal, PriorityHigh:
+		return priority, nil
+	}
+	return "", fmt.Errorf("unknown priority %q, must be one of: low, normal, high", name)
+}
+
+// ParsePriorities checks the priorities of job kinds, such as
+// {"compaction": "low"}
+func ParsePriorities(priorities map[string]string) (map[string]Priority, error) {
+	parsed := make(map[string]Priority, len(priorities))
+	for kind, name := range priorities {
+		switch kind {
+		case KindReindex, KindCompaction, KindResync, KindSnapshot, KindImport:
+		default:
+			return nil, fmt.Errorf("unknown background job kind %q", kind)
+		}
+		priority, err := ParsePriority(name)
+		if err != nil {
+			return nil, err
+		}
+		parsed[kind] = priority
+	}
+	return parsed, nil
+}
+
+// maxYield returns how long a job of the priority waits for foreground
+// requests, given the maximum
+func (p Priority) maxYield(max time.Duration) time.Duration {
+	switch p {
+	case PriorityLow:
+		return max
+	case PriorityHigh:
+		return 0
+	default:
+		return max / 10
+	}
+}
+
+// Options configure a Manager
+type Options struct {
+	// Priorities of job kinds, over DefaultPriorities
+	Priorities map[string]Priority
+
+	// MaxYield between units of work (DefaultMaxYield if zero)
+	MaxYield time.Duration
+}
+
+// Info describes a running job
+type Info struct {
+	ID       string   `json:"id"`
+	Kind     string   `json:"kind"`
+	Target   string   `json:"target"`
+	Priority Priority `json:"priority"`
+
+	// RateLimit caps the units of work per second (0 is unlimited)
+	RateLimit float64 `json:"rateLimit,omitempty"`
+	Paused    bool    `json:"paused,omitempty"`
+
+	StartedAt time.Time `json:"startedAt"`
+
+	// Units of work done so far: documents, or bytes for snapshots
+	Units uint64 `json:"units"`
+
+	// ThrottledMs is the time spent paused, rate limited or yielding
+	ThrottledMs int64 `json:"throttledMs"`
+}
+
+// Update changes the throttling of a running job; nil fields are kept
+type Update struct {
+	Priority  *Priority `json:"priority"`
+	RateLimit *float64  `json:"rateLimit"`
+	Paused    *bool     `json:"paused"`
+}
+
+// Manager keeps the running background jobs of this node and the number of
+// foreground requests they yield to
+type Manager struct {
+	priorities map[string]Priority
+	maxYield   time.Duration
+
+	foreground atomic.Int64
+
+	jobs   map[string]*Job
+	nextID uint64
+	mu     sync.Mutex
+}
+
+// NewManager creates a job manager
+func NewManager(options Options) *Manager {
+	m := &Manager{jobs: make(map[string]*Job)}
+	m.Configure(options)
+	return m
+}
+
+// Configure replaces the options of the manager. Running jobs keep their
+// priority.
+func (m *Manager) Configure(options Options) {
+	priorities := make(map[string]Priority, len(DefaultPriorities)+len(options.Priorities))
+	for kind, priority := range DefaultPriorities {
+		priorities[kind] = priority
+	}
+	for kind, priority := range options.Priorities {
+		priorities[kind] = priority
+	}
+
+	maxYield := options.MaxYield
+	if maxYield <= 0 {
+		maxYield = DefaultMaxYield
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.priorities = priorities
+	m.maxYield = maxYield
+}
+
+// Start registers a job of a kind working on target (an index or ingress).
+// Done must be called when it ends.
+func (m *Manager) Start(kind, target string) *Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	priority, ok := m.priorities[kind]
+	if !ok {
+		priority = PriorityNormal
+	}
+
+	m.nextID++
+	now := time.Now()
+	j := &Job{
+		manager: m,
+		info: Info{
+			ID:        strconv.FormatUint(m.nextID, 10),
+			Kind:      kind,
+			Target:    target,
+			Priority:  priority,
+			StartedAt: now,
+		},
+		rateSince: now,
+		changed:   make(chan struct{}),
+	}
+	m.jobs[j.info.ID] = j
+	return j
+}
+
+// List returns the running jobs, oldest first
+func (m *Manager) List() []Info {
+	m.mu.Lock()
+	jobs := make([]*Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		jobs = append(jobs, j)
+	}
+	m.mu.Unlock()
+
+	infos := make([]Info, 0, len(jobs))
+	for _, j := range jobs {
+		infos = append(infos, j.Info())
+	}
+	sort.Slice(infos, func(a, b int) bool {
+		return infos[a].StartedAt.Before(infos[b].StartedAt)
+	})
+	return infos
+}
+
+// Get returns a running job
+func (m *Manager) Get(id string) (Info, error) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	m.mu.Unlock()
+	if !ok {
+		return Info{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return j.Info(), nil
+}
+
+// Update changes the priority, rate limit or pause state of a running job
+func (m *Manager) Update(id string, update Update) (Info, error) {
+	if update.Priority != nil {
+		if _, err := ParsePriority(string(*update.Priority)); err != nil {
+			return Info{}, err
+		}
+	}
+	if update.RateLimit != nil && *update.RateLimit < 0 {
+		return Info{}, fmt.Errorf("rateLimit must not be negative")
+	}
+
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	m.mu.Unlock()
+	if !ok {
+		return Info{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	j.mu.Lock()
+	if update.Priority != nil {
+		j.info.Priority = *update.Priority
+	}
+	if update.RateLimit != nil {
+		j.info.RateLimit = *update.RateLimit
+		j.rateSince = time.Now()
+		j.rateUnits = 0
+	}
+	if update.Paused != nil {
+		j.info.Paused = *update.Paused
+	}
+
+	// Wake the job up, so it sees the new settings
+	close(j.changed)
+	j.changed = make(chan struct{})
+	info := j.info
+	j.mu.Unlock()
+	return info, nil
+}
+
+// Foreground marks a foreground request as in flight until the returned
+// function is called
+func (m *Manager) Foreground() func() {
+	m.foreground.Add(1)
+	return func() { m.foreground.Add(-1) }
+}
+
+// Foregrounds returns the number of foreground requests in flight
+func (m *Manager) Foregrounds() int64 {
+	return m.foreground.Load()
+}
+
+// Middleware counts searches and document reads and writes as foreground
+// requests
+func (m *Manager) Middleware(c *fiber.Ctx) error {
+	if !isForeground(c.Path()) {
+		return c.Next()
+	}
+	done := m.Foreground()
+	defer done()
+	return c.Next()
+}
+
+// isForeground returns true for the paths of searches and document requests
+func isForeground(path string) bool {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	switch segments[len(segments)-1] {
+	case "_search", "_bulk", "graphql":
+		return true
+	}
+	if segments[0] == "indexes" && len(segments) >= 3 {
+		switch segments[2] {
+		case "searches", "documents", "duplicates", "percolate":
+			return true
+		}
+	}
+	return false
+}
+
+// Job is a running background job
+type Job struct {
+	manager *Manager
+	info    Info
+
+	// Units done since the rate limit was set, for pacing
+	rateSince time.Time
+	rateUnits uint64
+
+	// changed is closed when the job is updated
+	changed chan struct{}
+	mu      sync.Mutex
+}
+
+// Info returns the state of the job
+func (j *Job) Info() Info {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.info
+}
+
+// Wait records units of work done and blocks while the job is paused, ahead
+// of its rate limit, or yielding to foreground requests. It returns early
+// with the error of ctx.
+func (j *Job) Wait(ctx context.Context, units int) error {
+	start := time.Now()
+	defer func() {
+		j.mu.Lock()
+		j.info.ThrottledMs += time.Since(start).Milliseconds()
+		j.mu.Unlock()
+	}()
+
+	j.mu.Lock()
+	j.info.Units += uint64(units)
+	j.rateUnits += uint64(units)
+	j.mu.Unlock()
+
+	for {
+		j.mu.Lock()
+		paused, changed := j.info.Paused, j.changed
+		var delay time.Duration
+		if j.info.RateLimit > 0 {
+			due := j.rateSince.Add(time.Duration(float64(j.rateUnits) / j.info.RateLimit * float64(time.Second)))
+			delay = time.Until(due)
+		}
+		j.mu.Unlock()
+
+		if !paused && delay <= 0 {
+			break
+		}
+
+		var timer <-chan time.Time
+		if !paused {
+			timer = time.After(delay)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		case <-timer:
+		}
+	}
+
+	j.manager.mu.Lock()
+	maxYield := j.manager.maxYield
+	j.manager.mu.Unlock()
+
+	deadline := time.Now().Add(j.Info().Priority.maxYield(maxYield))
+	for j.manager.foreground.Load() > 0 && time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(yieldPoll):
+		}
+	}
+	return nil
+}
+
+// Done removes the job from the running jobs
+func (j *Job) Done() {
+	j.manager.mu.Lock()
+	defer j.manager.mu.Unlock()
+	delete(j.manager.jobs, j.info.ID)
+}
@@ -0,0 +1,99 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJobThrottling(t *testing.T) {
+	m := NewManager(Options{Priorities: map[string]Priority{KindReindex: PriorityLow}, MaxYield: 50 * time.Millisecond})
+	job := m.Start(KindReindex, "products")
+	defer job.Done()
+
+	if info := job.Info(); info.Priority != PriorityLow || info.Kind != KindReindex {
+		t.Fatalf("Unexpected job: %+v", info)
+	}
+
+	// A low priority job yields to foreground requests up to the maximum
+	done := m.Foreground()
+	start := time.Now()
+	if err := job.Wait(context.Background(), 10); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected the job to yield, waited %v", elapsed)
+	}
+	done()
+
+	high := PriorityHigh
+	if _, err := m.Update(job.Info().ID, Update{Priority: &high}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	done = m.Foreground()
+	start = time.Now()
+	job.Wait(context.Background(), 10)
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("Expected a high priority job not to yield, waited %v", elapsed)
+	}
+	done()
+
+	// Paused jobs wait until resumed
+	paused := true
+	if _, err := m.Update(job.Info().ID, Update{Paused: &paused}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	waited := make(chan error)
+	go func() { waited <- job.Wait(context.Background(), 1) }()
+	select {
+	case <-waited:
+		t.Fatal("Expected a paused job to wait")
+	case <-time.After(20 * time.Millisecond):
+	}
+	paused = false
+	m.Update(job.Info().ID, Update{Paused: &paused})
+	if err := <-waited; err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+
+	// Rate limits pace the units of work
+	rate := 1000.0
+	m.Update(job.Info().ID, Update{RateLimit: &rate})
+	start = time.Now()
+	job.Wait(context.Background(), 50)
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected 50 units at 1000/s to take 50ms, took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := job.Wait(ctx, 1000); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled wait, got %v", err)
+	}
+
+	info := job.Info()
+	if info.Units != 1071 || info.ThrottledMs == 0 {
+		t.Errorf("Unexpected progress: %+v", info)
+	}
+	if len(m.List()) != 1 {
+		t.Errorf("Expected one running job, got %+v", m.List())
+	}
+}
+
+func TestParsePriorities(t *testing.T) {
+	parsed, err := ParsePriorities(map[string]string{"compaction": "high"})
+	if err != nil || parsed[KindCompaction] != PriorityHigh {
+		t.Fatalf("Unexpected priorities %v (%v)", parsed, err)
+	}
+	for _, invalid := range []map[string]string{{"compaction": "urgent"}, {"backup": "low"}} {
+		if _, err := ParsePriorities(invalid); err == nil {
+			t.Errorf("Expected an error for %v", invalid)
+		}
+	}
+
+	m := NewManager(Options{})
+	if _, err := m.Update("42", Update{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bright/keys"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+type KeysCmd struct {
+	Create KeysCreateCmd `cmd:"" help:"Create a scoped API key"`
+	List   KeysListCmd   `cmd:"" help:"List API keys"`
+	Revoke KeysRevokeCmd `cmd:"" help:"Revoke an API key"`
+}
+
+type KeysCreateCmd struct {
+	RemoteFlags `embed:""`
+
+	Name  string   `help:"Human readable key name"`
+	Scope []string `help:"Scope granted to the key (search, read, write, admin); repeatable" required:"" enum:"search,read,write,admin"`
+}
+
+func (k *KeysCreateCmd) Run() error {
+	scopes := make([]keys.Scope, 0, len(k.Scope))
+	for _, scope := range k.Scope {
+		scopes = append(scopes, keys.Scope(scope))
+	}
+
+	body, err := k.call(http.MethodPost, "/keys", map[string]any{
+		"name":   k.Name,
+		"scopes": scopes,
+	})
+	if err != nil {
+		return err
+	}
+	printJSON(body)
+	return nil
+}
+
+type KeysListCmd struct {
+	RemoteFlags `embed:""`
+}
+
+func (k *KeysListCmd) Run() error {
+	body, err := k.call(http.MethodGet, "/keys", nil)
+	if err != nil {
+		return err
+	}
+	printJSON(body)
+	return nil
+}
+
+type KeysRevokeCmd struct {
+	RemoteFlags `embed:""`
+
+	ID string `arg:"" help:"ID of the key to revoke"`
+}
+
+func (k *KeysRevokeCmd) Run() error {
+	if _, err := k.call(http.MethodDelete, "/keys/"+url.PathEscape(k.ID), nil); err != nil {
+		return err
+	}
+	fmt.Printf("Revoked key %s\n", k.ID)
+	return nil
+}
+
+type KeygenCmd struct {
+	Bytes int `help:"Number of random bytes in the key" default:"32"`
+}
+
+func (k *KeygenCmd) Run() error {
+	key, err := keys.Generate(k.Bytes)
+	if err != nil {
+		return err
+	}
+	fmt.Println(key)
+	return nil
+}
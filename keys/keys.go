@@ -0,0 +1,257 @@
+package keys
+
+import (
+	"bright/persist"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+)
+
+// Scope restricts what an API key is allowed to do
+type Scope string
+
+const (
+	ScopeSearch Scope = "search" // Run searches
+	ScopeRead   Scope = "read"   // Read indexes, documents and ingresses
+	ScopeWrite  Scope = "write"  // Add, update and delete documents
+	ScopeAdmin  Scope = "admin"  // Everything, including index, ingress, cluster and key management
+	ScopeHidden Scope = "hidden" // Receive hidden attributes when requested
+)
+
+// ValidScope returns true if s is a known scope
+func ValidScope(s Scope) bool {
+	switch s {
+	case ScopeSearch, ScopeRead, ScopeWrite, ScopeAdmin, ScopeHidden:
+		return true
+	}
+	return false
+}
+
+// Key is a persisted API key. Only the SHA-256 hash of the secret is stored.
+type Key struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	Prefix    string    `json:"prefix"`
+	Hash      string    `json:"hash"`
+	Scopes    []Scope   `json:"scopes"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Allows returns true if the key grants the given scope
+func (k *Key) Allows(scope Scope) bool {
+	for _, s := range k.Scopes {
+		if s == ScopeAdmin || s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsAny returns true if the key grants any of the given scopes
+func (k *Key) AllowsAny(scopes []Scope) bool {
+	for _, scope := range scopes {
+		if k.Allows(scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// Generate returns a new random secret suitable for API or master keys
+func Generate(byteLength int) (string, error) {
+	if byteLength < 16 {
+		return "", fmt.Errorf("key length must be at least 16 bytes")
+	}
+	buf := make([]byte, byteLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Hash returns the hex-encoded SHA-256 hash of a secret
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewKey generates a new key with the given name and scopes.
+// The plaintext secret is returned alongside and is never stored.
+func NewKey(name string, scopes []Scope) (*Key, string, error) {
+	if len(scopes) == 0 {
+		return nil, "", fmt.Errorf("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !ValidScope(scope) {
+			return nil, "", fmt.Errorf("unknown scope: %s", scope)
+		}
+	}
+
+	secret, err := Generate(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate key id: %w", err)
+	}
+
+	return &Key{
+		ID:        id.String(),
+		Name:      name,
+		Prefix:    secret[:8],
+		Hash:      Hash(secret),
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}, secret, nil
+}
+
+// Store manages API keys persisted in the data directory
+type Store struct {
+	keys       map[string]*Key // id -> key
+	byHash     map[string]*Key // hash -> key
+	mu         sync.RWMutex
+	configFile string
+}
+
+// NewStore creates a key store backed by keys.json in dataDir
+func NewStore(dataDir string) (*Store, error) {
+	s := &Store{
+		keys:       make(map[string]*Key),
+		byHash:     make(map[string]*Key),
+		configFile: filepath.Join(dataDir, "keys.json"),
+	}
+
+	data, _, err := persist.ReadFile(s.configFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read keys: %w", err)
+	}
+
+	var keys []*Key
+	if err := sonic.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse keys: %w", err)
+	}
+	s.index(keys)
+
+	return s, nil
+}
+
+// index rebuilds the lookup maps (must be called with lock held)
+func (s *Store) index(keys []*Key) {
+	s.keys = make(map[string]*Key, len(keys))
+	s.byHash = make(map[string]*Key, len(keys))
+	for _, key := range keys {
+		s.keys[key.ID] = key
+		s.byHash[key.Hash] = key
+	}
+}
+
+// save persists keys to disk (must be called with lock held)
+func (s *Store) save() error {
+	data, err := sonic.ConfigDefault.MarshalIndent(s.listLocked(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal keys: %w", err)
+	}
+
+	if err := persist.WriteFile(s.configFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write keys: %w", err)
+	}
+
+	return nil
+}
+
+// Add stores a key
+func (s *Store) Add(key *Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.keys[key.ID]; exists {
+		return fmt.Errorf("key %s already exists", key.ID)
+	}
+
+	s.keys[key.ID] = key
+	s.byHash[key.Hash] = key
+
+	return s.save()
+}
+
+// Revoke deletes a key by ID
+func (s *Store) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, exists := s.keys[id]
+	if !exists {
+		return fmt.Errorf("key %s not found", id)
+	}
+
+	delete(s.keys, id)
+	delete(s.byHash, key.Hash)
+
+	return s.save()
+}
+
+// Get returns the key with the given ID
+func (s *Store) Get(id string) (*Key, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.keys[id]
+	return key, ok
+}
+
+// List returns all keys ordered by creation time
+func (s *Store) List() []*Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.listLocked()
+}
+
+func (s *Store) listLocked() []*Key {
+	keys := make([]*Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys
+}
+
+// Authenticate returns the key matching the given secret
+func (s *Store) Authenticate(secret string) (*Key, bool) {
+	hash := Hash(secret)
+
+	s.mu.RLock()
+	key, ok := s.byHash[hash]
+	s.mu.RUnlock()
+
+	if !ok || subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hash)) != 1 {
+		return nil, false
+	}
+	return key, true
+}
+
+// Restore replaces all keys (used when restoring Raft snapshots)
+func (s *Store) Restore(keys []*Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.index(keys)
+	return s.save()
+}
@@ -1,25 +1,40 @@
 package main
 
 import (
+	"bright/alerts"
 	"bright/config"
+	"bright/disk"
+	brerrors "bright/errors"
+	"bright/gql"
 	"bright/handlers"
 	"bright/ingresses"
 	"bright/ingresses/postgres"
+	"bright/jobs"
+	"bright/keys"
+	"bright/metrics"
+	"bright/migrate"
+	"bright/models"
+	"bright/notify"
+	"bright/objectstore"
 	middleware "bright/middlewares"
 	"bright/raft"
+	"bright/replication"
+	"bright/routing"
 	"bright/rpc"
+	"bright/schedules"
 	"bright/store"
 	"context"
 	"fmt"
 	"log"
 	"os"
+	"runtime"
 	"time"
 
 	"github.com/alecthomas/kong"
-	"github.com/ansrivas/fiberprometheus/v2"
 	"github.com/bytedance/sonic"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"go.uber.org/zap"
 )
 
@@ -28,6 +43,12 @@ var Version = "dev"
 
 var CLI struct {
 	Serve   ServeCmd   `cmd:"" help:"Start the Bright server" default:"1"`
+	Bench   BenchCmd   `cmd:"" help:"Run indexing and search benchmarks against a server"`
+	Cluster ClusterCmd `cmd:"" help:"Manage Raft cluster membership"`
+	Inspect InspectCmd `cmd:"" help:"Inspect a data directory without starting the server"`
+	Migrate MigrateCmd `cmd:"" help:"Upgrade a data directory to the current storage layout"`
+	Keys    KeysCmd    `cmd:"" help:"Manage scoped API keys"`
+	Keygen  KeygenCmd  `cmd:"" help:"Generate a strong random master key"`
 	Version VersionCmd `cmd:"" help:"Show version information"`
 }
 
@@ -74,8 +95,55 @@ func (s *ServeCmd) Run() error {
 		zap.Bool("raft_enabled", cfg.RaftEnabled),
 	)
 
+	// Refuse to open data directories written in an unsupported format
+	if err := migrate.Check(cfg.DataPath); err != nil {
+		log.Fatal("Incompatible data directory: ", err)
+	}
+
 	// Initialize store with configured data path
-	indexStore := store.Initialize(cfg.DataPath)
+	indexStore, err := store.New(cfg.DataPath)
+	if err != nil {
+		log.Fatal("Failed to load index configurations: ", err)
+	}
+
+	// Throttle background work relative to searches and writes
+	jobPriorities, err := jobs.ParsePriorities(cfg.JobPriorities)
+	if err != nil {
+		log.Fatal("Invalid background job configuration: ", err)
+	}
+	indexStore.Jobs().Configure(jobs.Options{Priorities: jobPriorities, MaxYield: cfg.JobMaxYield})
+
+	if cfg.IndexWorkers > 0 {
+		indexStore.SetIndexParallelism(cfg.IndexWorkers, cfg.IndexSubBatchSize)
+	} else {
+		indexStore.SetIndexParallelism(runtime.GOMAXPROCS(0), cfg.IndexSubBatchSize)
+	}
+
+	indexStore.SearchCache().SetCapacity(cfg.SearchCacheSize)
+	if err := indexStore.SearchCache().RegisterMetrics(metrics.Registry); err != nil {
+		log.Fatal("Failed to initialize search cache metrics:", err)
+	}
+
+	if err := indexStore.SetIndexTypeDefaults(cfg.DefaultIndexType, cfg.DefaultKVStore); err != nil {
+		log.Fatal("Invalid default index type: ", err)
+	}
+
+	// Journal document batches in single-node mode; Raft's log covers cluster mode
+	if !cfg.RaftEnabled && cfg.WALEnabled {
+		replayed, err := indexStore.EnableWAL()
+		if err != nil {
+			log.Fatal("Failed to open write-ahead log:", err)
+		}
+		if replayed > 0 {
+			zapLogger.Info("Replayed uncommitted document batches", zap.Int("batches", replayed))
+		}
+	}
+
+	// Initialize API key store
+	keyStore, err := keys.NewStore(cfg.DataPath)
+	if err != nil {
+		log.Fatal("Failed to load API keys:", err)
+	}
 
 	// Initialize RPC client if Raft is enabled (needed for cluster join)
 	var rpcClient rpc.RPCClient
@@ -97,8 +165,27 @@ func (s *ServeCmd) Run() error {
 			RPCClient:    rpcClient,
 		}
 
+		if cfg.RaftSnapshotS3Bucket != "" {
+			objects, err := objectstore.NewS3(objectstore.Options{
+				Endpoint:  cfg.RaftSnapshotS3Endpoint,
+				Region:    cfg.RaftSnapshotS3Region,
+				Bucket:    cfg.RaftSnapshotS3Bucket,
+				AccessKey: cfg.RaftSnapshotS3AccessKey,
+				SecretKey: cfg.RaftSnapshotS3SecretKey,
+				PathStyle: cfg.RaftSnapshotS3PathStyle,
+			})
+			if err != nil {
+				log.Fatal("Invalid Raft snapshot storage configuration: ", err)
+			}
+			raftConfig.SnapshotMirror = &raft.SnapshotMirrorConfig{
+				Objects: objects,
+				Prefix:  cfg.RaftSnapshotS3Prefix,
+				Retain:  cfg.RaftSnapshotS3Retain,
+			}
+		}
+
 		var err error
-		raftNode, err = raft.NewRaftNode(raftConfig, indexStore, zapLogger)
+		raftNode, err = raft.NewRaftNode(raftConfig, indexStore, keyStore, zapLogger)
 		if err != nil {
 			log.Fatal("Failed to initialize Raft:", err)
 		}
@@ -114,19 +201,161 @@ func (s *ServeCmd) Run() error {
 	// Initialize ingress manager
 	ingressManager := ingresses.NewManager(cfg.DataPath, indexStore, raftNode, zapLogger)
 	ingressManager.RegisterFactory("postgres", postgres.Factory)
-
-	// Load existing ingress configurations
-	if err := ingressManager.Load(); err != nil {
-		zapLogger.Warn("Failed to load ingress configurations", zap.Error(err))
+	if err := ingressManager.SetProfileKey(cfg.ProfileKey); err != nil {
+		log.Fatal("Failed to initialize connection profiles:", err)
 	}
+	if err := ingressManager.RegisterMetrics(metrics.Registry); err != nil {
+		log.Fatal("Failed to initialize ingress metrics:", err)
+	}
+	indexStore.OnIndexDeleted(func(indexID string) {
+		ingressManager.RemoveIndexIngresses(indexID)
+	})
+
+	// A standby only writes what it replicates from its source, so its
+	// ingresses, alerts and scheduled searches wait until it is promoted
+	standby := cfg.ReplicateFrom != ""
+
+	if !standby {
+		// Load existing ingress configurations
+		if err := ingressManager.Load(); err != nil {
+			zapLogger.Warn("Failed to load ingress configurations", zap.Error(err))
+		}
 
-	// Start all ingresses
-	if err := ingressManager.StartAll(context.Background()); err != nil {
-		zapLogger.Warn("Some ingresses failed to start", zap.Error(err))
+		// Ingresses of a cluster run on the leader and follow it on failover
+		if raftNode != nil {
+			ingressManager.WatchLeadership(context.Background(), raftNode.LeaderCh())
+		}
+
+		// Start all ingresses
+		if err := ingressManager.StartAll(context.Background()); err != nil {
+			zapLogger.Warn("Some ingresses failed to start", zap.Error(err))
+		}
 	}
 	defer ingressManager.StopAll()
 
-	return startServer(cfg, zapLogger, indexStore, raftNode, rpcClient, ingressManager)
+	// Refuse writes, then pause ingresses and snapshots, as the disk fills up
+	diskMonitor, err := disk.NewMonitor(cfg.DataPath, disk.Options{
+		Low:      cfg.DiskWatermarkLow,
+		High:     cfg.DiskWatermarkHigh,
+		Interval: cfg.DiskCheckInterval,
+	}, metrics.Registry, zapLogger)
+	if err != nil {
+		log.Fatal("Failed to initialize disk monitor:", err)
+	}
+	var diskPausedIngresses []string
+	diskMonitor.OnChange(func(previous, current disk.Level) {
+		switch {
+		case current == disk.LevelHigh:
+			diskPausedIngresses = ingressManager.PauseAll()
+			if raftNode != nil {
+				raftNode.SetSnapshotsPaused(true)
+			}
+		case previous == disk.LevelHigh:
+			ingressManager.ResumeAll(diskPausedIngresses)
+			diskPausedIngresses = nil
+			if raftNode != nil {
+				raftNode.SetSnapshotsPaused(false)
+			}
+		}
+	})
+	diskMonitor.Start()
+	defer diskMonitor.Stop()
+
+	// Evaluate alerting rules in the background
+	nodeID := cfg.RaftNodeID
+	if nodeID == "" {
+		nodeID, _ = os.Hostname()
+	}
+	sender := notify.NewSender(notify.SMTPConfig{
+		Addr:     cfg.SMTPAddr,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.SMTPFrom,
+	})
+	alertScheduler := alerts.NewScheduler(indexStore, raftNode, ingressManager, alerts.Options{
+		Interval: cfg.AlertsInterval,
+		NodeID:   nodeID,
+		Sender:   sender,
+	}, zapLogger)
+	if !standby {
+		alertScheduler.Start()
+	}
+	defer alertScheduler.Stop()
+
+	// Run scheduled searches in the background
+	scheduleRunner := schedules.NewRunner(indexStore, raftNode, schedules.Options{
+		NodeID: nodeID,
+		Sender: sender,
+	}, zapLogger)
+	if !standby {
+		scheduleRunner.Start()
+	}
+	defer scheduleRunner.Stop()
+
+	// Follow the change feed of the source cluster
+	var follower *replication.Follower
+	if standby {
+		follower = replication.NewFollower(indexStore, raftNode, keyStore, replication.Options{
+			Source:   cfg.ReplicateFrom,
+			Key:      cfg.ReplicateKey,
+			Interval: cfg.ReplicateInterval,
+		}, zapLogger)
+		follower.Start()
+		defer follower.Stop()
+
+		zapLogger.Info("Replicating as a read-only standby", zap.String("source", cfg.ReplicateFrom))
+	}
+
+	// Spread searches across the cluster by its routing policy
+	var router *routing.Router
+	if raftNode != nil {
+		router = routing.NewRouter(indexStore, raftNode, rpcClient, routing.Options{
+			NodeID:    raftNode.GetConfig().NodeID,
+			MasterKey: cfg.MasterKey,
+			Interval:  cfg.RoutingInterval,
+			Tags:      cfg.NodeTags,
+		}, zapLogger)
+		router.Start()
+		defer router.Stop()
+	}
+
+	// Sample index sizes for the growth rates of /indexes/:id/storage
+	indexStore.StartStorageSampling(cfg.StorageSampleInterval)
+
+	// Merge segments of indexes with many deletes or segments
+	compaction := models.CompactionSettings{
+		MaxDeletedRatio: cfg.CompactionMaxDeletedRatio,
+		MaxSegments:     cfg.CompactionMaxSegments,
+		Window:          cfg.CompactionWindow,
+		Timezone:        cfg.CompactionTimezone,
+	}
+	if err := store.ValidateCompaction(&compaction); err != nil {
+		log.Fatal("Invalid compaction configuration:", err)
+	}
+	indexStore.StartCompaction(cfg.CompactionInterval, compaction)
+
+	// Warm up indexes before accepting traffic
+	if cfg.WarmupEnabled {
+		warmupCtx, cancel := context.WithTimeout(context.Background(), cfg.WarmupTimeout)
+		start := time.Now()
+		for _, result := range indexStore.Warmup(warmupCtx) {
+			fields := []zap.Field{
+				zap.String("index", result.IndexID),
+				zap.Int64("bytes_read", result.BytesRead),
+				zap.Int("queries", result.Queries),
+				zap.Duration("duration", result.Duration),
+			}
+			if result.Error != "" {
+				zapLogger.Warn("Index warmup incomplete", append(fields, zap.String("error", result.Error))...)
+			} else {
+				zapLogger.Debug("Index warmed up", fields...)
+			}
+		}
+		cancel()
+		zapLogger.Info("Warmup finished", zap.Duration("duration", time.Since(start)))
+	}
+
+	return startServer(cfg, zapLogger, indexStore, keyStore, raftNode, rpcClient, ingressManager, alertScheduler, scheduleRunner, diskMonitor, follower, router)
 }
 
 type VersionCmd struct{}
@@ -136,9 +365,10 @@ func (v *VersionCmd) Run() error {
 	return nil
 }
 
-func startServer(cfg *config.Config, zapLogger *zap.Logger, indexStore *store.IndexStore, raftNode *raft.RaftNode, rpcClient rpc.RPCClient, ingressManager *ingresses.Manager) error {
+func startServer(cfg *config.Config, zapLogger *zap.Logger, indexStore *store.IndexStore, keyStore *keys.Store, raftNode *raft.RaftNode, rpcClient rpc.RPCClient, ingressManager *ingresses.Manager, alertScheduler *alerts.Scheduler, scheduleRunner *schedules.Runner, diskMonitor *disk.Monitor, follower *replication.Follower, router *routing.Router) error {
 	app := fiber.New(fiber.Config{
 		DisableStartupMessage: true,
+		BodyLimit:             cfg.BodyLimit,
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			code := fiber.StatusInternalServerError
 			if e, ok := err.(*fiber.Error); ok {
@@ -149,16 +379,19 @@ func startServer(cfg *config.Config, zapLogger *zap.Logger, indexStore *store.In
 				zap.Int("status", code),
 				zap.String("path", c.Path()),
 				zap.String("method", c.Method()),
+				zap.String("request_id", brerrors.RequestID(c)),
 			)
-			return c.Status(code).JSON(fiber.Map{
-				"error": err.Error(),
-			})
+			return brerrors.Respond(c, code, brerrors.CodeForStatus(code), err.Error(), "")
 		},
 		JSONEncoder: sonic.Marshal,
 		JSONDecoder: sonic.Unmarshal,
 	})
 
 	// Middleware
+	// Request IDs are taken from X-Request-ID or generated, echoed in the
+	// response header and included in error bodies
+	app.Use(requestid.New())
+
 	// Custom zap-based request logger
 	app.Use(func(c *fiber.Ctx) error {
 		start := time.Now()
@@ -176,6 +409,7 @@ func startServer(cfg *config.Config, zapLogger *zap.Logger, indexStore *store.In
 			zap.String("method", c.Method()),
 			zap.String("path", c.Path()),
 			zap.String("ip", c.IP()),
+			zap.String("request_id", brerrors.RequestID(c)),
 		}
 
 		if err != nil {
@@ -195,6 +429,8 @@ func startServer(cfg *config.Config, zapLogger *zap.Logger, indexStore *store.In
 	})
 	app.Use(recover.New())
 
+	graphQL := gql.NewService(indexStore)
+
 	// Inject handler context middleware
 	app.Use(func(c *fiber.Ctx) error {
 		handlers.SetContext(c, &handlers.HandlerContext{
@@ -203,27 +439,106 @@ func startServer(cfg *config.Config, zapLogger *zap.Logger, indexStore *store.In
 			Config:         cfg,
 			RPCClient:      rpcClient,
 			IngressManager: ingressManager,
+			Keys:           keyStore,
+			GraphQL:        graphQL,
+			Alerts:         alertScheduler,
+			Schedules:      scheduleRunner,
+			Disk:           diskMonitor,
+			Replication:    follower,
+			Router:         router,
+			Version:        Version,
 		})
 		return c.Next()
 	})
 
 	// Prometheus metrics (before auth to allow scraping without authentication)
-	prometheus := fiberprometheus.New("bright")
-	prometheus.RegisterAt(app, "/metrics")
-	app.Use(prometheus.Middleware)
+	httpMetrics, err := metrics.NewHTTP(metrics.Registry, metrics.HTTPOptions{
+		Buckets:     cfg.MetricsBuckets,
+		Labels:      cfg.MetricsLabels,
+		ConstLabels: cfg.MetricsConstLabels,
+	})
+	if err != nil {
+		return err
+	}
+	app.Get("/metrics", metrics.Handler(metrics.Registry))
+	app.Use(httpMetrics.Middleware)
+
+	// Searches and document requests in flight hold back background jobs
+	app.Use(indexStore.Jobs().Middleware)
 
 	// Health check route (before auth to allow health checks without authentication)
 	app.Get("/health", handlers.Health)
 
 	// Authentication middleware
-	app.Use(middleware.Authorization(cfg, zapLogger))
+	app.Use(middleware.Authorization(cfg, keyStore, zapLogger))
+
+	// A standby serves reads only
+	if follower != nil {
+		app.Use(middleware.ReadOnly(cfg.ReplicateFrom))
+	}
 
 	// Cluster management routes (if Raft enabled)
 	if cfg.RaftEnabled {
 		app.Get("/cluster/status", handlers.ClusterStatus)
 		app.Post("/cluster/join", handlers.JoinCluster)
+		app.Get("/cluster/nodes", handlers.ListClusterNodes)
+		app.Post("/cluster/remove", handlers.RemoveClusterNode)
+		app.Post("/cluster/transfer-leader", handlers.TransferLeadership)
+		app.Get("/cluster/routing", handlers.GetRouting)
+		app.Put("/cluster/routing", handlers.PutRouting)
+
+		// Change feed of standby clusters
+		app.Get("/cluster/changes", handlers.GetChanges)
 	}
 
+	// Runtime settings shared by the cluster
+	app.Get("/cluster/settings", handlers.GetSettings)
+	app.Patch("/cluster/settings", handlers.UpdateSettings)
+
+	// Replication state of a standby
+	app.Get("/replication", handlers.GetReplication)
+
+	// Operation counts of this node
+	app.Get("/stats", handlers.GetStats)
+
+	// Alerting rules
+	app.Get("/alerts", handlers.ListAlerts)
+	app.Post("/alerts", handlers.CreateAlert)
+	app.Get("/alerts/:id", handlers.GetAlert)
+	app.Put("/alerts/:id", handlers.UpdateAlert)
+	app.Delete("/alerts/:id", handlers.DeleteAlert)
+
+	// Scheduled searches
+	app.Get("/schedules", handlers.ListSchedules)
+	app.Post("/schedules", handlers.CreateSchedule)
+	app.Get("/schedules/:id", handlers.GetSchedule)
+	app.Put("/schedules/:id", handlers.UpdateSchedule)
+	app.Delete("/schedules/:id", handlers.DeleteSchedule)
+	app.Post("/schedules/:id/run", handlers.RunSchedule)
+
+	// Index aliases (created by POST /indexes/:id/rollover)
+	app.Get("/aliases", handlers.ListAliases)
+	app.Get("/aliases/:name", handlers.GetAlias)
+
+	// Background jobs of this node
+	app.Get("/admin/background-jobs", handlers.ListBackgroundJobs)
+	app.Get("/admin/background-jobs/:id", handlers.GetBackgroundJob)
+	app.Patch("/admin/background-jobs/:id", handlers.UpdateBackgroundJob)
+
+	// Ingresses of all indexes
+	app.Get("/ingresses", handlers.ListAllIngresses)
+
+	// Connection profiles of ingresses
+	app.Get("/ingress-profiles", handlers.ListProfiles)
+	app.Get("/ingress-profiles/:id", handlers.GetProfile)
+	app.Put("/ingress-profiles/:id", handlers.PutProfile)
+	app.Delete("/ingress-profiles/:id", handlers.DeleteProfile)
+
+	// API key management
+	app.Get("/keys", handlers.ListKeys)
+	app.Post("/keys", handlers.CreateKey)
+	app.Delete("/keys/:id", handlers.RevokeKey)
+
 	// API routes grouped under /indexes
 	indexes := app.Group("/indexes")
 	{
@@ -233,15 +548,52 @@ func startServer(cfg *config.Config, zapLogger *zap.Logger, indexStore *store.In
 		indexes.Get("/:id", handlers.GetIndex)
 		indexes.Delete("/:id", handlers.DeleteIndex)
 		indexes.Patch("/:id", handlers.UpdateIndex)
+		indexes.Post("/:id/refresh", handlers.RefreshIndex)
+		indexes.Post("/:id/rollover", handlers.Rollover)
+		indexes.Get("/:id/storage", handlers.GetIndexStorage)
+		indexes.Get("/:id/text-report", handlers.GetTextReport)
+		indexes.Get("/:id/settings", handlers.ExportSettings)
+		indexes.Put("/:id/settings", handlers.ImportSettings)
+		indexes.Get("/:id/snapshot", handlers.GetIndexSnapshot)
+
+		// Shadow reindex
+		indexes.Post("/:id/reindex", handlers.StartReindex)
+		indexes.Get("/:id/reindex", handlers.GetReindex)
+		indexes.Delete("/:id/reindex", handlers.CancelReindex)
 
 		// Document management
 		indexes.Post("/:id/documents", handlers.AddDocuments)
 		indexes.Delete("/:id/documents", handlers.DeleteDocuments)
+		indexes.Patch("/:id/documents", handlers.UpdateDocuments)
 		indexes.Delete("/:id/documents/:documentid", handlers.DeleteDocument)
 		indexes.Patch("/:id/documents/:documentid", handlers.UpdateDocument)
 
 		// Search
-		indexes.Post("/:id/searches", handlers.Search)
+		routeSearches := middleware.RouteSearches(router, zapLogger)
+		limitSearches := middleware.LimitSearches(indexStore.Settings())
+		indexes.Post("/:id/searches", routeSearches, limitSearches, handlers.Search)
+		indexes.Get("/:id/searches/explain", routeSearches, limitSearches, handlers.ExplainSearch)
+		indexes.Get("/:id/search-as-you-type", routeSearches, limitSearches, handlers.SearchAsYouType)
+		indexes.Post("/:id/search-as-you-type", routeSearches, limitSearches, handlers.SearchAsYouType)
+		indexes.Post("/:id/duplicates", handlers.FindDuplicates)
+
+		// Stored queries and percolation
+		indexes.Get("/:id/queries", handlers.ListQueries)
+		indexes.Get("/:id/queries/:queryId", handlers.GetQuery)
+		indexes.Put("/:id/queries/:queryId", handlers.PutQuery)
+		indexes.Delete("/:id/queries/:queryId", handlers.DeleteQuery)
+		indexes.Post("/:id/percolate", handlers.Percolate)
+
+		// Ranking experiments
+		indexes.Post("/:id/clicks", handlers.RecordClick)
+		indexes.Get("/:id/experiment", handlers.GetExperiment)
+		indexes.Delete("/:id/experiment/stats", handlers.ResetExperiment)
+
+		// Curation rules
+		indexes.Get("/:id/rules", handlers.ListRules)
+		indexes.Get("/:id/rules/:ruleId", handlers.GetRule)
+		indexes.Put("/:id/rules/:ruleId", handlers.PutRule)
+		indexes.Delete("/:id/rules/:ruleId", handlers.DeleteRule)
 
 		// Ingress management
 		indexes.Get("/:id/ingresses", handlers.ListIngresses)
@@ -251,6 +603,21 @@ func startServer(cfg *config.Config, zapLogger *zap.Logger, indexStore *store.In
 		indexes.Delete("/:id/ingresses/:ingressId", handlers.DeleteIngress)
 	}
 
+	// GraphQL API
+	app.Get("/graphql", handlers.GraphQL)
+	app.Post("/graphql", handlers.GraphQL)
+
+	// Elasticsearch compatibility endpoints
+	app.Get("/", handlers.ElasticInfo)
+	app.Post("/_bulk", handlers.ElasticBulk)
+	app.Put("/_bulk", handlers.ElasticBulk)
+	app.Post("/:index/_bulk", handlers.ElasticBulk)
+	app.Put("/:index/_bulk", handlers.ElasticBulk)
+	app.Get("/_search", handlers.ElasticSearch)
+	app.Post("/_search", handlers.ElasticSearch)
+	app.Get("/:index/_search", handlers.ElasticSearch)
+	app.Post("/:index/_search", handlers.ElasticSearch)
+
 	// Start server
 	zapLogger.Info("Server starting", zap.String("address", ":"+cfg.Port))
 	if err := app.Listen(":" + cfg.Port); err != nil {
@@ -0,0 +1,225 @@
+// Package metrics exposes Prometheus metrics of the HTTP API.
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Labels of request metrics
+const (
+	LabelStatusCode  = "status_code"
+	LabelStatusClass = "status_class" // 2xx, 4xx, ...
+	LabelMethod      = "method"
+	LabelPath        = "path" // the route pattern, such as /indexes/:id/searches
+)
+
+// UnmatchedPath is the path label of requests that matched no route, so
+// scans of random URLs don't create a series each
+const UnmatchedPath = "unmatched"
+
+// DefaultLabels are the labels of request metrics when none are configured
+var DefaultLabels = []string{LabelStatusCode, LabelMethod, LabelPath}
+
+// DefaultBuckets are the request duration buckets in seconds when none are
+// configured, from 100µs to a minute
+var DefaultBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Registry holds the metrics served at /metrics, starting with those of
+// the Go runtime and the process
+var Registry = newRegistry()
+
+func newRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return registry
+}
+
+// Handler serves the metrics of a registry in the Prometheus text format
+func Handler(gatherer prometheus.Gatherer) fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	}))
+}
+
+// HTTPOptions configure request metrics
+type HTTPOptions struct {
+	// Buckets of the request duration histogram (DefaultBuckets if empty)
+	Buckets []float64
+
+	// Labels of request metrics (DefaultLabels if empty)
+	Labels []string
+
+	// ConstLabels are added to every request metric, such as
+	// {"service": "bright"}
+	ConstLabels map[string]string
+}
+
+// HTTP records the count, duration and concurrency of requests
+type HTTP struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+	labels   []string
+
+	// Routes registered on the app, to tell matched requests apart
+	routes     map[string]bool
+	routesOnce sync.Once
+}
+
+// NewHTTP creates request metrics and registers them
+func NewHTTP(registerer prometheus.Registerer, options HTTPOptions) (*HTTP, error) {
+	labels := options.Labels
+	if len(labels) == 0 {
+		labels = DefaultLabels
+	}
+	for i, label := range labels {
+		switch label {
+		case LabelStatusCode, LabelStatusClass, LabelMethod, LabelPath:
+		default:
+			return nil, fmt.Errorf("unknown metrics label %q (labels: %s, %s, %s, %s)", label, LabelStatusCode, LabelStatusClass, LabelMethod, LabelPath)
+		}
+		if slices.Contains(labels[:i], label) {
+			return nil, fmt.Errorf("duplicate metrics label %q", label)
+		}
+	}
+
+	buckets := options.Buckets
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	for i, bucket := range buckets {
+		if bucket <= 0 || (i > 0 && bucket <= buckets[i-1]) {
+			return nil, fmt.Errorf("metrics buckets must be positive and increasing")
+		}
+	}
+
+	h := &HTTP{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "http_requests_total",
+			Help:        "Count all http requests by status code, method and path.",
+			ConstLabels: options.ConstLabels,
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:        "http_request_duration_seconds",
+			Help:        "Duration of all HTTP requests by status code, method and path.",
+			ConstLabels: options.ConstLabels,
+			Buckets:     buckets,
+		}, labels),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name:        "http_requests_in_progress_total",
+			Help:        "All the requests in progress",
+			ConstLabels: options.ConstLabels,
+		}, []string{LabelMethod}),
+		labels: labels,
+	}
+
+	for _, collector := range []prometheus.Collector{h.requests, h.duration, h.inFlight} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, fmt.Errorf("failed to register request metrics: %w", err)
+		}
+	}
+	return h, nil
+}
+
+// Middleware records a request. Requests are labeled with the pattern of
+// the route that handled them, never with the raw path.
+func (h *HTTP) Middleware(c *fiber.Ctx) error {
+	method := utils.CopyString(c.Method())
+
+	h.inFlight.WithLabelValues(method).Inc()
+	defer h.inFlight.WithLabelValues(method).Dec()
+
+	start := time.Now()
+	err := c.Next()
+	elapsed := time.Since(start).Seconds()
+
+	status := c.Response().StatusCode()
+	if err != nil {
+		status = fiber.StatusInternalServerError
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			status = fiberErr.Code
+		}
+	}
+
+	values := make([]string, len(h.labels))
+	for i, label := range h.labels {
+		switch label {
+		case LabelStatusCode:
+			values[i] = strconv.Itoa(status)
+		case LabelStatusClass:
+			values[i] = strconv.Itoa(status/100) + "xx"
+		case LabelMethod:
+			values[i] = method
+		case LabelPath:
+			values[i] = h.routePath(c, method)
+		}
+	}
+
+	h.requests.WithLabelValues(values...).Inc()
+	h.duration.WithLabelValues(values...).Observe(elapsed)
+	return err
+}
+
+// routePath returns the pattern of the route that handled a request, or
+// UnmatchedPath if only middleware ran
+func (h *HTTP) routePath(c *fiber.Ctx, method string) string {
+	h.routesOnce.Do(func() {
+		h.routes = make(map[string]bool)
+		for _, route := range c.App().GetRoutes(true) {
+			h.routes[route.Method+" "+normalizePath(route.Path)] = true
+		}
+	})
+
+	// Without a matching route the last route run is a middleware's, whose
+	// path is only a prefix of the request's
+	path := normalizePath(c.Route().Path)
+	if !h.routes[method+" "+path] || !matchesRoute(path, normalizePath(c.Path())) {
+		return UnmatchedPath
+	}
+	return path
+}
+
+// matchesRoute reports whether a route pattern matches a whole request path
+func matchesRoute(pattern, path string) bool {
+	patternSegments := strings.Split(pattern, "/")
+	pathSegments := strings.Split(path, "/")
+	for i, segment := range patternSegments {
+		if segment == "*" || segment == "+" {
+			return true
+		}
+		if i >= len(pathSegments) {
+			// Optional parameters may be missing
+			return strings.HasPrefix(segment, ":") && strings.HasSuffix(segment, "?")
+		}
+		if !strings.HasPrefix(segment, ":") && !strings.EqualFold(segment, pathSegments[i]) {
+			return false
+		}
+	}
+	return len(pathSegments) == len(patternSegments)
+}
+
+// normalizePath removes the trailing slash of a route path
+func normalizePath(path string) string {
+	path = strings.TrimRight(path, "/")
+	if path == "" {
+		return "/"
+	}
+	return path
+}
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestHTTPLabelsRoutePatterns(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	h, err := NewHTTP(registry, HTTPOptions{
+		Labels:      []string{LabelStatusClass, LabelPath},
+		Buckets:     []float64{0.01, 0.1, 1},
+		ConstLabels: map[string]string{"service": "bright"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create metrics: %v", err)
+	}
+
+	app := fiber.New()
+	app.Use(h.Middleware)
+	indexes := app.Group("/indexes")
+	indexes.Get("/:id/searches", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	for _, path := range []string{"/indexes/products/searches", "/indexes/orders/searches", "/wp-login.php"} {
+		if _, err := app.Test(httptest.NewRequest("GET", path, nil)); err != nil {
+			t.Fatalf("Request to %s failed: %v", path, err)
+		}
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	counts := make(map[string]float64)
+	for _, family := range families {
+		if family.GetName() != "http_requests_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if _, ok := labels[LabelMethod]; ok {
+				t.Errorf("Expected no method label, got %v", labels)
+			}
+			if labels["service"] != "bright" {
+				t.Errorf("Expected the service label, got %v", labels)
+			}
+			counts[labels[LabelPath]+" "+labels[LabelStatusClass]] = metric.GetCounter().GetValue()
+		}
+	}
+
+	if counts["/indexes/:id/searches 2xx"] != 2 {
+		t.Errorf("Expected both searches under the route pattern, got %v", counts)
+	}
+	if counts[UnmatchedPath+" 4xx"] != 1 {
+		t.Errorf("Expected the unknown path as unmatched, got %v", counts)
+	}
+	if len(counts) != 2 {
+		t.Errorf("Expected two series, got %v", counts)
+	}
+}
+
+func TestNewHTTPValidatesOptions(t *testing.T) {
+	invalid := []HTTPOptions{
+		{Labels: []string{"index"}},
+		{Labels: []string{LabelPath, LabelPath}},
+		{Buckets: []float64{1, 0.5}},
+		{Buckets: []float64{0, 1}},
+	}
+	for i, options := range invalid {
+		if _, err := NewHTTP(prometheus.NewRegistry(), options); err == nil {
+			t.Errorf("Options %d: expected an error", i)
+		}
+	}
+}
+
+func TestRegistryIncludesRuntimeMetrics(t *testing.T) {
+	families, err := Registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	names := make(map[string]bool, len(families))
+	for _, family := range families {
+		names[family.GetName()] = true
+	}
+	if !names["go_goroutines"] {
+		t.Error("Expected the Go collector to be registered")
+	}
+	if !names["process_start_time_seconds"] && runtime.GOOS == "linux" {
+		t.Error("Expected the process collector to be registered")
+	}
+}
@@ -2,16 +2,101 @@ package middleware
 
 import (
 	"bright/config"
+	"bright/errors"
+	"bright/keys"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"go.uber.org/zap"
 )
 
+const apiKeyContextKey = "api_key"
+
+// APIKey returns the API key that authorized the request, or nil when the
+// request used the master key or authentication is disabled
+func APIKey(c *fiber.Ctx) *keys.Key {
+	key, _ := c.Locals(apiKeyContextKey).(*keys.Key)
+	return key
+}
+
+// requiredScope maps a request to the API key scope it needs
+func requiredScope(method, path string) keys.Scope {
+	path = strings.TrimSuffix(path, "/")
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+
+	// Elasticsearch compatibility endpoints: /_search, /_bulk and
+	// /<index>/_search, /<index>/_bulk. Other paths ending in them fall
+	// through to the rules of their route.
+	if len(segments) <= 2 && segments[0] != "indexes" {
+		switch segments[len(segments)-1] {
+		case "_search":
+			if method == fiber.MethodGet || method == fiber.MethodPost {
+				return keys.ScopeSearch
+			}
+		case "_bulk":
+			if method == fiber.MethodPost || method == fiber.MethodPut {
+				return keys.ScopeWrite
+			}
+		}
+	}
+	if path == "" && method == fiber.MethodGet {
+		return keys.ScopeRead
+	}
+
+	// GraphQL is read-only: it lists indexes, fetches and searches documents
+	// (see acceptedScopes)
+	if segments[0] == "graphql" {
+		return keys.ScopeRead
+	}
+
+	if segments[0] == "indexes" && len(segments) >= 3 {
+		switch segments[2] {
+		case "searches", "search-as-you-type":
+			return keys.ScopeSearch
+		case "duplicates":
+			return keys.ScopeRead
+		case "percolate", "clicks":
+			return keys.ScopeSearch
+		case "queries", "rules":
+			if method == fiber.MethodGet {
+				return keys.ScopeRead
+			}
+			return keys.ScopeWrite
+		case "documents":
+			if method == fiber.MethodGet {
+				return keys.ScopeRead
+			}
+			return keys.ScopeWrite
+		case "snapshot":
+			// Archives include hidden attributes
+			return keys.ScopeAdmin
+		}
+	}
+
+	// Ingress responses redact credentials (ingresses.RedactConfig), aliases
+	// only name indexes, and stats only count
+	if (segments[0] == "indexes" || segments[0] == "aliases" || path == "/ingresses" || path == "/stats") && method == fiber.MethodGet {
+		return keys.ScopeRead
+	}
+
+	return keys.ScopeAdmin
+}
+
+// acceptedScopes returns the scopes any of which authorizes a request
+func acceptedScopes(method, path string) []keys.Scope {
+	scope := requiredScope(method, path)
+	// GraphQL queries mostly search, so search-only keys may run them too
+	if scope == keys.ScopeRead && strings.Split(strings.TrimPrefix(path, "/"), "/")[0] == "graphql" {
+		return []keys.Scope{keys.ScopeRead, keys.ScopeSearch}
+	}
+	return []keys.Scope{scope}
+}
+
 // Authorization creates an authentication middleware
 // If masterKey is empty, authentication is disabled and all requests are allowed
-// Otherwise, validates Bearer token in Authorization header
-func Authorization(cfg *config.Config, logger *zap.Logger) fiber.Handler {
+// Otherwise, validates Bearer token in Authorization header against the
+// master key or a scoped API key
+func Authorization(cfg *config.Config, keyStore *keys.Store, logger *zap.Logger) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// If no master key is configured, skip authentication
 		if !cfg.RequiresAuth() {
@@ -25,9 +110,7 @@ func Authorization(cfg *config.Config, logger *zap.Logger) fiber.Handler {
 				zap.String("method", c.Method()),
 				zap.String("ip", c.IP()),
 			)
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "missing authorization header",
-			})
+			return errors.Unauthorized(c, errors.ErrorCodeMissingAuthorization, "missing authorization header")
 		}
 
 		// Check for Bearer token format
@@ -38,21 +121,37 @@ func Authorization(cfg *config.Config, logger *zap.Logger) fiber.Handler {
 				zap.String("method", c.Method()),
 				zap.String("ip", c.IP()),
 			)
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "invalid authorization format, expected 'Bearer <token>'",
-			})
+			return errors.Unauthorized(c, errors.ErrorCodeInvalidAuthorization, "invalid authorization format, expected 'Bearer <token>'")
 		}
 
 		token := parts[1]
 		if token != cfg.MasterKey {
-			logger.Warn("invalid authorization token",
-				zap.String("path", c.Path()),
-				zap.String("method", c.Method()),
-				zap.String("ip", c.IP()),
-			)
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "invalid authorization token",
-			})
+			key, ok := keyStore.Authenticate(token)
+			if !ok {
+				logger.Warn("invalid authorization token",
+					zap.String("path", c.Path()),
+					zap.String("method", c.Method()),
+					zap.String("ip", c.IP()),
+				)
+				return errors.Unauthorized(c, errors.ErrorCodeInvalidAuthorization, "invalid authorization token")
+			}
+
+			scopes := acceptedScopes(c.Method(), c.Path())
+			if !key.AllowsAny(scopes) {
+				required := make([]string, len(scopes))
+				for i, scope := range scopes {
+					required[i] = string(scope)
+				}
+				logger.Warn("insufficient api key scope",
+					zap.String("path", c.Path()),
+					zap.String("method", c.Method()),
+					zap.String("key_id", key.ID),
+					zap.String("required_scope", strings.Join(required, " or ")),
+				)
+				return errors.Forbidden(c, errors.ErrorCodeInsufficientPermissions, "api key lacks required scope: "+strings.Join(required, " or "))
+			}
+
+			c.Locals(apiKeyContextKey, key)
 		}
 
 		logger.Debug("request authorized",
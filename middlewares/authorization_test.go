@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"testing"
+
+	"bright/keys"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequiredScope(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   keys.Scope
+	}{
+		// Elasticsearch compatibility endpoints
+		{fiber.MethodGet, "/_search", keys.ScopeSearch},
+		{fiber.MethodPost, "/products/_search", keys.ScopeSearch},
+		{fiber.MethodPost, "/_bulk", keys.ScopeWrite},
+		{fiber.MethodPut, "/products/_bulk", keys.ScopeWrite},
+
+		// Paths that only end in _search or _bulk keep the scope of their route
+		{fiber.MethodDelete, "/indexes/_search", keys.ScopeAdmin},
+		{fiber.MethodPatch, "/indexes/_search", keys.ScopeAdmin},
+		{fiber.MethodDelete, "/indexes/_bulk", keys.ScopeAdmin},
+		{fiber.MethodGet, "/indexes/_search", keys.ScopeRead},
+		{fiber.MethodDelete, "/indexes/products/documents/_search", keys.ScopeWrite},
+		{fiber.MethodGet, "/indexes/products/documents/_search", keys.ScopeRead},
+		{fiber.MethodDelete, "/products/_search", keys.ScopeAdmin},
+		{fiber.MethodGet, "/products/_bulk", keys.ScopeAdmin},
+		{fiber.MethodPost, "/cluster/nodes/_search", keys.ScopeAdmin},
+
+		{fiber.MethodGet, "", keys.ScopeRead},
+		{fiber.MethodPost, "/graphql", keys.ScopeRead},
+		{fiber.MethodPost, "/indexes/products/searches", keys.ScopeSearch},
+		{fiber.MethodPost, "/indexes/products/documents", keys.ScopeWrite},
+		{fiber.MethodGet, "/indexes/products/snapshot", keys.ScopeAdmin},
+		{fiber.MethodGet, "/indexes", keys.ScopeRead},
+		{fiber.MethodPost, "/indexes", keys.ScopeAdmin},
+		{fiber.MethodGet, "/stats", keys.ScopeRead},
+		{fiber.MethodGet, "/ingresses", keys.ScopeRead},
+		{fiber.MethodGet, "/indexes/products/ingresses", keys.ScopeRead},
+		{fiber.MethodPost, "/indexes/products/ingresses", keys.ScopeAdmin},
+	}
+
+	for _, tt := range tests {
+		if got := requiredScope(tt.method, tt.path); got != tt.want {
+			t.Errorf("requiredScope(%s %q) = %s, want %s", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestAcceptedScopesGraphQL(t *testing.T) {
+	search := &keys.Key{Scopes: []keys.Scope{keys.ScopeSearch}}
+	read := &keys.Key{Scopes: []keys.Scope{keys.ScopeRead}}
+	write := &keys.Key{Scopes: []keys.Scope{keys.ScopeWrite}}
+
+	for _, method := range []string{fiber.MethodGet, fiber.MethodPost} {
+		scopes := acceptedScopes(method, "/graphql")
+		if !search.AllowsAny(scopes) || !read.AllowsAny(scopes) {
+			t.Errorf("%s /graphql should accept search and read keys, accepts %v", method, scopes)
+		}
+		if write.AllowsAny(scopes) {
+			t.Errorf("%s /graphql should refuse write keys, accepts %v", method, scopes)
+		}
+	}
+
+	if search.AllowsAny(acceptedScopes(fiber.MethodGet, "/indexes")) {
+		t.Error("Search keys should not list indexes")
+	}
+}
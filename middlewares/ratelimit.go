@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"bright/errors"
+	"bright/store"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// LimitSearches caps the searches this node serves per second at the
+// cluster's rateLimits.searchesPerSecond setting, read on every search so
+// that changes apply at once. Searches over the cap are refused with 429.
+// Bursts of up to a second's worth of searches are allowed.
+func LimitSearches(settings *store.ClusterSettings) fiber.Handler {
+	var (
+		mu     sync.Mutex
+		tokens float64
+		last   time.Time
+	)
+	return func(c *fiber.Ctx) error {
+		rate := settings.Number(store.SettingSearchesPerSecond)
+		if rate <= 0 {
+			return c.Next()
+		}
+		burst := math.Max(rate, 1)
+
+		mu.Lock()
+		now := time.Now()
+		if last.IsZero() {
+			tokens = burst
+		} else {
+			tokens = math.Min(tokens+now.Sub(last).Seconds()*rate, burst)
+		}
+		last = now
+		allowed := tokens >= 1
+		if allowed {
+			tokens--
+		}
+		wait := (1 - tokens) / rate
+		mu.Unlock()
+
+		if !allowed {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait))))
+			return errors.TooManyRequests(c, errors.ErrorCodeRateLimited, "search rate limit of "+strconv.FormatFloat(rate, 'f', -1, 64)+" per second exceeded")
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"bright/errors"
+	"bright/keys"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReadOnly refuses the requests of a standby that would change what it
+// replicates from source. Reads and searches are served, as is the
+// management of the standby's own cluster and background jobs.
+func ReadOnly(source string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		method, path := c.Method(), c.Path()
+		if method == fiber.MethodGet || method == fiber.MethodHead {
+			return c.Next()
+		}
+		if strings.HasPrefix(path, "/cluster/") || strings.HasPrefix(path, "/admin/") {
+			return c.Next()
+		}
+		switch requiredScope(method, path) {
+		case keys.ScopeSearch, keys.ScopeRead:
+			return c.Next()
+		}
+		return errors.Forbidden(c, errors.ErrorCodeReadOnlyStandby, "this node is a read-only standby of "+source)
+	}
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"bytes"
+
+	"bright/models"
+	"bright/routing"
+	"bright/rpc"
+
+	"github.com/bytedance/sonic"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// HeaderRoute names the HTTP address of the node that should take the next
+// searches, in hints mode
+const HeaderRoute = "X-Bright-Route"
+
+// RouteSearches applies the cluster's routing policy to searches. In hints
+// mode they are served here and the response names the least-loaded node;
+// in proxy mode they are forwarded to it, and served here when it can't be
+// reached. Searches another node forwarded, and cursor searches, whose
+// points in time live on the node that opened them, are always served
+// here. Without a router (no Raft) every search is.
+func RouteSearches(router *routing.Router, logger *zap.Logger) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if router == nil || c.Get(rpc.HeaderRoutedBy) != "" {
+			return c.Next()
+		}
+		policy := router.Policy()
+		if policy.Mode == models.RoutingLocal || isCursorSearch(c) {
+			return c.Next()
+		}
+
+		node := router.Pick()
+		if policy.Mode == models.RoutingHints {
+			if node.HTTPAddress != "" {
+				c.Set(HeaderRoute, node.HTTPAddress)
+			}
+			return c.Next()
+		}
+		if node.ID == router.NodeID() {
+			return c.Next()
+		}
+		if err := router.Forward(c, node); err != nil {
+			logger.Warn("Failed to route search, serving it locally", zap.String("node_id", node.ID), zap.Error(err))
+			return c.Next()
+		}
+		return nil
+	}
+}
+
+// isCursorSearch returns true for searches paging with a cursor, given as a
+// query parameter or in the body
+func isCursorSearch(c *fiber.Ctx) bool {
+	if c.Query("cursor") != "" {
+		return true
+	}
+	body := c.Body()
+	if !bytes.Contains(body, []byte(`"cursor"`)) {
+		return false
+	}
+	var req struct {
+		Cursor string `json:"cursor"`
+	}
+	return sonic.Unmarshal(body, &req) == nil && req.Cursor != ""
+}
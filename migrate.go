@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bright/migrate"
+	"fmt"
+
+	"github.com/bytedance/sonic"
+)
+
+// MigrateCmd upgrades (or rolls back) the on-disk layout of a data directory.
+// The server must be stopped while migrating.
+type MigrateCmd struct {
+	Data     string `help:"Path to data directory" default:"./data" type:"path"`
+	To       int    `help:"Target format version (defaults to the latest)"`
+	DryRun   bool   `help:"Show the changes without applying them"`
+	Rollback bool   `help:"Restore the data directory from the most recent migration backup"`
+	JSON     bool   `help:"Print the result as JSON"`
+}
+
+func (m *MigrateCmd) Run() error {
+	if m.Rollback {
+		version, backup, err := migrate.Rollback(m.Data, m.DryRun)
+		if err != nil {
+			return err
+		}
+		if m.DryRun {
+			fmt.Printf("Would restore %s (format version %d)\n", backup, version)
+		} else {
+			fmt.Printf("Restored %s (format version %d)\n", backup, version)
+		}
+		return nil
+	}
+
+	result, err := migrate.Run(m.Data, m.To, m.DryRun)
+	if result != nil {
+		if m.JSON {
+			data, err := sonic.ConfigDefault.MarshalIndent(result, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+		} else {
+			printMigrateResult(result)
+		}
+	}
+	return err
+}
+
+// printMigrateResult writes a migration result in a human readable form
+func printMigrateResult(result *migrate.Result) {
+	if len(result.Steps) == 0 {
+		fmt.Printf("Data directory is up to date (format version %d)\n", result.FromVersion)
+		return
+	}
+
+	if result.DryRun {
+		fmt.Printf("Dry run: format version %d -> %d\n", result.FromVersion, result.ToVersion)
+	} else {
+		fmt.Printf("Migrated format version %d -> %d\n", result.FromVersion, result.ToVersion)
+	}
+
+	for _, step := range result.Steps {
+		fmt.Printf("\n[%d] %s\n", step.Version, step.Description)
+		if len(step.Changes) == 0 {
+			fmt.Println("  no changes")
+		}
+		for _, change := range step.Changes {
+			fmt.Printf("  - %s\n", change)
+		}
+	}
+
+	if result.Backup != "" {
+		fmt.Printf("\nBackup written to %s (undo with --rollback)\n", result.Backup)
+	}
+}
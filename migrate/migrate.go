@@ -0,0 +1,328 @@
+package migrate
+
+import (
+	"bright/persist"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// CurrentVersion is the data directory format version written by this build
+const CurrentVersion = 2
+
+// FormatFile is the name of the data directory format stamp
+const FormatFile = "format.json"
+
+// backupDir is the directory (inside the data directory) holding migration backups
+const backupDir = ".migrations"
+
+// metadataFiles are the top-level files backed up before every migration
+var metadataFiles = []string{"configs.json", "ingresses.json", "keys.json", "idempotency.json", FormatFile}
+
+// Format is the content of the format stamp
+type Format struct {
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Migration upgrades a data directory from Version-1 to Version
+type Migration struct {
+	Version     int
+	Description string
+
+	// Apply performs the migration. When dryRun is true it must not modify
+	// anything and only report the changes it would make.
+	Apply func(dataDir string, dryRun bool) ([]string, error)
+}
+
+// Step is a migration that was (or would be) applied
+type Step struct {
+	Version     int      `json:"version"`
+	Description string   `json:"description"`
+	Changes     []string `json:"changes"`
+}
+
+// Result describes the outcome of a migration run
+type Result struct {
+	FromVersion int    `json:"fromVersion"`
+	ToVersion   int    `json:"toVersion"`
+	DryRun      bool   `json:"dryRun"`
+	Backup      string `json:"backup,omitempty"`
+	Steps       []Step `json:"steps"`
+}
+
+// manifest records what a backup contains so it can be rolled back
+type manifest struct {
+	FromVersion int       `json:"fromVersion"`
+	ToVersion   int       `json:"toVersion"`
+	Files       []string  `json:"files"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// ReadVersion returns the format version of a data directory.
+// Directories without a stamp report version 0 and exists=false.
+func ReadVersion(dataDir string) (version int, exists bool, err error) {
+	data, err := os.ReadFile(filepath.Join(dataDir, FormatFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("failed to read format stamp: %w", err)
+	}
+
+	var format Format
+	if err := sonic.Unmarshal(data, &format); err != nil {
+		return 0, true, fmt.Errorf("failed to parse format stamp: %w", err)
+	}
+
+	return format.Version, true, nil
+}
+
+// WriteVersion stamps a data directory with the given format version
+func WriteVersion(dataDir string, version int) error {
+	data, err := sonic.ConfigDefault.MarshalIndent(Format{
+		Version:   version,
+		UpdatedAt: time.Now().UTC(),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal format stamp: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dataDir, FormatFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write format stamp: %w", err)
+	}
+
+	return nil
+}
+
+// Check verifies that a data directory can be opened by this build.
+// Fresh directories are created and stamped with CurrentVersion; existing
+// ones must already be at CurrentVersion.
+func Check(dataDir string) error {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	version, exists, err := ReadVersion(dataDir)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		fresh, err := isFresh(dataDir)
+		if err != nil {
+			return err
+		}
+		if fresh {
+			return WriteVersion(dataDir, CurrentVersion)
+		}
+	}
+
+	switch {
+	case version < CurrentVersion:
+		return fmt.Errorf("data directory %s has format version %d but this build requires version %d; run `bright migrate --data %s` to upgrade it", dataDir, version, CurrentVersion, dataDir)
+	case version > CurrentVersion:
+		return fmt.Errorf("data directory %s has format version %d which is newer than supported version %d; upgrade Bright or run `bright migrate --data %s --rollback`", dataDir, version, CurrentVersion, dataDir)
+	}
+
+	return nil
+}
+
+// isFresh returns true if the data directory holds no metadata yet
+func isFresh(dataDir string) (bool, error) {
+	for _, name := range metadataFiles {
+		if _, err := os.Stat(filepath.Join(dataDir, name)); err == nil {
+			return false, nil
+		} else if !os.IsNotExist(err) {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Pending returns the migrations needed to bring version up to target
+func Pending(version, target int) []Migration {
+	var pending []Migration
+	for _, m := range migrations {
+		if m.Version > version && m.Version <= target {
+			pending = append(pending, m)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Version < pending[j].Version
+	})
+	return pending
+}
+
+// Run upgrades a data directory to the target version (CurrentVersion if 0).
+// Metadata files are backed up first so the run can be rolled back.
+func Run(dataDir string, target int, dryRun bool) (*Result, error) {
+	if target == 0 {
+		target = CurrentVersion
+	}
+	if target > CurrentVersion {
+		return nil, fmt.Errorf("target version %d is newer than supported version %d", target, CurrentVersion)
+	}
+
+	if _, err := os.Stat(dataDir); err != nil {
+		return nil, fmt.Errorf("data directory not accessible: %w", err)
+	}
+
+	version, _, err := ReadVersion(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	if version > target {
+		return nil, fmt.Errorf("data directory is at version %d, which is newer than target %d; use rollback instead", version, target)
+	}
+
+	result := &Result{
+		FromVersion: version,
+		ToVersion:   version,
+		DryRun:      dryRun,
+		Steps:       []Step{},
+	}
+
+	pending := Pending(version, target)
+	if len(pending) == 0 {
+		return result, nil
+	}
+
+	if !dryRun {
+		backup, err := createBackup(dataDir, version, target)
+		if err != nil {
+			return nil, err
+		}
+		result.Backup = backup
+	}
+
+	for _, m := range pending {
+		changes, err := m.Apply(dataDir, dryRun)
+		if err != nil {
+			return result, fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Description, err)
+		}
+		result.Steps = append(result.Steps, Step{
+			Version:     m.Version,
+			Description: m.Description,
+			Changes:     changes,
+		})
+
+		if !dryRun {
+			if err := WriteVersion(dataDir, m.Version); err != nil {
+				return result, err
+			}
+		}
+		result.ToVersion = m.Version
+	}
+
+	return result, nil
+}
+
+// Rollback restores the metadata files from the most recent backup and
+// returns the version the data directory was rolled back to
+func Rollback(dataDir string, dryRun bool) (int, string, error) {
+	backups, err := filepath.Glob(filepath.Join(dataDir, backupDir, "backup-*"))
+	if err != nil {
+		return 0, "", err
+	}
+	if len(backups) == 0 {
+		return 0, "", fmt.Errorf("no migration backups found in %s", filepath.Join(dataDir, backupDir))
+	}
+	sort.Strings(backups)
+	latest := backups[len(backups)-1]
+
+	data, err := os.ReadFile(filepath.Join(latest, "manifest.json"))
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to read backup manifest: %w", err)
+	}
+	var m manifest
+	if err := sonic.Unmarshal(data, &m); err != nil {
+		return 0, "", fmt.Errorf("failed to parse backup manifest: %w", err)
+	}
+
+	if dryRun {
+		return m.FromVersion, latest, nil
+	}
+
+	backedUp := make(map[string]bool, len(m.Files))
+	for _, name := range m.Files {
+		backedUp[name] = true
+		if err := restoreFile(filepath.Join(latest, name), filepath.Join(dataDir, name)); err != nil {
+			return 0, "", fmt.Errorf("failed to restore %s: %w", name, err)
+		}
+	}
+
+	// Files that did not exist before the migration are removed again
+	for _, name := range metadataFiles {
+		if !backedUp[name] {
+			if err := os.Remove(filepath.Join(dataDir, name)); err != nil && !os.IsNotExist(err) {
+				return 0, "", fmt.Errorf("failed to remove %s: %w", name, err)
+			}
+		}
+	}
+
+	if err := os.RemoveAll(latest); err != nil {
+		return 0, "", fmt.Errorf("failed to remove backup: %w", err)
+	}
+
+	return m.FromVersion, latest, nil
+}
+
+// createBackup copies metadata files into a new backup directory
+func createBackup(dataDir string, from, to int) (string, error) {
+	now := time.Now().UTC()
+	dir := filepath.Join(dataDir, backupDir, fmt.Sprintf("backup-%s-v%d", now.Format("20060102T150405.000000000"), from))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	m := manifest{FromVersion: from, ToVersion: to, Files: []string{}, CreatedAt: now}
+	for _, name := range metadataFiles {
+		src := filepath.Join(dataDir, name)
+		info, err := os.Stat(src)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return "", fmt.Errorf("failed to back up %s: %w", name, err)
+		}
+
+		// Back up the last intact generation
+		data, _, err := persist.ReadFile(src)
+		if err != nil {
+			return "", fmt.Errorf("failed to back up %s: %w", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, info.Mode().Perm()); err != nil {
+			return "", fmt.Errorf("failed to back up %s: %w", name, err)
+		}
+		m.Files = append(m.Files, name)
+	}
+
+	data, err := sonic.ConfigDefault.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "manifest.json"), data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write backup manifest: %w", err)
+	}
+
+	return dir, nil
+}
+
+// restoreFile atomically replaces dst with the content of a backed up file
+func restoreFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	return persist.WriteFile(dst, data, info.Mode().Perm())
+}
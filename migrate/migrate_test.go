@@ -0,0 +1,174 @@
+package migrate
+
+import (
+	"bright/models"
+	"bright/persist"
+	"bright/store"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bytedance/sonic"
+)
+
+// newPreStampFixture creates a data directory as written before format
+// stamps: no format.json, configs.json entries that need normalizing, and an
+// index whose mapping on disk predates its settings
+func newPreStampFixture(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	s, err := store.New(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	for _, config := range []*models.IndexConfig{
+		{ID: "products", PrimaryKey: "sku"},
+		{ID: "orders", PrimaryKey: "id"},
+	} {
+		if err := s.CreateIndex(config); err != nil {
+			t.Fatalf("Failed to create index: %v", err)
+		}
+	}
+	documents := []map[string]any{{"sku": "a", "name": "Red Shoe"}, {"sku": "b", "name": "Blue Shoe"}}
+	if err := s.AddDocuments("products", "", documents); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+
+	configs := readFixtureConfigs(t, dir)
+	configs["products"].Fields = map[string]models.FieldSettings{"name": {Exact: true}}
+	configs["orders"].ID = ""
+	configs["orders"].PrimaryKey = ""
+	configs["ghost"] = nil
+	data, err := sonic.Marshal(configs)
+	if err != nil {
+		t.Fatalf("Failed to marshal configs: %v", err)
+	}
+	if err := persist.WriteFile(filepath.Join(dir, "configs.json"), data, 0644); err != nil {
+		t.Fatalf("Failed to write configs: %v", err)
+	}
+	return dir
+}
+
+// readFixtureConfigs reads configs.json, keeping null entries
+func readFixtureConfigs(t *testing.T, dir string) map[string]*models.IndexConfig {
+	t.Helper()
+
+	data, _, err := persist.ReadFile(filepath.Join(dir, "configs.json"))
+	if err != nil {
+		t.Fatalf("Failed to read configs: %v", err)
+	}
+	var configs map[string]*models.IndexConfig
+	if err := sonic.Unmarshal(data, &configs); err != nil {
+		t.Fatalf("Failed to parse configs: %v", err)
+	}
+	return configs
+}
+
+// TestRunPreStampDirectory tests that a dry run changes nothing and that a
+// run normalizes configs.json, rebuilds the outdated index with its
+// documents and stamps the current format
+func TestRunPreStampDirectory(t *testing.T) {
+	dir := newPreStampFixture(t)
+
+	if err := Check(dir); err == nil || !strings.Contains(err.Error(), "bright migrate") {
+		t.Fatalf("Expected startup to be refused until migrated, got %v", err)
+	}
+
+	result, err := Run(dir, 0, true)
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if result.FromVersion != 0 || result.ToVersion != CurrentVersion || len(result.Steps) != CurrentVersion {
+		t.Fatalf("Unexpected dry run result: %+v", result)
+	}
+	if changes := strings.Join(result.Steps[1].Changes, "\n"); !strings.Contains(changes, "rebuild index products (2 documents)") || strings.Contains(changes, "orders") {
+		t.Errorf("Expected only products to be rebuilt, got:\n%s", changes)
+	}
+	if _, exists, _ := ReadVersion(dir); exists {
+		t.Errorf("Expected the dry run not to stamp the directory")
+	}
+	if configs := readFixtureConfigs(t, dir); configs["orders"].PrimaryKey != "" {
+		t.Errorf("Expected the dry run not to change configs.json")
+	}
+
+	result, err = Run(dir, 0, false)
+	if err != nil {
+		t.Fatalf("Migration failed: %v", err)
+	}
+	if result.Backup == "" || result.ToVersion != CurrentVersion {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	version, exists, err := ReadVersion(dir)
+	if err != nil || !exists || version != CurrentVersion {
+		t.Errorf("Expected format.json at version %d, got %d (exists %v, %v)", CurrentVersion, version, exists, err)
+	}
+	if err := Check(dir); err != nil {
+		t.Errorf("Expected the migrated directory to pass the startup check: %v", err)
+	}
+
+	configs := readFixtureConfigs(t, dir)
+	if _, ok := configs["ghost"]; ok || len(configs) != 2 {
+		t.Errorf("Expected the empty entry to be removed, got %v", configs)
+	}
+	if orders := configs["orders"]; orders.ID != "orders" || orders.PrimaryKey != "id" {
+		t.Errorf("Expected orders to be normalized, got %+v", orders)
+	}
+	if products := configs["products"]; products.PrimaryKey != "sku" || !products.Fields["name"].Exact {
+		t.Errorf("Expected products settings to be kept, got %+v", products)
+	}
+
+	report, err := store.Inspect(dir)
+	if err != nil {
+		t.Fatalf("Failed to inspect: %v", err)
+	}
+	for _, index := range report.Indexes {
+		if index.Status != store.InspectStatusOK || index.MappingChanged {
+			t.Errorf("Expected index %s to match its settings, got %+v", index.ID, index)
+		}
+		if index.ID == "products" && index.DocCount != 2 {
+			t.Errorf("Expected the rebuilt index to keep its 2 documents, got %d", index.DocCount)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".reindex", "products")); !os.IsNotExist(err) {
+		t.Errorf("Expected no shadow index to be left behind")
+	}
+
+	// Another run finds nothing to do
+	if result, err := Run(dir, 0, false); err != nil || len(result.Steps) != 0 {
+		t.Errorf("Expected the directory to be up to date, got %+v (%v)", result, err)
+	}
+}
+
+// TestRollback tests that a rollback restores the metadata files as they
+// were before the migration
+func TestRollback(t *testing.T) {
+	dir := newPreStampFixture(t)
+
+	if _, err := Run(dir, 0, false); err != nil {
+		t.Fatalf("Migration failed: %v", err)
+	}
+	version, _, err := Rollback(dir, false)
+	if err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if version != 0 {
+		t.Errorf("Expected to roll back to version 0, got %d", version)
+	}
+
+	if _, exists, _ := ReadVersion(dir); exists {
+		t.Errorf("Expected format.json to be removed again")
+	}
+	configs := readFixtureConfigs(t, dir)
+	if _, ok := configs["ghost"]; !ok || configs["orders"].PrimaryKey != "" {
+		t.Errorf("Expected configs.json as before the migration, got %v", configs)
+	}
+	if _, _, err := Rollback(dir, false); err == nil {
+		t.Errorf("Expected no backup to be left")
+	}
+}
@@ -0,0 +1,138 @@
+package migrate
+
+import (
+	"bright/models"
+	"bright/persist"
+	"bright/store"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/bytedance/sonic"
+)
+
+// migrations is the ordered list of known data directory migrations.
+// New layout changes append an entry and bump CurrentVersion.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "normalize configs.json and stamp the data directory format",
+		Apply:       normalizeConfigs,
+	},
+	{
+		Version:     2,
+		Description: "rebuild indexes created with an older mapping",
+		Apply:       rebuildMappings,
+	},
+}
+
+// normalizeConfigs fixes configs.json entries written by early versions:
+// null entries are dropped, IDs are made to match their map key and a
+// missing primary key falls back to "id".
+func normalizeConfigs(dataDir string, dryRun bool) ([]string, error) {
+	configs, err := readConfigs(dataDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{"no configs.json present, nothing to normalize"}, nil
+		}
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(configs))
+	for id := range configs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	changes := []string{}
+	for _, id := range ids {
+		config := configs[id]
+		if config == nil {
+			delete(configs, id)
+			changes = append(changes, fmt.Sprintf("remove empty config entry %s", id))
+			continue
+		}
+		if config.ID != id {
+			changes = append(changes, fmt.Sprintf("set id of index %s (was %q)", id, config.ID))
+			config.ID = id
+		}
+		if config.PrimaryKey == "" {
+			changes = append(changes, fmt.Sprintf("set missing primary key of index %s to \"id\"", id))
+			config.PrimaryKey = "id"
+		}
+	}
+
+	if len(changes) == 0 || dryRun {
+		return changes, nil
+	}
+
+	data, err := sonic.ConfigDefault.MarshalIndent(configs, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal configs: %w", err)
+	}
+	if err := persist.WriteFile(filepath.Join(dataDir, "configs.json"), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write configs.json: %w", err)
+	}
+
+	return changes, nil
+}
+
+// rebuildMappings rebuilds the indexes whose mapping on disk differs from
+// the one this build makes from their settings, as after an upgrade that
+// maps fields differently. Their stored documents are copied into a new
+// index that replaces the old one, as a reindex does.
+func rebuildMappings(dataDir string, dryRun bool) ([]string, error) {
+	configs, err := readConfigs(dataDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{"no configs.json present, nothing to rebuild"}, nil
+		}
+		return nil, err
+	}
+	report, err := store.Inspect(dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect data directory: %w", err)
+	}
+
+	changes := []string{}
+	var outdated []string
+	for _, index := range report.Indexes {
+		switch {
+		case index.Status == store.InspectStatusUnreadable:
+			changes = append(changes, fmt.Sprintf("skip index %s, which cannot be opened: %s", index.ID, index.Error))
+		case index.MappingChanged:
+			changes = append(changes, fmt.Sprintf("rebuild index %s (%d documents) with the current mapping", index.ID, index.DocCount))
+			outdated = append(outdated, index.ID)
+		}
+	}
+
+	if len(outdated) == 0 || dryRun {
+		return changes, nil
+	}
+
+	for _, id := range outdated {
+		if err := store.RebuildIndex(dataDir, configs[id]); err != nil {
+			return nil, fmt.Errorf("failed to rebuild index %s: %w", id, err)
+		}
+	}
+
+	return changes, nil
+}
+
+// readConfigs reads the index configurations of a data directory
+func readConfigs(dataDir string) (map[string]*models.IndexConfig, error) {
+	data, _, err := persist.ReadFile(filepath.Join(dataDir, "configs.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to read configs.json: %w", err)
+	}
+
+	var configs map[string]*models.IndexConfig
+	if err := sonic.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse configs.json: %w", err)
+	}
+	return configs, nil
+}
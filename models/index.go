@@ -1,10 +1,391 @@
 package models
 
+import "time"
+
 // IndexConfig represents the configuration for an index
 type IndexConfig struct {
 	ID                string   `json:"id"`
 	PrimaryKey        string   `json:"primaryKey"`
 	ExcludeAttributes []string `json:"excludeAttributes,omitempty"`
+
+	// HiddenAttributes are indexed and searchable but left out of responses
+	// unless a privileged key asks for them
+	HiddenAttributes []string `json:"hiddenAttributes,omitempty"`
+
+	// SearchableAttributes, when set, are the fields query terms without a
+	// field are matched in, each with a boost its matches' scores are
+	// multiplied by ({"title": 3, "description": 1}). They can change at any
+	// time.
+	SearchableAttributes map[string]float64 `json:"searchableAttributes,omitempty"`
+
+	// SortableAttributes restricts sorting to the listed fields. Fields listed
+	// when the index is created also get a keyword sort field.
+	SortableAttributes []string `json:"sortableAttributes,omitempty"`
+
+	// FilterableAttributes restricts field-qualified query clauses and filters
+	// to the listed fields. Fields listed when the index is created without
+	// their own field settings are indexed as case-insensitive exact values.
+	FilterableAttributes []string `json:"filterableAttributes,omitempty"`
+
+	// GeoAttributes are indexed as geo points ({"lat": 48.85, "lon": 2.35},
+	// "48.85,2.35" or [2.35, 48.85]) for geo searches and distance sorting.
+	// They are fixed when the index is created.
+	GeoAttributes []string `json:"geoAttributes,omitempty"`
+
+	// Vectors are dense vector fields holding embeddings of each document,
+	// for kNN searches. They are fixed when the index is created and need a
+	// server built with -tags vectors.
+	Vectors map[string]VectorSettings `json:"vectors,omitempty"`
+
+	// MaxLimit caps the limit of a single search request
+	MaxLimit int `json:"maxLimit,omitempty"`
+
+	// MaxTotalHits caps how deep results can be paged (offset + limit) and
+	// the reported totalHits
+	MaxTotalHits int `json:"maxTotalHits,omitempty"`
+
+	// Synonyms are groups of equivalent terms ("laptop": ["notebook"]).
+	// Query terms of a group also match the group's other terms, so the
+	// groups can change at any time without reindexing.
+	Synonyms map[string][]string `json:"synonyms,omitempty"`
+
+	// RefreshInterval ("1s", "30s") buffers added documents and makes them
+	// searchable in periodic refreshes instead of on every write
+	RefreshInterval string `json:"refreshInterval,omitempty"`
+
+	// SearchDefaults are used by searches that leave the options unset
+	SearchDefaults *SearchDefaults `json:"searchDefaults,omitempty"`
+
+	// Experiment splits searches between ranking variants to compare their
+	// click-through rates
+	Experiment *RankingExperiment `json:"experiment,omitempty"`
+
+	// PrimaryKeyInferred is set when the primary key was detected from the
+	// first documents of an auto-created index rather than configured
+	PrimaryKeyInferred bool `json:"primaryKeyInferred,omitempty"`
+
+	// Queries run against the index during startup warmup
+	WarmupQueries []string `json:"warmupQueries,omitempty"`
+
+	// Fields configures the analysis of individual text fields. It is applied
+	// when the index is created and cannot be changed afterwards.
+	Fields map[string]FieldSettings `json:"fields,omitempty"`
+
+	// TextAnalysis tunes the analyzer of text without field settings of its
+	// own, unqualified queries included. It is applied when the index is
+	// created and cannot be changed afterwards.
+	TextAnalysis *TextAnalysisSettings `json:"textAnalysis,omitempty"`
+
+	// Analysis defines tokenizers, char filters and token filter chains
+	// that fields refer to by name. Like fields, it is applied when the index
+	// is created and cannot be changed afterwards.
+	Analysis *AnalysisSettings `json:"analysis,omitempty"`
+
+	// Language enables per-document language detection. It is applied when
+	// the index is created and cannot be changed afterwards.
+	Language *LanguageSettings `json:"language,omitempty"`
+
+	// Enrichment adds fields from an external HTTP lookup to ingested documents
+	Enrichment *EnrichmentSettings `json:"enrichment,omitempty"`
+
+	// NumericFields turns human-written numbers ("$1,299.00", "1.5 kg") in
+	// the named fields into numeric values at ingest
+	NumericFields map[string]NumericFieldSettings `json:"numericFields,omitempty"`
+
+	// Attachments extracts the text of files embedded in ingested documents
+	Attachments []AttachmentSettings `json:"attachments,omitempty"`
+
+	// Compaction overrides the server's background compaction policy
+	Compaction *CompactionSettings `json:"compaction,omitempty"`
+
+	// Conflicts decides which write wins when several ingresses write
+	// documents with the same primary key
+	Conflicts *ConflictSettings `json:"conflicts,omitempty"`
+
+	// IndexType is the bleve index type ("scorch" or "upside_down") and
+	// KVStore where it keeps its data ("boltdb" for upside_down, or
+	// "memory"). Both are set when the index is created, from the server's
+	// defaults when left empty, and change only through a reindex.
+	IndexType string `json:"indexType,omitempty"`
+	KVStore   string `json:"kvStore,omitempty"`
+}
+
+// Conflict policies
+const (
+	// ConflictLastWriteWins keeps the write with the newest source timestamp
+	ConflictLastWriteWins = "last-write-wins"
+
+	// ConflictPriority keeps the write of the ingress with the highest
+	// priority
+	ConflictPriority = "priority"
+
+	// ConflictMerge merges the fields of each write into the stored document
+	ConflictMerge = "merge"
+)
+
+// ConflictSettings configures how writes of different ingresses to the same
+// document are reconciled. Writes that don't come from an ingress always
+// replace the document.
+type ConflictSettings struct {
+	Policy string `json:"policy"`
+
+	// Priorities ranks ingresses by ID for the priority policy; unlisted
+	// ingresses rank 0. Ties go to the newer write.
+	Priorities map[string]int `json:"priorities,omitempty"`
+}
+
+// LanguageSettings configures language detection. The detected language is
+// stored in the document's _lang field, and each of Fields is also indexed as
+// <field>_<lang> with the analyzer of that language.
+type LanguageSettings struct {
+	Fields []string `json:"fields"`
+
+	// Languages restricts detection to these languages; all when empty
+	Languages []string `json:"languages,omitempty"`
+
+	// Default is used when no language is detected
+	Default string `json:"default,omitempty"`
+}
+
+// EnrichmentSettings configures the HTTP lookup of an index's documents. The
+// service receives {"keys": [...]} with the values of KeyField and answers
+// {"results": {"<key>": {"field": value, ...}}}.
+type EnrichmentSettings struct {
+	URL      string `json:"url"`
+	KeyField string `json:"keyField"`
+
+	// Fields limits which returned fields are added; all when empty
+	Fields []string `json:"fields,omitempty"`
+
+	BatchSize       int `json:"batchSize,omitempty"`
+	TimeoutMs       int `json:"timeoutMs,omitempty"`
+	CacheTTLSeconds int `json:"cacheTtlSeconds,omitempty"`
+}
+
+// SearchDefaults are search options applied when a request omits them
+type SearchDefaults struct {
+	Limit                int      `json:"limit,omitempty"`
+	Sort                 []string `json:"sort,omitempty"`
+	Filter               string   `json:"filter,omitempty"`
+	AttributesToRetrieve []string `json:"attributesToRetrieve,omitempty"`
+	ScoreScript          string   `json:"scoreScript,omitempty"`
+
+	// SortOptions fill the sort options of fields a search leaves unset
+	SortOptions map[string]SortOptions `json:"sortOptions,omitempty"`
+}
+
+// SortOptions tune how hits are sorted by a field
+type SortOptions struct {
+	// Missing places hits without a value "last" (the default) or "first",
+	// whichever the direction
+	Missing string `json:"missing,omitempty"`
+
+	// Type compares values as a "number", "date" or "text" instead of
+	// guessing from each document's terms; values of other types count as
+	// missing
+	Type string `json:"type,omitempty"`
+}
+
+// RankingExperiment splits the searches of an index between ranking
+// variants. Searches with the same userToken get the same variant.
+type RankingExperiment struct {
+	Variants []RankingVariant `json:"variants"`
+}
+
+// RankingVariant is a ranking configuration tried on a share of searches.
+// Its settings replace the index's, and give way to the request's.
+type RankingVariant struct {
+	Name string `json:"name"`
+
+	// Traffic is the variant's share of searches, relative to the others
+	Traffic int `json:"traffic"`
+
+	SearchableAttributes map[string]float64 `json:"searchableAttributes,omitempty"`
+	ScoreScript          string             `json:"scoreScript,omitempty"`
+}
+
+// ClickEvent reports a click on a hit of a search tagged with a variant
+type ClickEvent struct {
+	Variant    string `json:"variant"`
+	DocumentID string `json:"documentId,omitempty"`
+}
+
+// VariantStats counts the searches and clicks of a ranking variant.
+// CTR is clicks per search.
+type VariantStats struct {
+	Variant  string  `json:"variant"`
+	Traffic  int     `json:"traffic"`
+	Searches uint64  `json:"searches"`
+	Clicks   uint64  `json:"clicks"`
+	CTR      float64 `json:"ctr"`
+}
+
+// ExperimentReport compares the variants of an index's ranking experiment
+type ExperimentReport struct {
+	IndexID  string         `json:"indexId"`
+	Variants []VariantStats `json:"variants"`
+}
+
+// NumericFieldSettings configures the normalization of a numeric field.
+// Values that cannot be parsed are indexed as they are.
+type NumericFieldSettings struct {
+	// DecimalSeparator is "." or ","; guessed from each value when empty
+	DecimalSeparator string `json:"decimalSeparator,omitempty"`
+
+	// Precision rounds values to this many decimals
+	Precision *int `json:"precision,omitempty"`
+
+	// Units maps unit suffixes to their factor to the base unit, e.g.
+	// {"g": 1, "kg": 1000}. When set, values need a known unit, or are in
+	// the unit with factor 1.
+	Units map[string]float64 `json:"units,omitempty"`
+
+	// CurrencyField receives the ISO code of a detected currency
+	CurrencyField string `json:"currencyField,omitempty"`
+
+	// OriginalField keeps the original text of normalized values
+	OriginalField string `json:"originalField,omitempty"`
+}
+
+// AttachmentSettings configures the text extraction of one attachment field.
+// The field holds a PDF, DOCX, HTML or text file as base64, a base64 data
+// URI, or (when AllowURLs is set) an http(s) URL to fetch.
+type AttachmentSettings struct {
+	Field string `json:"field"`
+
+	// TargetField receives the text; by default it replaces the attachment
+	TargetField string `json:"targetField,omitempty"`
+
+	// AllowURLs lets documents reference attachments by URL. The node
+	// fetches any URL it is given, private and loopback addresses included,
+	// so enable it only when the keys that write documents are trusted not
+	// to probe the node's network.
+	AllowURLs bool `json:"allowUrls,omitempty"`
+
+	MaxBytes  int `json:"maxBytes,omitempty"`
+	TimeoutMs int `json:"timeoutMs,omitempty"`
+}
+
+// CompactionSettings configures when the segments of an index are merged in
+// the background. Zero values fall back to the server's policy.
+type CompactionSettings struct {
+	// Disabled turns background compaction off for the index
+	Disabled bool `json:"disabled,omitempty"`
+
+	// MaxDeletedRatio (0-1) of deleted documents still held by segments
+	// above which the index is compacted
+	MaxDeletedRatio float64 `json:"maxDeletedRatio,omitempty"`
+
+	// MaxSegments above which the index is compacted
+	MaxSegments int `json:"maxSegments,omitempty"`
+
+	// Window restricts compactions to a daily low-traffic window such as
+	// "01:00-05:00" (it may wrap midnight), in Timezone (UTC when empty)
+	Window   string `json:"window,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// FieldSettings controls how the text of a field is analyzed. Fields without
+// settings use the standard analyzer.
+type FieldSettings struct {
+	// Exact indexes the whole value as a single token (e.g. SKUs and codes)
+	Exact bool `json:"exact,omitempty"`
+
+	// Lowercase lowercases tokens. Defaults to true, or false for exact fields.
+	Lowercase *bool `json:"lowercase,omitempty"`
+
+	// ASCIIFolding folds accented characters to ASCII ("café" -> "cafe")
+	ASCIIFolding bool `json:"asciiFolding,omitempty"`
+
+	// Stemmer reduces words to their stem in the given language ("en", "fr", ...)
+	Stemmer string `json:"stemmer,omitempty"`
+
+	// EdgeNgram indexes the leading characters of each token for prefix matching
+	EdgeNgram *NgramSettings `json:"edgeNgram,omitempty"`
+
+	// Ngram indexes every substring of each token between Min and Max
+	// characters long for substring matching ("book" in "notebook"), at the
+	// cost of a much larger index
+	Ngram *NgramSettings `json:"ngram,omitempty"`
+
+	// Tokenizer replaces the default word tokenizer with a tokenizer of the
+	// index's analysis settings or a built-in one ("whitespace", "letter",
+	// "web")
+	Tokenizer string `json:"tokenizer,omitempty"`
+
+	// CharFilters and TokenFilters name components of the index's analysis
+	// settings, applied in order before the built-in ones above. Token
+	// filters may also be built-in ("lowercase", "camelCase", "unique",
+	// "reverse", "apostrophe").
+	CharFilters  []string `json:"charFilters,omitempty"`
+	TokenFilters []string `json:"tokenFilters,omitempty"`
+
+	// Keyword also indexes the whole value, case-sensitive, as
+	// <field>.keyword for exact filters, sorting and facets
+	Keyword bool `json:"keyword,omitempty"`
+
+	// SearchAsYouType also indexes the field's word prefixes and pairs of
+	// adjacent words for /indexes/:id/search-as-you-type
+	SearchAsYouType bool `json:"searchAsYouType,omitempty"`
+}
+
+// TextAnalysisSettings configures the default analyzer of an index
+type TextAnalysisSettings struct {
+	// Lowercase lowercases tokens. Defaults to true.
+	Lowercase *bool `json:"lowercase,omitempty"`
+
+	// Stemmer reduces words to their stem in the given language ("en", "fr", ...)
+	Stemmer string `json:"stemmer,omitempty"`
+
+	// StopWordsLanguage drops the common words of a language ("en", "fr",
+	// ...) and StopWords the listed words, which are lowercased with the
+	// tokens
+	StopWordsLanguage string   `json:"stopWordsLanguage,omitempty"`
+	StopWords         []string `json:"stopWords,omitempty"`
+}
+
+// AnalysisSettings defines named analysis components for domain-specific
+// tokenization, such as SKUs and code identifiers
+type AnalysisSettings struct {
+	// Tokenizers emit each match of a regular expression as a token
+	Tokenizers map[string]RegexpTokenizer `json:"tokenizers,omitempty"`
+
+	// CharFilters replace the matches of a regular expression before the
+	// text is tokenized
+	CharFilters map[string]CharFilterSettings `json:"charFilters,omitempty"`
+
+	// TokenFilters name chains of built-in and other named token filters
+	TokenFilters map[string][]string `json:"tokenFilters,omitempty"`
+}
+
+// RegexpTokenizer splits text into the matches of Pattern
+type RegexpTokenizer struct {
+	Pattern string `json:"pattern"`
+}
+
+// CharFilterSettings replaces the matches of Pattern by Replacement, which
+// may refer to groups ("${1}")
+type CharFilterSettings struct {
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+}
+
+// NgramSettings bounds the length of generated n-grams
+type NgramSettings struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// FieldIndexCost estimates how much the n-grams of a field grow its part of
+// the index
+type FieldIndexCost struct {
+	// Mode is "ngram" or "edgeNgram"
+	Mode string `json:"mode"`
+
+	// TermsPerWord is the number of terms indexed for an 8-letter word,
+	// instead of one without n-grams
+	TermsPerWord int    `json:"termsPerWord"`
+	Note         string `json:"note"`
 }
 
 // SearchRequest represents a search request
@@ -16,11 +397,526 @@ type SearchRequest struct {
 	Sort                 []string `json:"sort,omitempty"`
 	AttributesToRetrieve []string `json:"attributesToRetrieve"`
 	AttributesToExclude  []string `json:"attributesToExclude"`
+
+	// SortOptions tune how hits are sorted by the fields of Sort, by field
+	// ({"price": {"missing": "first", "type": "number"}})
+	SortOptions map[string]SortOptions `json:"sortOptions,omitempty"`
+
+	// GroupBy buckets hits by the value of a field, GroupSize hits per group
+	// (1 by default); limit and offset then count groups
+	GroupBy   string `json:"groupBy,omitempty"`
+	GroupSize int    `json:"groupSize,omitempty"`
+
+	// Cursor pages deep results after the last hit of the previous page:
+	// "*" for the first page, then the nextCursor of the previous response
+	Cursor string `json:"cursor,omitempty"`
+
+	// Filter restricts hits to documents matching a query-string expression
+	// ("status:active"), without affecting their scores
+	Filter string `json:"filter,omitempty"`
+
+	// Ranges restrict hits to documents whose fields lie within bounds, by
+	// field ({"price": {"gte": 10, "lt": 50}}), without affecting scores
+	Ranges map[string]RangeFilter `json:"ranges,omitempty"`
+
+	// Lookups add documents of other indexes to hits by key
+	Lookups []Lookup `json:"lookups,omitempty"`
+
+	// Facets count the values of fields among all hits, by facet name
+	Facets map[string]FacetRequest `json:"facets,omitempty"`
+
+	// Indexes are searched along with the request's index: IDs or wildcard
+	// patterns ("logs-*") whose hits are merged
+	Indexes []string `json:"indexes,omitempty"`
+
+	// ExcludeTerms drops hits matching any of the words or phrases, in any field
+	ExcludeTerms []string `json:"excludeTerms,omitempty"`
+
+	// InjectID adds the document ID to hits that lack IDAttribute ("id" by
+	// default). Defaults to true.
+	InjectID    *bool  `json:"injectId,omitempty"`
+	IDAttribute string `json:"idAttribute,omitempty"`
+
+	// RecencyField and RecencyHalfLife ("12h", "7d") boost fresh documents:
+	// scores are multiplied by 0.5^(age / half-life)
+	RecencyField    string `json:"recencyField,omitempty"`
+	RecencyHalfLife string `json:"recencyHalfLife,omitempty"`
+
+	// ScoreScript recomputes the scores of the top matches from _score and
+	// document fields ("_score * log1p(popularity)") before they are paged
+	ScoreScript string `json:"scoreScript,omitempty"`
+
+	// AroundLatLng ("48.85,2.35") and AroundRadius (meters, or with a unit
+	// such as "5km") keep hits whose geo attribute lies within the circle.
+	// AroundLatLng is also the origin of the "_geoDistance" sort.
+	AroundLatLng string `json:"aroundLatLng,omitempty"`
+	AroundRadius string `json:"aroundRadius,omitempty"`
+
+	// InsideBoundingBox ("top,left,bottom,right": the latitude and longitude
+	// of the top left corner, then of the bottom right one) keeps hits whose
+	// geo attribute lies within the box
+	InsideBoundingBox string `json:"insideBoundingBox,omitempty"`
+
+	// GeoField is the geo attribute searched, by default the index's only one
+	GeoField string `json:"geoField,omitempty"`
+
+	// Vector finds the nearest neighbours of a vector, alone or blended
+	// with the scores of the query
+	Vector *VectorQuery `json:"vector,omitempty"`
+
+	// MatchingStrategy "prefix" matches the query as text being typed into
+	// the index's search-as-you-type fields, the last word as a prefix,
+	// instead of parsing it as a query string ("query", the default)
+	MatchingStrategy string `json:"matchingStrategy,omitempty"`
+
+	// ShowMeta adds a _meta object with the document's _id, _score and _index
+	ShowMeta bool `json:"showMeta,omitempty"`
+
+	// ShowMatches adds a _matches object with where the query's terms
+	// matched the returned fields, by field, for custom highlighting
+	ShowMatches bool `json:"showMatches,omitempty"`
+
+	// TimeoutMs bounds the time spent matching documents. A search running
+	// past it returns the hits found so far, with TimedOut set.
+	TimeoutMs int `json:"timeoutMs,omitempty"`
+
+	// Explain adds an _explanation object with how bleve scored the hit,
+	// including the recency boost, score script or fusion that rescored it
+	Explain bool `json:"explain,omitempty"`
+
+	// UserToken assigns the searches of a user to the same ranking variant,
+	// and Variant picks one by name
+	UserToken string `json:"userToken,omitempty"`
+	Variant   string `json:"variant,omitempty"`
+
+	// IndexID is reported as _meta._index. Callers fill it.
+	IndexID string `json:"-"`
+
+	// PointInTime is the pinned index the pages of a cursor search read,
+	// carried in their cursors. Callers fill it.
+	PointInTime string `json:"-"`
+
+	// HiddenAttributes are removed from every hit. Callers fill it from the
+	// index configuration; it is never read from a request body.
+	HiddenAttributes []string `json:"-"`
+
+	// SearchableAttributes, when set, are the boosted fields query terms
+	// without a field are matched in. Callers fill it from the index
+	// configuration.
+	SearchableAttributes map[string]float64 `json:"-"`
+
+	// SortableAttributes, when set, are the only fields the request may sort
+	// on. Callers fill it from the index configuration.
+	SortableAttributes []string `json:"-"`
+
+	// FilterableAttributes, when set, are the only fields the query may refer
+	// to. Callers fill it from the index configuration.
+	FilterableAttributes []string `json:"-"`
+
+	// GeoAttributes are the fields geo searches may use. Callers fill it from
+	// the index configuration.
+	GeoAttributes []string `json:"-"`
+
+	// Vectors are the vector fields kNN searches may use. Callers fill it
+	// from the index configuration.
+	Vectors map[string]VectorSettings `json:"-"`
+
+	// MaxLimit and MaxTotalHits bound the request when positive. Callers fill
+	// them from the index configuration.
+	MaxLimit     int `json:"-"`
+	MaxTotalHits int `json:"-"`
+
+	// DefaultLimit replaces the built-in default limit when positive.
+	// Callers fill it from the cluster settings.
+	DefaultLimit int `json:"-"`
+
+	// Synonyms expand the terms of the query. Callers fill it from the index
+	// configuration.
+	Synonyms map[string][]string `json:"-"`
+
+	// PinnedDocuments lead the results and HiddenDocuments are left out.
+	// Callers fill them from the curation rules matching the query.
+	PinnedDocuments []string `json:"-"`
+	HiddenDocuments []string `json:"-"`
+
+	// AsYouTypeFields, when set, match the query as text being typed into
+	// these search-as-you-type fields instead of parsing it as a query string
+	AsYouTypeFields []string `json:"-"`
+}
+
+// VectorSettings describe a dense vector field
+type VectorSettings struct {
+	// Dims is the length of the field's vectors (1 to 2048)
+	Dims int `json:"dims"`
+
+	// Similarity compares vectors: "l2_norm" (the default) or "dot_product"
+	Similarity string `json:"similarity,omitempty"`
+
+	// OptimizedFor trades "recall" (the default) for "latency"
+	OptimizedFor string `json:"optimizedFor,omitempty"`
+}
+
+// VectorQuery is the kNN part of a search. Without a query its K nearest
+// neighbours are the hits; with one their vector scores, multiplied by
+// Boost, are added to the scores of the query.
+type VectorQuery struct {
+	Field  string    `json:"field"`
+	Values []float32 `json:"values"`
+
+	// K is the number of neighbours found, by default every hit up to the
+	// requested page
+	K int `json:"k,omitempty"`
+
+	// Boost weighs vector scores against query scores (1 by default)
+	Boost *float64 `json:"boost,omitempty"`
+
+	// Fusion ranks hybrid searches by fusing the query's ranking with the
+	// neighbours' instead of adding their scores: "rrf" (reciprocal rank
+	// fusion) or "linear" (weighted sum of normalized scores)
+	Fusion string `json:"fusion,omitempty"`
+
+	// Weight is the share of the neighbours' scores in linear fusion, from
+	// 0 to 1 (0.5 by default)
+	Weight *float64 `json:"weight,omitempty"`
+}
+
+// RangeFilter bounds the values of a field. Bounds are numbers, or dates
+// (RFC 3339 or 2006-01-02) for date fields; unset sides are open.
+type RangeFilter struct {
+	Gt  any `json:"gt,omitempty"`
+	Gte any `json:"gte,omitempty"`
+	Lt  any `json:"lt,omitempty"`
+	Lte any `json:"lte,omitempty"`
+}
+
+// Lookup joins documents of another index into search hits: the value of
+// Key (one ID or a list) is looked up as document IDs of Index, and the
+// documents found are added under As (the index name by default)
+type Lookup struct {
+	Index  string   `json:"index"`
+	Key    string   `json:"key"`
+	Fields []string `json:"fields,omitempty"`
+	As     string   `json:"as,omitempty"`
 }
 
 // SearchResponse represents a search response
 type SearchResponse struct {
-	Hits       []map[string]any `json:"hits"`
-	TotalHits  uint64           `json:"totalHits"`
-	TotalPages int              `json:"totalPages"`
+	Hits       []map[string]any       `json:"hits"`
+	TotalHits  uint64                 `json:"totalHits"`
+	TotalPages int                    `json:"totalPages"`
+	Facets     map[string]FacetResult `json:"facets,omitempty"`
+
+	// Variant is the ranking variant of the index's experiment that served
+	// the search, to report clicks against
+	Variant string `json:"variant,omitempty"`
+
+	// NextCursor continues a search paged by cursor; it is left out on the
+	// last page
+	NextCursor string `json:"nextCursor,omitempty"`
+
+	// Groups are the hits of a search with groupBy, by value of the field.
+	// TotalGroups counts the groups among the top 1000 hits.
+	Groups      []SearchGroup `json:"groups,omitempty"`
+	TotalGroups int           `json:"totalGroups,omitempty"`
+
+	// TimedOut is set when the search ran past its timeoutMs: the hits,
+	// totals and facets only count the documents matched until then
+	TimedOut bool `json:"timedOut,omitempty"`
+}
+
+// MatchPosition is where a term of a query matched a field: the byte
+// offsets of the matched word in the field's text, its position among the
+// words, and for lists the index of the value
+type MatchPosition struct {
+	Term           string   `json:"term"`
+	Start          uint64   `json:"start"`
+	End            uint64   `json:"end"`
+	Position       uint64   `json:"position"`
+	ArrayPositions []uint64 `json:"arrayPositions,omitempty"`
+}
+
+// SearchGroup is the top hits of a search sharing a value of its groupBy
+// field, or lacking the field when Value is null
+type SearchGroup struct {
+	Value any              `json:"value"`
+	Hits  []map[string]any `json:"hits"`
+}
+
+// FacetRequest counts the values of Field among all hits of a search: the
+// Size most frequent terms (10 by default), or the hits within each of
+// NumericRanges when they are set
+type FacetRequest struct {
+	Field         string         `json:"field"`
+	Size          int            `json:"size,omitempty"`
+	NumericRanges []NumericRange `json:"numericRanges,omitempty"`
+}
+
+// NumericRange is a named bucket of a numeric facet. Min is inclusive and
+// Max exclusive; either may be left open.
+type NumericRange struct {
+	Name string   `json:"name"`
+	Min  *float64 `json:"min,omitempty"`
+	Max  *float64 `json:"max,omitempty"`
+}
+
+// FacetResult holds the counts of a facet. Total counts the values found,
+// Missing the hits without the field and Other the values beyond the
+// returned terms or outside every range.
+type FacetResult struct {
+	Field         string              `json:"field"`
+	Total         int                 `json:"total"`
+	Missing       int                 `json:"missing"`
+	Other         int                 `json:"other"`
+	Terms         []FacetTerm         `json:"terms,omitempty"`
+	NumericRanges []NumericRangeCount `json:"numericRanges,omitempty"`
+}
+
+// FacetTerm is the number of hits with a term
+type FacetTerm struct {
+	Term  string `json:"term"`
+	Count int    `json:"count"`
+}
+
+// NumericRangeCount is the number of hits within a numeric range
+type NumericRangeCount struct {
+	NumericRange
+	Count int `json:"count"`
+}
+
+// DuplicatesRequest configures duplicate detection over an index
+type DuplicatesRequest struct {
+	// Fields must be equal (ignoring case, punctuation and spacing)
+	Fields []string `json:"fields,omitempty"`
+
+	// FuzzyField must be similar, at least Threshold (0-1, default 0.8)
+	FuzzyField string  `json:"fuzzyField,omitempty"`
+	Threshold  float64 `json:"threshold,omitempty"`
+
+	// Filter restricts the scan to documents matching a query string
+	Filter string `json:"filter,omitempty"`
+
+	// MaxDocuments bounds the scan and Limit the returned clusters
+	MaxDocuments int `json:"maxDocuments,omitempty"`
+	Limit        int `json:"limit,omitempty"`
+}
+
+// DuplicateCluster is a group of candidate duplicates. Documents hold the
+// _id and compared fields of each member.
+type DuplicateCluster struct {
+	Key       string           `json:"key,omitempty"`
+	Score     float64          `json:"score"`
+	Documents []map[string]any `json:"documents"`
+}
+
+// DuplicatesResponse lists candidate duplicate clusters, largest first
+type DuplicatesResponse struct {
+	Clusters      []DuplicateCluster `json:"clusters"`
+	TotalClusters int                `json:"totalClusters"`
+	Scanned       int                `json:"scanned"`
+	Truncated     bool               `json:"truncated"`
+}
+
+// StoredQuery is a search saved on an index, matched against incoming
+// documents rather than against the index (percolation)
+type StoredQuery struct {
+	ID      string `json:"id"`
+	IndexID string `json:"indexId"`
+
+	// Query, Filter and ExcludeTerms have the same meaning as in searches
+	Query        string   `json:"q"`
+	Filter       string   `json:"filter,omitempty"`
+	ExcludeTerms []string `json:"excludeTerms,omitempty"`
+
+	// Metadata is kept for the client, for example who to notify
+	Metadata map[string]any `json:"metadata,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// CurationRule pins and hides documents in the results of the searches
+// whose query matches its pattern
+type CurationRule struct {
+	ID      string `json:"id"`
+	IndexID string `json:"indexId"`
+
+	// Pattern is compared with the search's query, ignoring case and extra
+	// spaces: the whole query with Match "exact" (the default), or any query
+	// holding it as words with Match "contains"
+	Pattern string `json:"pattern"`
+	Match   string `json:"match,omitempty"`
+
+	// Pinned documents lead the results, in this order, when they pass the
+	// search's filters. Hidden documents are left out of the results.
+	Pinned []string `json:"pinned,omitempty"`
+	Hidden []string `json:"hidden,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// SettingsExportVersion is the version of the settings exports written by
+// this server
+const SettingsExportVersion = 1
+
+// SettingsExport holds the settings of an index and its curation rules as
+// one file, to version them or copy them to another index. Settings and
+// rules carry no index ID.
+type SettingsExport struct {
+	Version  int             `json:"version"`
+	Settings *IndexConfig    `json:"settings"`
+	Rules    []*CurationRule `json:"rules"`
+}
+
+// PercolateRequest lists documents to match against an index's stored queries
+type PercolateRequest struct {
+	Documents []map[string]any `json:"documents"`
+}
+
+// PercolateResult lists the stored queries one document matches. DocumentID
+// is the document's primary key, if it has one.
+type PercolateResult struct {
+	DocumentID string   `json:"documentId,omitempty"`
+	Queries    []string `json:"queries"`
+}
+
+// PercolateResponse holds one result per percolated document, in order
+type PercolateResponse struct {
+	Results []PercolateResult `json:"results"`
+}
+
+// AlertRule fires notifications while a metric of an index, an ingress or
+// the cluster crosses a threshold
+type AlertRule struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+
+	// Metric is compared to Threshold with Operator (">", ">=", "<" or "<=").
+	// Index metrics need Index, ingress metrics need Ingress.
+	Metric    string  `json:"metric"`
+	Index     string  `json:"index,omitempty"`
+	Ingress   string  `json:"ingress,omitempty"`
+	Operator  string  `json:"operator"`
+	Threshold float64 `json:"threshold"`
+
+	// Window is the period rate metrics compare against ("1h")
+	Window string `json:"window,omitempty"`
+
+	// For is how long the condition must hold before the rule fires ("2m")
+	For string `json:"for,omitempty"`
+
+	// Webhooks receive a JSON POST and Emails a message when the rule
+	// fires and when it resolves
+	Webhooks []string `json:"webhooks,omitempty"`
+	Emails   []string `json:"emails,omitempty"`
+
+	Disabled  bool      `json:"disabled,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ScheduledSearch runs a search on a cron schedule and delivers its hits to
+// webhooks and email addresses
+type ScheduledSearch struct {
+	ID    string `json:"id"`
+	Name  string `json:"name,omitempty"`
+	Index string `json:"index"`
+
+	// Schedule is a cron expression ("0 8 * * 1-5") evaluated in Timezone
+	// (an IANA name, UTC by default)
+	Schedule string `json:"schedule"`
+	Timezone string `json:"timezone,omitempty"`
+
+	// Search is the request to run, as posted to /indexes/:id/searches
+	Search SearchRequest `json:"search"`
+
+	// SkipEmpty skips deliveries of runs without hits
+	SkipEmpty bool `json:"skipEmpty,omitempty"`
+
+	// Webhooks receive the hits as a JSON POST and Emails as a message
+	Webhooks []string `json:"webhooks,omitempty"`
+	Emails   []string `json:"emails,omitempty"`
+
+	Disabled  bool      `json:"disabled,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// IndexAlias names a series of time-partitioned indexes ("logs-000001",
+// "logs-000002", ...). Documents written through the alias go to its write
+// index, the newest partition, and searches of the alias cover every
+// partition.
+type IndexAlias struct {
+	Name       string   `json:"name"`
+	WriteIndex string   `json:"writeIndex"`
+	Indexes    []string `json:"indexes"`
+
+	// RolledOverAt is when the write index became current, from which its
+	// age is counted
+	RolledOverAt time.Time `json:"rolledOverAt"`
+}
+
+// RolloverRequest rolls an alias over to a new write index when any of its
+// conditions is met, or unconditionally when none is set
+type RolloverRequest struct {
+	Conditions RolloverConditions `json:"conditions"`
+
+	// MaxIndexes deletes the oldest partitions beyond this many after a
+	// rollover (0 keeps them all)
+	MaxIndexes int `json:"maxIndexes,omitempty"`
+
+	// DryRun reports whether the alias would roll over without changing it
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// RolloverConditions are the limits of a write index
+type RolloverConditions struct {
+	MaxDocs uint64 `json:"maxDocs,omitempty"`
+
+	// MaxAge is a Go duration or a number of days ("7d")
+	MaxAge string `json:"maxAge,omitempty"`
+
+	// MaxSizeBytes is compared with the write index's disk usage
+	MaxSizeBytes int64 `json:"maxSizeBytes,omitempty"`
+}
+
+// RolloverResponse reports the outcome of a rollover request
+type RolloverResponse struct {
+	Alias      string `json:"alias"`
+	OldIndex   string `json:"oldIndex"`
+	NewIndex   string `json:"newIndex"`
+	RolledOver bool   `json:"rolledOver"`
+	DryRun     bool   `json:"dryRun"`
+
+	// Conditions tells which conditions the old write index met
+	Conditions map[string]bool `json:"conditions"`
+
+	DeletedIndexes []string `json:"deletedIndexes,omitempty"`
+}
+
+// Search routing modes of a cluster
+const (
+	// RoutingLocal searches the node that received the request
+	RoutingLocal = "local"
+
+	// RoutingHints searches it as well, and names the node that should take
+	// the next searches in an X-Bright-Route response header
+	RoutingHints = "hints"
+
+	// RoutingProxy forwards searches to the least-loaded node
+	RoutingProxy = "proxy"
+)
+
+// DefaultRoutingMaxLag is the number of Raft log entries a node may be
+// behind the most up-to-date one and still be routed searches
+const DefaultRoutingMaxLag = 1000
+
+// RoutingPolicy decides which node of a cluster serves its searches
+type RoutingPolicy struct {
+	// Mode is local (the default), hints or proxy
+	Mode string `json:"mode"`
+
+	// MaxLag is how many log entries a node may be behind to be routed
+	// searches (DefaultRoutingMaxLag if zero)
+	MaxLag uint64 `json:"max_lag"`
+
+	// PreferTags keeps searches on the nodes sharing the receiving node's
+	// values of these tags ("zone"), while any of them is eligible
+	PreferTags []string `json:"prefer_tags,omitempty"`
 }
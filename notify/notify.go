@@ -0,0 +1,127 @@
+// Package notify delivers notifications to webhooks and email addresses.
+// It is shared by alerting rules and scheduled searches.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// webhookTimeout bounds a single webhook call
+const webhookTimeout = 10 * time.Second
+
+// SMTPConfig is the mail server emails are sent through
+type SMTPConfig struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+// Enabled returns true if emails can be sent
+func (c SMTPConfig) Enabled() bool {
+	return c.Addr != "" && c.From != ""
+}
+
+// Sender posts JSON to webhooks and sends plain-text emails
+type Sender struct {
+	client *http.Client
+	smtp   SMTPConfig
+}
+
+// NewSender creates a sender. Emails fail unless smtp is enabled.
+func NewSender(smtp SMTPConfig) *Sender {
+	return &Sender{client: &http.Client{}, smtp: smtp}
+}
+
+// EmailEnabled returns true if emails can be sent
+func (s *Sender) EmailEnabled() bool {
+	return s.smtp.Enabled()
+}
+
+// PostJSON posts a value as JSON to a webhook
+func (s *Sender) PostJSON(ctx context.Context, url string, value any) error {
+	body, err := sonic.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Email sends a plain-text email
+func (s *Sender) Email(to []string, subject, body string) error {
+	if !s.smtp.Enabled() {
+		return fmt.Errorf("no SMTP server configured")
+	}
+
+	var auth smtp.Auth
+	if s.smtp.Username != "" {
+		host, _, err := net.SplitHostPort(s.smtp.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", s.smtp.Username, s.smtp.Password, host)
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", s.smtp.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return smtp.SendMail(s.smtp.Addr, auth, s.smtp.From, to, []byte(msg.String()))
+}
+
+// ValidateChannels checks the webhooks and emails of a notification target.
+// At least one is required, and emails need an SMTP server, so emailEnabled
+// reports whether one is configured.
+func ValidateChannels(webhooks, emails []string, emailEnabled bool) error {
+	if len(webhooks) == 0 && len(emails) == 0 {
+		return fmt.Errorf("at least one webhook or email is required")
+	}
+	for _, webhook := range webhooks {
+		u, err := url.Parse(webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid webhook URL %q", webhook)
+		}
+	}
+	if len(emails) > 0 && !emailEnabled {
+		return fmt.Errorf("email notifications need BRIGHT_SMTP_ADDR and BRIGHT_SMTP_FROM")
+	}
+	for _, email := range emails {
+		if _, err := mail.ParseAddress(email); err != nil {
+			return fmt.Errorf("invalid email address %q", email)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+// Package objectstore stores objects in S3 and S3-compatible services
+// such as MinIO, signing requests with AWS Signature Version 4.
+package objectstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultRegion is the region of buckets when none is configured
+const DefaultRegion = "us-east-1"
+
+// ErrNotFound is returned for objects that don't exist
+var ErrNotFound = errors.New("object not found")
+
+// unsignedPayload lets bodies stream without being hashed first
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// Options configure an S3 client
+type Options struct {
+	// Endpoint is the URL of the service (https://s3.<region>.amazonaws.com
+	// if empty)
+	Endpoint string
+
+	// Region of the bucket (DefaultRegion if empty)
+	Region string
+
+	Bucket    string
+	AccessKey string
+	SecretKey string
+
+	// PathStyle addresses the bucket in the path (endpoint/bucket/key)
+	// rather than the host (bucket.endpoint/key), as MinIO expects
+	PathStyle bool
+
+	// HTTPClient sends the requests (http.DefaultClient if nil)
+	HTTPClient *http.Client
+}
+
+// S3 is a client of a bucket
+type S3 struct {
+	options  Options
+	endpoint *url.URL
+	now      func() time.Time
+}
+
+// NewS3 creates a client of options.Bucket
+func NewS3(options Options) (*S3, error) {
+	if options.Bucket == "" {
+		return nil, errors.New("bucket is required")
+	}
+	if options.AccessKey == "" || options.SecretKey == "" {
+		return nil, errors.New("access key and secret key are required")
+	}
+	if options.Region == "" {
+		options.Region = DefaultRegion
+	}
+	if options.Endpoint == "" {
+		options.Endpoint = "https://s3." + options.Region + ".amazonaws.com"
+	}
+	if options.HTTPClient == nil {
+		options.HTTPClient = http.DefaultClient
+	}
+
+	endpoint, err := url.Parse(strings.TrimSuffix(options.Endpoint, "/"))
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q", options.Endpoint)
+	}
+	if !options.PathStyle {
+		endpoint.Host = options.Bucket + "." + endpoint.Host
+	}
+	return &S3{options: options, endpoint: endpoint, now: time.Now}, nil
+}
+
+// Put stores size bytes of body at key
+func (s *S3) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	req, err := s.request(ctx, http.MethodPut, key, nil, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Get returns the object at key, or ErrNotFound
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := s.request(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Delete removes the object at key. Missing objects aren't an error.
+func (s *S3) Delete(ctx context.Context, key string) error {
+	req, err := s.request(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+	return nil
+}
+
+// listResult is a page of ListObjectsV2
+type listResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List returns the keys starting with prefix, sorted
+func (s *S3) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := s.request(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.do(req)
+		if err != nil {
+			return nil, err
+		}
+		var page listResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse object list: %w", err)
+		}
+
+		for _, object := range page.Contents {
+			keys = append(keys, object.Key)
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			break
+		}
+		token = page.NextContinuationToken
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// request builds a signed request for key, or for the bucket when key is
+// empty
+func (s *S3) request(ctx context.Context, method, key string, query url.Values, body io.Reader) (*http.Request, error) {
+	u := *s.endpoint
+	path := u.Path
+	if s.options.PathStyle {
+		path += "/" + s.options.Bucket
+	}
+	path += "/" + key
+	u.Path = path
+	u.RawPath = encodePath(path)
+	u.RawQuery = encodeQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	s.sign(req, u.RawPath, u.RawQuery)
+	return req, nil
+}
+
+// do sends a request, turning error responses into errors
+func (s *S3) do(req *http.Request) (*http.Response, error) {
+	resp, err := s.options.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed: %w", req.Method, req.URL.Path, err)
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	var apiErr struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	xml.Unmarshal(data, &apiErr)
+	if resp.StatusCode == http.StatusNotFound && apiErr.Code != "NoSuchBucket" {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, req.URL.Path)
+	}
+	if apiErr.Code != "" {
+		return nil, fmt.Errorf("%s %s failed with %d: %s: %s", req.Method, req.URL.Path, resp.StatusCode, apiErr.Code, apiErr.Message)
+	}
+	return nil, fmt.Errorf("%s %s failed with %d", req.Method, req.URL.Path, resp.StatusCode)
+}
+
+// sign adds the Signature Version 4 headers of a request
+func (s *S3) sign(req *http.Request, rawPath, rawQuery string) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		rawPath,
+		rawQuery,
+		"host:" + req.URL.Host + "\n" + "x-amz-content-sha256:" + unsignedPayload + "\n" + "x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+
+	scope := date + "/" + s.options.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256(canonicalRequest)
+
+	key := hmacSHA256([]byte("AWS4"+s.options.SecretKey), date)
+	key = hmacSHA256(key, s.options.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.options.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// encodePath escapes a path as Signature Version 4 expects, keeping slashes
+func encodePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = encode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// encodeQuery escapes a query as Signature Version 4 expects, sorted by
+// name
+func encodeQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, encode(name)+"="+encode(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// encode percent-encodes every byte but unreserved characters
+func encode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
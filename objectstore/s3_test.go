@@ -0,0 +1,130 @@
+package objectstore
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 serves a path-style bucket from memory, listing two keys per page
+type fakeS3 struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string][]byte
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") || r.Header.Get("X-Amz-Date") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>"))
+		return
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+s.bucket+"/")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("<Error><Code>NoSuchBucket</Code><Message>The specified bucket does not exist</Message></Error>"))
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		s.objects[key] = data
+	case r.Method == http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case key == "" && r.URL.Query().Get("list-type") == "2":
+		var keys []string
+		for k := range s.objects {
+			if strings.HasPrefix(k, r.URL.Query().Get("prefix")) && k > r.URL.Query().Get("continuation-token") {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		var page listResult
+		if len(keys) > 2 {
+			keys = keys[:2]
+			page.IsTruncated, page.NextContinuationToken = true, keys[1]
+		}
+		for _, k := range keys {
+			page.Contents = append(page.Contents, struct {
+				Key string `xml:"Key"`
+			}{k})
+		}
+		xml.NewEncoder(w).Encode(page)
+	default:
+		data, ok := s.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>"))
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func TestS3(t *testing.T) {
+	fake := &fakeS3{bucket: "backups", objects: make(map[string][]byte)}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client, err := NewS3(Options{Endpoint: server.URL, Bucket: "backups", AccessKey: "key", SecretKey: "secret", PathStyle: true})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	ctx := context.Background()
+
+	for _, key := range []string{"snapshots/1 a/state.bin", "snapshots/2/state.bin", "snapshots/3/state.bin", "other"} {
+		if err := client.Put(ctx, key, strings.NewReader("data of "+key), int64(len("data of "+key))); err != nil {
+			t.Fatalf("Failed to put %s: %v", key, err)
+		}
+	}
+	if string(fake.objects["snapshots/1 a/state.bin"]) != "data of snapshots/1 a/state.bin" {
+		t.Errorf("Expected the key to be stored as given, got %v", fake.objects)
+	}
+
+	keys, err := client.List(ctx, "snapshots/")
+	if err != nil {
+		t.Fatalf("Failed to list: %v", err)
+	}
+	if len(keys) != 3 || keys[0] != "snapshots/1 a/state.bin" || keys[2] != "snapshots/3/state.bin" {
+		t.Errorf("Expected the 3 snapshot keys across pages, got %v", keys)
+	}
+
+	body, err := client.Get(ctx, "snapshots/2/state.bin")
+	if err != nil {
+		t.Fatalf("Failed to get: %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "data of snapshots/2/state.bin" {
+		t.Errorf("Expected the object's data, got %q", data)
+	}
+
+	if err := client.Delete(ctx, "snapshots/2/state.bin"); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if _, err := client.Get(ctx, "snapshots/2/state.bin"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the deleted object not to be found, got %v", err)
+	}
+
+	// Errors of the service are reported with their code
+	denied, _ := NewS3(Options{Endpoint: server.URL, Bucket: "backups", AccessKey: "other", SecretKey: "secret", PathStyle: true})
+	if err := denied.Put(ctx, "x", strings.NewReader("x"), 1); err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("Expected access to be denied, got %v", err)
+	}
+	missing, _ := NewS3(Options{Endpoint: server.URL, Bucket: "missing", AccessKey: "key", SecretKey: "secret", PathStyle: true})
+	if _, err := missing.Get(ctx, "x"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a missing bucket to fail, got %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package persist
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// File layout for a persisted file "configs.json":
+//
+//	configs.json         current generation
+//	configs.json.sha256  checksum of the current generation
+//	configs.json.bak     previous generation
+//	configs.json.bak.sha256
+//
+// Files written by older versions have no checksum and are accepted as is.
+
+const (
+	checksumSuffix = ".sha256"
+	backupSuffix   = ".bak"
+	tempSuffix     = ".tmp"
+)
+
+// ErrChecksumMismatch is returned when a file does not match its recorded checksum
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// WriteFile atomically replaces path with data. The data is written to a
+// temporary file, fsynced and renamed into place; the replaced generation
+// is kept as a backup to fall back on if the new one turns out corrupt.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp := path + tempSuffix
+	if err := writeSynced(tmp, data, perm); err != nil {
+		return err
+	}
+
+	// Keep the current generation as the backup, if it is intact
+	if _, err := readVerified(path); err == nil {
+		if err := os.Rename(path, path+backupSuffix); err != nil {
+			return fmt.Errorf("failed to rotate %s: %w", filepath.Base(path), err)
+		}
+		if err := os.Remove(path + backupSuffix + checksumSuffix); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate checksum of %s: %w", filepath.Base(path), err)
+		}
+		if err := renameIfExists(path+checksumSuffix, path+backupSuffix+checksumSuffix); err != nil {
+			return fmt.Errorf("failed to rotate checksum of %s: %w", filepath.Base(path), err)
+		}
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", filepath.Base(path), err)
+	}
+
+	if err := writeSynced(path+checksumSuffix+tempSuffix, []byte(checksum(data)+"\n"), perm); err != nil {
+		return err
+	}
+	if err := os.Rename(path+checksumSuffix+tempSuffix, path+checksumSuffix); err != nil {
+		return fmt.Errorf("failed to replace checksum of %s: %w", filepath.Base(path), err)
+	}
+
+	return syncDir(dir)
+}
+
+// ReadFile reads a file written by WriteFile, verifying its checksum. If the
+// current generation is missing or corrupt the previous one is returned and
+// recovered is set. An error satisfying os.IsNotExist is returned when no
+// generation exists.
+func ReadFile(path string) (data []byte, recovered bool, err error) {
+	data, err = readVerified(path)
+	if err == nil {
+		return data, false, nil
+	}
+
+	backup, backupErr := readVerified(path + backupSuffix)
+	if backupErr == nil {
+		return backup, true, nil
+	}
+
+	if errors.Is(err, fs.ErrNotExist) && errors.Is(backupErr, fs.ErrNotExist) {
+		return nil, false, err
+	}
+
+	return nil, false, fmt.Errorf("%s is unreadable and no intact previous generation exists: %w", filepath.Base(path), err)
+}
+
+// readVerified reads a file and checks it against its checksum, if any
+func readVerified(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	expected, err := os.ReadFile(path + checksumSuffix)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return data, nil
+		}
+		return nil, err
+	}
+
+	if strings.TrimSpace(string(expected)) != checksum(data) {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), ErrChecksumMismatch)
+	}
+
+	return data, nil
+}
+
+// checksum returns the hex-encoded SHA-256 of data
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeSynced writes data to path and fsyncs it
+func writeSynced(path string, data []byte, perm os.FileMode) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Base(path), err)
+	}
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync %s: %w", filepath.Base(path), err)
+	}
+
+	return file.Close()
+}
+
+// renameIfExists renames src to dst, ignoring a missing src
+func renameIfExists(src, dst string) error {
+	if err := os.Rename(src, dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// syncDir fsyncs a directory so renames within it are durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open directory: %w", err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package persist
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestReadFileFallsBackToPreviousGeneration tests that a corrupt file is
+// detected by its checksum and the previous generation is returned instead
+func TestReadFileFallsBackToPreviousGeneration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "configs.json")
+
+	if err := WriteFile(path, []byte(`{"a":1}`), 0644); err != nil {
+		t.Fatalf("Failed to write first generation: %v", err)
+	}
+	if err := WriteFile(path, []byte(`{"a":2}`), 0644); err != nil {
+		t.Fatalf("Failed to write second generation: %v", err)
+	}
+
+	data, recovered, err := ReadFile(path)
+	if err != nil || recovered || string(data) != `{"a":2}` {
+		t.Fatalf("Expected intact second generation, got %q recovered=%v err=%v", data, recovered, err)
+	}
+
+	// Simulate a torn write of the current generation
+	if err := os.WriteFile(path, []byte(`{"a":`), 0644); err != nil {
+		t.Fatalf("Failed to corrupt file: %v", err)
+	}
+
+	data, recovered, err = ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected fallback to previous generation, got error: %v", err)
+	}
+	if !recovered || string(data) != `{"a":1}` {
+		t.Errorf("Expected recovered first generation, got %q recovered=%v", data, recovered)
+	}
+}
+
+// TestReadFileMissing tests that a missing file reports os.IsNotExist
+func TestReadFileMissing(t *testing.T) {
+	_, _, err := ReadFile(filepath.Join(t.TempDir(), "missing.json"))
+	if !os.IsNotExist(err) {
+		t.Errorf("Expected not-exist error, got %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package raft
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/hashicorp/raft"
+)
+
+// Change feed bounds
+const (
+	// DefaultChangesLimit is the number of changes returned when no limit
+	// is given
+	DefaultChangesLimit = 100
+
+	// MaxChangesLimit bounds the changes returned at once
+	MaxChangesLimit = 1000
+)
+
+// ErrChangesCompacted is returned when the changes asked for were compacted
+// into a snapshot and are no longer in the log
+var ErrChangesCompacted = errors.New("changes were compacted out of the log")
+
+// Change is a command committed to the log, at its log index
+type Change struct {
+	Index uint64 `json:"index"`
+	Command
+	AppendedAt time.Time `json:"appended_at"`
+}
+
+// ChangeFeed is a page of the change feed. The next page starts after
+// LastIndex, which is AppliedIndex once the reader is caught up.
+type ChangeFeed struct {
+	Changes      []Change `json:"changes"`
+	LastIndex    uint64   `json:"last_index"`
+	AppliedIndex uint64   `json:"applied_index"`
+}
+
+// AppliedIndex returns the index of the last log entry applied on this node
+func (r *RaftNode) AppliedIndex() uint64 {
+	return r.raft.AppliedIndex()
+}
+
+// Changes returns up to limit commands applied on this node after the log
+// index after, for standbys replicating the cluster. Entries that are not
+// commands, the replication positions of this cluster if it is itself a
+// standby, and its search routing policy and cluster settings are skipped.
+func (r *RaftNode) Changes(after uint64, limit int) (*ChangeFeed, error) {
+	applied := r.raft.AppliedIndex()
+	feed := &ChangeFeed{Changes: []Change{}, LastIndex: after, AppliedIndex: applied}
+	if after >= applied {
+		return feed, nil
+	}
+
+	first, err := r.logStore.FirstIndex()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log: %w", err)
+	}
+	if first == 0 || after+1 < first {
+		return nil, ErrChangesCompacted
+	}
+
+	for index := after + 1; index <= applied && len(feed.Changes) < limit; index++ {
+		var entry raft.Log
+		if err := r.logStore.GetLog(index, &entry); err != nil {
+			// Compacted while reading
+			if errors.Is(err, raft.ErrLogNotFound) {
+				return nil, ErrChangesCompacted
+			}
+			return nil, fmt.Errorf("failed to read log entry %d: %w", index, err)
+		}
+		feed.LastIndex = index
+
+		if entry.Type != raft.LogCommand {
+			continue
+		}
+		var cmd Command
+		if err := sonic.Unmarshal(entry.Data, &cmd); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal command %d: %w", index, err)
+		}
+		switch cmd.Type {
+		case CommandSetReplicationPosition, CommandSetRoutingPolicy, CommandUpdateSettings:
+			continue
+		}
+		feed.Changes = append(feed.Changes, Change{Index: index, Command: cmd, AppendedAt: entry.AppendedAt})
+	}
+	return feed, nil
+}
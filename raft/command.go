@@ -6,7 +6,7 @@ import "encoding/json"
 type CommandType string
 
 const (
-	// Index operations
+	// Index operations; create and update carry a full models.IndexConfig
 	CommandCreateIndex CommandType = "create_index"
 	CommandDeleteIndex CommandType = "delete_index"
 	CommandUpdateIndex CommandType = "update_index"
@@ -16,9 +16,46 @@ const (
 	CommandDeleteDocument  CommandType = "delete_document"
 	CommandDeleteDocuments CommandType = "delete_documents"
 	CommandUpdateDocument  CommandType = "update_document"
+	CommandUpdateDocuments CommandType = "update_documents"
 
 	// Compound operations
 	CommandAutoCreateAndAddDocuments CommandType = "auto_create_and_add_documents"
+
+	// API key operations
+	CommandCreateKey CommandType = "create_key"
+	CommandRevokeKey CommandType = "revoke_key"
+
+	// Stored query operations
+	CommandPutQuery    CommandType = "put_query"
+	CommandDeleteQuery CommandType = "delete_query"
+
+	// Curation rule operations
+	CommandPutRule    CommandType = "put_rule"
+	CommandDeleteRule CommandType = "delete_rule"
+
+	// Alert rule operations
+	CommandPutAlert    CommandType = "put_alert"
+	CommandDeleteAlert CommandType = "delete_alert"
+
+	// Scheduled search operations
+	CommandPutSchedule    CommandType = "put_schedule"
+	CommandDeleteSchedule CommandType = "delete_schedule"
+
+	// Index alias operations
+	CommandPutAlias CommandType = "put_alias"
+
+	// Shadow reindex operations
+	CommandStartReindex  CommandType = "start_reindex"
+	CommandCancelReindex CommandType = "cancel_reindex"
+
+	// Cross-cluster replication operations
+	CommandSetReplicationPosition CommandType = "set_replication_position"
+
+	// Search routing operations
+	CommandSetRoutingPolicy CommandType = "set_routing_policy"
+
+	// Cluster settings operations
+	CommandUpdateSettings CommandType = "update_settings"
 )
 
 // Command represents a replicated operation that flows through Raft consensus
@@ -29,42 +66,37 @@ type Command struct {
 
 // Index operation payloads
 
-// CreateIndexPayload contains data for creating an index
-type CreateIndexPayload struct {
-	ID         string `json:"id"`
-	PrimaryKey string `json:"primaryKey"`
-}
-
 // DeleteIndexPayload contains data for deleting an index
 type DeleteIndexPayload struct {
 	ID string `json:"id"`
 }
 
-// UpdateIndexPayload contains data for updating an index configuration
-type UpdateIndexPayload struct {
-	ID         string `json:"id"`
-	PrimaryKey string `json:"primaryKey"`
-}
-
 // Document operation payloads
 
 // AddDocumentsPayload contains data for adding documents to an index
 type AddDocumentsPayload struct {
-	IndexID   string           `json:"index_id"`
-	Documents []map[string]any `json:"documents"`
+	IndexID        string           `json:"index_id"`
+	Documents      []map[string]any `json:"documents"`
+	IdempotencyKey string           `json:"idempotency_key,omitempty"`
 }
 
 // DeleteDocumentPayload contains data for deleting a single document
 type DeleteDocumentPayload struct {
-	IndexID    string `json:"index_id"`
-	DocumentID string `json:"document_id"`
+	IndexID        string `json:"index_id"`
+	DocumentID     string `json:"document_id"`
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
 }
 
 // DeleteDocumentsPayload contains data for deleting multiple documents
 type DeleteDocumentsPayload struct {
-	IndexID string   `json:"index_id"`
-	Filter  string   `json:"filter"`
-	IDs     []string `json:"ids"`
+	IndexID        string   `json:"index_id"`
+	Filter         string   `json:"filter"`
+	IDs            []string `json:"ids"`
+	IdempotencyKey string   `json:"idempotency_key,omitempty"`
+
+	// Count is the size of the whole request when its IDs are split across
+	// commands; the last command carries it with the idempotency key
+	Count int `json:"count,omitempty"`
 }
 
 // UpdateDocumentPayload contains data for updating a document
@@ -74,9 +106,87 @@ type UpdateDocumentPayload struct {
 	Updates    map[string]any `json:"updates"`
 }
 
+// UpdateDocumentsPayload contains partial documents, identified by primary
+// key, to merge into stored documents
+type UpdateDocumentsPayload struct {
+	IndexID        string           `json:"index_id"`
+	Updates        []map[string]any `json:"updates"`
+	IdempotencyKey string           `json:"idempotency_key,omitempty"`
+}
+
 // AutoCreateAndAddDocumentsPayload contains data for auto-creating an index and adding documents
 type AutoCreateAndAddDocumentsPayload struct {
-	IndexID    string           `json:"index_id"`
-	PrimaryKey string           `json:"primary_key"`
-	Documents  []map[string]any `json:"documents"`
+	IndexID            string           `json:"index_id"`
+	PrimaryKey         string           `json:"primary_key"`
+	PrimaryKeyInferred bool             `json:"primary_key_inferred,omitempty"`
+	Documents          []map[string]any `json:"documents"`
+	IdempotencyKey     string           `json:"idempotency_key,omitempty"`
+}
+
+// API key operation payloads
+
+// RevokeKeyPayload contains data for revoking an API key
+type RevokeKeyPayload struct {
+	ID string `json:"id"`
+}
+
+// Stored query operation payloads
+
+// Put query commands carry a full models.StoredQuery
+
+// DeleteQueryPayload contains data for deleting a stored query
+type DeleteQueryPayload struct {
+	IndexID string `json:"index_id"`
+	ID      string `json:"id"`
+}
+
+// Curation rule operation payloads
+
+// Put rule commands carry a full models.CurationRule
+
+// DeleteRulePayload contains data for deleting a curation rule
+type DeleteRulePayload struct {
+	IndexID string `json:"index_id"`
+	ID      string `json:"id"`
+}
+
+// Alert rule operation payloads
+
+// Put alert commands carry a full models.AlertRule
+
+// DeleteAlertPayload contains data for deleting an alert rule
+type DeleteAlertPayload struct {
+	ID string `json:"id"`
+}
+
+// Scheduled search operation payloads
+
+// Put schedule commands carry a full models.ScheduledSearch
+
+// DeleteSchedulePayload contains data for deleting a scheduled search
+type DeleteSchedulePayload struct {
+	ID string `json:"id"`
+}
+
+// Index alias operation payloads
+
+// Put alias commands carry a full models.IndexAlias
+
+// Shadow reindex operation payloads
+
+// The start reindex command carries the full models.IndexConfig to rebuild
+// the index with
+
+// CancelReindexPayload contains data for cancelling a shadow reindex
+type CancelReindexPayload struct {
+	IndexID string `json:"index_id"`
+}
+
+// Cross-cluster replication operation payloads
+
+// ReplicationPositionPayload records how far a standby applied the change
+// feed of its source
+type ReplicationPositionPayload struct {
+	Source string `json:"source"`
+	Index  uint64 `json:"index"`
 }
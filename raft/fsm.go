@@ -1,11 +1,15 @@
 package raft
 
 import (
+	"bright/keys"
 	"bright/models"
 	"bright/store"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"sync/atomic"
+	"time"
 
 	"github.com/bytedance/sonic"
 	"github.com/hashicorp/raft"
@@ -15,11 +19,15 @@ import (
 // All state mutations flow through Apply() to ensure consistency
 type FSM struct {
 	store *store.IndexStore
+	keys  *keys.Store
+
+	// snapshotsPaused refuses snapshots while the disk is nearly full
+	snapshotsPaused atomic.Bool
 }
 
-// NewFSM creates a new FSM with the given store
-func NewFSM(store *store.IndexStore) *FSM {
-	return &FSM{store: store}
+// NewFSM creates a new FSM with the given stores
+func NewFSM(store *store.IndexStore, keyStore *keys.Store) *FSM {
+	return &FSM{store: store, keys: keyStore}
 }
 
 // Apply applies a Raft log entry to the FSM
@@ -30,6 +38,18 @@ func (f *FSM) Apply(log *raft.Log) any {
 		return fmt.Errorf("failed to unmarshal command: %w", err)
 	}
 
+	return f.applyCommand(cmd, log.AppendedAt)
+}
+
+// ApplyCommand applies a command to the stores directly, without a Raft
+// log, as a node outside a cluster applies the change feed it replicates.
+// Like RaftNode.ApplyCommand, it returns the command's result.
+func (f *FSM) ApplyCommand(cmd Command, _ time.Duration) (any, error) {
+	return f.applyCommand(cmd, time.Now()), nil
+}
+
+// applyCommand applies a command appended to the log at appendedAt
+func (f *FSM) applyCommand(cmd Command, appendedAt time.Time) any {
 	switch cmd.Type {
 	case CommandCreateIndex:
 		return f.applyCreateIndex(cmd.Data)
@@ -38,23 +58,69 @@ func (f *FSM) Apply(log *raft.Log) any {
 	case CommandUpdateIndex:
 		return f.applyUpdateIndex(cmd.Data)
 	case CommandAddDocuments:
-		return f.applyAddDocuments(cmd.Data)
+		return f.applyAddDocuments(cmd.Data, appendedAt)
 	case CommandDeleteDocument:
-		return f.applyDeleteDocument(cmd.Data)
+		return f.applyDeleteDocument(cmd.Data, appendedAt)
 	case CommandDeleteDocuments:
-		return f.applyDeleteDocuments(cmd.Data)
+		return f.applyDeleteDocuments(cmd.Data, appendedAt)
 	case CommandUpdateDocument:
 		return f.applyUpdateDocument(cmd.Data)
+	case CommandUpdateDocuments:
+		return f.applyUpdateDocuments(cmd.Data, appendedAt)
 	case CommandAutoCreateAndAddDocuments:
-		return f.applyAutoCreateAndAddDocuments(cmd.Data)
+		return f.applyAutoCreateAndAddDocuments(cmd.Data, appendedAt)
+	case CommandCreateKey:
+		return f.applyCreateKey(cmd.Data)
+	case CommandRevokeKey:
+		return f.applyRevokeKey(cmd.Data)
+	case CommandPutQuery:
+		return f.applyPutQuery(cmd.Data)
+	case CommandDeleteQuery:
+		return f.applyDeleteQuery(cmd.Data)
+	case CommandPutRule:
+		return f.applyPutRule(cmd.Data)
+	case CommandDeleteRule:
+		return f.applyDeleteRule(cmd.Data)
+	case CommandPutAlert:
+		return f.applyPutAlert(cmd.Data)
+	case CommandDeleteAlert:
+		return f.applyDeleteAlert(cmd.Data)
+	case CommandPutSchedule:
+		return f.applyPutSchedule(cmd.Data)
+	case CommandDeleteSchedule:
+		return f.applyDeleteSchedule(cmd.Data)
+	case CommandPutAlias:
+		return f.applyPutAlias(cmd.Data)
+	case CommandStartReindex:
+		return f.applyStartReindex(cmd.Data)
+	case CommandCancelReindex:
+		return f.applyCancelReindex(cmd.Data)
+	case CommandSetReplicationPosition:
+		return f.applySetReplicationPosition(cmd.Data, appendedAt)
+	case CommandSetRoutingPolicy:
+		return f.applySetRoutingPolicy(cmd.Data)
+	case CommandUpdateSettings:
+		return f.applyUpdateSettings(cmd.Data)
 	default:
 		return fmt.Errorf("unknown command type: %s", cmd.Type)
 	}
 }
 
+// ErrSnapshotsPaused is returned by Snapshot while snapshots are paused.
+// Raft retries at its next snapshot interval.
+var ErrSnapshotsPaused = errors.New("snapshots are paused")
+
+// SetSnapshotsPaused pauses or resumes snapshots
+func (f *FSM) SetSnapshotsPaused(paused bool) {
+	f.snapshotsPaused.Store(paused)
+}
+
 // Snapshot returns a snapshot of the current FSM state
 func (f *FSM) Snapshot() (raft.FSMSnapshot, error) {
-	return &fsmSnapshot{store: f.store}, nil
+	if f.snapshotsPaused.Load() {
+		return nil, ErrSnapshotsPaused
+	}
+	return &fsmSnapshot{store: f.store, keys: f.keys}, nil
 }
 
 // Restore restores the FSM from a snapshot
@@ -62,29 +128,73 @@ func (f *FSM) Restore(rc io.ReadCloser) error {
 	defer rc.Close()
 
 	// Read snapshot data
-	var configs map[string]*models.IndexConfig
-	if err := json.NewDecoder(rc).Decode(&configs); err != nil {
+	raw, err := io.ReadAll(rc)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	data, err := decodeSnapshot(raw)
+	if err != nil {
 		return fmt.Errorf("failed to decode snapshot: %w", err)
 	}
 
 	// Restore configuration metadata
-	return f.store.RestoreConfigs(configs)
+	if err := f.store.RestoreConfigs(data.Configs); err != nil {
+		return err
+	}
+
+	if err := f.store.Idempotency().Restore(data.Idempotency); err != nil {
+		return err
+	}
+
+	if err := f.store.Queries().Restore(data.Queries); err != nil {
+		return err
+	}
+
+	if err := f.store.Curation().Restore(data.Rules); err != nil {
+		return err
+	}
+
+	if err := f.store.AlertRules().Restore(data.Alerts); err != nil {
+		return err
+	}
+
+	if err := f.store.Schedules().Restore(data.Schedules); err != nil {
+		return err
+	}
+
+	if err := f.store.Aliases().Restore(data.Aliases); err != nil {
+		return err
+	}
+
+	if err := f.store.RestoreReindexes(data.Reindexes); err != nil {
+		return err
+	}
+
+	if err := f.store.Replication().Restore(data.Replication); err != nil {
+		return err
+	}
+
+	if err := f.store.Routing().Restore(data.Routing); err != nil {
+		return err
+	}
+
+	if err := f.store.Settings().Restore(data.Settings); err != nil {
+		return err
+	}
+
+	return f.keys.Restore(data.Keys)
 }
 
 // Index operation apply methods
 
 func (f *FSM) applyCreateIndex(data json.RawMessage) any {
-	var payload CreateIndexPayload
-	if err := sonic.Unmarshal(data, &payload); err != nil {
+	var config models.IndexConfig
+	if err := sonic.Unmarshal(data, &config); err != nil {
 		return err
 	}
 
-	config := &models.IndexConfig{
-		ID:         payload.ID,
-		PrimaryKey: payload.PrimaryKey,
-	}
-
-	return f.store.CreateIndexInternal(config)
+	return f.store.CreateIndexInternal(&config)
 }
 
 func (f *FSM) applyDeleteIndex(data json.RawMessage) any {
@@ -97,46 +207,87 @@ func (f *FSM) applyDeleteIndex(data json.RawMessage) any {
 }
 
 func (f *FSM) applyUpdateIndex(data json.RawMessage) any {
-	var payload UpdateIndexPayload
-	if err := sonic.Unmarshal(data, &payload); err != nil {
+	var config models.IndexConfig
+	if err := sonic.Unmarshal(data, &config); err != nil {
 		return err
 	}
 
-	config := &models.IndexConfig{
-		ID:         payload.ID,
-		PrimaryKey: payload.PrimaryKey,
-	}
-
-	return f.store.UpdateIndexInternal(payload.ID, config)
+	return f.store.UpdateIndexInternal(config.ID, &config)
 }
 
 // Document operation apply methods
 
-func (f *FSM) applyAddDocuments(data json.RawMessage) any {
+// applyIdempotent runs a document write unless its idempotency key was
+// already applied, and records the key afterwards. The log's append time is
+// used so every node records the same timestamp and expires keys alike.
+func (f *FSM) applyIdempotent(indexID, key, operation string, count int, appendedAt time.Time, apply func() error) any {
+	if key == "" {
+		return apply()
+	}
+
+	if appendedAt.IsZero() {
+		appendedAt = time.Now()
+	}
+
+	registry := f.store.Idempotency()
+	if _, applied := registry.Lookup(indexID, key, appendedAt); applied {
+		return nil
+	}
+
+	if err := apply(); err != nil {
+		return err
+	}
+
+	registry.Apply(store.IdempotencyRecord{
+		IndexID:   indexID,
+		Key:       key,
+		Operation: operation,
+		Count:     count,
+		AppliedAt: appendedAt.UTC(),
+	})
+	return nil
+}
+
+func (f *FSM) applyAddDocuments(data json.RawMessage, appendedAt time.Time) any {
 	var payload AddDocumentsPayload
 	if err := sonic.Unmarshal(data, &payload); err != nil {
 		return err
 	}
 
-	return f.store.AddDocumentsInternal(payload.IndexID, payload.Documents)
+	return f.applyIdempotent(payload.IndexID, payload.IdempotencyKey, store.OperationAddDocuments, len(payload.Documents), appendedAt, func() error {
+		return f.store.AddDocumentsInternal(payload.IndexID, payload.Documents)
+	})
 }
 
-func (f *FSM) applyDeleteDocument(data json.RawMessage) any {
+func (f *FSM) applyDeleteDocument(data json.RawMessage, appendedAt time.Time) any {
 	var payload DeleteDocumentPayload
 	if err := sonic.Unmarshal(data, &payload); err != nil {
 		return err
 	}
 
-	return f.store.DeleteDocumentInternal(payload.IndexID, payload.DocumentID)
+	return f.applyIdempotent(payload.IndexID, payload.IdempotencyKey, store.OperationDeleteDocument, 1, appendedAt, func() error {
+		return f.store.DeleteDocumentInternal(payload.IndexID, payload.DocumentID)
+	})
 }
 
-func (f *FSM) applyDeleteDocuments(data json.RawMessage) any {
+func (f *FSM) applyDeleteDocuments(data json.RawMessage, appendedAt time.Time) any {
 	var payload DeleteDocumentsPayload
 	if err := sonic.Unmarshal(data, &payload); err != nil {
 		return err
 	}
 
-	return f.store.DeleteDocumentsInternal(payload.IndexID, payload.Filter, payload.IDs)
+	count := len(payload.IDs)
+	if payload.Count > 0 {
+		count = payload.Count
+	}
+
+	return f.applyIdempotent(payload.IndexID, payload.IdempotencyKey, store.OperationDeleteDocuments, count, appendedAt, func() error {
+		// A delete that matched nothing only records its idempotency key
+		if len(payload.IDs) == 0 && payload.Filter == "" {
+			return nil
+		}
+		return f.store.DeleteDocumentsInternal(payload.IndexID, payload.Filter, payload.IDs)
+	})
 }
 
 func (f *FSM) applyUpdateDocument(data json.RawMessage) any {
@@ -148,29 +299,214 @@ func (f *FSM) applyUpdateDocument(data json.RawMessage) any {
 	return f.store.UpdateDocumentInternal(payload.IndexID, payload.DocumentID, payload.Updates)
 }
 
-func (f *FSM) applyAutoCreateAndAddDocuments(data json.RawMessage) any {
+func (f *FSM) applyUpdateDocuments(data json.RawMessage, appendedAt time.Time) any {
+	var payload UpdateDocumentsPayload
+	if err := sonic.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+
+	return f.applyIdempotent(payload.IndexID, payload.IdempotencyKey, store.OperationUpdateDocuments, len(payload.Updates), appendedAt, func() error {
+		// The leader checked that all documents exist; any deleted since are skipped
+		_, err := f.store.UpdateDocumentsInternal(payload.IndexID, payload.Updates)
+		return err
+	})
+}
+
+func (f *FSM) applyAutoCreateAndAddDocuments(data json.RawMessage, appendedAt time.Time) any {
 	var payload AutoCreateAndAddDocumentsPayload
 	if err := sonic.Unmarshal(data, &payload); err != nil {
 		return err
 	}
 
-	// Check if index already exists (race safety)
-	_, _, err := f.store.GetIndex(payload.IndexID)
-	if err == nil {
-		// Index already exists, just add documents
+	return f.applyIdempotent(payload.IndexID, payload.IdempotencyKey, store.OperationAddDocuments, len(payload.Documents), appendedAt, func() error {
+		// Check if index already exists (race safety)
+		if _, _, err := f.store.GetIndex(payload.IndexID); err != nil {
+			// Create the index first
+			config := &models.IndexConfig{
+				ID:                 payload.IndexID,
+				PrimaryKey:         payload.PrimaryKey,
+				PrimaryKeyInferred: payload.PrimaryKeyInferred,
+			}
+
+			if err := f.store.CreateIndexInternal(config); err != nil {
+				return err
+			}
+		}
+
+		// Then add documents
 		return f.store.AddDocumentsInternal(payload.IndexID, payload.Documents)
+	})
+}
+
+// API key operation apply methods
+
+func (f *FSM) applyCreateKey(data json.RawMessage) any {
+	var key keys.Key
+	if err := sonic.Unmarshal(data, &key); err != nil {
+		return err
+	}
+
+	return f.keys.Add(&key)
+}
+
+func (f *FSM) applyRevokeKey(data json.RawMessage) any {
+	var payload RevokeKeyPayload
+	if err := sonic.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+
+	return f.keys.Revoke(payload.ID)
+}
+
+// Stored query apply methods
+
+func (f *FSM) applyPutQuery(data json.RawMessage) any {
+	var query models.StoredQuery
+	if err := sonic.Unmarshal(data, &query); err != nil {
+		return err
+	}
+
+	if _, _, err := f.store.GetIndex(query.IndexID); err != nil {
+		return err
+	}
+	return f.store.Queries().Put(&query)
+}
+
+func (f *FSM) applyDeleteQuery(data json.RawMessage) any {
+	var payload DeleteQueryPayload
+	if err := sonic.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+
+	return f.store.Queries().Delete(payload.IndexID, payload.ID)
+}
+
+// Curation rule apply methods
+
+func (f *FSM) applyPutRule(data json.RawMessage) any {
+	var rule models.CurationRule
+	if err := sonic.Unmarshal(data, &rule); err != nil {
+		return err
+	}
+
+	if _, _, err := f.store.GetIndex(rule.IndexID); err != nil {
+		return err
+	}
+	return f.store.Curation().Put(&rule)
+}
+
+func (f *FSM) applyDeleteRule(data json.RawMessage) any {
+	var payload DeleteRulePayload
+	if err := sonic.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+
+	return f.store.Curation().Delete(payload.IndexID, payload.ID)
+}
+
+// Alert rule apply methods
+
+func (f *FSM) applyPutAlert(data json.RawMessage) any {
+	var rule models.AlertRule
+	if err := sonic.Unmarshal(data, &rule); err != nil {
+		return err
 	}
 
-	// Create the index first
-	config := &models.IndexConfig{
-		ID:         payload.IndexID,
-		PrimaryKey: payload.PrimaryKey,
+	return f.store.AlertRules().Put(&rule)
+}
+
+func (f *FSM) applyDeleteAlert(data json.RawMessage) any {
+	var payload DeleteAlertPayload
+	if err := sonic.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+
+	return f.store.AlertRules().Delete(payload.ID)
+}
+
+// Scheduled search apply methods
+
+func (f *FSM) applyPutSchedule(data json.RawMessage) any {
+	var schedule models.ScheduledSearch
+	if err := sonic.Unmarshal(data, &schedule); err != nil {
+		return err
 	}
 
-	if err := f.store.CreateIndexInternal(config); err != nil {
+	return f.store.Schedules().Put(&schedule)
+}
+
+func (f *FSM) applyDeleteSchedule(data json.RawMessage) any {
+	var payload DeleteSchedulePayload
+	if err := sonic.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+
+	return f.store.Schedules().Delete(payload.ID)
+}
+
+// Index alias apply methods
+
+func (f *FSM) applyPutAlias(data json.RawMessage) any {
+	var alias models.IndexAlias
+	if err := sonic.Unmarshal(data, &alias); err != nil {
+		return err
+	}
+
+	return f.store.Aliases().Put(&alias)
+}
+
+// Shadow reindex apply methods
+
+func (f *FSM) applyStartReindex(data json.RawMessage) any {
+	var config models.IndexConfig
+	if err := sonic.Unmarshal(data, &config); err != nil {
+		return err
+	}
+
+	return f.store.StartReindex(config.ID, &config)
+}
+
+func (f *FSM) applyCancelReindex(data json.RawMessage) any {
+	var payload CancelReindexPayload
+	if err := sonic.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+
+	return f.store.CancelReindex(payload.IndexID)
+}
+
+// Cross-cluster replication apply methods
+
+func (f *FSM) applySetReplicationPosition(data json.RawMessage, appendedAt time.Time) any {
+	var payload ReplicationPositionPayload
+	if err := sonic.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+
+	if appendedAt.IsZero() {
+		appendedAt = time.Now()
+	}
+	return f.store.Replication().SetPosition(payload.Source, payload.Index, appendedAt)
+}
+
+// Search routing apply methods
+
+func (f *FSM) applySetRoutingPolicy(data json.RawMessage) any {
+	var policy models.RoutingPolicy
+	if err := sonic.Unmarshal(data, &policy); err != nil {
+		return err
+	}
+
+	return f.store.Routing().Set(policy)
+}
+
+// Cluster settings apply methods
+
+func (f *FSM) applyUpdateSettings(data json.RawMessage) any {
+	var changes map[string]any
+	if err := sonic.Unmarshal(data, &changes); err != nil {
 		return err
 	}
 
-	// Then add documents
-	return f.store.AddDocumentsInternal(payload.IndexID, payload.Documents)
+	return f.store.Settings().Update(changes)
 }
@@ -0,0 +1,137 @@
+package raft
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb"
+)
+
+// BoltFileReport describes the state of one of the BoltDB Raft stores
+type BoltFileReport struct {
+	Path      string `json:"path"`
+	SizeBytes int64  `json:"sizeBytes"`
+	Error     string `json:"error,omitempty"`
+}
+
+// SnapshotReport summarizes a Raft snapshot on disk
+type SnapshotReport struct {
+	ID    string `json:"id"`
+	Index uint64 `json:"index"`
+	Term  uint64 `json:"term"`
+	Size  int64  `json:"size"`
+}
+
+// StoreReport describes the health of a Raft directory
+type StoreReport struct {
+	Dir         string           `json:"dir"`
+	Present     bool             `json:"present"`
+	LogStore    BoltFileReport   `json:"logStore"`
+	FirstIndex  uint64           `json:"firstIndex"`
+	LastIndex   uint64           `json:"lastIndex"`
+	StableStore BoltFileReport   `json:"stableStore"`
+	CurrentTerm uint64           `json:"currentTerm"`
+	Snapshots   []SnapshotReport `json:"snapshots"`
+	Error       string           `json:"error,omitempty"`
+}
+
+// Healthy returns true if every Raft store could be read
+func (r *StoreReport) Healthy() bool {
+	return r.Error == "" && r.LogStore.Error == "" && r.StableStore.Error == ""
+}
+
+// InspectDir examines a Raft directory read-only, without starting a node
+func InspectDir(raftDir string) *StoreReport {
+	report := &StoreReport{
+		Dir:       raftDir,
+		Snapshots: []SnapshotReport{},
+	}
+
+	if _, err := os.Stat(raftDir); err != nil {
+		if !os.IsNotExist(err) {
+			report.Error = err.Error()
+		}
+		return report
+	}
+	report.Present = true
+
+	logStore, logReport := openBoltReadOnly(filepath.Join(raftDir, "raft-log.db"))
+	report.LogStore = logReport
+	if logStore != nil {
+		if first, err := logStore.FirstIndex(); err != nil {
+			report.LogStore.Error = err.Error()
+		} else {
+			report.FirstIndex = first
+		}
+		if last, err := logStore.LastIndex(); err != nil {
+			report.LogStore.Error = err.Error()
+		} else {
+			report.LastIndex = last
+		}
+		logStore.Close()
+	}
+
+	stableStore, stableReport := openBoltReadOnly(filepath.Join(raftDir, "raft-stable.db"))
+	report.StableStore = stableReport
+	if stableStore != nil {
+		// A missing key means the node never voted; that's not an error
+		if term, err := stableStore.GetUint64([]byte("CurrentTerm")); err == nil {
+			report.CurrentTerm = term
+		}
+		stableStore.Close()
+	}
+
+	// NewFileSnapshotStore creates the snapshots directory, so only list existing ones
+	if _, err := os.Stat(filepath.Join(raftDir, "snapshots")); err == nil {
+		snapshotStore, err := raft.NewFileSnapshotStore(raftDir, 3, io.Discard)
+		if err != nil {
+			report.Error = err.Error()
+			return report
+		}
+		snapshots, err := snapshotStore.List()
+		if err != nil {
+			report.Error = err.Error()
+			return report
+		}
+		for _, meta := range snapshots {
+			report.Snapshots = append(report.Snapshots, SnapshotReport{
+				ID:    meta.ID,
+				Index: meta.Index,
+				Term:  meta.Term,
+				Size:  meta.Size,
+			})
+		}
+	}
+
+	return report
+}
+
+// openBoltReadOnly opens a BoltDB Raft store without write access
+func openBoltReadOnly(path string) (*raftboltdb.BoltStore, BoltFileReport) {
+	report := BoltFileReport{Path: path}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		report.Error = err.Error()
+		return nil, report
+	}
+	report.SizeBytes = info.Size()
+
+	boltStore, err := raftboltdb.New(raftboltdb.Options{
+		Path: path,
+		BoltOptions: &bolt.Options{
+			ReadOnly: true,
+			Timeout:  time.Second,
+		},
+	})
+	if err != nil {
+		report.Error = err.Error()
+		return nil, report
+	}
+
+	return boltStore, report
+}
@@ -1,6 +1,7 @@
 package raft
 
 import (
+	"bright/keys"
 	"bright/rpc"
 	"bright/store"
 	"context"
@@ -22,6 +23,8 @@ type RaftNode struct {
 	fsm       *FSM
 	config    *RaftConfig
 	transport *raft.NetworkTransport
+	logStore  raft.LogStore
+	mirror    *snapshotMirror
 	logger    *zap.Logger
 }
 
@@ -35,10 +38,11 @@ type RaftConfig struct {
 	Peers        []string    // Initial peer addresses (e.g., ["node-0.bright:7000"])
 	MasterKey    string      // Master key for authentication when joining cluster
 	RPCClient    rpc.RPCClient // RPC client for cluster communication
+	SnapshotMirror *SnapshotMirrorConfig // Mirror snapshots to object storage (optional)
 }
 
 // NewRaftNode creates and initializes a new Raft node
-func NewRaftNode(config *RaftConfig, indexStore *store.IndexStore, logger *zap.Logger) (*RaftNode, error) {
+func NewRaftNode(config *RaftConfig, indexStore *store.IndexStore, keyStore *keys.Store, logger *zap.Logger) (*RaftNode, error) {
 	// Create Raft configuration
 	raftConfig := raft.DefaultConfig()
 	raftConfig.LocalID = raft.ServerID(config.NodeID)
@@ -48,7 +52,7 @@ func NewRaftNode(config *RaftConfig, indexStore *store.IndexStore, logger *zap.L
 	raftConfig.Logger = NewHclogAdapter(logger, "raft")
 
 	// Setup FSM
-	fsm := NewFSM(indexStore)
+	fsm := NewFSM(indexStore, keyStore)
 
 	// Setup persistent stores
 	if err := os.MkdirAll(config.RaftDir, 0755); err != nil {
@@ -68,11 +72,19 @@ func NewRaftNode(config *RaftConfig, indexStore *store.IndexStore, logger *zap.L
 	}
 
 	// File-based snapshot store (keeps last 3 snapshots)
-	snapshotStore, err := raft.NewFileSnapshotStore(config.RaftDir, 3, os.Stderr)
+	fileSnapshots, err := raft.NewFileSnapshotStore(config.RaftDir, 3, os.Stderr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create snapshot store: %w", err)
 	}
 
+	// Snapshots are uploaded to object storage as they are taken
+	var snapshotStore raft.SnapshotStore = fileSnapshots
+	var mirror *snapshotMirror
+	if config.SnapshotMirror != nil {
+		mirror = newSnapshotMirror(fileSnapshots, *config.SnapshotMirror, logger)
+		snapshotStore = mirror
+	}
+
 	// Setup network transport
 	// Use advertise address if provided, otherwise use bind address
 	advertiseAddr := config.RaftAdvertise
@@ -90,14 +102,56 @@ func NewRaftNode(config *RaftConfig, indexStore *store.IndexStore, logger *zap.L
 		return nil, fmt.Errorf("failed to create transport: %w", err)
 	}
 
+	// A node without Raft state, such as one whose disk was wiped, starts
+	// from the latest mirrored snapshot. The bootstrap node recovers the
+	// cluster from it alone, since its peers may be gone as well; the others
+	// join it.
+	restored := false
+	if mirror != nil {
+		existing, err := raft.HasExistingState(logStore, stableStore, fileSnapshots)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check raft state: %w", err)
+		}
+		if !existing {
+			meta, err := mirror.restoreLatest(context.Background(), transport)
+			if err != nil {
+				return nil, fmt.Errorf("failed to restore mirrored snapshot: %w", err)
+			}
+			if meta != nil {
+				restored = true
+				logger.Info("Restored Raft snapshot from object storage",
+					zap.String("snapshot_id", meta.ID),
+					zap.Uint64("index", meta.Index),
+					zap.Uint64("term", meta.Term),
+				)
+			}
+		}
+		mirror.start()
+	}
+	if restored && config.Bootstrap {
+		configuration := raft.Configuration{
+			Servers: []raft.Server{
+				{
+					ID:      raftConfig.LocalID,
+					Address: raft.ServerAddress(advertiseAddr),
+				},
+			},
+		}
+		if err := raft.RecoverCluster(raftConfig, fsm, logStore, stableStore, snapshotStore, transport, configuration); err != nil {
+			mirror.stopMirroring()
+			return nil, fmt.Errorf("failed to recover cluster from mirrored snapshot: %w", err)
+		}
+	}
+
 	// Create Raft node
 	raftNode, err := raft.NewRaft(raftConfig, fsm, logStore, stableStore, snapshotStore, transport)
 	if err != nil {
+		mirror.stopMirroring()
 		return nil, fmt.Errorf("failed to create raft node: %w", err)
 	}
 
 	// Bootstrap cluster if this is the first node
-	if config.Bootstrap {
+	if config.Bootstrap && !restored {
 		// Use advertise address for stable DNS-based addressing
 		bootstrapAddr := raft.ServerAddress(advertiseAddr)
 
@@ -177,6 +231,8 @@ func NewRaftNode(config *RaftConfig, indexStore *store.IndexStore, logger *zap.L
 		fsm:       fsm,
 		config:    config,
 		transport: transport,
+		logStore:  logStore,
+		mirror:    mirror,
 		logger:    logger,
 	}, nil
 }
@@ -186,6 +242,13 @@ func (r *RaftNode) IsLeader() bool {
 	return r.raft.State() == raft.Leader
 }
 
+// LeaderCh returns a channel that delivers true when this node gains
+// leadership and false when it loses it. Only the latest change is kept
+// for a slow receiver, and the channel has a single receiver.
+func (r *RaftNode) LeaderCh() <-chan bool {
+	return r.raft.LeaderCh()
+}
+
 // LeaderAddr returns the address of the current leader
 func (r *RaftNode) LeaderAddr() string {
 	leaderAddr, _ := r.raft.LeaderWithID()
@@ -194,35 +257,110 @@ func (r *RaftNode) LeaderAddr() string {
 
 // Apply submits a command to the Raft log for replication
 func (r *RaftNode) Apply(cmd Command, timeout time.Duration) error {
-	data, err := sonic.Marshal(cmd)
+	result, err := r.ApplyCommand(cmd, timeout)
 	if err != nil {
-		return fmt.Errorf("failed to marshal command: %w", err)
-	}
-
-	future := r.raft.Apply(data, timeout)
-	if err := future.Error(); err != nil {
 		return err
 	}
 
 	// Check if the command application returned an error
-	if result := future.Response(); result != nil {
-		if err, ok := result.(error); ok {
-			return err
-		}
+	if err, ok := result.(error); ok {
+		return err
 	}
 
 	return nil
 }
 
+// ApplyCommand submits a command like Apply, but returns the command's
+// result apart from errors replicating it. A command that failed was still
+// committed, and fails again wherever the log is replayed.
+func (r *RaftNode) ApplyCommand(cmd Command, timeout time.Duration) (any, error) {
+	data, err := sonic.Marshal(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal command: %w", err)
+	}
+
+	future := r.raft.Apply(data, timeout)
+	if err := future.Error(); err != nil {
+		return nil, err
+	}
+	return future.Response(), nil
+}
+
 // Join adds a new node to the Raft cluster
 func (r *RaftNode) Join(nodeID, addr string) error {
 	future := r.raft.AddVoter(raft.ServerID(nodeID), raft.ServerAddress(addr), 0, 0)
 	return future.Error()
 }
 
+// NodeInfo describes a member of the Raft cluster
+type NodeInfo struct {
+	ID       string `json:"id"`
+	Address  string `json:"address"`
+	Suffrage string `json:"suffrage"`
+	IsLeader bool   `json:"is_leader"`
+}
+
+// Nodes returns the current cluster membership
+func (r *RaftNode) Nodes() ([]NodeInfo, error) {
+	future := r.raft.GetConfiguration()
+	if err := future.Error(); err != nil {
+		return nil, err
+	}
+
+	_, leaderID := r.raft.LeaderWithID()
+
+	servers := future.Configuration().Servers
+	nodes := make([]NodeInfo, 0, len(servers))
+	for _, server := range servers {
+		nodes = append(nodes, NodeInfo{
+			ID:       string(server.ID),
+			Address:  string(server.Address),
+			Suffrage: server.Suffrage.String(),
+			IsLeader: server.ID == leaderID,
+		})
+	}
+
+	return nodes, nil
+}
+
+// Remove removes a node from the Raft cluster
+func (r *RaftNode) Remove(nodeID string) error {
+	future := r.raft.RemoveServer(raft.ServerID(nodeID), 0, 0)
+	return future.Error()
+}
+
+// TransferLeadership hands leadership to the given node, or to the most
+// up-to-date follower when nodeID is empty
+func (r *RaftNode) TransferLeadership(nodeID string) error {
+	if nodeID == "" {
+		return r.raft.LeadershipTransfer().Error()
+	}
+
+	nodes, err := r.Nodes()
+	if err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		if node.ID == nodeID {
+			return r.raft.LeadershipTransferToServer(raft.ServerID(node.ID), raft.ServerAddress(node.Address)).Error()
+		}
+	}
+
+	return fmt.Errorf("node %s is not a cluster member", nodeID)
+}
+
+// SetSnapshotsPaused pauses or resumes snapshots of the FSM, so a nearly
+// full disk isn't filled by a snapshot. The log keeps growing meanwhile.
+func (r *RaftNode) SetSnapshotsPaused(paused bool) {
+	r.fsm.SetSnapshotsPaused(paused)
+}
+
 // Shutdown gracefully shuts down the Raft node
 func (r *RaftNode) Shutdown() error {
-	return r.raft.Shutdown().Error()
+	err := r.raft.Shutdown().Error()
+	r.mirror.stopMirroring()
+	return err
 }
 
 // GetConfig returns the Raft configuration
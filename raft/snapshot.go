@@ -1,25 +1,97 @@
 package raft
 
 import (
+	"bright/keys"
+	"bright/models"
 	"bright/store"
 
 	"github.com/bytedance/sonic"
 	"github.com/hashicorp/raft"
 )
 
+// snapshotVersion is the current snapshot format version.
+// Version 1 snapshots were a bare map of index configurations.
+const snapshotVersion = 2
+
+// snapshotData is the serialized FSM state
+type snapshotData struct {
+	Version     int                            `json:"version"`
+	Configs     map[string]*models.IndexConfig `json:"configs"`
+	Keys        []*keys.Key                    `json:"keys"`
+	Idempotency []*store.IdempotencyRecord     `json:"idempotency,omitempty"`
+	Queries     []*models.StoredQuery          `json:"queries,omitempty"`
+	Rules       []*models.CurationRule         `json:"rules,omitempty"`
+	Alerts      []*models.AlertRule            `json:"alerts,omitempty"`
+	Schedules   []*models.ScheduledSearch      `json:"schedules,omitempty"`
+	Aliases     []*models.IndexAlias           `json:"aliases,omitempty"`
+	Reindexes   map[string]*models.IndexConfig `json:"reindexes,omitempty"`
+	Replication *store.ReplicationPosition     `json:"replication,omitempty"`
+	Routing     *models.RoutingPolicy          `json:"routing,omitempty"`
+	Settings    map[string]any                 `json:"settings,omitempty"`
+}
+
+// decodeSnapshot decodes versioned snapshots as well as legacy ones
+func decodeSnapshot(raw []byte) (*snapshotData, error) {
+	// An index may legitimately be named "version", so only a numeric
+	// version field marks the versioned format
+	var probe struct {
+		Version any `json:"version"`
+	}
+	if err := sonic.Unmarshal(raw, &probe); err != nil {
+		return nil, err
+	}
+
+	if version, ok := probe.Version.(float64); ok && version >= 2 {
+		var data snapshotData
+		if err := sonic.Unmarshal(raw, &data); err != nil {
+			return nil, err
+		}
+		return &data, nil
+	}
+
+	// Legacy format: the whole snapshot is the index configuration map
+	data := &snapshotData{Version: 1}
+	if err := sonic.Unmarshal(raw, &data.Configs); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
 // fsmSnapshot represents a point-in-time snapshot of the FSM state
 type fsmSnapshot struct {
 	store *store.IndexStore
+	keys  *keys.Store
 }
 
 // Persist saves the FSM snapshot to the provided sink
-// Only index configurations are saved (not Bleve index data)
+// Only index configurations, API keys, idempotency keys, stored queries,
+// curation rules, alert rules, scheduled searches, index aliases, pending
+// reindexes, the replication position of standbys, the routing policy and
+// the cluster settings are saved (not Bleve index data)
 func (s *fsmSnapshot) Persist(sink raft.SnapshotSink) error {
-	// Get all index configurations
-	configs := s.store.GetAllConfigs()
+	// Get all index configurations, API keys, idempotency keys, stored
+	// queries, curation rules, alert rules, scheduled searches, index
+	// aliases, pending reindexes, the replication position, the routing
+	// policy and the cluster settings
+	routing := s.store.Routing().Policy()
+	state := snapshotData{
+		Version:     snapshotVersion,
+		Configs:     s.store.GetAllConfigs(),
+		Keys:        s.keys.List(),
+		Idempotency: s.store.Idempotency().Records(),
+		Queries:     s.store.Queries().Records(),
+		Rules:       s.store.Curation().Records(),
+		Alerts:      s.store.AlertRules().List(),
+		Schedules:   s.store.Schedules().List(),
+		Aliases:     s.store.Aliases().List(),
+		Reindexes:   s.store.PendingReindexes(),
+		Replication: s.store.Replication().Position(),
+		Routing:     &routing,
+		Settings:    s.store.Settings().Overrides(),
+	}
 
-	// Serialize configurations to JSON
-	data, err := sonic.Marshal(configs)
+	// Serialize state to JSON
+	data, err := sonic.Marshal(state)
 	if err != nil {
 		sink.Cancel()
 		return err
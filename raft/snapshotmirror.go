@@ -0,0 +1,332 @@
+package raft
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/hashicorp/raft"
+	"go.uber.org/zap"
+)
+
+// Snapshot mirror defaults
+const (
+	// DefaultSnapshotMirrorPrefix is the key prefix of mirrored snapshots
+	// when none is configured
+	DefaultSnapshotMirrorPrefix = "raft-snapshots"
+
+	// DefaultSnapshotMirrorRetain is the number of mirrored snapshots kept
+	// when no retention is configured
+	DefaultSnapshotMirrorRetain = 3
+)
+
+const (
+	// uploadTimeout bounds the upload of a snapshot
+	uploadTimeout = 10 * time.Minute
+
+	// uploadRetryInterval is how long a failed upload waits to be retried
+	// when no newer snapshot was taken meanwhile
+	uploadRetryInterval = time.Minute
+
+	// Objects of a mirrored snapshot, under <prefix>/<snapshot ID>/. The
+	// metadata is uploaded last, so snapshots without it are incomplete.
+	snapshotMetaObject  = "meta.json"
+	snapshotStateObject = "state.bin"
+)
+
+// ObjectStore holds mirrored snapshots, such as an *objectstore.S3
+type ObjectStore interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	List(ctx context.Context, prefix string) ([]string, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// SnapshotMirrorConfig configures the mirroring of Raft snapshots to object
+// storage
+type SnapshotMirrorConfig struct {
+	Objects ObjectStore
+
+	// Prefix of the snapshot keys, shared by the nodes of a cluster
+	// (DefaultSnapshotMirrorPrefix if empty)
+	Prefix string
+
+	// Retain is the number of snapshots kept in object storage
+	// (DefaultSnapshotMirrorRetain if zero)
+	Retain int
+}
+
+// snapshotMirror is a snapshot store that uploads each snapshot taken to
+// object storage after it is written locally. Only the latest snapshot
+// waiting to be uploaded is kept; older ones are skipped.
+type snapshotMirror struct {
+	raft.SnapshotStore
+	config SnapshotMirrorConfig
+	logger *zap.Logger
+
+	mu     sync.Mutex
+	queued string
+	wake   chan struct{}
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newSnapshotMirror mirrors the snapshots of local
+func newSnapshotMirror(local raft.SnapshotStore, config SnapshotMirrorConfig, logger *zap.Logger) *snapshotMirror {
+	config.Prefix = strings.Trim(config.Prefix, "/")
+	if config.Prefix == "" {
+		config.Prefix = DefaultSnapshotMirrorPrefix
+	}
+	if config.Retain <= 0 {
+		config.Retain = DefaultSnapshotMirrorRetain
+	}
+	return &snapshotMirror{
+		SnapshotStore: local,
+		config:        config,
+		logger:        logger,
+		wake:          make(chan struct{}, 1),
+	}
+}
+
+// mirrorSink queues its snapshot for upload once it is complete
+type mirrorSink struct {
+	raft.SnapshotSink
+	mirror *snapshotMirror
+}
+
+// Close completes the snapshot locally, then queues its upload
+func (s *mirrorSink) Close() error {
+	if err := s.SnapshotSink.Close(); err != nil {
+		return err
+	}
+	s.mirror.queue(s.ID())
+	return nil
+}
+
+// Create begins a snapshot in the local store
+func (m *snapshotMirror) Create(version raft.SnapshotVersion, index, term uint64, configuration raft.Configuration,
+	configurationIndex uint64, trans raft.Transport) (raft.SnapshotSink, error) {
+	sink, err := m.SnapshotStore.Create(version, index, term, configuration, configurationIndex, trans)
+	if err != nil {
+		return nil, err
+	}
+	return &mirrorSink{SnapshotSink: sink, mirror: m}, nil
+}
+
+// queue makes id the next snapshot to upload
+func (m *snapshotMirror) queue(id string) {
+	m.mu.Lock()
+	m.queued = id
+	m.mu.Unlock()
+
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// start uploads queued snapshots in the background until stopMirroring is
+// called
+func (m *snapshotMirror) start() {
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	go func() {
+		defer close(m.done)
+		retry := time.NewTimer(uploadRetryInterval)
+		retry.Stop()
+		var failed string
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-m.wake:
+			case <-retry.C:
+				m.mu.Lock()
+				if m.queued == "" {
+					m.queued = failed
+				}
+				m.mu.Unlock()
+			}
+
+			m.mu.Lock()
+			id := m.queued
+			m.queued = ""
+			m.mu.Unlock()
+			if id == "" {
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), uploadTimeout)
+			go func() {
+				select {
+				case <-m.stop:
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+			err := m.upload(ctx, id)
+			cancel()
+			if err != nil {
+				m.logger.Warn("Failed to mirror Raft snapshot to object storage", zap.String("snapshot_id", id), zap.Error(err))
+				failed = id
+				retry.Reset(uploadRetryInterval)
+				continue
+			}
+			failed = ""
+			m.logger.Info("Mirrored Raft snapshot to object storage", zap.String("snapshot_id", id))
+		}
+	}()
+}
+
+// stopMirroring stops uploading, cancelling the current upload. A nil
+// mirror does nothing.
+func (m *snapshotMirror) stopMirroring() {
+	if m == nil || m.stop == nil {
+		return
+	}
+	close(m.stop)
+	<-m.done
+}
+
+// key returns the key of an object of a mirrored snapshot
+func (m *snapshotMirror) key(id, object string) string {
+	return m.config.Prefix + "/" + id + "/" + object
+}
+
+// upload copies a local snapshot to object storage, then drops the oldest
+// mirrored snapshots past the retention
+func (m *snapshotMirror) upload(ctx context.Context, id string) error {
+	meta, state, err := m.SnapshotStore.Open(id)
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer state.Close()
+
+	if err := m.config.Objects.Put(ctx, m.key(id, snapshotStateObject), state, meta.Size); err != nil {
+		return fmt.Errorf("failed to upload snapshot state: %w", err)
+	}
+	data, err := sonic.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot metadata: %w", err)
+	}
+	if err := m.config.Objects.Put(ctx, m.key(id, snapshotMetaObject), bytes.NewReader(data), int64(len(data))); err != nil {
+		return fmt.Errorf("failed to upload snapshot metadata: %w", err)
+	}
+
+	return m.prune(ctx)
+}
+
+// prune deletes the mirrored snapshots past the retention, metadata first
+// so that a snapshot is never left half deleted but complete
+func (m *snapshotMirror) prune(ctx context.Context) error {
+	ids, err := m.list(ctx)
+	if err != nil {
+		return err
+	}
+	if len(ids) <= m.config.Retain {
+		return nil
+	}
+	for _, id := range ids[m.config.Retain:] {
+		for _, object := range []string{snapshotMetaObject, snapshotStateObject} {
+			if err := m.config.Objects.Delete(ctx, m.key(id, object)); err != nil {
+				return fmt.Errorf("failed to delete mirrored snapshot %s: %w", id, err)
+			}
+		}
+	}
+	return nil
+}
+
+// list returns the IDs of the complete mirrored snapshots, newest first
+func (m *snapshotMirror) list(ctx context.Context) ([]string, error) {
+	keys, err := m.config.Objects.List(ctx, m.config.Prefix+"/")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mirrored snapshots: %w", err)
+	}
+
+	var ids []string
+	for _, key := range keys {
+		id, ok := strings.CutSuffix(strings.TrimPrefix(key, m.config.Prefix+"/"), "/"+snapshotMetaObject)
+		if ok && !strings.Contains(id, "/") {
+			ids = append(ids, id)
+		}
+	}
+	// IDs are <term>-<index>-<milliseconds>, as the file store names them
+	sort.Slice(ids, func(i, j int) bool {
+		var a, b [3]uint64
+		fmt.Sscanf(ids[i], "%d-%d-%d", &a[0], &a[1], &a[2])
+		fmt.Sscanf(ids[j], "%d-%d-%d", &b[0], &b[1], &b[2])
+		if a != b {
+			return a[0] > b[0] || a[0] == b[0] && (a[1] > b[1] || a[1] == b[1] && a[2] > b[2])
+		}
+		return ids[i] > ids[j]
+	})
+	return ids, nil
+}
+
+// restoreLatest copies the newest complete mirrored snapshot into the
+// local store, falling back to older ones when one can't be read. It
+// returns nil when object storage holds no snapshot.
+func (m *snapshotMirror) restoreLatest(ctx context.Context, trans raft.Transport) (*raft.SnapshotMeta, error) {
+	ids, err := m.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, id := range ids {
+		meta, err := m.download(ctx, id, trans)
+		if err == nil {
+			return meta, nil
+		}
+		m.logger.Warn("Failed to restore mirrored Raft snapshot", zap.String("snapshot_id", id), zap.Error(err))
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// download copies a mirrored snapshot into the local store
+func (m *snapshotMirror) download(ctx context.Context, id string, trans raft.Transport) (*raft.SnapshotMeta, error) {
+	body, err := m.config.Objects.Get(ctx, m.key(id, snapshotMetaObject))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download snapshot metadata: %w", err)
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to download snapshot metadata: %w", err)
+	}
+	var meta raft.SnapshotMeta
+	if err := sonic.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot metadata: %w", err)
+	}
+
+	state, err := m.config.Objects.Get(ctx, m.key(id, snapshotStateObject))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download snapshot state: %w", err)
+	}
+	defer state.Close()
+
+	sink, err := m.SnapshotStore.Create(meta.Version, meta.Index, meta.Term, meta.Configuration, meta.ConfigurationIndex, trans)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create local snapshot: %w", err)
+	}
+	size, err := io.Copy(sink, state)
+	if err == nil && size != meta.Size {
+		err = fmt.Errorf("snapshot state has %d bytes, expected %d", size, meta.Size)
+	}
+	if err != nil {
+		sink.Cancel()
+		return nil, fmt.Errorf("failed to download snapshot state: %w", err)
+	}
+	if err := sink.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write local snapshot: %w", err)
+	}
+	return &meta, nil
+}
@@ -0,0 +1,145 @@
+package raft
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/raft"
+	"go.uber.org/zap"
+)
+
+// memObjects is an object store in memory
+type memObjects struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (m *memObjects) Put(_ context.Context, key string, body io.Reader, size int64) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != size {
+		return errors.New("short body")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = data
+	return nil
+}
+
+func (m *memObjects) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *memObjects) List(_ context.Context, prefix string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []string
+	for key := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (m *memObjects) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
+
+// takeSnapshot writes a snapshot of state at index into store, returning
+// its ID
+func takeSnapshot(t *testing.T, store raft.SnapshotStore, index uint64, state string) string {
+	t.Helper()
+	_, trans := raft.NewInmemTransport("")
+	configuration := raft.Configuration{Servers: []raft.Server{{ID: "node-0", Address: "node-0:7000"}}}
+	sink, err := store.Create(raft.SnapshotVersionMax, index, 2, configuration, 1, trans)
+	if err != nil {
+		t.Fatalf("Failed to create snapshot: %v", err)
+	}
+	if _, err := sink.Write([]byte(state)); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Failed to close snapshot: %v", err)
+	}
+	return sink.ID()
+}
+
+func TestSnapshotMirror(t *testing.T) {
+	objects := &memObjects{objects: make(map[string][]byte)}
+	local, err := raft.NewFileSnapshotStore(t.TempDir(), 3, io.Discard)
+	if err != nil {
+		t.Fatalf("Failed to create snapshot store: %v", err)
+	}
+	mirror := newSnapshotMirror(local, SnapshotMirrorConfig{Objects: objects, Prefix: "/cluster/", Retain: 2}, zap.NewNop())
+
+	// Each snapshot is uploaded, and only the newest two are kept
+	var ids []string
+	for i, state := range []string{"first", "second", "third"} {
+		id := takeSnapshot(t, mirror, uint64(10*(i+1)), state)
+		if err := mirror.upload(context.Background(), id); err != nil {
+			t.Fatalf("Failed to upload snapshot: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if mirrored, _ := mirror.list(context.Background()); len(mirrored) != 2 || mirrored[0] != ids[2] || mirrored[1] != ids[1] {
+		t.Fatalf("Expected the two newest snapshots to be mirrored, got %v", mirrored)
+	}
+	if _, ok := objects.objects["cluster/"+ids[0]+"/state.bin"]; ok {
+		t.Errorf("Expected the oldest snapshot to be deleted")
+	}
+
+	// An incomplete upload is skipped
+	delete(objects.objects, "cluster/"+ids[2]+"/state.bin")
+
+	// A node with a wiped disk restores the newest complete snapshot
+	wiped, err := raft.NewFileSnapshotStore(t.TempDir(), 3, io.Discard)
+	if err != nil {
+		t.Fatalf("Failed to create snapshot store: %v", err)
+	}
+	_, trans := raft.NewInmemTransport("")
+	meta, err := newSnapshotMirror(wiped, SnapshotMirrorConfig{Objects: objects, Prefix: "cluster"}, zap.NewNop()).restoreLatest(context.Background(), trans)
+	if err != nil {
+		t.Fatalf("Failed to restore snapshot: %v", err)
+	}
+	if meta == nil || meta.Index != 20 {
+		t.Fatalf("Expected the snapshot at index 20, got %+v", meta)
+	}
+	snapshots, err := wiped.List()
+	if err != nil || len(snapshots) != 1 {
+		t.Fatalf("Expected one local snapshot, got %v (%v)", snapshots, err)
+	}
+	restored, state, err := wiped.Open(snapshots[0].ID)
+	if err != nil {
+		t.Fatalf("Failed to open restored snapshot: %v", err)
+	}
+	defer state.Close()
+	data, _ := io.ReadAll(state)
+	if string(data) != "second" || restored.Term != 2 || len(restored.Configuration.Servers) != 1 {
+		t.Errorf("Expected the second snapshot with its configuration, got %q and %+v", data, restored)
+	}
+
+	// Without mirrored snapshots there's nothing to restore
+	empty := newSnapshotMirror(wiped, SnapshotMirrorConfig{Objects: &memObjects{objects: make(map[string][]byte)}}, zap.NewNop())
+	if meta, err := empty.restoreLatest(context.Background(), trans); meta != nil || err != nil {
+		t.Errorf("Expected nothing to restore, got %+v (%v)", meta, err)
+	}
+}
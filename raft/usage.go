@@ -0,0 +1,80 @@
+package raft
+
+import (
+	"fmt"
+
+	"github.com/bytedance/sonic"
+	"github.com/hashicorp/raft"
+)
+
+// LogUsage is the share of the Raft log taken by the commands of an index
+type LogUsage struct {
+	Bytes   int64 `json:"bytes"`
+	Entries int   `json:"entries"`
+}
+
+// IndexLogUsage sums the entries of the Raft log that carry commands for an
+// index. Entries are kept until the next snapshot compacts the log, so this
+// is the Raft disk usage the index is responsible for.
+func (r *RaftNode) IndexLogUsage(indexID string) (LogUsage, error) {
+	var usage LogUsage
+
+	first, err := r.logStore.FirstIndex()
+	if err != nil {
+		return usage, fmt.Errorf("failed to read raft log: %w", err)
+	}
+	last, err := r.logStore.LastIndex()
+	if err != nil {
+		return usage, fmt.Errorf("failed to read raft log: %w", err)
+	}
+
+	for i := first; i <= last && first > 0; i++ {
+		var entry raft.Log
+		if err := r.logStore.GetLog(i, &entry); err != nil {
+			// Compaction may remove entries while the log is read
+			continue
+		}
+		if entry.Type != raft.LogCommand {
+			continue
+		}
+		if commandIndex(entry.Data) == indexID {
+			usage.Bytes += int64(len(entry.Data))
+			usage.Entries++
+		}
+	}
+	return usage, nil
+}
+
+// commandIndex returns the index a command applies to, or "" for commands
+// that are not about an index
+func commandIndex(data []byte) string {
+	typeNode, err := sonic.Get(data, "type")
+	if err != nil {
+		return ""
+	}
+	commandType, err := typeNode.String()
+	if err != nil {
+		return ""
+	}
+
+	var key string
+	switch CommandType(commandType) {
+	case CommandCreateIndex, CommandUpdateIndex, CommandDeleteIndex:
+		key = "id"
+	case CommandPutQuery, CommandPutRule:
+		key = "indexId"
+	case CommandAddDocuments, CommandDeleteDocument, CommandDeleteDocuments,
+		CommandUpdateDocument, CommandUpdateDocuments,
+		CommandAutoCreateAndAddDocuments, CommandDeleteQuery, CommandDeleteRule:
+		key = "index_id"
+	default:
+		return ""
+	}
+
+	node, err := sonic.Get(data, "data", key)
+	if err != nil {
+		return ""
+	}
+	id, _ := node.String()
+	return id
+}
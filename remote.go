@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bright/rpc"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// RemoteFlags holds connection settings for commands that call a running server
+type RemoteFlags struct {
+	Target    string `help:"Base URL of the Bright server" default:"http://localhost:3000" env:"BRIGHT_URL"`
+	MasterKey string `help:"Master key for authentication" env:"BRIGHT_MASTER_KEY"`
+}
+
+// call performs an API request and returns the raw response body.
+// Non-2xx responses are returned as errors carrying the server message.
+// Followers refuse leader-only operations with a 403 naming the leader, in
+// which case the request is sent to the leader once.
+func (r *RemoteFlags) call(method, path string, body any) ([]byte, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = sonic.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+	}
+
+	target := strings.TrimRight(r.Target, "/")
+	status, respBody, err := r.send(method, target+path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if status == http.StatusForbidden {
+		var refusal struct {
+			Leader string `json:"leader"`
+		}
+		if sonic.Unmarshal(respBody, &refusal) == nil && refusal.Leader != "" {
+			leader := leaderURL(target, refusal.Leader)
+			fmt.Fprintf(os.Stderr, "Retrying on the leader at %s\n", leader)
+			if status, respBody, err = r.send(method, leader+path, data); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if status >= 300 {
+		return nil, fmt.Errorf("%s %s failed with status %d: %s", method, path, status, strings.TrimSpace(string(respBody)))
+	}
+
+	return respBody, nil
+}
+
+// send performs a single request and returns the status and body of the
+// response
+func (r *RemoteFlags) send(method, url string, data []byte) (int, []byte, error) {
+	var reader io.Reader
+	if data != nil {
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.MasterKey != "" {
+		req.Header.Set("Authorization", "Bearer "+r.MasterKey)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return resp.StatusCode, respBody, nil
+}
+
+// leaderURL returns the base URL of the leader named by a follower, from its
+// Raft address and the scheme of the target
+func leaderURL(target, leaderRaftAddr string) string {
+	scheme := "http"
+	if u, err := url.Parse(target); err == nil && u.Scheme != "" {
+		scheme = u.Scheme
+	}
+	return scheme + "://" + rpc.HTTPAddr(leaderRaftAddr)
+}
+
+// printJSON pretty-prints a JSON response body to stdout
+func printJSON(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		os.Stdout.Write(data)
+		fmt.Println()
+		return
+	}
+	out.WriteByte('\n')
+	out.WriteTo(os.Stdout)
+}
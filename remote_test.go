@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestCallFollowsLeader tests that a leader-only request refused by a
+// follower is sent to the leader it names, once
+func TestCallFollowsLeader(t *testing.T) {
+	var leaderCalls int
+	leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaderCalls++
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Expected the master key to be sent to the leader")
+		}
+		if r.URL.Path == "/cluster/remove" {
+			w.Write([]byte(`{"removed": true}`))
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"code": "LEADER_ONLY_OPERATION", "leader": "` + r.Host + `"}`))
+	}))
+	defer leader.Close()
+
+	follower := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"code": "LEADER_ONLY_OPERATION", "leader": "` + strings.TrimPrefix(leader.URL, "http://") + `"}`))
+	}))
+	defer follower.Close()
+
+	remote := &RemoteFlags{Target: follower.URL, MasterKey: "secret"}
+	body, err := remote.call(http.MethodPost, "/cluster/remove", map[string]string{"node_id": "node2"})
+	if err != nil {
+		t.Fatalf("Expected the leader to answer, got %v", err)
+	}
+	if string(body) != `{"removed": true}` || leaderCalls != 1 {
+		t.Errorf("Expected one call answered by the leader, got %d calls and %s", leaderCalls, body)
+	}
+
+	// A leader that refuses too isn't followed further
+	if _, err := remote.call(http.MethodPost, "/cluster/join", nil); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Expected the second refusal to be returned, got %v", err)
+	}
+	if leaderCalls != 2 {
+		t.Errorf("Expected the leader to be called once more, got %d calls", leaderCalls)
+	}
+}
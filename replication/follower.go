@@ -0,0 +1,482 @@
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"bright/jobs"
+	"bright/keys"
+	"bright/models"
+	"bright/raft"
+	"bright/store"
+
+	"github.com/bytedance/sonic"
+	"go.uber.org/zap"
+)
+
+// DefaultInterval is how often a caught-up standby checks its source for
+// changes when no interval is set
+const DefaultInterval = time.Second
+
+const (
+	// requestTimeout bounds requests to the source, except archive downloads
+	requestTimeout = 30 * time.Second
+
+	// applyTimeout bounds the replication of a command within the standby
+	applyTimeout = 10 * time.Second
+
+	// copyBatch is the number of documents per command when an index is
+	// copied from its archive
+	copyBatch = 1000
+
+	// listPageSize is the number of indexes listed per request
+	listPageSize = 100
+)
+
+// States of a standby
+const (
+	// StateFollowing applies the change feed of the source
+	StateFollowing = "following"
+
+	// StateCopying copies the indexes of the source, because the standby
+	// has no position in its change feed or fell behind its log
+	StateCopying = "copying"
+
+	// StateIdle is a node of a standby cluster that doesn't lead it, and
+	// leaves replication to the leader
+	StateIdle = "idle"
+)
+
+// Options configure a Follower
+type Options struct {
+	// Source is the base URL of a node of the source cluster
+	Source string
+
+	// Key authenticates with the source: its master key or an admin API key
+	Key string
+
+	// Interval between checks for changes once caught up (DefaultInterval
+	// if zero)
+	Interval time.Duration
+
+	// BatchSize is the number of changes fetched at once
+	// (raft.DefaultChangesLimit if zero)
+	BatchSize int
+}
+
+// Status is the replication state of a standby
+type Status struct {
+	Source      string     `json:"source"`
+	State       string     `json:"state"`
+	Index       uint64     `json:"index"`
+	SourceIndex uint64     `json:"sourceIndex"`
+	Lag         uint64     `json:"lag"`
+	LastSyncAt  *time.Time `json:"lastSyncAt,omitempty"`
+	LastCopyAt  *time.Time `json:"lastCopyAt,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+}
+
+// applier applies commands to the standby: its Raft node in a cluster, its
+// stores directly on a single node
+type applier interface {
+	ApplyCommand(cmd raft.Command, timeout time.Duration) (any, error)
+}
+
+// Follower keeps a standby up to date with a source cluster by applying its
+// change feed. A standby without a position in the feed, or one that fell
+// behind the source's log, first copies the source's indexes from their
+// archives. In Raft mode only the leader of the standby follows the source,
+// and the position is replicated with the changes, so another node carries
+// on after a failover. Changes are applied at least once.
+type Follower struct {
+	store     *store.IndexStore
+	raftNode  *raft.RaftNode
+	applier   applier
+	source    string
+	key       string
+	interval  time.Duration
+	batchSize int
+	client    *http.Client
+	logger    *zap.Logger
+
+	status Status
+	mu     sync.Mutex
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewFollower creates a follower. raftNode may be nil, the changes are then
+// applied to the stores directly.
+func NewFollower(indexStore *store.IndexStore, raftNode *raft.RaftNode, keyStore *keys.Store, options Options, logger *zap.Logger) *Follower {
+	interval := options.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	batchSize := options.BatchSize
+	if batchSize <= 0 {
+		batchSize = raft.DefaultChangesLimit
+	}
+
+	f := &Follower{
+		store:     indexStore,
+		raftNode:  raftNode,
+		source:    strings.TrimRight(options.Source, "/"),
+		key:       options.Key,
+		interval:  interval,
+		batchSize: min(batchSize, raft.MaxChangesLimit),
+		client:    &http.Client{},
+		logger:    logger,
+	}
+	f.status = Status{Source: f.source, State: StateFollowing}
+	if raftNode != nil {
+		f.applier = raftNode
+	} else {
+		f.applier = raft.NewFSM(indexStore, keyStore)
+	}
+	return f
+}
+
+// Start follows the source, checking for changes every interval once caught
+// up, until Stop is called
+func (f *Follower) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	f.cancel = cancel
+	f.done = make(chan struct{})
+
+	go func() {
+		defer close(f.done)
+		ticker := time.NewTicker(f.interval)
+		defer ticker.Stop()
+		for {
+			f.Tick(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop halts the follower and waits for the changes being applied
+func (f *Follower) Stop() {
+	if f.cancel == nil {
+		return
+	}
+	f.cancel()
+	<-f.done
+}
+
+// Status returns the replication state of this node
+func (f *Follower) Status() Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	status := f.status
+	if position := f.store.Replication().Position(); position != nil && position.Source == f.source {
+		status.Index = position.Index
+	}
+	if status.SourceIndex > status.Index {
+		status.Lag = status.SourceIndex - status.Index
+	}
+	return status
+}
+
+// Tick applies the changes of the source until the standby is caught up,
+// copying the source first when needed
+func (f *Follower) Tick(ctx context.Context) {
+	if f.raftNode != nil && !f.raftNode.IsLeader() {
+		f.setState(StateIdle)
+		return
+	}
+	f.setState(StateFollowing)
+
+	err := f.follow(ctx)
+	if err != nil && ctx.Err() == nil {
+		f.logger.Warn("Replication failed",
+			zap.String("source", f.source),
+			zap.Error(err),
+		)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status.LastError = ""
+	if err != nil {
+		f.status.LastError = err.Error()
+	}
+}
+
+// follow applies pages of changes after the standby's position
+func (f *Follower) follow(ctx context.Context) error {
+	position := f.store.Replication().Position()
+	if position == nil || position.Source != f.source {
+		return f.copy(ctx)
+	}
+
+	after := position.Index
+	for ctx.Err() == nil {
+		feed, err := f.changes(ctx, after)
+		if errors.Is(err, raft.ErrChangesCompacted) {
+			f.logger.Warn("Standby fell behind the log of its source, copying it again",
+				zap.String("source", f.source),
+				zap.Uint64("index", after),
+			)
+			return f.copy(ctx)
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, change := range feed.Changes {
+			result, err := f.applier.ApplyCommand(change.Command, applyTimeout)
+			if err != nil {
+				return fmt.Errorf("failed to apply change %d: %w", change.Index, err)
+			}
+			// The command failed on the source as well, where it was
+			// committed all the same
+			if err, ok := result.(error); ok {
+				f.logger.Debug("Replicated change failed",
+					zap.Uint64("index", change.Index),
+					zap.String("type", string(change.Type)),
+					zap.Error(err),
+				)
+			}
+		}
+
+		if feed.LastIndex > after {
+			if err := f.setPosition(feed.LastIndex); err != nil {
+				return err
+			}
+			after = feed.LastIndex
+		}
+		f.synced(feed.AppliedIndex, false)
+		if after >= feed.AppliedIndex {
+			return nil
+		}
+	}
+	return ctx.Err()
+}
+
+// copy replaces the indexes of the standby with archives of the source's,
+// then follows the change feed from where the source was before they were
+// taken. Changes already in the archives are applied again.
+func (f *Follower) copy(ctx context.Context) error {
+	f.setState(StateCopying)
+	defer f.setState(StateFollowing)
+
+	var status struct {
+		AppliedIndex *uint64 `json:"applied_index"`
+	}
+	if err := f.getJSON(ctx, "/cluster/status", &status); err != nil {
+		return err
+	}
+	if status.AppliedIndex == nil {
+		return fmt.Errorf("%s doesn't run in cluster mode and has no change feed", f.source)
+	}
+
+	configs, err := f.listIndexes(ctx)
+	if err != nil {
+		return err
+	}
+	f.logger.Info("Copying the indexes of the source",
+		zap.String("source", f.source),
+		zap.Int("indexes", len(configs)),
+		zap.Uint64("index", *status.AppliedIndex),
+	)
+
+	copied := make(map[string]bool, len(configs))
+	for _, config := range configs {
+		copied[config.ID] = true
+	}
+	for id := range f.store.GetAllConfigs() {
+		if !copied[id] {
+			if err := f.apply(raft.CommandDeleteIndex, raft.DeleteIndexPayload{ID: id}); err != nil {
+				return err
+			}
+		}
+	}
+	for _, config := range configs {
+		if err := f.copyIndex(ctx, config.ID); err != nil {
+			return fmt.Errorf("failed to copy index %s: %w", config.ID, err)
+		}
+	}
+
+	if err := f.setPosition(*status.AppliedIndex); err != nil {
+		return err
+	}
+	f.synced(*status.AppliedIndex, true)
+	return nil
+}
+
+// copyIndex replaces an index of the standby with an archive of the
+// source's, replicating its creation and then its documents in batches
+func (f *Follower) copyIndex(ctx context.Context, id string) error {
+	resp, err := f.get(ctx, "/indexes/"+url.PathEscape(id)+"/snapshot")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	archive, err := f.store.ReadArchive(resp.Body)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	if _, _, err := f.store.GetIndex(id); err == nil {
+		if err := f.apply(raft.CommandDeleteIndex, raft.DeleteIndexPayload{ID: id}); err != nil {
+			return err
+		}
+	}
+	config := archive.Config
+	config.ID = id
+	if err := f.apply(raft.CommandCreateIndex, config); err != nil {
+		return err
+	}
+
+	job := f.store.Jobs().Start(jobs.KindImport, id)
+	defer job.Done()
+
+	return archive.ScanDocuments(copyBatch, func(documents []map[string]any) error {
+		if err := f.apply(raft.CommandAddDocuments, raft.AddDocumentsPayload{IndexID: id, Documents: documents}); err != nil {
+			return err
+		}
+		return job.Wait(ctx, len(documents))
+	})
+}
+
+// listIndexes returns the configurations of the source's indexes
+func (f *Follower) listIndexes(ctx context.Context) ([]*models.IndexConfig, error) {
+	var configs []*models.IndexConfig
+	for offset := 0; ; offset += listPageSize {
+		var page struct {
+			Items []*models.IndexConfig `json:"items"`
+		}
+		if err := f.getJSON(ctx, fmt.Sprintf("/indexes?limit=%d&offset=%d", listPageSize, offset), &page); err != nil {
+			return nil, err
+		}
+		configs = append(configs, page.Items...)
+		if len(page.Items) < listPageSize {
+			return configs, nil
+		}
+	}
+}
+
+// changes fetches the page of the change feed after a log index
+func (f *Follower) changes(ctx context.Context, after uint64) (*raft.ChangeFeed, error) {
+	var feed raft.ChangeFeed
+	err := f.getJSON(ctx, fmt.Sprintf("/cluster/changes?after=%d&limit=%d", after, f.batchSize), &feed)
+	var status *statusError
+	if errors.As(err, &status) && status.code == http.StatusGone {
+		return nil, raft.ErrChangesCompacted
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &feed, nil
+}
+
+// apply replicates a command within the standby and returns its error
+func (f *Follower) apply(cmdType raft.CommandType, payload any) error {
+	data, err := sonic.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", cmdType, err)
+	}
+	result, err := f.applier.ApplyCommand(raft.Command{Type: cmdType, Data: json.RawMessage(data)}, applyTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to apply %s: %w", cmdType, err)
+	}
+	if err, ok := result.(error); ok {
+		return fmt.Errorf("failed to apply %s: %w", cmdType, err)
+	}
+	return nil
+}
+
+// setPosition replicates the standby's position in the change feed
+func (f *Follower) setPosition(index uint64) error {
+	return f.apply(raft.CommandSetReplicationPosition, raft.ReplicationPositionPayload{Source: f.source, Index: index})
+}
+
+// setState updates the state reported by Status
+func (f *Follower) setState(state string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status.State = state
+}
+
+// synced records a page of changes or a copy, and the source's applied index
+func (f *Follower) synced(sourceIndex uint64, copied bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now().UTC()
+	f.status.SourceIndex = sourceIndex
+	f.status.LastSyncAt = &now
+	if copied {
+		f.status.LastCopyAt = &now
+	}
+}
+
+// statusError is a response of the source with an error status
+type statusError struct {
+	code    int
+	message string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("source responded with status %d: %s", e.code, e.message)
+}
+
+// get requests a path of the source. Responses with an error status are
+// returned as a *statusError.
+func (f *Follower) get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.source+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if f.key != "" {
+		req.Header.Set("Authorization", "Bearer "+f.key)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to source failed: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &statusError{code: resp.StatusCode, message: strings.TrimSpace(string(body))}
+	}
+	return resp, nil
+}
+
+// getJSON requests a path of the source and decodes its JSON response
+func (f *Follower) getJSON(ctx context.Context, path string, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	resp, err := f.get(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if err := sonic.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse response of %s: %w", path, err)
+	}
+	return nil
+}
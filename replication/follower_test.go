@@ -0,0 +1,182 @@
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"bright/keys"
+	"bright/models"
+	"bright/raft"
+	"bright/store"
+
+	"go.uber.org/zap"
+)
+
+// fakeSource serves the endpoints a follower reads from a store, with a
+// change feed compacted up to first
+type fakeSource struct {
+	store *store.IndexStore
+
+	mu      sync.Mutex
+	first   uint64
+	applied uint64
+	changes []raft.Change
+}
+
+func (s *fakeSource) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/cluster/status":
+		json.NewEncoder(w).Encode(map[string]any{"mode": "clustered", "applied_index": s.applied})
+	case "/indexes":
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		json.NewEncoder(w).Encode(map[string]any{"items": s.store.ListIndexes(limit, offset)})
+	case "/cluster/changes":
+		after, _ := strconv.ParseUint(r.URL.Query().Get("after"), 10, 64)
+		if after+1 < s.first {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		feed := raft.ChangeFeed{Changes: []raft.Change{}, LastIndex: max(after, s.applied), AppliedIndex: s.applied}
+		for _, change := range s.changes {
+			if change.Index > after {
+				feed.Changes = append(feed.Changes, change)
+			}
+		}
+		json.NewEncoder(w).Encode(feed)
+	default:
+		id, ok := indexOfSnapshot(r.URL.Path)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		archive, err := s.store.ArchiveIndex(id)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		defer archive.Close()
+		archive.Write(w)
+	}
+}
+
+// indexOfSnapshot returns the index of a /indexes/:id/snapshot path
+func indexOfSnapshot(path string) (string, bool) {
+	id, ok := strings.CutPrefix(path, "/indexes/")
+	if !ok {
+		return "", false
+	}
+	return strings.CutSuffix(id, "/snapshot")
+}
+
+// change builds a change of the feed
+func change(t *testing.T, index uint64, cmdType raft.CommandType, payload any) raft.Change {
+	t.Helper()
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("Failed to marshal payload: %v", err)
+	}
+	return raft.Change{Index: index, Command: raft.Command{Type: cmdType, Data: data}}
+}
+
+func TestFollower(t *testing.T) {
+	sourceStore, err := store.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open source store: %v", err)
+	}
+	defer sourceStore.Close()
+	if err := sourceStore.CreateIndex(&models.IndexConfig{ID: "books", PrimaryKey: "id"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	if err := sourceStore.AddDocuments("books", "", []map[string]any{{"id": "1", "title": "Dune"}, {"id": "2", "title": "Emma"}}); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	// The standby has an index the source no longer has
+	dir := t.TempDir()
+	standby, err := store.New(dir)
+	if err != nil {
+		t.Fatalf("Failed to open standby store: %v", err)
+	}
+	defer standby.Close()
+	if err := standby.CreateIndex(&models.IndexConfig{ID: "stale", PrimaryKey: "id"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	keyStore, err := keys.NewStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to open key store: %v", err)
+	}
+
+	source := &fakeSource{store: sourceStore, first: 4, applied: 5}
+	server := httptest.NewServer(source)
+	defer server.Close()
+
+	follower := NewFollower(standby, nil, keyStore, Options{Source: server.URL, Key: "secret"}, zap.NewNop())
+
+	// Without a position the standby copies the source
+	follower.Tick(context.Background())
+	if status := follower.Status(); status.LastError != "" || status.Index != 5 || status.LastCopyAt == nil {
+		t.Fatalf("Expected a copy up to index 5, got %+v", status)
+	}
+	if _, _, err := standby.GetIndex("stale"); err == nil {
+		t.Errorf("Expected the index missing from the source to be deleted")
+	}
+	index, _, err := standby.GetIndex("books")
+	if err != nil {
+		t.Fatalf("Expected the index to be copied: %v", err)
+	}
+	if count, _ := index.DocCount(); count != 2 {
+		t.Errorf("Expected 2 copied documents, got %d", count)
+	}
+
+	// Then it applies the changes after its position
+	source.mu.Lock()
+	source.applied = 8
+	source.changes = []raft.Change{
+		change(t, 6, raft.CommandAddDocuments, raft.AddDocumentsPayload{IndexID: "books", Documents: []map[string]any{{"id": "3", "title": "Ulysses"}}}),
+		change(t, 7, raft.CommandCreateIndex, models.IndexConfig{ID: "news", PrimaryKey: "id"}),
+		// Failed on the source as well
+		change(t, 8, raft.CommandDeleteDocument, raft.DeleteDocumentPayload{IndexID: "missing", DocumentID: "1"}),
+	}
+	source.mu.Unlock()
+
+	follower.Tick(context.Background())
+	if status := follower.Status(); status.LastError != "" || status.Index != 8 || status.Lag != 0 || status.State != StateFollowing {
+		t.Fatalf("Expected to follow up to index 8, got %+v", status)
+	}
+	if count, _ := index.DocCount(); count != 3 {
+		t.Errorf("Expected 3 documents, got %d", count)
+	}
+	if _, _, err := standby.GetIndex("news"); err != nil {
+		t.Errorf("Expected the created index to be replicated: %v", err)
+	}
+	if position := standby.Replication().Position(); position == nil || position.Source != server.URL || position.Index != 8 {
+		t.Errorf("Expected the position to be recorded, got %+v", position)
+	}
+
+	// A standby that fell behind the log copies the source again
+	if err := standby.Replication().SetPosition(server.URL, 2, standby.Replication().Position().UpdatedAt); err != nil {
+		t.Fatalf("Failed to set position: %v", err)
+	}
+	follower.Tick(context.Background())
+	if status := follower.Status(); status.LastError != "" || status.Index != 8 {
+		t.Fatalf("Expected a copy up to index 8, got %+v", status)
+	}
+	if _, _, err := standby.GetIndex("news"); err == nil {
+		t.Errorf("Expected the index missing from the source to be deleted")
+	}
+}
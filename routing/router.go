@@ -0,0 +1,392 @@
+// Package routing spreads the searches of a cluster across its nodes by
+// load and replication lag, following the cluster's routing policy.
+package routing
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"bright/models"
+	"bright/raft"
+	"bright/rpc"
+	"bright/store"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// DefaultInterval is how often the load of peers is polled when no interval
+// is set
+const DefaultInterval = time.Second
+
+const (
+	// statusTimeout bounds a poll of a peer's status
+	statusTimeout = 2 * time.Second
+
+	// stalePolls is the number of polls a peer may miss before it is no
+	// longer routed searches
+	stalePolls = 3
+)
+
+// Cluster is the membership and progress of the Raft cluster, as given by
+// a *raft.RaftNode
+type Cluster interface {
+	Nodes() ([]raft.NodeInfo, error)
+	AppliedIndex() uint64
+}
+
+// Options configure a Router
+type Options struct {
+	// NodeID is the ID of this node in the cluster
+	NodeID string
+
+	// MasterKey authenticates status polls of peers
+	MasterKey string
+
+	// Interval between polls of the peers (DefaultInterval if zero)
+	Interval time.Duration
+
+	// Tags describe this node, such as its zone
+	Tags map[string]string
+}
+
+// NodeLoad is the load and lag of a node as last seen
+type NodeLoad struct {
+	ID           string `json:"id"`
+	Address      string `json:"address"`
+	HTTPAddress  string `json:"http_address"`
+	IsLeader     bool   `json:"is_leader"`
+	AppliedIndex uint64 `json:"applied_index"`
+
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// Preferred nodes share this node's values of the policy's
+	// prefer_tags
+	Preferred bool `json:"preferred"`
+
+	// Lag is how many log entries the node is behind the most up-to-date
+	// node seen
+	Lag uint64 `json:"lag"`
+
+	// InFlight counts the searches and document requests the node is
+	// serving, including those this node forwarded to it since
+	InFlight int64 `json:"in_flight"`
+
+	// Eligible nodes answered the last polls and lag at most the policy's
+	// max_lag
+	Eligible  bool       `json:"eligible"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// Table is the routing policy of the cluster with the nodes it routes
+// searches between, as seen by one node
+type Table struct {
+	Policy models.RoutingPolicy `json:"policy"`
+	NodeID string               `json:"node_id"`
+
+	// Route is the ID of the node the next search goes to
+	Route string     `json:"route"`
+	Nodes []NodeLoad `json:"nodes"`
+}
+
+// peer is the last seen state of another node
+type peer struct {
+	status    rpc.NodeStatus
+	checkedAt time.Time
+	err       string
+
+	// routed counts the searches forwarded to it that are in flight
+	routed atomic.Int64
+}
+
+// Router picks the node serving each search. It polls the status of its
+// peers while the policy routes searches, and tracks the searches it
+// forwards.
+type Router struct {
+	store     *store.IndexStore
+	cluster   Cluster
+	rpcClient rpc.RPCClient
+	options   Options
+	logger    *zap.Logger
+
+	// forwarding counts the searches this node waits on other nodes for,
+	// which aren't part of its own load
+	forwarding atomic.Int64
+
+	mu      sync.RWMutex
+	members []raft.NodeInfo
+	peers   map[string]*peer
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewRouter creates a router for the node of options.NodeID
+func NewRouter(indexStore *store.IndexStore, cluster Cluster, rpcClient rpc.RPCClient, options Options, logger *zap.Logger) *Router {
+	if options.Interval <= 0 {
+		options.Interval = DefaultInterval
+	}
+	return &Router{
+		store:     indexStore,
+		cluster:   cluster,
+		rpcClient: rpcClient,
+		options:   options,
+		logger:    logger,
+		peers:     make(map[string]*peer),
+	}
+}
+
+// Start polls the peers in the background until Stop is called
+func (r *Router) Start() {
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.options.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				if r.Policy().Mode != models.RoutingLocal {
+					r.Tick(context.Background())
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops polling and waits for the current poll to finish
+func (r *Router) Stop() {
+	if r.stop == nil {
+		return
+	}
+	close(r.stop)
+	<-r.done
+}
+
+// NodeID returns the ID of this node
+func (r *Router) NodeID() string {
+	return r.options.NodeID
+}
+
+// Policy returns the routing policy of the cluster
+func (r *Router) Policy() models.RoutingPolicy {
+	return r.store.Routing().Policy()
+}
+
+// InFlight returns the load of this node: its searches and document
+// requests in flight, less those it forwarded to other nodes
+func (r *Router) InFlight() int64 {
+	return max(r.store.Jobs().Foregrounds()-r.forwarding.Load(), 0)
+}
+
+// Tick reads the cluster's membership and polls the status of every peer
+func (r *Router) Tick(ctx context.Context) {
+	members, err := r.cluster.Nodes()
+	if err != nil {
+		r.logger.Warn("Failed to read cluster membership for routing", zap.Error(err))
+		return
+	}
+
+	type result struct {
+		id     string
+		status *rpc.NodeStatus
+		err    error
+	}
+	results := make(chan result, len(members))
+	polled := 0
+	for _, member := range members {
+		if member.ID == r.options.NodeID {
+			continue
+		}
+		polled++
+		go func(member raft.NodeInfo) {
+			pollCtx, cancel := context.WithTimeout(ctx, statusTimeout)
+			defer cancel()
+			status, err := r.rpcClient.NodeStatus(pollCtx, member.Address, r.options.MasterKey)
+			results <- result{id: member.ID, status: status, err: err}
+		}(member)
+	}
+
+	peers := make(map[string]*peer, polled)
+	r.mu.RLock()
+	for _, member := range members {
+		if p, ok := r.peers[member.ID]; ok {
+			peers[member.ID] = p
+		}
+	}
+	r.mu.RUnlock()
+
+	now := time.Now()
+	for range polled {
+		res := <-results
+		p, ok := peers[res.id]
+		if !ok {
+			p = &peer{}
+			peers[res.id] = p
+		}
+		r.mu.Lock()
+		if res.err != nil {
+			p.err = res.err.Error()
+			r.logger.Debug("Failed to poll node for routing", zap.String("node_id", res.id), zap.Error(res.err))
+		} else {
+			p.status, p.checkedAt, p.err = *res.status, now, ""
+		}
+		r.mu.Unlock()
+	}
+
+	r.mu.Lock()
+	r.members = members
+	r.peers = peers
+	r.mu.Unlock()
+}
+
+// Table returns the routing policy and the nodes searches are routed
+// between, with the node the next search goes to
+func (r *Router) Table() Table {
+	policy := r.Policy()
+	nodes := r.loads(policy)
+	return Table{
+		Policy: policy,
+		NodeID: r.options.NodeID,
+		Route:  pick(nodes).ID,
+		Nodes:  nodes,
+	}
+}
+
+// Pick returns the node the next search goes to: the eligible node with the
+// fewest requests in flight, this node on ties
+func (r *Router) Pick() NodeLoad {
+	return pick(r.loads(r.Policy()))
+}
+
+// Forward sends the current search to node, counting it in the node's load
+// until it is answered. It returns an error without responding when the
+// node can't be reached, which makes it ineligible until its next poll.
+func (r *Router) Forward(c *fiber.Ctx, node NodeLoad) error {
+	r.mu.RLock()
+	p := r.peers[node.ID]
+	r.mu.RUnlock()
+	if p == nil {
+		return fiber.ErrServiceUnavailable
+	}
+
+	r.forwarding.Add(1)
+	p.routed.Add(1)
+	defer func() {
+		p.routed.Add(-1)
+		r.forwarding.Add(-1)
+	}()
+
+	err := rpc.ForwardSearch(c, r.rpcClient, node.Address, r.options.NodeID)
+	if err != nil {
+		r.mu.Lock()
+		p.err = err.Error()
+		r.mu.Unlock()
+	}
+	return err
+}
+
+// loads returns the nodes of the cluster as last seen, this node first and
+// the others by ID
+func (r *Router) loads(policy models.RoutingPolicy) []NodeLoad {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	self := NodeLoad{
+		ID:           r.options.NodeID,
+		AppliedIndex: r.cluster.AppliedIndex(),
+		Tags:         r.options.Tags,
+		InFlight:     r.InFlight(),
+		Eligible:     true,
+		CheckedAt:    &now,
+	}
+	nodes := []NodeLoad{self}
+	for _, member := range r.members {
+		if member.ID == r.options.NodeID {
+			nodes[0].Address, nodes[0].HTTPAddress, nodes[0].IsLeader = member.Address, rpc.HTTPAddr(member.Address), member.IsLeader
+			continue
+		}
+		node := NodeLoad{
+			ID:          member.ID,
+			Address:     member.Address,
+			HTTPAddress: rpc.HTTPAddr(member.Address),
+			IsLeader:    member.IsLeader,
+		}
+		if p, ok := r.peers[member.ID]; ok {
+			node.Error = p.err
+			if !p.checkedAt.IsZero() {
+				checkedAt := p.checkedAt
+				node.CheckedAt = &checkedAt
+				node.AppliedIndex = p.status.AppliedIndex
+				node.Tags = p.status.Tags
+				node.InFlight = p.status.InFlight + p.routed.Load()
+				node.Eligible = p.err == "" && now.Sub(checkedAt) <= stalePolls*r.options.Interval
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	slices.SortFunc(nodes[1:], func(a, b NodeLoad) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	var latest uint64
+	for _, node := range nodes {
+		if node.Eligible {
+			latest = max(latest, node.AppliedIndex)
+		}
+	}
+	for i := range nodes {
+		if nodes[i].CheckedAt == nil {
+			continue
+		}
+		nodes[i].Lag = latest - min(nodes[i].AppliedIndex, latest)
+		if nodes[i].Lag > policy.MaxLag {
+			nodes[i].Eligible = false
+		}
+	}
+	for i := range nodes {
+		nodes[i].Preferred = sameTags(nodes[i].Tags, r.options.Tags, policy.PreferTags)
+	}
+	return nodes
+}
+
+// sameTags returns true when a and b have the same value of each of names
+func sameTags(a, b map[string]string, names []string) bool {
+	for _, name := range names {
+		if a[name] != b[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// pick returns the eligible node with the fewest requests in flight among
+// the preferred ones, or among all when none is eligible, taking the first
+// on ties. nodes[0] is this node, which serves the search when no node is
+// eligible, even lagging.
+func pick(nodes []NodeLoad) NodeLoad {
+	for _, preferredOnly := range []bool{true, false} {
+		best := -1
+		for i, node := range nodes {
+			if !node.Eligible || preferredOnly && !node.Preferred {
+				continue
+			}
+			if best < 0 || node.InFlight < nodes[best].InFlight {
+				best = i
+			}
+		}
+		if best >= 0 {
+			return nodes[best]
+		}
+	}
+	return nodes[0]
+}
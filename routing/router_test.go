@@ -0,0 +1,176 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"bright/models"
+	"bright/raft"
+	"bright/rpc"
+	"bright/store"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// fakeCluster is a cluster of nodes a (this one), b and c
+type fakeCluster struct{}
+
+func (fakeCluster) Nodes() ([]raft.NodeInfo, error) {
+	return []raft.NodeInfo{
+		{ID: "c", Address: "c:7000"},
+		{ID: "a", Address: "a:7000", IsLeader: true},
+		{ID: "b", Address: "b:7000"},
+	}, nil
+}
+
+func (fakeCluster) AppliedIndex() uint64 { return 100 }
+
+// fakePeers answers status polls and forwarded searches by Raft address
+type fakePeers struct {
+	mu        sync.Mutex
+	statuses  map[string]*rpc.NodeStatus
+	forwarded []*rpc.ForwardedRequest
+}
+
+func (p *fakePeers) ForwardRequest(_ context.Context, addr string, req *rpc.ForwardedRequest) (*rpc.ForwardedResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.forwarded = append(p.forwarded, req)
+	return &rpc.ForwardedResponse{StatusCode: 200, Body: []byte("served by " + addr)}, nil
+}
+
+func (p *fakePeers) ClusterJoin(context.Context, string, string, string, string) error {
+	return nil
+}
+
+func (p *fakePeers) NodeStatus(_ context.Context, addr, _ string) (*rpc.NodeStatus, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	status, ok := p.statuses[addr]
+	if !ok {
+		return nil, errors.New("connection refused")
+	}
+	return status, nil
+}
+
+func TestRouter(t *testing.T) {
+	indexStore, err := store.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer indexStore.Close()
+	if err := indexStore.Routing().Set(models.RoutingPolicy{Mode: models.RoutingProxy, MaxLag: 50}); err != nil {
+		t.Fatalf("Failed to set policy: %v", err)
+	}
+
+	peers := &fakePeers{statuses: map[string]*rpc.NodeStatus{
+		"b:7000": {NodeID: "b", AppliedIndex: 95, InFlight: 1},
+		"c:7000": {NodeID: "c", AppliedIndex: 10},
+	}}
+	router := NewRouter(indexStore, fakeCluster{}, peers, Options{NodeID: "a"}, zap.NewNop())
+	router.Tick(context.Background())
+
+	// c lags too far to be routed searches, and a is idle
+	table := router.Table()
+	if len(table.Nodes) != 3 || table.Nodes[0].ID != "a" || table.Nodes[1].ID != "b" || table.Nodes[2].ID != "c" {
+		t.Fatalf("Expected nodes a, b and c, got %+v", table.Nodes)
+	}
+	if b := table.Nodes[1]; !b.Eligible || b.Lag != 5 || b.HTTPAddress != "b:3000" {
+		t.Errorf("Expected b to be eligible with a lag of 5, got %+v", b)
+	}
+	if c := table.Nodes[2]; c.Eligible || c.Lag != 90 {
+		t.Errorf("Expected c to lag 90 entries and be ineligible, got %+v", c)
+	}
+	if table.Route != "a" {
+		t.Errorf("Expected searches to stay on a, got %s", table.Route)
+	}
+
+	// Once a is busier, searches go to b
+	for range 2 {
+		defer indexStore.Jobs().Foreground()()
+	}
+	node := router.Pick()
+	if node.ID != "b" {
+		t.Fatalf("Expected searches to go to b, got %+v", node)
+	}
+
+	app := fiber.New()
+	app.Post("/indexes/books/searches", func(c *fiber.Ctx) error {
+		return router.Forward(c, node)
+	})
+	resp, err := app.Test(httptest.NewRequest("POST", "/indexes/books/searches?q=dune&attributesToRetrieve=a&attributesToRetrieve=b", nil))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "served by b:7000" {
+		t.Errorf("Expected b to serve the search, got %q", body)
+	}
+	forwarded := peers.forwarded[0]
+	if forwarded.Headers[rpc.HeaderRoutedBy] != "a" || forwarded.RawQuery != "q=dune&attributesToRetrieve=a&attributesToRetrieve=b" {
+		t.Errorf("Expected the search to be marked as routed with its query string, got %+v", forwarded)
+	}
+	if router.forwarding.Load() != 0 {
+		t.Errorf("Expected no search in flight to other nodes")
+	}
+
+	// An unreachable node isn't routed searches
+	peers.mu.Lock()
+	delete(peers.statuses, "b:7000")
+	peers.mu.Unlock()
+	router.Tick(context.Background())
+	table = router.Table()
+	if b := table.Nodes[1]; b.Eligible || b.Error == "" {
+		t.Errorf("Expected b to be ineligible with an error, got %+v", b)
+	}
+	if table.Route != "a" {
+		t.Errorf("Expected searches to stay on a, got %s", table.Route)
+	}
+}
+
+func TestRouterPreferTags(t *testing.T) {
+	indexStore, err := store.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer indexStore.Close()
+	if err := indexStore.Routing().Set(models.RoutingPolicy{Mode: models.RoutingProxy, PreferTags: []string{"zone"}}); err != nil {
+		t.Fatalf("Failed to set policy: %v", err)
+	}
+
+	peers := &fakePeers{statuses: map[string]*rpc.NodeStatus{
+		"b:7000": {NodeID: "b", AppliedIndex: 100, Tags: map[string]string{"zone": "west"}},
+		"c:7000": {NodeID: "c", AppliedIndex: 100, InFlight: 3, Tags: map[string]string{"zone": "east"}},
+	}}
+	router := NewRouter(indexStore, fakeCluster{}, peers, Options{NodeID: "a", Tags: map[string]string{"zone": "east"}}, zap.NewNop())
+	router.Tick(context.Background())
+
+	// a is busier than b, but searches stay in its zone with c
+	for range 5 {
+		defer indexStore.Jobs().Foreground()()
+	}
+	table := router.Table()
+	if table.Route != "c" {
+		t.Errorf("Expected searches to go to c, got %+v", table)
+	}
+	if b := table.Nodes[1]; b.Preferred || b.Tags["zone"] != "west" {
+		t.Errorf("Expected b not to be preferred, got %+v", b)
+	}
+
+	// Without an eligible node in the zone, the others take searches
+	if err := indexStore.Routing().Set(models.RoutingPolicy{Mode: models.RoutingProxy, MaxLag: 1, PreferTags: []string{"zone"}}); err != nil {
+		t.Fatalf("Failed to set policy: %v", err)
+	}
+	peers.mu.Lock()
+	peers.statuses["b:7000"].AppliedIndex = 120
+	peers.mu.Unlock()
+	router.Tick(context.Background())
+	if node := router.Pick(); node.ID != "b" {
+		t.Errorf("Expected searches to go to b, got %+v", node)
+	}
+}
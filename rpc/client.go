@@ -39,13 +39,15 @@ func NewHTTPRPCClient(logger *zap.Logger) *HTTPRPCClient {
 // ForwardRequest forwards an HTTP request to the leader node
 func (c *HTTPRPCClient) ForwardRequest(ctx context.Context, leaderRaftAddr string, req *ForwardedRequest) (*ForwardedResponse, error) {
 	// Convert Raft address (port 7000) to HTTP address (port 3000)
-	httpAddr := convertRaftAddrToHTTP(leaderRaftAddr)
+	httpAddr := HTTPAddr(leaderRaftAddr)
 
 	// Construct full URL
 	url := fmt.Sprintf("http://%s%s", httpAddr, req.Path)
 
 	// Add query parameters if present
-	if len(req.QueryParams) > 0 {
+	if req.RawQuery != "" {
+		url += "?" + req.RawQuery
+	} else if len(req.QueryParams) > 0 {
 		url += "?"
 		first := true
 		for key, value := range req.QueryParams {
@@ -113,15 +115,15 @@ func (c *HTTPRPCClient) ForwardRequest(ctx context.Context, leaderRaftAddr strin
 	}, nil
 }
 
-// convertRaftAddrToHTTP converts a Raft address (port 7000) to HTTP API address (port 3000)
-func convertRaftAddrToHTTP(raftAddr string) string {
+// HTTPAddr converts a Raft address (port 7000) to HTTP API address (port 3000)
+func HTTPAddr(raftAddr string) string {
 	return strings.Replace(raftAddr, ":7000", ":3000", 1)
 }
 
 // ClusterJoin sends a cluster join request to a peer node
 func (c *HTTPRPCClient) ClusterJoin(ctx context.Context, peerRaftAddr, nodeID, addr, masterKey string) error {
 	// Convert Raft address (port 7000) to HTTP address (port 3000)
-	httpAddr := convertRaftAddrToHTTP(peerRaftAddr)
+	httpAddr := HTTPAddr(peerRaftAddr)
 
 	// Prepare join request
 	joinReq := map[string]string{
@@ -179,3 +181,36 @@ func (c *HTTPRPCClient) ClusterJoin(ctx context.Context, peerRaftAddr, nodeID, a
 
 	return nil
 }
+
+// NodeStatus reads the cluster status of a peer node
+func (c *HTTPRPCClient) NodeStatus(ctx context.Context, peerRaftAddr, masterKey string) (*NodeStatus, error) {
+	httpAddr := HTTPAddr(peerRaftAddr)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("http://%s/cluster/status", httpAddr), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create status request: %w", err)
+	}
+	if masterKey != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", masterKey))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to contact peer: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read status: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var status NodeStatus
+	if err := sonic.Unmarshal(body, &status); err != nil {
+		return nil, fmt.Errorf("failed to parse status: %w", err)
+	}
+	return &status, nil
+}
@@ -10,15 +10,54 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
+// HeaderRoutedBy carries the ID of the node that forwarded a search under
+// the cluster's routing policy, so the node receiving it doesn't forward it
+// again
+const HeaderRoutedBy = "X-Bright-Routed-By"
+
 // ForwardToLeader forwards the current request to the leader node
 func ForwardToLeader(c *fiber.Ctx, rpcClient RPCClient, leaderRaftAddr string) error {
 	if rpcClient == nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "RPC client not initialized",
-		})
+		return brerrors.InternalError(c, brerrors.ErrorCodeInternalError, "RPC client not initialized")
 	}
 
 	// Extract request details from Fiber context
+	req := forwardedRequest(c)
+
+	// Extract query parameters
+	for key, value := range c.Request().URI().QueryArgs().All() {
+		req.QueryParams[string(key)] = string(value)
+	}
+
+	// Forward request to leader
+	resp, err := forward(rpcClient, leaderRaftAddr, req)
+	if err != nil {
+		return brerrors.ServiceUnavailableWithLeader(c, brerrors.ErrorCodeClusterUnavailable, fmt.Sprintf("Failed to forward request to leader: %v", err), leaderRaftAddr)
+	}
+
+	// Return leader's response
+	return respond(c, resp)
+}
+
+// ForwardSearch forwards the current search to another node, marked as
+// routed by nodeID. The query string is passed on as is, repeated parameters
+// included. When the node can't be reached it returns the error without
+// responding, so the search can run locally instead.
+func ForwardSearch(c *fiber.Ctx, rpcClient RPCClient, raftAddr, nodeID string) error {
+	req := forwardedRequest(c)
+	req.RawQuery = string(c.Request().URI().QueryString())
+	req.Headers[HeaderRoutedBy] = nodeID
+
+	resp, err := forward(rpcClient, raftAddr, req)
+	if err != nil {
+		return err
+	}
+	return respond(c, resp)
+}
+
+// forwardedRequest copies the method, path, body and critical headers of
+// the current request
+func forwardedRequest(c *fiber.Ctx) *ForwardedRequest {
 	req := &ForwardedRequest{
 		Method:      c.Method(),
 		Path:        c.Path(),
@@ -34,31 +73,28 @@ func ForwardToLeader(c *fiber.Ctx, rpcClient RPCClient, leaderRaftAddr string) e
 	if contentType := c.Get("Content-Type"); contentType != "" {
 		req.Headers["Content-Type"] = contentType
 	}
-
-	// Extract query parameters
-	for key, value := range c.Request().URI().QueryArgs().All() {
-		req.QueryParams[string(key)] = string(value)
+	if requestID := brerrors.RequestID(c); requestID != "" {
+		req.Headers[fiber.HeaderXRequestID] = requestID
 	}
+	return req
+}
 
+// forward sends a request to the node at raftAddr
+func forward(rpcClient RPCClient, raftAddr string, req *ForwardedRequest) (*ForwardedResponse, error) {
 	// Determine timeout - try to get from HTTPRPCClient, otherwise use default
 	timeout := 10 * time.Second
 	if httpClient, ok := rpcClient.(*HTTPRPCClient); ok {
 		timeout = httpClient.timeout
 	}
 
-	// Forward request to leader
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	resp, err := rpcClient.ForwardRequest(ctx, leaderRaftAddr, req)
-	if err != nil {
-		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
-			"code":    brerrors.ErrorCodeClusterUnavailable,
-			"message": fmt.Sprintf("Failed to forward request to leader: %v", err),
-			"leader":  leaderRaftAddr,
-		})
-	}
+	return rpcClient.ForwardRequest(ctx, raftAddr, req)
+}
 
+// respond sends the response of a forwarded request
+func respond(c *fiber.Ctx, resp *ForwardedResponse) error {
 	// Set response headers
 	for key, value := range resp.Headers {
 		// Skip headers that Fiber handles automatically
@@ -67,6 +103,5 @@ func ForwardToLeader(c *fiber.Ctx, rpcClient RPCClient, leaderRaftAddr string) e
 		}
 	}
 
-	// Return leader's response
 	return c.Status(resp.StatusCode).Send(resp.Body)
 }
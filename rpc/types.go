@@ -9,6 +9,9 @@ type RPCClient interface {
 
 	// ClusterJoin sends a cluster join request to a peer node
 	ClusterJoin(ctx context.Context, peerRaftAddr, nodeID, addr, masterKey string) error
+
+	// NodeStatus reads the cluster status of a peer node
+	NodeStatus(ctx context.Context, peerRaftAddr, masterKey string) (*NodeStatus, error)
 }
 
 // ForwardedRequest represents an HTTP request to be forwarded to the leader
@@ -18,6 +21,7 @@ type ForwardedRequest struct {
 	Body        []byte            // Request body
 	Headers     map[string]string // HTTP headers to preserve
 	QueryParams map[string]string // Query parameters
+	RawQuery    string            // Query string, sent instead of QueryParams when set
 }
 
 // ForwardedResponse represents the response from a forwarded request
@@ -26,3 +30,12 @@ type ForwardedResponse struct {
 	Body       []byte            // Response body
 	Headers    map[string]string // Response headers
 }
+
+// NodeStatus is the load and progress a node reports in /cluster/status
+type NodeStatus struct {
+	NodeID       string `json:"node_id"`
+	AppliedIndex uint64 `json:"applied_index"`
+	InFlight     int64  `json:"in_flight"`
+
+	Tags map[string]string `json:"tags,omitempty"`
+}
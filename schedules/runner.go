@@ -0,0 +1,286 @@
+package schedules
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"bright/models"
+	"bright/notify"
+	"bright/raft"
+	"bright/search"
+	"bright/store"
+
+	"go.uber.org/zap"
+)
+
+// DefaultInterval is how often schedules are checked when no interval is
+// set. Cron expressions have a resolution of one minute.
+const DefaultInterval = 15 * time.Second
+
+// Options configure a Runner
+type Options struct {
+	// Interval between checks for due schedules (DefaultInterval if zero)
+	Interval time.Duration
+
+	// NodeID identifies this node in deliveries
+	NodeID string
+
+	// Sender delivers hits (one without email support if nil)
+	Sender *notify.Sender
+}
+
+// Status is the run state of a schedule on this node
+type Status struct {
+	NextRunAt *time.Time `json:"nextRunAt,omitempty"`
+	LastRunAt *time.Time `json:"lastRunAt,omitempty"`
+	LastHits  *uint64    `json:"lastHits,omitempty"`
+	LastError string     `json:"lastError,omitempty"`
+}
+
+// scheduleState tracks a schedule between checks
+type scheduleState struct {
+	Status
+
+	// timing resets the next run when a schedule's timing is changed
+	timing string
+}
+
+// Runner runs scheduled searches when they are due and delivers their hits.
+// In Raft mode only the leader runs them, so each run is delivered once.
+// Runs due while no node leads are skipped.
+type Runner struct {
+	store    *store.IndexStore
+	raftNode *raft.RaftNode
+	sender   *notify.Sender
+	interval time.Duration
+	nodeID   string
+	logger   *zap.Logger
+
+	states map[string]*scheduleState
+	mu     sync.Mutex
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewRunner creates a runner. raftNode may be nil.
+func NewRunner(store *store.IndexStore, raftNode *raft.RaftNode, options Options, logger *zap.Logger) *Runner {
+	interval := options.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	sender := options.Sender
+	if sender == nil {
+		sender = notify.NewSender(notify.SMTPConfig{})
+	}
+	return &Runner{
+		store:    store,
+		raftNode: raftNode,
+		sender:   sender,
+		interval: interval,
+		nodeID:   options.NodeID,
+		logger:   logger,
+		states:   make(map[string]*scheduleState),
+	}
+}
+
+// EmailEnabled returns true if hits can be delivered by email
+func (r *Runner) EmailEnabled() bool {
+	return r.sender.EmailEnabled()
+}
+
+// Start checks for due schedules every interval until Stop is called
+func (r *Runner) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				r.Tick(ctx, now)
+			}
+		}
+	}()
+}
+
+// Stop halts the runner and waits for running schedules to finish
+func (r *Runner) Stop() {
+	if r.cancel == nil {
+		return
+	}
+	r.cancel()
+	<-r.done
+}
+
+// Status returns the run state of a schedule on this node
+func (r *Runner) Status(id string) Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if state, ok := r.states[id]; ok {
+		return state.Status
+	}
+	return Status{}
+}
+
+// Tick runs the schedules that are due. A schedule seen for the first time
+// (or after its timing changed) is only planned, so it first runs at its
+// next matching minute.
+func (r *Runner) Tick(ctx context.Context, now time.Time) {
+	leader := r.raftNode == nil || r.raftNode.IsLeader()
+
+	var due []*models.ScheduledSearch
+	planned := make(map[string]bool)
+
+	r.mu.Lock()
+	if leader {
+		for _, schedule := range r.store.Schedules().List() {
+			if schedule.Disabled {
+				continue
+			}
+			planned[schedule.ID] = true
+			if r.plan(schedule, now) {
+				due = append(due, schedule)
+			}
+		}
+	}
+
+	// Schedules that were deleted, disabled or run elsewhere start over
+	for id := range r.states {
+		if !planned[id] {
+			delete(r.states, id)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, schedule := range due {
+		if err := r.Run(ctx, schedule, now); err != nil {
+			r.logger.Warn("Scheduled search failed",
+				zap.String("schedule", schedule.ID),
+				zap.String("index", schedule.Index),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// plan updates the next run of a schedule and reports whether it is due
+// (must be called with lock held)
+func (r *Runner) plan(schedule *models.ScheduledSearch, now time.Time) bool {
+	timing := schedule.Schedule + "\x00" + schedule.Timezone
+	state, ok := r.states[schedule.ID]
+	if !ok || state.timing != timing {
+		state = &scheduleState{timing: timing}
+		r.states[schedule.ID] = state
+	}
+
+	if state.NextRunAt == nil {
+		next, err := nextRun(schedule, now)
+		if err != nil {
+			state.LastError = err.Error()
+			return false
+		}
+		state.NextRunAt = &next
+		return false
+	}
+
+	return !now.Before(*state.NextRunAt)
+}
+
+// nextRun returns the first run of a schedule after t
+func nextRun(schedule *models.ScheduledSearch, t time.Time) (time.Time, error) {
+	cron, err := ParseCron(schedule.Schedule)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := location(schedule.Timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := cron.Next(t.In(loc))
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("schedule %q never runs", schedule.Schedule)
+	}
+	return next, nil
+}
+
+// Run runs a schedule's search now and delivers the hits, recording the
+// outcome in its status
+func (r *Runner) Run(ctx context.Context, schedule *models.ScheduledSearch, now time.Time) error {
+	response, err := r.search(schedule)
+	if err == nil && !(schedule.SkipEmpty && response.TotalHits == 0) {
+		err = deliver(ctx, r.sender, schedule, Delivery{
+			ScheduleID: schedule.ID,
+			Name:       schedule.Name,
+			Index:      schedule.Index,
+			RunAt:      now,
+			TotalHits:  response.TotalHits,
+			Hits:       response.Hits,
+			Node:       r.nodeID,
+		})
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, ok := r.states[schedule.ID]
+	if !ok {
+		state = &scheduleState{timing: schedule.Schedule + "\x00" + schedule.Timezone}
+		r.states[schedule.ID] = state
+	}
+	runAt := now
+	state.LastRunAt = &runAt
+	state.LastError = ""
+	state.LastHits = nil
+	if response != nil {
+		hits := response.TotalHits
+		state.LastHits = &hits
+	}
+	if err != nil {
+		state.LastError = err.Error()
+	}
+	if next, nextErr := nextRun(schedule, now); nextErr == nil {
+		state.NextRunAt = &next
+	}
+	return err
+}
+
+// search runs a schedule's search like /indexes/:id/searches would for a
+// key without the hidden scope
+func (r *Runner) search(schedule *models.ScheduledSearch) (*models.SearchResponse, error) {
+	index, config, err := r.store.GetIndex(schedule.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	req := schedule.Search
+	req.IndexID = config.ID
+	req.HiddenAttributes = config.HiddenAttributes
+	req.SortableAttributes = config.SortableAttributes
+	req.FilterableAttributes = config.FilterableAttributes
+	req.GeoAttributes = config.GeoAttributes
+	req.Vectors = config.Vectors
+	req.MaxLimit = config.MaxLimit
+	req.MaxTotalHits = config.MaxTotalHits
+	req.Synonyms = config.Synonyms
+	req.SearchableAttributes = config.SearchableAttributes
+	search.ApplyDefaults(&req, config.SearchDefaults)
+
+	response, err := search.Execute(index, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := search.ApplyLookups(response.Hits, req.Lookups, r.store.GetIndex, false); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
@@ -0,0 +1,120 @@
+// Package schedules runs scheduled searches and delivers their hits to
+// webhooks and email addresses.
+package schedules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"bright/models"
+	"bright/notify"
+	"bright/search"
+
+	"github.com/bytedance/sonic"
+)
+
+// ErrInvalidSchedule is returned for scheduled searches that cannot run
+var ErrInvalidSchedule = errors.New("invalid scheduled search")
+
+// Validate checks a scheduled search. Schedules with emails need an SMTP
+// server, so emailEnabled reports whether one is configured.
+func Validate(schedule *models.ScheduledSearch, emailEnabled bool) error {
+	if schedule.Index == "" {
+		return fmt.Errorf("%w: index is required", ErrInvalidSchedule)
+	}
+
+	cron, err := ParseCron(schedule.Schedule)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSchedule, err)
+	}
+	loc, err := location(schedule.Timezone)
+	if err != nil {
+		return err
+	}
+	if cron.Next(time.Now().In(loc)).IsZero() {
+		return fmt.Errorf("%w: schedule %q never runs", ErrInvalidSchedule, schedule.Schedule)
+	}
+
+	if err := search.ValidateLookups(schedule.Search.Lookups); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSchedule, err)
+	}
+
+	if err := notify.ValidateChannels(schedule.Webhooks, schedule.Emails, emailEnabled); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSchedule, err)
+	}
+	return nil
+}
+
+// location loads the timezone of a schedule (UTC if empty)
+func location(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidSchedule, timezone)
+	}
+	return loc, nil
+}
+
+// Delivery is the JSON body posted to webhooks after a run
+type Delivery struct {
+	ScheduleID string           `json:"scheduleId"`
+	Name       string           `json:"name,omitempty"`
+	Index      string           `json:"index"`
+	RunAt      time.Time        `json:"runAt"`
+	TotalHits  uint64           `json:"totalHits"`
+	Hits       []map[string]any `json:"hits"`
+	Node       string           `json:"node,omitempty"`
+}
+
+// deliver sends a run's hits to every channel of a schedule and returns the
+// failures
+func deliver(ctx context.Context, sender *notify.Sender, schedule *models.ScheduledSearch, delivery Delivery) error {
+	var errs []error
+
+	for _, webhook := range schedule.Webhooks {
+		if err := sender.PostJSON(ctx, webhook, delivery); err != nil {
+			errs = append(errs, fmt.Errorf("webhook %s: %w", webhook, err))
+		}
+	}
+
+	if len(schedule.Emails) > 0 {
+		title := scheduleTitle(schedule)
+		subject := fmt.Sprintf("[Bright] %s: %d hits", title, delivery.TotalHits)
+
+		var body strings.Builder
+		fmt.Fprintf(&body, "%s matched %d documents in %s at %s.\n", title, delivery.TotalHits, delivery.Index, delivery.RunAt.Format(time.RFC3339))
+		if len(delivery.Hits) > 0 {
+			body.WriteString("\n")
+		}
+		for _, hit := range delivery.Hits {
+			line, err := sonic.Marshal(hit)
+			if err != nil {
+				return fmt.Errorf("failed to marshal hit: %w", err)
+			}
+			body.Write(line)
+			body.WriteString("\n")
+		}
+		if uint64(len(delivery.Hits)) < delivery.TotalHits {
+			fmt.Fprintf(&body, "\n%d more not shown.\n", delivery.TotalHits-uint64(len(delivery.Hits)))
+		}
+
+		if err := sender.Email(schedule.Emails, subject, body.String()); err != nil {
+			errs = append(errs, fmt.Errorf("email: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// scheduleTitle names a schedule by its name, or by its index
+func scheduleTitle(schedule *models.ScheduledSearch) string {
+	if schedule.Name != "" {
+		return schedule.Name
+	}
+	return "Scheduled search on " + schedule.Index
+}
@@ -0,0 +1,134 @@
+package schedules
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"bright/models"
+	"bright/store"
+
+	"go.uber.org/zap"
+)
+
+func TestCronNext(t *testing.T) {
+	// Thursday, 2024-02-29 10:07
+	start := time.Date(2024, 2, 29, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2024, 2, 29, 10, 15, 0, 0, time.UTC)},
+		{"0 8 * * MON-FRI", time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)},
+		{"30 9 1,15 * *", time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 9 29 feb *", time.Date(2028, 2, 29, 9, 0, 0, 0, time.UTC)},
+		// Either day field matches when both are restricted
+		{"0 0 13 * 5", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		cron, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.expr, err)
+			continue
+		}
+		if got := cron.Next(start); !got.Equal(tt.want) {
+			t.Errorf("%q: expected %s, got %s", tt.expr, tt.want, got)
+		}
+	}
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "0 0 * * fun"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("%q: expected an error", expr)
+		}
+	}
+
+	cron, _ := ParseCron("0 0 30 2 *")
+	if next := cron.Next(start); !next.IsZero() {
+		t.Errorf("Expected February 30 to never match, got %s", next)
+	}
+}
+
+func TestRunnerDelivers(t *testing.T) {
+	var mu sync.Mutex
+	var received []Delivery
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var d Delivery
+		json.NewDecoder(r.Body).Decode(&d)
+		mu.Lock()
+		received = append(received, d)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	s, err := store.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer s.Close()
+	if err := s.CreateIndex(&models.IndexConfig{ID: "tickets", PrimaryKey: "id", HiddenAttributes: []string{"email"}}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	docs := []map[string]any{
+		{"id": "1", "status": "open", "email": "a@example.com"},
+		{"id": "2", "status": "closed", "email": "b@example.com"},
+	}
+	if err := s.AddDocuments("tickets", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	schedule := &models.ScheduledSearch{
+		ID:       "open-tickets",
+		Index:    "tickets",
+		Schedule: "0 8 * * *",
+		Search:   models.SearchRequest{Filter: "status:open"},
+		Webhooks: []string{server.URL},
+	}
+	if err := Validate(schedule, false); err != nil {
+		t.Fatalf("Expected a valid schedule, got %v", err)
+	}
+	if err := s.Schedules().Put(schedule); err != nil {
+		t.Fatalf("Failed to store schedule: %v", err)
+	}
+
+	runner := NewRunner(s, nil, Options{NodeID: "node-1"}, zap.NewNop())
+	start := time.Date(2024, 5, 1, 7, 59, 0, 0, time.UTC)
+
+	// The first check only plans the next run
+	runner.Tick(context.Background(), start)
+	status := runner.Status(schedule.ID)
+	if status.NextRunAt == nil || !status.NextRunAt.Equal(start.Add(time.Minute)) {
+		t.Fatalf("Expected the next run at 08:00, got %+v", status)
+	}
+
+	runner.Tick(context.Background(), start.Add(30*time.Second))
+	runner.Tick(context.Background(), start.Add(time.Minute))
+	runner.Tick(context.Background(), start.Add(90*time.Second))
+
+	status = runner.Status(schedule.ID)
+	if status.LastRunAt == nil || status.LastHits == nil || *status.LastHits != 1 || status.LastError != "" {
+		t.Fatalf("Unexpected status after the run: %+v", status)
+	}
+	if !status.NextRunAt.Equal(start.Add(24*time.Hour + time.Minute)) {
+		t.Errorf("Expected the next run a day later, got %s", status.NextRunAt)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 {
+		t.Fatalf("Expected one delivery, got %d", len(received))
+	}
+	d := received[0]
+	if d.ScheduleID != schedule.ID || d.TotalHits != 1 || len(d.Hits) != 1 || d.Node != "node-1" {
+		t.Fatalf("Unexpected delivery: %+v", d)
+	}
+	if _, ok := d.Hits[0]["email"]; ok {
+		t.Errorf("Expected hidden attributes to be stripped, got %v", d.Hits[0])
+	}
+}
@@ -0,0 +1,109 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"bright/models"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// Search-as-you-type fields are also indexed under these prefixes: the
+// prefixes of their words, and pairs of adjacent words
+const (
+	AsYouTypePrefixField  = "_prefix."
+	AsYouTypeShingleField = "_shingle."
+)
+
+// AsYouTypeSearchAnalyzer analyzes search-as-you-type queries. The index
+// mapping registers it with the search-as-you-type fields.
+const AsYouTypeSearchAnalyzer = "sayt.search"
+
+// DefaultAsYouTypeLimit is the number of suggestions returned when no limit
+// is given
+const DefaultAsYouTypeLimit = 10
+
+// Matching strategies of searches: "query" parses the query as a query
+// string, "prefix" matches it as text being typed
+const (
+	MatchingStrategyQuery  = "query"
+	MatchingStrategyPrefix = "prefix"
+)
+
+// ErrInvalidMatchingStrategy is returned for unknown matching strategies,
+// and prefix searches of indexes without search-as-you-type fields
+var ErrInvalidMatchingStrategy = errors.New("invalid matching strategy")
+
+// AsYouTypeFields returns the sorted fields of an index set up for
+// search-as-you-type
+func AsYouTypeFields(config *models.IndexConfig) []string {
+	var fields []string
+	for name, settings := range config.Fields {
+		if settings.SearchAsYouType {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// ApplyMatchingStrategy sets up a request to match its query as its
+// matching strategy says. Prefix searches match the search-as-you-type
+// fields given, as the search-as-you-type endpoint does.
+func ApplyMatchingStrategy(req *models.SearchRequest, fields []string) error {
+	switch req.MatchingStrategy {
+	case "", MatchingStrategyQuery:
+		return nil
+	case MatchingStrategyPrefix:
+		if len(fields) == 0 {
+			return fmt.Errorf("%w: prefix searches need searchAsYouType fields", ErrInvalidMatchingStrategy)
+		}
+		req.AsYouTypeFields = fields
+		return nil
+	}
+	return fmt.Errorf("%w: %q (%s or %s)", ErrInvalidMatchingStrategy, req.MatchingStrategy, MatchingStrategyQuery, MatchingStrategyPrefix)
+}
+
+// commonAsYouTypeFields returns the search-as-you-type fields every target
+// has
+func commonAsYouTypeFields(targets []Target) []string {
+	fields := AsYouTypeFields(targets[0].Config)
+	for _, target := range targets[1:] {
+		own := AsYouTypeFields(target.Config)
+		fields = slices.DeleteFunc(fields, func(field string) bool {
+			return !slices.Contains(own, field)
+		})
+	}
+	return fields
+}
+
+// asYouTypeQuery matches documents where each word of the text starts a word
+// of one of the fields, so the word being typed matches as a prefix. Hits
+// with the words next to each other and in order rank first.
+func asYouTypeQuery(text string, fields []string) query.Query {
+	if strings.TrimSpace(text) == "" {
+		return bleve.NewMatchAllQuery()
+	}
+
+	perField := make([]query.Query, 0, len(fields))
+	for _, field := range fields {
+		prefixes := bleve.NewMatchQuery(text)
+		prefixes.SetField(AsYouTypePrefixField + field)
+		prefixes.Analyzer = AsYouTypeSearchAnalyzer
+		prefixes.SetOperator(query.MatchQueryOperatorAnd)
+
+		pairs := bleve.NewMatchQuery(text)
+		pairs.SetField(AsYouTypeShingleField + field)
+
+		boolean := bleve.NewBooleanQuery()
+		boolean.AddMust(prefixes)
+		boolean.AddShould(pairs)
+		perField = append(perField, boolean)
+	}
+	return bleve.NewDisjunctionQuery(perField...)
+}
@@ -0,0 +1,96 @@
+package search
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"bright/models"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// MaxCuratedDocuments bounds the documents a curation rule pins or hides
+const MaxCuratedDocuments = 100
+
+// Ways curation rule patterns match queries
+const (
+	CurationMatchExact    = "exact"
+	CurationMatchContains = "contains"
+)
+
+// ValidateCurationRule checks a curation rule
+func ValidateCurationRule(rule *models.CurationRule) error {
+	if normalizeCurationText(rule.Pattern) == "" {
+		return fmt.Errorf("curation rules need a pattern")
+	}
+	switch rule.Match {
+	case "", CurationMatchExact, CurationMatchContains:
+	default:
+		return fmt.Errorf("match must be %s or %s", CurationMatchExact, CurationMatchContains)
+	}
+	if len(rule.Pinned) == 0 && len(rule.Hidden) == 0 {
+		return fmt.Errorf("curation rules need pinned or hidden documents")
+	}
+	if len(rule.Pinned) > MaxCuratedDocuments || len(rule.Hidden) > MaxCuratedDocuments {
+		return fmt.Errorf("curation rules pin and hide at most %d documents each", MaxCuratedDocuments)
+	}
+	for _, id := range append(slices.Clone(rule.Pinned), rule.Hidden...) {
+		if strings.TrimSpace(id) == "" {
+			return fmt.Errorf("curation rules cannot list empty document IDs")
+		}
+	}
+	return nil
+}
+
+// Curate returns the documents pinned and hidden by the rules matching a
+// query. Pins keep the order of their rules, by ID, and of each rule's
+// list; a document hidden by any rule is not pinned.
+func Curate(rules []*models.CurationRule, q string) (pinned, hidden []string) {
+	text := normalizeCurationText(q)
+	if text == "" {
+		return nil, nil
+	}
+	for _, rule := range rules {
+		pattern := normalizeCurationText(rule.Pattern)
+		matched := text == pattern
+		if rule.Match == CurationMatchContains {
+			matched = strings.Contains(" "+text+" ", " "+pattern+" ")
+		}
+		if !matched {
+			continue
+		}
+		for _, id := range rule.Pinned {
+			if !slices.Contains(pinned, id) {
+				pinned = append(pinned, id)
+			}
+		}
+		for _, id := range rule.Hidden {
+			if !slices.Contains(hidden, id) {
+				hidden = append(hidden, id)
+			}
+		}
+	}
+	pinned = slices.DeleteFunc(pinned, func(id string) bool {
+		return slices.Contains(hidden, id)
+	})
+	return pinned, hidden
+}
+
+// normalizeCurationText lowercases text and collapses its spaces
+func normalizeCurationText(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+// excludeDocuments drops documents from a query's hits by ID, without
+// affecting the scores of the others
+func excludeDocuments(q query.Query, ids []string) query.Query {
+	if len(ids) == 0 {
+		return q
+	}
+	filtered := bleve.NewBooleanQuery()
+	filtered.AddMust(q)
+	filtered.AddMustNot(bleve.NewDocIDQuery(ids))
+	return filtered
+}
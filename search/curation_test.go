@@ -0,0 +1,82 @@
+package search_test
+
+import (
+	"slices"
+	"testing"
+
+	"bright/models"
+	"bright/search"
+)
+
+// TestCurationRules tests matching rules to queries and pinning and hiding
+// their documents across pages and filters
+func TestCurationRules(t *testing.T) {
+	config := &models.IndexConfig{ID: "products", PrimaryKey: "id", FilterableAttributes: []string{"color"}}
+	_, index := newTestIndex(t, config, []map[string]any{
+		{"id": "a", "title": "phone case", "color": "black"},
+		{"id": "b", "title": "phone case phone case", "color": "black"},
+		{"id": "c", "title": "leather case", "color": "red"},
+		{"id": "d", "title": "tablet stand", "color": "black"},
+		{"id": "e", "title": "phone charger", "color": "black"},
+	})
+
+	rules := []*models.CurationRule{
+		{ID: "cases", IndexID: "products", Pattern: "Phone  Case", Pinned: []string{"d", "c", "missing"}, Hidden: []string{"b"}},
+		{ID: "stands", IndexID: "products", Pattern: "case", Match: search.CurationMatchContains, Pinned: []string{"b", "e"}},
+	}
+	for _, rule := range rules {
+		if err := search.ValidateCurationRule(rule); err != nil {
+			t.Fatalf("Invalid rule %s: %v", rule.ID, err)
+		}
+	}
+
+	pinned, hidden := search.Curate(rules, "phone case")
+	if !slices.Equal(pinned, []string{"d", "c", "missing", "e"}) || !slices.Equal(hidden, []string{"b"}) {
+		t.Fatalf("Expected pins [d c missing e] and hidden [b], got %v and %v", pinned, hidden)
+	}
+	if pinned, hidden := search.Curate(rules, "cases"); pinned != nil || hidden != nil {
+		t.Errorf("Expected no rule to match, got %v and %v", pinned, hidden)
+	}
+
+	ids := func(req models.SearchRequest) ([]any, uint64) {
+		t.Helper()
+		req.Query = "phone case"
+		req.FilterableAttributes = config.FilterableAttributes
+		req.PinnedDocuments, req.HiddenDocuments = pinned, hidden
+		response, err := search.Execute(index, req)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		return hitIDs(response.Hits), response.TotalHits
+	}
+
+	tests := []struct {
+		name  string
+		req   models.SearchRequest
+		want  []any
+		total uint64
+	}{
+		{"pins first", models.SearchRequest{}, []any{"d", "c", "e", "a"}, 4},
+		{"first page", models.SearchRequest{Limit: 2}, []any{"d", "c"}, 4},
+		{"straddling page", models.SearchRequest{Limit: 2, Offset: 2}, []any{"e", "a"}, 4},
+		{"filtered pins", models.SearchRequest{Filter: "color:black"}, []any{"d", "e", "a"}, 3},
+	}
+	for _, tt := range tests {
+		got, total := ids(tt.req)
+		if !slices.Equal(got, tt.want) || total != tt.total {
+			t.Errorf("%s: expected %v of %d, got %v of %d", tt.name, tt.want, tt.total, got, total)
+		}
+	}
+
+	invalid := []*models.CurationRule{
+		{ID: "empty", Pattern: " ", Pinned: []string{"a"}},
+		{ID: "nothing", Pattern: "case"},
+		{ID: "match", Pattern: "case", Match: "prefix", Pinned: []string{"a"}},
+		{ID: "blank", Pattern: "case", Hidden: []string{""}},
+	}
+	for _, rule := range invalid {
+		if err := search.ValidateCurationRule(rule); err == nil {
+			t.Errorf("Expected rule %s to be rejected", rule.ID)
+		}
+	}
+}
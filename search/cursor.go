@@ -0,0 +1,117 @@
+package search
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+
+	"bright/models"
+
+	bsearch "github.com/blevesearch/bleve/v2/search"
+	"github.com/bytedance/sonic"
+)
+
+// ErrInvalidCursor is returned for cursors that don't continue the search
+// they are given with, and for searches that can't be paged by cursor
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// CursorStart asks for the first page of a search paged by cursor
+const CursorStart = "*"
+
+// searchCursor continues a search after the last hit of a page
+type searchCursor struct {
+	// After holds the sort values of the last hit
+	After []string `json:"a,omitempty"`
+
+	// Served counts the hits of the previous pages, for maxTotalHits
+	Served int `json:"n"`
+
+	// Key fingerprints the search, so a cursor can't continue another one
+	Key uint64 `json:"k"`
+
+	// PointInTime is the pinned index the pages read (see
+	// models.SearchRequest.PointInTime)
+	PointInTime string `json:"p,omitempty"`
+}
+
+// cursorKey fingerprints what the order of a search's hits depends on
+func cursorKey(req models.SearchRequest) uint64 {
+	data, _ := sonic.ConfigStd.Marshal(struct {
+		Query             string
+		Filter            string
+		Ranges            map[string]models.RangeFilter
+		ExcludeTerms      []string
+		Sort              []string
+		SortOptions       map[string]models.SortOptions
+		AroundLatLng      string
+		AroundRadius      string
+		InsideBoundingBox string
+		GeoField          string
+		MatchingStrategy  string
+		Indexes           []string
+	}{req.Query, req.Filter, req.Ranges, req.ExcludeTerms, req.Sort, req.SortOptions,
+		req.AroundLatLng, req.AroundRadius, req.InsideBoundingBox, req.GeoField, req.MatchingStrategy, req.Indexes})
+	h := fnv.New64a()
+	h.Write(data)
+	return h.Sum64()
+}
+
+// parseCursor decodes the cursor of a request
+func parseCursor(req models.SearchRequest) (*searchCursor, error) {
+	if req.Offset > 0 || req.Page > 1 {
+		return nil, fmt.Errorf("%w: cursors cannot be combined with offset or page", ErrInvalidCursor)
+	}
+	if req.Cursor == CursorStart {
+		return &searchCursor{}, nil
+	}
+
+	cursor, err := decodeCursor(req.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	if cursor.Key != cursorKey(req) || cursor.PointInTime != req.PointInTime {
+		return nil, fmt.Errorf("%w: it belongs to another search", ErrInvalidCursor)
+	}
+	return cursor, nil
+}
+
+// decodeCursor decodes a cursor returned as nextCursor
+func decodeCursor(s string) (*searchCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidCursor)
+	}
+	var cursor searchCursor
+	if err := sonic.Unmarshal(data, &cursor); err != nil || len(cursor.After) == 0 {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidCursor)
+	}
+	return &cursor, nil
+}
+
+// CursorPointInTime returns the point in time a cursor reads, or "" for
+// cursors of searches that aren't pinned
+func CursorPointInTime(cursor string) (string, error) {
+	if cursor == "" || cursor == CursorStart {
+		return "", nil
+	}
+	c, err := decodeCursor(cursor)
+	if err != nil {
+		return "", err
+	}
+	return c.PointInTime, nil
+}
+
+// next returns the cursor continuing after a hit
+func (c *searchCursor) next(req models.SearchRequest, sortOrder bsearch.SortOrder, last *bsearch.DocumentMatch, served int) string {
+	after := make([]string, len(last.Sort))
+	copy(after, last.Sort)
+	for i, sort := range sortOrder {
+		if sort.RequiresScoring() && i < len(after) {
+			after[i] = strconv.FormatFloat(last.Score, 'g', -1, 64)
+		}
+	}
+	data, _ := sonic.Marshal(searchCursor{After: after, Served: c.Served + served, Key: cursorKey(req), PointInTime: req.PointInTime})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
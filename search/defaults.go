@@ -0,0 +1,106 @@
+package search
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"bright/models"
+
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// ApplyDefaults fills the options a request leaves unset from the index's
+// search defaults. A request that retrieves or excludes attributes keeps its
+// own selection, one with a recency boost or score script is not given a
+// default sort, and one that sorts or fuses rankings no default script.
+// Sort options are filled field by field.
+func ApplyDefaults(req *models.SearchRequest, defaults *models.SearchDefaults) {
+	if defaults == nil {
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = defaults.Limit
+	}
+	if len(req.Sort) == 0 && req.RecencyField == "" && req.ScoreScript == "" {
+		req.Sort = defaults.Sort
+	}
+	if req.ScoreScript == "" && len(req.Sort) == 0 && (req.Vector == nil || req.Vector.Fusion == "") {
+		req.ScoreScript = defaults.ScoreScript
+	}
+	if req.Filter == "" {
+		req.Filter = defaults.Filter
+	}
+	if len(req.AttributesToRetrieve) == 0 && len(req.AttributesToExclude) == 0 {
+		req.AttributesToRetrieve = defaults.AttributesToRetrieve
+	}
+	if len(defaults.SortOptions) > 0 {
+		options := maps.Clone(req.SortOptions)
+		if options == nil {
+			options = make(map[string]models.SortOptions, len(defaults.SortOptions))
+		}
+		for field, option := range defaults.SortOptions {
+			own := options[field]
+			if own.Missing == "" {
+				own.Missing = option.Missing
+			}
+			if own.Type == "" {
+				own.Type = option.Type
+			}
+			options[field] = own
+		}
+		req.SortOptions = options
+	}
+}
+
+// ValidateDefaults checks an index's search defaults against its other
+// settings, so a default never makes every search fail
+func ValidateDefaults(config *models.IndexConfig) error {
+	defaults := config.SearchDefaults
+	if defaults == nil {
+		return nil
+	}
+
+	if defaults.Limit < 0 {
+		return fmt.Errorf("searchDefaults.limit must not be negative")
+	}
+	if config.MaxLimit > 0 && defaults.Limit > config.MaxLimit {
+		return fmt.Errorf("searchDefaults.limit exceeds maxLimit %d", config.MaxLimit)
+	}
+
+	for _, sortField := range defaults.Sort {
+		field := strings.TrimPrefix(strings.TrimSpace(sortField), "-")
+		if field == "_score" || field == "_id" || len(config.SortableAttributes) == 0 {
+			continue
+		}
+		if !slices.Contains(config.SortableAttributes, attributeOf(field)) {
+			return fmt.Errorf("searchDefaults.sort: %w: %s", ErrUnsortableAttribute, field)
+		}
+	}
+
+	if err := ValidateSortOptions(defaults.SortOptions); err != nil {
+		return fmt.Errorf("searchDefaults.sortOptions: %w", err)
+	}
+
+	if defaults.Filter != "" {
+		if !IsFilterExpression(defaults.Filter) {
+			if _, err := query.NewQueryStringQuery(defaults.Filter).Parse(); err != nil {
+				return fmt.Errorf("searchDefaults.filter: %w", err)
+			}
+		}
+		if err := ValidateFilter(defaults.Filter, config.FilterableAttributes); err != nil {
+			return fmt.Errorf("searchDefaults.filter: %w", err)
+		}
+	}
+
+	if defaults.ScoreScript != "" {
+		if len(defaults.Sort) > 0 {
+			return fmt.Errorf("searchDefaults.scoreScript cannot be combined with searchDefaults.sort")
+		}
+		if _, err := ParseScoreScript(defaults.ScoreScript); err != nil {
+			return fmt.Errorf("searchDefaults.scoreScript: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,308 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"bright/models"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// Bounds of a duplicate scan
+const (
+	DefaultDuplicateScan   = 10000
+	MaxDuplicateScan       = 100000
+	DefaultDuplicateLimit  = 100
+	DefaultFuzzyThreshold  = 0.8
+	duplicateScanPageSize  = 1000
+	maxFuzzyTokenFrequency = 200
+)
+
+// ErrInvalidDuplicatesRequest is returned for unusable duplicate detection options
+var ErrInvalidDuplicatesRequest = errors.New("invalid duplicates request")
+
+// FindDuplicates groups the documents of an index that share the values of
+// req.Fields and, with req.FuzzyField, have similar text in that field.
+// Values are compared case- and whitespace-insensitively; fuzzy similarity
+// is the Jaccard index of character trigrams. At most req.MaxDocuments
+// documents are scanned, in ID order.
+func FindDuplicates(index bleve.Index, req models.DuplicatesRequest) (*models.DuplicatesResponse, error) {
+	if len(req.Fields) == 0 && req.FuzzyField == "" {
+		return nil, fmt.Errorf("%w: fields or fuzzyField is required", ErrInvalidDuplicatesRequest)
+	}
+	threshold := req.Threshold
+	if threshold == 0 {
+		threshold = DefaultFuzzyThreshold
+	}
+	if threshold < 0 || threshold > 1 {
+		return nil, fmt.Errorf("%w: threshold must be between 0 and 1", ErrInvalidDuplicatesRequest)
+	}
+	maxDocuments := req.MaxDocuments
+	if maxDocuments <= 0 {
+		maxDocuments = DefaultDuplicateScan
+	}
+	if maxDocuments > MaxDuplicateScan {
+		return nil, fmt.Errorf("%w: maxDocuments must not exceed %d", ErrInvalidDuplicatesRequest, MaxDuplicateScan)
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = DefaultDuplicateLimit
+	}
+
+	compared := append(append([]string{}, req.Fields...), req.FuzzyField)
+	if req.FuzzyField == "" {
+		compared = compared[:len(compared)-1]
+	}
+
+	documents, truncated, err := scanDocuments(index, req.Filter, compared, maxDocuments)
+	if err != nil {
+		return nil, err
+	}
+
+	// Exact fields split documents into groups; without fields there is one
+	groups := make(map[string][]map[string]any)
+	var keys []string
+	for _, doc := range documents {
+		key, ok := exactKey(doc, req.Fields)
+		if !ok {
+			continue
+		}
+		if _, seen := groups[key]; !seen {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], doc)
+	}
+
+	clusters := []models.DuplicateCluster{}
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) < 2 {
+			continue
+		}
+		if req.FuzzyField == "" {
+			clusters = append(clusters, models.DuplicateCluster{Key: key, Score: 1, Documents: group})
+			continue
+		}
+		for _, cluster := range fuzzyClusters(group, req.FuzzyField, threshold) {
+			cluster.Key = key
+			clusters = append(clusters, cluster)
+		}
+	}
+
+	// Largest clusters first, then the most similar
+	sort.SliceStable(clusters, func(i, j int) bool {
+		if len(clusters[i].Documents) != len(clusters[j].Documents) {
+			return len(clusters[i].Documents) > len(clusters[j].Documents)
+		}
+		return clusters[i].Score > clusters[j].Score
+	})
+
+	response := &models.DuplicatesResponse{
+		TotalClusters: len(clusters),
+		Scanned:       len(documents),
+		Truncated:     truncated,
+	}
+	if len(clusters) > limit {
+		clusters = clusters[:limit]
+	}
+	response.Clusters = clusters
+	return response, nil
+}
+
+// scanDocuments pages through the documents matching a filter in ID order,
+// keeping only the compared fields. It reports whether documents were left
+// unscanned.
+func scanDocuments(index bleve.Index, filter string, fields []string, maxDocuments int) ([]map[string]any, bool, error) {
+	var q query.Query = bleve.NewMatchAllQuery()
+	if strings.TrimSpace(filter) != "" {
+		var err error
+		if q, err = FilterQuery(filter); err != nil {
+			return nil, false, err
+		}
+	}
+
+	documents := make([]map[string]any, 0, min(maxDocuments, duplicateScanPageSize))
+	var after []string
+	for {
+		size := min(duplicateScanPageSize, maxDocuments+1-len(documents))
+		request := bleve.NewSearchRequestOptions(q, size, 0, false)
+		request.SortBy([]string{"_id"})
+		request.Fields = fields
+		if after != nil {
+			request.SetSearchAfter(after)
+		}
+
+		result, err := index.Search(request)
+		if err != nil {
+			return nil, false, err
+		}
+
+		for _, hit := range result.Hits {
+			if len(documents) == maxDocuments {
+				return documents, true, nil
+			}
+			doc := make(map[string]any, len(hit.Fields)+1)
+			for field, value := range hit.Fields {
+				doc[field] = value
+			}
+			doc["_id"] = hit.ID
+			documents = append(documents, doc)
+		}
+
+		if len(result.Hits) < size {
+			return documents, false, nil
+		}
+		after = []string{result.Hits[len(result.Hits)-1].ID}
+	}
+}
+
+// exactKey joins the normalized values of the exact fields. Documents
+// missing one of them are not compared.
+func exactKey(doc map[string]any, fields []string) (string, bool) {
+	parts := make([]string, 0, len(fields))
+	for _, field := range fields {
+		value, ok := doc[field]
+		if !ok || value == nil {
+			return "", false
+		}
+		normalized := normalizeValue(fmt.Sprint(value))
+		if normalized == "" {
+			return "", false
+		}
+		parts = append(parts, normalized)
+	}
+	return strings.Join(parts, " | "), true
+}
+
+// normalizeValue lowercases text and collapses punctuation and whitespace
+func normalizeValue(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// fuzzyClusters links documents whose field is at least threshold-similar
+// and returns the connected groups. Only documents sharing a word are
+// compared, skipping words so common they carry no signal.
+func fuzzyClusters(documents []map[string]any, field string, threshold float64) []models.DuplicateCluster {
+	texts := make([]string, len(documents))
+	trigrams := make([]map[string]bool, len(documents))
+	postings := make(map[string][]int)
+	for i, doc := range documents {
+		if value, ok := doc[field]; ok && value != nil {
+			texts[i] = normalizeValue(fmt.Sprint(value))
+		}
+		if texts[i] == "" {
+			continue
+		}
+		trigrams[i] = trigramSet(texts[i])
+		seen := make(map[string]bool)
+		for _, word := range strings.Fields(texts[i]) {
+			if !seen[word] {
+				seen[word] = true
+				postings[word] = append(postings[word], i)
+			}
+		}
+	}
+
+	parent := make([]int, len(documents))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	// Lowest similarity of the links that formed each cluster
+	minScore := make(map[int]float64)
+	compared := make(map[[2]int]bool)
+	for _, docs := range postings {
+		if len(docs) < 2 || len(docs) > maxFuzzyTokenFrequency {
+			continue
+		}
+		for a := 0; a < len(docs); a++ {
+			for b := a + 1; b < len(docs); b++ {
+				pair := [2]int{docs[a], docs[b]}
+				if compared[pair] {
+					continue
+				}
+				compared[pair] = true
+
+				score := jaccard(trigrams[pair[0]], trigrams[pair[1]])
+				if score < threshold {
+					continue
+				}
+				rootA, rootB := find(pair[0]), find(pair[1])
+				low := score
+				for _, root := range []int{rootA, rootB} {
+					if s, ok := minScore[root]; ok && s < low {
+						low = s
+					}
+				}
+				if rootA != rootB {
+					parent[rootB] = rootA
+				}
+				minScore[rootA] = low
+			}
+		}
+	}
+
+	members := make(map[int][]int)
+	var roots []int
+	for i := range documents {
+		if _, linked := minScore[find(i)]; !linked {
+			continue
+		}
+		root := find(i)
+		if _, seen := members[root]; !seen {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], i)
+	}
+
+	clusters := make([]models.DuplicateCluster, 0, len(roots))
+	for _, root := range roots {
+		if len(members[root]) < 2 {
+			continue
+		}
+		cluster := models.DuplicateCluster{Score: minScore[root]}
+		for _, i := range members[root] {
+			cluster.Documents = append(cluster.Documents, documents[i])
+		}
+		clusters = append(clusters, cluster)
+	}
+	return clusters
+}
+
+// trigramSet returns the character trigrams of a padded text
+func trigramSet(text string) map[string]bool {
+	runes := []rune("  " + text + " ")
+	set := make(map[string]bool, len(runes))
+	for i := 0; i+3 <= len(runes); i++ {
+		set[string(runes[i:i+3])] = true
+	}
+	return set
+}
+
+// jaccard is the size of the intersection of two sets over their union
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for gram := range a {
+		if b[gram] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
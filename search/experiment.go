@@ -0,0 +1,98 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"slices"
+	"strings"
+
+	"bright/models"
+)
+
+// ErrUnknownVariant is returned for ranking variants an index's experiment
+// doesn't have
+var ErrUnknownVariant = errors.New("unknown ranking variant")
+
+// MaxVariants bounds the variants of a ranking experiment
+const MaxVariants = 10
+
+// ValidateExperiment checks the variants of a ranking experiment
+func ValidateExperiment(experiment *models.RankingExperiment) error {
+	if experiment == nil {
+		return nil
+	}
+	if len(experiment.Variants) < 2 || len(experiment.Variants) > MaxVariants {
+		return fmt.Errorf("experiments need 2 to %d variants", MaxVariants)
+	}
+	total := 0
+	for i, variant := range experiment.Variants {
+		if strings.TrimSpace(variant.Name) == "" {
+			return fmt.Errorf("experiment variants need a name")
+		}
+		if slices.ContainsFunc(experiment.Variants[:i], func(v models.RankingVariant) bool { return v.Name == variant.Name }) {
+			return fmt.Errorf("duplicate experiment variant %s", variant.Name)
+		}
+		if variant.Traffic < 0 {
+			return fmt.Errorf("traffic of variant %s must not be negative", variant.Name)
+		}
+		total += variant.Traffic
+		if err := ValidateSearchableAttributes(variant.SearchableAttributes); err != nil {
+			return fmt.Errorf("variant %s: %w", variant.Name, err)
+		}
+		if variant.ScoreScript != "" {
+			if _, err := ParseScoreScript(variant.ScoreScript); err != nil {
+				return fmt.Errorf("variant %s: %w", variant.Name, err)
+			}
+		}
+	}
+	if total == 0 {
+		return fmt.Errorf("experiment variants need traffic")
+	}
+	return nil
+}
+
+// ChooseVariant picks the variant of an experiment serving a search: the
+// named one, or one drawn by traffic share. The draw hashes userToken when
+// set, so a user keeps the same variant.
+func ChooseVariant(experiment *models.RankingExperiment, name, userToken string) (*models.RankingVariant, error) {
+	if name != "" {
+		for i := range experiment.Variants {
+			if experiment.Variants[i].Name == name {
+				return &experiment.Variants[i], nil
+			}
+		}
+		return nil, fmt.Errorf("%w: %s", ErrUnknownVariant, name)
+	}
+
+	total := 0
+	for _, variant := range experiment.Variants {
+		total += variant.Traffic
+	}
+	draw := rand.IntN(total)
+	if userToken != "" {
+		h := fnv.New32a()
+		h.Write([]byte(userToken))
+		draw = int(h.Sum32() % uint32(total))
+	}
+	for i := range experiment.Variants {
+		if draw < experiment.Variants[i].Traffic {
+			return &experiment.Variants[i], nil
+		}
+		draw -= experiment.Variants[i].Traffic
+	}
+	return &experiment.Variants[len(experiment.Variants)-1], nil
+}
+
+// ApplyVariant replaces the ranking settings of a request with a variant's,
+// leaving a score script given by the request
+func ApplyVariant(req *models.SearchRequest, variant *models.RankingVariant) {
+	req.Variant = variant.Name
+	if variant.SearchableAttributes != nil {
+		req.SearchableAttributes = variant.SearchableAttributes
+	}
+	if req.ScoreScript == "" {
+		req.ScoreScript = variant.ScoreScript
+	}
+}
@@ -0,0 +1,19 @@
+package search
+
+import (
+	bsearch "github.com/blevesearch/bleve/v2/search"
+)
+
+// explainRescore records a change of a hit's score made after bleve scored
+// it, keeping bleve's explanation as its child. Hits of searches without
+// explain have none and are left alone.
+func explainRescore(hit *bsearch.DocumentMatch, message string) {
+	if hit.Expl == nil {
+		return
+	}
+	hit.Expl = &bsearch.Explanation{
+		Value:    hit.Score,
+		Message:  message,
+		Children: []*bsearch.Explanation{hit.Expl},
+	}
+}
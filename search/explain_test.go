@@ -0,0 +1,45 @@
+package search_test
+
+import (
+	"testing"
+
+	"bright/models"
+	"bright/search"
+
+	bsearch "github.com/blevesearch/bleve/v2/search"
+)
+
+// TestExplain tests the scoring explanations of hits, including rescoring
+func TestExplain(t *testing.T) {
+	_, index := newTestIndex(t, &models.IndexConfig{ID: "pets", PrimaryKey: "id"}, []map[string]any{
+		{"id": "1", "title": "Lazy dog", "popularity": 3.0},
+	})
+
+	response, err := search.Execute(index, models.SearchRequest{Query: "dog", Limit: 10, Explain: true, ShowMeta: true})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	expl, ok := response.Hits[0]["_explanation"].(*bsearch.Explanation)
+	if !ok || expl.Value != response.Hits[0]["_meta"].(map[string]any)["_score"] || len(expl.Children) == 0 {
+		t.Fatalf("Expected an explanation of the score, got %v", response.Hits[0])
+	}
+
+	// A score script wraps the query's explanation
+	response, err = search.Execute(index, models.SearchRequest{Query: "dog", Limit: 10, Explain: true, ScoreScript: "_score * popularity"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	scripted := response.Hits[0]["_explanation"].(*bsearch.Explanation)
+	if scripted.Value != expl.Value*3 || scripted.Message != "score script _score * popularity" || len(scripted.Children) != 1 {
+		t.Errorf("Expected the script in the explanation, got %v", scripted)
+	}
+
+	// Without the option hits have no explanation
+	response, err = search.Execute(index, models.SearchRequest{Query: "dog", Limit: 10})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if _, ok := response.Hits[0]["_explanation"]; ok {
+		t.Errorf("Expected no _explanation, got %v", response.Hits[0])
+	}
+}
@@ -0,0 +1,478 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// ErrInvalidFilter is returned for filter expressions that don't parse
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// Date layouts accepted by range comparisons of filter expressions
+var filterDateLayouts = []string{time.RFC3339, "2006-01-02"}
+
+// filterToken is a token of a filter expression. Quoted values are never
+// operators or keywords.
+type filterToken struct {
+	text   string
+	quoted bool
+}
+
+// is reports whether the token is the unquoted operator or keyword s
+func (t filterToken) is(s string) bool {
+	return !t.quoted && t.text == s
+}
+
+// IsFilterExpression reports whether a filter is written in the expression
+// language ("status = active AND price < 100") rather than query-string
+// syntax ("+status:active +price:<100"). Expressions compare with =, !=,
+// <, <=, >, >=, IN, TO, CONTAINS, EXISTS or IS NULL, and have no
+// field:value clause outside quotes.
+func IsFilterExpression(filter string) bool {
+	tokens, err := tokenizeFilter(filter)
+	if err != nil {
+		return !strings.Contains(filter, ":") && strings.ContainsAny(filter, "=<>[")
+	}
+	comparison := false
+	for _, token := range tokens {
+		if token.quoted {
+			continue
+		}
+		if strings.Contains(token.text, ":") {
+			return false
+		}
+		switch token.text {
+		case "=", "!=", "<", "<=", ">", ">=", "IN", "TO", "CONTAINS", "EXISTS", "NULL":
+			comparison = true
+		}
+	}
+	return comparison
+}
+
+// FilterQuery compiles a filter to a query. Expressions are parsed by
+// ParseFilterExpression and other filters as query strings.
+func FilterQuery(filter string) (query.Query, error) {
+	if IsFilterExpression(filter) {
+		return ParseFilterExpression(filter)
+	}
+	return bleve.NewQueryStringQuery(filter), nil
+}
+
+// ValidateFilter checks a filter's syntax when it is an expression, and
+// that every field it refers to is filterable
+func ValidateFilter(filter string, filterable []string) error {
+	if !IsFilterExpression(filter) {
+		return ValidateFilterFields(filter, filterable)
+	}
+	parsed, err := ParseFilterExpression(filter)
+	if err != nil {
+		return err
+	}
+	return validateFields(queryFields(parsed, nil), filterable)
+}
+
+// ParseFilterExpression compiles a filter expression to a query. Clauses
+// compare a field with a value (color = red, price >= 10, status != sold),
+// a list (color IN [red, blue], color NOT IN [green]) or a range (price 10
+// TO 100, inclusive), check that it has a value (email EXISTS, email NOT
+// EXISTS, email IS NULL, email IS NOT NULL) or that it holds every value of
+// a list (tags CONTAINS ALL [go, search]), and combine with NOT, AND and
+// OR, in that precedence, and parentheses. A field with several values
+// matches = and IN when any value does, and != and NOT IN when none does.
+// Values with spaces or operators are quoted. Numbers also match their
+// text, and range bounds are numbers or dates (RFC 3339 or 2006-01-02).
+func ParseFilterExpression(filter string) (query.Query, error) {
+	tokens, err := tokenizeFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	q, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidFilter, p.tokens[p.pos].text)
+	}
+	return q, nil
+}
+
+// tokenizeFilter splits a filter expression into values, quoted values,
+// operators and punctuation
+func tokenizeFilter(filter string) ([]filterToken, error) {
+	var tokens []filterToken
+	runes := []rune(filter)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			i++
+		case r == '"' || r == '\'':
+			var value strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				value.WriteRune(runes[j])
+			}
+			if j == len(runes) {
+				return nil, fmt.Errorf("%w: unterminated quote", ErrInvalidFilter)
+			}
+			tokens = append(tokens, filterToken{text: value.String(), quoted: true})
+			i = j + 1
+		case strings.ContainsRune("()[],", r):
+			tokens = append(tokens, filterToken{text: string(r)})
+			i++
+		case strings.ContainsRune("=!<>", r):
+			if i+1 < len(runes) && runes[i+1] == '=' && r != '=' {
+				tokens = append(tokens, filterToken{text: string(runes[i : i+2])})
+				i += 2
+				continue
+			}
+			if r == '!' {
+				return nil, fmt.Errorf("%w: expected !=", ErrInvalidFilter)
+			}
+			tokens = append(tokens, filterToken{text: string(r)})
+			i++
+		default:
+			j := i
+			for j < len(runes) && !strings.ContainsRune(" \t\n\r\"'()[],=!<>", runes[j]) {
+				j++
+			}
+			tokens = append(tokens, filterToken{text: string(runes[i:j])})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+// isFilterOperator reports whether an unquoted token is an operator or
+// punctuation rather than a value
+func isFilterOperator(text string) bool {
+	switch text {
+	case "(", ")", "[", "]", ",", "=", "!=", "<", "<=", ">", ">=":
+		return true
+	}
+	return false
+}
+
+// filterParser is a recursive descent parser over filter tokens
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+// peek returns the current token, if any
+func (p *filterParser) peek() (filterToken, bool) {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos], true
+	}
+	return filterToken{}, false
+}
+
+// accept consumes the current token if it is the unquoted s
+func (p *filterParser) accept(s string) bool {
+	if token, ok := p.peek(); ok && token.is(s) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// next consumes and returns the current token
+func (p *filterParser) next() (filterToken, error) {
+	token, ok := p.peek()
+	if !ok {
+		return filterToken{}, fmt.Errorf("%w: unexpected end", ErrInvalidFilter)
+	}
+	p.pos++
+	return token, nil
+}
+
+// value consumes a value, which may not be an operator
+func (p *filterParser) value() (filterToken, error) {
+	token, err := p.next()
+	if err != nil {
+		return token, err
+	}
+	if !token.quoted && isFilterOperator(token.text) {
+		return token, fmt.Errorf("%w: expected a value, got %q", ErrInvalidFilter, token.text)
+	}
+	return token, nil
+}
+
+func (p *filterParser) parseOr() (query.Query, error) {
+	q, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	disjuncts := []query.Query{q}
+	for p.accept("OR") {
+		q, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		disjuncts = append(disjuncts, q)
+	}
+	if len(disjuncts) == 1 {
+		return disjuncts[0], nil
+	}
+	return bleve.NewDisjunctionQuery(disjuncts...), nil
+}
+
+func (p *filterParser) parseAnd() (query.Query, error) {
+	q, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	conjuncts := []query.Query{q}
+	for p.accept("AND") {
+		q, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		conjuncts = append(conjuncts, q)
+	}
+	if len(conjuncts) == 1 {
+		return conjuncts[0], nil
+	}
+	return bleve.NewConjunctionQuery(conjuncts...), nil
+}
+
+func (p *filterParser) parseNot() (query.Query, error) {
+	if p.accept("NOT") {
+		q, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return negate(q), nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *filterParser) parsePrimary() (query.Query, error) {
+	if p.accept("(") {
+		q, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf("%w: missing )", ErrInvalidFilter)
+		}
+		return q, nil
+	}
+
+	field, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+
+	// Checks and list operators are keywords after the field
+	switch {
+	case p.accept("EXISTS"):
+		return exists(field.text), nil
+	case p.accept("IS"):
+		not := p.accept("NOT")
+		if !p.accept("NULL") {
+			return nil, fmt.Errorf("%w: expected NULL after IS", ErrInvalidFilter)
+		}
+		if not {
+			return exists(field.text), nil
+		}
+		return negate(exists(field.text)), nil
+	case p.accept("NOT"):
+		if p.accept("EXISTS") {
+			return negate(exists(field.text)), nil
+		}
+		if !p.accept("IN") {
+			return nil, fmt.Errorf("%w: expected IN or EXISTS after %s NOT", ErrInvalidFilter, field.text)
+		}
+		values, err := p.list(field.text)
+		if err != nil {
+			return nil, err
+		}
+		return negate(bleve.NewDisjunctionQuery(values...)), nil
+	case p.accept("CONTAINS"):
+		if !p.accept("ALL") {
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			return equals(field.text, value), nil
+		}
+		values, err := p.list(field.text)
+		if err != nil {
+			return nil, err
+		}
+		return bleve.NewConjunctionQuery(values...), nil
+	}
+
+	op, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case op.is("="), op.is("!="):
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		q := equals(field.text, value)
+		if op.is("!=") {
+			return negate(q), nil
+		}
+		return q, nil
+
+	case op.is("<"), op.is("<="), op.is(">"), op.is(">="):
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		inclusive := op.is("<=") || op.is(">=")
+		if op.is("<") || op.is("<=") {
+			return rangeQuery(field.text, nil, &value, false, inclusive)
+		}
+		return rangeQuery(field.text, &value, nil, inclusive, false)
+
+	case op.is("IN"):
+		values, err := p.list(field.text)
+		if err != nil {
+			return nil, err
+		}
+		return bleve.NewDisjunctionQuery(values...), nil
+
+	case op.quoted || !isFilterOperator(op.text):
+		// A range: field low TO high
+		if !p.accept("TO") {
+			return nil, fmt.Errorf("%w: expected an operator after %s", ErrInvalidFilter, field.text)
+		}
+		high, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		return rangeQuery(field.text, &op, &high, true, true)
+	}
+	return nil, fmt.Errorf("%w: expected an operator after %s, got %q", ErrInvalidFilter, field.text, op.text)
+}
+
+// list parses a [list] of values, each compiled to a query matching the
+// field
+func (p *filterParser) list(field string) ([]query.Query, error) {
+	if !p.accept("[") {
+		return nil, fmt.Errorf("%w: expected a [list] of values for %s", ErrInvalidFilter, field)
+	}
+	var values []query.Query
+	for !p.accept("]") {
+		if len(values) > 0 && !p.accept(",") {
+			return nil, fmt.Errorf("%w: expected , or ] in the list of %s", ErrInvalidFilter, field)
+		}
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, equals(field, value))
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("%w: empty list for %s", ErrInvalidFilter, field)
+	}
+	return values, nil
+}
+
+// exists matches documents with any text, number, date or boolean in the
+// field. Missing fields and null values have none.
+func exists(field string) query.Query {
+	// Booleans are indexed as text terms
+	text := bleve.NewRegexpQuery(".+")
+	text.SetField(field)
+
+	// Dates are indexed as numbers, so a range over every number covers both
+	lowest := -math.MaxFloat64
+	numeric := bleve.NewNumericRangeQuery(&lowest, nil)
+	numeric.SetField(field)
+
+	return bleve.NewDisjunctionQuery(text, numeric)
+}
+
+// equals matches documents whose field is the value. Numbers match both
+// numeric fields and their text, true and false boolean fields.
+func equals(field string, value filterToken) query.Query {
+	phrase := bleve.NewMatchPhraseQuery(value.text)
+	phrase.SetField(field)
+	if value.quoted {
+		return phrase
+	}
+
+	if value.text == "true" || value.text == "false" {
+		boolean := bleve.NewBoolFieldQuery(value.text == "true")
+		boolean.SetField(field)
+		return bleve.NewDisjunctionQuery(boolean, phrase)
+	}
+	if n, err := strconv.ParseFloat(value.text, 64); err == nil {
+		inclusive := true
+		numeric := bleve.NewNumericRangeInclusiveQuery(&n, &n, &inclusive, &inclusive)
+		numeric.SetField(field)
+		return bleve.NewDisjunctionQuery(numeric, phrase)
+	}
+	return phrase
+}
+
+// rangeQuery matches a field between numeric or date bounds
+func rangeQuery(field string, low, high *filterToken, lowInclusive, highInclusive bool) (query.Query, error) {
+	var numbers [2]*float64
+	var dates [2]time.Time
+	isNumeric, isDate := true, true
+	for i, bound := range []*filterToken{low, high} {
+		if bound == nil {
+			continue
+		}
+		if n, err := strconv.ParseFloat(bound.text, 64); err == nil {
+			numbers[i] = &n
+		} else {
+			isNumeric = false
+		}
+		if d, ok := parseFilterDate(bound.text); ok {
+			dates[i] = d
+		} else {
+			isDate = false
+		}
+	}
+
+	switch {
+	case isNumeric:
+		q := bleve.NewNumericRangeInclusiveQuery(numbers[0], numbers[1], &lowInclusive, &highInclusive)
+		q.SetField(field)
+		return q, nil
+	case isDate:
+		q := bleve.NewDateRangeInclusiveQuery(dates[0], dates[1], &lowInclusive, &highInclusive)
+		q.SetField(field)
+		return q, nil
+	}
+	return nil, fmt.Errorf("%w: range bounds of %s must be numbers or dates", ErrInvalidFilter, field)
+}
+
+// parseFilterDate parses a date bound of a range
+func parseFilterDate(text string) (time.Time, bool) {
+	for _, layout := range filterDateLayouts {
+		if d, err := time.Parse(layout, text); err == nil {
+			return d, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// negate matches the documents a query doesn't
+func negate(q query.Query) query.Query {
+	boolean := bleve.NewBooleanQuery()
+	boolean.AddMust(bleve.NewMatchAllQuery())
+	boolean.AddMustNot(q)
+	return boolean
+}
@@ -0,0 +1,110 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"bright/models"
+
+	"github.com/blevesearch/bleve/v2"
+	blevesearch "github.com/blevesearch/bleve/v2/search"
+)
+
+// MaxFacets bounds the facets of a single search request
+const MaxFacets = 20
+
+// DefaultFacetSize is the number of terms a facet returns when no size is given
+const DefaultFacetSize = 10
+
+// MaxFacetSize bounds the terms returned by a facet
+const MaxFacetSize = 1000
+
+// ErrInvalidFacet is returned for unusable facet options
+var ErrInvalidFacet = errors.New("invalid facet")
+
+// validateFacets checks the facets of a search request. Faceted fields must
+// be filterable when the index lists filterable attributes, and hidden
+// attributes can't be faceted since their values would show in the counts.
+func validateFacets(facets map[string]models.FacetRequest, filterable, hidden []string) error {
+	if len(facets) > MaxFacets {
+		return fmt.Errorf("%w: at most %d facets per search", ErrInvalidFacet, MaxFacets)
+	}
+	for name, facet := range facets {
+		if name == "" || facet.Field == "" {
+			return fmt.Errorf("%w: name and field are required", ErrInvalidFacet)
+		}
+		if facet.Size < 0 || facet.Size > MaxFacetSize {
+			return fmt.Errorf("%w: size of %s must be between 0 and %d", ErrInvalidFacet, name, MaxFacetSize)
+		}
+		if slices.Contains(hidden, attributeOf(facet.Field)) {
+			return fmt.Errorf("%w: %s is hidden", ErrInvalidFacet, facet.Field)
+		}
+		if len(filterable) > 0 && !strings.HasPrefix(facet.Field, "_") && !slices.Contains(filterable, attributeOf(facet.Field)) {
+			return fmt.Errorf("%w: %s (filterable attributes: %s)", ErrUnfilterableAttribute, facet.Field, strings.Join(filterable, ", "))
+		}
+		ranges := make(map[string]bool, len(facet.NumericRanges))
+		for _, r := range facet.NumericRanges {
+			if r.Name == "" || ranges[r.Name] {
+				return fmt.Errorf("%w: range names of %s must be set and unique", ErrInvalidFacet, name)
+			}
+			if r.Min == nil && r.Max == nil {
+				return fmt.Errorf("%w: range %s of %s needs min or max", ErrInvalidFacet, r.Name, name)
+			}
+			ranges[r.Name] = true
+		}
+	}
+	return nil
+}
+
+// addFacets adds the facets of a search request to a bleve request
+func addFacets(request *bleve.SearchRequest, facets map[string]models.FacetRequest) {
+	for name, facet := range facets {
+		size := facet.Size
+		if size == 0 {
+			size = DefaultFacetSize
+		}
+		facetRequest := bleve.NewFacetRequest(facet.Field, size)
+		for _, r := range facet.NumericRanges {
+			facetRequest.AddNumericRange(r.Name, r.Min, r.Max)
+		}
+		request.AddFacet(name, facetRequest)
+	}
+}
+
+// facetResults converts bleve's facet results. Ranges are listed in the
+// order they were requested.
+func facetResults(results blevesearch.FacetResults, facets map[string]models.FacetRequest) map[string]models.FacetResult {
+	if len(facets) == 0 {
+		return nil
+	}
+
+	converted := make(map[string]models.FacetResult, len(facets))
+	for name, facet := range facets {
+		result := models.FacetResult{Field: facet.Field}
+		if found, ok := results[name]; ok && found != nil {
+			result.Total = found.Total
+			result.Missing = found.Missing
+			result.Other = found.Other
+			for _, term := range found.Terms.Terms() {
+				result.Terms = append(result.Terms, models.FacetTerm{Term: term.Term, Count: term.Count})
+			}
+		}
+
+		if len(facet.NumericRanges) > 0 {
+			counts := make(map[string]int)
+			if found, ok := results[name]; ok && found != nil {
+				for _, r := range found.NumericRanges {
+					counts[r.Name] = r.Count
+				}
+			}
+			result.NumericRanges = make([]models.NumericRangeCount, 0, len(facet.NumericRanges))
+			for _, r := range facet.NumericRanges {
+				result.NumericRanges = append(result.NumericRanges, models.NumericRangeCount{NumericRange: r, Count: counts[r.Name]})
+			}
+		}
+		converted[name] = result
+	}
+	return converted
+}
@@ -0,0 +1,126 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// ErrUnfilterableAttribute is returned when a query filters on a field that
+// is not listed in the index's filterable attributes
+var ErrUnfilterableAttribute = errors.New("attribute is not filterable")
+
+// ValidateFilterFields checks that every field a query string refers to is
+// filterable. Unqualified terms and internal fields (_id, ...) are always
+// allowed. Syntax errors are left for the search itself to report.
+func ValidateFilterFields(q string, filterable []string) error {
+	if len(filterable) == 0 || strings.TrimSpace(q) == "" {
+		return nil
+	}
+
+	parsed, err := query.NewQueryStringQuery(q).Parse()
+	if err != nil {
+		return nil
+	}
+
+	return validateFields(queryFields(parsed, nil), filterable)
+}
+
+// validateFields checks that the fields a filter refers to are filterable
+func validateFields(fields, filterable []string) error {
+	if len(filterable) == 0 {
+		return nil
+	}
+	for _, field := range fields {
+		if field == "" || strings.HasPrefix(field, "_") || slices.Contains(filterable, attributeOf(field)) {
+			continue
+		}
+		if suggestion := closestAttribute(field, filterable); suggestion != "" {
+			return fmt.Errorf("%w: %s (did you mean %s?)", ErrUnfilterableAttribute, field, suggestion)
+		}
+		return fmt.Errorf("%w: %s (filterable attributes: %s)", ErrUnfilterableAttribute, field, strings.Join(filterable, ", "))
+	}
+	return nil
+}
+
+// queryFields collects the fields referenced by a parsed query
+func queryFields(q query.Query, fields []string) []string {
+	switch v := q.(type) {
+	case *query.BooleanQuery:
+		for _, clause := range []query.Query{v.Must, v.Should, v.MustNot} {
+			if clause != nil {
+				fields = queryFields(clause, fields)
+			}
+		}
+	case *query.ConjunctionQuery:
+		for _, clause := range v.Conjuncts {
+			fields = queryFields(clause, fields)
+		}
+	case *query.DisjunctionQuery:
+		for _, clause := range v.Disjuncts {
+			fields = queryFields(clause, fields)
+		}
+	case query.FieldableQuery:
+		fields = append(fields, v.Field())
+	}
+	return fields
+}
+
+// closestAttribute returns the attribute within two edits of field, if any
+func closestAttribute(field string, attributes []string) string {
+	best, bestDistance := "", 3
+	for _, attr := range attributes {
+		if d := editDistance(strings.ToLower(field), strings.ToLower(attr)); d < bestDistance {
+			best, bestDistance = attr, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		curr := make([]int, len(br)+1)
+		curr[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev = curr
+	}
+	return prev[len(br)]
+}
+
+// applyFilter restricts a query to documents matching a filter without
+// changing scores. Bleve has no non-scoring clause, but must-not clauses do
+// not score, so the filter is expressed as "not (not filter)".
+func applyFilter(q query.Query, filter string) (query.Query, error) {
+	if strings.TrimSpace(filter) == "" {
+		return q, nil
+	}
+	filterQuery, err := FilterQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := bleve.NewBooleanQuery()
+	excluded.AddMust(bleve.NewMatchAllQuery())
+	excluded.AddMustNot(filterQuery)
+
+	filtered := bleve.NewBooleanQuery()
+	filtered.AddMust(q)
+	filtered.AddMustNot(excluded)
+	return filtered, nil
+}
@@ -0,0 +1,124 @@
+package search_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"bright/models"
+	"bright/search"
+)
+
+// TestFilterExpressions tests the structured filter language
+func TestFilterExpressions(t *testing.T) {
+	config := &models.IndexConfig{ID: "books", PrimaryKey: "id", FilterableAttributes: []string{"genre", "price", "published", "inStock", "tags", "isbn"}}
+	s, index := newTestIndex(t, config, []map[string]any{
+		{"id": "1", "title": "dune", "genre": "Science Fiction", "price": 12.0, "published": "1965-08-01T00:00:00Z", "inStock": true, "tags": []any{"classic", "desert"}, "isbn": "0441013597"},
+		{"id": "2", "title": "emma", "genre": "romance", "price": 8.0, "published": "1815-12-23T00:00:00Z", "inStock": false, "tags": []any{"classic"}},
+		{"id": "3", "title": "neuromancer", "genre": "science fiction", "price": 25.0, "published": "1984-07-01T00:00:00Z", "inStock": true, "tags": []any{"cyberpunk"}, "isbn": nil},
+		{"id": "4", "title": "dracula", "genre": "horror", "price": 10.0, "published": "1897-05-26T00:00:00Z", "inStock": true},
+	})
+
+	tests := []struct {
+		filter string
+		want   []any
+	}{
+		{`genre = "science fiction"`, []any{"1", "3"}},
+		{`genre != "science fiction" AND price < 10`, []any{"2"}},
+		{`price 10 TO 12`, []any{"1", "4"}},
+		{`genre IN [horror, romance] OR price >= 25`, []any{"2", "3", "4"}},
+		{`NOT (inStock = true) OR published < 1850-01-01`, []any{"2"}},
+		{`price = 12`, []any{"1"}},
+		{`+genre:horror`, []any{"4"}},
+		{`genre NOT IN [horror, romance]`, []any{"1", "3"}},
+		{`tags = classic`, []any{"1", "2"}},
+		{`tags != classic`, []any{"3", "4"}},
+		{`tags CONTAINS ALL [classic, desert]`, []any{"1"}},
+		{`tags CONTAINS cyberpunk OR (isbn EXISTS AND price > 100)`, []any{"3"}},
+		{`isbn EXISTS`, []any{"1"}},
+		{`isbn IS NULL AND tags IS NOT NULL`, []any{"2", "3"}},
+		{`tags NOT EXISTS OR inStock = false`, []any{"2", "4"}},
+		{`price EXISTS AND published EXISTS AND inStock EXISTS`, []any{"1", "2", "3", "4"}},
+	}
+	for _, tt := range tests {
+		response, err := search.Execute(index, models.SearchRequest{Filter: tt.filter, Sort: []string{"_id"}, FilterableAttributes: config.FilterableAttributes})
+		if err != nil {
+			t.Errorf("Filter %q failed: %v", tt.filter, err)
+			continue
+		}
+		if got := hitIDs(response.Hits); !slices.Equal(got, tt.want) {
+			t.Errorf("Filter %q matched %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+
+	for _, filter := range []string{`price >`, `genre IN []`, `(genre = horror`, `price 10 TO cheap`, `isbn IS = 1`, `tags NOT = classic`, `tags CONTAINS ALL classic`} {
+		if _, err := search.Execute(index, models.SearchRequest{Filter: filter}); !errors.Is(err, search.ErrInvalidFilter) {
+			t.Errorf("Expected filter %q to be invalid, got %v", filter, err)
+		}
+	}
+	if _, err := search.Execute(index, models.SearchRequest{Filter: "title = dune", FilterableAttributes: config.FilterableAttributes}); !errors.Is(err, search.ErrUnfilterableAttribute) {
+		t.Errorf("Expected an unfilterable attribute to be rejected, got %v", err)
+	}
+
+	rangeTests := []struct {
+		ranges map[string]models.RangeFilter
+		want   []any
+	}{
+		{map[string]models.RangeFilter{"price": {Gte: 10.0, Lt: 25.0}}, []any{"1", "4"}},
+		{map[string]models.RangeFilter{"published": {Gt: "1850-01-01"}, "price": {Lte: 12}}, []any{"1", "4"}},
+		{map[string]models.RangeFilter{"published": {Lt: "1900-01-01T00:00:00Z"}}, []any{"2", "4"}},
+	}
+	for _, tt := range rangeTests {
+		response, err := search.Execute(index, models.SearchRequest{Ranges: tt.ranges, Sort: []string{"_id"}, FilterableAttributes: config.FilterableAttributes})
+		if err != nil {
+			t.Errorf("Ranges %v failed: %v", tt.ranges, err)
+			continue
+		}
+		if got := hitIDs(response.Hits); !slices.Equal(got, tt.want) {
+			t.Errorf("Ranges %v matched %v, want %v", tt.ranges, got, tt.want)
+		}
+	}
+	for _, ranges := range []map[string]models.RangeFilter{
+		{"price": {}},
+		{"price": {Gt: 1.0, Gte: 2.0}},
+		{"price": {Gt: 1.0, Lt: "2024-01-01"}},
+		{"published": {Gt: "last year"}},
+	} {
+		if _, err := search.Execute(index, models.SearchRequest{Ranges: ranges}); !errors.Is(err, search.ErrInvalidFilter) {
+			t.Errorf("Expected ranges %v to be invalid, got %v", ranges, err)
+		}
+	}
+	if _, err := search.Execute(index, models.SearchRequest{Ranges: map[string]models.RangeFilter{"title": {Gt: 1.0}}, FilterableAttributes: config.FilterableAttributes}); !errors.Is(err, search.ErrUnfilterableAttribute) {
+		t.Errorf("Expected ranges on an unfilterable attribute to be rejected, got %v", err)
+	}
+
+	ids, err := s.MatchingDocumentIDs("books", "price > 20")
+	if err != nil || !slices.Equal(ids, []string{"3"}) {
+		t.Errorf("Expected expressions to select documents to delete, got %v (%v)", ids, err)
+	}
+}
+
+// TestExcludeTerms tests that hits matching excluded words or phrases are dropped
+func TestExcludeTerms(t *testing.T) {
+	_, index := newTestIndex(t, &models.IndexConfig{ID: "jobs", PrimaryKey: "id"}, []map[string]any{
+		{"id": "1", "title": "senior go engineer", "location": "remote"},
+		{"id": "2", "title": "go engineer", "location": "new york"},
+		{"id": "3", "title": "junior go engineer", "location": "berlin"},
+	})
+
+	response, err := search.Execute(index, models.SearchRequest{Query: "engineer", ExcludeTerms: []string{"junior", "new york", " "}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if response.TotalHits != 1 || response.Hits[0]["id"] != "1" {
+		t.Errorf("Expected only document 1, got %v", response.Hits)
+	}
+
+	response, err = search.Execute(index, models.SearchRequest{ExcludeTerms: []string{"york"}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if response.TotalHits != 2 {
+		t.Errorf("Expected match-all minus one document, got %d hits", response.TotalHits)
+	}
+}
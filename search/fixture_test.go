@@ -0,0 +1,43 @@
+package search_test
+
+import (
+	"testing"
+
+	"bright/models"
+	"bright/store"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// newTestIndex opens a store in a temporary directory, creates an index from
+// config and adds docs to it. The store is closed when the test ends.
+func newTestIndex(t *testing.T, config *models.IndexConfig, docs []map[string]any) (*store.IndexStore, bleve.Index) {
+	t.Helper()
+
+	s, err := store.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	if err := s.CreateIndex(config); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	if err := s.AddDocuments(config.ID, "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+	index, _, err := s.GetIndex(config.ID)
+	if err != nil {
+		t.Fatalf("Failed to get index: %v", err)
+	}
+	return s, index
+}
+
+// hitIDs returns the id field of each hit, in order
+func hitIDs(hits []map[string]any) []any {
+	var ids []any
+	for _, hit := range hits {
+		ids = append(ids, hit["id"])
+	}
+	return ids
+}
@@ -0,0 +1,103 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"bright/models"
+
+	bsearch "github.com/blevesearch/bleve/v2/search"
+)
+
+// Fusion methods of hybrid searches
+const (
+	FusionRRF    = "rrf"
+	FusionLinear = "linear"
+)
+
+// rrfRankConstant dampens the lead of top ranks in reciprocal rank fusion
+const rrfRankConstant = 60
+
+// defaultFusionWeight is the share of the neighbours' scores in linear fusion
+const defaultFusionWeight = 0.5
+
+// validateFusion checks the fusion settings of a kNN search
+func validateFusion(v *models.VectorQuery) error {
+	switch v.Fusion {
+	case "", FusionRRF, FusionLinear:
+	default:
+		return fmt.Errorf("%w: fusion must be %s or %s", ErrInvalidVector, FusionRRF, FusionLinear)
+	}
+	if v.Weight != nil {
+		if v.Fusion != FusionLinear {
+			return fmt.Errorf("%w: weight applies to %s fusion", ErrInvalidVector, FusionLinear)
+		}
+		if w := *v.Weight; math.IsNaN(w) || w < 0 || w > 1 {
+			return fmt.Errorf("%w: weight must be between 0 and 1", ErrInvalidVector)
+		}
+	}
+	if v.Fusion != "" && v.Boost != nil {
+		return fmt.Errorf("%w: boost cannot be combined with fusion", ErrInvalidVector)
+	}
+	return nil
+}
+
+// fuse merges the hits of the query and of the kNN search, each ranked best
+// first, into one ranking scored by the fusion method. Hits found by both
+// keep the query's match, with its stored fields.
+func fuse(text, neighbours bsearch.DocumentMatchCollection, v *models.VectorQuery) bsearch.DocumentMatchCollection {
+	weight := defaultFusionWeight
+	if v.Weight != nil {
+		weight = *v.Weight
+	}
+	textScores := fusionScores(text, v.Fusion, 1-weight)
+	neighbourScores := fusionScores(neighbours, v.Fusion, weight)
+
+	fused := make(bsearch.DocumentMatchCollection, 0, len(text)+len(neighbours))
+	seen := make(map[string]bool, len(text)+len(neighbours))
+	for _, hits := range []bsearch.DocumentMatchCollection{text, neighbours} {
+		for _, hit := range hits {
+			if seen[hit.ID] {
+				continue
+			}
+			seen[hit.ID] = true
+			hit.Score = textScores[hit.ID] + neighbourScores[hit.ID]
+			explainRescore(hit, fmt.Sprintf("%s fusion of the query and kNN rankings", v.Fusion))
+			fused = append(fused, hit)
+		}
+	}
+	sort.SliceStable(fused, func(i, j int) bool {
+		if fused[i].Score != fused[j].Score {
+			return fused[i].Score > fused[j].Score
+		}
+		return fused[i].ID < fused[j].ID
+	})
+	return fused
+}
+
+// fusionScores returns the share of each hit of one ranking in the fused
+// score: 1/(60 + rank) for rrf, and for linear fusion its score scaled to
+// 0..1 within the ranking and multiplied by the ranking's weight
+func fusionScores(hits bsearch.DocumentMatchCollection, fusion string, weight float64) map[string]float64 {
+	scores := make(map[string]float64, len(hits))
+	if fusion == FusionRRF {
+		for rank, hit := range hits {
+			scores[hit.ID] = 1 / float64(rrfRankConstant+rank+1)
+		}
+		return scores
+	}
+
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, hit := range hits {
+		low, high = min(low, hit.Score), max(high, hit.Score)
+	}
+	for _, hit := range hits {
+		normalized := 1.0
+		if high > low {
+			normalized = (hit.Score - low) / (high - low)
+		}
+		scores[hit.ID] = weight * normalized
+	}
+	return scores
+}
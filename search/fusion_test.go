@@ -0,0 +1,49 @@
+package search
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"bright/models"
+
+	bsearch "github.com/blevesearch/bleve/v2/search"
+)
+
+func TestFuse(t *testing.T) {
+	ranking := func(scores ...any) bsearch.DocumentMatchCollection {
+		hits := make(bsearch.DocumentMatchCollection, 0, len(scores)/2)
+		for i := 0; i < len(scores); i += 2 {
+			hits = append(hits, &bsearch.DocumentMatch{ID: scores[i].(string), Score: scores[i+1].(float64)})
+		}
+		return hits
+	}
+	ids := func(hits bsearch.DocumentMatchCollection) []string {
+		var got []string
+		for _, hit := range hits {
+			got = append(got, hit.ID)
+		}
+		return got
+	}
+
+	// b ranks second in both, so it beats a and c, first in one ranking only
+	rrf := fuse(ranking("a", 9.0, "b", 5.0), ranking("c", 0.9, "b", 0.8, "d", 0.1), &models.VectorQuery{Fusion: FusionRRF})
+	if got, want := ids(rrf), []string{"b", "a", "c", "d"}; !slices.Equal(got, want) {
+		t.Errorf("rrf: expected %v, got %v", want, got)
+	}
+	if want := 2.0 / 62; math.Abs(rrf[0].Score-want) > 1e-9 {
+		t.Errorf("rrf: expected b to score %v, got %v", want, rrf[0].Score)
+	}
+
+	weight := 0.8
+	linear := fuse(ranking("a", 9.0, "b", 5.0, "e", 1.0), ranking("c", 0.9, "b", 0.5, "d", 0.1), &models.VectorQuery{Fusion: FusionLinear, Weight: &weight})
+	if got, want := ids(linear), []string{"c", "b", "a", "d", "e"}; !slices.Equal(got, want) {
+		t.Errorf("linear: expected %v, got %v", want, got)
+	}
+	scores := map[string]float64{"a": 0.2, "b": 0.2*0.5 + 0.8*0.5, "c": 0.8, "d": 0, "e": 0}
+	for _, hit := range linear {
+		if math.Abs(hit.Score-scores[hit.ID]) > 1e-9 {
+			t.Errorf("linear: expected %s to score %v, got %v", hit.ID, scores[hit.ID], hit.Score)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"bright/models"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/geo"
+	"github.com/blevesearch/bleve/v2/numeric"
+	bsearch "github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// GeoDistanceSort sorts hits by their distance from aroundLatLng, nearest
+// first ("-_geoDistance" for farthest first)
+const GeoDistanceSort = "_geoDistance"
+
+// ErrInvalidGeo is returned for malformed geo search parameters
+var ErrInvalidGeo = errors.New("invalid geo search")
+
+// geoSearch is the geo filter and origin of a request
+type geoSearch struct {
+	field    string
+	lat, lon float64
+	radius   string
+	box      []float64
+}
+
+// parseGeo reads the geo parameters of a request, or nil when unset. The
+// field is the request's geoField, or the index's only geo attribute.
+func parseGeo(req *models.SearchRequest) (*geoSearch, error) {
+	sortsByDistance := slices.ContainsFunc(req.Sort, func(field string) bool {
+		return strings.TrimPrefix(strings.TrimSpace(field), "-") == GeoDistanceSort
+	})
+	if req.AroundLatLng == "" && req.AroundRadius == "" && req.InsideBoundingBox == "" && !sortsByDistance {
+		return nil, nil
+	}
+
+	g := &geoSearch{field: req.GeoField}
+	if g.field == "" {
+		if len(req.GeoAttributes) != 1 {
+			return nil, fmt.Errorf("%w: geoField must name one of the index's geo attributes", ErrInvalidGeo)
+		}
+		g.field = req.GeoAttributes[0]
+	} else if !slices.Contains(req.GeoAttributes, g.field) {
+		return nil, fmt.Errorf("%w: %s is not a geo attribute", ErrInvalidGeo, g.field)
+	}
+
+	if req.AroundLatLng != "" {
+		point, err := parseCoordinates(req.AroundLatLng, 2)
+		if err != nil {
+			return nil, fmt.Errorf("%w: aroundLatLng must be \"lat,lng\"", ErrInvalidGeo)
+		}
+		g.lat, g.lon = point[0], point[1]
+	} else if req.AroundRadius != "" || sortsByDistance {
+		return nil, fmt.Errorf("%w: aroundRadius and sorting by %s need aroundLatLng", ErrInvalidGeo, GeoDistanceSort)
+	}
+
+	if req.AroundRadius != "" {
+		if radius, err := geo.ParseDistance(req.AroundRadius); err != nil || radius <= 0 {
+			return nil, fmt.Errorf("%w: aroundRadius must be a distance such as 500, 500m or 5km", ErrInvalidGeo)
+		}
+		g.radius = req.AroundRadius
+	}
+
+	if req.InsideBoundingBox != "" {
+		box, err := parseCoordinates(req.InsideBoundingBox, 4)
+		if err != nil {
+			return nil, fmt.Errorf("%w: insideBoundingBox must be \"top,left,bottom,right\"", ErrInvalidGeo)
+		}
+		g.box = box
+	}
+	return g, nil
+}
+
+// parseCoordinates parses n comma-separated latitudes and longitudes, in
+// that order
+func parseCoordinates(value string, n int) ([]float64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("expected %d coordinates", n)
+	}
+	coordinates := make([]float64, n)
+	for i, part := range parts {
+		c, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, err
+		}
+		limit := 90.0
+		if i%2 == 1 {
+			limit = 180
+		}
+		if math.IsNaN(c) || math.Abs(c) > limit {
+			return nil, fmt.Errorf("coordinate %v out of range", c)
+		}
+		coordinates[i] = c
+	}
+	return coordinates, nil
+}
+
+// apply restricts a query to hits within the radius and bounding box,
+// without affecting their scores
+func (g *geoSearch) apply(q query.Query) query.Query {
+	var filters []query.Query
+	if g.radius != "" {
+		distance := bleve.NewGeoDistanceQuery(g.lon, g.lat, g.radius)
+		distance.SetField(g.field)
+		filters = append(filters, distance)
+	}
+	if g.box != nil {
+		box := bleve.NewGeoBoundingBoxQuery(g.box[1], g.box[0], g.box[3], g.box[2])
+		box.SetField(g.field)
+		filters = append(filters, box)
+	}
+	if len(filters) == 0 {
+		return q
+	}
+
+	// Hits must be within both the radius and the box
+	excluded := bleve.NewBooleanQuery()
+	excluded.AddMust(bleve.NewMatchAllQuery())
+	excluded.AddMustNot(bleve.NewConjunctionQuery(filters...))
+
+	filtered := bleve.NewBooleanQuery()
+	filtered.AddMust(q)
+	filtered.AddMustNot(excluded)
+	return filtered
+}
+
+// sort returns the bleve sort of a "_geoDistance" sort field, in meters
+func (g *geoSearch) sort(sortField string) (bsearch.SearchSort, error) {
+	return bsearch.NewSortGeoDistance(g.field, "m", g.lon, g.lat, strings.HasPrefix(sortField, "-"))
+}
+
+// distance decodes the distance in meters of a hit's geo distance sort value
+func distance(value string) (float64, bool) {
+	i64, err := numeric.PrefixCoded(value).Int64()
+	if err != nil {
+		return 0, false
+	}
+	// Hits without a point sort last, at the largest distance
+	if i64 == math.MaxInt64 {
+		return 0, false
+	}
+	return numeric.Int64ToFloat64(i64), true
+}
@@ -0,0 +1,70 @@
+package search_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"bright/models"
+	"bright/search"
+)
+
+// TestGeoSearch tests radius and bounding box filters and distance sorting
+func TestGeoSearch(t *testing.T) {
+	config := &models.IndexConfig{ID: "places", PrimaryKey: "id", GeoAttributes: []string{"location"}}
+	_, index := newTestIndex(t, config, []map[string]any{
+		{"id": "louvre", "name": "museum", "location": map[string]any{"lat": 48.8606, "lon": 2.3376}},
+		{"id": "orsay", "name": "museum", "location": "48.8600,2.3266"},
+		{"id": "prado", "name": "museum", "location": []any{-3.6921, 40.4138}},
+		{"id": "nowhere", "name": "museum"},
+	})
+
+	ids := func(req models.SearchRequest) []any {
+		t.Helper()
+		req.GeoAttributes = config.GeoAttributes
+		response, err := search.Execute(index, req)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		return hitIDs(response.Hits)
+	}
+
+	if got := ids(models.SearchRequest{Query: "museum", AroundLatLng: "48.8584,2.2945", AroundRadius: "5km", Sort: []string{"_geoDistance"}}); !slices.Equal(got, []any{"orsay", "louvre"}) {
+		t.Errorf("Expected the Paris museums nearest first, got %v", got)
+	}
+	got := ids(models.SearchRequest{AroundLatLng: "48.8584,2.2945", Sort: []string{"-_geoDistance"}, Limit: 3})
+	if len(got) != 3 || got[0] != "nowhere" || got[1] != "prado" {
+		t.Errorf("Expected documents without a point, then the farthest first, got %v", got)
+	}
+	if got := ids(models.SearchRequest{InsideBoundingBox: "41,-4,40,-3"}); !slices.Equal(got, []any{"prado"}) {
+		t.Errorf("Expected the Madrid museum inside the box, got %v", got)
+	}
+
+	response, err := search.Execute(index, models.SearchRequest{
+		AroundLatLng:  "48.8606,2.3376",
+		Sort:          []string{"_geoDistance"},
+		ShowMeta:      true,
+		Limit:         1,
+		GeoAttributes: config.GeoAttributes,
+	})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if meta := response.Hits[0]["_meta"].(map[string]any); meta["_geoDistance"] != 0.0 {
+		t.Errorf("Expected a distance of 0 in _meta, got %v", meta)
+	}
+
+	invalid := []models.SearchRequest{
+		{AroundRadius: "5km"},
+		{AroundLatLng: "91,0"},
+		{AroundLatLng: "48,2", AroundRadius: "far"},
+		{InsideBoundingBox: "1,2,3"},
+		{AroundLatLng: "48,2", GeoField: "name"},
+	}
+	for _, req := range invalid {
+		req.GeoAttributes = config.GeoAttributes
+		if _, err := search.Execute(index, req); !errors.Is(err, search.ErrInvalidGeo) {
+			t.Errorf("Expected ErrInvalidGeo for %+v, got %v", req, err)
+		}
+	}
+}
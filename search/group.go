@@ -0,0 +1,94 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"bright/models"
+
+	bsearch "github.com/blevesearch/bleve/v2/search"
+)
+
+// ErrInvalidGroup is returned for unusable groupBy options
+var ErrInvalidGroup = errors.New("invalid group")
+
+// Grouping bounds
+const (
+	// DefaultGroupSize is the number of hits per group when groupSize isn't
+	// given
+	DefaultGroupSize = 1
+
+	// MaxGroupSize bounds the hits of a group
+	MaxGroupSize = 100
+
+	// groupWindow is the number of top hits bucketed into groups
+	groupWindow = 1000
+)
+
+// matchGroup is the top hits sharing a value of the groupBy field
+type matchGroup struct {
+	value any
+	hits  bsearch.DocumentMatchCollection
+}
+
+// validateGroup checks the groupBy options of a search request. As with
+// facets, the field must be filterable when the index lists filterable
+// attributes, and hidden attributes can't be grouped by since their values
+// would show in the groups.
+func validateGroup(req models.SearchRequest, filterable, hidden []string) error {
+	if req.GroupBy == "" {
+		if req.GroupSize != 0 {
+			return fmt.Errorf("%w: groupSize needs groupBy", ErrInvalidGroup)
+		}
+		return nil
+	}
+	if req.GroupSize < 0 || req.GroupSize > MaxGroupSize {
+		return fmt.Errorf("%w: groupSize must be between 1 and %d", ErrInvalidGroup, MaxGroupSize)
+	}
+	if slices.Contains(hidden, attributeOf(req.GroupBy)) {
+		return fmt.Errorf("%w: %s is hidden", ErrInvalidGroup, req.GroupBy)
+	}
+	if len(filterable) > 0 && !slices.Contains(filterable, attributeOf(req.GroupBy)) {
+		return fmt.Errorf("%w: %s (filterable attributes: %s)", ErrUnfilterableAttribute, req.GroupBy, strings.Join(filterable, ", "))
+	}
+	return nil
+}
+
+// groupValue returns the value a hit is grouped by: the field's value, its
+// first one for lists, or nil when the hit doesn't have the field
+func groupValue(hit *bsearch.DocumentMatch, field string) any {
+	value := hit.Fields[field]
+	if list, ok := value.([]any); ok {
+		if len(list) == 0 {
+			return nil
+		}
+		value = list[0]
+	}
+	switch value.(type) {
+	case nil, string, float64, bool:
+		return value
+	}
+	return fmt.Sprint(value)
+}
+
+// groupMatches buckets ranked hits by the value of field, keeping the first
+// size hits of each group. Groups are ranked by their first hit.
+func groupMatches(matches bsearch.DocumentMatchCollection, field string, size int) []matchGroup {
+	var groups []matchGroup
+	positions := make(map[any]int)
+	for _, hit := range matches {
+		value := groupValue(hit, field)
+		i, ok := positions[value]
+		if !ok {
+			i = len(groups)
+			positions[value] = i
+			groups = append(groups, matchGroup{value: value})
+		}
+		if len(groups[i].hits) < size {
+			groups[i].hits = append(groups[i].hits, hit)
+		}
+	}
+	return groups
+}
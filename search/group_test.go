@@ -0,0 +1,73 @@
+package search_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"bright/models"
+	"bright/search"
+)
+
+// TestGroupBy tests bucketing hits by a field, the paging of groups and
+// rejection of unusable group options
+func TestGroupBy(t *testing.T) {
+	config := &models.IndexConfig{ID: "books", PrimaryKey: "id", SortableAttributes: []string{"rank"}, HiddenAttributes: []string{"cost"}}
+	_, index := newTestIndex(t, config, []map[string]any{
+		{"id": "1", "category": "novel", "rank": 1.0},
+		{"id": "2", "category": "poetry", "rank": 2.0},
+		{"id": "3", "category": "novel", "rank": 3.0},
+		{"id": "4", "category": "novel", "rank": 4.0},
+		{"id": "5", "rank": 5.0},
+		{"id": "6", "category": "poetry", "rank": 6.0},
+		{"id": "7", "category": "essay", "rank": 7.0},
+	})
+
+	// Groups rank by their best hit, documents without the field share one
+	response, err := search.Execute(index, models.SearchRequest{Limit: 10, Sort: []string{"rank"}, GroupBy: "category", GroupSize: 2})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	want := []struct {
+		value any
+		ids   []any
+	}{
+		{"novel", []any{"1", "3"}},
+		{"poetry", []any{"2", "6"}},
+		{nil, []any{"5"}},
+		{"essay", []any{"7"}},
+	}
+	if len(response.Groups) != len(want) || response.TotalGroups != 4 || response.TotalHits != 7 || len(response.Hits) != 0 {
+		t.Fatalf("Expected 4 groups of 7 hits, got %+v", response)
+	}
+	for i, group := range response.Groups {
+		if group.Value != want[i].value || !slices.Equal(hitIDs(group.Hits), want[i].ids) {
+			t.Errorf("Group %d: expected %v %v, got %v %v", i, want[i].value, want[i].ids, group.Value, hitIDs(group.Hits))
+		}
+	}
+
+	// Limit and page count groups, and the field is read without being
+	// retrieved
+	response, err = search.Execute(index, models.SearchRequest{Limit: 2, Page: 2, Sort: []string{"rank"}, GroupBy: "category", AttributesToRetrieve: []string{"rank"}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(response.Groups) != 2 || response.Groups[0].Value != nil || response.Groups[1].Value != "essay" || response.TotalPages != 2 {
+		t.Fatalf("Expected the second page of groups, got %+v", response)
+	}
+	if _, ok := response.Groups[1].Hits[0]["category"]; ok {
+		t.Errorf("Expected the group field to be left out of hits, got %v", response.Groups[1].Hits[0])
+	}
+
+	invalid := []models.SearchRequest{
+		{GroupSize: 2},
+		{GroupBy: "category", GroupSize: search.MaxGroupSize + 1},
+		{GroupBy: "cost", HiddenAttributes: config.HiddenAttributes},
+		{GroupBy: "category", Cursor: search.CursorStart},
+	}
+	for _, req := range invalid {
+		if _, err := search.Execute(index, req); !errors.Is(err, search.ErrInvalidGroup) && !errors.Is(err, search.ErrInvalidCursor) {
+			t.Errorf("Expected %+v to be rejected, got %v", req, err)
+		}
+	}
+}
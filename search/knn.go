@@ -0,0 +1,23 @@
+//go:build vectors
+
+package search
+
+import (
+	"bright/models"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// addKNN adds the kNN search of a request. With exclusive set, hits must be
+// both neighbours and matches of the query.
+func addKNN(request *bleve.SearchRequest, v *models.VectorQuery, k int, exclusive bool) error {
+	boost := 1.0
+	if v.Boost != nil {
+		boost = *v.Boost
+	}
+	request.AddKNN(v.Field, v.Values, int64(k), boost)
+	if exclusive {
+		request.AddKNNOperator("and")
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !vectors
+
+package search
+
+import (
+	"bright/models"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// addKNN fails without vector support
+func addKNN(request *bleve.SearchRequest, v *models.VectorQuery, k int, exclusive bool) error {
+	return ErrVectorsUnsupported
+}
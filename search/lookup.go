@@ -0,0 +1,142 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"bright/models"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// MaxLookups bounds the lookups of a single search request
+const MaxLookups = 5
+
+// ErrInvalidLookup is returned for unusable lookup options
+var ErrInvalidLookup = errors.New("invalid lookup")
+
+// IndexResolver returns an index and its configuration by ID
+type IndexResolver func(indexID string) (bleve.Index, *models.IndexConfig, error)
+
+// ValidateLookups checks the lookups of a search request
+func ValidateLookups(lookups []models.Lookup) error {
+	if len(lookups) > MaxLookups {
+		return fmt.Errorf("%w: at most %d lookups per search", ErrInvalidLookup, MaxLookups)
+	}
+	targets := make(map[string]bool, len(lookups))
+	for _, lookup := range lookups {
+		if lookup.Index == "" || lookup.Key == "" {
+			return fmt.Errorf("%w: index and key are required", ErrInvalidLookup)
+		}
+		as := lookupTarget(lookup)
+		if strings.HasPrefix(as, "_") || targets[as] {
+			return fmt.Errorf("%w: invalid or repeated target %q", ErrInvalidLookup, as)
+		}
+		targets[as] = true
+	}
+	return nil
+}
+
+// lookupTarget is the hit attribute a lookup writes to
+func lookupTarget(lookup models.Lookup) string {
+	if lookup.As != "" {
+		return lookup.As
+	}
+	return lookup.Index
+}
+
+// ApplyLookups adds to each hit the documents of other indexes whose IDs
+// are the values of the lookup's key field. Every lookup fetches its
+// documents in one batch. A single key gives an object (null when missing),
+// a list of keys a list of the documents found. Hidden attributes of the
+// looked-up index are removed unless showHidden is set.
+func ApplyLookups(hits []map[string]any, lookups []models.Lookup, resolve IndexResolver, showHidden bool) error {
+	if err := ValidateLookups(lookups); err != nil {
+		return err
+	}
+
+	for _, lookup := range lookups {
+		index, config, err := resolve(lookup.Index)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidLookup, err)
+		}
+
+		ids := []string{}
+		seen := make(map[string]bool)
+		for _, hit := range hits {
+			for _, id := range lookupKeys(hit[lookup.Key]) {
+				if !seen[id] {
+					seen[id] = true
+					ids = append(ids, id)
+				}
+			}
+		}
+
+		found := make(map[string]map[string]any, len(ids))
+		if len(ids) > 0 {
+			request := bleve.NewSearchRequestOptions(bleve.NewDocIDQuery(ids), len(ids), 0, false)
+			request.Fields = lookup.Fields
+			if len(request.Fields) == 0 {
+				request.Fields = []string{"*"}
+			}
+			result, err := index.Search(request)
+			if err != nil {
+				return fmt.Errorf("lookup in %s failed: %w", lookup.Index, err)
+			}
+			for _, match := range result.Hits {
+				doc := make(map[string]any, len(match.Fields))
+				for field, value := range match.Fields {
+					doc[field] = value
+				}
+				if !showHidden {
+					HideAttributes(doc, config.HiddenAttributes)
+				}
+				found[match.ID] = doc
+			}
+		}
+
+		as := lookupTarget(lookup)
+		for _, hit := range hits {
+			value, ok := hit[lookup.Key]
+			if !ok || value == nil {
+				continue
+			}
+			if _, isList := value.([]any); isList {
+				docs := []map[string]any{}
+				for _, id := range lookupKeys(value) {
+					if doc, ok := found[id]; ok {
+						docs = append(docs, doc)
+					}
+				}
+				hit[as] = docs
+				continue
+			}
+			keys := lookupKeys(value)
+			if len(keys) == 1 && found[keys[0]] != nil {
+				hit[as] = found[keys[0]]
+			} else {
+				hit[as] = nil
+			}
+		}
+	}
+	return nil
+}
+
+// lookupKeys returns the document IDs a key field refers to
+func lookupKeys(value any) []string {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []any:
+		keys := make([]string, 0, len(v))
+		for _, item := range v {
+			if item != nil {
+				keys = append(keys, fmt.Sprintf("%v", item))
+			}
+		}
+		return keys
+	default:
+		return []string{fmt.Sprintf("%v", v)}
+	}
+}
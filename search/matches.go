@@ -0,0 +1,64 @@
+package search
+
+import (
+	"sort"
+	"strings"
+
+	"bright/models"
+
+	bsearch "github.com/blevesearch/bleve/v2/search"
+)
+
+// matchPositions lists where the terms of a query matched a hit, by field,
+// in the order they appear. Matches of search-as-you-type prefixes are
+// reported on their fields, and fields keep rejects are left out.
+func matchPositions(locations bsearch.FieldTermLocationMap, keep func(field string) bool) map[string][]models.MatchPosition {
+	matches := make(map[string][]models.MatchPosition)
+	for field, terms := range locations {
+		// Pairs of words repeat the matches of their words
+		if strings.HasPrefix(field, AsYouTypeShingleField) {
+			continue
+		}
+		field = strings.TrimPrefix(field, AsYouTypePrefixField)
+		if !keep(field) {
+			continue
+		}
+		for term, locations := range terms {
+			for _, location := range locations {
+				matches[field] = append(matches[field], models.MatchPosition{
+					Term:           term,
+					Start:          location.Start,
+					End:            location.End,
+					Position:       location.Pos,
+					ArrayPositions: location.ArrayPositions,
+				})
+			}
+		}
+	}
+	for _, positions := range matches {
+		sort.Slice(positions, func(i, j int) bool {
+			a, b := positions[i], positions[j]
+			if c := compareArrayPositions(a.ArrayPositions, b.ArrayPositions); c != 0 {
+				return c < 0
+			}
+			if a.Start != b.Start {
+				return a.Start < b.Start
+			}
+			return a.Term < b.Term
+		})
+	}
+	return matches
+}
+
+// compareArrayPositions orders the values of lists
+func compareArrayPositions(a, b []uint64) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(a) - len(b)
+}
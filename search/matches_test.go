@@ -0,0 +1,45 @@
+package search_test
+
+import (
+	"reflect"
+	"testing"
+
+	"bright/models"
+	"bright/search"
+)
+
+// TestShowMatches tests the matched terms and positions returned with hits
+func TestShowMatches(t *testing.T) {
+	config := &models.IndexConfig{ID: "pets", PrimaryKey: "id", HiddenAttributes: []string{"notes"}}
+	_, index := newTestIndex(t, config, []map[string]any{
+		{"id": "1", "title": "Lazy dogs and a dog", "tags": []any{"cat", "dog"}, "notes": "dog"},
+	})
+
+	response, err := search.Execute(index, models.SearchRequest{Query: "dog", Limit: 10, ShowMatches: true, HiddenAttributes: config.HiddenAttributes})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(response.Hits) != 1 {
+		t.Fatalf("Expected 1 hit, got %+v", response)
+	}
+	matches, ok := response.Hits[0]["_matches"].(map[string][]models.MatchPosition)
+	if !ok {
+		t.Fatalf("Expected _matches, got %v", response.Hits[0])
+	}
+	want := map[string][]models.MatchPosition{
+		"title": {{Term: "dog", Start: 16, End: 19, Position: 5}},
+		"tags":  {{Term: "dog", Start: 0, End: 3, Position: 1, ArrayPositions: []uint64{1}}},
+	}
+	if !reflect.DeepEqual(matches, want) {
+		t.Errorf("Expected %v, got %v", want, matches)
+	}
+
+	// Without the option hits have no matches
+	response, err = search.Execute(index, models.SearchRequest{Query: "dog", Limit: 10})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if _, ok := response.Hits[0]["_matches"]; ok {
+		t.Errorf("Expected no _matches, got %v", response.Hits[0])
+	}
+}
@@ -0,0 +1,110 @@
+package search
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"bright/models"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// Target is one of the indexes searched together by ExecuteAcross
+type Target struct {
+	ID     string
+	Index  bleve.Index
+	Config *models.IndexConfig
+}
+
+// ExecuteAcross runs a search request against several indexes at once and
+// merges their hits in the requested order. Bleve searches the indexes
+// concurrently. The query, filter, sort and facets must be allowed by the
+// settings of every index, the strictest maxLimit and maxTotalHits apply,
+// and the hidden attributes of each index are removed from its hits unless
+// showHidden is set. Search defaults of the indexes are not applied.
+func ExecuteAcross(targets []Target, req models.SearchRequest, showHidden bool) (*models.SearchResponse, error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no index to search")
+	}
+
+	if err := ApplyMatchingStrategy(&req, commonAsYouTypeFields(targets)); err != nil {
+		return nil, err
+	}
+
+	indexes := make([]bleve.Index, 0, len(targets))
+	sources := make(map[string]hitSource, len(targets))
+	// Geo searches use the geo attributes every index has, and kNN searches
+	req.GeoAttributes = slices.Clone(targets[0].Config.GeoAttributes)
+	// and the vector fields every index has with the same settings
+	req.Vectors = maps.Clone(targets[0].Config.Vectors)
+	for _, target := range targets {
+		config := target.Config
+		req.GeoAttributes = slices.DeleteFunc(req.GeoAttributes, func(attr string) bool {
+			return !slices.Contains(config.GeoAttributes, attr)
+		})
+		maps.DeleteFunc(req.Vectors, func(field string, settings models.VectorSettings) bool {
+			return config.Vectors[field] != settings
+		})
+		if len(req.AsYouTypeFields) == 0 {
+			if err := ValidateFilterFields(req.Query, config.FilterableAttributes); err != nil {
+				return nil, fmt.Errorf("%w in %s", err, target.ID)
+			}
+		}
+		if err := ValidateFilter(req.Filter, config.FilterableAttributes); err != nil {
+			return nil, fmt.Errorf("%w in %s", err, target.ID)
+		}
+		if err := ValidateRanges(req.Ranges, config.FilterableAttributes); err != nil {
+			return nil, fmt.Errorf("%w in %s", err, target.ID)
+		}
+
+		hidden := config.HiddenAttributes
+		if showHidden {
+			hidden = nil
+		}
+		if err := validateFacets(req.Facets, config.FilterableAttributes, hidden); err != nil {
+			return nil, fmt.Errorf("%w in %s", err, target.ID)
+		}
+		if err := validateGroup(req, config.FilterableAttributes, hidden); err != nil {
+			return nil, fmt.Errorf("%w in %s", err, target.ID)
+		}
+
+		if config.MaxLimit > 0 && (req.MaxLimit == 0 || config.MaxLimit < req.MaxLimit) {
+			req.MaxLimit = config.MaxLimit
+		}
+		if config.MaxTotalHits > 0 && (req.MaxTotalHits == 0 || config.MaxTotalHits < req.MaxTotalHits) {
+			req.MaxTotalHits = config.MaxTotalHits
+		}
+
+		indexes = append(indexes, target.Index)
+		sources[target.Index.Name()] = hitSource{id: target.ID, hidden: hidden}
+	}
+
+	// Each index has been checked against its own settings
+	req.FilterableAttributes = nil
+	req.HiddenAttributes = nil
+
+	// A sort field is only switched to its keyword sort field when every
+	// index has one, since the merged hits are ordered by a single field
+	resolveSort := func(sortField string) (string, error) {
+		resolved := ""
+		for i, target := range targets {
+			field, err := resolveSortField(target.Index, target.Config.SortableAttributes, sortField)
+			if err != nil {
+				return "", fmt.Errorf("%w in %s", err, target.ID)
+			}
+			if i == 0 {
+				resolved = field
+			} else if field != resolved {
+				resolved = sortField
+			}
+		}
+		return resolved, nil
+	}
+
+	var index bleve.Index = targets[0].Index
+	if len(targets) > 1 {
+		index = bleve.NewIndexAlias(indexes...)
+	}
+	return execute(index, req, resolveSort, sources)
+}
@@ -0,0 +1,80 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"bright/models"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// MaxStoredQueryIDLength is the longest accepted stored query ID
+const MaxStoredQueryIDLength = 255
+
+// ErrInvalidStoredQuery is returned for stored queries that cannot be saved
+var ErrInvalidStoredQuery = errors.New("invalid stored query")
+
+// ValidateStoredQuery checks a stored query against its index configuration
+func ValidateStoredQuery(q *models.StoredQuery, config *models.IndexConfig) error {
+	if q.ID == "" || len(q.ID) > MaxStoredQueryIDLength || strings.ContainsAny(q.ID, "/\x00") {
+		return fmt.Errorf("%w: id must be 1 to %d characters without slashes", ErrInvalidStoredQuery, MaxStoredQueryIDLength)
+	}
+	if strings.TrimSpace(q.Query) == "" && strings.TrimSpace(q.Filter) == "" {
+		return fmt.Errorf("%w: q or filter is required", ErrInvalidStoredQuery)
+	}
+
+	for _, text := range []string{q.Query, q.Filter} {
+		if strings.TrimSpace(text) == "" || (text == q.Filter && IsFilterExpression(text)) {
+			continue
+		}
+		if _, err := bleve.NewQueryStringQuery(text).Parse(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidStoredQuery, err)
+		}
+	}
+	if err := ValidateFilterFields(q.Query, config.FilterableAttributes); err != nil {
+		return err
+	}
+	if err := ValidateFilter(q.Filter, config.FilterableAttributes); err != nil {
+		if errors.Is(err, ErrInvalidFilter) {
+			return fmt.Errorf("%w: %v", ErrInvalidStoredQuery, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// MatchStoredQueries runs stored queries against an index holding count
+// documents with IDs "0" to count-1, and returns the IDs of the queries
+// matching each document
+func MatchStoredQueries(index bleve.Index, queries []*models.StoredQuery, count int) ([][]string, error) {
+	matches := make([][]string, count)
+	for i := range matches {
+		matches[i] = []string{}
+	}
+	if count == 0 {
+		return matches, nil
+	}
+
+	for _, stored := range queries {
+		q, err := buildQuery(stored.Query, nil, nil, stored.ExcludeTerms, stored.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("stored query %s failed: %w", stored.ID, err)
+		}
+		request := bleve.NewSearchRequestOptions(q, count, 0, false)
+		result, err := index.Search(request)
+		if err != nil {
+			return nil, fmt.Errorf("stored query %s failed: %w", stored.ID, err)
+		}
+		for _, hit := range result.Hits {
+			position, err := strconv.Atoi(hit.ID)
+			if err != nil || position < 0 || position >= count {
+				continue
+			}
+			matches[position] = append(matches[position], stored.ID)
+		}
+	}
+	return matches, nil
+}
@@ -0,0 +1,152 @@
+package search
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"bright/models"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// rangeBound is one side of a range filter
+type rangeBound struct {
+	value     any
+	inclusive bool
+}
+
+// rangeBounds returns the lower and upper bounds of a range filter, or nil
+// for open sides
+func rangeBounds(field string, r models.RangeFilter) (low, high *rangeBound, err error) {
+	if r.Gt != nil && r.Gte != nil {
+		return nil, nil, fmt.Errorf("%w: range of %s has both gt and gte", ErrInvalidFilter, field)
+	}
+	if r.Lt != nil && r.Lte != nil {
+		return nil, nil, fmt.Errorf("%w: range of %s has both lt and lte", ErrInvalidFilter, field)
+	}
+	switch {
+	case r.Gt != nil:
+		low = &rangeBound{value: r.Gt}
+	case r.Gte != nil:
+		low = &rangeBound{value: r.Gte, inclusive: true}
+	}
+	switch {
+	case r.Lt != nil:
+		high = &rangeBound{value: r.Lt}
+	case r.Lte != nil:
+		high = &rangeBound{value: r.Lte, inclusive: true}
+	}
+	if low == nil && high == nil {
+		return nil, nil, fmt.Errorf("%w: range of %s needs gt, gte, lt or lte", ErrInvalidFilter, field)
+	}
+	return low, high, nil
+}
+
+// rangeFilterQuery compiles the range filter of a field to a numeric range
+// query when its bounds are numbers, and a date range query when they are
+// dates (RFC 3339 or 2006-01-02)
+func rangeFilterQuery(field string, r models.RangeFilter) (query.Query, error) {
+	low, high, err := rangeBounds(field, r)
+	if err != nil {
+		return nil, err
+	}
+
+	var numbers [2]*float64
+	var dates [2]time.Time
+	isNumeric, isDate := true, true
+	for i, bound := range []*rangeBound{low, high} {
+		if bound == nil {
+			continue
+		}
+		switch v := bound.value.(type) {
+		case float64:
+			numbers[i] = &v
+			isDate = false
+		case int:
+			n := float64(v)
+			numbers[i] = &n
+			isDate = false
+		case int64:
+			n := float64(v)
+			numbers[i] = &n
+			isDate = false
+		case string:
+			d, ok := parseFilterDate(v)
+			if !ok {
+				return nil, fmt.Errorf("%w: range bound %q of %s is not a date (RFC 3339 or 2006-01-02)", ErrInvalidFilter, v, field)
+			}
+			dates[i] = d
+			isNumeric = false
+		default:
+			return nil, fmt.Errorf("%w: range bounds of %s must be numbers or dates", ErrInvalidFilter, field)
+		}
+	}
+
+	lowInclusive := low != nil && low.inclusive
+	highInclusive := high != nil && high.inclusive
+	switch {
+	case isNumeric:
+		q := bleve.NewNumericRangeInclusiveQuery(numbers[0], numbers[1], &lowInclusive, &highInclusive)
+		q.SetField(field)
+		return q, nil
+	case isDate:
+		q := bleve.NewDateRangeInclusiveQuery(dates[0], dates[1], &lowInclusive, &highInclusive)
+		q.SetField(field)
+		return q, nil
+	}
+	return nil, fmt.Errorf("%w: range bounds of %s mix numbers and dates", ErrInvalidFilter, field)
+}
+
+// ValidateRanges checks the range filters of a request and that their
+// fields are filterable
+func ValidateRanges(ranges map[string]models.RangeFilter, filterable []string) error {
+	for _, field := range sortedFields(ranges) {
+		if strings.TrimSpace(field) == "" {
+			return fmt.Errorf("%w: range filters need a field", ErrInvalidFilter)
+		}
+		if _, err := rangeFilterQuery(field, ranges[field]); err != nil {
+			return err
+		}
+	}
+	return validateFields(sortedFields(ranges), filterable)
+}
+
+// applyRanges restricts a query to documents within every range filter,
+// without affecting their scores
+func applyRanges(q query.Query, ranges map[string]models.RangeFilter) (query.Query, error) {
+	if len(ranges) == 0 {
+		return q, nil
+	}
+
+	conjuncts := make([]query.Query, 0, len(ranges))
+	for _, field := range sortedFields(ranges) {
+		bounded, err := rangeFilterQuery(field, ranges[field])
+		if err != nil {
+			return nil, err
+		}
+		conjuncts = append(conjuncts, bounded)
+	}
+
+	excluded := bleve.NewBooleanQuery()
+	excluded.AddMust(bleve.NewMatchAllQuery())
+	excluded.AddMustNot(bleve.NewConjunctionQuery(conjuncts...))
+
+	filtered := bleve.NewBooleanQuery()
+	filtered.AddMust(q)
+	filtered.AddMustNot(excluded)
+	return filtered, nil
+}
+
+// sortedFields returns the fields of range filters in order, so queries
+// and errors are the same every time
+func sortedFields(ranges map[string]models.RangeFilter) []string {
+	fields := make([]string, 0, len(ranges))
+	for field := range ranges {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
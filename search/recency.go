@@ -0,0 +1,94 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	bsearch "github.com/blevesearch/bleve/v2/search"
+)
+
+// recencyWindow is the number of top text matches re-ranked by a recency
+// boost or score script
+const recencyWindow = 1000
+
+// ErrInvalidRecency is returned for malformed recency boost parameters
+var ErrInvalidRecency = errors.New("invalid recency boost")
+
+// recencyBoost multiplies text scores by 0.5^(age / halfLife)
+type recencyBoost struct {
+	field    string
+	halfLife time.Duration
+	now      time.Time
+}
+
+// parseRecency reads the recency boost of a request, or nil when unset
+func parseRecency(field, halfLife string) (*recencyBoost, error) {
+	if field == "" && halfLife == "" {
+		return nil, nil
+	}
+	if field == "" || halfLife == "" {
+		return nil, fmt.Errorf("%w: recencyField and recencyHalfLife must be set together", ErrInvalidRecency)
+	}
+
+	duration, err := parseHalfLife(halfLife)
+	if err != nil || duration <= 0 {
+		return nil, fmt.Errorf("%w: recencyHalfLife must be a positive duration such as 12h or 7d", ErrInvalidRecency)
+	}
+
+	return &recencyBoost{field: field, halfLife: duration, now: time.Now()}, nil
+}
+
+// parseHalfLife parses a Go duration, also accepting whole days ("7d")
+func parseHalfLife(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// apply rescales the scores of hits and re-sorts them. Documents without a
+// readable date in the field sink below every dated document.
+func (r *recencyBoost) apply(hits bsearch.DocumentMatchCollection) {
+	for _, hit := range hits {
+		at, ok := documentTime(hit.Fields[r.field])
+		if !ok {
+			hit.Score = 0
+			explainRescore(hit, fmt.Sprintf("recency boost, no date in %s", r.field))
+			continue
+		}
+		age := max(r.now.Sub(at), 0)
+		hit.Score *= math.Pow(0.5, float64(age)/float64(r.halfLife))
+		explainRescore(hit, fmt.Sprintf("recency boost, %s old with a half-life of %s", age.Round(time.Second), r.halfLife))
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Score > hits[j].Score
+	})
+}
+
+// documentTime reads a stored date: an RFC 3339 string or a Unix timestamp in
+// seconds (or milliseconds for values past the year 33658)
+func documentTime(value any) (time.Time, bool) {
+	switch v := value.(type) {
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, true
+			}
+		}
+	case float64:
+		if v > 1e12 {
+			return time.UnixMilli(int64(v)), true
+		}
+		return time.Unix(int64(v), 0), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,51 @@
+package search_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"bright/models"
+	"bright/search"
+)
+
+// TestRecencyBoost tests that fresh documents outrank stale ones with equal text scores
+func TestRecencyBoost(t *testing.T) {
+	now := time.Now().UTC()
+	_, index := newTestIndex(t, &models.IndexConfig{ID: "news", PrimaryKey: "id"}, []map[string]any{
+		{"id": "old", "title": "election results", "published": now.Add(-30 * 24 * time.Hour).Format(time.RFC3339)},
+		{"id": "new", "title": "election results", "published": now.Add(-time.Hour).Format(time.RFC3339)},
+		{"id": "undated", "title": "election results"},
+	})
+
+	response, err := search.Execute(index, models.SearchRequest{
+		Query:                "election",
+		RecencyField:         "published",
+		RecencyHalfLife:      "7d",
+		AttributesToRetrieve: []string{"title"},
+	})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	for _, hit := range response.Hits {
+		if _, ok := hit["published"]; ok {
+			t.Errorf("Expected the date field to stay unretrieved, got %v", hit)
+		}
+	}
+	if got := hitIDs(response.Hits); !slices.Equal(got, []any{"new", "old", "undated"}) {
+		t.Errorf("Expected fresh documents first, got %v", got)
+	}
+
+	response, err = search.Execute(index, models.SearchRequest{Query: "election", RecencyField: "published", RecencyHalfLife: "7d", Offset: 1, Limit: 1})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(response.Hits) != 1 || response.Hits[0]["id"] != "old" {
+		t.Errorf("Expected the second page to hold the older document, got %v", response.Hits)
+	}
+
+	if _, err := search.Execute(index, models.SearchRequest{RecencyField: "published"}); !errors.Is(err, search.ErrInvalidRecency) {
+		t.Errorf("Expected ErrInvalidRecency without a half-life, got %v", err)
+	}
+}
@@ -0,0 +1,307 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	bsearch "github.com/blevesearch/bleve/v2/search"
+)
+
+// ErrInvalidScript is returned for score scripts that don't parse
+var ErrInvalidScript = errors.New("invalid score script")
+
+// MaxScriptLength bounds the length of a score script
+const MaxScriptLength = 256
+
+// scriptFunctions are the functions score scripts may call, by number of
+// arguments
+var scriptFunctions = map[string]struct {
+	args int
+	fn   func(args []float64) float64
+}{
+	"abs":   {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"sqrt":  {1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"log":   {1, func(a []float64) float64 { return math.Log(a[0]) }},
+	"log10": {1, func(a []float64) float64 { return math.Log10(a[0]) }},
+	"log1p": {1, func(a []float64) float64 { return math.Log1p(a[0]) }},
+	"exp":   {1, func(a []float64) float64 { return math.Exp(a[0]) }},
+	"pow":   {2, func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+	"min":   {2, func(a []float64) float64 { return math.Min(a[0], a[1]) }},
+	"max":   {2, func(a []float64) float64 { return math.Max(a[0], a[1]) }},
+}
+
+// scriptExpr evaluates part of a score script for a hit
+type scriptExpr func(score float64, fields map[string]any) float64
+
+// ScoreScript is a compiled score script
+type ScoreScript struct {
+	source string
+	expr   scriptExpr
+	fields []string
+}
+
+// ParseScoreScript compiles a score script: an arithmetic expression over
+// _score and numeric document fields (_score * log1p(popularity)) with +,
+// -, *, /, ^, parentheses and the functions abs, sqrt, log, log10, log1p,
+// exp, pow, min and max. Scripts can't loop or reach anything but the hit.
+func ParseScoreScript(script string) (*ScoreScript, error) {
+	if len(script) > MaxScriptLength {
+		return nil, fmt.Errorf("%w: longer than %d characters", ErrInvalidScript, MaxScriptLength)
+	}
+	tokens, err := tokenizeScript(script)
+	if err != nil {
+		return nil, err
+	}
+	p := &scriptParser{tokens: tokens}
+	expr, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidScript, p.tokens[p.pos])
+	}
+	sort.Strings(p.fields)
+	return &ScoreScript{source: script, expr: expr, fields: slices.Compact(p.fields)}, nil
+}
+
+// Fields returns the document fields a script reads
+func (s *ScoreScript) Fields() []string {
+	return s.fields
+}
+
+// apply rescores hits with the script and re-sorts them. Scores that aren't
+// finite numbers, such as the log of 0, become 0.
+func (s *ScoreScript) apply(hits bsearch.DocumentMatchCollection) {
+	for _, hit := range hits {
+		score := s.expr(hit.Score, hit.Fields)
+		if math.IsNaN(score) || math.IsInf(score, 0) {
+			score = 0
+		}
+		hit.Score = score
+		explainRescore(hit, "score script "+s.source)
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Score > hits[j].Score
+	})
+}
+
+// scriptValue reads a field of a hit as a number: numbers and numeric text
+// as they are, booleans as 0 or 1, lists by their first value, and missing
+// or other values as 0
+func scriptValue(value any) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case string:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return f
+		}
+	case bool:
+		if v {
+			return 1
+		}
+	case []any:
+		if len(v) > 0 {
+			return scriptValue(v[0])
+		}
+	}
+	return 0
+}
+
+// tokenizeScript splits a score script into numbers, names and operators
+func tokenizeScript(script string) ([]string, error) {
+	var tokens []string
+	runes := []rune(script)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case strings.ContainsRune("+-*/^(),", r):
+			tokens = append(tokens, string(r))
+			i++
+		case unicode.IsDigit(r) || r == '.' || unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || unicode.IsLetter(runes[j]) || runes[j] == '_' || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		default:
+			return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidScript, r)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%w: empty script", ErrInvalidScript)
+	}
+	return tokens, nil
+}
+
+// scriptParser is a recursive descent parser over score script tokens
+type scriptParser struct {
+	tokens []string
+	pos    int
+	fields []string
+}
+
+// accept consumes the current token if it is s
+func (p *scriptParser) accept(s string) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos] == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// parseSum parses terms joined by + and -
+func (p *scriptParser) parseSum() (scriptExpr, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.accept("+"):
+			right, err := p.parseProduct()
+			if err != nil {
+				return nil, err
+			}
+			l := left
+			left = func(s float64, f map[string]any) float64 { return l(s, f) + right(s, f) }
+		case p.accept("-"):
+			right, err := p.parseProduct()
+			if err != nil {
+				return nil, err
+			}
+			l := left
+			left = func(s float64, f map[string]any) float64 { return l(s, f) - right(s, f) }
+		default:
+			return left, nil
+		}
+	}
+}
+
+// parseProduct parses factors joined by * and /
+func (p *scriptParser) parseProduct() (scriptExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.accept("*"):
+			right, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			l := left
+			left = func(s float64, f map[string]any) float64 { return l(s, f) * right(s, f) }
+		case p.accept("/"):
+			right, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			l := left
+			left = func(s float64, f map[string]any) float64 { return l(s, f) / right(s, f) }
+		default:
+			return left, nil
+		}
+	}
+}
+
+// parseUnary parses a negation or a power
+func (p *scriptParser) parseUnary() (scriptExpr, error) {
+	if p.accept("-") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(s float64, f map[string]any) float64 { return -operand(s, f) }, nil
+	}
+	base, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept("^") {
+		return base, nil
+	}
+	// ^ is right-associative and binds tighter than a leading minus
+	exponent, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return func(s float64, f map[string]any) float64 { return math.Pow(base(s, f), exponent(s, f)) }, nil
+}
+
+// parsePrimary parses a number, _score, a field, a function call or a
+// parenthesized expression
+func (p *scriptParser) parsePrimary() (scriptExpr, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected end", ErrInvalidScript)
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+
+	if token == "(" {
+		inner, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf("%w: expected )", ErrInvalidScript)
+		}
+		return inner, nil
+	}
+
+	first := []rune(token)[0]
+	if unicode.IsDigit(first) || first == '.' {
+		n, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid number %q", ErrInvalidScript, token)
+		}
+		return func(float64, map[string]any) float64 { return n }, nil
+	}
+	if !unicode.IsLetter(first) && first != '_' {
+		return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidScript, token)
+	}
+
+	if p.accept("(") {
+		function, ok := scriptFunctions[token]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown function %s", ErrInvalidScript, token)
+		}
+		var args []scriptExpr
+		for len(args) == 0 || p.accept(",") {
+			arg, err := p.parseSum()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf("%w: expected ) after the arguments of %s", ErrInvalidScript, token)
+		}
+		if len(args) != function.args {
+			return nil, fmt.Errorf("%w: %s takes %d arguments", ErrInvalidScript, token, function.args)
+		}
+		return func(s float64, f map[string]any) float64 {
+			values := make([]float64, len(args))
+			for i, arg := range args {
+				values[i] = arg(s, f)
+			}
+			return function.fn(values)
+		}, nil
+	}
+
+	if token == "_score" {
+		return func(s float64, _ map[string]any) float64 { return s }, nil
+	}
+	p.fields = append(p.fields, token)
+	return func(_ float64, f map[string]any) float64 { return scriptValue(f[token]) }, nil
+}
@@ -0,0 +1,63 @@
+package search_test
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"bright/models"
+	"bright/search"
+	"bright/store"
+)
+
+// TestScoreScript tests re-scoring hits from document fields, from the
+// index defaults and from the request
+func TestScoreScript(t *testing.T) {
+	config := &models.IndexConfig{
+		ID:             "products",
+		PrimaryKey:     "id",
+		SearchDefaults: &models.SearchDefaults{ScoreScript: "_score * log1p(popularity)"},
+	}
+	_, index := newTestIndex(t, config, []map[string]any{
+		{"id": "niche", "title": "phone case", "popularity": 1},
+		{"id": "hit", "title": "phone case", "popularity": 1000},
+		{"id": "unrated", "title": "phone case"},
+	})
+
+	ids := func(req models.SearchRequest) []any {
+		t.Helper()
+		search.ApplyDefaults(&req, config.SearchDefaults)
+		response, err := search.Execute(index, req)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		for _, hit := range response.Hits {
+			if _, ok := hit["popularity"]; ok && len(req.AttributesToRetrieve) > 0 {
+				t.Errorf("Expected popularity to stay unretrieved, got %v", hit)
+			}
+		}
+		return hitIDs(response.Hits)
+	}
+
+	if got := ids(models.SearchRequest{Query: "phone", AttributesToRetrieve: []string{"title"}}); !slices.Equal(got, []any{"hit", "niche", "unrated"}) {
+		t.Errorf("Expected popular documents first, got %v", got)
+	}
+	if got := ids(models.SearchRequest{Query: "phone", ScoreScript: "-popularity", Offset: 1, Limit: 1}); !slices.Equal(got, []any{"niche"}) {
+		t.Errorf("Expected the request's script to page after re-scoring, got %v", got)
+	}
+
+	invalid := []string{"", "_score *", "log(1, 2)", "cbrt(_score)", "_score; 1", "(_score", strings.Repeat("1+", 200) + "1"}
+	for _, script := range invalid {
+		if _, err := search.ParseScoreScript(script); !errors.Is(err, search.ErrInvalidScript) {
+			t.Errorf("Expected ErrInvalidScript for %q, got %v", script, err)
+		}
+	}
+	if _, err := search.Execute(index, models.SearchRequest{ScoreScript: "_score", Sort: []string{"title"}}); !errors.Is(err, search.ErrInvalidScript) {
+		t.Errorf("Expected ErrInvalidScript with sort, got %v", err)
+	}
+	bad := &models.IndexConfig{ID: "bad", SearchDefaults: &models.SearchDefaults{ScoreScript: "popularity +"}}
+	if err := store.ValidateIndexSettings(bad); err == nil {
+		t.Error("Expected an error for an invalid default score script")
+	}
+}
@@ -0,0 +1,679 @@
+package search
+
+import (
+	"bright/models"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+	bsearch "github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// DefaultLimit is the number of hits returned when no limit is given
+const DefaultLimit = 20
+
+// DefaultIDAttribute is the attribute the document ID is injected under
+const DefaultIDAttribute = "id"
+
+// SortFieldPrefix prefixes the keyword fields indexed for sortable attributes
+const SortFieldPrefix = "_sort."
+
+// KeywordSuffix ends the name of the exact, case-sensitive subfield of a
+// field with keyword settings ("brand.keyword")
+const KeywordSuffix = ".keyword"
+
+// attributeOf returns the attribute a field belongs to: a keyword subfield
+// is filtered, sorted and hidden along with its field
+func attributeOf(field string) string {
+	return strings.TrimSuffix(field, KeywordSuffix)
+}
+
+// ErrUnsortableAttribute is returned when sorting on a field that is not
+// listed in the index's sortable attributes
+var ErrUnsortableAttribute = errors.New("attribute is not sortable")
+
+// ErrLimitExceeded is returned when a request's limit is above the index's maxLimit
+var ErrLimitExceeded = errors.New("limit exceeds the index's maxLimit")
+
+// ErrMaxTotalHitsExceeded is returned when a request pages beyond the
+// index's maxTotalHits
+var ErrMaxTotalHitsExceeded = errors.New("offset exceeds the index's maxTotalHits")
+
+// ErrConflictingAttributes is returned when both attributesToRetrieve and
+// attributesToExclude are set
+var ErrConflictingAttributes = errors.New("cannot use both attributesToRetrieve and attributesToExclude at the same time")
+
+// Normalize applies defaults to a search request. The default limit, the
+// request's DefaultLimit when set, never exceeds the request's MaxLimit.
+func Normalize(req *models.SearchRequest) {
+	if req.Limit <= 0 {
+		req.Limit = DefaultLimit
+		if req.DefaultLimit > 0 {
+			req.Limit = req.DefaultLimit
+		}
+		if req.MaxLimit > 0 && req.Limit > req.MaxLimit {
+			req.Limit = req.MaxLimit
+		}
+	}
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+}
+
+// HideAttributes removes hidden attributes from a document
+func HideAttributes(doc map[string]any, hidden []string) {
+	for _, attr := range hidden {
+		delete(doc, attr)
+	}
+}
+
+// resolveSortField checks a sort field against the sortable attributes and
+// switches to its keyword sort field when the index has one
+func resolveSortField(index bleve.Index, sortable []string, sortField string) (string, error) {
+	desc := strings.HasPrefix(sortField, "-")
+	field := strings.TrimPrefix(sortField, "-")
+	if field == "_score" || field == "_id" {
+		return sortField, nil
+	}
+
+	if len(sortable) > 0 && !slices.Contains(sortable, attributeOf(field)) {
+		return "", fmt.Errorf("%w: %s (sortable attributes: %s)", ErrUnsortableAttribute, field, strings.Join(sortable, ", "))
+	}
+
+	if hasSortField(index, field) {
+		field = SortFieldPrefix + field
+	}
+	if desc {
+		return "-" + field, nil
+	}
+	return field, nil
+}
+
+// hasSortField reports whether the index mapping has a keyword sort field
+// for a field
+func hasSortField(index bleve.Index, field string) bool {
+	impl, ok := index.Mapping().(*mapping.IndexMappingImpl)
+	if !ok || impl.DefaultMapping == nil {
+		return false
+	}
+
+	current := impl.DefaultMapping
+	for _, segment := range strings.Split(field, ".") {
+		next, ok := current.Properties[segment]
+		if !ok {
+			return false
+		}
+		current = next
+	}
+	for _, fieldMapping := range current.Fields {
+		if fieldMapping.Name == SortFieldPrefix+field {
+			return true
+		}
+	}
+	return false
+}
+
+// Execute runs a search request against an index. It is shared by the HTTP
+// handlers and the embeddable engine so both return identical results.
+func Execute(index bleve.Index, req models.SearchRequest) (*models.SearchResponse, error) {
+	resolveSort := func(sortField string) (string, error) {
+		return resolveSortField(index, req.SortableAttributes, sortField)
+	}
+	return execute(index, req, resolveSort, nil)
+}
+
+// hitSource is the index a hit of a search over several indexes came from
+type hitSource struct {
+	id     string
+	hidden []string
+}
+
+// execute runs a search request against an index or an alias over several.
+// sources maps the bleve names of aliased indexes to the IDs and hidden
+// attributes of their hits; hits of other indexes use the request's.
+func execute(index bleve.Index, req models.SearchRequest, resolveSort func(string) (string, error), sources map[string]hitSource) (*models.SearchResponse, error) {
+	Normalize(&req)
+
+	// Validate that both attributesToRetrieve and attributesToExclude are not provided
+	if len(req.AttributesToRetrieve) > 0 && len(req.AttributesToExclude) > 0 {
+		return nil, ErrConflictingAttributes
+	}
+
+	if len(req.AsYouTypeFields) == 0 {
+		if err := ValidateFilterFields(req.Query, req.FilterableAttributes); err != nil {
+			return nil, err
+		}
+	}
+	if err := ValidateFilter(req.Filter, req.FilterableAttributes); err != nil {
+		return nil, err
+	}
+	if err := ValidateRanges(req.Ranges, req.FilterableAttributes); err != nil {
+		return nil, err
+	}
+	if err := validateFacets(req.Facets, req.FilterableAttributes, req.HiddenAttributes); err != nil {
+		return nil, err
+	}
+	if err := ValidateSortOptions(req.SortOptions); err != nil {
+		return nil, err
+	}
+	if err := validateGroup(req, req.FilterableAttributes, req.HiddenAttributes); err != nil {
+		return nil, err
+	}
+	if req.Vector != nil {
+		if err := validateVector(req.Vector, req.Vectors); err != nil {
+			return nil, err
+		}
+	}
+	if req.TimeoutMs < 0 {
+		return nil, fmt.Errorf("%w: timeoutMs must not be negative", ErrInvalidTimeout)
+	}
+
+	// The timeout runs from here, and cuts matching short with the hits
+	// found so far
+	var deadline *searchDeadline
+	if req.TimeoutMs > 0 {
+		deadline = newSearchDeadline(time.Duration(req.TimeoutMs) * time.Millisecond)
+		defer deadline.stop()
+	}
+
+	// Calculate offset from page if page is provided
+	offset := req.Offset
+	if req.Page > 1 {
+		offset = (req.Page - 1) * req.Limit
+	}
+
+	// Pages of a cursor search continue after the previous page's last hit,
+	// as deep as the hits served so far
+	var cursor *searchCursor
+	if req.Cursor != "" {
+		var err error
+		if cursor, err = parseCursor(req); err != nil {
+			return nil, err
+		}
+		offset = cursor.Served
+	}
+
+	if req.MaxLimit > 0 && req.Limit > req.MaxLimit {
+		return nil, fmt.Errorf("%w: %d > %d", ErrLimitExceeded, req.Limit, req.MaxLimit)
+	}
+	size := req.Limit
+	if req.MaxTotalHits > 0 {
+		if offset >= req.MaxTotalHits {
+			return nil, fmt.Errorf("%w: %d >= %d", ErrMaxTotalHitsExceeded, offset, req.MaxTotalHits)
+		}
+		// The last reachable page is cut short
+		size = min(size, req.MaxTotalHits-offset)
+	}
+
+	recency, err := parseRecency(req.RecencyField, req.RecencyHalfLife)
+	if err != nil {
+		return nil, err
+	}
+	if recency != nil && len(req.Sort) > 0 {
+		return nil, fmt.Errorf("%w: cannot be combined with sort", ErrInvalidRecency)
+	}
+
+	var script *ScoreScript
+	if req.ScoreScript != "" {
+		if script, err = ParseScoreScript(req.ScoreScript); err != nil {
+			return nil, err
+		}
+		if len(req.Sort) > 0 {
+			return nil, fmt.Errorf("%w: cannot be combined with sort", ErrInvalidScript)
+		}
+	}
+
+	// Hybrid searches with a fusion method rank the query's hits and the
+	// neighbours apart, then fuse the two rankings
+	fused := req.Vector != nil && req.Vector.Fusion != "" && req.Query != ""
+	if fused && (len(req.Sort) > 0 || recency != nil || script != nil) {
+		return nil, fmt.Errorf("%w: fusion cannot be combined with sort, a recency boost or a score script", ErrInvalidVector)
+	}
+	if cursor != nil {
+		if recency != nil || script != nil || req.Vector != nil {
+			return nil, fmt.Errorf("%w: cursors cannot be combined with a recency boost, a score script or a vector search", ErrInvalidCursor)
+		}
+		// Pinned documents keep their rank, as pages don't know which
+		// pins came before them
+		req.PinnedDocuments = nil
+	}
+
+	// Grouped searches bucket the top hits by the groupBy field, then page
+	// the groups. The field is read even when it isn't retrieved.
+	groupOffset, groupLimit, groupSize := 0, 0, 0
+	var dropFields []string
+	if req.GroupBy != "" {
+		if cursor != nil {
+			return nil, fmt.Errorf("%w: cursors cannot be combined with groupBy", ErrInvalidCursor)
+		}
+		groupOffset, groupLimit = offset, size
+		groupSize = req.GroupSize
+		if groupSize == 0 {
+			groupSize = DefaultGroupSize
+		}
+		offset, size = 0, groupWindow
+		if req.MaxTotalHits > 0 {
+			size = min(size, req.MaxTotalHits)
+		}
+		if len(req.AttributesToRetrieve) > 0 && !slices.Contains(req.AttributesToRetrieve, req.GroupBy) {
+			req.AttributesToRetrieve = append(slices.Clone(req.AttributesToRetrieve), req.GroupBy)
+			dropFields = append(dropFields, req.GroupBy)
+		}
+	}
+
+	geoParams, err := parseGeo(&req)
+	if err != nil {
+		return nil, err
+	}
+
+	var searchQuery query.Query
+	if len(req.AsYouTypeFields) > 0 {
+		searchQuery, err = applyFilter(excludeTerms(asYouTypeQuery(req.Query, req.AsYouTypeFields), req.ExcludeTerms), req.Filter)
+	} else {
+		searchQuery, err = buildQuery(req.Query, req.Synonyms, req.SearchableAttributes, req.ExcludeTerms, req.Filter)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if searchQuery, err = applyRanges(searchQuery, req.Ranges); err != nil {
+		return nil, err
+	}
+	if geoParams != nil {
+		searchQuery = geoParams.apply(searchQuery)
+	}
+	excluded := append(slices.Clone(req.HiddenDocuments), req.PinnedDocuments...)
+	searchQuery = excludeDocuments(searchQuery, excluded)
+
+	// Pinned documents passing the filters lead the results, so the other
+	// hits of a page shift by the number of pins on or before it
+	var pinned bsearch.DocumentMatchCollection
+	pinnedCount := 0
+	if len(req.PinnedDocuments) > 0 {
+		if pinned, err = searchPinned(index, req, geoParams); err != nil {
+			return nil, err
+		}
+		pinnedCount = len(pinned)
+		pinned = pinned[min(offset, pinnedCount):min(offset+size, pinnedCount)]
+		size -= len(pinned)
+		offset = max(offset-pinnedCount, 0)
+	}
+
+	if deadline != nil {
+		searchQuery = deadline.wrap(searchQuery)
+	}
+	searchRequest := bleve.NewSearchRequest(searchQuery)
+	searchRequest.From = offset
+	searchRequest.Size = size
+	searchRequest.IncludeLocations = req.ShowMatches
+	searchRequest.Explain = req.Explain
+	if cursor != nil {
+		searchRequest.From = 0
+	}
+	addFacets(searchRequest, req.Facets)
+
+	k := 0
+	if req.Vector != nil {
+		k = req.Vector.K
+		if k == 0 {
+			k = max(offset+size, 1)
+		}
+	}
+	// Without a query, or with filters to respect, neighbours must also match
+	// the filtered query; otherwise they are blended with its hits
+	if req.Vector != nil && !fused {
+		exclusive := req.Query == "" || req.Filter != "" || len(req.Ranges) > 0 || len(req.ExcludeTerms) > 0 || geoParams != nil || len(excluded) > 0
+		if err := addKNN(searchRequest, req.Vector, k, exclusive); err != nil {
+			return nil, err
+		}
+	}
+
+	// Optimize field retrieval: only request fields we need
+	if len(req.AttributesToRetrieve) > 0 {
+		searchRequest.Fields = req.AttributesToRetrieve
+	} else {
+		// Request all fields (attributesToExclude is applied in post-processing)
+		searchRequest.Fields = []string{"*"}
+	}
+
+	// A recency boost or score script re-ranks the top text matches, so
+	// fetch them all along with the fields they read and page afterwards
+	if recency != nil || script != nil {
+		searchRequest.From = 0
+		searchRequest.Size = max(offset+size, recencyWindow)
+
+		var read []string
+		if recency != nil {
+			read = append(read, recency.field)
+		}
+		if script != nil {
+			read = append(read, script.Fields()...)
+		}
+		if len(req.AttributesToRetrieve) > 0 {
+			searchRequest.Fields = slices.Clone(req.AttributesToRetrieve)
+			for _, field := range read {
+				if !slices.Contains(searchRequest.Fields, field) {
+					searchRequest.Fields = append(searchRequest.Fields, field)
+					dropFields = append(dropFields, field)
+				}
+			}
+		}
+	}
+
+	// Apply sorting if provided
+	sortOrder := make(bsearch.SortOrder, 0, len(req.Sort))
+	geoSortIndex := -1
+	for _, sortField := range req.Sort {
+		// A leading "-" means descending order, which bleve understands natively
+		if sortField = strings.TrimSpace(sortField); sortField == "" {
+			continue
+		}
+		if strings.TrimPrefix(sortField, "-") == GeoDistanceSort {
+			geoSort, err := geoParams.sort(sortField)
+			if err != nil {
+				return nil, err
+			}
+			geoSortIndex = len(sortOrder)
+			sortOrder = append(sortOrder, geoSort)
+			continue
+		}
+		field, err := resolveSort(sortField)
+		if err != nil {
+			return nil, err
+		}
+		sortOrder = append(sortOrder, sortBy(field, req.SortOptions[strings.TrimPrefix(sortField, "-")]))
+	}
+	if len(sortOrder) == 0 && cursor != nil {
+		sortOrder = bsearch.SortOrder{&bsearch.SortScore{Desc: true}}
+	}
+	if len(sortOrder) > 0 {
+		// Hits with equal sort values, such as those missing the field, keep
+		// the same order from page to page
+		if _, ok := sortOrder[len(sortOrder)-1].(*bsearch.SortDocID); !ok {
+			sortOrder = append(sortOrder, &bsearch.SortDocID{})
+		}
+		searchRequest.SortByCustom(sortOrder)
+		if cursor != nil && len(cursor.After) > 0 {
+			if len(cursor.After) != len(sortOrder) {
+				return nil, fmt.Errorf("%w: it belongs to another search", ErrInvalidCursor)
+			}
+			searchRequest.SearchAfter = cursor.After
+		}
+	} else {
+		// Default sorting by score (relevance)
+		searchRequest.SortBy([]string{"-_score"})
+	}
+
+	// Fused rankings are paged once both are fetched
+	if fused {
+		searchRequest.From = 0
+		searchRequest.Size = offset + size
+	}
+
+	// Execute search
+	searchResult, err := index.Search(searchRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := searchResult.Hits
+	if recency != nil || script != nil {
+		if recency != nil {
+			recency.apply(matches)
+		}
+		if script != nil {
+			script.apply(matches)
+		}
+		matches = matches[min(offset, len(matches)):min(offset+size, len(matches))]
+	}
+	if fused {
+		filtered, err := filterQuery(req, geoParams)
+		if err != nil {
+			return nil, err
+		}
+		neighbourRequest := bleve.NewSearchRequestOptions(excludeDocuments(filtered, excluded), k, 0, false)
+		neighbourRequest.Fields = searchRequest.Fields
+		neighbourRequest.Explain = req.Explain
+		if err := addKNN(neighbourRequest, req.Vector, k, true); err != nil {
+			return nil, err
+		}
+		neighbours, err := searchNeighbours(index, neighbourRequest, deadline)
+		if err != nil {
+			return nil, err
+		}
+
+		// Neighbours beyond the query's hits add to the total
+		matches = fuse(matches, neighbours, req.Vector)
+		searchResult.Total += uint64(len(matches) - len(searchResult.Hits))
+		matches = matches[min(offset, len(matches)):min(offset+size, len(matches))]
+	}
+	if len(pinned) > 0 {
+		matches = append(pinned, matches...)
+	}
+	searchResult.Total += uint64(pinnedCount)
+
+	var groups []matchGroup
+	totalGroups := 0
+	if req.GroupBy != "" {
+		groups = groupMatches(matches, req.GroupBy, groupSize)
+		totalGroups = len(groups)
+		groups = groups[min(groupOffset, totalGroups):min(groupOffset+groupLimit, totalGroups)]
+		matches = nil
+		for _, group := range groups {
+			matches = append(matches, group.hits...)
+		}
+	}
+
+	// Process results
+	hits := make([]map[string]any, 0, len(matches))
+	for _, hit := range matches {
+		doc := make(map[string]any, len(hit.Fields)+2)
+
+		indexID, hidden := req.IndexID, req.HiddenAttributes
+		if source, ok := sources[hit.Index]; ok {
+			indexID, hidden = source.id, source.hidden
+		}
+
+		// Add all fields from the hit
+		for fieldName, fieldValue := range hit.Fields {
+			doc[fieldName] = fieldValue
+		}
+		for _, field := range dropFields {
+			delete(doc, field)
+		}
+
+		// Add the document ID unless the document has its own value there
+		if req.InjectID == nil || *req.InjectID {
+			idAttribute := req.IDAttribute
+			if idAttribute == "" {
+				idAttribute = DefaultIDAttribute
+			}
+			if _, ok := doc[idAttribute]; !ok {
+				doc[idAttribute] = hit.ID
+			}
+		}
+
+		// Apply attributesToExclude if specified
+		for _, attr := range req.AttributesToExclude {
+			delete(doc, attr)
+		}
+		HideAttributes(doc, hidden)
+
+		if req.ShowMatches {
+			// Only the fields returned, so hidden attributes don't show
+			doc["_matches"] = matchPositions(hit.Locations, func(field string) bool {
+				_, returned := doc[field]
+				return returned
+			})
+		}
+
+		if req.Explain {
+			doc["_explanation"] = hit.Expl
+		}
+
+		if req.ShowMeta {
+			meta := map[string]any{
+				"_id":    hit.ID,
+				"_score": hit.Score,
+			}
+			if indexID != "" {
+				meta["_index"] = indexID
+			}
+			if slices.Contains(req.PinnedDocuments, hit.ID) {
+				meta["_pinned"] = true
+			}
+			if geoSortIndex >= 0 && geoSortIndex < len(hit.Sort) {
+				if meters, ok := distance(hit.Sort[geoSortIndex]); ok {
+					meta["_geoDistance"] = math.Round(meters)
+				}
+			}
+			doc["_meta"] = meta
+		}
+
+		hits = append(hits, doc)
+	}
+
+	// Results past maxTotalHits cannot be reached, so they are not counted
+	total := searchResult.Total
+	if req.MaxTotalHits > 0 && total > uint64(req.MaxTotalHits) {
+		total = uint64(req.MaxTotalHits)
+	}
+
+	response := &models.SearchResponse{
+		Hits:       hits,
+		TotalHits:  total,
+		TotalPages: int(math.Ceil(float64(total) / float64(req.Limit))),
+		Facets:     facetResults(searchResult.Facets, req.Facets),
+	}
+	if req.GroupBy != "" {
+		// Groups hold the hits, in the order of the groups
+		response.Groups = make([]models.SearchGroup, 0, len(groups))
+		for _, group := range groups {
+			response.Groups = append(response.Groups, models.SearchGroup{Value: group.value, Hits: hits[:len(group.hits)]})
+			hits = hits[len(group.hits):]
+		}
+		response.Hits = []map[string]any{}
+		response.TotalGroups = totalGroups
+		response.TotalPages = int(math.Ceil(float64(totalGroups) / float64(req.Limit)))
+	}
+	if deadline != nil && deadline.timedOut() {
+		response.TimedOut = true
+	}
+	// A page cut short by the timeout may have missed hits ranked before its
+	// last one, which the next page would skip
+	if cursor != nil && !response.TimedOut && len(matches) > 0 && len(matches) == size && uint64(offset+size) < total {
+		response.NextCursor = cursor.next(req, searchRequest.Sort, matches[len(matches)-1], len(matches))
+	}
+	return response, nil
+}
+
+// searchNeighbours runs the kNN search of a fused hybrid search. kNN
+// searches can't return partial results, so one running past the deadline
+// finds no neighbours.
+func searchNeighbours(index bleve.Index, request *bleve.SearchRequest, deadline *searchDeadline) (bsearch.DocumentMatchCollection, error) {
+	if deadline == nil {
+		result, err := index.Search(request)
+		if err != nil {
+			return nil, err
+		}
+		return result.Hits, nil
+	}
+
+	ctx, cancel := deadline.context()
+	defer cancel()
+	result, err := index.SearchInContext(ctx, request)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			deadline.cut.Store(true)
+			return nil, nil
+		}
+		return nil, err
+	}
+	return result.Hits, nil
+}
+
+// buildQuery combines a query string (matching everything when empty),
+// expanded with synonyms and boosted by searchable attribute, with excluded
+// terms and a filter
+func buildQuery(q string, synonyms map[string][]string, searchable map[string]float64, exclude []string, filter string) (query.Query, error) {
+	var searchQuery query.Query
+	if q == "" {
+		searchQuery = bleve.NewMatchAllQuery()
+	} else {
+		searchQuery = bleve.NewQueryStringQuery(q)
+		if len(synonyms) > 0 || len(searchable) > 0 {
+			// Unparsable queries are left for bleve to report
+			if parsed, err := bleve.NewQueryStringQuery(q).Parse(); err == nil {
+				searchQuery = boostFields(expandSynonyms(parsed, synonyms), searchable)
+			}
+		}
+	}
+	searchQuery = excludeTerms(searchQuery, exclude)
+	return applyFilter(searchQuery, filter)
+}
+
+// searchPinned returns the pinned documents of a request that pass its
+// filters, in the order they are pinned
+func searchPinned(index bleve.Index, req models.SearchRequest, geoParams *geoSearch) (bsearch.DocumentMatchCollection, error) {
+	filtered, err := filterQuery(req, geoParams)
+	if err != nil {
+		return nil, err
+	}
+	pinnedQuery := bleve.NewConjunctionQuery(bleve.NewDocIDQuery(req.PinnedDocuments), filtered)
+	pinnedRequest := bleve.NewSearchRequestOptions(pinnedQuery, len(req.PinnedDocuments), 0, false)
+	pinnedRequest.Fields = []string{"*"}
+	if len(req.AttributesToRetrieve) > 0 {
+		pinnedRequest.Fields = req.AttributesToRetrieve
+	}
+
+	result, err := index.Search(pinnedRequest)
+	if err != nil {
+		return nil, err
+	}
+	hits := result.Hits
+	sort.SliceStable(hits, func(i, j int) bool {
+		return slices.Index(req.PinnedDocuments, hits[i].ID) < slices.Index(req.PinnedDocuments, hits[j].ID)
+	})
+	return hits, nil
+}
+
+// filterQuery matches the documents passing the filter, ranges, excluded
+// terms and geo filter of a request
+func filterQuery(req models.SearchRequest, geoParams *geoSearch) (query.Query, error) {
+	filtered, err := buildQuery("", nil, nil, req.ExcludeTerms, req.Filter)
+	if err != nil {
+		return nil, err
+	}
+	if filtered, err = applyRanges(filtered, req.Ranges); err != nil {
+		return nil, err
+	}
+	if geoParams != nil {
+		filtered = geoParams.apply(filtered)
+	}
+	return filtered, nil
+}
+
+// excludeTerms wraps a query so documents matching any of the terms are
+// dropped. Each term is matched as a phrase against all fields.
+func excludeTerms(q query.Query, terms []string) query.Query {
+	mustNot := make([]query.Query, 0, len(terms))
+	for _, term := range terms {
+		if term = strings.TrimSpace(term); term != "" {
+			mustNot = append(mustNot, bleve.NewMatchPhraseQuery(term))
+		}
+	}
+	if len(mustNot) == 0 {
+		return q
+	}
+	boolean := bleve.NewBooleanQuery()
+	boolean.AddMust(q)
+	boolean.AddMustNot(mustNot...)
+	return boolean
+}
@@ -0,0 +1,104 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// ValidateSearchableAttributes checks the searchable attributes of an index
+// and their boosts
+func ValidateSearchableAttributes(searchable map[string]float64) error {
+	for field, boost := range searchable {
+		if strings.TrimSpace(field) == "" || strings.HasPrefix(field, "_") {
+			return fmt.Errorf("invalid searchable attribute %q", field)
+		}
+		if boost <= 0 || math.IsInf(boost, 0) || math.IsNaN(boost) {
+			return fmt.Errorf("boost of searchable attribute %s must be a positive number", field)
+		}
+	}
+	return nil
+}
+
+// boostFields rewrites a parsed query string so each clause without a field
+// matches the searchable attributes instead of _all, its score multiplied by
+// each attribute's boost
+func boostFields(q query.Query, searchable map[string]float64) query.Query {
+	if len(searchable) == 0 {
+		return q
+	}
+	fields := make([]string, 0, len(searchable))
+	for field := range searchable {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return rewriteFields(q, fields, searchable)
+}
+
+func rewriteFields(q query.Query, fields []string, boosts map[string]float64) query.Query {
+	switch q := q.(type) {
+	case *query.BooleanQuery:
+		if q.Must != nil {
+			q.Must = rewriteFields(q.Must, fields, boosts)
+		}
+		if q.Should != nil {
+			q.Should = rewriteFields(q.Should, fields, boosts)
+		}
+		if q.MustNot != nil {
+			q.MustNot = rewriteFields(q.MustNot, fields, boosts)
+		}
+		return q
+	case *query.ConjunctionQuery:
+		for i, conjunct := range q.Conjuncts {
+			q.Conjuncts[i] = rewriteFields(conjunct, fields, boosts)
+		}
+		return q
+	case *query.DisjunctionQuery:
+		for i, disjunct := range q.Disjuncts {
+			q.Disjuncts[i] = rewriteFields(disjunct, fields, boosts)
+		}
+		return q
+	case *query.MatchQuery:
+		return perField(q, q.FieldVal, q.BoostVal, fields, boosts, func() query.FieldableQuery { c := *q; return &c })
+	case *query.MatchPhraseQuery:
+		return perField(q, q.FieldVal, q.BoostVal, fields, boosts, func() query.FieldableQuery { c := *q; return &c })
+	case *query.WildcardQuery:
+		return perField(q, q.FieldVal, q.BoostVal, fields, boosts, func() query.FieldableQuery { c := *q; return &c })
+	case *query.RegexpQuery:
+		return perField(q, q.FieldVal, q.BoostVal, fields, boosts, func() query.FieldableQuery { c := *q; return &c })
+	case *query.FuzzyQuery:
+		return perField(q, q.FieldVal, q.BoostVal, fields, boosts, func() query.FieldableQuery { c := *q; return &c })
+	case *query.PrefixQuery:
+		return perField(q, q.FieldVal, q.BoostVal, fields, boosts, func() query.FieldableQuery { c := *q; return &c })
+	case *query.TermQuery:
+		return perField(q, q.FieldVal, q.BoostVal, fields, boosts, func() query.FieldableQuery { c := *q; return &c })
+	}
+	return q
+}
+
+// perField matches a copy of a clause without a field in each searchable
+// attribute, boosted by the attribute's boost. Clauses with a field are kept.
+func perField(original query.Query, field string, boost *query.Boost, fields []string, boosts map[string]float64, clone func() query.FieldableQuery) query.Query {
+	if field != "" {
+		return original
+	}
+	base := 1.0
+	if boost != nil {
+		base = float64(*boost)
+	}
+
+	disjuncts := make([]query.Query, 0, len(fields))
+	for _, name := range fields {
+		c := clone()
+		c.SetField(name)
+		if boostable, ok := c.(query.BoostableQuery); ok {
+			boostable.SetBoost(base * boosts[name])
+		}
+		disjuncts = append(disjuncts, c)
+	}
+	return bleve.NewDisjunctionQuery(disjuncts...)
+}
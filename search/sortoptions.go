@@ -0,0 +1,69 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"bright/models"
+
+	bsearch "github.com/blevesearch/bleve/v2/search"
+)
+
+// ErrInvalidSort is returned for invalid sort options
+var ErrInvalidSort = errors.New("invalid sort options")
+
+// Where hits missing a sort field are placed
+const (
+	SortMissingFirst = "first"
+	SortMissingLast  = "last"
+)
+
+// How the values of a sort field are compared
+const (
+	SortTypeNumber = "number"
+	SortTypeDate   = "date"
+	SortTypeText   = "text"
+)
+
+// ValidateSortOptions checks the sort options of a request or of search
+// defaults
+func ValidateSortOptions(options map[string]models.SortOptions) error {
+	for field, option := range options {
+		if strings.TrimSpace(field) == "" || strings.HasPrefix(field, "-") {
+			return fmt.Errorf("%w: %q is not a field", ErrInvalidSort, field)
+		}
+		switch option.Missing {
+		case "", SortMissingFirst, SortMissingLast:
+		default:
+			return fmt.Errorf("%w: missing of %s must be %s or %s", ErrInvalidSort, field, SortMissingFirst, SortMissingLast)
+		}
+		switch option.Type {
+		case "", SortTypeNumber, SortTypeDate, SortTypeText:
+		default:
+			return fmt.Errorf("%w: type of %s must be %s, %s or %s", ErrInvalidSort, field, SortTypeNumber, SortTypeDate, SortTypeText)
+		}
+	}
+	return nil
+}
+
+// sortBy compiles a resolved sort field with its options
+func sortBy(field string, option models.SortOptions) bsearch.SearchSort {
+	sort := bsearch.ParseSearchSortString(field)
+	sortField, ok := sort.(*bsearch.SortField)
+	if !ok {
+		return sort
+	}
+	if option.Missing == SortMissingFirst {
+		sortField.Missing = bsearch.SortFieldMissingFirst
+	}
+	switch option.Type {
+	case SortTypeNumber:
+		sortField.Type = bsearch.SortFieldAsNumber
+	case SortTypeDate:
+		sortField.Type = bsearch.SortFieldAsDate
+	case SortTypeText:
+		sortField.Type = bsearch.SortFieldAsString
+	}
+	return sortField
+}
@@ -0,0 +1,123 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// MaxSynonymGroups bounds the synonym groups of an index
+const MaxSynonymGroups = 10000
+
+// ValidateSynonyms checks the synonym groups of an index. Each group is a
+// term with the terms equivalent to it.
+func ValidateSynonyms(synonyms map[string][]string) error {
+	if len(synonyms) > MaxSynonymGroups {
+		return fmt.Errorf("at most %d synonym groups per index", MaxSynonymGroups)
+	}
+	for term, equivalents := range synonyms {
+		if strings.TrimSpace(term) == "" {
+			return fmt.Errorf("synonyms need a term")
+		}
+		if len(equivalents) == 0 {
+			return fmt.Errorf("synonyms of %q are empty", term)
+		}
+		for _, equivalent := range equivalents {
+			if strings.TrimSpace(equivalent) == "" {
+				return fmt.Errorf("synonyms of %q include an empty term", term)
+			}
+		}
+	}
+	return nil
+}
+
+// synonymTable maps each lowercased term of the synonym groups to the other
+// terms of its groups. Groups work both ways: every term of a group expands
+// to all others.
+func synonymTable(synonyms map[string][]string) map[string][]string {
+	table := make(map[string][]string)
+	for term, equivalents := range synonyms {
+		group := append([]string{term}, equivalents...)
+		for _, member := range group {
+			key := strings.ToLower(strings.TrimSpace(member))
+			for _, other := range group {
+				other = strings.TrimSpace(other)
+				if strings.ToLower(other) != key && !containsFold(table[key], other) {
+					table[key] = append(table[key], other)
+				}
+			}
+		}
+	}
+	return table
+}
+
+// containsFold reports whether terms hold term, ignoring case
+func containsFold(terms []string, term string) bool {
+	for _, t := range terms {
+		if strings.EqualFold(t, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// expandSynonyms rewrites a parsed query string so each term and quoted
+// phrase that is part of a synonym group also matches the other terms of
+// the group, in the same field. Terms of several words are matched as
+// phrases.
+func expandSynonyms(q query.Query, synonyms map[string][]string) query.Query {
+	if len(synonyms) == 0 {
+		return q
+	}
+	return rewriteSynonyms(q, synonymTable(synonyms))
+}
+
+func rewriteSynonyms(q query.Query, table map[string][]string) query.Query {
+	switch q := q.(type) {
+	case *query.BooleanQuery:
+		if q.Must != nil {
+			q.Must = rewriteSynonyms(q.Must, table)
+		}
+		if q.Should != nil {
+			q.Should = rewriteSynonyms(q.Should, table)
+		}
+		// Excluded terms are left as written
+		return q
+	case *query.ConjunctionQuery:
+		for i, conjunct := range q.Conjuncts {
+			q.Conjuncts[i] = rewriteSynonyms(conjunct, table)
+		}
+		return q
+	case *query.DisjunctionQuery:
+		for i, disjunct := range q.Disjuncts {
+			q.Disjuncts[i] = rewriteSynonyms(disjunct, table)
+		}
+		return q
+	case *query.MatchQuery:
+		return withSynonyms(q, q.Match, q.FieldVal, q.BoostVal, table)
+	case *query.MatchPhraseQuery:
+		return withSynonyms(q, q.MatchPhrase, q.FieldVal, q.BoostVal, table)
+	}
+	return q
+}
+
+// withSynonyms matches either the original query or any synonym of its text
+func withSynonyms(original query.Query, text, field string, boost *query.Boost, table map[string][]string) query.Query {
+	equivalents := table[strings.ToLower(strings.TrimSpace(text))]
+	if len(equivalents) == 0 {
+		return original
+	}
+
+	disjuncts := []query.Query{original}
+	for _, equivalent := range equivalents {
+		phrase := bleve.NewMatchPhraseQuery(equivalent)
+		phrase.SetField(field)
+		if boost != nil {
+			phrase.SetBoost(float64(*boost))
+		}
+		disjuncts = append(disjuncts, phrase)
+	}
+	return bleve.NewDisjunctionQuery(disjuncts...)
+}
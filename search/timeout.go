@@ -0,0 +1,92 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/blevesearch/bleve/v2/mapping"
+	bsearch "github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
+	index "github.com/blevesearch/bleve_index_api"
+)
+
+// ErrInvalidTimeout is returned for negative timeouts
+var ErrInvalidTimeout = errors.New("invalid timeout")
+
+// searchDeadline cuts the matching of a search short once its timeout
+// passed, so it returns the hits collected so far
+type searchDeadline struct {
+	at      time.Time
+	expired atomic.Bool
+	timer   *time.Timer
+
+	// cut is set when a searcher stopped matching because of the deadline
+	cut atomic.Bool
+}
+
+// newSearchDeadline starts the clock of a search's timeout
+func newSearchDeadline(timeout time.Duration) *searchDeadline {
+	d := &searchDeadline{at: time.Now().Add(timeout)}
+	d.timer = time.AfterFunc(timeout, func() { d.expired.Store(true) })
+	return d
+}
+
+// stop releases the timer
+func (d *searchDeadline) stop() {
+	d.timer.Stop()
+}
+
+// timedOut returns true when the deadline cut the search short
+func (d *searchDeadline) timedOut() bool {
+	return d.cut.Load()
+}
+
+// context returns a context ending at the deadline, for searches that can't
+// return partial results
+func (d *searchDeadline) context() (context.Context, context.CancelFunc) {
+	return context.WithDeadline(context.Background(), d.at)
+}
+
+// wrap returns q, matching no more documents once the deadline passed
+func (d *searchDeadline) wrap(q query.Query) query.Query {
+	return &deadlineQuery{Query: q, deadline: d}
+}
+
+// deadlineQuery is a query whose searcher stops at a deadline
+type deadlineQuery struct {
+	query.Query
+	deadline *searchDeadline
+}
+
+func (q *deadlineQuery) Searcher(ctx context.Context, i index.IndexReader, m mapping.IndexMapping, options bsearch.SearcherOptions) (bsearch.Searcher, error) {
+	searcher, err := q.Query.Searcher(ctx, i, m, options)
+	if err != nil {
+		return nil, err
+	}
+	return &deadlineSearcher{Searcher: searcher, deadline: q.deadline}, nil
+}
+
+// deadlineSearcher reports no further matches once the deadline passed,
+// which ends the collection of hits as if the index had no more
+type deadlineSearcher struct {
+	bsearch.Searcher
+	deadline *searchDeadline
+}
+
+func (s *deadlineSearcher) Next(ctx *bsearch.SearchContext) (*bsearch.DocumentMatch, error) {
+	if s.deadline.expired.Load() {
+		s.deadline.cut.Store(true)
+		return nil, nil
+	}
+	return s.Searcher.Next(ctx)
+}
+
+func (s *deadlineSearcher) Advance(ctx *bsearch.SearchContext, ID index.IndexInternalID) (*bsearch.DocumentMatch, error) {
+	if s.deadline.expired.Load() {
+		s.deadline.cut.Store(true)
+		return nil, nil
+	}
+	return s.Searcher.Advance(ctx, ID)
+}
@@ -0,0 +1,57 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"bright/models"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+func TestSearchDeadline(t *testing.T) {
+	index, err := bleve.NewMemOnly(bleve.NewIndexMapping())
+	if err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	defer index.Close()
+	for i := range 10 {
+		if err := index.Index(fmt.Sprint(i), map[string]any{"title": "dune"}); err != nil {
+			t.Fatalf("Failed to index: %v", err)
+		}
+	}
+
+	deadline := newSearchDeadline(time.Hour)
+	defer deadline.stop()
+	request := bleve.NewSearchRequest(deadline.wrap(bleve.NewMatchQuery("dune")))
+	result, err := index.Search(request)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if result.Total != 10 || deadline.timedOut() {
+		t.Errorf("Expected 10 hits before the deadline, got %d (timed out: %v)", result.Total, deadline.timedOut())
+	}
+
+	// Past the deadline matching stops, and the search returns what it found
+	deadline.expired.Store(true)
+	result, err = index.Search(request)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if result.Total != 0 || !deadline.timedOut() {
+		t.Errorf("Expected the search to be cut short, got %d hits (timed out: %v)", result.Total, deadline.timedOut())
+	}
+
+	response, err := Execute(index, models.SearchRequest{Query: "dune", Limit: 20, TimeoutMs: 60000})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(response.Hits) != 10 || response.TimedOut {
+		t.Errorf("Expected 10 hits within the timeout, got %d (timed out: %v)", len(response.Hits), response.TimedOut)
+	}
+	if _, err := Execute(index, models.SearchRequest{Query: "dune", TimeoutMs: -1}); !errors.Is(err, ErrInvalidTimeout) {
+		t.Errorf("Expected ErrInvalidTimeout, got %v", err)
+	}
+}
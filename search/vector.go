@@ -0,0 +1,77 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"bright/models"
+)
+
+// Vector field bounds and settings, as supported by bleve
+const (
+	MinVectorDims = 1
+	MaxVectorDims = 2048
+	MaxVectorK    = 10000
+)
+
+// Similarities and optimizations of vector fields
+const (
+	VectorL2Norm     = "l2_norm"
+	VectorDotProduct = "dot_product"
+	VectorRecall     = "recall"
+	VectorLatency    = "latency"
+)
+
+// ErrInvalidVector is returned for malformed vector fields and kNN searches
+var ErrInvalidVector = errors.New("invalid vector search")
+
+// ErrVectorsUnsupported is returned for vector fields and kNN searches when
+// the server is built without vector support (-tags vectors)
+var ErrVectorsUnsupported = errors.New("vector search is not supported by this build, rebuild with -tags vectors")
+
+// ValidateVectorSettings checks the settings of a vector field
+func ValidateVectorSettings(field string, settings models.VectorSettings) error {
+	if field == "" || strings.HasPrefix(field, "_") {
+		return fmt.Errorf("%w: invalid vector field %q", ErrInvalidVector, field)
+	}
+	if settings.Dims < MinVectorDims || settings.Dims > MaxVectorDims {
+		return fmt.Errorf("%w: dims of %s must be between %d and %d", ErrInvalidVector, field, MinVectorDims, MaxVectorDims)
+	}
+	switch settings.Similarity {
+	case "", VectorL2Norm, VectorDotProduct:
+	default:
+		return fmt.Errorf("%w: similarity of %s must be %s or %s", ErrInvalidVector, field, VectorL2Norm, VectorDotProduct)
+	}
+	switch settings.OptimizedFor {
+	case "", VectorRecall, VectorLatency:
+	default:
+		return fmt.Errorf("%w: optimizedFor of %s must be %s or %s", ErrInvalidVector, field, VectorRecall, VectorLatency)
+	}
+	return nil
+}
+
+// validateVector checks the kNN part of a request against the vector fields
+// it may use
+func validateVector(v *models.VectorQuery, fields map[string]models.VectorSettings) error {
+	settings, ok := fields[v.Field]
+	if !ok {
+		return fmt.Errorf("%w: %s is not a vector field", ErrInvalidVector, v.Field)
+	}
+	if len(v.Values) != settings.Dims {
+		return fmt.Errorf("%w: %s holds vectors of %d values, got %d", ErrInvalidVector, v.Field, settings.Dims, len(v.Values))
+	}
+	for _, value := range v.Values {
+		if f := float64(value); math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("%w: vector values must be finite numbers", ErrInvalidVector)
+		}
+	}
+	if v.K < 0 || v.K > MaxVectorK {
+		return fmt.Errorf("%w: k must be between 1 and %d", ErrInvalidVector, MaxVectorK)
+	}
+	if v.Boost != nil && (*v.Boost < 0 || math.IsNaN(*v.Boost)) {
+		return fmt.Errorf("%w: boost must not be negative", ErrInvalidVector)
+	}
+	return validateFusion(v)
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"bright/models"
+	"errors"
+	"fmt"
+)
+
+// ErrAlertRuleNotFound is returned for unknown alert rules
+var ErrAlertRuleNotFound = errors.New("alert rule not found")
+
+// AlertRegistry holds the alerting rules kept in alerts.json. The leader's
+// alert scheduler evaluates them, apart from cluster.has_leader rules which
+// every node watches.
+type AlertRegistry struct {
+	rules *fileRegistry[models.AlertRule]
+}
+
+// newAlertRegistry loads the alert rules persisted in dataDir
+func newAlertRegistry(dataDir string) (*AlertRegistry, error) {
+	rules, err := loadRegistry(dataDir, "alerts.json", "alert rules",
+		func(rule *models.AlertRule) string { return rule.ID },
+		func(a, b *models.AlertRule) bool {
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.Before(b.CreatedAt)
+			}
+			return a.ID < b.ID
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &AlertRegistry{rules: rules}, nil
+}
+
+// Put creates or replaces a rule
+func (r *AlertRegistry) Put(rule *models.AlertRule) error {
+	return r.rules.put(rule)
+}
+
+// Get returns a rule by ID
+func (r *AlertRegistry) Get(id string) (*models.AlertRule, error) {
+	rule, ok := r.rules.get(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrAlertRuleNotFound, id)
+	}
+	return rule, nil
+}
+
+// Delete removes a rule
+func (r *AlertRegistry) Delete(id string) error {
+	found, err := r.rules.delete(id)
+	if !found {
+		return fmt.Errorf("%w: %s", ErrAlertRuleNotFound, id)
+	}
+	return err
+}
+
+// List returns all rules ordered by creation time
+func (r *AlertRegistry) List() []*models.AlertRule {
+	return r.rules.list(nil)
+}
+
+// Restore replaces all rules (used when restoring Raft snapshots)
+func (r *AlertRegistry) Restore(rules []*models.AlertRule) error {
+	return r.rules.restore(rules)
+}
@@ -0,0 +1,188 @@
+package store
+
+import (
+	"bright/models"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrAliasNotFound is returned for unknown index aliases
+var ErrAliasNotFound = errors.New("alias not found")
+
+// FirstPartitionSuffix ends the name of an alias's first partition. An alias
+// is created by its first rollover when the index <alias>-000001 exists.
+const FirstPartitionSuffix = "-000001"
+
+// AliasRegistry holds the index aliases kept in aliases.json. Reads resolve
+// an alias to all its indexes and writes to its write index, which rollovers
+// move to a new partition.
+type AliasRegistry struct {
+	aliases *fileRegistry[models.IndexAlias]
+}
+
+// newAliasRegistry loads the aliases persisted in dataDir
+func newAliasRegistry(dataDir string) (*AliasRegistry, error) {
+	aliases, err := loadRegistry(dataDir, "aliases.json", "aliases",
+		func(alias *models.IndexAlias) string { return alias.Name },
+		func(a, b *models.IndexAlias) bool { return a.Name < b.Name },
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &AliasRegistry{aliases: aliases}, nil
+}
+
+// Put creates or replaces an alias
+func (r *AliasRegistry) Put(alias *models.IndexAlias) error {
+	return r.aliases.put(alias)
+}
+
+// Get returns an alias by name
+func (r *AliasRegistry) Get(name string) (*models.IndexAlias, error) {
+	alias, ok := r.aliases.get(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrAliasNotFound, name)
+	}
+	return alias, nil
+}
+
+// List returns all aliases ordered by name
+func (r *AliasRegistry) List() []*models.IndexAlias {
+	return r.aliases.list(nil)
+}
+
+// removeIndex drops a deleted index from the aliases listing it. An alias
+// whose write index is deleted writes to its newest remaining partition, and
+// an alias without partitions is removed.
+func (r *AliasRegistry) removeIndex(indexID string) error {
+	return r.aliases.update(func(aliases map[string]*models.IndexAlias) bool {
+		changed := false
+		for name, alias := range aliases {
+			if !slices.Contains(alias.Indexes, indexID) {
+				continue
+			}
+			changed = true
+
+			updated := *alias
+			updated.Indexes = slices.DeleteFunc(slices.Clone(alias.Indexes), func(id string) bool { return id == indexID })
+			if len(updated.Indexes) == 0 {
+				delete(aliases, name)
+				continue
+			}
+			if updated.WriteIndex == indexID {
+				updated.WriteIndex = updated.Indexes[len(updated.Indexes)-1]
+			}
+			aliases[name] = &updated
+		}
+		return changed
+	})
+}
+
+// Restore replaces all aliases (used when restoring Raft snapshots)
+func (r *AliasRegistry) Restore(aliases []*models.IndexAlias) error {
+	return r.aliases.restore(aliases)
+}
+
+// WriteIndex returns the index written to through id: the write index when
+// id names an alias, and id itself otherwise
+func (s *IndexStore) WriteIndex(id string) string {
+	if alias, err := s.aliases.Get(id); err == nil {
+		return alias.WriteIndex
+	}
+	return id
+}
+
+// ResolveAlias returns an alias, or the alias its first rollover would
+// create when the index <name>-000001 exists. The new alias isn't stored.
+func (s *IndexStore) ResolveAlias(name string, now time.Time) (*models.IndexAlias, error) {
+	if alias, err := s.aliases.Get(name); err == nil {
+		return alias, nil
+	}
+	if _, _, err := s.GetIndex(name); err == nil {
+		return nil, fmt.Errorf("%s is an index, not an alias", name)
+	}
+
+	first := name + FirstPartitionSuffix
+	if _, _, err := s.GetIndex(first); err != nil {
+		return nil, fmt.Errorf("%w: %s (and no index %s to start it)", ErrAliasNotFound, name, first)
+	}
+	return &models.IndexAlias{
+		Name:         name,
+		WriteIndex:   first,
+		Indexes:      []string{first},
+		RolledOverAt: now,
+	}, nil
+}
+
+// RolloverConditionsMet checks the write index of an alias against rollover
+// conditions. It reports each set condition and whether the alias should
+// roll over: when any condition is met, or when none is set.
+func (s *IndexStore) RolloverConditionsMet(alias *models.IndexAlias, conditions models.RolloverConditions, now time.Time) (map[string]bool, bool, error) {
+	index, _, err := s.GetIndex(alias.WriteIndex)
+	if err != nil {
+		return nil, false, err
+	}
+
+	met := make(map[string]bool)
+	if conditions.MaxDocs > 0 {
+		count, err := index.DocCount()
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to count documents: %w", err)
+		}
+		met["maxDocs"] = count >= conditions.MaxDocs
+	}
+	if conditions.MaxAge != "" {
+		maxAge, err := ParseRolloverAge(conditions.MaxAge)
+		if err != nil {
+			return nil, false, err
+		}
+		met["maxAge"] = now.Sub(alias.RolledOverAt) >= maxAge
+	}
+	if conditions.MaxSizeBytes > 0 {
+		report, err := s.Storage(alias.WriteIndex)
+		if err != nil {
+			return nil, false, err
+		}
+		met["maxSizeBytes"] = report.TotalBytes >= conditions.MaxSizeBytes
+	}
+
+	rollover := len(met) == 0
+	for _, ok := range met {
+		rollover = rollover || ok
+	}
+	return met, rollover, nil
+}
+
+// ParseRolloverAge parses a maximum age: a Go duration or a number of days
+// ("7d")
+func ParseRolloverAge(value string) (time.Duration, error) {
+	duration, err := time.ParseDuration(value)
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		duration = time.Duration(n) * 24 * time.Hour
+	}
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("invalid maxAge %q", value)
+	}
+	return duration, nil
+}
+
+// NextPartition returns the name of the partition after id, incrementing its
+// numeric suffix and keeping its width ("logs-000009" → "logs-000010")
+func NextPartition(id string) (string, error) {
+	dash := strings.LastIndex(id, "-")
+	if dash < 0 {
+		return "", fmt.Errorf("index %s has no numeric suffix", id)
+	}
+	digits := id[dash+1:]
+	n, err := strconv.ParseUint(digits, 10, 64)
+	if err != nil || digits == "" {
+		return "", fmt.Errorf("index %s has no numeric suffix", id)
+	}
+	return fmt.Sprintf("%s-%0*d", id[:dash], len(digits), n+1), nil
+}
@@ -0,0 +1,170 @@
+package store
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"bright/models"
+
+	regexpchar "github.com/blevesearch/bleve/v2/analysis/char/regexp"
+	"github.com/blevesearch/bleve/v2/analysis/token/apostrophe"
+	"github.com/blevesearch/bleve/v2/analysis/token/camelcase"
+	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/v2/analysis/token/reverse"
+	"github.com/blevesearch/bleve/v2/analysis/token/unique"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/letter"
+	regexptokenizer "github.com/blevesearch/bleve/v2/analysis/tokenizer/regexp"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/single"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/unicode"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/web"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/whitespace"
+	"github.com/blevesearch/bleve/v2/mapping"
+)
+
+// Names of the analysis components of an index are prefixed in the bleve
+// mapping so they never shadow bleve's own
+const analysisPrefix = "analysis."
+
+// builtinTokenizers are the tokenizers fields can name without defining them
+var builtinTokenizers = map[string]string{
+	"unicode":    unicode.Name,
+	"whitespace": whitespace.Name,
+	"letter":     letter.Name,
+	"web":        web.Name,
+	"single":     single.Name,
+}
+
+// builtinTokenFilters are the token filters fields and chains can name
+// without defining them
+var builtinTokenFilters = map[string]string{
+	"lowercase":  lowercase.Name,
+	"camelCase":  camelcase.Name,
+	"unique":     unique.Name,
+	"reverse":    reverse.Name,
+	"apostrophe": apostrophe.Name,
+}
+
+// addAnalysisComponents registers the tokenizers and char filters of an
+// index's analysis settings. Token filter chains are expanded into the
+// analyzers of the fields using them.
+func addAnalysisComponents(indexMapping *mapping.IndexMappingImpl, analysis *models.AnalysisSettings) error {
+	if analysis == nil {
+		return nil
+	}
+
+	for _, name := range sortedKeys(analysis.Tokenizers) {
+		tokenizer := analysis.Tokenizers[name]
+		if _, builtin := builtinTokenizers[name]; builtin || name == "" {
+			return fmt.Errorf("tokenizer name %q is taken", name)
+		}
+		if _, err := regexp.Compile(tokenizer.Pattern); err != nil || tokenizer.Pattern == "" {
+			return fmt.Errorf("tokenizer %s needs a valid pattern", name)
+		}
+		err := indexMapping.AddCustomTokenizer(analysisPrefix+name, map[string]any{
+			"type":   regexptokenizer.Name,
+			"regexp": tokenizer.Pattern,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, name := range sortedKeys(analysis.CharFilters) {
+		filter := analysis.CharFilters[name]
+		if _, err := regexp.Compile(filter.Pattern); err != nil || filter.Pattern == "" || name == "" {
+			return fmt.Errorf("char filter %s needs a valid pattern", name)
+		}
+		err := indexMapping.AddCustomCharFilter(analysisPrefix+name, map[string]any{
+			"type":    regexpchar.Name,
+			"regexp":  filter.Pattern,
+			"replace": filter.Replacement,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, name := range sortedKeys(analysis.TokenFilters) {
+		if _, builtin := builtinTokenFilters[name]; builtin || name == "" {
+			return fmt.Errorf("token filter name %q is taken", name)
+		}
+		if _, err := expandTokenFilters(analysis, []string{name}, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldTokenizer resolves the tokenizer a field names
+func fieldTokenizer(analysis *models.AnalysisSettings, name string) (string, error) {
+	if tokenizer, ok := builtinTokenizers[name]; ok {
+		return tokenizer, nil
+	}
+	if analysis != nil {
+		if _, ok := analysis.Tokenizers[name]; ok {
+			return analysisPrefix + name, nil
+		}
+	}
+	return "", fmt.Errorf("unknown tokenizer %q", name)
+}
+
+// fieldCharFilters resolves the char filters a field names
+func fieldCharFilters(analysis *models.AnalysisSettings, names []string) ([]any, error) {
+	filters := make([]any, 0, len(names))
+	for _, name := range names {
+		if analysis == nil {
+			return nil, fmt.Errorf("unknown char filter %q", name)
+		}
+		if _, ok := analysis.CharFilters[name]; !ok {
+			return nil, fmt.Errorf("unknown char filter %q", name)
+		}
+		filters = append(filters, analysisPrefix+name)
+	}
+	return filters, nil
+}
+
+// expandTokenFilters resolves token filter names to bleve token filters,
+// expanding chains in place. path holds the chains being expanded, to
+// reject chains that contain themselves.
+func expandTokenFilters(analysis *models.AnalysisSettings, names []string, path []string) ([]any, error) {
+	var filters []any
+	for _, name := range names {
+		if filter, ok := builtinTokenFilters[name]; ok {
+			filters = append(filters, filter)
+			continue
+		}
+
+		var chain []string
+		ok := false
+		if analysis != nil {
+			chain, ok = analysis.TokenFilters[name]
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown token filter %q", name)
+		}
+		for _, expanding := range path {
+			if expanding == name {
+				return nil, fmt.Errorf("token filter %s contains itself", name)
+			}
+		}
+
+		expanded, err := expandTokenFilters(analysis, chain, append(path, name))
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, expanded...)
+	}
+	return filters, nil
+}
+
+// sortedKeys returns the keys of a map in order, so mappings are built the
+// same way every time
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
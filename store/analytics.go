@@ -0,0 +1,149 @@
+package store
+
+import (
+	"bright/models"
+	"bright/persist"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/bytedance/sonic"
+)
+
+// analyticsRecord is the persisted form of a variant's counts
+type analyticsRecord struct {
+	IndexID  string `json:"indexId"`
+	Variant  string `json:"variant"`
+	Searches uint64 `json:"searches"`
+	Clicks   uint64 `json:"clicks"`
+}
+
+// Analytics counts the searches and clicks of ranking variants. Counts are
+// kept by each node for the requests it serves, and saved when the store
+// closes.
+type Analytics struct {
+	counts map[string]map[string]*analyticsRecord
+	file   string
+	mu     sync.Mutex
+}
+
+// newAnalytics loads the counts persisted in dataDir
+func newAnalytics(dataDir string) (*Analytics, error) {
+	a := &Analytics{
+		counts: make(map[string]map[string]*analyticsRecord),
+		file:   filepath.Join(dataDir, "analytics.json"),
+	}
+
+	data, _, err := persist.ReadFile(a.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return a, nil
+		}
+		return nil, fmt.Errorf("failed to read analytics: %w", err)
+	}
+
+	var records []*analyticsRecord
+	if err := sonic.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse analytics: %w", err)
+	}
+	for _, record := range records {
+		if a.counts[record.IndexID] == nil {
+			a.counts[record.IndexID] = make(map[string]*analyticsRecord)
+		}
+		a.counts[record.IndexID][record.Variant] = record
+	}
+
+	return a, nil
+}
+
+// record returns the counts of a variant (must be called with lock held)
+func (a *Analytics) record(indexID, variant string) *analyticsRecord {
+	variants := a.counts[indexID]
+	if variants == nil {
+		variants = make(map[string]*analyticsRecord)
+		a.counts[indexID] = variants
+	}
+	record := variants[variant]
+	if record == nil {
+		record = &analyticsRecord{IndexID: indexID, Variant: variant}
+		variants[variant] = record
+	}
+	return record
+}
+
+// RecordSearch counts a search served by a variant
+func (a *Analytics) RecordSearch(indexID, variant string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.record(indexID, variant).Searches++
+}
+
+// RecordClick counts a click on a hit of a search served by a variant
+func (a *Analytics) RecordClick(indexID, variant string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.record(indexID, variant).Clicks++
+}
+
+// Report returns the counts and click-through rates of the variants of an
+// experiment, in its order
+func (a *Analytics) Report(indexID string, experiment *models.RankingExperiment) *models.ExperimentReport {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	report := &models.ExperimentReport{IndexID: indexID, Variants: []models.VariantStats{}}
+	if experiment == nil {
+		return report
+	}
+	for _, variant := range experiment.Variants {
+		stats := models.VariantStats{Variant: variant.Name, Traffic: variant.Traffic}
+		if record := a.counts[indexID][variant.Name]; record != nil {
+			stats.Searches, stats.Clicks = record.Searches, record.Clicks
+		}
+		if stats.Searches > 0 {
+			stats.CTR = float64(stats.Clicks) / float64(stats.Searches)
+		}
+		report.Variants = append(report.Variants, stats)
+	}
+	return report
+}
+
+// Reset drops the counts of an index, to start an experiment over
+func (a *Analytics) Reset(indexID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.counts, indexID)
+}
+
+// save persists the counts
+func (a *Analytics) save() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	records := make([]*analyticsRecord, 0)
+	for _, variants := range a.counts {
+		for _, record := range variants {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].IndexID != records[j].IndexID {
+			return records[i].IndexID < records[j].IndexID
+		}
+		return records[i].Variant < records[j].Variant
+	})
+
+	data, err := sonic.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("failed to marshal analytics: %w", err)
+	}
+	if err := persist.WriteFile(a.file, data, 0644); err != nil {
+		return fmt.Errorf("failed to write analytics: %w", err)
+	}
+	return nil
+}
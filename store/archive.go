@@ -0,0 +1,379 @@
+package store
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"bright/jobs"
+	"bright/models"
+
+	"github.com/blevesearch/bleve/v2"
+	bsearch "github.com/blevesearch/bleve/v2/search"
+	"github.com/bytedance/sonic"
+)
+
+// ErrInvalidArchive is returned for uploads that are not index archives this
+// server can read
+var ErrInvalidArchive = errors.New("invalid index archive")
+
+// ArchiveFormatVersion is the version of the index archive layout
+const ArchiveFormatVersion = 1
+
+// Index archive entries
+const (
+	archiveManifest = "manifest.json"
+	archiveConfig   = "config.json"
+	archiveIndexDir = "index"
+)
+
+// archiveDir holds the index copies being archived or imported, apart from
+// the indexes
+const archiveDir = ".archives"
+
+// maxArchiveMetadata bounds the size of the manifest and config entries
+const maxArchiveMetadata = 1 << 20
+
+// archiveChunkSize is the number of bytes written between throttling waits
+const archiveChunkSize = 1 << 20
+
+// ArchiveManifest describes the contents of an index archive
+type ArchiveManifest struct {
+	FormatVersion int       `json:"formatVersion"`
+	IndexID       string    `json:"indexId"`
+	CreatedAt     time.Time `json:"createdAt"`
+	Documents     uint64    `json:"documents"`
+}
+
+// IndexArchive is a point-in-time copy of an index with its configuration,
+// written out as a gzipped tar. Close removes the copy.
+type IndexArchive struct {
+	Manifest ArchiveManifest
+	config   *models.IndexConfig
+	path     string
+	jobs     *jobs.Manager
+}
+
+// ArchiveIndex copies an index and its configuration as they are now. Writes
+// that happen while the archive is written out are not part of it.
+func (s *IndexStore) ArchiveIndex(indexID string) (*IndexArchive, error) {
+	// Buffered documents are indexed first, so the archive has every
+	// acknowledged write
+	s.Refresh(indexID)
+
+	index, config, err := s.GetIndex(indexID)
+	if err != nil {
+		return nil, err
+	}
+	if inMemory(config) {
+		return nil, fmt.Errorf("index %s is kept in memory and cannot be archived", indexID)
+	}
+	copyable, ok := index.(bleve.IndexCopyable)
+	if !ok {
+		return nil, fmt.Errorf("index %s does not support archiving", indexID)
+	}
+
+	dir := filepath.Join(s.dataDir, archiveDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	path, err := os.MkdirTemp(dir, indexID+"-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	if err := copyable.CopyTo(bleve.FileSystemDirectory(path)); err != nil {
+		os.RemoveAll(path)
+		return nil, fmt.Errorf("failed to copy index: %w", err)
+	}
+
+	// The count comes from the copy, which may lag the index by the writes
+	// made since
+	documents := uint64(0)
+	if copied, err := bleve.Open(path); err == nil {
+		documents, _ = copied.DocCount()
+		copied.Close()
+	}
+
+	return &IndexArchive{
+		Manifest: ArchiveManifest{
+			FormatVersion: ArchiveFormatVersion,
+			IndexID:       indexID,
+			CreatedAt:     time.Now().UTC(),
+			Documents:     documents,
+		},
+		config: config,
+		path:   path,
+		jobs:   s.jobs,
+	}, nil
+}
+
+// Write writes the archive as a gzipped tar holding manifest.json,
+// config.json and the index files under index/. Writing is throttled as a
+// snapshot job.
+func (a *IndexArchive) Write(w io.Writer) error {
+	job := a.jobs.Start(jobs.KindSnapshot, a.Manifest.IndexID)
+	defer job.Done()
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	manifest, err := sonic.Marshal(a.Manifest)
+	if err != nil {
+		return fmt.Errorf("failed to marshal archive manifest: %w", err)
+	}
+	if err := writeArchiveFile(tw, archiveManifest, manifest); err != nil {
+		return err
+	}
+	config, err := sonic.Marshal(a.config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal index config: %w", err)
+	}
+	if err := writeArchiveFile(tw, archiveConfig, config); err != nil {
+		return err
+	}
+
+	err = filepath.WalkDir(a.path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(a.path, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(filepath.Join(archiveIndexDir, rel))
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		for {
+			n, err := io.CopyN(tw, file, archiveChunkSize)
+			if err == io.EOF {
+				return job.Wait(context.Background(), int(n))
+			}
+			if err != nil {
+				return err
+			}
+			if err := job.Wait(context.Background(), int(n)); err != nil {
+				return err
+			}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write index files: %w", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Close removes the copy of the index
+func (a *IndexArchive) Close() error {
+	return os.RemoveAll(a.path)
+}
+
+// writeArchiveFile adds a file with data to an archive
+func writeArchiveFile(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// ArchiveImport is an uploaded index archive unpacked into the data
+// directory. Close removes what was not installed.
+type ArchiveImport struct {
+	Manifest ArchiveManifest
+	Config   *models.IndexConfig
+	dir      string
+}
+
+// ReadArchive unpacks an index archive written by IndexArchive.Write and
+// checks that its format version is supported
+func (s *IndexStore) ReadArchive(r io.Reader) (*ArchiveImport, error) {
+	dir := filepath.Join(s.dataDir, archiveDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	path, err := os.MkdirTemp(dir, "import-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	a := &ArchiveImport{dir: path}
+
+	if err := a.unpack(r); err != nil {
+		a.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+// unpack extracts the entries of an archive
+func (a *ArchiveImport) unpack(r io.Reader) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	tr := tar.NewReader(gz)
+
+	var manifest, config []byte
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		switch name := header.Name; name {
+		case archiveManifest:
+			manifest, err = io.ReadAll(io.LimitReader(tr, maxArchiveMetadata))
+		case archiveConfig:
+			config, err = io.ReadAll(io.LimitReader(tr, maxArchiveMetadata))
+		default:
+			rel, ok := strings.CutPrefix(name, archiveIndexDir+"/")
+			if !ok || !filepath.IsLocal(rel) {
+				return fmt.Errorf("%w: unexpected entry %s", ErrInvalidArchive, name)
+			}
+			err = extractArchiveFile(tr, filepath.Join(a.indexPath(), filepath.FromSlash(rel)))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", header.Name, err)
+		}
+	}
+
+	if manifest == nil || config == nil {
+		return fmt.Errorf("%w: %s and %s are required", ErrInvalidArchive, archiveManifest, archiveConfig)
+	}
+	if err := sonic.Unmarshal(manifest, &a.Manifest); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidArchive, archiveManifest, err)
+	}
+	if version := a.Manifest.FormatVersion; version < 1 || version > ArchiveFormatVersion {
+		return fmt.Errorf("%w: format version %d is not supported (up to %d)", ErrInvalidArchive, version, ArchiveFormatVersion)
+	}
+	if err := sonic.Unmarshal(config, &a.Config); err != nil || a.Config == nil {
+		return fmt.Errorf("%w: %s is not an index configuration", ErrInvalidArchive, archiveConfig)
+	}
+	if _, err := os.Stat(filepath.Join(a.indexPath(), "index_meta.json")); err != nil {
+		return fmt.Errorf("%w: the archive holds no index", ErrInvalidArchive)
+	}
+	return nil
+}
+
+// indexPath is where the index files are unpacked
+func (a *ArchiveImport) indexPath() string {
+	return filepath.Join(a.dir, archiveIndexDir)
+}
+
+// ScanDocuments calls fn with pages of the archived documents, in ID order
+func (a *ArchiveImport) ScanDocuments(pageSize int, fn func(documents []map[string]any) error) error {
+	index, err := bleve.Open(a.indexPath())
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer index.Close()
+
+	return scanDocuments(context.Background(), index, pageSize, func(hits bsearch.DocumentMatchCollection) error {
+		documents := make([]map[string]any, 0, len(hits))
+		for _, hit := range hits {
+			documents = append(documents, hit.Fields)
+		}
+		return fn(documents)
+	})
+}
+
+// Close removes the unpacked archive
+func (a *ArchiveImport) Close() error {
+	return os.RemoveAll(a.dir)
+}
+
+// InstallArchive makes the index of an unpacked archive an index of the
+// store, with config (the archive's configuration under a new ID)
+func (s *IndexStore) InstallArchive(a *ArchiveImport, config *models.IndexConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.indexes[config.ID]; exists {
+		return fmt.Errorf("index %s already exists", config.ID)
+	}
+
+	// A directory without a configuration is left over from a failed delete
+	indexPath := filepath.Join(s.dataDir, config.ID)
+	if err := os.RemoveAll(indexPath); err != nil {
+		return fmt.Errorf("failed to clear index directory: %w", err)
+	}
+	if err := os.Rename(a.indexPath(), indexPath); err != nil {
+		return fmt.Errorf("failed to install index: %w", err)
+	}
+	index, err := bleve.Open(indexPath)
+	if err != nil {
+		os.RemoveAll(indexPath)
+		return fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+
+	s.indexes[config.ID] = index
+	s.configs[config.ID] = config
+	s.indexLocks[config.ID] = &sync.RWMutex{}
+	s.searchCache.Invalidate(config.ID)
+	return s.saveConfigs()
+}
+
+// extractArchiveFile writes an archive entry to path
+func extractArchiveFile(r io.Reader, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// cleanArchives removes the index copies left by archives interrupted by a
+// crash
+func cleanArchives(dataDir string) error {
+	return os.RemoveAll(filepath.Join(dataDir, archiveDir))
+}
@@ -0,0 +1,21 @@
+package store
+
+import (
+	"context"
+
+	"bright/attach"
+)
+
+// ExtractAttachments replaces the attachment fields of documents with their
+// text. Like enrichment it runs before a batch is journaled or replicated,
+// but an attachment that cannot be read fails the batch.
+func (s *IndexStore) ExtractAttachments(ctx context.Context, indexID string, documents []map[string]any) error {
+	_, config, err := s.GetIndex(indexID)
+	if err != nil {
+		return err
+	}
+	if len(config.Attachments) == 0 {
+		return nil
+	}
+	return attach.Process(ctx, config.Attachments, documents)
+}
@@ -0,0 +1,223 @@
+package store
+
+import (
+	"bright/persist"
+	"bright/search"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bytedance/sonic"
+)
+
+// Cluster setting keys
+const (
+	// SettingSearchDefaultLimit is the limit of searches that set none,
+	// when the index's search defaults don't either
+	SettingSearchDefaultLimit = "search.defaultLimit"
+
+	// SettingSearchDefaultTimeoutMs is the timeoutMs of searches that set
+	// none (0 for no timeout)
+	SettingSearchDefaultTimeoutMs = "search.defaultTimeoutMs"
+
+	// SettingSearchesPerSecond caps the searches each node serves per
+	// second (0 for no cap)
+	SettingSearchesPerSecond = "rateLimits.searchesPerSecond"
+
+	// SettingFeatureExplain allows searches with explain
+	SettingFeatureExplain = "features.explain"
+
+	// SettingFeatureGraphQL serves the GraphQL endpoint
+	SettingFeatureGraphQL = "features.graphql"
+)
+
+// ErrInvalidSetting is returned for unknown cluster settings and values of
+// the wrong type
+var ErrInvalidSetting = errors.New("invalid cluster setting")
+
+// settingDefinition is the default value of a setting, whose type (float64
+// or bool) its values must have, and the bounds of numbers
+type settingDefinition struct {
+	defaultValue any
+	min          float64
+	integer      bool
+}
+
+// settingDefinitions are the known cluster settings
+var settingDefinitions = map[string]settingDefinition{
+	SettingSearchDefaultLimit:     {defaultValue: float64(search.DefaultLimit), min: 1, integer: true},
+	SettingSearchDefaultTimeoutMs: {defaultValue: float64(0), integer: true},
+	SettingSearchesPerSecond:      {defaultValue: float64(0)},
+	SettingFeatureExplain:         {defaultValue: true},
+	SettingFeatureGraphQL:         {defaultValue: true},
+}
+
+// ValidateSettings checks changes to the cluster settings. A nil value
+// resets a setting to its default.
+func ValidateSettings(changes map[string]any) error {
+	for key, value := range changes {
+		definition, ok := settingDefinitions[key]
+		if !ok {
+			return fmt.Errorf("%w: unknown setting %s (settings: %s)", ErrInvalidSetting, key, strings.Join(settingKeys(), ", "))
+		}
+		if value == nil {
+			continue
+		}
+		switch v := value.(type) {
+		case bool:
+			if _, ok := definition.defaultValue.(bool); !ok {
+				return fmt.Errorf("%w: %s must be a number", ErrInvalidSetting, key)
+			}
+		case float64:
+			if _, ok := definition.defaultValue.(float64); !ok {
+				return fmt.Errorf("%w: %s must be true or false", ErrInvalidSetting, key)
+			}
+			if v < definition.min || math.IsInf(v, 0) || definition.integer && v != math.Trunc(v) {
+				kind := "a number"
+				if definition.integer {
+					kind = "an integer"
+				}
+				return fmt.Errorf("%w: %s must be %s of at least %v", ErrInvalidSetting, key, kind, definition.min)
+			}
+		default:
+			return fmt.Errorf("%w: %s must be a number or a boolean", ErrInvalidSetting, key)
+		}
+	}
+	return nil
+}
+
+// settingKeys returns the known settings, sorted
+func settingKeys() []string {
+	keys := make([]string, 0, len(settingDefinitions))
+	for key := range settingDefinitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ClusterSettings are runtime settings shared by every node of a cluster.
+// In Raft mode they are updated by the FSM and included in snapshots.
+type ClusterSettings struct {
+	values map[string]any
+	file   string
+	mu     sync.RWMutex
+}
+
+// newClusterSettings loads the settings persisted in dataDir
+func newClusterSettings(dataDir string) (*ClusterSettings, error) {
+	s := &ClusterSettings{
+		values: make(map[string]any),
+		file:   filepath.Join(dataDir, "cluster_settings.json"),
+	}
+
+	data, _, err := persist.ReadFile(s.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read cluster settings: %w", err)
+	}
+
+	if err := sonic.Unmarshal(data, &s.values); err != nil {
+		return nil, fmt.Errorf("failed to parse cluster settings: %w", err)
+	}
+	return s, nil
+}
+
+// Overrides returns the settings that were set
+func (s *ClusterSettings) Overrides() map[string]any {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return maps.Clone(s.values)
+}
+
+// Effective returns the value of every setting, set or default
+func (s *ClusterSettings) Effective() map[string]any {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	effective := make(map[string]any, len(settingDefinitions))
+	for key, definition := range settingDefinitions {
+		effective[key] = definition.defaultValue
+		if value, ok := s.values[key]; ok {
+			effective[key] = value
+		}
+	}
+	return effective
+}
+
+// Number returns the value of a numeric setting
+func (s *ClusterSettings) Number(key string) float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if value, ok := s.values[key].(float64); ok {
+		return value
+	}
+	value, _ := settingDefinitions[key].defaultValue.(float64)
+	return value
+}
+
+// Bool returns the value of a boolean setting
+func (s *ClusterSettings) Bool(key string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if value, ok := s.values[key].(bool); ok {
+		return value
+	}
+	value, _ := settingDefinitions[key].defaultValue.(bool)
+	return value
+}
+
+// Update validates and applies changes to the settings. A nil value resets
+// a setting to its default.
+func (s *ClusterSettings) Update(changes map[string]any) error {
+	if err := ValidateSettings(changes); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	values := maps.Clone(s.values)
+	for key, value := range changes {
+		if value == nil {
+			delete(values, key)
+		} else {
+			values[key] = value
+		}
+	}
+	return s.saveLocked(values)
+}
+
+// Restore replaces the settings (used when restoring Raft snapshots)
+func (s *ClusterSettings) Restore(values map[string]any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if values == nil {
+		values = make(map[string]any)
+	}
+	return s.saveLocked(values)
+}
+
+// saveLocked persists values and makes them current while s.mu is held
+func (s *ClusterSettings) saveLocked(values map[string]any) error {
+	data, err := sonic.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cluster settings: %w", err)
+	}
+	if err := persist.WriteFile(s.file, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cluster settings: %w", err)
+	}
+	s.values = values
+	return nil
+}
@@ -0,0 +1,364 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"bright/jobs"
+	"bright/models"
+
+	"github.com/blevesearch/bleve/v2/index/scorch"
+	"github.com/blevesearch/bleve/v2/index/scorch/mergeplan"
+)
+
+// ErrNothingToCompact is returned by Compact for an index that is already a
+// single segment. Merges never rewrite a lone segment, so its deleted
+// documents stay until newer segments are merged with it.
+var ErrNothingToCompact = errors.New("index is a single segment, nothing to compact")
+
+// SegmentStats describes the segments of an index
+type SegmentStats struct {
+	Segments         int     `json:"segments"`
+	Documents        uint64  `json:"documents"`
+	DeletedDocuments uint64  `json:"deletedDocuments"`
+	DeletedRatio     float64 `json:"deletedRatio"`
+}
+
+// CompactionStatus is the outcome of the last compaction of an index on
+// this node
+type CompactionStatus struct {
+	LastCompactedAt *time.Time `json:"lastCompactedAt,omitempty"`
+	LastReason      string     `json:"lastReason,omitempty"`
+	LastDurationMs  int64      `json:"lastDurationMs,omitempty"`
+	LastError       string     `json:"lastError,omitempty"`
+	Running         bool       `json:"running,omitempty"`
+}
+
+// compactions keeps the compaction status of each index
+type compactions struct {
+	status map[string]*CompactionStatus
+	mu     sync.Mutex
+}
+
+func newCompactions() *compactions {
+	return &compactions{status: make(map[string]*CompactionStatus)}
+}
+
+// get returns a copy of the status of an index, or nil if it was never
+// compacted
+func (c *compactions) get(indexID string) *CompactionStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status, ok := c.status[indexID]
+	if !ok {
+		return nil
+	}
+	copied := *status
+	return &copied
+}
+
+// start marks an index as being compacted, returning false if it already is
+func (c *compactions) start(indexID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status, ok := c.status[indexID]
+	if !ok {
+		status = &CompactionStatus{}
+		c.status[indexID] = status
+	}
+	if status.Running {
+		return false
+	}
+	status.Running = true
+	return true
+}
+
+// finish records the outcome of a compaction. Compactions that found
+// nothing to compact leave the last outcome as it was.
+func (c *compactions) finish(indexID, reason string, started time.Time, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status, ok := c.status[indexID]
+	if !ok {
+		status = &CompactionStatus{}
+		c.status[indexID] = status
+	}
+	status.Running = false
+	if errors.Is(err, ErrNothingToCompact) {
+		return
+	}
+	status.LastCompactedAt = &started
+	status.LastReason = reason
+	status.LastDurationMs = time.Since(started).Milliseconds()
+	status.LastError = ""
+	if err != nil {
+		status.LastError = err.Error()
+	}
+}
+
+// forget drops the status of a deleted index
+func (c *compactions) forget(indexID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.status, indexID)
+}
+
+// ValidateCompaction checks the compaction settings of an index or server
+func ValidateCompaction(settings *models.CompactionSettings) error {
+	if settings == nil {
+		return nil
+	}
+	if settings.MaxDeletedRatio < 0 || settings.MaxDeletedRatio > 1 {
+		return fmt.Errorf("compaction maxDeletedRatio must be between 0 and 1")
+	}
+	if settings.MaxSegments < 0 {
+		return fmt.Errorf("compaction maxSegments must not be negative")
+	}
+	if _, _, err := parseWindow(settings.Window); err != nil {
+		return err
+	}
+	if _, err := time.LoadLocation(settings.Timezone); err != nil {
+		return fmt.Errorf("invalid compaction timezone %q", settings.Timezone)
+	}
+	return nil
+}
+
+// effectiveCompaction merges the settings of an index over the server's
+func effectiveCompaction(defaults models.CompactionSettings, settings *models.CompactionSettings) models.CompactionSettings {
+	if settings == nil {
+		return defaults
+	}
+	effective := defaults
+	effective.Disabled = settings.Disabled
+	if settings.MaxDeletedRatio > 0 {
+		effective.MaxDeletedRatio = settings.MaxDeletedRatio
+	}
+	if settings.MaxSegments > 0 {
+		effective.MaxSegments = settings.MaxSegments
+	}
+	if settings.Window != "" {
+		effective.Window = settings.Window
+		effective.Timezone = settings.Timezone
+	}
+	return effective
+}
+
+// parseWindow parses a daily window such as "01:00-05:00" into minutes of
+// the day. An empty window is the whole day.
+func parseWindow(window string) (start, end int, err error) {
+	if window == "" {
+		return 0, 0, nil
+	}
+	from, to, ok := strings.Cut(window, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("compaction window must look like 01:00-05:00")
+	}
+	if start, err = parseClock(from); err != nil {
+		return 0, 0, err
+	}
+	if end, err = parseClock(to); err != nil {
+		return 0, 0, err
+	}
+	if start == end {
+		return 0, 0, fmt.Errorf("compaction window %q is empty", window)
+	}
+	return start, end, nil
+}
+
+// parseClock parses "HH:MM" into minutes of the day
+func parseClock(clock string) (int, error) {
+	hours, minutes, ok := strings.Cut(strings.TrimSpace(clock), ":")
+	h, hErr := strconv.Atoi(hours)
+	m, mErr := strconv.Atoi(minutes)
+	if !ok || hErr != nil || mErr != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, fmt.Errorf("invalid compaction window time %q", clock)
+	}
+	return h*60 + m, nil
+}
+
+// inWindow reports whether t falls in the compaction window of settings
+func inWindow(settings models.CompactionSettings, t time.Time) bool {
+	if settings.Window == "" {
+		return true
+	}
+	start, end, err := parseWindow(settings.Window)
+	if err != nil {
+		return false
+	}
+	loc, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		return false
+	}
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	if start < end {
+		return minute >= start && minute < end
+	}
+	// The window wraps midnight
+	return minute >= start || minute < end
+}
+
+// Segments returns the segment statistics of an index. Indexes that are not
+// stored in segments report none.
+func (s *IndexStore) Segments(indexID string) (*SegmentStats, error) {
+	index, _, err := s.GetIndex(indexID)
+	if err != nil {
+		return nil, err
+	}
+
+	advanced, err := index.Advanced()
+	if err != nil {
+		return nil, err
+	}
+	engine, ok := advanced.(*scorch.Scorch)
+	if !ok {
+		return &SegmentStats{}, nil
+	}
+
+	reader, err := engine.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	stats := &SegmentStats{}
+	snapshot, ok := reader.(*scorch.IndexSnapshot)
+	if !ok {
+		return stats, nil
+	}
+	for _, segment := range snapshot.Segments() {
+		full, live := uint64(segment.FullSize()), uint64(segment.LiveSize())
+		stats.Segments++
+		stats.Documents += live
+		stats.DeletedDocuments += full - live
+	}
+	if total := stats.Documents + stats.DeletedDocuments; total > 0 {
+		stats.DeletedRatio = float64(stats.DeletedDocuments) / float64(total)
+	}
+	return stats, nil
+}
+
+// Compaction returns the status of the last compaction of an index on this
+// node, or nil if it was not compacted since the node started
+func (s *IndexStore) Compaction(indexID string) *CompactionStatus {
+	return s.compactions.get(indexID)
+}
+
+// Compact merges the segments of an index into one, dropping deleted
+// documents. It blocks until the merge is done, and returns
+// ErrNothingToCompact if the index was a single segment by then.
+func (s *IndexStore) Compact(ctx context.Context, indexID, reason string) error {
+	index, _, err := s.GetIndex(indexID)
+	if err != nil {
+		return err
+	}
+	advanced, err := index.Advanced()
+	if err != nil {
+		return err
+	}
+	engine, ok := advanced.(*scorch.Scorch)
+	if !ok {
+		return fmt.Errorf("index %s does not support compaction", indexID)
+	}
+
+	if !s.compactions.start(indexID) {
+		return fmt.Errorf("index %s is already being compacted", indexID)
+	}
+
+	// The merge itself can't be throttled, so the job waits its turn before
+	// it starts
+	job := s.jobs.Start(jobs.KindCompaction, indexID)
+	defer job.Done()
+	started := time.Now()
+	err = job.Wait(ctx, 0)
+	if err == nil {
+		// bleve merges segments in the background too, so the index may
+		// have become a single segment since it was found due
+		var stats *SegmentStats
+		if stats, err = s.Segments(indexID); err == nil && stats.Segments < 2 {
+			err = ErrNothingToCompact
+		}
+	}
+	if err == nil {
+		err = engine.ForceMerge(ctx, &mergeplan.SingleSegmentMergePlanOptions)
+	}
+	s.compactions.finish(indexID, reason, started, err)
+	return err
+}
+
+// compactionReason returns why an index with stats should be compacted at
+// now, or "" if it should not. Deleted documents of a lone segment are only
+// dropped once it is merged with another, so it is never compacted.
+func compactionReason(settings models.CompactionSettings, stats *SegmentStats, now time.Time) string {
+	if settings.Disabled || stats.Segments < 2 || !inWindow(settings, now) {
+		return ""
+	}
+
+	switch {
+	case settings.MaxDeletedRatio > 0 && stats.DeletedRatio > settings.MaxDeletedRatio:
+		return fmt.Sprintf("deleted ratio %.2f above %.2f", stats.DeletedRatio, settings.MaxDeletedRatio)
+	case settings.MaxSegments > 0 && stats.Segments > settings.MaxSegments:
+		return fmt.Sprintf("%d segments above %d", stats.Segments, settings.MaxSegments)
+	}
+	return ""
+}
+
+// CompactDue compacts, one after another, the indexes that exceed their
+// compaction thresholds and are in their compaction window at now
+func (s *IndexStore) CompactDue(ctx context.Context, defaults models.CompactionSettings, now time.Time) map[string]error {
+	results := make(map[string]error)
+	for id, config := range s.GetAllConfigs() {
+		if ctx.Err() != nil {
+			break
+		}
+		stats, err := s.Segments(id)
+		if err != nil {
+			continue
+		}
+		reason := compactionReason(effectiveCompaction(defaults, config.Compaction), stats, now)
+		if reason == "" {
+			continue
+		}
+		results[id] = s.Compact(ctx, id, reason)
+	}
+	return results
+}
+
+// StartCompaction checks every interval for indexes to compact until the
+// store is closed. Outcomes are reported by Compaction. A zero interval
+// disables background compaction.
+func (s *IndexStore) StartCompaction(interval time.Duration, defaults models.CompactionSettings) {
+	if interval <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	s.stopCompaction = func() {
+		cancel()
+		<-done
+	}
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.CompactDue(ctx, defaults, now)
+			}
+		}
+	}()
+}
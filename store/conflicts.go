@@ -0,0 +1,196 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+
+	"bright/models"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// Fields recording which ingress last wrote a document and the source time
+// of that write, in Unix milliseconds. Ingresses set them on the documents
+// they write to indexes with a conflict policy.
+const (
+	OriginField          = "_origin"
+	OriginTimestampField = "_origin_ts"
+)
+
+// ConflictStats counts the writes of an ingress to documents last written by
+// another ingress
+type ConflictStats struct {
+	// Rejected writes lost to the stored document
+	Rejected int64 `json:"rejected"`
+
+	// Overwritten writes replaced the stored document
+	Overwritten int64 `json:"overwritten"`
+
+	// Merged writes were merged into the stored document
+	Merged int64 `json:"merged"`
+}
+
+// conflicts keeps the conflict counters of each index and ingress
+type conflicts struct {
+	stats map[string]map[string]*ConflictStats
+	mu    sync.Mutex
+}
+
+func newConflicts() *conflicts {
+	return &conflicts{stats: make(map[string]map[string]*ConflictStats)}
+}
+
+// get returns a copy of the counters of an ingress of an index
+func (c *conflicts) get(indexID, origin string) ConflictStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if stats, ok := c.stats[indexID][origin]; ok {
+		return *stats
+	}
+	return ConflictStats{}
+}
+
+// add adds counts to the counters of an ingress of an index
+func (c *conflicts) add(indexID, origin string, counts ConflictStats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	origins, ok := c.stats[indexID]
+	if !ok {
+		origins = make(map[string]*ConflictStats)
+		c.stats[indexID] = origins
+	}
+	stats, ok := origins[origin]
+	if !ok {
+		stats = &ConflictStats{}
+		origins[origin] = stats
+	}
+	stats.Rejected += counts.Rejected
+	stats.Overwritten += counts.Overwritten
+	stats.Merged += counts.Merged
+}
+
+// forget drops the counters of a deleted index
+func (c *conflicts) forget(indexID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.stats, indexID)
+}
+
+// ConflictStats returns the conflict counters of an ingress writing to an
+// index. They are kept in memory by each node.
+func (s *IndexStore) ConflictStats(indexID, ingressID string) ConflictStats {
+	return s.conflicts.get(indexID, ingressID)
+}
+
+// ValidateConflicts checks the conflict settings of an index
+func ValidateConflicts(settings *models.ConflictSettings) error {
+	if settings == nil {
+		return nil
+	}
+	switch settings.Policy {
+	case models.ConflictLastWriteWins, models.ConflictPriority, models.ConflictMerge:
+	default:
+		return fmt.Errorf("conflicts policy must be %q, %q or %q",
+			models.ConflictLastWriteWins, models.ConflictPriority, models.ConflictMerge)
+	}
+	if len(settings.Priorities) > 0 && settings.Policy != models.ConflictPriority {
+		return fmt.Errorf("conflicts priorities require the %q policy", models.ConflictPriority)
+	}
+	return nil
+}
+
+// resolveConflicts applies the conflict policy of an index to documents
+// written by ingresses, against the stored documents with the same IDs. It
+// returns the documents to index: rejected writes are left out and merged
+// ones replaced by the merged document. Must be called with the index lock
+// held, so the stored documents don't change in between.
+func (s *IndexStore) resolveConflicts(index bleve.Index, indexID string, settings *models.ConflictSettings, ids []string, docs []map[string]any) ([]string, []map[string]any, error) {
+	var origins []string
+	for _, doc := range docs {
+		if origin, _ := doc[OriginField].(string); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	if len(origins) == 0 {
+		return ids, docs, nil
+	}
+
+	searchRequest := bleve.NewSearchRequestOptions(bleve.NewDocIDQuery(ids), len(ids), 0, false)
+	searchRequest.Fields = []string{"*"}
+	searchResult, err := index.Search(searchRequest)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch documents: %w", err)
+	}
+	stored := make(map[string]map[string]any, len(searchResult.Hits))
+	for _, hit := range searchResult.Hits {
+		stored[hit.ID] = hit.Fields
+	}
+
+	counts := make(map[string]ConflictStats)
+	keptIDs := make([]string, 0, len(ids))
+	kept := make([]map[string]any, 0, len(docs))
+	for n, doc := range docs {
+		origin, _ := doc[OriginField].(string)
+		current, found := stored[ids[n]]
+		currentOrigin, _ := current[OriginField].(string)
+		if origin == "" || !found {
+			keptIDs = append(keptIDs, ids[n])
+			kept = append(kept, doc)
+			continue
+		}
+
+		stats := counts[origin]
+		switch {
+		case settings.Policy == models.ConflictMerge:
+			merged := make(map[string]any, len(current)+len(doc))
+			for field, value := range current {
+				merged[field] = value
+			}
+			for field, value := range doc {
+				merged[field] = value
+			}
+			doc = merged
+			if currentOrigin != origin {
+				stats.Merged++
+			}
+		case currentOrigin == "" || currentOrigin == origin:
+			// Nothing to reconcile with
+		case !wins(settings, origin, currentOrigin, doc, current):
+			stats.Rejected++
+			counts[origin] = stats
+			continue
+		default:
+			stats.Overwritten++
+		}
+		counts[origin] = stats
+
+		keptIDs = append(keptIDs, ids[n])
+		kept = append(kept, doc)
+	}
+
+	for origin, stats := range counts {
+		if stats != (ConflictStats{}) {
+			s.conflicts.add(indexID, origin, stats)
+		}
+	}
+	return keptIDs, kept, nil
+}
+
+// wins decides whether a write of origin replaces the document last written
+// by currentOrigin
+func wins(settings *models.ConflictSettings, origin, currentOrigin string, doc, current map[string]any) bool {
+	if settings.Policy == models.ConflictPriority {
+		priority, currentPriority := settings.Priorities[origin], settings.Priorities[currentOrigin]
+		if priority != currentPriority {
+			return priority > currentPriority
+		}
+	}
+
+	// Writes without a source timestamp can't be ordered, so they win
+	timestamp, ok := doc[OriginTimestampField].(float64)
+	currentTimestamp, currentOk := current[OriginTimestampField].(float64)
+	return !ok || !currentOk || timestamp >= currentTimestamp
+}
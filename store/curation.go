@@ -0,0 +1,86 @@
+package store
+
+import (
+	"bright/models"
+	"errors"
+	"fmt"
+)
+
+// ErrRuleNotFound is returned for unknown curation rules
+var ErrRuleNotFound = errors.New("curation rule not found")
+
+// CurationRegistry holds the curation rules of all indexes, kept in
+// curation.json. Searches apply the rules of their index whose query
+// matches.
+type CurationRegistry struct {
+	rules *fileRegistry[models.CurationRule]
+}
+
+// newCurationRegistry loads the curation rules persisted in dataDir
+func newCurationRegistry(dataDir string) (*CurationRegistry, error) {
+	rules, err := loadRegistry(dataDir, "curation.json", "curation rules",
+		func(rule *models.CurationRule) string { return storedQueryID(rule.IndexID, rule.ID) },
+		func(a, b *models.CurationRule) bool {
+			if a.IndexID != b.IndexID {
+				return a.IndexID < b.IndexID
+			}
+			return a.ID < b.ID
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &CurationRegistry{rules: rules}, nil
+}
+
+// Put creates or replaces a curation rule
+func (r *CurationRegistry) Put(rule *models.CurationRule) error {
+	return r.rules.put(rule)
+}
+
+// Get returns a curation rule
+func (r *CurationRegistry) Get(indexID, id string) (*models.CurationRule, error) {
+	rule, ok := r.rules.get(storedQueryID(indexID, id))
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrRuleNotFound, id)
+	}
+	return rule, nil
+}
+
+// Delete removes a curation rule
+func (r *CurationRegistry) Delete(indexID, id string) error {
+	found, err := r.rules.delete(storedQueryID(indexID, id))
+	if !found {
+		return fmt.Errorf("%w: %s", ErrRuleNotFound, id)
+	}
+	return err
+}
+
+// DeleteIndex removes all curation rules of an index
+func (r *CurationRegistry) DeleteIndex(indexID string) error {
+	return r.rules.update(func(rules map[string]*models.CurationRule) bool {
+		removed := false
+		for key, rule := range rules {
+			if rule.IndexID == indexID {
+				delete(rules, key)
+				removed = true
+			}
+		}
+		return removed
+	})
+}
+
+// List returns the curation rules of an index ordered by ID
+func (r *CurationRegistry) List(indexID string) []*models.CurationRule {
+	return r.rules.list(func(rule *models.CurationRule) bool { return rule.IndexID == indexID })
+}
+
+// Records returns all curation rules (for snapshotting)
+func (r *CurationRegistry) Records() []*models.CurationRule {
+	return r.rules.list(nil)
+}
+
+// Restore replaces all curation rules (used when restoring Raft snapshots)
+func (r *CurationRegistry) Restore(rules []*models.CurationRule) error {
+	return r.rules.restore(rules)
+}
@@ -0,0 +1,20 @@
+package store
+
+import "context"
+
+// EnrichDocuments adds fields from the index's enrichment service to
+// documents. It must run before a batch is journaled or replicated so every
+// node indexes the same documents. Enrichment is best effort: on error the
+// documents are left partly or not enriched and can still be indexed.
+func (s *IndexStore) EnrichDocuments(ctx context.Context, indexID string, documents []map[string]any) error {
+	_, config, err := s.GetIndex(indexID)
+	if err != nil {
+		return err
+	}
+
+	enricher := s.enrichers.Get(indexID, config.Enrichment)
+	if enricher == nil {
+		return nil
+	}
+	return enricher.Enrich(ctx, documents)
+}
@@ -0,0 +1,167 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// IdempotencyTTL is how long applied idempotency keys are remembered
+const IdempotencyTTL = 24 * time.Hour
+
+// MaxIdempotencyKeyLength is the longest accepted idempotency key
+const MaxIdempotencyKeyLength = 255
+
+// maxIdempotencyRecords bounds the registry; the oldest records are dropped first
+const maxIdempotencyRecords = 10000
+
+// ErrIdempotencyInProgress is returned when a request with the same key is still being applied
+var ErrIdempotencyInProgress = errors.New("a request with this idempotency key is in progress")
+
+// Idempotent operations
+const (
+	OperationAddDocuments    = "add_documents"
+	OperationDeleteDocuments = "delete_documents"
+	OperationDeleteDocument  = "delete_document"
+	OperationUpdateDocuments = "update_documents"
+)
+
+// IdempotencyRecord describes a write that was applied under an idempotency key
+type IdempotencyRecord struct {
+	IndexID   string    `json:"indexId"`
+	Key       string    `json:"key"`
+	Operation string    `json:"operation"`
+	Count     int       `json:"count"`
+	AppliedAt time.Time `json:"appliedAt"`
+}
+
+// IdempotencyRegistry remembers recently applied idempotency keys, in
+// idempotency.json, so retried writes are skipped. Keys are scoped per index
+// and forgotten after IdempotencyTTL. Single-node writes reserve their key
+// while they run, so concurrent retries wait for the first one.
+type IdempotencyRegistry struct {
+	records  *fileRegistry[IdempotencyRecord]
+	inflight map[string]struct{}
+}
+
+// newIdempotencyRegistry loads the registry persisted in dataDir
+func newIdempotencyRegistry(dataDir string) (*IdempotencyRegistry, error) {
+	records, err := loadRegistry(dataDir, "idempotency.json", "idempotency keys",
+		func(record *IdempotencyRecord) string { return idempotencyID(record.IndexID, record.Key) },
+		func(a, b *IdempotencyRecord) bool { return a.AppliedAt.Before(b.AppliedAt) },
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &IdempotencyRegistry{records: records, inflight: make(map[string]struct{})}, nil
+}
+
+// idempotencyID scopes a client key to an index
+func idempotencyID(indexID, key string) string {
+	return indexID + "\x00" + key
+}
+
+// ValidateIdempotencyKey checks a client-supplied key
+func ValidateIdempotencyKey(key string) error {
+	if len(key) > MaxIdempotencyKeyLength {
+		return fmt.Errorf("idempotency key must be at most %d characters", MaxIdempotencyKeyLength)
+	}
+	return nil
+}
+
+// Lookup returns the record of a key applied within IdempotencyTTL of now.
+// The FSM passes the append time of the log entry being applied, so that
+// every node agrees whatever its clock.
+func (r *IdempotencyRegistry) Lookup(indexID, key string, now time.Time) (*IdempotencyRecord, bool) {
+	record, ok := r.records.get(idempotencyID(indexID, key))
+	if !ok || now.Sub(record.AppliedAt) > IdempotencyTTL {
+		return nil, false
+	}
+	return record, true
+}
+
+// Reserve marks a key as in flight. If the key was already applied its
+// record is returned instead; if it is in flight ErrIdempotencyInProgress is
+// returned. A successful reservation must be followed by Complete or Release.
+func (r *IdempotencyRegistry) Reserve(indexID, key string) (*IdempotencyRecord, error) {
+	r.records.mu.Lock()
+	defer r.records.mu.Unlock()
+
+	id := idempotencyID(indexID, key)
+	if record, ok := r.records.records[id]; ok && time.Since(record.AppliedAt) <= IdempotencyTTL {
+		return record, nil
+	}
+	if _, ok := r.inflight[id]; ok {
+		return nil, ErrIdempotencyInProgress
+	}
+
+	r.inflight[id] = struct{}{}
+	return nil, nil
+}
+
+// Release drops a reservation after a failed write so the key can be retried
+func (r *IdempotencyRegistry) Release(indexID, key string) {
+	r.records.mu.Lock()
+	defer r.records.mu.Unlock()
+
+	delete(r.inflight, idempotencyID(indexID, key))
+}
+
+// Complete records that a single-node write was applied under a key at the
+// given time, drops records that expired relative to it and persists the
+// registry
+func (r *IdempotencyRegistry) Complete(record IdempotencyRecord) error {
+	r.records.mu.Lock()
+	defer r.records.mu.Unlock()
+
+	r.recordLocked(record)
+	return r.records.saveLocked()
+}
+
+// Apply records that a write was applied from the Raft log under a key.
+// The registry isn't persisted: snapshots carry it, and the entries after
+// the latest snapshot are applied again when the node restarts.
+func (r *IdempotencyRegistry) Apply(record IdempotencyRecord) {
+	r.records.mu.Lock()
+	defer r.records.mu.Unlock()
+
+	r.recordLocked(record)
+}
+
+// recordLocked adds a record and drops those that expired relative to it
+// (must be called with lock held)
+func (r *IdempotencyRegistry) recordLocked(record IdempotencyRecord) {
+	id := idempotencyID(record.IndexID, record.Key)
+	delete(r.inflight, id)
+	r.records.records[id] = &record
+
+	r.prune(record.AppliedAt)
+}
+
+// prune drops expired and excess records (must be called with lock held)
+func (r *IdempotencyRegistry) prune(now time.Time) {
+	for id, record := range r.records.records {
+		if now.Sub(record.AppliedAt) > IdempotencyTTL {
+			delete(r.records.records, id)
+		}
+	}
+
+	if len(r.records.records) <= maxIdempotencyRecords {
+		return
+	}
+
+	records := r.records.listLocked(nil)
+	for _, record := range records[:len(records)-maxIdempotencyRecords] {
+		delete(r.records.records, idempotencyID(record.IndexID, record.Key))
+	}
+}
+
+// Records returns all records ordered by application time (for snapshotting)
+func (r *IdempotencyRegistry) Records() []*IdempotencyRecord {
+	return r.records.list(nil)
+}
+
+// Restore replaces all records (used when restoring Raft snapshots)
+func (r *IdempotencyRegistry) Restore(records []*IdempotencyRecord) error {
+	return r.records.restore(records)
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"bright/models"
+	"fmt"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/index/scorch"
+	"github.com/blevesearch/bleve/v2/index/upsidedown"
+	"github.com/blevesearch/bleve/v2/index/upsidedown/store/boltdb"
+	"github.com/blevesearch/bleve/v2/index/upsidedown/store/gtreap"
+	"github.com/blevesearch/bleve/v2/mapping"
+)
+
+// Bleve index types
+const (
+	IndexTypeScorch     = scorch.Name
+	IndexTypeUpsideDown = upsidedown.Name
+)
+
+// Key/value stores. Upside_down indexes keep their data in BoltDB files or
+// in memory; scorch writes its own segment files, or keeps them in memory.
+const (
+	KVStoreBoltDB = boltdb.Name
+	KVStoreMemory = "memory"
+)
+
+// ValidateIndexType checks the index type and key/value store settings of
+// an index. Either may be empty for the store's default.
+func ValidateIndexType(indexType, kvStore string) error {
+	switch indexType {
+	case "", IndexTypeScorch, IndexTypeUpsideDown:
+	default:
+		return fmt.Errorf("indexType must be %s or %s", IndexTypeScorch, IndexTypeUpsideDown)
+	}
+	switch kvStore {
+	case "", KVStoreBoltDB, KVStoreMemory:
+	default:
+		return fmt.Errorf("kvStore must be %s or %s", KVStoreBoltDB, KVStoreMemory)
+	}
+	if indexType == IndexTypeScorch && kvStore == KVStoreBoltDB {
+		return fmt.Errorf("scorch indexes don't use %s, only %s can be chosen", KVStoreBoltDB, KVStoreMemory)
+	}
+	return nil
+}
+
+// SetIndexTypeDefaults sets the index type and key/value store of indexes
+// created without their own. Empty values keep scorch on disk.
+func (s *IndexStore) SetIndexTypeDefaults(indexType, kvStore string) error {
+	if err := ValidateIndexType(indexType, kvStore); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.defaultIndexType = indexType
+	s.defaultKVStore = kvStore
+	return nil
+}
+
+// resolveIndexType fills in the index type and key/value store of a new
+// index from the store's defaults, so that they are kept with its settings
+// (must be called with the store lock held)
+func (s *IndexStore) resolveIndexType(config *models.IndexConfig) error {
+	if config.IndexType == "" {
+		config.IndexType = s.defaultIndexType
+		if config.IndexType == "" {
+			config.IndexType = IndexTypeScorch
+		}
+	}
+	if config.KVStore == "" && (config.IndexType == IndexTypeUpsideDown || s.defaultKVStore == KVStoreMemory) {
+		config.KVStore = s.defaultKVStore
+		if config.KVStore == "" {
+			config.KVStore = KVStoreBoltDB
+		}
+	}
+	return ValidateIndexType(config.IndexType, config.KVStore)
+}
+
+// inMemory reports whether an index keeps its data in memory only
+func inMemory(config *models.IndexConfig) bool {
+	return config != nil && config.KVStore == KVStoreMemory
+}
+
+// newBleveIndex creates a bleve index of the type and key/value store of
+// its settings. In-memory indexes have no directory.
+func newBleveIndex(path string, config *models.IndexConfig, indexMapping mapping.IndexMapping) (bleve.Index, error) {
+	indexType := config.IndexType
+	if indexType == "" {
+		indexType = IndexTypeScorch
+	}
+
+	kvStore := config.KVStore
+	switch {
+	case kvStore == KVStoreMemory:
+		path = ""
+		kvStore = gtreap.Name
+	case kvStore == "":
+		kvStore = bleve.Config.DefaultKVStore
+	}
+	return bleve.NewUsing(path, indexMapping, indexType, kvStore, nil)
+}
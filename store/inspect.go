@@ -0,0 +1,238 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"bright/models"
+	"bright/persist"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/bytedance/sonic"
+)
+
+// Index inspection statuses
+const (
+	InspectStatusOK         = "ok"
+	InspectStatusMissing    = "missing"
+	InspectStatusUnreadable = "unreadable"
+	InspectStatusMismatch   = "mismatch"
+)
+
+// IndexReport describes the on-disk state of a single index
+type IndexReport struct {
+	ID         string `json:"id"`
+	PrimaryKey string `json:"primaryKey"`
+	Path       string `json:"path"`
+	SizeBytes  int64  `json:"sizeBytes"`
+	DocCount   uint64 `json:"docCount"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+
+	// How the index on disk differs from its configuration
+	Mismatches []string `json:"mismatches,omitempty"`
+
+	// True if the index was created with another mapping than the one
+	// this build makes from its configuration; a reindex rebuilds it
+	MappingChanged bool `json:"mappingChanged,omitempty"`
+}
+
+// DataDirReport describes the on-disk state of a data directory
+type DataDirReport struct {
+	DataDir     string        `json:"dataDir"`
+	ConfigFile  string        `json:"configFile"`
+	ConfigError string        `json:"configError,omitempty"`
+	Indexes     []IndexReport `json:"indexes"`
+
+	// True if configs.json failed its checksum and the previous generation was read
+	ConfigRecovered bool `json:"configRecovered,omitempty"`
+
+	// Directories that look like bleve indexes but have no configuration
+	OrphanDirectories []string `json:"orphanDirectories,omitempty"`
+}
+
+// Inspect examines a data directory without loading it into a store.
+// Indexes are opened read-only, so it must not be used on a directory
+// owned by a running server.
+func Inspect(dataDir string) (*DataDirReport, error) {
+	if _, err := os.Stat(dataDir); err != nil {
+		return nil, err
+	}
+
+	report := &DataDirReport{
+		DataDir:    dataDir,
+		ConfigFile: filepath.Join(dataDir, "configs.json"),
+		Indexes:    []IndexReport{},
+	}
+
+	configs := make(map[string]*models.IndexConfig)
+	if data, recovered, err := persist.ReadFile(report.ConfigFile); err != nil {
+		if !os.IsNotExist(err) {
+			report.ConfigError = err.Error()
+		}
+	} else if err := sonic.Unmarshal(data, &configs); err != nil {
+		report.ConfigError = err.Error()
+	} else {
+		report.ConfigRecovered = recovered
+	}
+
+	for id, config := range configs {
+		indexPath := filepath.Join(dataDir, id)
+		indexReport := IndexReport{
+			ID:     id,
+			Path:   indexPath,
+			Status: InspectStatusOK,
+		}
+		if config != nil {
+			indexReport.PrimaryKey = config.PrimaryKey
+		}
+
+		if _, err := os.Stat(indexPath); os.IsNotExist(err) {
+			indexReport.Status = InspectStatusMissing
+			report.Indexes = append(report.Indexes, indexReport)
+			continue
+		}
+
+		indexReport.SizeBytes = dirSize(indexPath)
+
+		index, err := bleve.OpenUsing(indexPath, map[string]any{
+			"read_only":    true,
+			"bolt_timeout": "1s",
+		})
+		if err != nil {
+			indexReport.Status = InspectStatusUnreadable
+			indexReport.Error = err.Error()
+		} else {
+			if count, err := index.DocCount(); err != nil {
+				indexReport.Status = InspectStatusUnreadable
+				indexReport.Error = err.Error()
+			} else {
+				indexReport.DocCount = count
+			}
+			if config != nil {
+				compareIndex(&indexReport, index, config)
+				if len(indexReport.Mismatches) > 0 && indexReport.Status == InspectStatusOK {
+					indexReport.Status = InspectStatusMismatch
+				}
+			}
+			index.Close()
+		}
+
+		report.Indexes = append(report.Indexes, indexReport)
+	}
+
+	sort.Slice(report.Indexes, func(i, j int) bool {
+		return report.Indexes[i].ID < report.Indexes[j].ID
+	})
+
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, known := configs[entry.Name()]; known {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dataDir, entry.Name(), "index_meta.json")); err == nil {
+			report.OrphanDirectories = append(report.OrphanDirectories, entry.Name())
+		}
+	}
+
+	return report, nil
+}
+
+// compareIndex reports how an index on disk differs from its configuration:
+// its index type and key/value store as recorded in index_meta.json, and the
+// mapping it was created with against the one the configuration builds
+func compareIndex(report *IndexReport, index bleve.Index, config *models.IndexConfig) {
+	var meta struct {
+		Storage   string `json:"storage"`
+		IndexType string `json:"index_type"`
+	}
+	if data, err := os.ReadFile(filepath.Join(report.Path, "index_meta.json")); err != nil {
+		report.Mismatches = append(report.Mismatches, fmt.Sprintf("index_meta.json is unreadable: %s", err))
+	} else if err := json.Unmarshal(data, &meta); err != nil {
+		report.Mismatches = append(report.Mismatches, fmt.Sprintf("index_meta.json is invalid: %s", err))
+	} else {
+		indexType := config.IndexType
+		if indexType == "" {
+			indexType = IndexTypeScorch
+		}
+		if meta.IndexType != indexType {
+			report.Mismatches = append(report.Mismatches, fmt.Sprintf("index type is %s, configured %s", meta.IndexType, indexType))
+		} else if indexType == IndexTypeUpsideDown {
+			// Scorch records a store it does not use
+			kvStore := config.KVStore
+			if kvStore == "" {
+				kvStore = KVStoreBoltDB
+			}
+			if meta.Storage != kvStore {
+				report.Mismatches = append(report.Mismatches, fmt.Sprintf("key/value store is %s, configured %s", meta.Storage, kvStore))
+			}
+		}
+	}
+
+	expected, err := buildIndexMapping(config)
+	if err != nil {
+		report.Mismatches = append(report.Mismatches, fmt.Sprintf("configured mapping is invalid: %s", err))
+		return
+	}
+	if fields := mappingDifferences(expected, index.Mapping()); len(fields) > 0 {
+		report.Mismatches = append(report.Mismatches, fmt.Sprintf("mapping differs from the configured one in %v", fields))
+		report.MappingChanged = true
+	}
+}
+
+// mappingDifferences returns the sorted top-level keys whose JSON differs
+// between two mappings
+func mappingDifferences(expected, actual any) []string {
+	expectedFields, actualFields := mappingFields(expected), mappingFields(actual)
+
+	var differences []string
+	for key, value := range expectedFields {
+		if !reflect.DeepEqual(value, actualFields[key]) {
+			differences = append(differences, key)
+		}
+	}
+	for key := range actualFields {
+		if _, ok := expectedFields[key]; !ok {
+			differences = append(differences, key)
+		}
+	}
+	sort.Strings(differences)
+	return differences
+}
+
+// mappingFields decodes the JSON form of a mapping into its top-level keys
+func mappingFields(m any) map[string]any {
+	fields := map[string]any{}
+	if data, err := json.Marshal(m); err == nil {
+		json.Unmarshal(data, &fields)
+	}
+	return fields
+}
+
+// dirSize returns the total size of regular files under path
+func dirSize(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
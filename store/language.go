@@ -0,0 +1,229 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"bright/models"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis"
+	"github.com/blevesearch/bleve/v2/analysis/lang/da"
+	"github.com/blevesearch/bleve/v2/analysis/lang/de"
+	"github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/analysis/lang/es"
+	"github.com/blevesearch/bleve/v2/analysis/lang/fi"
+	"github.com/blevesearch/bleve/v2/analysis/lang/fr"
+	"github.com/blevesearch/bleve/v2/analysis/lang/hu"
+	"github.com/blevesearch/bleve/v2/analysis/lang/it"
+	"github.com/blevesearch/bleve/v2/analysis/lang/nl"
+	"github.com/blevesearch/bleve/v2/analysis/lang/no"
+	"github.com/blevesearch/bleve/v2/analysis/lang/pt"
+	"github.com/blevesearch/bleve/v2/analysis/lang/ro"
+	"github.com/blevesearch/bleve/v2/analysis/lang/ru"
+	"github.com/blevesearch/bleve/v2/analysis/lang/sv"
+	"github.com/blevesearch/bleve/v2/analysis/lang/tr"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/bytedance/sonic"
+)
+
+// LanguageField holds the detected language of a document
+const LanguageField = "_lang"
+
+// maxDetectionWords bounds how much text is inspected per document
+const maxDetectionWords = 1000
+
+// minLanguageHits is the number of stop words needed to detect a language
+const minLanguageHits = 2
+
+// languageStopWords are the stop word lists detection is based on. The
+// languages double as bleve analyzer names.
+var languageStopWords = map[string][]byte{
+	da.AnalyzerName: da.DanishStopWords,
+	de.AnalyzerName: de.GermanStopWords,
+	en.AnalyzerName: en.EnglishStopWords,
+	es.AnalyzerName: es.SpanishStopWords,
+	fi.AnalyzerName: fi.FinnishStopWords,
+	fr.AnalyzerName: fr.FrenchStopWords,
+	hu.AnalyzerName: hu.HungarianStopWords,
+	it.AnalyzerName: it.ItalianStopWords,
+	nl.AnalyzerName: nl.DutchStopWords,
+	no.AnalyzerName: no.NorwegianStopWords,
+	pt.AnalyzerName: pt.PortugueseStopWords,
+	ro.AnalyzerName: ro.RomanianStopWords,
+	ru.AnalyzerName: ru.RussianStopWords,
+	sv.AnalyzerName: sv.SwedishStopWords,
+	tr.AnalyzerName: tr.TurkishStopWords,
+}
+
+// stopWords maps each stop word to the languages it belongs to
+var stopWords = func() map[string][]string {
+	words := make(map[string][]string)
+	for language, list := range languageStopWords {
+		tokens := analysis.NewTokenMap()
+		if err := tokens.LoadBytes(list); err != nil {
+			panic(err)
+		}
+		for token := range tokens {
+			words[token] = append(words[token], language)
+		}
+	}
+	return words
+}()
+
+// DetectLanguage returns the candidate language whose stop words occur most
+// often in text, or "" when no language clearly stands out. All supported
+// languages are candidates when none are given.
+func DetectLanguage(text string, candidates []string) string {
+	hits := make(map[string]int)
+	words := 0
+	for word := range strings.FieldsFuncSeq(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	}) {
+		if words++; words > maxDetectionWords {
+			break
+		}
+		for _, language := range stopWords[word] {
+			if len(candidates) == 0 || slices.Contains(candidates, language) {
+				hits[language]++
+			}
+		}
+	}
+
+	best, bestHits, tied := "", 0, false
+	for language, n := range hits {
+		switch {
+		case n > bestHits:
+			best, bestHits, tied = language, n, false
+		case n == bestHits:
+			tied = true
+		}
+	}
+	if bestHits < minLanguageHits || tied {
+		return ""
+	}
+	return best
+}
+
+// tagLanguage stores the detected language of a document in LanguageField,
+// unless the document already names its language
+func tagLanguage(settings *models.LanguageSettings, doc map[string]any) {
+	if _, ok := doc[LanguageField]; ok {
+		return
+	}
+
+	var text strings.Builder
+	for _, field := range settings.Fields {
+		appendText(&text, lookupField(doc, field))
+	}
+
+	language := DetectLanguage(text.String(), settings.Languages)
+	if language == "" {
+		language = settings.Default
+	}
+	if language != "" {
+		doc[LanguageField] = language
+	}
+}
+
+// retagLanguage detects the language of a document again when an update
+// changed one of the detection fields without setting the language itself
+func retagLanguage(settings *models.LanguageSettings, doc, update map[string]any) {
+	if _, ok := update[LanguageField]; !ok {
+		for _, field := range settings.Fields {
+			if _, changed := update[strings.Split(field, ".")[0]]; changed {
+				delete(doc, LanguageField)
+				break
+			}
+		}
+	}
+	tagLanguage(settings, doc)
+}
+
+// lookupField returns the value of a dotted field in nested objects
+func lookupField(doc map[string]any, field string) any {
+	if value, ok := doc[field]; ok {
+		return value
+	}
+	head, rest, nested := strings.Cut(field, ".")
+	if !nested {
+		return nil
+	}
+	object, _ := doc[head].(map[string]any)
+	return lookupField(object, rest)
+}
+
+func appendText(text *strings.Builder, value any) {
+	switch v := value.(type) {
+	case string:
+		text.WriteString(v)
+		text.WriteByte(' ')
+	case []any:
+		for _, item := range v {
+			appendText(text, item)
+		}
+	}
+}
+
+// addLanguageMappings adds one document type per language, selected by the
+// document's LanguageField. Each is a copy of the default mapping where the
+// detection fields are also indexed as <field>_<language> with the
+// language's analyzer.
+func addLanguageMappings(indexMapping *mapping.IndexMappingImpl, settings *models.LanguageSettings) error {
+	if len(settings.Fields) == 0 {
+		return fmt.Errorf("language detection needs at least one field")
+	}
+	for _, field := range settings.Fields {
+		if field == "" || strings.HasPrefix(field, "_") {
+			return fmt.Errorf("invalid language field %q", field)
+		}
+	}
+
+	languages := settings.Languages
+	if len(languages) == 0 {
+		for language := range languageStopWords {
+			languages = append(languages, language)
+		}
+		slices.Sort(languages)
+	}
+	for _, language := range languages {
+		if _, ok := languageStopWords[language]; !ok {
+			return fmt.Errorf("unsupported language %q", language)
+		}
+	}
+	if settings.Default != "" && !slices.Contains(languages, settings.Default) {
+		return fmt.Errorf("default language %q is not a detected language", settings.Default)
+	}
+
+	defaultJSON, err := sonic.Marshal(indexMapping.DefaultMapping)
+	if err != nil {
+		return err
+	}
+
+	indexMapping.TypeField = LanguageField
+	for _, language := range languages {
+		typeMapping := bleve.NewDocumentMapping()
+		if err := sonic.Unmarshal(defaultJSON, typeMapping); err != nil {
+			return err
+		}
+
+		for _, field := range settings.Fields {
+			property := documentMappingAt(typeMapping, field)
+			if len(property.Fields) == 0 {
+				addDynamicFieldMappings(property)
+			}
+
+			segments := strings.Split(field, ".")
+			text := bleve.NewTextFieldMapping()
+			text.Name = segments[len(segments)-1] + "_" + language
+			text.Analyzer = language
+			text.Store = false
+			property.AddFieldMapping(text)
+		}
+
+		indexMapping.AddDocumentMapping(language, typeMapping)
+	}
+	return nil
+}
@@ -0,0 +1,623 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"bright/attach"
+	"bright/enrich"
+	"bright/models"
+	"bright/search"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/custom"
+	keywordanalyzer "github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/v2/analysis/char/asciifolding"
+	"github.com/blevesearch/bleve/v2/analysis/lang/da"
+	"github.com/blevesearch/bleve/v2/analysis/lang/de"
+	"github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/analysis/lang/es"
+	"github.com/blevesearch/bleve/v2/analysis/lang/fi"
+	"github.com/blevesearch/bleve/v2/analysis/lang/fr"
+	"github.com/blevesearch/bleve/v2/analysis/lang/hu"
+	"github.com/blevesearch/bleve/v2/analysis/lang/it"
+	"github.com/blevesearch/bleve/v2/analysis/lang/nl"
+	"github.com/blevesearch/bleve/v2/analysis/lang/no"
+	"github.com/blevesearch/bleve/v2/analysis/lang/pt"
+	"github.com/blevesearch/bleve/v2/analysis/lang/ro"
+	"github.com/blevesearch/bleve/v2/analysis/lang/ru"
+	"github.com/blevesearch/bleve/v2/analysis/lang/sv"
+	"github.com/blevesearch/bleve/v2/analysis/lang/tr"
+	"github.com/blevesearch/bleve/v2/analysis/token/edgengram"
+	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/v2/analysis/token/ngram"
+	"github.com/blevesearch/bleve/v2/analysis/token/porter"
+	"github.com/blevesearch/bleve/v2/analysis/token/shingle"
+	"github.com/blevesearch/bleve/v2/analysis/token/stop"
+	"github.com/blevesearch/bleve/v2/analysis/token/truncate"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/single"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/unicode"
+	"github.com/blevesearch/bleve/v2/analysis/tokenmap"
+	"github.com/blevesearch/bleve/v2/mapping"
+)
+
+// sortAnalyzer indexes whole lowercased values for sorting
+const sortAnalyzer = "sort_keyword"
+
+// textAnalyzer is the default analyzer of indexes with text analysis
+// settings
+const textAnalyzer = "index.text"
+
+// maxEdgeNgram bounds edge n-gram lengths to keep index growth predictable
+const maxEdgeNgram = 20
+
+// maxNgram bounds n-gram lengths, which grow the index faster than edge
+// n-grams
+const maxNgram = 10
+
+// stemmers maps stemmer languages to bleve token filters
+var stemmers = map[string]string{
+	"da":     da.SnowballStemmerName,
+	"de":     de.SnowballStemmerName,
+	"en":     en.SnowballStemmerName,
+	"es":     es.SnowballStemmerName,
+	"fi":     fi.SnowballStemmerName,
+	"fr":     fr.SnowballStemmerName,
+	"hu":     hu.SnowballStemmerName,
+	"it":     it.SnowballStemmerName,
+	"nl":     nl.SnowballStemmerName,
+	"no":     no.SnowballStemmerName,
+	"porter": porter.Name,
+	"pt":     pt.LightStemmerName,
+	"ro":     ro.SnowballStemmerName,
+	"ru":     ru.SnowballStemmerName,
+	"sv":     sv.SnowballStemmerName,
+	"tr":     tr.SnowballStemmerName,
+}
+
+// stopFilters maps stop word languages to bleve token filters
+var stopFilters = map[string]string{
+	"da": da.StopName,
+	"de": de.StopName,
+	"en": en.StopName,
+	"es": es.StopName,
+	"fi": fi.StopName,
+	"fr": fr.StopName,
+	"hu": hu.StopName,
+	"it": it.StopName,
+	"nl": nl.StopName,
+	"no": no.StopName,
+	"pt": pt.StopName,
+	"ro": ro.StopName,
+	"ru": ru.StopName,
+	"sv": sv.StopName,
+	"tr": tr.StopName,
+}
+
+// ValidateIndexSettings checks an index config, including that its mapping
+// can be built
+func ValidateIndexSettings(config *models.IndexConfig) error {
+	if config.MaxLimit < 0 || config.MaxTotalHits < 0 {
+		return fmt.Errorf("maxLimit and maxTotalHits must not be negative")
+	}
+	if _, err := ParseRefreshInterval(config); err != nil {
+		return err
+	}
+	if err := search.ValidateDefaults(config); err != nil {
+		return err
+	}
+	if config.Enrichment != nil {
+		if err := enrich.Validate(config.Enrichment); err != nil {
+			return err
+		}
+	}
+	if err := attach.Validate(config.Attachments); err != nil {
+		return err
+	}
+	if err := ValidateNumericFields(config.NumericFields); err != nil {
+		return err
+	}
+	if err := ValidateCompaction(config.Compaction); err != nil {
+		return err
+	}
+	if err := ValidateConflicts(config.Conflicts); err != nil {
+		return err
+	}
+	if err := ValidateIndexType(config.IndexType, config.KVStore); err != nil {
+		return err
+	}
+	if err := search.ValidateSynonyms(config.Synonyms); err != nil {
+		return err
+	}
+	if err := search.ValidateSearchableAttributes(config.SearchableAttributes); err != nil {
+		return err
+	}
+	if err := search.ValidateExperiment(config.Experiment); err != nil {
+		return err
+	}
+
+	indexMapping, err := buildIndexMapping(config)
+	if err != nil {
+		return err
+	}
+	return indexMapping.Validate()
+}
+
+// buildIndexMapping builds the bleve mapping of an index from its config
+func buildIndexMapping(config *models.IndexConfig) (*mapping.IndexMappingImpl, error) {
+	indexMapping := bleve.NewIndexMapping()
+	defaultMapping := indexMapping.DefaultMapping
+
+	for _, attr := range config.ExcludeAttributes {
+		disabledMapping := bleve.NewDocumentDisabledMapping()
+		defaultMapping.AddSubDocumentMapping(attr, disabledMapping)
+	}
+
+	if err := addAnalysisComponents(indexMapping, config.Analysis); err != nil {
+		return nil, err
+	}
+	if config.TextAnalysis != nil {
+		if err := addTextAnalyzer(indexMapping, config.TextAnalysis); err != nil {
+			return nil, fmt.Errorf("textAnalysis: %w", err)
+		}
+		indexMapping.DefaultAnalyzer = textAnalyzer
+	}
+
+	fields := fieldSettings(config)
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == "" || strings.HasPrefix(name, "_") {
+			return nil, fmt.Errorf("invalid field name %q", name)
+		}
+
+		analyzer, err := addFieldAnalyzer(indexMapping, name, fields[name], config.Analysis)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", name, err)
+		}
+
+		// The field is searched with its own analyzer and kept out of _all,
+		// where the original text is indexed with the default analyzer so
+		// unqualified queries keep matching it
+		text := bleve.NewTextFieldMapping()
+		text.Analyzer = analyzer
+		text.IncludeInAll = false
+
+		all := bleve.NewTextFieldMapping()
+		all.Name = "_all." + name
+		all.Store = false
+		all.DocValues = false
+
+		// Non-text values in the field are indexed as they would be
+		// dynamically, and date strings as dates too, for range filters
+		datetime := bleve.NewDateTimeFieldMapping()
+		datetime.Store = false
+		datetime.IncludeInAll = false
+
+		property := documentMappingAt(defaultMapping, name)
+		property.AddFieldMapping(text)
+		property.AddFieldMapping(all)
+		property.AddFieldMapping(bleve.NewNumericFieldMapping())
+		property.AddFieldMapping(bleve.NewBooleanFieldMapping())
+		property.AddFieldMapping(datetime)
+
+		if fields[name].Keyword {
+			addKeywordField(property, name)
+		}
+
+		if fields[name].SearchAsYouType {
+			if fields[name].Exact {
+				return nil, fmt.Errorf("field %s: exact fields cannot be searched as you type", name)
+			}
+			if err := addAsYouTypeFields(indexMapping, property, name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(config.SortableAttributes) > 0 {
+		err := indexMapping.AddCustomAnalyzer(sortAnalyzer, map[string]any{
+			"type":          custom.Name,
+			"tokenizer":     single.Name,
+			"token_filters": []any{lowercase.Name},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, attr := range config.SortableAttributes {
+		if attr == "" || strings.HasPrefix(attr, "_") {
+			return nil, fmt.Errorf("invalid sortable attribute %q", attr)
+		}
+
+		// Explicit mappings replace dynamic indexing, so keep it for plain fields
+		property := documentMappingAt(defaultMapping, attr)
+		if len(property.Fields) == 0 {
+			addDynamicFieldMappings(property)
+		}
+
+		// Whole values are indexed under a separate name so sorting never
+		// depends on how the searchable text is tokenized
+		keyword := bleve.NewTextFieldMapping()
+		keyword.Name = search.SortFieldPrefix + attr
+		keyword.Analyzer = sortAnalyzer
+		keyword.Store = false
+		keyword.IncludeInAll = false
+		keyword.IncludeTermVectors = false
+
+		numeric := bleve.NewNumericFieldMapping()
+		numeric.Name = keyword.Name
+		numeric.Store = false
+		numeric.IncludeInAll = false
+
+		property.AddFieldMapping(keyword)
+		property.AddFieldMapping(numeric)
+	}
+
+	for _, attr := range config.GeoAttributes {
+		if attr == "" || strings.HasPrefix(attr, "_") {
+			return nil, fmt.Errorf("invalid geo attribute %q", attr)
+		}
+		if _, ok := fields[attr]; ok {
+			return nil, fmt.Errorf("geo attribute %s cannot have field settings", attr)
+		}
+
+		// Points given as objects keep their lat/lon values indexed as numbers
+		property := documentMappingAt(defaultMapping, attr)
+		property.AddFieldMapping(bleve.NewGeoPointFieldMapping())
+	}
+
+	for field, settings := range config.Vectors {
+		if err := search.ValidateVectorSettings(field, settings); err != nil {
+			return nil, err
+		}
+		if _, ok := fields[field]; ok || slices.Contains(config.GeoAttributes, field) {
+			return nil, fmt.Errorf("vector field %s cannot have field settings or be a geo attribute", field)
+		}
+		fieldMapping, err := vectorFieldMapping(settings)
+		if err != nil {
+			return nil, err
+		}
+		documentMappingAt(defaultMapping, field).AddFieldMapping(fieldMapping)
+	}
+
+	// Language types copy the default mapping, so they are added last
+	if config.Language != nil {
+		if err := addLanguageMappings(indexMapping, config.Language); err != nil {
+			return nil, err
+		}
+	}
+
+	return indexMapping, nil
+}
+
+// addTextAnalyzer registers the default analyzer of an index: words are
+// lowercased, stop words dropped, then stemmed
+func addTextAnalyzer(indexMapping *mapping.IndexMappingImpl, settings *models.TextAnalysisSettings) error {
+	tokenFilters := []any{}
+	lower := settings.Lowercase == nil || *settings.Lowercase
+	if lower {
+		tokenFilters = append(tokenFilters, lowercase.Name)
+	}
+
+	if settings.StopWordsLanguage != "" {
+		stop, ok := stopFilters[settings.StopWordsLanguage]
+		if !ok {
+			return fmt.Errorf("unsupported stop words language %q", settings.StopWordsLanguage)
+		}
+		tokenFilters = append(tokenFilters, stop)
+	}
+
+	if len(settings.StopWords) > 0 {
+		words := make([]any, 0, len(settings.StopWords))
+		for _, word := range settings.StopWords {
+			if lower {
+				word = strings.ToLower(word)
+			}
+			words = append(words, word)
+		}
+		if err := indexMapping.AddCustomTokenMap(textAnalyzer+".stop_words", map[string]any{
+			"type":   tokenmap.Name,
+			"tokens": words,
+		}); err != nil {
+			return err
+		}
+		if err := indexMapping.AddCustomTokenFilter(textAnalyzer+".stop", map[string]any{
+			"type":           stop.Name,
+			"stop_token_map": textAnalyzer + ".stop_words",
+		}); err != nil {
+			return err
+		}
+		tokenFilters = append(tokenFilters, textAnalyzer+".stop")
+	}
+
+	if settings.Stemmer != "" {
+		stemmer, ok := stemmers[settings.Stemmer]
+		if !ok {
+			return fmt.Errorf("unsupported stemmer %q", settings.Stemmer)
+		}
+		tokenFilters = append(tokenFilters, stemmer)
+	}
+
+	return indexMapping.AddCustomAnalyzer(textAnalyzer, map[string]any{
+		"type":          custom.Name,
+		"tokenizer":     unicode.Name,
+		"token_filters": tokenFilters,
+	})
+}
+
+// addDynamicFieldMappings adds the mappings dynamic indexing would pick for
+// each type of value
+func addDynamicFieldMappings(property *mapping.DocumentMapping) {
+	// Date strings are also kept as text, so only one of them is stored
+	datetime := bleve.NewDateTimeFieldMapping()
+	datetime.Store = false
+	datetime.IncludeInAll = false
+
+	property.AddFieldMapping(bleve.NewTextFieldMapping())
+	property.AddFieldMapping(bleve.NewNumericFieldMapping())
+	property.AddFieldMapping(bleve.NewBooleanFieldMapping())
+	property.AddFieldMapping(datetime)
+}
+
+// fieldSettings returns the analysis settings of every configured field.
+// Filterable attributes without settings of their own are matched as
+// case-insensitive exact values.
+func fieldSettings(config *models.IndexConfig) map[string]models.FieldSettings {
+	fields := make(map[string]models.FieldSettings, len(config.Fields)+len(config.FilterableAttributes))
+	for name, settings := range config.Fields {
+		fields[name] = settings
+	}
+
+	lowercase := true
+	for _, attr := range config.FilterableAttributes {
+		if _, ok := fields[attr]; !ok {
+			fields[attr] = models.FieldSettings{Exact: true, Lowercase: &lowercase}
+		}
+	}
+	return fields
+}
+
+// addFieldAnalyzer registers the analyzer chain of a field and returns its
+// name. The components the field names come from the index's analysis
+// settings or bleve's built-ins.
+func addFieldAnalyzer(indexMapping *mapping.IndexMappingImpl, name string, settings models.FieldSettings, analysis *models.AnalysisSettings) (string, error) {
+	tokenizer := unicode.Name
+	if settings.Exact {
+		if settings.Stemmer != "" {
+			return "", fmt.Errorf("exact fields cannot be stemmed")
+		}
+		if settings.Tokenizer != "" {
+			return "", fmt.Errorf("exact fields cannot have a tokenizer")
+		}
+		tokenizer = single.Name
+	}
+	if settings.Tokenizer != "" {
+		var err error
+		if tokenizer, err = fieldTokenizer(analysis, settings.Tokenizer); err != nil {
+			return "", err
+		}
+	}
+
+	charFilters, err := fieldCharFilters(analysis, settings.CharFilters)
+	if err != nil {
+		return "", err
+	}
+	if settings.ASCIIFolding {
+		charFilters = append(charFilters, asciifolding.Name)
+	}
+
+	tokenFilters, err := expandTokenFilters(analysis, settings.TokenFilters, nil)
+	if err != nil {
+		return "", err
+	}
+	if tokenFilters == nil {
+		tokenFilters = []any{}
+	}
+	if lower := settings.Lowercase; (lower == nil && !settings.Exact) || (lower != nil && *lower) {
+		tokenFilters = append(tokenFilters, lowercase.Name)
+	}
+
+	if settings.Stemmer != "" {
+		stemmer, ok := stemmers[settings.Stemmer]
+		if !ok {
+			return "", fmt.Errorf("unsupported stemmer %q", settings.Stemmer)
+		}
+		tokenFilters = append(tokenFilters, stemmer)
+	}
+
+	if settings.Ngram != nil && settings.EdgeNgram != nil {
+		return "", fmt.Errorf("ngram and edgeNgram cannot be combined")
+	}
+	if grams := settings.Ngram; grams != nil {
+		if grams.Min < 1 || grams.Max < grams.Min || grams.Max > maxNgram {
+			return "", fmt.Errorf("ngram needs 1 <= min <= max <= %d", maxNgram)
+		}
+		filterName := fmt.Sprintf("ngram_%d_%d", grams.Min, grams.Max)
+		if _, exists := indexMapping.CustomAnalysis.TokenFilters[filterName]; !exists {
+			err := indexMapping.AddCustomTokenFilter(filterName, map[string]any{
+				"type": ngram.Name,
+				"min":  float64(grams.Min),
+				"max":  float64(grams.Max),
+			})
+			if err != nil {
+				return "", err
+			}
+		}
+		tokenFilters = append(tokenFilters, filterName)
+	}
+
+	if ngram := settings.EdgeNgram; ngram != nil {
+		if ngram.Min < 1 || ngram.Max < ngram.Min || ngram.Max > maxEdgeNgram {
+			return "", fmt.Errorf("edgeNgram needs 1 <= min <= max <= %d", maxEdgeNgram)
+		}
+		filterName := fmt.Sprintf("edge_ngram_%d_%d", ngram.Min, ngram.Max)
+		if _, exists := indexMapping.CustomAnalysis.TokenFilters[filterName]; !exists {
+			err := indexMapping.AddCustomTokenFilter(filterName, map[string]any{
+				"type": edgengram.Name,
+				"min":  float64(ngram.Min),
+				"max":  float64(ngram.Max),
+			})
+			if err != nil {
+				return "", err
+			}
+		}
+		tokenFilters = append(tokenFilters, filterName)
+	}
+
+	analyzerName := "field." + name
+	err = indexMapping.AddCustomAnalyzer(analyzerName, map[string]any{
+		"type":          custom.Name,
+		"tokenizer":     tokenizer,
+		"char_filters":  charFilters,
+		"token_filters": tokenFilters,
+	})
+	if err != nil {
+		return "", err
+	}
+	return analyzerName, nil
+}
+
+// addKeywordField indexes the whole value of a field, as written, under
+// <field>.keyword for exact filters, sorting and facets
+func addKeywordField(property *mapping.DocumentMapping, name string) {
+	keyword := bleve.NewTextFieldMapping()
+	keyword.Name = name[strings.LastIndex(name, ".")+1:] + search.KeywordSuffix
+	keyword.Analyzer = keywordanalyzer.Name
+	keyword.Store = false
+	keyword.IncludeInAll = false
+	property.AddFieldMapping(keyword)
+
+	// Bleve finds the analyzer of a queried field through the document
+	// mapping at its path, so queries on <field>.keyword analyze their
+	// values the same way only with a "keyword" property under the field
+	lookup := bleve.NewDocumentStaticMapping()
+	analyzed := bleve.NewTextFieldMapping()
+	analyzed.Analyzer = keywordanalyzer.Name
+	analyzed.IncludeInAll = false
+	lookup.AddFieldMapping(analyzed)
+	property.AddSubDocumentMapping(strings.TrimPrefix(search.KeywordSuffix, "."), lookup)
+}
+
+// Analyzers of search-as-you-type sub-fields: word prefixes and word pairs
+// are indexed, and queries are cut to the longest prefix indexed
+const (
+	asYouTypePrefixAnalyzer  = "sayt.prefix"
+	asYouTypeShingleAnalyzer = "sayt.shingle"
+)
+
+// addAsYouTypeFields indexes a field's word prefixes and pairs of adjacent
+// words under separate names, registering their analyzers with the first
+// field that needs them
+func addAsYouTypeFields(indexMapping *mapping.IndexMappingImpl, property *mapping.DocumentMapping, name string) error {
+	if _, exists := indexMapping.CustomAnalysis.Analyzers[asYouTypePrefixAnalyzer]; !exists {
+		filters := map[string]map[string]any{
+			"sayt.edge_ngram": {"type": edgengram.Name, "min": float64(1), "max": float64(maxEdgeNgram)},
+			"sayt.truncate":   {"type": truncate.Name, "length": float64(maxEdgeNgram)},
+			"sayt.shingles":   {"type": shingle.Name, "min": float64(2), "max": float64(2)},
+		}
+		for _, filterName := range sortedKeys(filters) {
+			if err := indexMapping.AddCustomTokenFilter(filterName, filters[filterName]); err != nil {
+				return err
+			}
+		}
+
+		analyzers := map[string][]any{
+			asYouTypePrefixAnalyzer:        {lowercase.Name, "sayt.edge_ngram"},
+			search.AsYouTypeSearchAnalyzer: {lowercase.Name, "sayt.truncate"},
+			asYouTypeShingleAnalyzer:       {lowercase.Name, "sayt.shingles"},
+		}
+		for _, analyzerName := range sortedKeys(analyzers) {
+			err := indexMapping.AddCustomAnalyzer(analyzerName, map[string]any{
+				"type":          custom.Name,
+				"tokenizer":     unicode.Name,
+				"char_filters":  []any{asciifolding.Name},
+				"token_filters": analyzers[analyzerName],
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	prefix := bleve.NewTextFieldMapping()
+	prefix.Name = search.AsYouTypePrefixField + name
+	prefix.Analyzer = asYouTypePrefixAnalyzer
+
+	shingles := bleve.NewTextFieldMapping()
+	shingles.Name = search.AsYouTypeShingleField + name
+	shingles.Analyzer = asYouTypeShingleAnalyzer
+
+	for _, sub := range []*mapping.FieldMapping{prefix, shingles} {
+		sub.Store = false
+		sub.IncludeInAll = false
+		sub.IncludeTermVectors = false
+		sub.DocValues = false
+		property.AddFieldMapping(sub)
+	}
+	return nil
+}
+
+// typicalWordLength is the word length index costs are estimated for
+const typicalWordLength = 8
+
+// FieldIndexCosts estimates the index growth of the fields indexed with
+// n-grams, edge n-grams or for search-as-you-type
+func FieldIndexCosts(config *models.IndexConfig) map[string]models.FieldIndexCost {
+	costs := make(map[string]models.FieldIndexCost)
+	for name, settings := range config.Fields {
+		switch {
+		case settings.Ngram != nil:
+			terms := 0
+			for n := settings.Ngram.Min; n <= min(settings.Ngram.Max, typicalWordLength); n++ {
+				terms += typicalWordLength - n + 1
+			}
+			costs[name] = models.FieldIndexCost{
+				Mode:         "ngram",
+				TermsPerWord: terms,
+				Note:         fmt.Sprintf("every substring of %d to %d characters is indexed: expect the field to take about %d times the space, and queries shorter than %d characters to match nothing", settings.Ngram.Min, settings.Ngram.Max, terms, settings.Ngram.Min),
+			}
+		case settings.EdgeNgram != nil:
+			terms := max(0, min(settings.EdgeNgram.Max, typicalWordLength)-settings.EdgeNgram.Min+1)
+			costs[name] = models.FieldIndexCost{
+				Mode:         "edgeNgram",
+				TermsPerWord: terms,
+				Note:         fmt.Sprintf("every prefix of %d to %d characters is indexed: expect the field to take about %d times the space", settings.EdgeNgram.Min, settings.EdgeNgram.Max, terms),
+			}
+		case settings.SearchAsYouType:
+			// Each word's prefixes, and the pair it starts, on top of the word
+			terms := 1 + typicalWordLength + 1
+			costs[name] = models.FieldIndexCost{
+				Mode:         "searchAsYouType",
+				TermsPerWord: terms,
+				Note:         fmt.Sprintf("word prefixes and pairs of words are indexed for search-as-you-type: expect the field to take about %d times the space", terms),
+			}
+		}
+	}
+	if len(costs) == 0 {
+		return nil
+	}
+	return costs
+}
+
+// documentMappingAt returns the document mapping of a dotted path, creating
+// dynamic sub-document mappings along the way
+func documentMappingAt(root *mapping.DocumentMapping, path string) *mapping.DocumentMapping {
+	current := root
+	for _, segment := range strings.Split(path, ".") {
+		next, ok := current.Properties[segment]
+		if !ok {
+			next = bleve.NewDocumentMapping()
+			current.AddSubDocumentMapping(segment, next)
+		}
+		current = next
+	}
+	return current
+}
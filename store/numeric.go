@@ -0,0 +1,300 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"bright/models"
+)
+
+// currencySymbols maps currency symbols to ISO 4217 codes. Ambiguous dollar
+// and yen signs map to their most common currency.
+var currencySymbols = map[string]string{
+	"US$": "USD",
+	"CA$": "CAD",
+	"A$":  "AUD",
+	"NZ$": "NZD",
+	"HK$": "HKD",
+	"R$":  "BRL",
+	"$":   "USD",
+	"€":   "EUR",
+	"£":   "GBP",
+	"¥":   "JPY",
+	"₹":   "INR",
+	"₽":   "RUB",
+	"₩":   "KRW",
+	"₺":   "TRY",
+	"₪":   "ILS",
+	"₫":   "VND",
+	"₴":   "UAH",
+	"₱":   "PHP",
+	"zł":  "PLN",
+	"kr":  "SEK",
+	"CHF": "CHF",
+}
+
+// ValidateNumericFields checks the numeric normalization settings of an index
+func ValidateNumericFields(fields map[string]models.NumericFieldSettings) error {
+	for name, settings := range fields {
+		if name == "" || strings.HasPrefix(name, "_") {
+			return fmt.Errorf("invalid numeric field name %q", name)
+		}
+		switch settings.DecimalSeparator {
+		case "", ".", ",":
+		default:
+			return fmt.Errorf("numeric field %s: decimalSeparator must be \".\" or \",\"", name)
+		}
+		if settings.Precision != nil && (*settings.Precision < 0 || *settings.Precision > 10) {
+			return fmt.Errorf("numeric field %s: precision must be between 0 and 10", name)
+		}
+		for unit, factor := range settings.Units {
+			if strings.TrimSpace(unit) == "" || factor <= 0 || math.IsInf(factor, 0) {
+				return fmt.Errorf("numeric field %s: unit %q needs a positive factor", name, unit)
+			}
+		}
+		for _, field := range []string{settings.CurrencyField, settings.OriginalField} {
+			if strings.HasPrefix(field, "_") || field == name {
+				return fmt.Errorf("numeric field %s: invalid target field %q", name, field)
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeNumbers replaces the configured string fields of a document with
+// their numeric value. Values that cannot be parsed are left as they are.
+func normalizeNumbers(fields map[string]models.NumericFieldSettings, doc map[string]any) {
+	for name, settings := range fields {
+		value, ok := doc[name]
+		if !ok {
+			continue
+		}
+
+		switch v := value.(type) {
+		case string:
+			number, currency, ok := ParseNumber(v, settings)
+			if !ok {
+				continue
+			}
+			if settings.OriginalField != "" {
+				doc[settings.OriginalField] = v
+			}
+			if settings.CurrencyField != "" && currency != "" {
+				doc[settings.CurrencyField] = currency
+			}
+			doc[name] = number
+		case []any:
+			normalized := make([]any, len(v))
+			for i, item := range v {
+				normalized[i] = item
+				if s, ok := item.(string); ok {
+					if number, _, ok := ParseNumber(s, settings); ok {
+						normalized[i] = number
+					}
+				}
+			}
+			doc[name] = normalized
+		case float64:
+			doc[name] = roundTo(v, settings.Precision)
+		}
+	}
+}
+
+// ParseNumber parses a number written for people: currency amounts such as
+// "$1,299.00", "1.299,00 €" or "(12.50) USD", and, when the settings list
+// units, quantities such as "1.5 kg". Values are converted to the base unit
+// and rounded to the configured precision. The detected currency code is
+// returned as well.
+func ParseNumber(text string, settings models.NumericFieldSettings) (float64, string, bool) {
+	s := strings.TrimSpace(text)
+	if s == "" {
+		return 0, "", false
+	}
+
+	factor := 1.0
+	if len(settings.Units) > 0 {
+		unit, rest, ok := cutUnit(s, settings.Units)
+		if !ok {
+			return 0, "", false
+		}
+		factor = settings.Units[unit]
+		s = rest
+	}
+
+	s, currency := cutCurrency(s)
+
+	// Accounting notation marks negative amounts with parentheses
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative = true
+		s = strings.TrimSpace(s[1 : len(s)-1])
+		if currency == "" {
+			s, currency = cutCurrency(s)
+		}
+	}
+
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "−") {
+		negative = !negative
+		s = strings.TrimLeft(s, "-−")
+	} else if strings.HasSuffix(s, "-") {
+		negative = !negative
+		s = strings.TrimSuffix(s, "-")
+	}
+	// A currency after the sign ("-$5") is cut once the sign is gone
+	if currency == "" {
+		s, currency = cutCurrency(s)
+	}
+
+	number, ok := parseDigits(s, settings.DecimalSeparator)
+	if !ok {
+		return 0, "", false
+	}
+	if negative {
+		number = -number
+	}
+	return roundTo(number*factor, settings.Precision), currency, true
+}
+
+// cutUnit removes the unit suffix of a quantity, trying longer units first
+// so "mg" is not read as "g". Units match case-insensitively; a bare number
+// is in the unit with factor 1, if any.
+func cutUnit(s string, units map[string]float64) (string, string, bool) {
+	names := longestFirst(units)
+	lower := strings.ToLower(s)
+	for _, name := range names {
+		suffix := strings.ToLower(strings.TrimSpace(name))
+		if strings.HasSuffix(lower, suffix) {
+			rest := strings.TrimSpace(s[:len(s)-len(suffix)])
+			if rest != "" && !unicode.IsLetter(lastRune(rest)) {
+				return name, rest, true
+			}
+		}
+	}
+
+	for _, name := range names {
+		if units[name] == 1 {
+			return name, s, true
+		}
+	}
+	return "", "", false
+}
+
+// currencySymbolOrder lists longer symbols first, so "US$" wins over "$"
+var currencySymbolOrder = longestFirst(currencySymbols)
+
+// cutCurrency removes a leading or trailing currency symbol or ISO code
+func cutCurrency(s string) (string, string) {
+	for _, symbol := range currencySymbolOrder {
+		if strings.HasPrefix(s, symbol) {
+			return strings.TrimSpace(s[len(symbol):]), currencySymbols[symbol]
+		}
+		if strings.HasSuffix(s, symbol) {
+			return strings.TrimSpace(s[:len(s)-len(symbol)]), currencySymbols[symbol]
+		}
+	}
+
+	if len(s) > 3 {
+		if code := s[:3]; isCurrencyCode(code) {
+			return strings.TrimSpace(s[3:]), code
+		}
+		if code := s[len(s)-3:]; isCurrencyCode(code) {
+			return strings.TrimSpace(s[:len(s)-3]), code
+		}
+	}
+	return s, ""
+}
+
+func isCurrencyCode(s string) bool {
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseDigits parses digits with thousands separators. The other of "." and
+// "," than the decimal separator is a thousands separator, as are spaces and
+// apostrophes ("1 299", "1'299"). Without a configured decimal separator it
+// is guessed from the value.
+func parseDigits(s, decimal string) (float64, bool) {
+	if decimal == "" {
+		decimal = guessDecimalSeparator(s)
+	}
+	thousands := ","
+	if decimal == "," {
+		thousands = "."
+	}
+
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case string(r) == decimal:
+			b.WriteByte('.')
+		case string(r) == thousands, r == ' ', r == '\'', r == '\u00a0', r == '\u202f', r == '\u2019':
+		default:
+			return 0, false
+		}
+	}
+
+	digits := b.String()
+	if digits == "" || digits == "." || strings.Count(digits, ".") > 1 {
+		return 0, false
+	}
+	number, err := strconv.ParseFloat(digits, 64)
+	return number, err == nil
+}
+
+// guessDecimalSeparator picks the decimal separator of a value: the last of
+// "." and "," when both appear, a separator that appears once unless it is
+// a comma followed by exactly three digits ("1,299"), and "." otherwise
+func guessDecimalSeparator(s string) string {
+	dot, comma := strings.LastIndex(s, "."), strings.LastIndex(s, ",")
+	switch {
+	case dot >= 0 && comma >= 0:
+		if comma > dot {
+			return ","
+		}
+		return "."
+	case comma >= 0 && strings.Count(s, ",") == 1 && len(strings.TrimSpace(s[comma+1:])) != 3:
+		return ","
+	case dot >= 0 && strings.Count(s, ".") > 1:
+		return ","
+	}
+	return "."
+}
+
+// roundTo rounds a value to a number of decimals, if set
+func roundTo(value float64, precision *int) float64 {
+	if precision == nil {
+		return value
+	}
+	scale := math.Pow(10, float64(*precision))
+	return math.Round(value*scale) / scale
+}
+
+// longestFirst returns the keys of a map, longest first
+func longestFirst[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func lastRune(s string) rune {
+	runes := []rune(s)
+	return runes[len(runes)-1]
+}
@@ -0,0 +1,164 @@
+package store
+
+import (
+	"bright/models"
+	"bright/search"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// MaxPercolateDocuments bounds the documents of a single percolate request
+const MaxPercolateDocuments = 1000
+
+// ErrQueryNotFound is returned for unknown stored queries
+var ErrQueryNotFound = errors.New("stored query not found")
+
+// QueryRegistry holds the stored queries of all indexes, kept in
+// queries.json. Percolate matches documents against the queries of an index.
+type QueryRegistry struct {
+	queries *fileRegistry[models.StoredQuery]
+}
+
+// newQueryRegistry loads the stored queries persisted in dataDir
+func newQueryRegistry(dataDir string) (*QueryRegistry, error) {
+	queries, err := loadRegistry(dataDir, "queries.json", "stored queries",
+		func(q *models.StoredQuery) string { return storedQueryID(q.IndexID, q.ID) },
+		func(a, b *models.StoredQuery) bool {
+			if a.IndexID != b.IndexID {
+				return a.IndexID < b.IndexID
+			}
+			return a.ID < b.ID
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &QueryRegistry{queries: queries}, nil
+}
+
+// storedQueryID scopes a query ID to an index
+func storedQueryID(indexID, id string) string {
+	return indexID + "\x00" + id
+}
+
+// Put creates or replaces a stored query
+func (r *QueryRegistry) Put(q *models.StoredQuery) error {
+	return r.queries.put(q)
+}
+
+// Get returns a stored query
+func (r *QueryRegistry) Get(indexID, id string) (*models.StoredQuery, error) {
+	q, ok := r.queries.get(storedQueryID(indexID, id))
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrQueryNotFound, id)
+	}
+	return q, nil
+}
+
+// Delete removes a stored query
+func (r *QueryRegistry) Delete(indexID, id string) error {
+	found, err := r.queries.delete(storedQueryID(indexID, id))
+	if !found {
+		return fmt.Errorf("%w: %s", ErrQueryNotFound, id)
+	}
+	return err
+}
+
+// DeleteIndex removes all stored queries of an index
+func (r *QueryRegistry) DeleteIndex(indexID string) error {
+	return r.queries.update(func(queries map[string]*models.StoredQuery) bool {
+		removed := false
+		for key, q := range queries {
+			if q.IndexID == indexID {
+				delete(queries, key)
+				removed = true
+			}
+		}
+		return removed
+	})
+}
+
+// List returns the stored queries of an index ordered by ID
+func (r *QueryRegistry) List(indexID string) []*models.StoredQuery {
+	return r.queries.list(func(q *models.StoredQuery) bool { return q.IndexID == indexID })
+}
+
+// Records returns all stored queries (for snapshotting)
+func (r *QueryRegistry) Records() []*models.StoredQuery {
+	return r.queries.list(nil)
+}
+
+// Restore replaces all stored queries (used when restoring Raft snapshots)
+func (r *QueryRegistry) Restore(queries []*models.StoredQuery) error {
+	return r.queries.restore(queries)
+}
+
+// Percolate reports which stored queries of an index each document would
+// match if it were indexed. The documents are normalized like indexed ones
+// and analyzed with the index's mapping in a throwaway in-memory index;
+// they are not stored.
+func (s *IndexStore) Percolate(indexID string, documents []map[string]any) ([]models.PercolateResult, error) {
+	_, config, err := s.GetIndex(indexID)
+	if err != nil {
+		return nil, err
+	}
+	if len(documents) > MaxPercolateDocuments {
+		return nil, fmt.Errorf("at most %d documents can be percolated at once", MaxPercolateDocuments)
+	}
+
+	results := make([]models.PercolateResult, len(documents))
+	for i, doc := range documents {
+		if id, ok := doc[config.PrimaryKey]; ok && id != nil {
+			results[i].DocumentID = DocumentID(id)
+		}
+		results[i].Queries = []string{}
+	}
+
+	queries := s.queries.List(indexID)
+	if len(queries) == 0 || len(documents) == 0 {
+		return results, nil
+	}
+
+	indexMapping, err := buildIndexMapping(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid index settings: %w", err)
+	}
+	scratch, err := bleve.NewMemOnly(indexMapping)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create percolator index: %w", err)
+	}
+	defer scratch.Close()
+
+	batch := scratch.NewBatch()
+	for i, doc := range documents {
+		// Normalization rewrites fields, so it runs on a copy
+		prepared := make(map[string]any, len(doc))
+		for field, value := range doc {
+			prepared[field] = value
+		}
+		if len(config.NumericFields) > 0 {
+			normalizeNumbers(config.NumericFields, prepared)
+		}
+		if config.Language != nil {
+			tagLanguage(config.Language, prepared)
+		}
+		if err := batch.Index(strconv.Itoa(i), prepared); err != nil {
+			return nil, fmt.Errorf("failed to index document: %w", err)
+		}
+	}
+	if err := scratch.Batch(batch); err != nil {
+		return nil, fmt.Errorf("failed to index documents: %w", err)
+	}
+
+	matches, err := search.MatchStoredQueries(scratch, queries, len(documents))
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Queries = matches[i]
+	}
+	return results, nil
+}
@@ -0,0 +1,224 @@
+package store
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"bright/models"
+	"bright/search"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/index/scorch"
+	"github.com/blevesearch/bleve/v2/registry"
+	index "github.com/blevesearch/bleve_index_api"
+)
+
+// Point-in-time bounds
+const (
+	// PointInTimeKeepAlive is how long a point in time stays open after the
+	// page that last read it
+	PointInTimeKeepAlive = time.Minute
+
+	// MaxPointsInTime bounds the points in time open at once. Opening one
+	// more closes the least recently used.
+	MaxPointsInTime = 64
+)
+
+// pointInTimeType is the bleve index type reading a point in time
+const pointInTimeType = "bright.pointintime"
+
+// errReadOnly is returned for writes to a point in time
+var errReadOnly = errors.New("points in time are read-only")
+
+func init() {
+	registry.RegisterIndexType(pointInTimeType, newFrozenIndex)
+}
+
+// frozenIndex is a bleve index reading a scorch snapshot, which later
+// writes to its index don't change. Searches close the reader they are
+// given, so each gets its own reference to the snapshot.
+type frozenIndex struct {
+	snapshot *scorch.IndexSnapshot
+}
+
+// newFrozenIndex takes the snapshot from the "snapshot" entry of config
+func newFrozenIndex(_ string, config map[string]any, _ *index.AnalysisQueue) (index.Index, error) {
+	snapshot, ok := config["snapshot"].(*scorch.IndexSnapshot)
+	if !ok {
+		return nil, fmt.Errorf("%s indexes need a scorch snapshot", pointInTimeType)
+	}
+	return &frozenIndex{snapshot: snapshot}, nil
+}
+
+func (f *frozenIndex) Open() error                   { return nil }
+func (f *frozenIndex) Close() error                  { return f.snapshot.DecRef() }
+func (f *frozenIndex) Update(index.Document) error   { return errReadOnly }
+func (f *frozenIndex) Delete(string) error           { return errReadOnly }
+func (f *frozenIndex) Batch(*index.Batch) error      { return errReadOnly }
+func (f *frozenIndex) DeleteInternal([]byte) error   { return errReadOnly }
+func (f *frozenIndex) StatsMap() map[string]any      { return map[string]any{} }
+func (f *frozenIndex) SetInternal(_, _ []byte) error { return nil } // the mapping, which the snapshot already has
+
+func (f *frozenIndex) Reader() (index.IndexReader, error) {
+	f.snapshot.AddRef()
+	return f.snapshot, nil
+}
+
+// pointInTime is an index as it was when a cursor search started
+type pointInTime struct {
+	indexID  string
+	index    bleve.Index
+	timer    *time.Timer
+	lastUsed time.Time
+}
+
+// pointsInTime are the open points in time of this node, by ID
+type pointsInTime struct {
+	mu   sync.Mutex
+	open map[string]*pointInTime
+}
+
+// OpenPointInTime pins an index as it is now, so the pages of a cursor
+// search read the same documents however it is written to meanwhile. It
+// returns the point in time's ID and index, or "" and the index itself
+// when its type can't be pinned (upside_down).
+func (s *IndexStore) OpenPointInTime(indexID string) (string, bleve.Index, error) {
+	live, _, err := s.GetIndex(indexID)
+	if err != nil {
+		return "", nil, err
+	}
+	advanced, err := live.Advanced()
+	if err != nil {
+		return "", live, nil
+	}
+	reader, err := advanced.Reader()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read index: %w", err)
+	}
+	snapshot, ok := reader.(*scorch.IndexSnapshot)
+	if !ok {
+		reader.Close()
+		return "", live, nil
+	}
+	frozen, err := bleve.NewUsing("", live.Mapping(), pointInTimeType, pointInTimeType, map[string]any{"snapshot": snapshot})
+	if err != nil {
+		snapshot.DecRef()
+		return "", nil, fmt.Errorf("failed to open point in time: %w", err)
+	}
+
+	p := &s.pointsInTime
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.open == nil {
+		p.open = make(map[string]*pointInTime)
+	}
+	if len(p.open) >= MaxPointsInTime {
+		oldest := ""
+		for id, pit := range p.open {
+			if oldest == "" || pit.lastUsed.Before(p.open[oldest].lastUsed) {
+				oldest = id
+			}
+		}
+		p.closeLocked(oldest)
+	}
+
+	id := rand.Text()
+	p.open[id] = &pointInTime{
+		indexID:  indexID,
+		index:    frozen,
+		timer:    time.AfterFunc(PointInTimeKeepAlive, func() { s.ClosePointInTime(id) }),
+		lastUsed: time.Now(),
+	}
+	return id, frozen, nil
+}
+
+// PointInTime returns the index pinned by a point in time of indexID and
+// keeps it open for another PointInTimeKeepAlive
+func (s *IndexStore) PointInTime(indexID, id string) (bleve.Index, error) {
+	p := &s.pointsInTime
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pit, ok := p.open[id]
+	// A stopped timer has already fired, and the point in time is closing
+	if !ok || pit.indexID != indexID || !pit.timer.Stop() {
+		return nil, fmt.Errorf("%w: its point in time expired or was opened on another node, start again from %s", search.ErrInvalidCursor, search.CursorStart)
+	}
+	pit.timer.Reset(PointInTimeKeepAlive)
+	pit.lastUsed = time.Now()
+	return pit.index, nil
+}
+
+// ClosePointInTime closes a point in time, if it is still open. Searches
+// reading it finish first.
+func (s *IndexStore) ClosePointInTime(id string) {
+	p := &s.pointsInTime
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closeLocked(id)
+}
+
+// CursorIndex returns the index a page of a cursor search reads: a new
+// point in time of indexID for the first page, and the point in time of the
+// cursor for the next ones. It sets the request's PointInTime, which the
+// cursors it returns carry.
+func (s *IndexStore) CursorIndex(indexID string, req *models.SearchRequest) (bleve.Index, error) {
+	if req.Cursor == search.CursorStart {
+		id, pinned, err := s.OpenPointInTime(indexID)
+		if err != nil {
+			return nil, err
+		}
+		req.PointInTime = id
+		return pinned, nil
+	}
+
+	id, err := search.CursorPointInTime(req.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		live, _, err := s.GetIndex(indexID)
+		return live, err
+	}
+	pinned, err := s.PointInTime(indexID, id)
+	if err != nil {
+		return nil, err
+	}
+	req.PointInTime = id
+	return pinned, nil
+}
+
+// closeIndex closes the points in time of an index
+func (p *pointsInTime) closeIndex(indexID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id, pit := range p.open {
+		if pit.indexID == indexID {
+			p.closeLocked(id)
+		}
+	}
+}
+
+// closeAll closes every point in time
+func (p *pointsInTime) closeAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id := range p.open {
+		p.closeLocked(id)
+	}
+}
+
+// closeLocked closes a point in time while p.mu is held
+func (p *pointsInTime) closeLocked(id string) {
+	pit, ok := p.open[id]
+	if !ok {
+		return
+	}
+	delete(p.open, id)
+	pit.timer.Stop()
+	// Closing waits for the searches reading it, which don't need p.mu
+	pit.index.Close()
+}
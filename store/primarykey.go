@@ -0,0 +1,187 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidPrimaryKey is returned when a document's primary key value is unusable
+var ErrInvalidPrimaryKey = errors.New("invalid primary key")
+
+// ErrDuplicatePrimaryKey is returned when different documents in a batch share a primary key
+var ErrDuplicatePrimaryKey = errors.New("duplicate primary key")
+
+// DocumentID returns the document ID of a primary key value. Integral
+// numbers are written out in full, so that 1000000 decoded from JSON as a
+// float is "1000000" rather than "1e+06".
+func DocumentID(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	case float32:
+		if v == float32(math.Trunc(float64(v))) && !math.IsInf(float64(v), 0) {
+			return strconv.FormatFloat(float64(v), 'f', -1, 32)
+		}
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// ValidatePrimaryKeys checks the primary key values of a batch. Values must
+// be non-empty strings or integers, never objects or arrays, and all documents must use the same type
+// (1 and "1" would otherwise silently map to the same document). Unless
+// allowDuplicates is set, two different documents may not share a key;
+// identical copies are accepted. Documents without a key are skipped since
+// they are assigned one.
+func ValidatePrimaryKeys(primaryKey string, documents []map[string]any, allowDuplicates bool) error {
+	if primaryKey == "" {
+		return fmt.Errorf("%w: no primary key configured", ErrInvalidPrimaryKey)
+	}
+
+	var batchKind string
+	positions := make(map[string]int, len(documents))
+
+	for i, doc := range documents {
+		value, ok := doc[primaryKey]
+		if !ok || value == nil {
+			continue
+		}
+
+		kind, err := primaryKeyKind(value)
+		if err != nil {
+			return fmt.Errorf("%w: document %d: %s %v", ErrInvalidPrimaryKey, i, primaryKey, err)
+		}
+		if batchKind == "" {
+			batchKind = kind
+		} else if kind != batchKind {
+			return fmt.Errorf("%w: document %d: %s has type %s but earlier documents use type %s", ErrInvalidPrimaryKey, i, primaryKey, kind, batchKind)
+		}
+
+		if allowDuplicates {
+			continue
+		}
+
+		id := DocumentID(value)
+		if first, seen := positions[id]; seen {
+			if !reflect.DeepEqual(documents[first], doc) {
+				return fmt.Errorf("%w: documents %d and %d both use %s %q", ErrDuplicatePrimaryKey, first, i, primaryKey, id)
+			}
+			continue
+		}
+		positions[id] = i
+	}
+
+	return nil
+}
+
+// primaryKeyKind classifies a primary key value as "string" or "integer"
+func primaryKeyKind(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return "", fmt.Errorf("is empty")
+		}
+		return "string", nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return "integer", nil
+	case float32:
+		if float64(v) != math.Trunc(float64(v)) {
+			return "", fmt.Errorf("must be an integer, got %v", v)
+		}
+		return "integer", nil
+	case float64:
+		if v != math.Trunc(v) || math.IsInf(v, 0) {
+			return "", fmt.Errorf("must be an integer, got %v", v)
+		}
+		return "integer", nil
+	case map[string]any:
+		return "", fmt.Errorf("must be a string or integer, got an object")
+	case []any:
+		return "", fmt.Errorf("must be a string or integer, got an array")
+	default:
+		return "", fmt.Errorf("must be a string or integer, got %T", value)
+	}
+}
+
+// PrimaryKeyHeuristics configures how the primary key of an auto-created
+// index is chosen. The zero value accepts only a single attribute ending in
+// "id".
+type PrimaryKeyHeuristics struct {
+	// Default is used as the primary key without looking at the documents
+	Default string
+
+	// PreferredNames are tried in order before any other attribute, for
+	// schemas whose keys are not named "id" (e.g. "sku", "uuid", "code")
+	PreferredNames []string
+
+	// PreferExactID picks an attribute named exactly "id", then "_id", over
+	// other attributes ending in "id" such as "userId"
+	PreferExactID bool
+
+	// Fallback is used when no attribute qualifies; documents then get
+	// generated UUIDs under it
+	Fallback string
+}
+
+// DetectPrimaryKey returns the primary key attribute for a batch. Candidates
+// are, in order: the default, the first preferred name present in the
+// documents, an exact "id" or "_id", and a single attribute ending in "id"
+// (case-insensitive). It fails when several attributes end in "id", or when
+// none does and there is no fallback.
+func DetectPrimaryKey(documents []map[string]any, heuristics PrimaryKeyHeuristics) (string, error) {
+	if heuristics.Default != "" {
+		return heuristics.Default, nil
+	}
+	if len(documents) == 0 {
+		return "", fmt.Errorf("cannot detect primary key from empty document set")
+	}
+
+	attributes := make(map[string]bool)
+	for _, doc := range documents {
+		for attr := range doc {
+			attributes[attr] = true
+		}
+	}
+
+	for _, name := range heuristics.PreferredNames {
+		if attributes[name] {
+			return name, nil
+		}
+	}
+
+	if heuristics.PreferExactID {
+		for _, name := range []string{"id", "_id"} {
+			if attributes[name] {
+				return name, nil
+			}
+		}
+	}
+
+	// Collect all attribute names ending with "id" (case-insensitive)
+	candidates := make([]string, 0, 1)
+	for attr := range attributes {
+		if strings.HasSuffix(strings.ToLower(attr), "id") {
+			candidates = append(candidates, attr)
+		}
+	}
+
+	switch {
+	case len(candidates) == 1:
+		return candidates[0], nil
+	case len(candidates) > 1:
+		sort.Strings(candidates)
+		return "", fmt.Errorf("multiple primary key candidates found: %v", candidates)
+	case heuristics.Fallback != "":
+		return heuristics.Fallback, nil
+	default:
+		return "", fmt.Errorf("no primary key candidate found (no attribute ending with 'id')")
+	}
+}
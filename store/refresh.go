@@ -0,0 +1,172 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"bright/models"
+)
+
+// maxPendingDocuments bounds the documents buffered per index; reaching it
+// refreshes the index right away
+const maxPendingDocuments = 10000
+
+// Refresh policies of a write
+const (
+	// RefreshTrue makes the write searchable before it returns
+	RefreshTrue = "true"
+
+	// RefreshWaitFor returns once the next scheduled refresh made the write searchable
+	RefreshWaitFor = "wait_for"
+
+	// RefreshFalse returns as soon as the write is accepted
+	RefreshFalse = "false"
+)
+
+// pendingBatch is a run of buffered documents sharing a primary key
+type pendingBatch struct {
+	primaryKey string
+	documents  []map[string]any
+	seqs       []uint64
+}
+
+// pendingWrites are the documents of an index waiting for its next refresh
+type pendingWrites struct {
+	batches []*pendingBatch
+	count   int
+	timer   *time.Timer
+
+	// done is closed once the writes are indexed, with err set on failure
+	done chan struct{}
+	err  error
+}
+
+// ParseRefreshInterval parses an index's refresh interval. Empty or zero
+// means every write is searchable immediately.
+func ParseRefreshInterval(config *models.IndexConfig) (time.Duration, error) {
+	if config.RefreshInterval == "" {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(config.RefreshInterval)
+	if err != nil || interval < 0 {
+		return 0, fmt.Errorf("refreshInterval must be a non-negative duration such as 1s")
+	}
+	return interval, nil
+}
+
+// ValidateRefresh checks the refresh policy of a write
+func ValidateRefresh(policy string) error {
+	switch policy {
+	case "", RefreshTrue, RefreshWaitFor, RefreshFalse:
+		return nil
+	}
+	return fmt.Errorf("refresh must be true, wait_for or false")
+}
+
+// bufferDocuments holds documents until the index's next refresh. Journaled
+// batches are committed to the write-ahead log once they are indexed.
+func (s *IndexStore) bufferDocuments(indexID, primaryKey string, documents []map[string]any, seq uint64, interval time.Duration) error {
+	s.pendingMu.Lock()
+	writes := s.pending[indexID]
+	if writes == nil {
+		writes = &pendingWrites{done: make(chan struct{})}
+		writes.timer = time.AfterFunc(interval, func() { s.Refresh(indexID) })
+		s.pending[indexID] = writes
+	}
+
+	var batch *pendingBatch
+	if n := len(writes.batches); n > 0 && writes.batches[n-1].primaryKey == primaryKey {
+		batch = writes.batches[n-1]
+	} else {
+		batch = &pendingBatch{primaryKey: primaryKey}
+		writes.batches = append(writes.batches, batch)
+	}
+	batch.documents = append(batch.documents, documents...)
+	if seq != 0 {
+		batch.seqs = append(batch.seqs, seq)
+	}
+	writes.count += len(documents)
+	full := writes.count >= maxPendingDocuments
+	s.pendingMu.Unlock()
+
+	if full {
+		return s.Refresh(indexID)
+	}
+	return nil
+}
+
+// Refresh indexes the buffered documents of an index, making them
+// searchable. Writes that must not overtake buffered documents (deletes,
+// updates, unbuffered adds) refresh the index first; a failure there belongs
+// to the buffered writes and is reported to their waiters, not to the write.
+func (s *IndexStore) Refresh(indexID string) error {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	s.pendingMu.Lock()
+	writes := s.pending[indexID]
+	delete(s.pending, indexID)
+	s.pendingMu.Unlock()
+
+	if writes == nil {
+		return nil
+	}
+	writes.timer.Stop()
+
+	s.mu.RLock()
+	wal := s.wal
+	s.mu.RUnlock()
+
+	for _, batch := range writes.batches {
+		err := s.indexDocuments(indexID, batch.primaryKey, batch.documents)
+		if wal != nil {
+			// A rejected batch would fail the same way when replayed
+			for _, seq := range batch.seqs {
+				if commitErr := wal.Commit(seq); err == nil {
+					err = commitErr
+				}
+			}
+		}
+		if err != nil && writes.err == nil {
+			writes.err = err
+		}
+	}
+
+	close(writes.done)
+	return writes.err
+}
+
+// WaitForRefresh blocks until the documents buffered for an index so far
+// have been refreshed
+func (s *IndexStore) WaitForRefresh(indexID string) error {
+	// A refresh in progress has taken its writes out of pending already
+	s.refreshMu.Lock()
+	s.pendingMu.Lock()
+	writes := s.pending[indexID]
+	s.pendingMu.Unlock()
+	s.refreshMu.Unlock()
+
+	if writes == nil {
+		return nil
+	}
+	<-writes.done
+	return writes.err
+}
+
+// refreshAll refreshes every index with buffered documents
+func (s *IndexStore) refreshAll() error {
+	s.pendingMu.Lock()
+	ids := make([]string, 0, len(s.pending))
+	for id := range s.pending {
+		ids = append(ids, id)
+	}
+	s.pendingMu.Unlock()
+
+	var firstErr error
+	for _, id := range ids {
+		if err := s.Refresh(id); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"bright/persist"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/bytedance/sonic"
+)
+
+// fileRegistry is a set of records kept in memory and persisted as a JSON
+// array in one file of the data directory. The registries of the store wrap
+// it with their own lookups and errors.
+type fileRegistry[T any] struct {
+	records map[string]*T
+	file    string
+
+	// what names the records in errors, e.g. "alert rules"
+	what string
+
+	// key identifies a record; less orders the records when listed
+	key  func(*T) string
+	less func(a, b *T) bool
+
+	mu sync.RWMutex
+}
+
+// loadRegistry loads the records persisted in the named file of dataDir. A
+// missing file is an empty registry.
+func loadRegistry[T any](dataDir, name, what string, key func(*T) string, less func(a, b *T) bool) (*fileRegistry[T], error) {
+	r := &fileRegistry[T]{
+		records: make(map[string]*T),
+		file:    filepath.Join(dataDir, name),
+		what:    what,
+		key:     key,
+		less:    less,
+	}
+
+	data, _, err := persist.ReadFile(r.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", what, err)
+	}
+
+	var records []*T
+	if err := sonic.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", what, err)
+	}
+	for _, record := range records {
+		r.records[key(record)] = record
+	}
+
+	return r, nil
+}
+
+// put creates or replaces a record
+func (r *fileRegistry[T]) put(record *T) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.records[r.key(record)] = record
+	return r.saveLocked()
+}
+
+// get returns the record with the given key
+func (r *fileRegistry[T]) get(key string) (*T, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	record, ok := r.records[key]
+	return record, ok
+}
+
+// delete removes the record with the given key, and reports whether there
+// was one
+func (r *fileRegistry[T]) delete(key string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.records[key]; !ok {
+		return false, nil
+	}
+	delete(r.records, key)
+	return true, r.saveLocked()
+}
+
+// update lets fn change the records and persists them if it reports a change
+func (r *fileRegistry[T]) update(fn func(records map[string]*T) bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !fn(r.records) {
+		return nil
+	}
+	return r.saveLocked()
+}
+
+// list returns the records accepted by match, or all records if match is
+// nil, in order
+func (r *fileRegistry[T]) list(match func(*T) bool) []*T {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.listLocked(match)
+}
+
+// listLocked is list for callers holding the lock
+func (r *fileRegistry[T]) listLocked(match func(*T) bool) []*T {
+	records := make([]*T, 0, len(r.records))
+	for _, record := range r.records {
+		if match == nil || match(record) {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return r.less(records[i], records[j])
+	})
+	return records
+}
+
+// saveLocked writes all records to the file (must be called with the lock
+// held)
+func (r *fileRegistry[T]) saveLocked() error {
+	data, err := sonic.Marshal(r.listLocked(nil))
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", r.what, err)
+	}
+
+	if err := persist.WriteFile(r.file, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", r.what, err)
+	}
+
+	return nil
+}
+
+// restore replaces all records (used when restoring Raft snapshots)
+func (r *fileRegistry[T]) restore(records []*T) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.records = make(map[string]*T, len(records))
+	for _, record := range records {
+		r.records[r.key(record)] = record
+	}
+	return r.saveLocked()
+}
@@ -0,0 +1,51 @@
+package store
+
+import (
+	"bright/models"
+	"testing"
+	"time"
+)
+
+// TestRegistryPersists tests that the changes to a registry are ordered and
+// survive reloading it from its file
+func TestRegistryPersists(t *testing.T) {
+	dir := t.TempDir()
+	load := func() *fileRegistry[models.AlertRule] {
+		r, err := loadRegistry(dir, "alerts.json", "alert rules",
+			func(rule *models.AlertRule) string { return rule.ID },
+			func(a, b *models.AlertRule) bool { return a.CreatedAt.Before(b.CreatedAt) },
+		)
+		if err != nil {
+			t.Fatalf("Failed to load registry: %v", err)
+		}
+		return r
+	}
+
+	r := load()
+	now := time.Now().UTC()
+	for i, id := range []string{"c", "a", "b"} {
+		if err := r.put(&models.AlertRule{ID: id, CreatedAt: now.Add(time.Duration(i) * time.Second)}); err != nil {
+			t.Fatalf("Failed to put %s: %v", id, err)
+		}
+	}
+	if found, err := r.delete("a"); !found || err != nil {
+		t.Fatalf("Expected a to be deleted, got %v (%v)", found, err)
+	}
+	if found, _ := r.delete("missing"); found {
+		t.Errorf("Expected nothing to delete")
+	}
+	if err := r.update(func(rules map[string]*models.AlertRule) bool {
+		rules["b"] = &models.AlertRule{ID: "b", CreatedAt: now.Add(-time.Second)}
+		return true
+	}); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+
+	var ids []string
+	for _, rule := range load().list(nil) {
+		ids = append(ids, rule.ID)
+	}
+	if len(ids) != 2 || ids[0] != "b" || ids[1] != "c" {
+		t.Errorf("Expected [b c] after reloading, got %v", ids)
+	}
+}
@@ -0,0 +1,697 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"bright/jobs"
+	"bright/models"
+	"bright/persist"
+
+	"github.com/blevesearch/bleve/v2"
+	bsearch "github.com/blevesearch/bleve/v2/search"
+	"github.com/bytedance/sonic"
+)
+
+// ErrReindexNotFound is returned for indexes without a shadow reindex
+var ErrReindexNotFound = errors.New("no reindex for index")
+
+// Reindex states
+const (
+	ReindexBuilding  = "building"
+	ReindexCompleted = "completed"
+	ReindexFailed    = "failed"
+)
+
+// reindexDir holds the shadow indexes being built, apart from the indexes
+const reindexDir = ".reindex"
+
+// reindexPageSize is the number of documents copied per backfill batch
+const reindexPageSize = 500
+
+// ReindexStatus is the progress of a shadow reindex on this node
+type ReindexStatus struct {
+	IndexID string `json:"indexId"`
+	State   string `json:"state"`
+
+	// Settings the index is rebuilt with; they take effect on the swap
+	Settings *models.IndexConfig `json:"settings"`
+
+	StartedAt   time.Time  `json:"startedAt"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+
+	// Total is the document count of the index when the build started;
+	// Copied counts the documents backfilled from it and LiveWrites the
+	// documents written to both indexes during the build
+	Total      uint64 `json:"total"`
+	Copied     uint64 `json:"copied"`
+	LiveWrites uint64 `json:"liveWrites"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// reindex is a shadow index rebuilt from an index with new settings. Writes
+// to the index are mirrored into it while it is built; the documents they
+// touched are left alone by the backfill, which would copy older versions.
+type reindex struct {
+	status ReindexStatus
+	index  bleve.Index
+	path   string
+
+	// touched is guarded by the lock of the index
+	touched map[string]bool
+
+	// err is set when a mirrored write failed, which makes the shadow
+	// incomplete
+	err error
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	mu     sync.Mutex
+}
+
+// snapshot returns a copy of the status
+func (r *reindex) snapshot() *ReindexStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := r.status
+	return &status
+}
+
+// building returns true while writes must be mirrored into the shadow
+func (r *reindex) building() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.index != nil && r.status.State == ReindexBuilding && r.err == nil
+}
+
+// StartReindex rebuilds an index with new settings in a hidden shadow index
+// while the index keeps serving, then swaps them. A reindex of the index
+// already running is replaced.
+func (s *IndexStore) StartReindex(indexID string, config *models.IndexConfig) error {
+	_, current, err := s.GetIndex(indexID)
+	if err != nil {
+		return err
+	}
+	if config.PrimaryKey != current.PrimaryKey {
+		return fmt.Errorf("primaryKey cannot be changed by a reindex")
+	}
+	if config.IndexType == "" {
+		config.IndexType = current.IndexType
+	}
+	if config.KVStore == "" && config.IndexType == current.IndexType {
+		config.KVStore = current.KVStore
+	}
+	if inMemory(current) || inMemory(config) {
+		return fmt.Errorf("in-memory indexes cannot be reindexed, create a new index instead")
+	}
+	if err := ValidateIndexType(config.IndexType, config.KVStore); err != nil {
+		return err
+	}
+
+	s.stopReindex(indexID)
+
+	config.ID = indexID
+	if err := s.setPendingReindex(indexID, config); err != nil {
+		return err
+	}
+	return s.startReindex(indexID, config)
+}
+
+// CancelReindex stops the reindex of an index and drops its shadow
+func (s *IndexStore) CancelReindex(indexID string) error {
+	s.mu.RLock()
+	r, ok := s.reindexes[indexID]
+	s.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w %s", ErrReindexNotFound, indexID)
+	}
+
+	s.stopReindex(indexID)
+	if r.snapshot().State != ReindexCompleted {
+		os.RemoveAll(r.path)
+	}
+
+	s.mu.Lock()
+	delete(s.reindexes, indexID)
+	s.mu.Unlock()
+	return s.setPendingReindex(indexID, nil)
+}
+
+// Reindex returns the status of the last reindex of an index on this node
+func (s *IndexStore) Reindex(indexID string) (*ReindexStatus, error) {
+	s.mu.RLock()
+	r, ok := s.reindexes[indexID]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrReindexNotFound, indexID)
+	}
+	return r.snapshot(), nil
+}
+
+// PendingReindexes returns the settings of the reindexes not yet swapped in
+// (for snapshotting)
+func (s *IndexStore) PendingReindexes() map[string]*models.IndexConfig {
+	s.reindexMu.Lock()
+	defer s.reindexMu.Unlock()
+
+	pending := make(map[string]*models.IndexConfig, len(s.pendingReindexes))
+	for id, config := range s.pendingReindexes {
+		pending[id] = config
+	}
+	return pending
+}
+
+// RestoreReindexes replaces the pending reindexes (used when restoring Raft
+// snapshots). Reindexes that are already running with the same settings
+// carry on.
+func (s *IndexStore) RestoreReindexes(pending map[string]*models.IndexConfig) error {
+	s.mu.RLock()
+	running := make(map[string]*reindex, len(s.reindexes))
+	for id, r := range s.reindexes {
+		running[id] = r
+	}
+	s.mu.RUnlock()
+
+	for id, r := range running {
+		status := r.snapshot()
+		if status.State == ReindexBuilding && !sameSettings(status.Settings, pending[id]) {
+			s.stopReindex(id)
+			os.RemoveAll(r.path)
+		}
+	}
+
+	s.reindexMu.Lock()
+	s.pendingReindexes = make(map[string]*models.IndexConfig, len(pending))
+	for id, config := range pending {
+		s.pendingReindexes[id] = config
+	}
+	err := s.savePendingReindexes()
+	s.reindexMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for id, config := range pending {
+		if r, ok := running[id]; ok && r.snapshot().State == ReindexBuilding && sameSettings(r.snapshot().Settings, config) {
+			continue
+		}
+		if _, _, err := s.GetIndex(id); err != nil {
+			continue
+		}
+		if err := s.startReindex(id, config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sameSettings compares two index configurations
+func sameSettings(a, b *models.IndexConfig) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	encodedA, errA := sonic.Marshal(a)
+	encodedB, errB := sonic.Marshal(b)
+	return errA == nil && errB == nil && string(encodedA) == string(encodedB)
+}
+
+// startReindex creates the shadow index and starts building it
+func (s *IndexStore) startReindex(indexID string, config *models.IndexConfig) error {
+	path := filepath.Join(s.dataDir, reindexDir, indexID)
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("failed to clear shadow index: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create reindex directory: %w", err)
+	}
+	shadow, err := s.createNewIndex(path, config)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &reindex{
+		status: ReindexStatus{
+			IndexID:   indexID,
+			State:     ReindexBuilding,
+			Settings:  config,
+			StartedAt: time.Now(),
+		},
+		index:   shadow,
+		path:    path,
+		touched: make(map[string]bool),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	s.reindexes[indexID] = r
+	s.mu.Unlock()
+
+	go s.buildReindex(ctx, indexID, r)
+	return nil
+}
+
+// stopReindex cancels a running reindex and waits for it to stop. The
+// shadow directory is left in place.
+func (s *IndexStore) stopReindex(indexID string) {
+	s.mu.RLock()
+	r, ok := s.reindexes[indexID]
+	s.mu.RUnlock()
+	if !ok {
+		return
+	}
+	r.cancel()
+	<-r.done
+}
+
+// stopReindexes cancels all running reindexes (when closing the store)
+func (s *IndexStore) stopReindexes() {
+	s.mu.RLock()
+	ids := make([]string, 0, len(s.reindexes))
+	for id := range s.reindexes {
+		ids = append(ids, id)
+	}
+	s.mu.RUnlock()
+
+	for _, id := range ids {
+		s.stopReindex(id)
+	}
+}
+
+// buildReindex backfills the shadow index and swaps it in
+func (s *IndexStore) buildReindex(ctx context.Context, indexID string, r *reindex) {
+	defer close(r.done)
+
+	err := s.backfill(ctx, indexID, r)
+	if err == nil {
+		err = s.swapReindex(indexID, r)
+	}
+	if err == nil {
+		return
+	}
+
+	// Mirrored writes hold the index lock while they use the shadow
+	indexLock := s.getIndexLock(indexID)
+	indexLock.Lock()
+	defer indexLock.Unlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.index != nil {
+		r.index.Close()
+		r.index = nil
+	}
+	if ctx.Err() != nil {
+		// Cancelled, or replaced by a newer reindex
+		return
+	}
+	now := time.Now()
+	r.status.State = ReindexFailed
+	r.status.CompletedAt = &now
+	r.status.Error = err.Error()
+}
+
+// backfill copies the documents of an index into its shadow, in ID order
+func (s *IndexStore) backfill(ctx context.Context, indexID string, r *reindex) error {
+	index, _, err := s.GetIndex(indexID)
+	if err != nil {
+		return err
+	}
+	if total, err := index.DocCount(); err == nil {
+		r.mu.Lock()
+		r.status.Total = total
+		r.mu.Unlock()
+	}
+
+	job := s.jobs.Start(jobs.KindReindex, indexID)
+	defer job.Done()
+
+	return scanDocuments(ctx, index, reindexPageSize, func(hits bsearch.DocumentMatchCollection) error {
+		if err := s.copyPage(indexID, r, hits); err != nil {
+			return err
+		}
+		return job.Wait(ctx, len(hits))
+	})
+}
+
+// scanDocuments calls fn with pages of the stored documents of an index, in
+// ID order, until fn fails or ctx is done
+func scanDocuments(ctx context.Context, index bleve.Index, pageSize int, fn func(hits bsearch.DocumentMatchCollection) error) error {
+	var after []string
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		searchRequest := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), pageSize, 0, false)
+		searchRequest.SortBy([]string{"_id"})
+		searchRequest.Fields = []string{"*"}
+		if after != nil {
+			searchRequest.SetSearchAfter(after)
+		}
+		searchResult, err := index.Search(searchRequest)
+		if err != nil {
+			return fmt.Errorf("failed to read documents: %w", err)
+		}
+
+		if len(searchResult.Hits) > 0 {
+			if err := fn(searchResult.Hits); err != nil {
+				return err
+			}
+		}
+
+		if len(searchResult.Hits) < pageSize {
+			return nil
+		}
+		after = []string{searchResult.Hits[len(searchResult.Hits)-1].ID}
+	}
+}
+
+// copyPage writes a page of documents into the shadow, skipping those
+// written since the build started
+func (s *IndexStore) copyPage(indexID string, r *reindex, hits bsearch.DocumentMatchCollection) error {
+	indexLock := s.getIndexLock(indexID)
+	indexLock.Lock()
+	defer indexLock.Unlock()
+
+	r.mu.Lock()
+	err := r.err
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	config := r.status.Settings
+	batch := r.index.NewBatch()
+	copied := uint64(0)
+	for _, hit := range hits {
+		if r.touched[hit.ID] {
+			continue
+		}
+		prepareReindexDocument(config, hit.Fields)
+		if err := batch.Index(hit.ID, hit.Fields); err != nil {
+			return fmt.Errorf("failed to copy document %s: %w", hit.ID, err)
+		}
+		copied++
+	}
+	if err := r.index.Batch(batch); err != nil {
+		return fmt.Errorf("failed to copy documents: %w", err)
+	}
+
+	r.mu.Lock()
+	r.status.Copied += copied
+	r.mu.Unlock()
+	return nil
+}
+
+// prepareReindexDocument applies the ingest steps of the new settings to a
+// stored document
+func prepareReindexDocument(config *models.IndexConfig, doc map[string]any) {
+	normalizeNumbers(config.NumericFields, doc)
+	if config.Language != nil {
+		tagLanguage(config.Language, doc)
+	}
+}
+
+// swapReindex replaces an index by its finished shadow and makes the new
+// settings current
+func (s *IndexStore) swapReindex(indexID string, r *reindex) error {
+	// Buffered documents are indexed (and mirrored) first
+	s.Refresh(indexID)
+
+	indexLock := s.getIndexLock(indexID)
+	indexLock.Lock()
+	defer indexLock.Unlock()
+
+	r.mu.Lock()
+	err := r.err
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	live, exists := s.indexes[indexID]
+	if !exists {
+		return fmt.Errorf("index %s not found", indexID)
+	}
+
+	r.mu.Lock()
+	closeErr := r.index.Close()
+	r.index = nil
+	r.mu.Unlock()
+	if closeErr != nil {
+		return fmt.Errorf("failed to close shadow index: %w", closeErr)
+	}
+	if err := live.Close(); err != nil {
+		return fmt.Errorf("failed to close index: %w", err)
+	}
+
+	// A crash between the renames is repaired by recoverReindexes
+	livePath := filepath.Join(s.dataDir, indexID)
+	previousPath := r.path + ".previous"
+	os.RemoveAll(previousPath)
+	if err := os.Rename(livePath, previousPath); err != nil {
+		return s.reopen(indexID, livePath, fmt.Errorf("failed to move index aside: %w", err))
+	}
+	if err := os.Rename(r.path, livePath); err != nil {
+		os.Rename(previousPath, livePath)
+		return s.reopen(indexID, livePath, fmt.Errorf("failed to move shadow index in place: %w", err))
+	}
+
+	index, err := bleve.Open(livePath)
+	if err != nil {
+		return fmt.Errorf("failed to open reindexed index: %w", err)
+	}
+	s.indexes[indexID] = index
+	s.configs[indexID] = r.status.Settings
+	s.searchCache.Invalidate(indexID)
+	if err := s.saveConfigs(); err != nil {
+		return err
+	}
+	os.RemoveAll(previousPath)
+
+	s.reindexMu.Lock()
+	delete(s.pendingReindexes, indexID)
+	err = s.savePendingReindexes()
+	s.reindexMu.Unlock()
+
+	now := time.Now()
+	r.mu.Lock()
+	r.status.State = ReindexCompleted
+	r.status.CompletedAt = &now
+	r.mu.Unlock()
+	return err
+}
+
+// reopen opens an index again after a failed swap (must be called with the
+// store lock held)
+func (s *IndexStore) reopen(indexID, path string, cause error) error {
+	index, err := bleve.Open(path)
+	if err != nil {
+		return errors.Join(cause, fmt.Errorf("failed to reopen index: %w", err))
+	}
+	s.indexes[indexID] = index
+	return cause
+}
+
+// lockedIndex returns the current index of indexID. Writes call it with the
+// index lock held, as a reindex may have swapped the index while they waited.
+func (s *IndexStore) lockedIndex(indexID string) (bleve.Index, error) {
+	s.mu.RLock()
+	index, exists := s.indexes[indexID]
+	s.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("index %s not found", indexID)
+	}
+	return index, nil
+}
+
+// mirror applies a write to the shadow of an index being reindexed and marks
+// the written documents, so the backfill leaves them alone (must be called
+// with the index lock held, after the write succeeded on the index)
+func (s *IndexStore) mirror(indexID string, ids []string, write func(shadow bleve.Index, config *models.IndexConfig) error) {
+	s.mu.RLock()
+	r, ok := s.reindexes[indexID]
+	s.mu.RUnlock()
+	if !ok || !r.building() {
+		return
+	}
+
+	for _, id := range ids {
+		r.touched[id] = true
+	}
+	err := write(r.index, r.status.Settings)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.err = fmt.Errorf("failed to mirror a write: %w", err)
+		return
+	}
+	r.status.LiveWrites += uint64(len(ids))
+}
+
+// setPendingReindex records (or with a nil config, drops) the settings of a
+// pending reindex, so it is started again after a restart
+func (s *IndexStore) setPendingReindex(indexID string, config *models.IndexConfig) error {
+	s.reindexMu.Lock()
+	defer s.reindexMu.Unlock()
+
+	if config == nil {
+		delete(s.pendingReindexes, indexID)
+	} else {
+		s.pendingReindexes[indexID] = config
+	}
+	return s.savePendingReindexes()
+}
+
+// savePendingReindexes persists the pending reindexes (must be called with
+// reindexMu held)
+func (s *IndexStore) savePendingReindexes() error {
+	data, err := sonic.Marshal(s.pendingReindexes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pending reindexes: %w", err)
+	}
+	if err := persist.WriteFile(filepath.Join(s.dataDir, "reindexes.json"), data, 0644); err != nil {
+		return fmt.Errorf("failed to save pending reindexes: %w", err)
+	}
+	return nil
+}
+
+// loadPendingReindexes reads the pending reindexes
+func (s *IndexStore) loadPendingReindexes() error {
+	s.pendingReindexes = make(map[string]*models.IndexConfig)
+
+	data, _, err := persist.ReadFile(filepath.Join(s.dataDir, "reindexes.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read pending reindexes: %w", err)
+	}
+	if err := sonic.Unmarshal(data, &s.pendingReindexes); err != nil {
+		return fmt.Errorf("failed to parse pending reindexes: %w", err)
+	}
+	if s.pendingReindexes == nil {
+		s.pendingReindexes = make(map[string]*models.IndexConfig)
+	}
+	return nil
+}
+
+// RebuildIndex rebuilds an index of a data directory no store has open with
+// the mapping its settings make in this build. The stored documents are
+// copied into a shadow index that then replaces the index, laid out as a
+// reindex so that recoverReindexes repairs an interrupted swap.
+func RebuildIndex(dataDir string, config *models.IndexConfig) error {
+	livePath := filepath.Join(dataDir, config.ID)
+	path := filepath.Join(dataDir, reindexDir, config.ID)
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("failed to clear shadow index: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create reindex directory: %w", err)
+	}
+
+	live, err := bleve.Open(livePath)
+	if err != nil {
+		return fmt.Errorf("failed to open index: %w", err)
+	}
+	indexMapping, err := buildIndexMapping(config)
+	if err != nil {
+		live.Close()
+		return fmt.Errorf("invalid index settings: %w", err)
+	}
+	shadow, err := newBleveIndex(path, config, indexMapping)
+	if err != nil {
+		live.Close()
+		return fmt.Errorf("failed to create index: %w", err)
+	}
+
+	err = scanDocuments(context.Background(), live, reindexPageSize, func(hits bsearch.DocumentMatchCollection) error {
+		batch := shadow.NewBatch()
+		for _, hit := range hits {
+			prepareReindexDocument(config, hit.Fields)
+			if err := batch.Index(hit.ID, hit.Fields); err != nil {
+				return fmt.Errorf("failed to copy document %s: %w", hit.ID, err)
+			}
+		}
+		return shadow.Batch(batch)
+	})
+	live.Close()
+	if closeErr := shadow.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close shadow index: %w", closeErr)
+	}
+	if err != nil {
+		os.RemoveAll(path)
+		return err
+	}
+
+	previousPath := path + ".previous"
+	os.RemoveAll(previousPath)
+	if err := os.Rename(livePath, previousPath); err != nil {
+		return fmt.Errorf("failed to move index aside: %w", err)
+	}
+	if err := os.Rename(path, livePath); err != nil {
+		os.Rename(previousPath, livePath)
+		return fmt.Errorf("failed to move shadow index in place: %w", err)
+	}
+	return os.RemoveAll(previousPath)
+}
+
+// recoverReindexes repairs a swap interrupted by a crash and removes the
+// shadows of unfinished builds, which are started over
+func recoverReindexes(dataDir string) error {
+	dir := filepath.Join(dataDir, reindexDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if id, ok := strings.CutSuffix(entry.Name(), ".previous"); ok {
+			livePath := filepath.Join(dataDir, id)
+			if _, err := os.Stat(livePath); os.IsNotExist(err) {
+				if err := os.Rename(path, livePath); err != nil {
+					return fmt.Errorf("failed to restore index %s: %w", id, err)
+				}
+				continue
+			}
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resumeReindexes starts the pending reindexes again after a restart
+func (s *IndexStore) resumeReindexes() error {
+	for id, config := range s.PendingReindexes() {
+		if _, _, err := s.GetIndex(id); err != nil {
+			s.setPendingReindex(id, nil)
+			continue
+		}
+		if err := s.startReindex(id, config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
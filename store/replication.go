@@ -0,0 +1,82 @@
+package store
+
+import (
+	"bright/persist"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// ReplicationPosition is the last entry of a source cluster's change feed
+// that a standby applied
+type ReplicationPosition struct {
+	Source    string    `json:"source"`
+	Index     uint64    `json:"index"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ReplicationState holds the replication position of a standby. In Raft mode
+// it is updated by the FSM and included in snapshots, so any node of the
+// standby can carry on from it.
+type ReplicationState struct {
+	position *ReplicationPosition
+	file     string
+	mu       sync.RWMutex
+}
+
+// newReplicationState loads the position persisted in dataDir
+func newReplicationState(dataDir string) (*ReplicationState, error) {
+	r := &ReplicationState{file: filepath.Join(dataDir, "replication.json")}
+
+	data, _, err := persist.ReadFile(r.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, fmt.Errorf("failed to read replication position: %w", err)
+	}
+
+	if err := sonic.Unmarshal(data, &r.position); err != nil {
+		return nil, fmt.Errorf("failed to parse replication position: %w", err)
+	}
+	return r, nil
+}
+
+// Position returns the replication position, or nil before anything was
+// replicated
+func (r *ReplicationState) Position() *ReplicationPosition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.position == nil {
+		return nil
+	}
+	position := *r.position
+	return &position
+}
+
+// SetPosition records that the change feed of source was applied up to
+// index, at the time given
+func (r *ReplicationState) SetPosition(source string, index uint64, at time.Time) error {
+	return r.Restore(&ReplicationPosition{Source: source, Index: index, UpdatedAt: at.UTC()})
+}
+
+// Restore replaces the position (used when restoring Raft snapshots)
+func (r *ReplicationState) Restore(position *ReplicationPosition) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.position = position
+	data, err := sonic.Marshal(position)
+	if err != nil {
+		return fmt.Errorf("failed to marshal replication position: %w", err)
+	}
+	if err := persist.WriteFile(r.file, data, 0644); err != nil {
+		return fmt.Errorf("failed to write replication position: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"bright/models"
+	"bright/persist"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/bytedance/sonic"
+)
+
+// ErrInvalidRoutingPolicy is returned for routing policies with an unknown
+// mode
+var ErrInvalidRoutingPolicy = errors.New("invalid routing policy")
+
+// ValidateRoutingPolicy checks a routing policy and fills in its defaults
+func ValidateRoutingPolicy(policy *models.RoutingPolicy) error {
+	switch policy.Mode {
+	case "":
+		policy.Mode = models.RoutingLocal
+	case models.RoutingLocal, models.RoutingHints, models.RoutingProxy:
+	default:
+		return fmt.Errorf("%w: mode must be %s, %s or %s", ErrInvalidRoutingPolicy, models.RoutingLocal, models.RoutingHints, models.RoutingProxy)
+	}
+	for _, tag := range policy.PreferTags {
+		if tag == "" {
+			return fmt.Errorf("%w: prefer_tags can't be empty", ErrInvalidRoutingPolicy)
+		}
+	}
+	if policy.MaxLag == 0 {
+		policy.MaxLag = models.DefaultRoutingMaxLag
+	}
+	return nil
+}
+
+// RoutingState holds the search routing policy of a cluster. It is updated
+// by the FSM and included in snapshots, so every node routes alike.
+type RoutingState struct {
+	policy *models.RoutingPolicy
+	file   string
+	mu     sync.RWMutex
+}
+
+// newRoutingState loads the policy persisted in dataDir
+func newRoutingState(dataDir string) (*RoutingState, error) {
+	r := &RoutingState{file: filepath.Join(dataDir, "routing.json")}
+
+	data, _, err := persist.ReadFile(r.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, fmt.Errorf("failed to read routing policy: %w", err)
+	}
+
+	if err := sonic.Unmarshal(data, &r.policy); err != nil {
+		return nil, fmt.Errorf("failed to parse routing policy: %w", err)
+	}
+	return r, nil
+}
+
+// Policy returns the routing policy, the default one when none was set
+func (r *RoutingState) Policy() models.RoutingPolicy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.policy == nil {
+		return models.RoutingPolicy{Mode: models.RoutingLocal, MaxLag: models.DefaultRoutingMaxLag}
+	}
+	return *r.policy
+}
+
+// Set validates and saves the routing policy
+func (r *RoutingState) Set(policy models.RoutingPolicy) error {
+	if err := ValidateRoutingPolicy(&policy); err != nil {
+		return err
+	}
+	return r.Restore(&policy)
+}
+
+// Restore replaces the policy (used when restoring Raft snapshots)
+func (r *RoutingState) Restore(policy *models.RoutingPolicy) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.policy = policy
+	data, err := sonic.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("failed to marshal routing policy: %w", err)
+	}
+	if err := persist.WriteFile(r.file, data, 0644); err != nil {
+		return fmt.Errorf("failed to write routing policy: %w", err)
+	}
+	return nil
+}
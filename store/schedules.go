@@ -0,0 +1,66 @@
+package store
+
+import (
+	"bright/models"
+	"errors"
+	"fmt"
+)
+
+// ErrScheduleNotFound is returned for unknown scheduled searches
+var ErrScheduleNotFound = errors.New("scheduled search not found")
+
+// ScheduleRegistry holds the scheduled searches kept in schedules.json. The
+// schedule runner of the leader plans their runs from it.
+type ScheduleRegistry struct {
+	schedules *fileRegistry[models.ScheduledSearch]
+}
+
+// newScheduleRegistry loads the scheduled searches persisted in dataDir
+func newScheduleRegistry(dataDir string) (*ScheduleRegistry, error) {
+	schedules, err := loadRegistry(dataDir, "schedules.json", "scheduled searches",
+		func(schedule *models.ScheduledSearch) string { return schedule.ID },
+		func(a, b *models.ScheduledSearch) bool {
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.Before(b.CreatedAt)
+			}
+			return a.ID < b.ID
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &ScheduleRegistry{schedules: schedules}, nil
+}
+
+// Put creates or replaces a scheduled search
+func (r *ScheduleRegistry) Put(schedule *models.ScheduledSearch) error {
+	return r.schedules.put(schedule)
+}
+
+// Get returns a scheduled search by ID
+func (r *ScheduleRegistry) Get(id string) (*models.ScheduledSearch, error) {
+	schedule, ok := r.schedules.get(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrScheduleNotFound, id)
+	}
+	return schedule, nil
+}
+
+// Delete removes a scheduled search
+func (r *ScheduleRegistry) Delete(id string) error {
+	found, err := r.schedules.delete(id)
+	if !found {
+		return fmt.Errorf("%w: %s", ErrScheduleNotFound, id)
+	}
+	return err
+}
+
+// List returns all scheduled searches ordered by creation time
+func (r *ScheduleRegistry) List() []*models.ScheduledSearch {
+	return r.schedules.list(nil)
+}
+
+// Restore replaces all scheduled searches (used when restoring Raft snapshots)
+func (r *ScheduleRegistry) Restore(schedules []*models.ScheduledSearch) error {
+	return r.schedules.restore(schedules)
+}
@@ -0,0 +1,244 @@
+package store
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"bright/models"
+
+	"github.com/bytedance/sonic"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultSearchCacheSize is the number of search responses cached when no
+// size is configured
+const DefaultSearchCacheSize = 1000
+
+// cachedResponses decodes cached responses, keeping numbers as they were
+// written
+var cachedResponses = sonic.Config{UseNumber: true}.Froze()
+
+var (
+	searchCacheHitsDesc = prometheus.NewDesc(
+		"search_cache_hits_total",
+		"Searches answered from the search cache.",
+		nil, nil,
+	)
+	searchCacheMissesDesc = prometheus.NewDesc(
+		"search_cache_misses_total",
+		"Cacheable searches that were not in the search cache.",
+		nil, nil,
+	)
+	searchCacheEntriesDesc = prometheus.NewDesc(
+		"search_cache_entries",
+		"Search responses in the search cache.",
+		nil, nil,
+	)
+)
+
+// searchCacheEntry is a cached response, encoded so that callers may change
+// what they get
+type searchCacheEntry struct {
+	indexID  string
+	key      string
+	response []byte
+}
+
+// SearchCache keeps the responses of recent searches of single indexes,
+// least recently used first out. Any write to an index drops its responses.
+type SearchCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // most recently used first
+	entries  map[string]*list.Element
+	byIndex  map[string]map[*list.Element]struct{}
+
+	// generations count the invalidations of each index, and cleared those
+	// of every index, so that responses of searches that overlapped a write
+	// aren't cached
+	generations map[string]uint64
+	cleared     uint64
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// newSearchCache creates a cache of up to capacity responses
+func newSearchCache(capacity int) *SearchCache {
+	return &SearchCache{
+		capacity:    capacity,
+		order:       list.New(),
+		entries:     make(map[string]*list.Element),
+		byIndex:     make(map[string]map[*list.Element]struct{}),
+		generations: make(map[string]uint64),
+	}
+}
+
+// SearchCacheKey returns the key of a search in the cache: the request as
+// normalized by the index's settings, with the hidden attributes and
+// curation that depend on the caller and the query
+func SearchCacheKey(req *models.SearchRequest) (string, error) {
+	// encoding/json sorts map keys, so equal requests have equal keys
+	key, err := json.Marshal(struct {
+		Request          *models.SearchRequest `json:"request"`
+		HiddenAttributes []string              `json:"hiddenAttributes"`
+		DefaultLimit     int                   `json:"defaultLimit"`
+		PinnedDocuments  []string              `json:"pinnedDocuments"`
+		HiddenDocuments  []string              `json:"hiddenDocuments"`
+	}{req, req.HiddenAttributes, req.DefaultLimit, req.PinnedDocuments, req.HiddenDocuments})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode search cache key: %w", err)
+	}
+	return string(key), nil
+}
+
+// SetCapacity changes the number of responses kept, dropping the least
+// recently used ones over it. Zero disables the cache.
+func (c *SearchCache) SetCapacity(capacity int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.capacity = max(capacity, 0)
+	for c.order.Len() > c.capacity {
+		c.removeLocked(c.order.Back())
+	}
+}
+
+// Enabled returns true when responses are cached
+func (c *SearchCache) Enabled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.capacity > 0
+}
+
+// Generation returns the number of times the responses of an index were
+// dropped, to pass to Put
+func (c *SearchCache) Generation(indexID string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generations[indexID] + c.cleared
+}
+
+// Get returns the cached response of a search of an index
+func (c *SearchCache) Get(indexID, key string) (*models.SearchResponse, bool) {
+	c.mu.Lock()
+	element, ok := c.entries[indexID+"\x00"+key]
+	var data []byte
+	if ok {
+		c.order.MoveToFront(element)
+		data = element.Value.(*searchCacheEntry).response
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	var response models.SearchResponse
+	if err := cachedResponses.Unmarshal(data, &response); err != nil {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	return &response, true
+}
+
+// Put caches the response of a search of an index, unless the index was
+// written since generation was read
+func (c *SearchCache) Put(indexID, key string, generation uint64, response *models.SearchResponse) {
+	data, err := sonic.Marshal(response)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.capacity == 0 || c.generations[indexID]+c.cleared != generation {
+		return
+	}
+	entryKey := indexID + "\x00" + key
+	if element, ok := c.entries[entryKey]; ok {
+		element.Value.(*searchCacheEntry).response = data
+		c.order.MoveToFront(element)
+		return
+	}
+
+	element := c.order.PushFront(&searchCacheEntry{indexID: indexID, key: key, response: data})
+	c.entries[entryKey] = element
+	if c.byIndex[indexID] == nil {
+		c.byIndex[indexID] = make(map[*list.Element]struct{})
+	}
+	c.byIndex[indexID][element] = struct{}{}
+	for c.order.Len() > c.capacity {
+		c.removeLocked(c.order.Back())
+	}
+}
+
+// Invalidate drops the responses of an index, after a write to it
+func (c *SearchCache) Invalidate(indexID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generations[indexID]++
+	for element := range c.byIndex[indexID] {
+		c.removeLocked(element)
+	}
+}
+
+// Clear drops every response, after the indexes were replaced
+func (c *SearchCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cleared++
+	c.order.Init()
+	clear(c.entries)
+	clear(c.byIndex)
+}
+
+// Counts returns the hits and misses of the cache since the node started
+func (c *SearchCache) Counts() (hits, misses uint64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+// removeLocked drops a cached response while c.mu is held
+func (c *SearchCache) removeLocked(element *list.Element) {
+	entry := c.order.Remove(element).(*searchCacheEntry)
+	delete(c.entries, entry.indexID+"\x00"+entry.key)
+	delete(c.byIndex[entry.indexID], element)
+	if len(c.byIndex[entry.indexID]) == 0 {
+		delete(c.byIndex, entry.indexID)
+	}
+}
+
+// RegisterMetrics registers the hit and miss counters and the size of the
+// cache
+func (c *SearchCache) RegisterMetrics(registerer prometheus.Registerer) error {
+	if err := registerer.Register(c); err != nil {
+		return fmt.Errorf("failed to register search cache metrics: %w", err)
+	}
+	return nil
+}
+
+// Describe implements prometheus.Collector
+func (c *SearchCache) Describe(ch chan<- *prometheus.Desc) {
+	ch <- searchCacheHitsDesc
+	ch <- searchCacheMissesDesc
+	ch <- searchCacheEntriesDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *SearchCache) Collect(ch chan<- prometheus.Metric) {
+	hits, misses := c.Counts()
+	c.mu.Lock()
+	entries := c.order.Len()
+	c.mu.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(searchCacheHitsDesc, prometheus.CounterValue, float64(hits))
+	ch <- prometheus.MustNewConstMetric(searchCacheMissesDesc, prometheus.CounterValue, float64(misses))
+	ch <- prometheus.MustNewConstMetric(searchCacheEntriesDesc, prometheus.GaugeValue, float64(entries))
+}
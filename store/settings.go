@@ -0,0 +1,29 @@
+package store
+
+import (
+	"bright/models"
+)
+
+// ExportSettings returns the settings of an index and its curation rules
+func (s *IndexStore) ExportSettings(id string) (*models.SettingsExport, error) {
+	_, config, err := s.GetIndex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := *config
+	settings.ID = ""
+	settings.PrimaryKeyInferred = false
+
+	export := &models.SettingsExport{
+		Version:  models.SettingsExportVersion,
+		Settings: &settings,
+		Rules:    make([]*models.CurationRule, 0),
+	}
+	for _, rule := range s.curation.List(id) {
+		exported := *rule
+		exported.IndexID = ""
+		export.Rules = append(export.Rules, &exported)
+	}
+	return export, nil
+}
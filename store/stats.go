@@ -0,0 +1,103 @@
+package store
+
+import "sync"
+
+// OperationStats counts the operations of this node since it started
+type OperationStats struct {
+	// DocumentsIndexed counts documents added or updated, including those
+	// applied from the Raft log and replayed from the write-ahead log
+	DocumentsIndexed uint64 `json:"documentsIndexed"`
+	DocumentsDeleted uint64 `json:"documentsDeleted"`
+	Searches         uint64 `json:"searches"`
+
+	// BytesIngested counts the bodies of the document writes this node
+	// accepted, not those forwarded to the leader
+	BytesIngested uint64 `json:"bytesIngested"`
+}
+
+// Stats counts the operations of this node, in total and by index. Counts
+// start from zero when the node starts.
+type Stats struct {
+	mu      sync.Mutex
+	total   OperationStats
+	indexes map[string]*OperationStats
+}
+
+// newStats creates empty counters
+func newStats() *Stats {
+	return &Stats{indexes: make(map[string]*OperationStats)}
+}
+
+// add applies fn to the node's counters and to those of indexID, unless it
+// is empty
+func (st *Stats) add(indexID string, fn func(counts *OperationStats)) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	fn(&st.total)
+	if indexID != "" {
+		fn(st.countsLocked(indexID))
+	}
+}
+
+// countsLocked returns the counters of an index while st.mu is held
+func (st *Stats) countsLocked(indexID string) *OperationStats {
+	counts, ok := st.indexes[indexID]
+	if !ok {
+		counts = &OperationStats{}
+		st.indexes[indexID] = counts
+	}
+	return counts
+}
+
+// RecordSearch counts a search, once for the node and once for each index
+// it searched
+func (st *Stats) RecordSearch(indexIDs ...string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.total.Searches++
+	for _, id := range indexIDs {
+		st.countsLocked(id).Searches++
+	}
+}
+
+// RecordIngest counts the bytes of a document write to an index. Writes
+// spanning indexes, such as Elasticsearch bulk requests, pass "" and only
+// count toward the node's total.
+func (st *Stats) RecordIngest(indexID string, bytes int) {
+	st.add(indexID, func(counts *OperationStats) { counts.BytesIngested += uint64(bytes) })
+}
+
+// recordIndexed counts documents added or updated
+func (st *Stats) recordIndexed(indexID string, n int) {
+	st.add(indexID, func(counts *OperationStats) { counts.DocumentsIndexed += uint64(n) })
+}
+
+// recordDeleted counts documents deleted
+func (st *Stats) recordDeleted(indexID string, n int) {
+	st.add(indexID, func(counts *OperationStats) { counts.DocumentsDeleted += uint64(n) })
+}
+
+// Total returns the counts of every index, including deleted ones
+func (st *Stats) Total() OperationStats {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.total
+}
+
+// Index returns the counts of an index
+func (st *Stats) Index(indexID string) OperationStats {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if counts, ok := st.indexes[indexID]; ok {
+		return *counts
+	}
+	return OperationStats{}
+}
+
+// reset drops the counts of a deleted index. They stay in the total.
+func (st *Stats) reset(indexID string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	delete(st.indexes, indexID)
+}
@@ -0,0 +1,203 @@
+package store
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Storage components of an index directory
+const (
+	// StorageSegments are the segment files holding the inverted index,
+	// doc values and the stored documents
+	StorageSegments = "segments"
+
+	// StorageMetadata is the index's root snapshot and metadata files
+	StorageMetadata = "metadata"
+
+	// StorageOther is everything else, such as files of an index kept in a
+	// key/value store
+	StorageOther = "other"
+)
+
+// Storage sampling bounds
+const (
+	// GrowthWindow is the period growth rates are measured over
+	GrowthWindow = 24 * time.Hour
+
+	// maxStorageSamples bounds the samples kept per index
+	maxStorageSamples = 1000
+)
+
+// StorageComponent is the disk usage of one part of an index
+type StorageComponent struct {
+	Bytes int64 `json:"bytes"`
+	Files int   `json:"files"`
+}
+
+// StorageGrowth is the change of an index's size over the samples taken in
+// the last GrowthWindow
+type StorageGrowth struct {
+	BytesPerHour float64   `json:"bytesPerHour"`
+	Since        time.Time `json:"since"`
+}
+
+// StorageReport is the disk usage of an index
+type StorageReport struct {
+	IndexID    string                      `json:"indexId"`
+	TotalBytes int64                       `json:"totalBytes"`
+	Components map[string]StorageComponent `json:"components"`
+
+	// Growth is omitted until a sample is at least a minute old
+	Growth *StorageGrowth `json:"growth,omitempty"`
+
+	Segments   *SegmentStats     `json:"segments,omitempty"`
+	Compaction *CompactionStatus `json:"compaction,omitempty"`
+}
+
+// storageSample is the size of an index at a point in time
+type storageSample struct {
+	at    time.Time
+	bytes int64
+}
+
+// storageHistory keeps the recent sizes of each index
+type storageHistory struct {
+	samples map[string][]storageSample
+	mu      sync.Mutex
+}
+
+func newStorageHistory() *storageHistory {
+	return &storageHistory{samples: make(map[string][]storageSample)}
+}
+
+// record adds a sample, dropping samples older than the window (keeping one
+// as the baseline) and the oldest ones beyond maxStorageSamples
+func (h *storageHistory) record(indexID string, bytes int64, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	samples := append(h.samples[indexID], storageSample{at: now, bytes: bytes})
+	for len(samples) > 1 && now.Sub(samples[1].at) >= GrowthWindow {
+		samples = samples[1:]
+	}
+	if len(samples) > maxStorageSamples {
+		samples = samples[len(samples)-maxStorageSamples:]
+	}
+	h.samples[indexID] = samples
+}
+
+// growth returns the growth rate of an index from its oldest sample to its
+// current size, or nil if no sample is at least a minute old
+func (h *storageHistory) growth(indexID string, bytes int64, now time.Time) *StorageGrowth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	samples := h.samples[indexID]
+	if len(samples) == 0 {
+		return nil
+	}
+	first := samples[0]
+	elapsed := now.Sub(first.at)
+	if elapsed < time.Minute {
+		return nil
+	}
+	return &StorageGrowth{
+		BytesPerHour: float64(bytes-first.bytes) / elapsed.Hours(),
+		Since:        first.at,
+	}
+}
+
+// forget drops the samples of a deleted index
+func (h *storageHistory) forget(indexID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.samples, indexID)
+}
+
+// Storage reports the disk usage of an index by component, with its growth
+// since the samples taken by StartStorageSampling
+func (s *IndexStore) Storage(indexID string) (*StorageReport, error) {
+	if _, _, err := s.GetIndex(indexID); err != nil {
+		return nil, err
+	}
+
+	report := storageUsage(indexID, filepath.Join(s.dataDir, indexID))
+	report.Growth = s.storage.growth(indexID, report.TotalBytes, time.Now())
+	report.Segments, _ = s.Segments(indexID)
+	report.Compaction = s.Compaction(indexID)
+	return report, nil
+}
+
+// SampleStorage records the size of every index for growth rates
+func (s *IndexStore) SampleStorage(now time.Time) {
+	for id := range s.GetAllConfigs() {
+		s.storage.record(id, dirSize(filepath.Join(s.dataDir, id)), now)
+	}
+}
+
+// StartStorageSampling samples index sizes every interval until the store
+// is closed. A zero interval disables sampling.
+func (s *IndexStore) StartStorageSampling(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	s.stopSampling = make(chan struct{})
+	stop := s.stopSampling
+
+	s.SampleStorage(time.Now())
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				s.SampleStorage(now)
+			}
+		}
+	}()
+}
+
+// storageUsage walks an index directory and sorts its files into components
+func storageUsage(indexID, path string) *StorageReport {
+	report := &StorageReport{
+		IndexID: indexID,
+		Components: map[string]StorageComponent{
+			StorageSegments: {},
+			StorageMetadata: {},
+			StorageOther:    {},
+		},
+	}
+
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		component := StorageOther
+		switch name := d.Name(); {
+		case strings.HasSuffix(name, ".zap"):
+			component = StorageSegments
+		case name == "root.bolt" || name == "index_meta.json":
+			component = StorageMetadata
+		}
+
+		usage := report.Components[component]
+		usage.Bytes += info.Size()
+		usage.Files++
+		report.Components[component] = usage
+		report.TotalBytes += info.Size()
+		return nil
+	})
+	return report
+}
@@ -4,16 +4,28 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"sort"
-	"strings"
 	"sync"
 
+	"bright/enrich"
+	"bright/jobs"
 	"bright/models"
+	"bright/persist"
+	"bright/search"
 
 	"github.com/blevesearch/bleve/v2"
 	"github.com/bytedance/sonic"
+	"golang.org/x/sync/errgroup"
 )
 
+// DefaultSubBatchSize is the number of documents per concurrently indexed sub-batch
+const DefaultSubBatchSize = 1000
+
+// filterPageSize is the number of hits fetched per page when resolving a filter
+const filterPageSize = 10000
+
 // IndexStore manages all indexes
 type IndexStore struct {
 	indexes    map[string]bleve.Index
@@ -22,42 +34,281 @@ type IndexStore struct {
 	mu         sync.RWMutex
 	dataDir    string
 	configFile string
+	wal        *WAL
+
+	idempotency *IdempotencyRegistry
+	queries     *QueryRegistry
+	curation    *CurationRegistry
+	analytics   *Analytics
+	stats       *Stats
+	alerts      *AlertRegistry
+	schedules   *ScheduleRegistry
+	aliases     *AliasRegistry
+	replication *ReplicationState
+	routing     *RoutingState
+	settings    *ClusterSettings
+	searchCache *SearchCache
+	storage     *storageHistory
+	compactions *compactions
+	conflicts   *conflicts
+	enrichers   *enrich.Registry
+	jobs        *jobs.Manager
+
+	// Documents buffered until their index's next refresh (see Refresh)
+	pending   map[string]*pendingWrites
+	pendingMu sync.Mutex
+	refreshMu sync.Mutex
+
+	// Parallel indexing of large batches (see SetIndexParallelism)
+	indexWorkers int
+	subBatchSize int
+
+	// Index type and key/value store of new indexes (see
+	// SetIndexTypeDefaults)
+	defaultIndexType string
+	defaultKVStore   string
+
+	// Closed to stop storage sampling (see StartStorageSampling)
+	stopSampling chan struct{}
+
+	// Stops background compaction and waits for a running one (see
+	// StartCompaction)
+	stopCompaction func()
+
+	// Shadow reindexes of this node (guarded by mu) and the settings of
+	// those not swapped in yet (see StartReindex)
+	reindexes        map[string]*reindex
+	pendingReindexes map[string]*models.IndexConfig
+	reindexMu        sync.Mutex
+
+	// Indexes pinned for cursor searches (see OpenPointInTime)
+	pointsInTime pointsInTime
+
+	// Called after an index is deleted (see OnIndexDeleted)
+	deleteListeners []func(indexID string)
+	listenersMu     sync.Mutex
 }
 
-var store *IndexStore
-var once sync.Once
+// New opens the store in the specified data directory, loading existing
+// index configurations and opening their indexes
+func New(dataDir string) (*IndexStore, error) {
+	s := &IndexStore{
+		indexes:      make(map[string]bleve.Index),
+		configs:      make(map[string]*models.IndexConfig),
+		indexLocks:   make(map[string]*sync.RWMutex),
+		dataDir:      dataDir,
+		configFile:   filepath.Join(dataDir, "configs.json"),
+		indexWorkers: runtime.GOMAXPROCS(0),
+		subBatchSize: DefaultSubBatchSize,
+		enrichers:    enrich.NewRegistry(),
+		pending:      make(map[string]*pendingWrites),
+		storage:      newStorageHistory(),
+		compactions:  newCompactions(),
+		conflicts:    newConflicts(),
+		jobs:         jobs.NewManager(jobs.Options{}),
+		reindexes:    make(map[string]*reindex),
+		stats:        newStats(),
+		searchCache:  newSearchCache(DefaultSearchCacheSize),
+	}
+
+	if err := recoverReindexes(dataDir); err != nil {
+		return nil, err
+	}
 
-// Initialize initializes the store with the specified data directory
-// Must be called before GetStore() if you want to use a custom data directory
-// Returns the initialized IndexStore
-func Initialize(dataDir string) *IndexStore {
-	once.Do(func() {
-		store = &IndexStore{
-			indexes:    make(map[string]bleve.Index),
-			configs:    make(map[string]*models.IndexConfig),
-			indexLocks: make(map[string]*sync.RWMutex),
-			dataDir:    dataDir,
-			configFile: filepath.Join(dataDir, "configs.json"),
-		}
-		store.loadConfigs()
+	if err := cleanArchives(dataDir); err != nil {
+		return nil, err
+	}
+
+	if err := s.loadConfigs(); err != nil {
+		return nil, err
+	}
+
+	if err := s.loadPendingReindexes(); err != nil {
+		return nil, err
+	}
+
+	idempotency, err := newIdempotencyRegistry(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	s.idempotency = idempotency
+
+	queries, err := newQueryRegistry(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	s.queries = queries
+
+	curation, err := newCurationRegistry(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	s.curation = curation
+
+	analytics, err := newAnalytics(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	s.analytics = analytics
+
+	alerts, err := newAlertRegistry(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	s.alerts = alerts
+
+	schedules, err := newScheduleRegistry(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	s.schedules = schedules
+
+	aliases, err := newAliasRegistry(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	s.aliases = aliases
+
+	replication, err := newReplicationState(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	s.replication = replication
+
+	routing, err := newRoutingState(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	s.routing = routing
+
+	settings, err := newClusterSettings(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	s.settings = settings
+
+	// Deleted partitions leave their aliases, and deleted indexes their
+	// experiment counts and points in time. A failed save is retried with
+	// the registry's next change.
+	s.OnIndexDeleted(func(indexID string) {
+		_ = aliases.removeIndex(indexID)
+		analytics.Reset(indexID)
+		s.stats.reset(indexID)
+		s.pointsInTime.closeIndex(indexID)
+		s.searchCache.Invalidate(indexID)
 	})
-	return store
+
+	if err := s.resumeReindexes(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Idempotency returns the registry of applied idempotency keys
+func (s *IndexStore) Idempotency() *IdempotencyRegistry {
+	return s.idempotency
+}
+
+// Queries returns the registry of stored queries
+func (s *IndexStore) Queries() *QueryRegistry {
+	return s.queries
+}
+
+// Curation returns the registry of curation rules
+func (s *IndexStore) Curation() *CurationRegistry {
+	return s.curation
+}
+
+// Analytics returns the counts of ranking experiments
+func (s *IndexStore) Analytics() *Analytics {
+	return s.analytics
+}
+
+// Stats returns the operation counts of this node
+func (s *IndexStore) Stats() *Stats {
+	return s.stats
+}
+
+// AlertRules returns the registry of alerting rules
+func (s *IndexStore) AlertRules() *AlertRegistry {
+	return s.alerts
+}
+
+// Schedules returns the registry of scheduled searches
+func (s *IndexStore) Schedules() *ScheduleRegistry {
+	return s.schedules
+}
+
+// Aliases returns the registry of index aliases
+func (s *IndexStore) Aliases() *AliasRegistry {
+	return s.aliases
+}
+
+// Replication returns how far this standby applied the change feed of its
+// source
+func (s *IndexStore) Replication() *ReplicationState {
+	return s.replication
+}
+
+// Routing returns the search routing policy of the cluster
+func (s *IndexStore) Routing() *RoutingState {
+	return s.routing
+}
+
+// Settings returns the runtime settings of the cluster
+func (s *IndexStore) Settings() *ClusterSettings {
+	return s.settings
 }
 
-// GetStore returns the singleton instance of IndexStore
-func GetStore() *IndexStore {
-	once.Do(func() {
-		// Default initialization if Initialize was not called
-		store = &IndexStore{
-			indexes:    make(map[string]bleve.Index),
-			configs:    make(map[string]*models.IndexConfig),
-			indexLocks: make(map[string]*sync.RWMutex),
-			dataDir:    "./data",
-			configFile: "./data/configs.json",
+// SearchCache returns the cache of search responses
+func (s *IndexStore) SearchCache() *SearchCache {
+	return s.searchCache
+}
+
+// Jobs returns the background jobs of this node
+func (s *IndexStore) Jobs() *jobs.Manager {
+	return s.jobs
+}
+
+// Close refreshes buffered documents, then closes all indexes and the
+// write-ahead log
+func (s *IndexStore) Close() error {
+	firstErr := s.refreshAll()
+
+	if s.stopSampling != nil {
+		close(s.stopSampling)
+		s.stopSampling = nil
+	}
+	if s.stopCompaction != nil {
+		s.stopCompaction()
+		s.stopCompaction = nil
+	}
+	s.stopReindexes()
+	s.pointsInTime.closeAll()
+
+	if err := s.analytics.save(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, index := range s.indexes {
+		if err := index.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close index %s: %w", id, err)
 		}
-		store.loadConfigs()
-	})
-	return store
+	}
+	s.indexes = make(map[string]bleve.Index)
+
+	if s.wal != nil {
+		if err := s.wal.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		s.wal = nil
+	}
+
+	return firstErr
 }
 
 // getIndexLock returns the lock for a specific index, creating it if necessary
@@ -88,13 +339,17 @@ func (s *IndexStore) CreateIndex(config *models.IndexConfig) error {
 		return fmt.Errorf("failed to create data directory: %w", err)
 	}
 
+	if err := s.resolveIndexType(config); err != nil {
+		return fmt.Errorf("invalid index settings: %w", err)
+	}
+
 	indexPath := filepath.Join(s.dataDir, config.ID)
 
 	var index bleve.Index
 	var err error
 
 	// Check if index directory already exists on disk
-	if _, statErr := os.Stat(indexPath); statErr == nil {
+	if _, statErr := os.Stat(indexPath); statErr == nil && !inMemory(config) {
 		// Directory exists, try to open existing index
 		index, err = bleve.Open(indexPath)
 		if err != nil {
@@ -116,22 +371,16 @@ func (s *IndexStore) CreateIndex(config *models.IndexConfig) error {
 	s.indexes[config.ID] = index
 	s.configs[config.ID] = config
 	s.indexLocks[config.ID] = &sync.RWMutex{}
-	s.saveConfigs()
-
-	return nil
+	return s.saveConfigs()
 }
 
 // createNewIndex creates a new bleve index with the given config
 func (s *IndexStore) createNewIndex(indexPath string, config *models.IndexConfig) (bleve.Index, error) {
-	indexMapping := bleve.NewIndexMapping()
-	if len(config.ExcludeAttributes) > 0 {
-		defaultMapping := indexMapping.DefaultMapping
-		for _, attr := range config.ExcludeAttributes {
-			disabledMapping := bleve.NewDocumentDisabledMapping()
-			defaultMapping.AddSubDocumentMapping(attr, disabledMapping)
-		}
+	indexMapping, err := buildIndexMapping(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid index settings: %w", err)
 	}
-	index, err := bleve.New(indexPath, indexMapping)
+	index, err := newBleveIndex(indexPath, config, indexMapping)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create index: %w", err)
 	}
@@ -154,6 +403,18 @@ func (s *IndexStore) GetIndex(id string) (bleve.Index, *models.IndexConfig, erro
 
 // DeleteIndex deletes an index
 func (s *IndexStore) DeleteIndex(id string) error {
+	if err := s.deleteIndex(id); err != nil {
+		return err
+	}
+	s.indexDeleted(id)
+	return nil
+}
+
+// deleteIndex closes an index and removes its data
+func (s *IndexStore) deleteIndex(id string) error {
+	s.CancelReindex(id)
+	s.Refresh(id)
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -176,9 +437,16 @@ func (s *IndexStore) DeleteIndex(id string) error {
 	delete(s.indexes, id)
 	delete(s.configs, id)
 	delete(s.indexLocks, id)
-	s.saveConfigs()
-
-	return nil
+	s.storage.forget(id)
+	s.compactions.forget(id)
+	s.conflicts.forget(id)
+	if err := s.queries.DeleteIndex(id); err != nil {
+		return err
+	}
+	if err := s.curation.DeleteIndex(id); err != nil {
+		return err
+	}
+	return s.saveConfigs()
 }
 
 // UpdateIndex updates index configuration
@@ -191,10 +459,12 @@ func (s *IndexStore) UpdateIndex(id string, config *models.IndexConfig) error {
 	}
 
 	config.ID = id // Ensure ID doesn't change
+	// The index type stays as created; a reindex changes it
+	config.IndexType = s.configs[id].IndexType
+	config.KVStore = s.configs[id].KVStore
 	s.configs[id] = config
-	s.saveConfigs()
-
-	return nil
+	s.searchCache.Invalidate(id)
+	return s.saveConfigs()
 }
 
 // ListIndexes returns all index configurations with pagination
@@ -207,6 +477,10 @@ func (s *IndexStore) ListIndexes(limit, offset int) []*models.IndexConfig {
 	for _, config := range s.configs {
 		allConfigs = append(allConfigs, config)
 	}
+	// Pages are stable when sorted by ID
+	sort.Slice(allConfigs, func(i, j int) bool {
+		return allConfigs[i].ID < allConfigs[j].ID
+	})
 
 	// Apply pagination
 	start := offset
@@ -223,26 +497,52 @@ func (s *IndexStore) ListIndexes(limit, offset int) []*models.IndexConfig {
 }
 
 // loadConfigs loads index configurations from disk
-func (s *IndexStore) loadConfigs() {
+func (s *IndexStore) loadConfigs() error {
 	// Create data directory if it doesn't exist
-	os.MkdirAll(s.dataDir, 0755)
+	if err := os.MkdirAll(s.dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
 
-	data, err := os.ReadFile(s.configFile)
+	data, recovered, err := persist.ReadFile(s.configFile)
 	if err != nil {
-		return // No configs to load
+		if os.IsNotExist(err) {
+			return nil // No configs to load
+		}
+		return fmt.Errorf("failed to read index configs: %w", err)
 	}
 
 	var configs map[string]*models.IndexConfig
 	if err := sonic.Unmarshal(data, &configs); err != nil {
-		return
+		return fmt.Errorf("failed to parse index configs: %w", err)
+	}
+	if configs == nil {
+		configs = make(map[string]*models.IndexConfig)
 	}
 
 	s.configs = configs
 
+	// Rewrite the previous generation so the current one is intact again
+	if recovered {
+		if err := s.saveConfigs(); err != nil {
+			return err
+		}
+	}
+
 	// Open existing indexes or recreate if missing
-	for id := range configs {
+	for id, config := range configs {
 		indexPath := filepath.Join(s.dataDir, id)
 
+		// In-memory indexes start empty again
+		if inMemory(config) {
+			index, err := s.createNewIndex(indexPath, config)
+			if err != nil {
+				continue
+			}
+			s.indexes[id] = index
+			s.indexLocks[id] = &sync.RWMutex{}
+			continue
+		}
+
 		// Check if index directory exists
 		if _, err := os.Stat(indexPath); os.IsNotExist(err) {
 			// Index directory doesn't exist, recreate it
@@ -269,16 +569,22 @@ func (s *IndexStore) loadConfigs() {
 			s.indexLocks[id] = &sync.RWMutex{}
 		}
 	}
+
+	return nil
 }
 
-// saveConfigs saves index configurations to disk
-func (s *IndexStore) saveConfigs() {
+// saveConfigs atomically saves index configurations to disk
+func (s *IndexStore) saveConfigs() error {
 	data, err := sonic.ConfigDefault.MarshalIndent(s.configs, "", "  ")
 	if err != nil {
-		return
+		return fmt.Errorf("failed to marshal index configs: %w", err)
 	}
 
-	os.WriteFile(s.configFile, data, 0644)
+	if err := persist.WriteFile(s.configFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to save index configs: %w", err)
+	}
+
+	return nil
 }
 
 // GetAllConfigs returns all index configurations (for snapshotting)
@@ -293,14 +599,25 @@ func (s *IndexStore) GetAllConfigs() map[string]*models.IndexConfig {
 	return configs
 }
 
-// RestoreConfigs restores index configurations from snapshot
+// RestoreConfigs restores index configurations from snapshot. Indexes this
+// node doesn't have open, as when it restores onto an empty data directory,
+// are opened from disk or created empty.
 func (s *IndexStore) RestoreConfigs(configs map[string]*models.IndexConfig) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	s.configs = configs
-	s.saveConfigs()
-	return nil
+	s.searchCache.Clear()
+	for id, config := range configs {
+		if _, exists := s.indexes[id]; exists {
+			continue
+		}
+		config.ID = id
+		if err := s.CreateIndexInternal(config); err != nil {
+			return fmt.Errorf("failed to open restored index %s: %w", id, err)
+		}
+	}
+	return s.saveConfigs()
 }
 
 // Internal methods (lock-free, called by FSM)
@@ -316,13 +633,17 @@ func (s *IndexStore) CreateIndexInternal(config *models.IndexConfig) error {
 		return fmt.Errorf("failed to create data directory: %w", err)
 	}
 
+	if err := s.resolveIndexType(config); err != nil {
+		return fmt.Errorf("invalid index settings: %w", err)
+	}
+
 	indexPath := filepath.Join(s.dataDir, config.ID)
 
 	var index bleve.Index
 	var err error
 
 	// Check if index directory already exists on disk
-	if _, statErr := os.Stat(indexPath); statErr == nil {
+	if _, statErr := os.Stat(indexPath); statErr == nil && !inMemory(config) {
 		// Directory exists, try to open existing index
 		index, err = bleve.Open(indexPath)
 		if err != nil {
@@ -344,13 +665,14 @@ func (s *IndexStore) CreateIndexInternal(config *models.IndexConfig) error {
 	s.indexes[config.ID] = index
 	s.configs[config.ID] = config
 	s.indexLocks[config.ID] = &sync.RWMutex{}
-	s.saveConfigs()
-
-	return nil
+	return s.saveConfigs()
 }
 
 // DeleteIndexInternal deletes an index without locking (called by FSM)
 func (s *IndexStore) DeleteIndexInternal(id string) error {
+	s.CancelReindex(id)
+	s.Refresh(id)
+
 	index, exists := s.indexes[id]
 	if !exists {
 		return fmt.Errorf("index %s not found", id)
@@ -370,11 +692,43 @@ func (s *IndexStore) DeleteIndexInternal(id string) error {
 	delete(s.indexes, id)
 	delete(s.configs, id)
 	delete(s.indexLocks, id)
-	s.saveConfigs()
-
+	s.storage.forget(id)
+	s.compactions.forget(id)
+	s.conflicts.forget(id)
+	if err := s.queries.DeleteIndex(id); err != nil {
+		return err
+	}
+	if err := s.curation.DeleteIndex(id); err != nil {
+		return err
+	}
+	if err := s.saveConfigs(); err != nil {
+		return err
+	}
+	s.indexDeleted(id)
 	return nil
 }
 
+// OnIndexDeleted adds a listener called after each index deletion, whether
+// requested on this node or applied from the Raft log. Listeners run on the
+// deleting goroutine, which may be the Raft FSM's, so they must not wait for
+// Raft commands.
+func (s *IndexStore) OnIndexDeleted(listener func(indexID string)) {
+	s.listenersMu.Lock()
+	defer s.listenersMu.Unlock()
+	s.deleteListeners = append(s.deleteListeners, listener)
+}
+
+// indexDeleted calls the deletion listeners
+func (s *IndexStore) indexDeleted(id string) {
+	s.listenersMu.Lock()
+	listeners := slices.Clone(s.deleteListeners)
+	s.listenersMu.Unlock()
+
+	for _, listener := range listeners {
+		listener(id)
+	}
+}
+
 // UpdateIndexInternal updates index configuration without locking (called by FSM)
 func (s *IndexStore) UpdateIndexInternal(id string, config *models.IndexConfig) error {
 	if _, exists := s.indexes[id]; !exists {
@@ -382,38 +736,179 @@ func (s *IndexStore) UpdateIndexInternal(id string, config *models.IndexConfig)
 	}
 
 	config.ID = id // Ensure ID doesn't change
+	// The index type stays as created; a reindex changes it
+	config.IndexType = s.configs[id].IndexType
+	config.KVStore = s.configs[id].KVStore
 	s.configs[id] = config
-	s.saveConfigs()
-
-	return nil
+	s.searchCache.Invalidate(id)
+	return s.saveConfigs()
 }
 
 // AddDocumentsInternal adds documents to an index without locking (called by FSM)
 func (s *IndexStore) AddDocumentsInternal(indexID string, documents []map[string]any) error {
+	return s.AddDocuments(indexID, "", documents)
+}
+
+// AddDocuments indexes documents keyed by primaryKey (the index's configured
+// primary key if empty). When the write-ahead log is enabled the batch is
+// journaled before indexing.
+func (s *IndexStore) AddDocuments(indexID, primaryKey string, documents []map[string]any) error {
 	s.mu.RLock()
-	index, exists := s.indexes[indexID]
+	_, exists := s.indexes[indexID]
 	config := s.configs[indexID]
+	wal := s.wal
 	s.mu.RUnlock()
 
 	if !exists {
 		return fmt.Errorf("index %s not found", indexID)
 	}
 
+	// With a refresh interval the batch is buffered (after journaling) and
+	// indexed with the next refresh
+	interval, _ := ParseRefreshInterval(config)
+
+	var seq uint64
+	if wal != nil {
+		var err error
+		if seq, err = wal.Journal(indexID, primaryKey, documents); err != nil {
+			return err
+		}
+	}
+
+	if interval > 0 {
+		return s.bufferDocuments(indexID, primaryKey, documents, seq, interval)
+	}
+
+	// Documents buffered before the interval was removed go first
+	s.Refresh(indexID)
+
+	if wal == nil {
+		return s.indexDocuments(indexID, primaryKey, documents)
+	}
+
+	if err := s.indexDocuments(indexID, primaryKey, documents); err != nil {
+		// The batch was rejected, replaying it would fail the same way
+		wal.Discard(seq)
+		return err
+	}
+
+	return wal.Commit(seq)
+}
+
+// indexDocuments commits documents to an index, splitting large batches
+// across workers
+func (s *IndexStore) indexDocuments(indexID, primaryKey string, documents []map[string]any) error {
+	s.mu.RLock()
+	_, exists := s.indexes[indexID]
+	config := s.configs[indexID]
+	s.mu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("index %s not found", indexID)
+	}
+
+	if primaryKey == "" {
+		primaryKey = config.PrimaryKey
+	}
+
+	// Normalization and detection are deterministic, so replicas and WAL
+	// replays agree
+	if len(config.NumericFields) > 0 {
+		for _, doc := range documents {
+			normalizeNumbers(config.NumericFields, doc)
+		}
+	}
+	if config.Language != nil {
+		for _, doc := range documents {
+			tagLanguage(config.Language, doc)
+		}
+	}
+
+	// Resolve document IDs up front; a later duplicate replaces an earlier
+	// one in place so sub-batches never race on the same ID
+	ids := make([]string, 0, len(documents))
+	docs := make([]map[string]any, 0, len(documents))
+	positions := make(map[string]int, len(documents))
+	for _, doc := range documents {
+		id, ok := doc[primaryKey]
+		if !ok || id == nil {
+			return fmt.Errorf("document missing primary key %s", primaryKey)
+		}
+		docID := DocumentID(id)
+
+		if pos, seen := positions[docID]; seen {
+			docs[pos] = doc
+			continue
+		}
+		positions[docID] = len(ids)
+		ids = append(ids, docID)
+		docs = append(docs, doc)
+	}
+
 	indexLock := s.getIndexLock(indexID)
 	indexLock.Lock()
 	defer indexLock.Unlock()
 
-	batch := index.NewBatch()
+	index, err := s.lockedIndex(indexID)
+	if err != nil {
+		return err
+	}
 
-	for _, doc := range documents {
-		var docID string
-		if id, ok := doc[config.PrimaryKey]; ok && id != nil {
-			docID = fmt.Sprintf("%v", id)
-		} else {
-			return fmt.Errorf("document missing primary key %s", config.PrimaryKey)
+	if config.Conflicts != nil {
+		ids, docs, err = s.resolveConflicts(index, indexID, config.Conflicts, ids, docs)
+		if err != nil {
+			return err
 		}
+	}
+
+	s.mu.RLock()
+	workers, subBatchSize := s.indexWorkers, s.subBatchSize
+	s.mu.RUnlock()
 
-		if err := batch.Index(docID, doc); err != nil {
+	err = indexBatches(index, ids, docs, workers, subBatchSize)
+	// Sub-batches indexed before a failure were committed
+	s.searchCache.Invalidate(indexID)
+	if err != nil {
+		return err
+	}
+	s.stats.recordIndexed(indexID, len(ids))
+
+	s.mirror(indexID, ids, func(shadow bleve.Index, config *models.IndexConfig) error {
+		for _, doc := range docs {
+			prepareReindexDocument(config, doc)
+		}
+		return indexBatches(shadow, ids, docs, workers, subBatchSize)
+	})
+	return nil
+}
+
+// indexBatches commits documents to an index, splitting batches bigger than
+// subBatchSize across workers
+func indexBatches(index bleve.Index, ids []string, docs []map[string]any, workers, subBatchSize int) error {
+	if workers <= 1 || subBatchSize <= 0 || len(ids) <= subBatchSize {
+		return indexBatch(index, ids, docs)
+	}
+
+	// Large batch: index sub-batches concurrently into the same index.
+	// Each sub-batch is atomic on its own; a failed request may be retried
+	// safely since documents are keyed by ID.
+	var group errgroup.Group
+	group.SetLimit(workers)
+	for start := 0; start < len(ids); start += subBatchSize {
+		end := min(start+subBatchSize, len(ids))
+		group.Go(func() error {
+			return indexBatch(index, ids[start:end], docs[start:end])
+		})
+	}
+
+	return group.Wait()
+}
+
+// indexBatch commits documents to an index in a single bleve batch
+func indexBatch(index bleve.Index, ids []string, docs []map[string]any) error {
+	batch := index.NewBatch()
+	for i, doc := range docs {
+		if err := batch.Index(ids[i], doc); err != nil {
 			return fmt.Errorf("failed to index document: %w", err)
 		}
 	}
@@ -425,106 +920,213 @@ func (s *IndexStore) AddDocumentsInternal(indexID string, documents []map[string
 	return nil
 }
 
+// SetIndexParallelism configures how large document batches are indexed:
+// batches bigger than subBatchSize are split and indexed by up to workers
+// goroutines concurrently. workers <= 1 disables splitting.
+func (s *IndexStore) SetIndexParallelism(workers, subBatchSize int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.indexWorkers = workers
+	s.subBatchSize = subBatchSize
+}
+
+// EnableWAL turns on write-ahead journaling of document batches (single-node
+// mode only). Batches left uncommitted by a previous crash are replayed and
+// the number of replayed batches is returned.
+func (s *IndexStore) EnableWAL() (int, error) {
+	wal, pending, err := openWAL(s.dataDir)
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for _, entry := range pending {
+		if _, _, err := s.GetIndex(entry.IndexID); err != nil {
+			// The index was deleted after the batch was accepted
+			continue
+		}
+		if err := s.indexDocuments(entry.IndexID, entry.PrimaryKey, entry.Documents); err != nil {
+			wal.Close()
+			return replayed, fmt.Errorf("failed to replay write-ahead log entry %d: %w", entry.Seq, err)
+		}
+		replayed++
+	}
+
+	// Everything in the log is now committed
+	wal.mu.Lock()
+	err = wal.reset()
+	wal.mu.Unlock()
+	if err != nil {
+		wal.Close()
+		return replayed, err
+	}
+
+	s.mu.Lock()
+	s.wal = wal
+	s.mu.Unlock()
+
+	return replayed, nil
+}
+
 // DeleteDocumentInternal deletes a document without locking (called by FSM)
 func (s *IndexStore) DeleteDocumentInternal(indexID, documentID string) error {
-	s.mu.RLock()
-	index, exists := s.indexes[indexID]
-	s.mu.RUnlock()
-
-	if !exists {
-		return fmt.Errorf("index %s not found", indexID)
+	if _, err := s.lockedIndex(indexID); err != nil {
+		return err
 	}
 
+	// Buffered documents are indexed first, so this write does not overtake them
+	s.Refresh(indexID)
+
 	indexLock := s.getIndexLock(indexID)
 	indexLock.Lock()
 	defer indexLock.Unlock()
 
+	index, err := s.lockedIndex(indexID)
+	if err != nil {
+		return err
+	}
+
 	if err := index.Delete(documentID); err != nil {
 		return fmt.Errorf("failed to delete document: %w", err)
 	}
+	s.stats.recordDeleted(indexID, 1)
+	s.searchCache.Invalidate(indexID)
 
+	s.mirror(indexID, []string{documentID}, func(shadow bleve.Index, _ *models.IndexConfig) error {
+		return shadow.Delete(documentID)
+	})
 	return nil
 }
 
 // DeleteDocumentsInternal deletes multiple documents without locking (called by FSM)
 func (s *IndexStore) DeleteDocumentsInternal(indexID, filter string, ids []string) error {
-	s.mu.RLock()
-	index, exists := s.indexes[indexID]
-	s.mu.RUnlock()
-
-	if !exists {
-		return fmt.Errorf("index %s not found", indexID)
+	if _, err := s.lockedIndex(indexID); err != nil {
+		return err
 	}
 
+	// Buffered documents are indexed first, so this write does not overtake them
+	s.Refresh(indexID)
+
 	indexLock := s.getIndexLock(indexID)
 	indexLock.Lock()
 	defer indexLock.Unlock()
 
+	index, err := s.lockedIndex(indexID)
+	if err != nil {
+		return err
+	}
+
+	// Without specific IDs, the documents matching the filter are deleted
+	if len(ids) == 0 {
+		if filter == "" {
+			return fmt.Errorf("must provide ids or filter parameter to delete documents")
+		}
+		if ids, err = matchingDocumentIDs(index, filter); err != nil {
+			return err
+		}
+	}
+
 	batch := index.NewBatch()
+	for _, id := range ids {
+		batch.Delete(id)
+	}
 
-	// If specific IDs are provided
-	if len(ids) > 0 {
+	if err := index.Batch(batch); err != nil {
+		return fmt.Errorf("failed to delete documents: %w", err)
+	}
+	s.stats.recordDeleted(indexID, len(ids))
+	s.searchCache.Invalidate(indexID)
+
+	s.mirror(indexID, ids, func(shadow bleve.Index, _ *models.IndexConfig) error {
+		batch := shadow.NewBatch()
 		for _, id := range ids {
 			batch.Delete(id)
 		}
-	} else if filter != "" {
-		// Search with filter and delete matching documents using pagination
-		query := bleve.NewQueryStringQuery(filter)
-		pageSize := 10000
-		offset := 0
+		return shadow.Batch(batch)
+	})
+	return nil
+}
 
-		for {
-			searchRequest := bleve.NewSearchRequest(query)
-			searchRequest.From = offset
-			searchRequest.Size = pageSize
+// MatchingDocumentIDs returns the IDs of all documents matching a query
+// string filter, ordered by ID. The Raft leader resolves filters with it so
+// every node deletes the same documents.
+func (s *IndexStore) MatchingDocumentIDs(indexID, filter string) ([]string, error) {
+	s.mu.RLock()
+	index, exists := s.indexes[indexID]
+	s.mu.RUnlock()
 
-			searchResult, err := index.Search(searchRequest)
-			if err != nil {
-				return fmt.Errorf("failed to search: %w", err)
-			}
+	if !exists {
+		return nil, fmt.Errorf("index %s not found", indexID)
+	}
 
-			// If no results, we're done
-			if len(searchResult.Hits) == 0 {
-				break
-			}
+	// Buffered documents are indexed first, so this write does not overtake them
+	s.Refresh(indexID)
 
-			// Delete documents from this page
-			for _, hit := range searchResult.Hits {
-				batch.Delete(hit.ID)
-			}
+	indexLock := s.getIndexLock(indexID)
+	indexLock.RLock()
+	defer indexLock.RUnlock()
 
-			// If we got fewer results than page size, we've reached the end
-			if len(searchResult.Hits) < pageSize {
-				break
-			}
+	return matchingDocumentIDs(index, filter)
+}
 
-			offset += pageSize
-		}
-	} else {
-		return fmt.Errorf("must provide ids or filter parameter to delete documents")
+// matchingDocumentIDs pages through all matches of a filter in ID order
+func matchingDocumentIDs(index bleve.Index, filter string) ([]string, error) {
+	query, err := search.FilterQuery(filter)
+	if err != nil {
+		return nil, err
 	}
+	ids := []string{}
+
+	var after []string
+	for {
+		searchRequest := bleve.NewSearchRequestOptions(query, filterPageSize, 0, false)
+		searchRequest.SortBy([]string{"_id"})
+		if after != nil {
+			searchRequest.SetSearchAfter(after)
+		}
 
-	if err := index.Batch(batch); err != nil {
-		return fmt.Errorf("failed to delete documents: %w", err)
-	}
+		searchResult, err := index.Search(searchRequest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search: %w", err)
+		}
 
-	return nil
+		for _, hit := range searchResult.Hits {
+			ids = append(ids, hit.ID)
+		}
+
+		// If we got fewer results than page size, we've reached the end
+		if len(searchResult.Hits) < filterPageSize {
+			return ids, nil
+		}
+
+		after = []string{searchResult.Hits[len(searchResult.Hits)-1].ID}
+	}
 }
 
 // UpdateDocumentInternal updates a document without locking (called by FSM)
 func (s *IndexStore) UpdateDocumentInternal(indexID, documentID string, updates map[string]any) error {
 	s.mu.RLock()
-	index, exists := s.indexes[indexID]
+	_, exists := s.indexes[indexID]
+	config := s.configs[indexID]
 	s.mu.RUnlock()
 
 	if !exists {
 		return fmt.Errorf("index %s not found", indexID)
 	}
 
+	// Buffered documents are indexed first, so this write does not overtake them
+	s.Refresh(indexID)
+
 	indexLock := s.getIndexLock(indexID)
 	indexLock.Lock()
 	defer indexLock.Unlock()
 
+	index, err := s.lockedIndex(indexID)
+	if err != nil {
+		return err
+	}
+
 	// Get existing document by searching for it
 	query := bleve.NewDocIDQuery([]string{documentID})
 	searchRequest := bleve.NewSearchRequest(query)
@@ -542,53 +1144,129 @@ func (s *IndexStore) UpdateDocumentInternal(indexID, documentID string, updates
 		}
 	}
 
+	normalizeNumbers(config.NumericFields, updates)
 	for key, value := range updates {
 		existingData[key] = value
 	}
+	if config.Language != nil {
+		retagLanguage(config.Language, existingData, updates)
+	}
 
 	// Re-index the document
 	if err := index.Index(documentID, existingData); err != nil {
 		return fmt.Errorf("failed to update document: %w", err)
 	}
+	s.stats.recordIndexed(indexID, 1)
+	s.searchCache.Invalidate(indexID)
 
+	s.mirror(indexID, []string{documentID}, func(shadow bleve.Index, config *models.IndexConfig) error {
+		prepareReindexDocument(config, existingData)
+		return shadow.Index(documentID, existingData)
+	})
 	return nil
 }
 
-// DetectPrimaryKey analyzes documents and returns the primary key attribute
-// Returns error if no candidates or multiple candidates are found
-func DetectPrimaryKey(documents []map[string]any) (string, error) {
-	if len(documents) == 0 {
-		return "", fmt.Errorf("cannot detect primary key from empty document set")
+// UpdateDocumentsInternal merges partial documents, identified by their
+// primary key, into the stored documents in a single batch (called by FSM).
+// Documents that do not exist are skipped and their IDs returned.
+func (s *IndexStore) UpdateDocumentsInternal(indexID string, updates []map[string]any) ([]string, error) {
+	s.mu.RLock()
+	_, exists := s.indexes[indexID]
+	config := s.configs[indexID]
+	s.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("index %s not found", indexID)
 	}
 
-	// Collect all unique attribute names ending with "id" (case-insensitive)
-	candidates := make(map[string]bool)
+	ids := make([]string, 0, len(updates))
+	for _, update := range updates {
+		id, ok := update[config.PrimaryKey]
+		if !ok || id == nil {
+			return nil, fmt.Errorf("document missing primary key %s", config.PrimaryKey)
+		}
+		ids = append(ids, DocumentID(id))
+	}
 
-	for _, doc := range documents {
-		for attr := range doc {
-			if strings.HasSuffix(strings.ToLower(attr), "id") {
-				candidates[attr] = true
+	// Buffered documents are indexed first, so this write does not overtake them
+	s.Refresh(indexID)
+
+	indexLock := s.getIndexLock(indexID)
+	indexLock.Lock()
+	defer indexLock.Unlock()
+
+	index, err := s.lockedIndex(indexID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch all stored documents at once
+	searchRequest := bleve.NewSearchRequestOptions(bleve.NewDocIDQuery(ids), len(ids), 0, false)
+	searchRequest.Fields = []string{"*"}
+	searchResult, err := index.Search(searchRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch documents: %w", err)
+	}
+
+	stored := make(map[string]map[string]any, len(searchResult.Hits))
+	for _, hit := range searchResult.Hits {
+		stored[hit.ID] = hit.Fields
+	}
+
+	// Merge in order, so later updates of the same document win
+	merged := make(map[string]map[string]any, len(stored))
+	missing := []string{}
+	batch := index.NewBatch()
+	for i, update := range updates {
+		id := ids[i]
+		document, ok := merged[id]
+		if !ok {
+			fields, found := stored[id]
+			if !found {
+				missing = append(missing, id)
+				continue
+			}
+			document = make(map[string]any, len(fields)+len(update))
+			for fieldName, fieldValue := range fields {
+				document[fieldName] = fieldValue
 			}
+			merged[id] = document
 		}
-	}
 
-	// Validate exactly one candidate exists
-	if len(candidates) == 0 {
-		return "", fmt.Errorf("no primary key candidate found (no attribute ending with 'id')")
+		normalizeNumbers(config.NumericFields, update)
+		for key, value := range update {
+			document[key] = value
+		}
+		if config.Language != nil {
+			retagLanguage(config.Language, document, update)
+		}
 	}
-	if len(candidates) > 1 {
-		candidateList := make([]string, 0, len(candidates))
-		for k := range candidates {
-			candidateList = append(candidateList, k)
+
+	for id, document := range merged {
+		if err := batch.Index(id, document); err != nil {
+			return nil, fmt.Errorf("failed to update document %s: %w", id, err)
 		}
-		sort.Strings(candidateList)
-		return "", fmt.Errorf("multiple primary key candidates found: %v", candidateList)
 	}
 
-	// Extract the single candidate
-	for candidate := range candidates {
-		return candidate, nil
+	if err := index.Batch(batch); err != nil {
+		return nil, fmt.Errorf("failed to update documents: %w", err)
 	}
+	s.stats.recordIndexed(indexID, len(merged))
+	s.searchCache.Invalidate(indexID)
 
-	return "", fmt.Errorf("unexpected error in primary key detection")
+	mergedIDs := make([]string, 0, len(merged))
+	for id := range merged {
+		mergedIDs = append(mergedIDs, id)
+	}
+	s.mirror(indexID, mergedIDs, func(shadow bleve.Index, config *models.IndexConfig) error {
+		batch := shadow.NewBatch()
+		for id, document := range merged {
+			prepareReindexDocument(config, document)
+			if err := batch.Index(id, document); err != nil {
+				return err
+			}
+		}
+		return shadow.Batch(batch)
+	})
+	return missing, nil
 }
@@ -1,17 +1,34 @@
 package store
 
 import (
+	"archive/tar"
 	"bright/models"
+	"bright/search"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/blevesearch/bleve/v2"
 )
 
 // TestConcurrentIndexOperations tests that concurrent operations on different indexes don't deadlock
 func TestConcurrentIndexOperations(t *testing.T) {
 	tmpDir := t.TempDir()
-	store := Initialize(tmpDir)
+	store, err := New(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
 
 	// Create multiple indexes
 	numIndexes := 5
@@ -86,7 +103,11 @@ func TestConcurrentIndexOperations(t *testing.T) {
 // TestConcurrentReadsAndWrites tests that concurrent reads and writes don't deadlock
 func TestConcurrentReadsAndWrites(t *testing.T) {
 	tmpDir := t.TempDir()
-	store := Initialize(tmpDir)
+	store, err := New(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
 
 	config := &models.IndexConfig{
 		ID:         "test_index",
@@ -163,7 +184,11 @@ func TestConcurrentReadsAndWrites(t *testing.T) {
 // TestConcurrentIndexCreationAndDeletion tests that creating and deleting indexes concurrently doesn't deadlock
 func TestConcurrentIndexCreationAndDeletion(t *testing.T) {
 	tmpDir := t.TempDir()
-	store := Initialize(tmpDir)
+	store, err := New(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
 
 	var opsCompleted int64
 	done := make(chan bool)
@@ -226,7 +251,11 @@ func TestConcurrentIndexCreationAndDeletion(t *testing.T) {
 // TestConcurrentBatchOperations tests that batch operations don't deadlock
 func TestConcurrentBatchOperations(t *testing.T) {
 	tmpDir := t.TempDir()
-	store := Initialize(tmpDir)
+	store, err := New(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
 
 	// Create multiple indexes
 	numIndexes := 3
@@ -300,7 +329,11 @@ func TestConcurrentBatchOperations(t *testing.T) {
 // TestLockFairnessUnderContention tests that locks are fair under high contention
 func TestLockFairnessUnderContention(t *testing.T) {
 	tmpDir := t.TempDir()
-	store := Initialize(tmpDir)
+	store, err := New(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
 
 	config := &models.IndexConfig{
 		ID:         "contention_index",
@@ -380,7 +413,11 @@ func TestLockFairnessUnderContention(t *testing.T) {
 // TestNoDeadlockWithMultipleIndexes tests a realistic scenario with multiple indexes
 func TestNoDeadlockWithMultipleIndexes(t *testing.T) {
 	tmpDir := t.TempDir()
-	store := Initialize(tmpDir)
+	store, err := New(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
 
 	// Create indexes
 	indexCount := 10
@@ -457,7 +494,11 @@ func TestNoDeadlockWithMultipleIndexes(t *testing.T) {
 // BenchmarkConcurrentOperations benchmarks concurrent operations
 func BenchmarkConcurrentOperations(b *testing.B) {
 	tmpDir := b.TempDir()
-	store := Initialize(tmpDir)
+	store, err := New(tmpDir)
+	if err != nil {
+		b.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
 
 	// Create indexes
 	for i := range 5 {
@@ -487,3 +528,3041 @@ func BenchmarkConcurrentOperations(b *testing.B) {
 		}
 	})
 }
+
+// TestWALReturnsUncommittedBatches tests that only uncommitted batches are replayed
+// and that a torn final record is ignored
+func TestWALReturnsUncommittedBatches(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	wal, pending, err := openWAL(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to open WAL: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("Expected no pending entries in a new WAL, got %d", len(pending))
+	}
+
+	committed, err := wal.Journal("products", "id", []map[string]any{{"id": "1"}})
+	if err != nil {
+		t.Fatalf("Failed to journal batch: %v", err)
+	}
+	if err := wal.Commit(committed); err != nil {
+		t.Fatalf("Failed to commit batch: %v", err)
+	}
+	if _, err := wal.Journal("products", "id", []map[string]any{{"id": "2"}}); err != nil {
+		t.Fatalf("Failed to journal batch: %v", err)
+	}
+
+	// Simulate a crash in the middle of writing a record
+	if _, err := wal.file.Write([]byte(`{"seq":3,"type":"add","indexId":"prod`)); err != nil {
+		t.Fatalf("Failed to write torn record: %v", err)
+	}
+	wal.Close()
+
+	wal, pending, err = openWAL(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to reopen WAL: %v", err)
+	}
+	defer wal.Close()
+
+	if len(pending) != 1 {
+		t.Fatalf("Expected 1 pending entry, got %d", len(pending))
+	}
+	if pending[0].Documents[0]["id"] != "2" {
+		t.Errorf("Expected pending document 2, got %v", pending[0].Documents[0]["id"])
+	}
+	if wal.seq != 2 {
+		t.Errorf("Expected sequence to resume at 2, got %d", wal.seq)
+	}
+}
+
+// TestIndependentStoreInstances tests that stores opened on different data directories don't share state
+func TestIndependentStoreInstances(t *testing.T) {
+	first, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer first.Close()
+
+	second, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer second.Close()
+
+	if err := first.CreateIndex(&models.IndexConfig{ID: "products", PrimaryKey: "id"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	if _, _, err := second.GetIndex("products"); err == nil {
+		t.Error("Expected index to exist only in the first store")
+	}
+	if len(first.ListIndexes(10, 0)) != 1 || len(second.ListIndexes(10, 0)) != 0 {
+		t.Error("Expected index listings to be independent")
+	}
+}
+
+// TestParallelBatchIndexing tests that large batches split across workers index every
+// document exactly once and that the last duplicate of an ID wins
+func TestParallelBatchIndexing(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	store.SetIndexParallelism(4, 50)
+
+	if err := store.CreateIndex(&models.IndexConfig{ID: "bulk", PrimaryKey: "id"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	docs := make([]map[string]any, 0, 1001)
+	for i := range 1000 {
+		docs = append(docs, map[string]any{"id": fmt.Sprintf("doc_%d", i), "version": "first"})
+	}
+	docs = append(docs, map[string]any{"id": "doc_7", "version": "second"})
+
+	if err := store.AddDocuments("bulk", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	index, _, err := store.GetIndex("bulk")
+	if err != nil {
+		t.Fatalf("Failed to get index: %v", err)
+	}
+
+	count, err := index.DocCount()
+	if err != nil {
+		t.Fatalf("Failed to count documents: %v", err)
+	}
+	if count != 1000 {
+		t.Errorf("Expected 1000 documents, got %d", count)
+	}
+
+	request := bleve.NewSearchRequest(bleve.NewDocIDQuery([]string{"doc_7"}))
+	request.Fields = []string{"version"}
+	result, err := index.Search(request)
+	if err != nil || len(result.Hits) != 1 {
+		t.Fatalf("Failed to load doc_7: %v", err)
+	}
+	if result.Hits[0].Fields["version"] != "second" {
+		t.Errorf("Expected the last duplicate of doc_7 to be indexed, got %v", result.Hits[0].Fields["version"])
+	}
+}
+
+// TestIdempotencyRegistrySkipsAppliedKeys tests that applied keys are remembered across restarts
+func TestIdempotencyRegistrySkipsAppliedKeys(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	registry, err := newIdempotencyRegistry(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to open registry: %v", err)
+	}
+
+	if record, err := registry.Reserve("products", "batch-1"); err != nil || record != nil {
+		t.Fatalf("Expected a fresh reservation, got %v, %v", record, err)
+	}
+	if _, err := registry.Reserve("products", "batch-1"); err != ErrIdempotencyInProgress {
+		t.Fatalf("Expected ErrIdempotencyInProgress, got %v", err)
+	}
+
+	err = registry.Complete(IdempotencyRecord{
+		IndexID:   "products",
+		Key:       "batch-1",
+		Operation: OperationAddDocuments,
+		Count:     3,
+		AppliedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to complete key: %v", err)
+	}
+
+	// Keys are scoped per index
+	if _, ok := registry.Lookup("orders", "batch-1", time.Now()); ok {
+		t.Error("Expected key to be unknown for another index")
+	}
+
+	// Applied keys survive a restart
+	registry, err = newIdempotencyRegistry(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to reopen registry: %v", err)
+	}
+	record, err := registry.Reserve("products", "batch-1")
+	if err != nil {
+		t.Fatalf("Failed to reserve applied key: %v", err)
+	}
+	if record == nil || record.Count != 3 {
+		t.Fatalf("Expected applied record with count 3, got %v", record)
+	}
+}
+
+// TestIdempotencyRegistryExpiresByLogTime tests that keys applied from the
+// Raft log expire relative to the time of the entry being applied, and
+// aren't written to disk
+func TestIdempotencyRegistryExpiresByLogTime(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	registry, err := newIdempotencyRegistry(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to open registry: %v", err)
+	}
+
+	appliedAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	registry.Apply(IdempotencyRecord{
+		IndexID:   "products",
+		Key:       "batch-1",
+		Operation: OperationAddDocuments,
+		Count:     3,
+		AppliedAt: appliedAt,
+	})
+
+	if _, ok := registry.Lookup("products", "batch-1", appliedAt.Add(IdempotencyTTL-time.Minute)); !ok {
+		t.Error("Expected key to be applied within the TTL of the entry time")
+	}
+	if _, ok := registry.Lookup("products", "batch-1", appliedAt.Add(IdempotencyTTL+time.Minute)); ok {
+		t.Error("Expected key to expire past the TTL of the entry time")
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "idempotency.json")); !os.IsNotExist(err) {
+		t.Errorf("Expected keys applied from the log not to be written, got %v", err)
+	}
+}
+
+// TestFilterDeleteHasNoCap tests that filter deletes resolve every match, not just the first page
+func TestFilterDeleteHasNoCap(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.CreateIndex(&models.IndexConfig{ID: "logs", PrimaryKey: "id"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	total := filterPageSize + 500
+	docs := make([]map[string]any, 0, total+1)
+	for i := range total {
+		docs = append(docs, map[string]any{"id": fmt.Sprintf("log_%05d", i), "level": "debug"})
+	}
+	docs = append(docs, map[string]any{"id": "keep", "level": "error"})
+
+	if err := store.AddDocuments("logs", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	ids, err := store.MatchingDocumentIDs("logs", "level:debug")
+	if err != nil {
+		t.Fatalf("Failed to resolve filter: %v", err)
+	}
+	if len(ids) != total {
+		t.Fatalf("Expected %d matching IDs, got %d", total, len(ids))
+	}
+	if ids[0] != "log_00000" || ids[len(ids)-1] != fmt.Sprintf("log_%05d", total-1) {
+		t.Errorf("Expected IDs in order, got %s..%s", ids[0], ids[len(ids)-1])
+	}
+
+	if err := store.DeleteDocumentsInternal("logs", "level:debug", nil); err != nil {
+		t.Fatalf("Failed to delete documents: %v", err)
+	}
+
+	index, _, _ := store.GetIndex("logs")
+	count, err := index.DocCount()
+	if err != nil {
+		t.Fatalf("Failed to count documents: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 remaining document, got %d", count)
+	}
+}
+
+// TestDocumentID tests that integral numbers become IDs without exponents
+func TestDocumentID(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{"sku-1", "sku-1"},
+		{float64(42), "42"},
+		{float64(1000000), "1000000"},
+		{float64(123456789012), "123456789012"},
+		{float32(2500000), "2500000"},
+		{1.5, "1.5"},
+		{int64(7), "7"},
+	}
+	for _, tt := range tests {
+		if got := DocumentID(tt.value); got != tt.want {
+			t.Errorf("DocumentID(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+// TestValidatePrimaryKeys tests primary key type and uniqueness checks on a batch
+func TestValidatePrimaryKeys(t *testing.T) {
+	tests := []struct {
+		name            string
+		documents       []map[string]any
+		allowDuplicates bool
+		wantErr         error
+	}{
+		{"strings", []map[string]any{{"id": "a"}, {"id": "b"}, {"title": "no key"}}, false, nil},
+		{"integers", []map[string]any{{"id": float64(1)}, {"id": int64(2)}}, false, nil},
+		{"mixed types", []map[string]any{{"id": "1"}, {"id": float64(1)}}, false, ErrInvalidPrimaryKey},
+		{"fractional", []map[string]any{{"id": 1.5}}, false, ErrInvalidPrimaryKey},
+		{"object", []map[string]any{{"id": map[string]any{"a": 1}}}, false, ErrInvalidPrimaryKey},
+		{"empty object", []map[string]any{{"id": map[string]any{}}}, false, ErrInvalidPrimaryKey},
+		{"array", []map[string]any{{"id": []any{"a", "b"}}}, false, ErrInvalidPrimaryKey},
+		{"empty array", []map[string]any{{"id": []any{}}}, false, ErrInvalidPrimaryKey},
+		{"boolean", []map[string]any{{"id": true}}, false, ErrInvalidPrimaryKey},
+		{"array after strings", []map[string]any{{"id": "a"}, {"id": []any{"a"}}}, false, ErrInvalidPrimaryKey},
+		{"object with duplicates allowed", []map[string]any{{"id": map[string]any{"a": 1}}}, true, ErrInvalidPrimaryKey},
+		{"empty string", []map[string]any{{"id": ""}}, false, ErrInvalidPrimaryKey},
+		{"identical copies", []map[string]any{{"id": "a", "v": 1}, {"id": "a", "v": 1}}, false, nil},
+		{"conflicting copies", []map[string]any{{"id": "a", "v": 1}, {"id": "a", "v": 2}}, false, ErrDuplicatePrimaryKey},
+		{"conflicting copies with idempotency", []map[string]any{{"id": "a", "v": 1}, {"id": "a", "v": 2}}, true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePrimaryKeys("id", tt.documents, tt.allowDuplicates)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// TestBatchUpdateDocuments tests merging partial documents in one batch
+func TestBatchUpdateDocuments(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.CreateIndex(&models.IndexConfig{ID: "products", PrimaryKey: "id"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	docs := []map[string]any{
+		{"id": "1", "title": "keyboard", "price": 10.0},
+		{"id": "2", "title": "mouse", "price": 5.0},
+	}
+	if err := store.AddDocuments("products", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	missing, err := store.UpdateDocumentsInternal("products", []map[string]any{
+		{"id": "1", "price": 12.0},
+		{"id": "2", "title": "trackball"},
+		{"id": "1", "stock": 3.0},
+		{"id": "9", "title": "monitor"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to update documents: %v", err)
+	}
+	if len(missing) != 1 || missing[0] != "9" {
+		t.Errorf("Expected missing [9], got %v", missing)
+	}
+
+	index, _, _ := store.GetIndex("products")
+	request := bleve.NewSearchRequest(bleve.NewDocIDQuery([]string{"1", "2", "9"}))
+	request.Fields = []string{"*"}
+	result, err := index.Search(request)
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if len(result.Hits) != 2 {
+		t.Fatalf("Expected 2 documents, got %d", len(result.Hits))
+	}
+	for _, hit := range result.Hits {
+		switch hit.ID {
+		case "1":
+			if hit.Fields["title"] != "keyboard" || hit.Fields["price"] != 12.0 || hit.Fields["stock"] != 3.0 {
+				t.Errorf("Unexpected merge result for 1: %v", hit.Fields)
+			}
+		case "2":
+			if hit.Fields["title"] != "trackball" || hit.Fields["price"] != 5.0 {
+				t.Errorf("Unexpected merge result for 2: %v", hit.Fields)
+			}
+		}
+	}
+}
+
+// TestFieldAnalysis tests per-field analyzer chains
+func TestFieldAnalysis(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	config := &models.IndexConfig{
+		ID:         "products",
+		PrimaryKey: "id",
+		Fields: map[string]models.FieldSettings{
+			"sku":         {Exact: true},
+			"description": {Stemmer: "en", ASCIIFolding: true},
+			"brand":       {EdgeNgram: &models.NgramSettings{Min: 2, Max: 10}},
+			"model":       {Ngram: &models.NgramSettings{Min: 3, Max: 5}},
+		},
+	}
+	if err := store.CreateIndex(config); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	docs := []map[string]any{
+		{"id": "1", "sku": "ABC-123", "description": "Running shoes for the café", "brand": "Adidas", "model": "Notebook"},
+		{"id": "2", "sku": 42.0, "description": "A warm jacket", "brand": "Patagonia", "model": "Parka"},
+	}
+	if err := store.AddDocuments("products", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	index, _, _ := store.GetIndex("products")
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"sku:ABC-123", 1},
+		{"sku:abc", 0},
+		{"description:runs", 1},
+		{"description:cafe", 1},
+		{"running", 1},
+		{"brand:pat", 1},
+		{"sku:42", 1},
+		{"model:book", 1},
+		{"model:ark", 1},
+		{"model:bo", 0},
+	}
+	for _, tt := range tests {
+		result, err := index.Search(bleve.NewSearchRequest(bleve.NewQueryStringQuery(tt.query)))
+		if err != nil {
+			t.Fatalf("Search %q failed: %v", tt.query, err)
+		}
+		if int(result.Total) != tt.want {
+			t.Errorf("Query %q: expected %d hits, got %d", tt.query, tt.want, result.Total)
+		}
+	}
+
+	// Numeric values in an analyzed field are still stored
+	request := bleve.NewSearchRequest(bleve.NewDocIDQuery([]string{"2"}))
+	request.Fields = []string{"sku"}
+	result, err := index.Search(request)
+	if err != nil || len(result.Hits) != 1 || result.Hits[0].Fields["sku"] != 42.0 {
+		t.Errorf("Expected stored numeric sku, got %v (%v)", result, err)
+	}
+
+	costs := FieldIndexCosts(config)
+	if costs["model"].Mode != "ngram" || costs["model"].TermsPerWord != 15 || costs["brand"].TermsPerWord != 7 {
+		t.Errorf("Unexpected field costs %+v", costs)
+	}
+
+	ngrams := &models.IndexConfig{ID: "bad", Fields: map[string]models.FieldSettings{"title": {Ngram: &models.NgramSettings{Min: 2, Max: 3}, EdgeNgram: &models.NgramSettings{Min: 2, Max: 3}}}}
+	if err := ValidateIndexSettings(ngrams); err == nil {
+		t.Error("Expected an error for ngram combined with edgeNgram")
+	}
+
+	invalid := &models.IndexConfig{ID: "bad", Fields: map[string]models.FieldSettings{"title": {Stemmer: "xx"}}}
+	if err := ValidateIndexSettings(invalid); err == nil {
+		t.Error("Expected an error for an unknown stemmer")
+	}
+}
+
+// TestCustomAnalysis tests tokenizers, char filters and token filter chains
+// defined in the analysis settings of an index
+func TestCustomAnalysis(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	config := &models.IndexConfig{
+		ID:         "code",
+		PrimaryKey: "id",
+		Analysis: &models.AnalysisSettings{
+			Tokenizers:   map[string]models.RegexpTokenizer{"sku": {Pattern: `[A-Za-z0-9]+(?:/[A-Za-z0-9]+)*`}},
+			CharFilters:  map[string]models.CharFilterSettings{"undash": {Pattern: `-`, Replacement: ""}},
+			TokenFilters: map[string][]string{"identifier": {"camelCase"}, "code": {"identifier", "unique"}},
+		},
+		Fields: map[string]models.FieldSettings{
+			"sku":    {Tokenizer: "sku"},
+			"part":   {CharFilters: []string{"undash"}},
+			"symbol": {TokenFilters: []string{"code"}},
+		},
+	}
+	if err := store.CreateIndex(config); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	docs := []map[string]any{
+		{"id": "1", "sku": "shirt-AB/XL", "part": "AB-12-C", "symbol": "parseHTTPRequest"},
+	}
+	if err := store.AddDocuments("code", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	index, _, _ := store.GetIndex("code")
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{`sku:"ab/xl"`, 1},
+		{"sku:ab", 0},
+		{"part:ab12c", 1},
+		{"symbol:request", 1},
+		{"symbol:http", 1},
+	}
+	for _, tt := range tests {
+		result, err := index.Search(bleve.NewSearchRequest(bleve.NewQueryStringQuery(tt.query)))
+		if err != nil {
+			t.Fatalf("Search %q failed: %v", tt.query, err)
+		}
+		if int(result.Total) != tt.want {
+			t.Errorf("Query %q: expected %d hits, got %d", tt.query, tt.want, result.Total)
+		}
+	}
+
+	invalid := []*models.IndexConfig{
+		{ID: "bad", Fields: map[string]models.FieldSettings{"title": {Tokenizer: "missing"}}},
+		{ID: "bad", Analysis: &models.AnalysisSettings{Tokenizers: map[string]models.RegexpTokenizer{"broken": {Pattern: "("}}}},
+		{ID: "bad", Analysis: &models.AnalysisSettings{TokenFilters: map[string][]string{"loop": {"lowercase", "loop"}}}},
+		{ID: "bad", Fields: map[string]models.FieldSettings{"sku": {Exact: true, Tokenizer: "whitespace"}}},
+	}
+	for _, config := range invalid {
+		if err := ValidateIndexSettings(config); err == nil {
+			t.Errorf("Expected an error for %+v", config)
+		}
+	}
+}
+
+// TestTextAnalysis tests the default analyzer of an index: stop words,
+// stemming and case
+func TestTextAnalysis(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	config := &models.IndexConfig{
+		ID:         "posts",
+		PrimaryKey: "id",
+		TextAnalysis: &models.TextAnalysisSettings{
+			Stemmer:           "en",
+			StopWordsLanguage: "en",
+			StopWords:         []string{"Acme"},
+		},
+	}
+	if err := store.CreateIndex(config); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	docs := []map[string]any{
+		{"id": "1", "body": "The Acme runners were running"},
+	}
+	if err := store.AddDocuments("posts", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	index, _, _ := store.GetIndex("posts")
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"body:run", 1},
+		{"runs", 1},
+		{"RUNNER", 1},
+		{"+acme", 0},
+		{"+the", 0},
+	}
+	for _, tt := range tests {
+		result, err := index.Search(bleve.NewSearchRequest(bleve.NewQueryStringQuery(tt.query)))
+		if err != nil {
+			t.Fatalf("Search %q failed: %v", tt.query, err)
+		}
+		if int(result.Total) != tt.want {
+			t.Errorf("Query %q: expected %d hits, got %d", tt.query, tt.want, result.Total)
+		}
+	}
+
+	invalid := []*models.IndexConfig{
+		{ID: "bad", TextAnalysis: &models.TextAnalysisSettings{Stemmer: "xx"}},
+		{ID: "bad", TextAnalysis: &models.TextAnalysisSettings{StopWordsLanguage: "porter"}},
+	}
+	for _, config := range invalid {
+		if err := ValidateIndexSettings(config); err == nil {
+			t.Errorf("Expected an error for %+v", config)
+		}
+	}
+}
+
+func TestTextReport(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.CreateIndex(&models.IndexConfig{ID: "posts", PrimaryKey: "id"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	docs := []map[string]any{
+		{"id": "1", "body": "Acme announced that the new release of the tool is available for all of the users", "secret": "zebra"},
+		{"id": "2", "body": "The Acme team said that they would fix the problems in the next version 2024", "secret": "zebra"},
+		{"id": "3", "body": "Acme customers were happy with the support and the speed of the service 2024", "secret": "zebra"},
+	}
+	if err := store.AddDocuments("posts", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	report, err := store.TextReport("posts", 0, []string{"secret"})
+	if err != nil {
+		t.Fatalf("TextReport failed: %v", err)
+	}
+	if report.SampledDocuments != 3 || report.TotalDocuments != 3 {
+		t.Errorf("Expected 3 of 3 documents sampled, got %d of %d", report.SampledDocuments, report.TotalDocuments)
+	}
+	if len(report.Languages) == 0 || report.Languages[0].Language != "en" {
+		t.Fatalf("Expected en first, got %+v", report.Languages)
+	}
+
+	stopWords := make(map[string]TokenStat)
+	for _, stat := range report.StopWords {
+		stopWords[stat.Token] = stat
+	}
+	if stat, ok := stopWords["acme"]; !ok || stat.Documents != 3 || len(stat.StopWordOf) != 0 {
+		t.Errorf("Expected acme as a stop word of no language, got %+v", stat)
+	}
+	if stat, ok := stopWords["the"]; !ok || !slices.Contains(stat.StopWordOf, "en") {
+		t.Errorf("Expected the as an English stop word, got %+v", stat)
+	}
+	if _, ok := stopWords["zebra"]; ok {
+		t.Error("Expected excluded fields to be left out of the sample")
+	}
+	if len(report.NoisyTokens) != 1 || report.NoisyTokens[0].Token != "2024" || report.NoisyTokens[0].Reason != NoisyNumber {
+		t.Errorf("Expected 2024 as a noisy number, got %+v", report.NoisyTokens)
+	}
+
+	suggested := report.Suggested
+	if suggested == nil || suggested.Stemmer != "en" || suggested.StopWordsLanguage != "en" {
+		t.Fatalf("Expected English text analysis, got %+v", suggested)
+	}
+	if !slices.Contains(suggested.StopWords, "acme") || slices.Contains(suggested.StopWords, "the") {
+		t.Errorf("Expected acme but not the in the suggested stop words, got %v", suggested.StopWords)
+	}
+
+	if _, err := store.TextReport("missing", 0, nil); err == nil {
+		t.Error("Expected an error for a missing index")
+	}
+}
+
+// TestSearchAsYouType tests prefix matching of the word being typed and the
+// ranking of words typed in order
+func TestVectorSettings(t *testing.T) {
+	invalid := []map[string]models.VectorSettings{
+		{"embedding": {Dims: 0}},
+		{"embedding": {Dims: 4096}},
+		{"embedding": {Dims: 3, Similarity: "cosine"}},
+		{"embedding": {Dims: 3, OptimizedFor: "speed"}},
+		{"_embedding": {Dims: 3}},
+	}
+	for _, vectors := range invalid {
+		config := &models.IndexConfig{ID: "bad", Vectors: vectors}
+		if err := ValidateIndexSettings(config); !errors.Is(err, search.ErrInvalidVector) {
+			t.Errorf("Expected ErrInvalidVector for %+v, got %v", vectors, err)
+		}
+	}
+
+	// Builds without vector support reject vector fields outright
+	config := &models.IndexConfig{ID: "docs", Vectors: map[string]models.VectorSettings{
+		"embedding": {Dims: 3, Similarity: search.VectorDotProduct},
+	}}
+	if err := ValidateIndexSettings(config); err != nil && !errors.Is(err, search.ErrVectorsUnsupported) {
+		t.Errorf("Expected valid vector settings, got %v", err)
+	}
+	config.Fields = map[string]models.FieldSettings{"embedding": {}}
+	if err := ValidateIndexSettings(config); err == nil {
+		t.Error("Expected an error for a vector field with field settings")
+	}
+
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.CreateIndex(&models.IndexConfig{ID: "docs", PrimaryKey: "id"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	index, _, _ := store.GetIndex("docs")
+
+	vectors := map[string]models.VectorSettings{"embedding": {Dims: 3}}
+	negative, half, two := -1.0, 0.5, 2.0
+	requests := []*models.VectorQuery{
+		{Field: "title", Values: []float32{1, 0, 0}},
+		{Field: "embedding", Values: []float32{1, 0}},
+		{Field: "embedding", Values: []float32{1, 0, 0}, K: -1},
+		{Field: "embedding", Values: []float32{1, 0, 0}, Boost: &negative},
+		{Field: "embedding", Values: []float32{1, 0, 0}, Fusion: "max"},
+		{Field: "embedding", Values: []float32{1, 0, 0}, Fusion: search.FusionRRF, Weight: &half},
+		{Field: "embedding", Values: []float32{1, 0, 0}, Fusion: search.FusionLinear, Weight: &two},
+		{Field: "embedding", Values: []float32{1, 0, 0}, Fusion: search.FusionLinear, Boost: &half},
+	}
+	for _, vector := range requests {
+		_, err := search.Execute(index, models.SearchRequest{Vector: vector, Vectors: vectors})
+		if !errors.Is(err, search.ErrInvalidVector) {
+			t.Errorf("Expected ErrInvalidVector for %+v, got %v", vector, err)
+		}
+	}
+	valid := &models.VectorQuery{Field: "embedding", Values: []float32{1, 0, 0}}
+	if _, err := search.Execute(index, models.SearchRequest{Vector: valid, Vectors: vectors}); err != nil && !errors.Is(err, search.ErrVectorsUnsupported) {
+		t.Errorf("Expected a valid kNN search, got %v", err)
+	}
+	fused := &models.VectorQuery{Field: "embedding", Values: []float32{1, 0, 0}, Fusion: search.FusionRRF}
+	sorted := models.SearchRequest{Query: "title:x", Sort: []string{"title"}, Vector: fused, Vectors: vectors}
+	if _, err := search.Execute(index, sorted); !errors.Is(err, search.ErrInvalidVector) {
+		t.Errorf("Expected ErrInvalidVector for fusion with sort, got %v", err)
+	}
+}
+
+func TestSearchAsYouType(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	config := &models.IndexConfig{
+		ID:         "movies",
+		PrimaryKey: "id",
+		Fields:     map[string]models.FieldSettings{"title": {SearchAsYouType: true}},
+	}
+	if err := store.CreateIndex(config); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	docs := []map[string]any{
+		{"id": "1", "title": "Star Wars"},
+		{"id": "2", "title": "Wars of the Star Empire"},
+		{"id": "3", "title": "Stardust Café"},
+		{"id": "4", "title": "Moon"},
+	}
+	if err := store.AddDocuments("movies", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	index, _, _ := store.GetIndex("movies")
+	ids := func(text string) []any {
+		t.Helper()
+		response, err := search.Execute(index, models.SearchRequest{Query: text, AsYouTypeFields: search.AsYouTypeFields(config)})
+		if err != nil {
+			t.Fatalf("Search %q failed: %v", text, err)
+		}
+		var got []any
+		for _, hit := range response.Hits {
+			got = append(got, hit["id"])
+		}
+		return got
+	}
+
+	if got := ids("sta"); len(got) != 3 {
+		t.Errorf("Expected every title with a word starting with sta, got %v", got)
+	}
+	if got := ids("star wa"); len(got) != 2 || got[0] != "1" {
+		t.Errorf("Expected both Star Wars titles, in order first, got %v", got)
+	}
+	if got := ids("stardust cafe"); len(got) != 1 || got[0] != "3" {
+		t.Errorf("Expected accents to be folded, got %v", got)
+	}
+	if got := ids("moonlight"); len(got) != 0 {
+		t.Errorf("Expected no title to match a longer word, got %v", got)
+	}
+
+	// The prefix matching strategy matches searches the same way, with
+	// excluded terms and across indexes
+	req := models.SearchRequest{Query: "star wa", MatchingStrategy: search.MatchingStrategyPrefix, ExcludeTerms: []string{"empire"}}
+	if err := search.ApplyMatchingStrategy(&req, search.AsYouTypeFields(config)); err != nil {
+		t.Fatalf("Failed to apply the prefix strategy: %v", err)
+	}
+	if response, err := search.Execute(index, req); err != nil || len(response.Hits) != 1 || response.Hits[0]["id"] != "1" {
+		t.Errorf("Expected a prefix search to find Star Wars only, got %v (%v)", response, err)
+	}
+	req = models.SearchRequest{Query: "moo", MatchingStrategy: search.MatchingStrategyPrefix}
+	if response, err := search.ExecuteAcross([]search.Target{{ID: "movies", Index: index, Config: config}}, req, false); err != nil || response.TotalHits != 1 {
+		t.Errorf("Expected a prefix search across indexes to find Moon, got %v (%v)", response, err)
+	}
+	for _, strategy := range []string{"fuzzy", search.MatchingStrategyPrefix} {
+		req := models.SearchRequest{Query: "moo", MatchingStrategy: strategy}
+		if err := search.ApplyMatchingStrategy(&req, nil); !errors.Is(err, search.ErrInvalidMatchingStrategy) {
+			t.Errorf("Expected strategy %s to be rejected without searchAsYouType fields, got %v", strategy, err)
+		}
+	}
+
+	costs := FieldIndexCosts(config)
+	if costs["title"].Mode != "searchAsYouType" {
+		t.Errorf("Expected the field cost to be estimated, got %v", costs)
+	}
+	if err := ValidateIndexSettings(&models.IndexConfig{ID: "bad", Fields: map[string]models.FieldSettings{"sku": {Exact: true, SearchAsYouType: true}}}); err == nil {
+		t.Error("Expected exact fields to reject searchAsYouType")
+	}
+}
+
+// TestKeywordSubfield tests exact filters, sorting and facets on the
+// keyword subfield of an analyzed field
+func TestKeywordSubfield(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	config := &models.IndexConfig{
+		ID:                   "products",
+		PrimaryKey:           "id",
+		Fields:               map[string]models.FieldSettings{"brand": {Keyword: true}},
+		FilterableAttributes: []string{"brand"},
+		SortableAttributes:   []string{"brand"},
+	}
+	if err := store.CreateIndex(config); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	docs := []map[string]any{
+		{"id": "1", "brand": "Acme Corp"},
+		{"id": "2", "brand": "acme corp"},
+		{"id": "3", "brand": "Zeta"},
+	}
+	if err := store.AddDocuments("products", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	index, _, _ := store.GetIndex("products")
+	req := models.SearchRequest{
+		Sort:                 []string{"brand.keyword"},
+		Facets:               map[string]models.FacetRequest{"brands": {Field: "brand.keyword"}},
+		SortableAttributes:   config.SortableAttributes,
+		FilterableAttributes: config.FilterableAttributes,
+	}
+	response, err := search.Execute(index, req)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	var got []any
+	for _, hit := range response.Hits {
+		got = append(got, hit["id"])
+	}
+	if len(got) != 3 || got[0] != "1" || got[1] != "3" || got[2] != "2" {
+		t.Errorf("Expected hits sorted by the values as written, got %v", got)
+	}
+	if terms := response.Facets["brands"].Terms; len(terms) != 3 {
+		t.Errorf("Expected a facet term per distinct value, got %v", terms)
+	}
+
+	req.Filter = `brand.keyword:"Acme Corp"`
+	if response, err = search.Execute(index, req); err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if response.TotalHits != 1 {
+		t.Errorf("Expected the filter to match the exact value only, got %d hits", response.TotalHits)
+	}
+
+	req.Filter = `brand:"acme corp"`
+	if response, err = search.Execute(index, req); err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if response.TotalHits != 2 {
+		t.Errorf("Expected the analyzed field to ignore case, got %d hits", response.TotalHits)
+	}
+}
+
+// TestSortableAttributes tests sorting on keyword sort fields and rejection
+// of undeclared sort fields
+func TestSortableAttributes(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	config := &models.IndexConfig{ID: "books", PrimaryKey: "id", SortableAttributes: []string{"title", "price"}}
+	if err := store.CreateIndex(config); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	docs := []map[string]any{
+		{"id": "1", "title": "zebra apple", "price": 5.0, "color": "red"},
+		{"id": "2", "title": "apple zebra", "price": 30.0, "color": "blue"},
+		{"id": "3", "title": "Mango", "price": 12.0, "color": "green"},
+	}
+	if err := store.AddDocuments("books", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	index, config, _ := store.GetIndex("books")
+	tests := []struct {
+		sort string
+		want []string
+	}{
+		{"title", []string{"2", "3", "1"}},
+		{"-price", []string{"2", "3", "1"}},
+	}
+	for _, tt := range tests {
+		response, err := search.Execute(index, models.SearchRequest{
+			Sort:               []string{tt.sort},
+			SortableAttributes: config.SortableAttributes,
+		})
+		if err != nil {
+			t.Fatalf("Sort %q failed: %v", tt.sort, err)
+		}
+		for i, hit := range response.Hits {
+			if hit["id"] != tt.want[i] {
+				t.Fatalf("Sort %q: expected %v, got %v", tt.sort, tt.want, response.Hits)
+			}
+		}
+		if response.Hits[0]["title"] == nil {
+			t.Errorf("Expected stored title, got %v", response.Hits[0])
+		}
+	}
+
+	_, err = search.Execute(index, models.SearchRequest{Sort: []string{"color"}, SortableAttributes: config.SortableAttributes})
+	if !errors.Is(err, search.ErrUnsortableAttribute) {
+		t.Errorf("Expected ErrUnsortableAttribute, got %v", err)
+	}
+}
+
+func TestSortOptions(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	config := &models.IndexConfig{
+		ID:                 "books",
+		PrimaryKey:         "id",
+		SortableAttributes: []string{"price"},
+		SearchDefaults:     &models.SearchDefaults{SortOptions: map[string]models.SortOptions{"price": {Type: search.SortTypeNumber}}},
+	}
+	if err := ValidateIndexSettings(config); err != nil {
+		t.Fatalf("Invalid settings: %v", err)
+	}
+	if err := store.CreateIndex(config); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	docs := []map[string]any{
+		{"id": "1", "price": 5.0},
+		{"id": "2", "price": 30.0},
+		{"id": "3", "price": 12.0},
+		{"id": "4"},
+		{"id": "5", "price": "cheap"},
+		{"id": "0"},
+	}
+	if err := store.AddDocuments("books", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	// Hits without a number to sort on tie, and keep the order of their IDs
+	index, _, _ := store.GetIndex("books")
+	tests := []struct {
+		sort    string
+		missing string
+		want    []any
+	}{
+		{"price", "", []any{"1", "3", "2", "0", "4", "5"}},
+		{"price", search.SortMissingFirst, []any{"0", "4", "5", "1", "3", "2"}},
+		{"-price", "", []any{"2", "3", "1", "0", "4", "5"}},
+		{"-price", search.SortMissingFirst, []any{"0", "4", "5", "2", "3", "1"}},
+	}
+	for _, tt := range tests {
+		req := models.SearchRequest{
+			Sort:               []string{tt.sort},
+			SortOptions:        map[string]models.SortOptions{"price": {Missing: tt.missing}},
+			SortableAttributes: config.SortableAttributes,
+		}
+		search.ApplyDefaults(&req, config.SearchDefaults)
+		response, err := search.Execute(index, req)
+		if err != nil {
+			t.Fatalf("Sort %q failed: %v", tt.sort, err)
+		}
+		var got []any
+		for _, hit := range response.Hits {
+			got = append(got, hit["id"])
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Sort %q, missing %q: expected %v, got %v", tt.sort, tt.missing, tt.want, got)
+		}
+	}
+
+	invalid := []map[string]models.SortOptions{
+		{"price": {Missing: "middle"}},
+		{"price": {Type: "boolean"}},
+		{"-price": {Type: search.SortTypeNumber}},
+	}
+	for _, options := range invalid {
+		if _, err := search.Execute(index, models.SearchRequest{Sort: []string{"price"}, SortOptions: options}); !errors.Is(err, search.ErrInvalidSort) {
+			t.Errorf("Expected %v to be rejected, got %v", options, err)
+		}
+	}
+}
+
+// TestCursorPagination tests paging through every hit by cursor, by score
+// and by field, and rejection of cursors that don't continue the search
+func TestCursorPagination(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	config := &models.IndexConfig{ID: "books", PrimaryKey: "id", SortableAttributes: []string{"price"}}
+	if err := store.CreateIndex(config); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	var docs []map[string]any
+	for i := range 25 {
+		doc := map[string]any{"id": fmt.Sprintf("%02d", i), "title": strings.Repeat("go ", 1+i%4) + "book"}
+		if i%5 != 0 {
+			doc["price"] = float64(i % 7)
+		}
+		docs = append(docs, doc)
+	}
+	if err := store.AddDocuments("books", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	index, _, _ := store.GetIndex("books")
+	for _, sort := range [][]string{nil, {"-price"}} {
+		req := models.SearchRequest{Query: "go", Limit: 7, Sort: sort, Cursor: search.CursorStart, SortableAttributes: config.SortableAttributes}
+		seen := make(map[any]bool)
+		pages := 0
+		for {
+			response, err := search.Execute(index, req)
+			if err != nil {
+				t.Fatalf("Sort %v, page %d failed: %v", sort, pages, err)
+			}
+			pages++
+			if response.TotalHits != 25 {
+				t.Errorf("Sort %v: expected 25 total hits, got %d", sort, response.TotalHits)
+			}
+			for _, hit := range response.Hits {
+				if seen[hit["id"]] {
+					t.Errorf("Sort %v: %v served twice", sort, hit["id"])
+				}
+				seen[hit["id"]] = true
+			}
+			if response.NextCursor == "" {
+				break
+			}
+			req.Cursor = response.NextCursor
+		}
+		if len(seen) != 25 || pages != 4 {
+			t.Errorf("Sort %v: expected 25 hits in 4 pages, got %d in %d", sort, len(seen), pages)
+		}
+	}
+
+	first, err := search.Execute(index, models.SearchRequest{Query: "go", Limit: 5, Cursor: search.CursorStart})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	invalid := []models.SearchRequest{
+		{Query: "go", Cursor: "not a cursor"},
+		{Query: "book", Limit: 5, Cursor: first.NextCursor},
+		{Query: "go", Limit: 5, Offset: 5, Cursor: search.CursorStart},
+		{Query: "go", Limit: 5, ScoreScript: "_score * 2", Cursor: search.CursorStart},
+	}
+	for _, req := range invalid {
+		if _, err := search.Execute(index, req); !errors.Is(err, search.ErrInvalidCursor) {
+			t.Errorf("Expected %+v to be rejected, got %v", req, err)
+		}
+	}
+}
+
+// TestCursorPointInTime tests that the pages of a cursor search read the
+// index as it was at the first page, whatever is written meanwhile
+func TestCursorPointInTime(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.CreateIndex(&models.IndexConfig{ID: "books", PrimaryKey: "id"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	var docs []map[string]any
+	for i := range 20 {
+		docs = append(docs, map[string]any{"id": fmt.Sprintf("%02d", i*2), "title": "go book"})
+	}
+	if err := store.AddDocuments("books", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	req := models.SearchRequest{Query: "go", Limit: 5, Sort: []string{"_id"}, Cursor: search.CursorStart}
+	seen := make(map[any]bool)
+	for page := 0; ; page++ {
+		index, err := store.CursorIndex("books", &req)
+		if err != nil {
+			t.Fatalf("Page %d: failed to pin index: %v", page, err)
+		}
+		response, err := search.Execute(index, req)
+		if err != nil {
+			t.Fatalf("Page %d failed: %v", page, err)
+		}
+		for _, hit := range response.Hits {
+			if seen[hit["id"]] {
+				t.Errorf("%v served twice", hit["id"])
+			}
+			seen[hit["id"]] = true
+		}
+		if response.NextCursor == "" {
+			break
+		}
+		req.Cursor = response.NextCursor
+
+		// Documents added between those served, and the deletion of one
+		// not served yet, are not seen
+		if page == 0 {
+			if err := store.AddDocuments("books", "", []map[string]any{{"id": "09", "title": "go book"}, {"id": "31", "title": "go book"}}); err != nil {
+				t.Fatalf("Failed to add documents: %v", err)
+			}
+			if err := store.DeleteDocumentInternal("books", "30"); err != nil {
+				t.Fatalf("Failed to delete document: %v", err)
+			}
+		}
+	}
+	if len(seen) != 20 || seen["09"] || seen["31"] || !seen["30"] {
+		t.Errorf("Expected the 20 documents of the first page's index, got %v", seen)
+	}
+
+	// Closed points in time can't be continued
+	req = models.SearchRequest{Query: "go", Limit: 5, Cursor: search.CursorStart}
+	index, err := store.CursorIndex("books", &req)
+	if err != nil {
+		t.Fatalf("Failed to pin index: %v", err)
+	}
+	response, err := search.Execute(index, req)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	store.ClosePointInTime(req.PointInTime)
+	req.Cursor, req.PointInTime = response.NextCursor, ""
+	if _, err := store.CursorIndex("books", &req); !errors.Is(err, search.ErrInvalidCursor) {
+		t.Errorf("Expected a closed point in time to be rejected, got %v", err)
+	}
+}
+
+// TestStats tests the operation counts of the node and of its indexes
+func TestStats(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	for _, id := range []string{"books", "films"} {
+		if err := store.CreateIndex(&models.IndexConfig{ID: id, PrimaryKey: "id"}); err != nil {
+			t.Fatalf("Failed to create index: %v", err)
+		}
+	}
+	docs := []map[string]any{{"id": "1"}, {"id": "2"}, {"id": "3"}}
+	if err := store.AddDocuments("books", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+	if err := store.UpdateDocumentInternal("books", "1", map[string]any{"title": "Dune"}); err != nil {
+		t.Fatalf("Failed to update document: %v", err)
+	}
+	if err := store.DeleteDocumentsInternal("books", "", []string{"2", "3"}); err != nil {
+		t.Fatalf("Failed to delete documents: %v", err)
+	}
+	if err := store.AddDocuments("films", "", docs[:1]); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+	store.Stats().RecordSearch("books", "films")
+	store.Stats().RecordIngest("books", 100)
+	store.Stats().RecordIngest("", 50)
+
+	want := OperationStats{DocumentsIndexed: 4, DocumentsDeleted: 2, Searches: 1, BytesIngested: 100}
+	if got := store.Stats().Index("books"); got != want {
+		t.Errorf("Expected books counts %+v, got %+v", want, got)
+	}
+	want = OperationStats{DocumentsIndexed: 5, DocumentsDeleted: 2, Searches: 1, BytesIngested: 150}
+	if got := store.Stats().Total(); got != want {
+		t.Errorf("Expected node counts %+v, got %+v", want, got)
+	}
+
+	// Deleted indexes leave their counts in the node's total
+	if err := store.DeleteIndex("books"); err != nil {
+		t.Fatalf("Failed to delete index: %v", err)
+	}
+	if got := store.Stats().Index("books"); got != (OperationStats{}) {
+		t.Errorf("Expected the counts of books to be dropped, got %+v", got)
+	}
+	if got := store.Stats().Total(); got != want {
+		t.Errorf("Expected node counts %+v, got %+v", want, got)
+	}
+}
+
+// TestFilterableAttributes tests exact matching of filterable attributes and
+// rejection of undeclared filter fields
+func TestFilterableAttributes(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	config := &models.IndexConfig{ID: "books", PrimaryKey: "id", FilterableAttributes: []string{"category", "price"}}
+	if err := store.CreateIndex(config); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	docs := []map[string]any{
+		{"id": "1", "title": "dune", "category": "Science Fiction", "price": 10.0},
+		{"id": "2", "title": "fiction notes", "category": "Essays", "price": 30.0},
+	}
+	if err := store.AddDocuments("books", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	index, config, _ := store.GetIndex("books")
+	tests := []struct {
+		query string
+		want  uint64
+	}{
+		{`category:"science fiction"`, 1},
+		{"category:fiction", 0},
+		{"fiction", 2},
+		{"price:>20", 1},
+	}
+	for _, tt := range tests {
+		response, err := search.Execute(index, models.SearchRequest{Query: tt.query, FilterableAttributes: config.FilterableAttributes})
+		if err != nil {
+			t.Fatalf("Query %q failed: %v", tt.query, err)
+		}
+		if response.TotalHits != tt.want {
+			t.Errorf("Query %q: expected %d hits, got %d", tt.query, tt.want, response.TotalHits)
+		}
+	}
+
+	err = search.ValidateFilterFields("dune AND catgory:Essays", config.FilterableAttributes)
+	if !errors.Is(err, search.ErrUnfilterableAttribute) || !strings.Contains(err.Error(), "did you mean category") {
+		t.Errorf("Expected a suggestion for a misspelled attribute, got %v", err)
+	}
+}
+
+// TestSearchLimits tests maxLimit and maxTotalHits enforcement
+func TestSearchLimits(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.CreateIndex(&models.IndexConfig{ID: "logs", PrimaryKey: "id", MaxLimit: 5, MaxTotalHits: 12}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	docs := make([]map[string]any, 0, 30)
+	for i := range 30 {
+		docs = append(docs, map[string]any{"id": fmt.Sprintf("log_%02d", i)})
+	}
+	if err := store.AddDocuments("logs", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	index, config, _ := store.GetIndex("logs")
+	limits := func(req models.SearchRequest) models.SearchRequest {
+		req.MaxLimit, req.MaxTotalHits = config.MaxLimit, config.MaxTotalHits
+		return req
+	}
+
+	response, err := search.Execute(index, limits(models.SearchRequest{}))
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(response.Hits) != 5 || response.TotalHits != 12 || response.TotalPages != 3 {
+		t.Errorf("Expected 5 hits of 12 in 3 pages, got %d of %d in %d", len(response.Hits), response.TotalHits, response.TotalPages)
+	}
+
+	if _, err := search.Execute(index, limits(models.SearchRequest{Limit: 6})); !errors.Is(err, search.ErrLimitExceeded) {
+		t.Errorf("Expected ErrLimitExceeded, got %v", err)
+	}
+	if _, err := search.Execute(index, limits(models.SearchRequest{Limit: 5, Page: 4})); !errors.Is(err, search.ErrMaxTotalHitsExceeded) {
+		t.Errorf("Expected ErrMaxTotalHitsExceeded, got %v", err)
+	}
+
+	// The last page stops at maxTotalHits
+	response, err = search.Execute(index, limits(models.SearchRequest{Limit: 5, Page: 3}))
+	if err != nil || len(response.Hits) != 2 {
+		t.Errorf("Expected 2 hits on the last page, got %v (%v)", response, err)
+	}
+}
+
+// TestSearchResponseFormatting tests ID injection and _meta options
+func TestSearchResponseFormatting(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.CreateIndex(&models.IndexConfig{ID: "items", PrimaryKey: "sku"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	docs := []map[string]any{{"sku": "s1", "id": "external", "name": "lamp"}}
+	if err := store.AddDocuments("items", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	index, _, _ := store.GetIndex("items")
+	inject := false
+	response, err := search.Execute(index, models.SearchRequest{InjectID: &inject, ShowMeta: true, IndexID: "items"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	hit := response.Hits[0]
+	if hit["id"] != "external" {
+		t.Errorf("Expected the document's own id, got %v", hit["id"])
+	}
+	meta, _ := hit["_meta"].(map[string]any)
+	if meta["_id"] != "s1" || meta["_index"] != "items" || meta["_score"] == nil {
+		t.Errorf("Unexpected _meta: %v", hit["_meta"])
+	}
+
+	response, err = search.Execute(index, models.SearchRequest{IDAttribute: "_docId", AttributesToRetrieve: []string{"name"}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if hit := response.Hits[0]; hit["_docId"] != "s1" || hit["id"] != nil {
+		t.Errorf("Expected the ID under _docId only, got %v", hit)
+	}
+}
+
+func TestSearchableAttributes(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	config := &models.IndexConfig{
+		ID:                   "products",
+		PrimaryKey:           "id",
+		SearchableAttributes: map[string]float64{"title": 5, "description": 1},
+	}
+	if err := store.CreateIndex(config); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	docs := []map[string]any{
+		{"id": "described", "title": "leather case", "description": "fits every phone and tablet"},
+		{"id": "titled", "title": "phone case", "description": "black leather, fits every tablet"},
+		{"id": "noted", "title": "tablet stand", "description": "aluminium", "notes": "phone"},
+	}
+	if err := store.AddDocuments("products", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	index, _, _ := store.GetIndex("products")
+	ids := func(q string) []any {
+		t.Helper()
+		response, err := search.Execute(index, models.SearchRequest{Query: q, SearchableAttributes: config.SearchableAttributes})
+		if err != nil {
+			t.Fatalf("Search %q failed: %v", q, err)
+		}
+		var got []any
+		for _, hit := range response.Hits {
+			got = append(got, hit["id"])
+		}
+		return got
+	}
+
+	tests := []struct {
+		query string
+		want  []any
+	}{
+		{"phone", []any{"titled", "described"}},
+		{`"phone case"`, []any{"titled"}},
+		{"phon*", []any{"titled", "described"}},
+		{"notes:phone", []any{"noted"}},
+		{"tablet -leather", []any{"noted"}},
+	}
+	for _, tt := range tests {
+		if got := ids(tt.query); !slices.Equal(got, tt.want) {
+			t.Errorf("Query %q: expected %v, got %v", tt.query, tt.want, got)
+		}
+	}
+
+	invalid := []map[string]float64{{"title": 0}, {"title": -1}, {"_all": 1}, {"": 2}}
+	for _, searchable := range invalid {
+		if err := ValidateIndexSettings(&models.IndexConfig{ID: "bad", SearchableAttributes: searchable}); err == nil {
+			t.Errorf("Expected an error for %v", searchable)
+		}
+	}
+}
+
+func TestRankingExperiment(t *testing.T) {
+	dir := t.TempDir()
+	store, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+
+	experiment := &models.RankingExperiment{Variants: []models.RankingVariant{
+		{Name: "control", Traffic: 1},
+		{Name: "titles", Traffic: 1, SearchableAttributes: map[string]float64{"title": 10, "description": 1}},
+		{Name: "paused", Traffic: 0, ScoreScript: "_score * 2"},
+	}}
+	config := &models.IndexConfig{ID: "products", PrimaryKey: "id", Experiment: experiment}
+	if err := ValidateIndexSettings(config); err != nil {
+		t.Fatalf("Invalid experiment: %v", err)
+	}
+	if err := store.CreateIndex(config); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	docs := []map[string]any{
+		{"id": "described", "title": "leather case", "description": "phone phone phone"},
+		{"id": "titled", "title": "phone case", "description": "black leather, fits every tablet and many other devices"},
+	}
+	if err := store.AddDocuments("products", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	// A user token keeps its variant, and variants without traffic are only
+	// served by name
+	for _, token := range []string{"ana", "bo", "cy", "di"} {
+		first, _ := search.ChooseVariant(experiment, "", token)
+		for range 5 {
+			if variant, _ := search.ChooseVariant(experiment, "", token); variant.Name != first.Name {
+				t.Errorf("Expected token %s to keep variant %s, got %s", token, first.Name, variant.Name)
+			}
+		}
+		if first.Name == "paused" {
+			t.Errorf("Expected a variant without traffic not to be drawn")
+		}
+	}
+	if _, err := search.ChooseVariant(experiment, "missing", ""); !errors.Is(err, search.ErrUnknownVariant) {
+		t.Errorf("Expected an unknown variant error, got %v", err)
+	}
+
+	index, _, _ := store.GetIndex("products")
+	first := func(name string) any {
+		t.Helper()
+		variant, err := search.ChooseVariant(experiment, name, "")
+		if err != nil {
+			t.Fatalf("Failed to choose variant %s: %v", name, err)
+		}
+		req := models.SearchRequest{Query: "phone"}
+		search.ApplyVariant(&req, variant)
+		response, err := search.Execute(index, req)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		store.Analytics().RecordSearch("products", req.Variant)
+		return response.Hits[0]["id"]
+	}
+	if got := first("control"); got != "described" {
+		t.Errorf("Expected the control ranking to lead with described, got %v", got)
+	}
+	if got := first("titles"); got != "titled" {
+		t.Errorf("Expected the titles variant to lead with titled, got %v", got)
+	}
+	first("titles")
+	store.Analytics().RecordClick("products", "titles")
+
+	// Counts survive a restart and go away with their index
+	store.Close()
+	store, err = New(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer store.Close()
+
+	report := store.Analytics().Report("products", experiment)
+	want := []models.VariantStats{
+		{Variant: "control", Traffic: 1, Searches: 1},
+		{Variant: "titles", Traffic: 1, Searches: 2, Clicks: 1, CTR: 0.5},
+		{Variant: "paused"},
+	}
+	if !slices.Equal(report.Variants, want) {
+		t.Errorf("Expected %+v, got %+v", want, report.Variants)
+	}
+	if err := store.DeleteIndex("products"); err != nil {
+		t.Fatalf("Failed to delete index: %v", err)
+	}
+	if got := store.Analytics().Report("products", experiment).Variants[1].Searches; got != 0 {
+		t.Errorf("Expected counts to be deleted with their index, got %d searches", got)
+	}
+
+	invalid := []*models.RankingExperiment{
+		{Variants: []models.RankingVariant{{Name: "only", Traffic: 1}}},
+		{Variants: []models.RankingVariant{{Name: "a", Traffic: 1}, {Name: "a", Traffic: 1}}},
+		{Variants: []models.RankingVariant{{Name: "a"}, {Name: "b"}}},
+		{Variants: []models.RankingVariant{{Name: "a", Traffic: 1}, {Name: "b", Traffic: -1}}},
+		{Variants: []models.RankingVariant{{Name: "a", Traffic: 1}, {Name: "", Traffic: 1}}},
+		{Variants: []models.RankingVariant{{Name: "a", Traffic: 1}, {Name: "b", Traffic: 1, ScoreScript: "_score *"}}},
+	}
+	for i, experiment := range invalid {
+		if err := ValidateIndexSettings(&models.IndexConfig{ID: "bad", Experiment: experiment}); err == nil {
+			t.Errorf("Expected experiment %d to be rejected", i)
+		}
+	}
+}
+
+// TestLanguageDetection tests that documents get a _lang and language-analyzed fields
+func TestLanguageDetection(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	config := &models.IndexConfig{ID: "posts", PrimaryKey: "id", Language: &models.LanguageSettings{
+		Fields:    []string{"body"},
+		Languages: []string{"en", "de", "fr"},
+	}}
+	if err := store.CreateIndex(config); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	docs := []map[string]any{
+		{"id": "en", "body": "The runners were running through the park and they were tired"},
+		{"id": "de", "body": "Die Kinder sind mit dem Hund durch den Park gelaufen und waren müde"},
+		{"id": "short", "body": "Park"},
+		{"id": "forced", "body": "Le parc", "_lang": "fr"},
+	}
+	if err := store.AddDocuments("posts", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	index, _, _ := store.GetIndex("posts")
+	languages := map[string]any{}
+	response, err := search.Execute(index, models.SearchRequest{Limit: 10})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	for _, hit := range response.Hits {
+		languages[hit["id"].(string)] = hit["_lang"]
+	}
+	if languages["en"] != "en" || languages["de"] != "de" || languages["short"] != nil || languages["forced"] != "fr" {
+		t.Errorf("Unexpected detected languages: %v", languages)
+	}
+
+	for q, want := range map[string]string{"body_en:run": "en", "body_de:kind": "de", "body:running": "en"} {
+		response, err := search.Execute(index, models.SearchRequest{Query: q})
+		if err != nil {
+			t.Fatalf("Search %s failed: %v", q, err)
+		}
+		if response.TotalHits != 1 || response.Hits[0]["id"] != want {
+			t.Errorf("Expected %s to match %s only, got %v", q, want, response.Hits)
+		}
+	}
+
+	if err := store.AddDocuments("posts", "", []map[string]any{{"id": "en", "body": "Die Katze ist auf dem Dach und der Hund ist im Garten"}}); err != nil {
+		t.Fatalf("Failed to replace document: %v", err)
+	}
+	if _, err := store.UpdateDocumentsInternal("posts", []map[string]any{{"id": "de", "body": "The cat is on the roof and the dog is in the garden"}}); err != nil {
+		t.Fatalf("Failed to update document: %v", err)
+	}
+	response, err = search.Execute(index, models.SearchRequest{Query: "_lang:en"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if response.TotalHits != 1 || response.Hits[0]["id"] != "de" {
+		t.Errorf("Expected updated text to be detected again, got %v", response.Hits)
+	}
+
+	if err := ValidateIndexSettings(&models.IndexConfig{Language: &models.LanguageSettings{Fields: []string{"body"}, Languages: []string{"xx"}}}); err == nil {
+		t.Error("Expected an error for an unsupported language")
+	}
+}
+
+// TestRefreshInterval tests that buffered documents become searchable on refresh
+func TestRefreshInterval(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.CreateIndex(&models.IndexConfig{ID: "logs", PrimaryKey: "id", RefreshInterval: "1h"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	index, _, _ := store.GetIndex("logs")
+	count := func() uint64 {
+		n, err := index.DocCount()
+		if err != nil {
+			t.Fatalf("Failed to count documents: %v", err)
+		}
+		return n
+	}
+
+	if err := store.AddDocuments("logs", "", []map[string]any{{"id": "1"}, {"id": "2"}}); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+	if n := count(); n != 0 {
+		t.Fatalf("Expected buffered documents to be invisible, got %d", n)
+	}
+
+	waited := make(chan error)
+	go func() { waited <- store.WaitForRefresh("logs") }()
+	if err := store.Refresh("logs"); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if err := <-waited; err != nil {
+		t.Fatalf("WaitForRefresh failed: %v", err)
+	}
+	if n := count(); n != 2 {
+		t.Fatalf("Expected 2 documents after refresh, got %d", n)
+	}
+
+	// A delete must not overtake a buffered add of the same document
+	if err := store.AddDocuments("logs", "", []map[string]any{{"id": "3"}}); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+	if err := store.DeleteDocumentsInternal("logs", "", []string{"1", "3"}); err != nil {
+		t.Fatalf("Failed to delete documents: %v", err)
+	}
+	if n := count(); n != 1 {
+		t.Errorf("Expected 1 document after delete, got %d", n)
+	}
+
+	config := &models.IndexConfig{ID: "logs", PrimaryKey: "id", RefreshInterval: "20ms"}
+	if err := store.UpdateIndex("logs", config); err != nil {
+		t.Fatalf("Failed to update index: %v", err)
+	}
+	if err := store.AddDocuments("logs", "", []map[string]any{{"id": "4"}}); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+	if err := store.WaitForRefresh("logs"); err != nil {
+		t.Fatalf("WaitForRefresh failed: %v", err)
+	}
+	if n := count(); n != 2 {
+		t.Errorf("Expected the scheduled refresh to index the document, got %d", n)
+	}
+
+	if err := ValidateIndexSettings(&models.IndexConfig{RefreshInterval: "soon"}); err == nil {
+		t.Error("Expected an error for an invalid refresh interval")
+	}
+}
+
+func TestDetectPrimaryKey(t *testing.T) {
+	documents := []map[string]any{{"id": "1", "userId": "u1", "sku": "A-1"}}
+
+	cases := []struct {
+		name       string
+		documents  []map[string]any
+		heuristics PrimaryKeyHeuristics
+		want       string
+		wantErr    bool
+	}{
+		{"ambiguous without heuristics", documents, PrimaryKeyHeuristics{}, "", true},
+		{"exact id preferred", documents, PrimaryKeyHeuristics{PreferExactID: true}, "id", false},
+		{"preferred names first", documents, PrimaryKeyHeuristics{PreferredNames: []string{"code", "sku"}, PreferExactID: true}, "sku", false},
+		{"single suffix candidate", []map[string]any{{"orderId": 1, "total": 3}}, PrimaryKeyHeuristics{PreferExactID: true}, "orderId", false},
+		{"no candidate", []map[string]any{{"title": "x"}}, PrimaryKeyHeuristics{}, "", true},
+		{"fallback", []map[string]any{{"title": "x"}}, PrimaryKeyHeuristics{Fallback: "id"}, "id", false},
+		{"default skips detection", documents, PrimaryKeyHeuristics{Default: "key"}, "key", false},
+	}
+
+	for _, tc := range cases {
+		got, err := DetectPrimaryKey(tc.documents, tc.heuristics)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("%s: got %q, %v; want %q, error %v", tc.name, got, err, tc.want, tc.wantErr)
+		}
+	}
+}
+
+// TestSearchDefaults tests that index search defaults fill omitted options
+// and that filters do not change scores
+func TestSearchDefaults(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	config := &models.IndexConfig{ID: "items", PrimaryKey: "id", SearchDefaults: &models.SearchDefaults{
+		Limit:                2,
+		Filter:               "status:active",
+		AttributesToRetrieve: []string{"title"},
+	}}
+	if err := ValidateIndexSettings(config); err != nil {
+		t.Fatalf("Expected valid defaults: %v", err)
+	}
+	if err := store.CreateIndex(config); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	docs := []map[string]any{
+		{"id": "1", "title": "red lamp", "status": "active"},
+		{"id": "2", "title": "red chair", "status": "archived"},
+		{"id": "3", "title": "blue lamp", "status": "active"},
+		{"id": "4", "title": "green lamp", "status": "active"},
+	}
+	if err := store.AddDocuments("items", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+	index, _, _ := store.GetIndex("items")
+
+	req := models.SearchRequest{Query: "lamp"}
+	search.ApplyDefaults(&req, config.SearchDefaults)
+	response, err := search.Execute(index, req)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if response.TotalHits != 3 || len(response.Hits) != 2 {
+		t.Errorf("Expected 3 active lamps paged by 2, got %d hits: %v", response.TotalHits, response.Hits)
+	}
+	if _, ok := response.Hits[0]["status"]; ok {
+		t.Errorf("Expected only the default attributes, got %v", response.Hits[0])
+	}
+
+	req = models.SearchRequest{Query: "red", Filter: "status:archived", Limit: 10, ShowMeta: true}
+	search.ApplyDefaults(&req, config.SearchDefaults)
+	filtered, err := search.Execute(index, req)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	unfiltered, err := search.Execute(index, models.SearchRequest{Query: "red", Limit: 10, ShowMeta: true})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if filtered.TotalHits != 1 || filtered.Hits[0]["id"] != "2" {
+		t.Fatalf("Expected the request filter to replace the default, got %v", filtered.Hits)
+	}
+	for _, hit := range unfiltered.Hits {
+		if hit["id"] == "2" {
+			filteredScore := filtered.Hits[0]["_meta"].(map[string]any)["_score"]
+			if score := hit["_meta"].(map[string]any)["_score"]; score != filteredScore {
+				t.Errorf("Expected the filter not to change scores, got %v and %v", score, filteredScore)
+			}
+		}
+	}
+
+	invalid := &models.IndexConfig{ID: "bad", PrimaryKey: "id", MaxLimit: 5, SearchDefaults: &models.SearchDefaults{Limit: 10}}
+	if err := ValidateIndexSettings(invalid); err == nil {
+		t.Error("Expected a default limit above maxLimit to be rejected")
+	}
+}
+
+// TestNumericNormalization tests that price-like strings become numbers
+func TestNumericNormalization(t *testing.T) {
+	two := 2
+	cases := []struct {
+		text     string
+		settings models.NumericFieldSettings
+		want     float64
+		currency string
+		ok       bool
+	}{
+		{"$1,299.00", models.NumericFieldSettings{}, 1299, "USD", true},
+		{"1.299,50 €", models.NumericFieldSettings{}, 1299.5, "EUR", true},
+		{"12,50", models.NumericFieldSettings{}, 12.5, "", true},
+		{"1,299", models.NumericFieldSettings{DecimalSeparator: ","}, 1.299, "", true},
+		{"(12.50) USD", models.NumericFieldSettings{}, -12.5, "USD", true},
+		{"-$5", models.NumericFieldSettings{}, -5, "USD", true},
+		{"CHF 1'000.555", models.NumericFieldSettings{Precision: &two}, 1000.56, "CHF", true},
+		{"1.5 kg", models.NumericFieldSettings{Units: map[string]float64{"g": 1, "kg": 1000}}, 1500, "", true},
+		{"250", models.NumericFieldSettings{Units: map[string]float64{"g": 1, "kg": 1000}}, 250, "", true},
+		{"3 lbs", models.NumericFieldSettings{Units: map[string]float64{"kg": 1}}, 0, "", false},
+		{"call us", models.NumericFieldSettings{}, 0, "", false},
+	}
+	for _, tc := range cases {
+		got, currency, ok := ParseNumber(tc.text, tc.settings)
+		if ok != tc.ok || got != tc.want || currency != tc.currency {
+			t.Errorf("ParseNumber(%q) = %v, %q, %v; want %v, %q, %v", tc.text, got, currency, ok, tc.want, tc.currency, tc.ok)
+		}
+	}
+
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	config := &models.IndexConfig{ID: "products", PrimaryKey: "id", NumericFields: map[string]models.NumericFieldSettings{
+		"price": {CurrencyField: "currency", OriginalField: "priceText"},
+	}}
+	if err := store.CreateIndex(config); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	docs := []map[string]any{
+		{"id": "1", "price": "$1,299.00"},
+		{"id": "2", "price": "€89,90"},
+		{"id": "3", "price": "ask"},
+	}
+	if err := store.AddDocuments("products", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	index, _, _ := store.GetIndex("products")
+	response, err := search.Execute(index, models.SearchRequest{Query: "price:>100"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if response.TotalHits != 1 || response.Hits[0]["id"] != "1" || response.Hits[0]["currency"] != "USD" || response.Hits[0]["priceText"] != "$1,299.00" {
+		t.Errorf("Expected document 1 by numeric range, got %v", response.Hits)
+	}
+
+	if err := store.UpdateDocumentInternal("products", "3", map[string]any{"price": "45 EUR"}); err != nil {
+		t.Fatalf("Failed to update document: %v", err)
+	}
+	response, err = search.Execute(index, models.SearchRequest{Query: "price:<50", Sort: []string{"price"}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if response.TotalHits != 1 || response.Hits[0]["price"] != 45.0 {
+		t.Errorf("Expected the updated price to be normalized, got %v", response.Hits)
+	}
+}
+
+// TestSearchFacets tests term and numeric range facets
+func TestSearchFacets(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	config := &models.IndexConfig{ID: "products", PrimaryKey: "id", FilterableAttributes: []string{"category", "price"}, HiddenAttributes: []string{"cost"}}
+	if err := store.CreateIndex(config); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	docs := []map[string]any{
+		{"id": "1", "title": "red lamp", "category": "lighting", "price": 20.0, "cost": 8.0},
+		{"id": "2", "title": "desk lamp", "category": "lighting", "price": 45.0, "cost": 20.0},
+		{"id": "3", "title": "red chair", "category": "furniture", "price": 120.0, "cost": 60.0},
+		{"id": "4", "title": "red rug", "category": "textiles"},
+	}
+	if err := store.AddDocuments("products", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+	index, config, _ := store.GetIndex("products")
+
+	fifty, hundred := 50.0, 100.0
+	req := models.SearchRequest{
+		Query: "red",
+		Limit: 1,
+		Facets: map[string]models.FacetRequest{
+			"categories": {Field: "category"},
+			"prices": {Field: "price", NumericRanges: []models.NumericRange{
+				{Name: "cheap", Max: &fifty},
+				{Name: "mid", Min: &fifty, Max: &hundred},
+				{Name: "premium", Min: &hundred},
+			}},
+		},
+		FilterableAttributes: config.FilterableAttributes,
+		HiddenAttributes:     config.HiddenAttributes,
+	}
+	response, err := search.Execute(index, req)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(response.Hits) != 1 {
+		t.Fatalf("Expected one hit, got %v", response.Hits)
+	}
+
+	categories := response.Facets["categories"]
+	counts := make(map[string]int)
+	for _, term := range categories.Terms {
+		counts[term.Term] = term.Count
+	}
+	if len(counts) != 3 || counts["lighting"] != 1 || counts["furniture"] != 1 || counts["textiles"] != 1 {
+		t.Errorf("Expected the categories of every red product, got %+v", categories)
+	}
+
+	prices := response.Facets["prices"]
+	if prices.Missing != 1 || len(prices.NumericRanges) != 3 {
+		t.Fatalf("Expected three ranges and one product without a price, got %+v", prices)
+	}
+	for i, want := range []int{1, 0, 1} {
+		if got := prices.NumericRanges[i].Count; got != want {
+			t.Errorf("Expected %d in range %s, got %d", want, prices.NumericRanges[i].Name, got)
+		}
+	}
+
+	req.Facets = map[string]models.FacetRequest{"titles": {Field: "title"}}
+	if _, err := search.Execute(index, req); !errors.Is(err, search.ErrUnfilterableAttribute) {
+		t.Errorf("Expected a facet on an unfilterable field to be rejected, got %v", err)
+	}
+	req.FilterableAttributes = nil
+	req.Facets = map[string]models.FacetRequest{"costs": {Field: "cost"}}
+	if _, err := search.Execute(index, req); !errors.Is(err, search.ErrInvalidFacet) {
+		t.Errorf("Expected a facet on a hidden field to be rejected, got %v", err)
+	}
+}
+
+// TestExecuteAcross tests searching several indexes with merged hits
+func TestExecuteAcross(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	configs := []*models.IndexConfig{
+		{ID: "logs-1", PrimaryKey: "id", SortableAttributes: []string{"at"}, HiddenAttributes: []string{"ip"}},
+		{ID: "logs-2", PrimaryKey: "id", SortableAttributes: []string{"at"}, MaxLimit: 2},
+	}
+	for i, config := range configs {
+		if err := store.CreateIndex(config); err != nil {
+			t.Fatalf("Failed to create index: %v", err)
+		}
+		docs := []map[string]any{
+			{"id": fmt.Sprintf("a%d", i), "message": "disk full", "at": float64(i), "ip": "10.0.0.1"},
+			{"id": fmt.Sprintf("b%d", i), "message": "disk ok", "at": float64(i + 10), "ip": "10.0.0.2"},
+		}
+		if err := store.AddDocuments(config.ID, "", docs); err != nil {
+			t.Fatalf("Failed to add documents: %v", err)
+		}
+	}
+
+	targets := []search.Target{}
+	for _, config := range configs {
+		index, config, _ := store.GetIndex(config.ID)
+		targets = append(targets, search.Target{ID: config.ID, Index: index, Config: config})
+	}
+
+	response, err := search.ExecuteAcross(targets, models.SearchRequest{Query: "disk", Sort: []string{"-at"}, ShowMeta: true}, false)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if response.TotalHits != 4 || len(response.Hits) != 2 {
+		t.Fatalf("Expected 4 hits limited to 2 by the strictest maxLimit, got %d: %v", response.TotalHits, response.Hits)
+	}
+	first, second := response.Hits[0], response.Hits[1]
+	if first["id"] != "b1" || second["id"] != "b0" {
+		t.Errorf("Expected hits merged by descending time, got %v", response.Hits)
+	}
+	if first["_meta"].(map[string]any)["_index"] != "logs-2" || first["ip"] != "10.0.0.2" {
+		t.Errorf("Expected a visible ip from logs-2, got %v", first)
+	}
+	if second["_meta"].(map[string]any)["_index"] != "logs-1" || second["ip"] != nil {
+		t.Errorf("Expected the ip hidden by logs-1, got %v", second)
+	}
+
+	if _, err := search.ExecuteAcross(targets, models.SearchRequest{Sort: []string{"message"}}, false); !errors.Is(err, search.ErrUnsortableAttribute) {
+		t.Errorf("Expected an unsortable attribute to be rejected, got %v", err)
+	}
+}
+
+func TestSynonyms(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	config := &models.IndexConfig{ID: "shop", PrimaryKey: "id"}
+	if err := store.CreateIndex(config); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	docs := []map[string]any{
+		{"id": "1", "title": "Slim notebook"},
+		{"id": "2", "title": "Portable computer stand"},
+		{"id": "3", "title": "Laptop bag"},
+		{"id": "4", "title": "Computer mouse"},
+	}
+	if err := store.AddDocuments("shop", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	if err := search.ValidateSynonyms(map[string][]string{"laptop": {""}}); err == nil {
+		t.Error("Expected an error for an empty synonym")
+	}
+	synonyms := map[string][]string{"Laptop": {"notebook", "portable computer"}}
+	if err := store.UpdateIndex("shop", &models.IndexConfig{PrimaryKey: "id", Synonyms: synonyms}); err != nil {
+		t.Fatalf("Failed to update index: %v", err)
+	}
+
+	index, config, _ := store.GetIndex("shop")
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"laptop", 3},
+		{"notebook", 3},
+		{`"portable computer"`, 3},
+		{"title:laptop", 3},
+		{"computer", 2},
+		{"+laptop -bag", 2},
+	}
+	for _, tt := range tests {
+		response, err := search.Execute(index, models.SearchRequest{Query: tt.query, Synonyms: config.Synonyms})
+		if err != nil {
+			t.Fatalf("Search %q failed: %v", tt.query, err)
+		}
+		if int(response.TotalHits) != tt.want {
+			t.Errorf("Query %q: expected %d hits, got %d", tt.query, tt.want, response.TotalHits)
+		}
+	}
+}
+
+// TestFindDuplicates tests exact and fuzzy duplicate clustering
+func TestFindDuplicates(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.CreateIndex(&models.IndexConfig{ID: "catalog", PrimaryKey: "id"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	docs := []map[string]any{
+		{"id": "1", "brand": "Acme", "title": "Acme Cordless Drill 18V"},
+		{"id": "2", "brand": "ACME ", "title": "Acme cordless drill, 18V"},
+		{"id": "3", "brand": "acme", "title": "Acme Garden Hose"},
+		{"id": "4", "brand": "Globex", "title": "Acme Cordless Drill 18V"},
+		{"id": "5", "brand": "Initech", "title": "Stapler"},
+	}
+	if err := store.AddDocuments("catalog", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+	index, _, _ := store.GetIndex("catalog")
+
+	exact, err := search.FindDuplicates(index, models.DuplicatesRequest{Fields: []string{"brand"}})
+	if err != nil {
+		t.Fatalf("FindDuplicates failed: %v", err)
+	}
+	if exact.TotalClusters != 1 || len(exact.Clusters[0].Documents) != 3 || exact.Clusters[0].Key != "acme" {
+		t.Errorf("Expected one cluster of three acme documents, got %+v", exact.Clusters)
+	}
+
+	fuzzy, err := search.FindDuplicates(index, models.DuplicatesRequest{Fields: []string{"brand"}, FuzzyField: "title"})
+	if err != nil {
+		t.Fatalf("FindDuplicates failed: %v", err)
+	}
+	if fuzzy.TotalClusters != 1 || len(fuzzy.Clusters[0].Documents) != 2 || fuzzy.Clusters[0].Score < 0.8 {
+		t.Fatalf("Expected the two acme drills, got %+v", fuzzy.Clusters)
+	}
+	ids := []any{fuzzy.Clusters[0].Documents[0]["_id"], fuzzy.Clusters[0].Documents[1]["_id"]}
+	if ids[0] != "1" || ids[1] != "2" {
+		t.Errorf("Expected documents 1 and 2, got %v", ids)
+	}
+
+	titles, err := search.FindDuplicates(index, models.DuplicatesRequest{FuzzyField: "title", MaxDocuments: 4})
+	if err != nil {
+		t.Fatalf("FindDuplicates failed: %v", err)
+	}
+	if titles.TotalClusters != 1 || len(titles.Clusters[0].Documents) != 3 || !titles.Truncated || titles.Scanned != 4 {
+		t.Errorf("Expected three drills across brands in a truncated scan, got %+v", titles)
+	}
+
+	if _, err := search.FindDuplicates(index, models.DuplicatesRequest{}); err == nil {
+		t.Error("Expected a request without fields to be rejected")
+	}
+}
+
+// TestLookups tests that hits are joined with documents of another index
+func TestLookups(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.CreateIndex(&models.IndexConfig{ID: "authors", PrimaryKey: "id", HiddenAttributes: []string{"email"}}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	if err := store.CreateIndex(&models.IndexConfig{ID: "books", PrimaryKey: "id"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	authors := []map[string]any{
+		{"id": 1, "name": "Ursula K. Le Guin", "email": "ursula@example.com"},
+		{"id": 2, "name": "Ted Chiang", "email": "ted@example.com"},
+	}
+	books := []map[string]any{
+		{"id": "a", "title": "The Dispossessed", "authorId": 1},
+		{"id": "b", "title": "Exhalation", "authorId": 2, "editorIds": []any{1, 2, 9}},
+		{"id": "c", "title": "Anonymous", "authorId": 7},
+	}
+	if err := store.AddDocuments("authors", "", authors); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+	if err := store.AddDocuments("books", "", books); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	index, _, _ := store.GetIndex("books")
+	response, err := search.Execute(index, models.SearchRequest{Sort: []string{"_id"}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	lookups := []models.Lookup{
+		{Index: "authors", Key: "authorId", As: "author"},
+		{Index: "authors", Key: "editorIds", Fields: []string{"name"}, As: "editors"},
+	}
+	if err := search.ApplyLookups(response.Hits, lookups, store.GetIndex, false); err != nil {
+		t.Fatalf("ApplyLookups failed: %v", err)
+	}
+
+	author, _ := response.Hits[0]["author"].(map[string]any)
+	if author["name"] != "Ursula K. Le Guin" || author["email"] != nil {
+		t.Errorf("Expected the author without hidden attributes, got %v", response.Hits[0]["author"])
+	}
+	editors, _ := response.Hits[1]["editors"].([]map[string]any)
+	if len(editors) != 2 || editors[0]["name"] != "Ursula K. Le Guin" || len(editors[0]) != 1 {
+		t.Errorf("Expected two editors with only their names, got %v", response.Hits[1]["editors"])
+	}
+	if value, ok := response.Hits[2]["author"]; !ok || value != nil {
+		t.Errorf("Expected a missing author to be null, got %v", value)
+	}
+
+	err = search.ApplyLookups(response.Hits, []models.Lookup{{Index: "missing", Key: "authorId"}}, store.GetIndex, false)
+	if !errors.Is(err, search.ErrInvalidLookup) {
+		t.Errorf("Expected an unknown index to be an invalid lookup, got %v", err)
+	}
+}
+
+// TestPercolate tests matching documents against stored queries
+func TestPercolate(t *testing.T) {
+	dir := t.TempDir()
+	store, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+
+	precision := 2
+	config := &models.IndexConfig{
+		ID:            "listings",
+		PrimaryKey:    "id",
+		NumericFields: map[string]models.NumericFieldSettings{"price": {Precision: &precision}},
+	}
+	if err := store.CreateIndex(config); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	queries := []*models.StoredQuery{
+		{ID: "cheap-bikes", IndexID: "listings", Query: "bike", Filter: "price:<100"},
+		{ID: "red", IndexID: "listings", Query: "red", ExcludeTerms: []string{"tricycle"}},
+		{ID: "cars", IndexID: "listings", Query: "car"},
+	}
+	for _, q := range queries {
+		if err := search.ValidateStoredQuery(q, config); err != nil {
+			t.Fatalf("Invalid stored query %s: %v", q.ID, err)
+		}
+		if err := store.Queries().Put(q); err != nil {
+			t.Fatalf("Failed to store query: %v", err)
+		}
+	}
+
+	documents := []map[string]any{
+		{"id": "1", "title": "Red bike", "price": "$80"},
+		{"id": "2", "title": "Blue bike", "price": "$250"},
+		{"title": "Red tricycle", "price": "$40"},
+	}
+	results, err := store.Percolate("listings", documents)
+	if err != nil {
+		t.Fatalf("Percolate failed: %v", err)
+	}
+
+	want := [][]string{{"cheap-bikes", "red"}, {}, {}}
+	for i, result := range results {
+		if !slices.Equal(result.Queries, want[i]) {
+			t.Errorf("Document %d: expected queries %v, got %v", i, want[i], result.Queries)
+		}
+	}
+	if results[0].DocumentID != "1" || results[2].DocumentID != "" {
+		t.Errorf("Expected document IDs from the primary key, got %q and %q", results[0].DocumentID, results[2].DocumentID)
+	}
+	if documents[0]["price"] != "$80" {
+		t.Errorf("Expected percolated documents to be left unchanged, got %v", documents[0]["price"])
+	}
+
+	// Percolated documents are not indexed
+	index, _, _ := store.GetIndex("listings")
+	if count, _ := index.DocCount(); count != 0 {
+		t.Errorf("Expected an empty index, got %d documents", count)
+	}
+
+	if err := search.ValidateStoredQuery(&models.StoredQuery{ID: "bad", Query: "price:>"}, config); !errors.Is(err, search.ErrInvalidStoredQuery) {
+		t.Errorf("Expected an unparsable query to be rejected, got %v", err)
+	}
+
+	// Stored queries survive a restart and go away with their index
+	store.Close()
+	store, err = New(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer store.Close()
+	if got := len(store.Queries().List("listings")); got != 3 {
+		t.Errorf("Expected 3 stored queries after restart, got %d", got)
+	}
+	if err := store.DeleteIndex("listings"); err != nil {
+		t.Fatalf("Failed to delete index: %v", err)
+	}
+	if got := len(store.Queries().Records()); got != 0 {
+		t.Errorf("Expected stored queries to be deleted with their index, got %d", got)
+	}
+}
+
+// TestCurationRegistry tests storing curation rules per index and deleting
+// them with their index
+func TestCurationRegistry(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.CreateIndex(&models.IndexConfig{ID: "products", PrimaryKey: "id"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	rules := []*models.CurationRule{
+		{ID: "stands", IndexID: "products", Pattern: "case", Match: search.CurationMatchContains, Pinned: []string{"b", "e"}},
+		{ID: "cases", IndexID: "products", Pattern: "phone case", Pinned: []string{"d"}},
+	}
+	for _, rule := range rules {
+		if err := store.Curation().Put(rule); err != nil {
+			t.Fatalf("Failed to store rule: %v", err)
+		}
+	}
+	if got := store.Curation().List("products"); len(got) != 2 || got[0].ID != "cases" || got[1].ID != "stands" {
+		t.Errorf("Expected the index's rules in order, got %v", got)
+	}
+
+	if err := store.Curation().Delete("products", "stands"); err != nil {
+		t.Fatalf("Failed to delete rule: %v", err)
+	}
+	if _, err := store.Curation().Get("products", "stands"); !errors.Is(err, ErrRuleNotFound) {
+		t.Errorf("Expected a deleted rule to be gone, got %v", err)
+	}
+	if err := store.DeleteIndex("products"); err != nil {
+		t.Fatalf("Failed to delete index: %v", err)
+	}
+	if got := len(store.Curation().Records()); got != 0 {
+		t.Errorf("Expected curation rules to be deleted with their index, got %d", got)
+	}
+}
+
+func TestExportSettings(t *testing.T) {
+	source, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer source.Close()
+
+	config := &models.IndexConfig{
+		ID:           "products",
+		PrimaryKey:   "id",
+		Synonyms:     map[string][]string{"laptop": {"notebook"}},
+		TextAnalysis: &models.TextAnalysisSettings{StopWordsLanguage: "en", StopWords: []string{"acme"}},
+	}
+	if err := source.CreateIndex(config); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	rule := &models.CurationRule{ID: "laptops", IndexID: "products", Pattern: "laptop", Pinned: []string{"42"}, CreatedAt: time.Now().UTC()}
+	if err := source.Curation().Put(rule); err != nil {
+		t.Fatalf("Failed to store rule: %v", err)
+	}
+
+	export, err := source.ExportSettings("products")
+	if err != nil {
+		t.Fatalf("Failed to export settings: %v", err)
+	}
+	if export.Settings.ID != "" || len(export.Rules) != 1 || export.Rules[0].IndexID != "" {
+		t.Fatalf("Expected settings and rules without index IDs, got %+v", export)
+	}
+	if config.ID != "products" || rule.IndexID != "products" {
+		t.Errorf("Expected exporting to leave the index's settings and rules unchanged")
+	}
+
+	// The export sets up an index in another store the same way
+	target, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer target.Close()
+
+	imported := *export.Settings
+	imported.ID = "staging"
+	if err := target.CreateIndex(&imported); err != nil {
+		t.Fatalf("Failed to create index from export: %v", err)
+	}
+	for _, rule := range export.Rules {
+		rule.IndexID = "staging"
+		if err := target.Curation().Put(rule); err != nil {
+			t.Fatalf("Failed to store rule: %v", err)
+		}
+	}
+	again, err := target.ExportSettings("staging")
+	if err != nil {
+		t.Fatalf("Failed to export settings: %v", err)
+	}
+	if !slices.Equal(again.Settings.TextAnalysis.StopWords, []string{"acme"}) || len(again.Settings.Synonyms["laptop"]) != 1 {
+		t.Errorf("Expected stop words and synonyms to be imported, got %+v", again.Settings)
+	}
+	if len(again.Rules) != 1 || again.Rules[0].Pattern != "laptop" || !again.Rules[0].CreatedAt.Equal(rule.CreatedAt) {
+		t.Errorf("Expected the rule to be imported, got %+v", again.Rules)
+	}
+
+	if _, err := target.ExportSettings("missing"); err == nil {
+		t.Error("Expected exporting a missing index to fail")
+	}
+}
+
+func TestStorage(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.CreateIndex(&models.IndexConfig{ID: "logs", PrimaryKey: "id"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	documents := make([]map[string]any, 0, 100)
+	for i := range 100 {
+		documents = append(documents, map[string]any{"id": fmt.Sprint(i), "message": strings.Repeat("lorem ipsum ", 20)})
+	}
+	if err := store.AddDocuments("logs", "", documents); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	report, err := store.Storage("logs")
+	if err != nil {
+		t.Fatalf("Failed to report storage: %v", err)
+	}
+	var sum int64
+	for _, component := range report.Components {
+		sum += component.Bytes
+	}
+	if report.TotalBytes == 0 || sum != report.TotalBytes {
+		t.Errorf("Expected components to add up to a non-zero total, got %+v", report)
+	}
+	if report.Components[StorageMetadata].Files == 0 {
+		t.Errorf("Expected metadata files, got %+v", report.Components)
+	}
+	if report.Growth != nil {
+		t.Errorf("Expected no growth without samples, got %+v", report.Growth)
+	}
+
+	// An empty sample two hours ago makes the whole size growth
+	store.storage.record("logs", 0, time.Now().Add(-2*time.Hour))
+	report, err = store.Storage("logs")
+	if err != nil {
+		t.Fatalf("Failed to report storage: %v", err)
+	}
+	if report.Growth == nil {
+		t.Fatal("Expected a growth rate")
+	}
+	want := float64(report.TotalBytes) / 2
+	if diff := report.Growth.BytesPerHour - want; diff > want/100 || diff < -want/100 {
+		t.Errorf("Expected about %.0f bytes per hour, got %.0f", want, report.Growth.BytesPerHour)
+	}
+
+	if _, err := store.Storage("missing"); err == nil {
+		t.Error("Expected an error for a missing index")
+	}
+}
+
+func TestCompaction(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.CreateIndex(&models.IndexConfig{ID: "logs", PrimaryKey: "id"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	addBatch := func(batch int) {
+		documents := make([]map[string]any, 0, 10)
+		for i := range 10 {
+			documents = append(documents, map[string]any{"id": fmt.Sprint(batch*10 + i), "message": "lorem ipsum"})
+		}
+		if err := store.AddDocuments("logs", "", documents); err != nil {
+			t.Fatalf("Failed to add documents: %v", err)
+		}
+	}
+	for batch := range 3 {
+		addBatch(batch)
+	}
+	if err := store.DeleteDocumentsInternal("logs", "", []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}); err != nil {
+		t.Fatalf("Failed to delete documents: %v", err)
+	}
+	// The new segment is merged with the one holding the deletes, either by
+	// bleve in the background or by the compaction
+	addBatch(3)
+
+	stats, err := store.Segments("logs")
+	if err != nil {
+		t.Fatalf("Failed to read segments: %v", err)
+	}
+	if stats.Documents != 30 || stats.Segments == 0 {
+		t.Fatalf("Expected 30 live documents, got %+v", stats)
+	}
+
+	compactErr := store.Compact(context.Background(), "logs", "test")
+	if compactErr != nil && !errors.Is(compactErr, ErrNothingToCompact) {
+		t.Fatalf("Failed to compact: %v", compactErr)
+	}
+	stats, err = store.Segments("logs")
+	if err != nil {
+		t.Fatalf("Failed to read segments: %v", err)
+	}
+	if stats.Segments != 1 || stats.DeletedDocuments != 0 || stats.Documents != 30 {
+		t.Errorf("Expected one segment without deletes, got %+v", stats)
+	}
+	if err := store.Compact(context.Background(), "logs", "again"); !errors.Is(err, ErrNothingToCompact) {
+		t.Errorf("Expected a single segment to have nothing to compact, got %v", err)
+	}
+	status := store.Compaction("logs")
+	if status == nil || status.Running {
+		t.Fatalf("Unexpected compaction status: %+v", status)
+	}
+	if compactErr == nil && (status.LastReason != "test" || status.LastError != "") {
+		t.Errorf("Expected the compaction to be recorded, got %+v", status)
+	}
+	if compactErr != nil && status.LastCompactedAt != nil {
+		t.Errorf("Expected no compaction to be recorded, got %+v", status)
+	}
+
+	// A lone segment keeps its deleted documents rather than being reported
+	// as compacted
+	if err := store.CreateIndex(&models.IndexConfig{ID: "single", PrimaryKey: "id"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	if err := store.AddDocuments("single", "", []map[string]any{{"id": "a"}, {"id": "b"}}); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+	if err := store.DeleteDocumentsInternal("single", "", []string{"a"}); err != nil {
+		t.Fatalf("Failed to delete documents: %v", err)
+	}
+	if err := store.Compact(context.Background(), "single", "test"); !errors.Is(err, ErrNothingToCompact) {
+		t.Errorf("Expected nothing to compact, got %v", err)
+	}
+	if status := store.Compaction("single"); status == nil || status.LastCompactedAt != nil {
+		t.Errorf("Expected no compaction to be recorded, got %+v", status)
+	}
+
+	// Thresholds only apply in the window, which may wrap midnight
+	policy := models.CompactionSettings{MaxDeletedRatio: 0.2, MaxSegments: 10, Window: "22:00-04:00"}
+	noon := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	night := time.Date(2024, 5, 1, 2, 0, 0, 0, time.UTC)
+	tests := []struct {
+		stats SegmentStats
+		at    time.Time
+		due   bool
+	}{
+		{SegmentStats{Segments: 3, DeletedRatio: 0.3}, night, true},
+		{SegmentStats{Segments: 11}, night, true},
+		{SegmentStats{Segments: 3, DeletedRatio: 0.3}, noon, false},
+		{SegmentStats{Segments: 10, DeletedRatio: 0.1}, night, false},
+		{SegmentStats{Segments: 1, DeletedRatio: 0.5}, night, false},
+	}
+	for i, tt := range tests {
+		if reason := compactionReason(policy, &tt.stats, tt.at); (reason != "") != tt.due {
+			t.Errorf("Case %d: expected due=%v, got %q", i, tt.due, reason)
+		}
+	}
+	disabled := effectiveCompaction(policy, &models.CompactionSettings{Disabled: true})
+	if reason := compactionReason(disabled, &tests[0].stats, night); reason != "" {
+		t.Errorf("Expected no compaction when disabled, got %q", reason)
+	}
+	for _, invalid := range []models.CompactionSettings{{Window: "2-4"}, {Window: "04:00-04:00"}, {MaxDeletedRatio: 2}, {Timezone: "Mars/Base"}} {
+		if err := ValidateCompaction(&invalid); err == nil {
+			t.Errorf("Expected %+v to be invalid", invalid)
+		}
+	}
+}
+
+// TestReindex tests rebuilding an index with new field settings while it is
+// written to
+func TestReindex(t *testing.T) {
+	dir := t.TempDir()
+	store, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+
+	if err := store.CreateIndex(&models.IndexConfig{ID: "products", PrimaryKey: "id"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	documents := make([]map[string]any, 0, 1200)
+	for i := range 1200 {
+		documents = append(documents, map[string]any{"id": fmt.Sprint(i), "sku": fmt.Sprintf("ABC-%d", i)})
+	}
+	if err := store.AddDocuments("products", "", documents); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	settings := &models.IndexConfig{
+		PrimaryKey: "id",
+		Fields:     map[string]models.FieldSettings{"sku": {Exact: true}},
+	}
+	if err := store.StartReindex("products", settings); err != nil {
+		t.Fatalf("Failed to start reindex: %v", err)
+	}
+	if err := store.StartReindex("products", &models.IndexConfig{PrimaryKey: "sku"}); err == nil {
+		t.Error("Expected an error for a changed primary key")
+	}
+
+	// Writes made during the build reach the rebuilt index
+	if err := store.UpdateDocumentInternal("products", "1", map[string]any{"sku": "XYZ-1"}); err != nil {
+		t.Fatalf("Failed to update document: %v", err)
+	}
+	if err := store.DeleteDocumentInternal("products", "2"); err != nil {
+		t.Fatalf("Failed to delete document: %v", err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	status, _ := store.Reindex("products")
+	for status.State == ReindexBuilding && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		status, _ = store.Reindex("products")
+	}
+	if status.State != ReindexCompleted || status.Total != 1200 {
+		t.Fatalf("Expected a completed reindex of 1200 documents, got %+v", status)
+	}
+
+	_, config, _ := store.GetIndex("products")
+	if !config.Fields["sku"].Exact {
+		t.Errorf("Expected the new settings to be current, got %+v", config)
+	}
+	if len(store.PendingReindexes()) != 0 {
+		t.Error("Expected no pending reindex")
+	}
+	store.Close()
+
+	// The swapped index survives a restart
+	store, err = New(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer store.Close()
+
+	index, _, _ := store.GetIndex("products")
+	tests := []struct {
+		query string
+		want  uint64
+	}{
+		{"sku:ABC-10", 1},
+		{"sku:abc", 0},
+		{"sku:XYZ-1", 1},
+		{"sku:ABC-2", 0},
+	}
+	for _, tt := range tests {
+		result, err := index.Search(bleve.NewSearchRequest(bleve.NewQueryStringQuery(tt.query)))
+		if err != nil {
+			t.Fatalf("Search %q failed: %v", tt.query, err)
+		}
+		if result.Total != tt.want {
+			t.Errorf("Query %q: expected %d hits, got %d", tt.query, tt.want, result.Total)
+		}
+	}
+	if count, _ := index.DocCount(); count != 1199 {
+		t.Errorf("Expected 1199 documents, got %d", count)
+	}
+}
+
+// TestArchiveIndex tests that an index archive holds a working copy of the
+// index with its configuration
+func TestArchiveIndex(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	config := &models.IndexConfig{ID: "products", PrimaryKey: "id", Fields: map[string]models.FieldSettings{"sku": {Exact: true}}}
+	if err := store.CreateIndex(config); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	docs := []map[string]any{{"id": "1", "sku": "ABC-1"}, {"id": "2", "sku": "ABC-2"}}
+	if err := store.AddDocuments("products", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	archive, err := store.ArchiveIndex("products")
+	if err != nil {
+		t.Fatalf("Failed to archive index: %v", err)
+	}
+	if archive.Manifest.Documents != 2 || archive.Manifest.FormatVersion != ArchiveFormatVersion {
+		t.Errorf("Unexpected manifest: %+v", archive.Manifest)
+	}
+	var buf bytes.Buffer
+	if err := archive.Write(&buf); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("Failed to close archive: %v", err)
+	}
+
+	data := buf.Bytes()
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+	extracted := t.TempDir()
+	entries := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read archive: %v", err)
+		}
+		data, _ := io.ReadAll(tr)
+		entries[header.Name] = data
+		if rel, ok := strings.CutPrefix(header.Name, "index/"); ok {
+			path := filepath.Join(extracted, rel)
+			os.MkdirAll(filepath.Dir(path), 0755)
+			os.WriteFile(path, data, 0644)
+		}
+	}
+	if !strings.Contains(string(entries["config.json"]), `"sku":{"exact":true}`) {
+		t.Errorf("Expected the index config, got %s", entries["config.json"])
+	}
+	if _, ok := entries["manifest.json"]; !ok {
+		t.Error("Expected a manifest")
+	}
+
+	index, err := bleve.Open(extracted)
+	if err != nil {
+		t.Fatalf("Failed to open archived index: %v", err)
+	}
+	defer index.Close()
+	result, err := index.Search(bleve.NewSearchRequest(bleve.NewQueryStringQuery("sku:ABC-2")))
+	if err != nil || result.Total != 1 {
+		t.Errorf("Expected the archived document, got %v (%v)", result, err)
+	}
+
+	// The archive imports under a new ID
+	imported, err := store.ReadArchive(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+	defer imported.Close()
+	var scanned []map[string]any
+	if err := imported.ScanDocuments(1, func(documents []map[string]any) error {
+		scanned = append(scanned, documents...)
+		return nil
+	}); err != nil || len(scanned) != 2 || scanned[1]["sku"] != "ABC-2" {
+		t.Errorf("Expected the archived documents, got %v (%v)", scanned, err)
+	}
+	copyConfig := imported.Config
+	copyConfig.ID = "products-copy"
+	if err := store.InstallArchive(imported, copyConfig); err != nil {
+		t.Fatalf("Failed to install archive: %v", err)
+	}
+	copied, _, err := store.GetIndex("products-copy")
+	if err != nil {
+		t.Fatalf("Expected the imported index: %v", err)
+	}
+	if count, _ := copied.DocCount(); count != 2 {
+		t.Errorf("Expected 2 imported documents, got %d", count)
+	}
+
+	// Archives of a newer format are rejected
+	var newer bytes.Buffer
+	gzw := gzip.NewWriter(&newer)
+	tw := tar.NewWriter(gzw)
+	manifest := []byte(`{"formatVersion": 99}`)
+	tw.WriteHeader(&tar.Header{Name: "manifest.json", Mode: 0644, Size: int64(len(manifest))})
+	tw.Write(manifest)
+	tw.Close()
+	gzw.Close()
+	if _, err := store.ReadArchive(&newer); !errors.Is(err, ErrInvalidArchive) {
+		t.Errorf("Expected an invalid archive error, got %v", err)
+	}
+}
+
+func TestOnIndexDeleted(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	var deleted []string
+	store.OnIndexDeleted(func(indexID string) {
+		deleted = append(deleted, indexID)
+	})
+
+	for _, id := range []string{"products", "orders"} {
+		if err := store.CreateIndex(&models.IndexConfig{ID: id, PrimaryKey: "id"}); err != nil {
+			t.Fatalf("Failed to create index: %v", err)
+		}
+	}
+	if err := store.DeleteIndex("products"); err != nil {
+		t.Fatalf("Failed to delete index: %v", err)
+	}
+	if err := store.DeleteIndexInternal("orders"); err != nil {
+		t.Fatalf("Failed to delete index: %v", err)
+	}
+	if err := store.DeleteIndex("missing"); err == nil {
+		t.Fatal("Expected an error deleting a missing index")
+	}
+
+	if !slices.Equal(deleted, []string{"products", "orders"}) {
+		t.Errorf("Expected listeners for both deletions, got %v", deleted)
+	}
+}
+
+func TestConflictPolicies(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	stored := func(indexID string) map[string]any {
+		index, _, err := store.GetIndex(indexID)
+		if err != nil {
+			t.Fatalf("Failed to get index: %v", err)
+		}
+		request := bleve.NewSearchRequest(bleve.NewDocIDQuery([]string{"1"}))
+		request.Fields = []string{"*"}
+		result, err := index.Search(request)
+		if err != nil || len(result.Hits) != 1 {
+			t.Fatalf("Failed to fetch document: %v", err)
+		}
+		return result.Hits[0].Fields
+	}
+	write := func(indexID, origin string, timestamp float64, fields map[string]any) {
+		doc := map[string]any{"id": "1", OriginField: origin, OriginTimestampField: timestamp}
+		for field, value := range fields {
+			doc[field] = value
+		}
+		if err := store.AddDocumentsInternal(indexID, []map[string]any{doc}); err != nil {
+			t.Fatalf("Failed to add document: %v", err)
+		}
+	}
+
+	policies := map[string]*models.ConflictSettings{
+		"lww":      {Policy: models.ConflictLastWriteWins},
+		"priority": {Policy: models.ConflictPriority, Priorities: map[string]int{"crm": 1}},
+		"merge":    {Policy: models.ConflictMerge},
+	}
+	for id, settings := range policies {
+		if err := store.CreateIndex(&models.IndexConfig{ID: id, PrimaryKey: "id", Conflicts: settings}); err != nil {
+			t.Fatalf("Failed to create index: %v", err)
+		}
+	}
+
+	// An older write loses, a newer one wins
+	write("lww", "crm", 200, map[string]any{"name": "crm"})
+	write("lww", "shop", 100, map[string]any{"name": "shop"})
+	if name := stored("lww")["name"]; name != "crm" {
+		t.Errorf("Expected the newer write to be kept, got %v", name)
+	}
+	write("lww", "shop", 300, map[string]any{"name": "shop"})
+	if name := stored("lww")["name"]; name != "shop" {
+		t.Errorf("Expected the newest write to win, got %v", name)
+	}
+	if stats := store.ConflictStats("lww", "shop"); stats.Rejected != 1 || stats.Overwritten != 1 {
+		t.Errorf("Unexpected conflict stats %+v", stats)
+	}
+
+	// A lower priority loses even when newer
+	write("priority", "crm", 100, map[string]any{"name": "crm"})
+	write("priority", "shop", 200, map[string]any{"name": "shop"})
+	if name := stored("priority")["name"]; name != "crm" {
+		t.Errorf("Expected the higher priority write to be kept, got %v", name)
+	}
+
+	// Fields of both ingresses are kept
+	write("merge", "crm", 100, map[string]any{"email": "a@example.com"})
+	write("merge", "shop", 200, map[string]any{"orders": 3.0})
+	doc := stored("merge")
+	if doc["email"] != "a@example.com" || doc["orders"] != 3.0 || doc[OriginField] != "shop" {
+		t.Errorf("Expected merged fields, got %v", doc)
+	}
+	if stats := store.ConflictStats("merge", "shop"); stats.Merged != 1 {
+		t.Errorf("Unexpected conflict stats %+v", stats)
+	}
+
+	if err := ValidateConflicts(&models.ConflictSettings{Policy: "first-write-wins"}); err == nil {
+		t.Error("Expected an unknown policy to be refused")
+	}
+}
+
+func TestAliasRollover(t *testing.T) {
+	dataDir := t.TempDir()
+	store, err := New(dataDir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer func() { store.Close() }()
+
+	if next, err := NextPartition("logs-000009"); err != nil || next != "logs-000010" {
+		t.Errorf("Expected logs-000010, got %q (%v)", next, err)
+	}
+	if _, err := NextPartition("logs"); err == nil {
+		t.Error("Expected an error for an index without numeric suffix")
+	}
+
+	now := time.Now()
+	if _, err := store.ResolveAlias("logs", now); !errors.Is(err, ErrAliasNotFound) {
+		t.Errorf("Expected ErrAliasNotFound, got %v", err)
+	}
+
+	if err := store.CreateIndex(&models.IndexConfig{ID: "logs-000001", PrimaryKey: "id"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	docs := []map[string]any{{"id": "1"}, {"id": "2"}, {"id": "3"}}
+	if err := store.AddDocuments("logs-000001", "", docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	alias, err := store.ResolveAlias("logs", now)
+	if err != nil {
+		t.Fatalf("Failed to resolve alias: %v", err)
+	}
+	if alias.WriteIndex != "logs-000001" {
+		t.Errorf("Expected write index logs-000001, got %s", alias.WriteIndex)
+	}
+	if _, err := store.ResolveAlias("logs-000001", now); err == nil {
+		t.Error("Expected an error resolving an index as an alias")
+	}
+
+	met, rollover, err := store.RolloverConditionsMet(alias, models.RolloverConditions{MaxDocs: 5, MaxAge: "1d"}, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to check conditions: %v", err)
+	}
+	if rollover || met["maxDocs"] || met["maxAge"] {
+		t.Errorf("Expected no condition met, got %v", met)
+	}
+	met, rollover, _ = store.RolloverConditionsMet(alias, models.RolloverConditions{MaxDocs: 3, MaxAge: "1d"}, now)
+	if !rollover || !met["maxDocs"] || met["maxAge"] {
+		t.Errorf("Expected maxDocs met, got %v", met)
+	}
+	if _, rollover, _ := store.RolloverConditionsMet(alias, models.RolloverConditions{}, now); !rollover {
+		t.Error("Expected an unconditional rollover")
+	}
+
+	// Roll over as the rollover handler does
+	if err := store.CreateIndex(&models.IndexConfig{ID: "logs-000002", PrimaryKey: "id"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	if err := store.Aliases().Put(&models.IndexAlias{Name: "logs", WriteIndex: "logs-000002", Indexes: []string{"logs-000001", "logs-000002"}, RolledOverAt: now}); err != nil {
+		t.Fatalf("Failed to put alias: %v", err)
+	}
+	if id := store.WriteIndex("logs"); id != "logs-000002" {
+		t.Errorf("Expected writes to logs-000002, got %s", id)
+	}
+	if id := store.WriteIndex("logs-000001"); id != "logs-000001" {
+		t.Errorf("Expected an index to write to itself, got %s", id)
+	}
+
+	// Deleted partitions leave the alias, which survives a restart
+	if err := store.DeleteIndex("logs-000002"); err != nil {
+		t.Fatalf("Failed to delete index: %v", err)
+	}
+	store.Close()
+	store, err = New(dataDir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	alias, err = store.Aliases().Get("logs")
+	if err != nil {
+		t.Fatalf("Expected alias to survive a restart: %v", err)
+	}
+	if alias.WriteIndex != "logs-000001" || len(alias.Indexes) != 1 {
+		t.Errorf("Expected only logs-000001 left, got %+v", alias)
+	}
+
+	if err := store.DeleteIndex("logs-000001"); err != nil {
+		t.Fatalf("Failed to delete index: %v", err)
+	}
+	if _, err := store.Aliases().Get("logs"); !errors.Is(err, ErrAliasNotFound) {
+		t.Errorf("Expected the alias removed with its last partition, got %v", err)
+	}
+}
+
+func TestIndexTypes(t *testing.T) {
+	dataDir := t.TempDir()
+	store, err := New(dataDir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer func() { store.Close() }()
+
+	if err := ValidateIndexType(IndexTypeScorch, KVStoreBoltDB); err == nil {
+		t.Error("Expected scorch with boltdb to be rejected")
+	}
+	if err := ValidateIndexType("leveldb", ""); err == nil {
+		t.Error("Expected an unknown index type to be rejected")
+	}
+
+	configs := []*models.IndexConfig{
+		{ID: "default", PrimaryKey: "id"},
+		{ID: "upside", PrimaryKey: "id", IndexType: IndexTypeUpsideDown},
+		{ID: "memory", PrimaryKey: "id", KVStore: KVStoreMemory},
+		{ID: "upside-memory", PrimaryKey: "id", IndexType: IndexTypeUpsideDown, KVStore: KVStoreMemory},
+	}
+	for _, config := range configs {
+		if err := store.CreateIndex(config); err != nil {
+			t.Fatalf("Failed to create index %s: %v", config.ID, err)
+		}
+		if err := store.AddDocuments(config.ID, "", []map[string]any{{"id": "1", "title": "hello"}}); err != nil {
+			t.Fatalf("Failed to add documents to %s: %v", config.ID, err)
+		}
+	}
+
+	_, config, _ := store.GetIndex("default")
+	if config.IndexType != IndexTypeScorch || config.KVStore != "" {
+		t.Errorf("Expected scorch on disk by default, got %q/%q", config.IndexType, config.KVStore)
+	}
+	_, config, _ = store.GetIndex("upside")
+	if config.KVStore != KVStoreBoltDB {
+		t.Errorf("Expected upside_down to default to boltdb, got %q", config.KVStore)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "memory")); !os.IsNotExist(err) {
+		t.Errorf("Expected no directory for an in-memory index, got %v", err)
+	}
+
+	// Settings updates keep the index type
+	if err := store.UpdateIndex("upside", &models.IndexConfig{PrimaryKey: "id"}); err != nil {
+		t.Fatalf("Failed to update index: %v", err)
+	}
+	_, config, _ = store.GetIndex("upside")
+	if config.IndexType != IndexTypeUpsideDown {
+		t.Errorf("Expected the index type kept, got %q", config.IndexType)
+	}
+
+	if _, err := store.ArchiveIndex("memory"); err == nil {
+		t.Error("Expected archiving an in-memory index to fail")
+	}
+
+	// In-memory indexes come back empty after a restart
+	store.Close()
+	store, err = New(dataDir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	for _, config := range configs {
+		index, _, err := store.GetIndex(config.ID)
+		if err != nil {
+			t.Fatalf("Expected index %s after a restart: %v", config.ID, err)
+		}
+		count, _ := index.DocCount()
+		want := uint64(1)
+		if config.KVStore == KVStoreMemory {
+			want = 0
+		}
+		if count != want {
+			t.Errorf("Expected %d documents in %s, got %d", want, config.ID, count)
+		}
+	}
+
+	// Store defaults apply to new indexes
+	if err := store.SetIndexTypeDefaults("", KVStoreMemory); err != nil {
+		t.Fatalf("Failed to set defaults: %v", err)
+	}
+	config = &models.IndexConfig{ID: "ephemeral", PrimaryKey: "id"}
+	if err := store.CreateIndex(config); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	if config.IndexType != IndexTypeScorch || config.KVStore != KVStoreMemory {
+		t.Errorf("Expected scorch in memory, got %q/%q", config.IndexType, config.KVStore)
+	}
+}
+
+func TestClusterSettings(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	if limit := s.Settings().Number(SettingSearchDefaultLimit); limit != search.DefaultLimit {
+		t.Errorf("Expected the default limit to be %d, got %v", search.DefaultLimit, limit)
+	}
+
+	invalid := []map[string]any{
+		{"search.unknown": float64(1)},
+		{SettingSearchDefaultLimit: float64(0)},
+		{SettingSearchDefaultLimit: 2.5},
+		{SettingFeatureExplain: "no"},
+		{SettingFeatureGraphQL: float64(1)},
+		{SettingSearchesPerSecond: true},
+	}
+	for _, changes := range invalid {
+		if err := s.Settings().Update(changes); !errors.Is(err, ErrInvalidSetting) {
+			t.Errorf("Expected %v to be rejected, got %v", changes, err)
+		}
+	}
+
+	if err := s.Settings().Update(map[string]any{SettingSearchDefaultLimit: float64(5), SettingFeatureExplain: false}); err != nil {
+		t.Fatalf("Failed to update settings: %v", err)
+	}
+	if err := s.Settings().Update(map[string]any{SettingFeatureExplain: nil, SettingSearchesPerSecond: 0.5}); err != nil {
+		t.Fatalf("Failed to update settings: %v", err)
+	}
+	s.Close()
+
+	// Settings survive a restart, and only those set are overrides
+	s, err = New(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer s.Close()
+	if overrides := s.Settings().Overrides(); len(overrides) != 2 || overrides[SettingSearchDefaultLimit] != float64(5) || overrides[SettingSearchesPerSecond] != 0.5 {
+		t.Errorf("Expected 2 overrides, got %v", overrides)
+	}
+	if !s.Settings().Bool(SettingFeatureExplain) {
+		t.Errorf("Expected explain to be reset to enabled")
+	}
+	if effective := s.Settings().Effective(); len(effective) != 5 || effective[SettingSearchDefaultLimit] != float64(5) {
+		t.Errorf("Expected every setting with the default limit set, got %v", effective)
+	}
+}
+
+func TestSearchCache(t *testing.T) {
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+	if err := s.CreateIndex(&models.IndexConfig{ID: "books", PrimaryKey: "id"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	cache := s.SearchCache()
+	req := models.SearchRequest{Query: "dune", Facets: map[string]models.FacetRequest{"b": {}, "a": {}}}
+	key, err := SearchCacheKey(&req)
+	if err != nil {
+		t.Fatalf("Failed to build key: %v", err)
+	}
+	hidden := req
+	hidden.HiddenAttributes = []string{"secret"}
+	if hiddenKey, _ := SearchCacheKey(&hidden); hiddenKey == key {
+		t.Errorf("Expected hidden attributes to change the key")
+	}
+
+	generation := cache.Generation("books")
+	cache.Put("books", key, generation, &models.SearchResponse{Hits: []map[string]any{{"id": "1", "year": int64(9007199254740993)}}, TotalHits: 1})
+	response, ok := cache.Get("books", key)
+	if !ok || response.TotalHits != 1 || fmt.Sprint(response.Hits[0]["year"]) != "9007199254740993" {
+		t.Fatalf("Expected a cache hit, got %+v", response)
+	}
+
+	// A write drops the index's responses, and those of searches that
+	// started before it aren't cached
+	if err := s.AddDocuments("books", "", []map[string]any{{"id": "1", "title": "Dune"}}); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+	if _, ok := cache.Get("books", key); ok {
+		t.Errorf("Expected the write to invalidate the response")
+	}
+	cache.Put("books", key, generation, &models.SearchResponse{})
+	if _, ok := cache.Get("books", key); ok {
+		t.Errorf("Expected a response older than the write not to be cached")
+	}
+
+	// The least recently used responses are dropped first
+	cache.SetCapacity(2)
+	generation = cache.Generation("books")
+	for _, k := range []string{"a", "b", "a", "c"} {
+		if _, ok := cache.Get("books", k); !ok {
+			cache.Put("books", k, generation, &models.SearchResponse{})
+		}
+	}
+	if _, ok := cache.Get("books", "b"); ok {
+		t.Errorf("Expected b to be evicted")
+	}
+	if hits, misses := cache.Counts(); hits != 2 || misses != 6 {
+		t.Errorf("Expected 2 hits and 6 misses, got %d and %d", hits, misses)
+	}
+}
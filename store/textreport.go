@@ -0,0 +1,257 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"bright/models"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// Text report bounds
+const (
+	// DefaultTextReportSample is the number of documents sampled by default
+	DefaultTextReportSample = 1000
+
+	// MaxTextReportSample bounds the documents a report samples
+	MaxTextReportSample = 10000
+
+	// textReportTokens bounds the stop words and noisy tokens listed
+	textReportTokens = 25
+
+	// stopWordShare is the share of sampled documents a word must occur in
+	// to be suggested as a stop word
+	stopWordShare = 0.5
+
+	// languageShare is the share of sampled documents a language must be
+	// detected in to be suggested
+	languageShare = 0.5
+)
+
+// Reasons tokens are reported as noisy
+const (
+	NoisyNumber     = "number"
+	NoisyShort      = "short"
+	NoisyIdentifier = "identifier"
+	NoisyMarkup     = "markup"
+)
+
+// markupTokens are words left by HTML that was indexed as text
+var markupTokens = map[string]bool{
+	"nbsp": true, "amp": true, "quot": true, "lt": true, "gt": true, "apos": true,
+	"br": true, "div": true, "span": true, "href": true, "http": true, "https": true, "www": true,
+}
+
+// LanguageShare is the number of sampled documents detected in a language
+type LanguageShare struct {
+	Language  string  `json:"language"`
+	Documents int     `json:"documents"`
+	Share     float64 `json:"share"`
+}
+
+// TokenStat is a token with the number of sampled documents holding it
+type TokenStat struct {
+	Token     string  `json:"token"`
+	Documents int     `json:"documents"`
+	Share     float64 `json:"share"`
+
+	// Occurrences counts every use of the token in the sample
+	Occurrences int `json:"occurrences"`
+
+	// Reason tells why a token is noisy
+	Reason string `json:"reason,omitempty"`
+
+	// StopWordOf lists the languages the token is a stop word of
+	StopWordOf []string `json:"stopWordOf,omitempty"`
+}
+
+// TextReport describes the text of a sample of an index's documents, as a
+// starting point for its text analysis settings
+type TextReport struct {
+	IndexID          string `json:"indexId"`
+	SampledDocuments int    `json:"sampledDocuments"`
+	TotalDocuments   uint64 `json:"totalDocuments"`
+
+	// Languages are the languages detected, most frequent first
+	Languages []LanguageShare `json:"languages"`
+
+	// StopWords are words in at least half of the sampled documents
+	StopWords []TokenStat `json:"stopWords"`
+
+	// NoisyTokens are frequent numbers, single characters, identifiers and
+	// markup left in the text
+	NoisyTokens []TokenStat `json:"noisyTokens"`
+
+	// Suggested are text analysis settings matching the sample, or nil
+	// when it has too little text
+	Suggested *models.TextAnalysisSettings `json:"suggested,omitempty"`
+}
+
+// TextReport samples up to sampleSize documents of an index and reports
+// their languages, candidate stop words and noisy tokens. Fields in exclude
+// are left out of the sample.
+func (s *IndexStore) TextReport(id string, sampleSize int, exclude []string) (*TextReport, error) {
+	index, _, err := s.GetIndex(id)
+	if err != nil {
+		return nil, err
+	}
+	if sampleSize <= 0 {
+		sampleSize = DefaultTextReportSample
+	}
+	sampleSize = min(sampleSize, MaxTextReportSample)
+
+	total, err := index.DocCount()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count documents: %w", err)
+	}
+
+	request := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), sampleSize, 0, false)
+	request.Fields = []string{"*"}
+	result, err := index.Search(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sample documents: %w", err)
+	}
+
+	report := &TextReport{
+		IndexID:          id,
+		SampledDocuments: len(result.Hits),
+		TotalDocuments:   total,
+		Languages:        []LanguageShare{},
+		StopWords:        []TokenStat{},
+		NoisyTokens:      []TokenStat{},
+	}
+
+	languages := make(map[string]int)
+	stats := make(map[string]*TokenStat)
+	for _, hit := range result.Hits {
+		var text strings.Builder
+		for field, value := range hit.Fields {
+			if !strings.HasPrefix(field, "_") && !excluded(field, exclude) {
+				appendText(&text, value)
+			}
+		}
+
+		if language := DetectLanguage(text.String(), nil); language != "" {
+			languages[language]++
+		}
+
+		seen := make(map[string]bool)
+		for token := range strings.FieldsFuncSeq(strings.ToLower(text.String()), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+		}) {
+			stat, ok := stats[token]
+			if !ok {
+				stat = &TokenStat{Token: token}
+				stats[token] = stat
+			}
+			stat.Occurrences++
+			if !seen[token] {
+				seen[token] = true
+				stat.Documents++
+			}
+		}
+	}
+
+	sampled := float64(max(report.SampledDocuments, 1))
+	for language, n := range languages {
+		report.Languages = append(report.Languages, LanguageShare{Language: language, Documents: n, Share: float64(n) / sampled})
+	}
+	sort.Slice(report.Languages, func(i, j int) bool {
+		a, b := report.Languages[i], report.Languages[j]
+		return a.Documents > b.Documents || (a.Documents == b.Documents && a.Language < b.Language)
+	})
+
+	byFrequency := make([]*TokenStat, 0, len(stats))
+	for _, stat := range stats {
+		stat.Share = float64(stat.Documents) / sampled
+		stat.StopWordOf = slices.Sorted(slices.Values(stopWords[stat.Token]))
+		byFrequency = append(byFrequency, stat)
+	}
+	sort.Slice(byFrequency, func(i, j int) bool {
+		a, b := byFrequency[i], byFrequency[j]
+		if a.Documents != b.Documents {
+			return a.Documents > b.Documents
+		}
+		if a.Occurrences != b.Occurrences {
+			return a.Occurrences > b.Occurrences
+		}
+		return a.Token < b.Token
+	})
+
+	for _, stat := range byFrequency {
+		if reason := noisyReason(stat.Token); reason != "" {
+			if len(report.NoisyTokens) < textReportTokens && stat.Documents > 1 {
+				stat.Reason = reason
+				report.NoisyTokens = append(report.NoisyTokens, *stat)
+			}
+			continue
+		}
+		if len(report.StopWords) < textReportTokens && stat.Share >= stopWordShare && report.SampledDocuments > 1 {
+			report.StopWords = append(report.StopWords, *stat)
+		}
+	}
+
+	report.Suggested = suggestTextAnalysis(report)
+	return report, nil
+}
+
+// excluded reports whether a field is one of the attributes, or nested in one
+func excluded(field string, attributes []string) bool {
+	for _, attr := range attributes {
+		if field == attr || strings.HasPrefix(field, attr+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// noisyReason tells why a token is unlikely to be worth searching for, or
+// returns "" for words
+func noisyReason(token string) string {
+	letters, digits := 0, 0
+	for _, r := range token {
+		switch {
+		case unicode.IsLetter(r):
+			letters++
+		case unicode.IsDigit(r):
+			digits++
+		}
+	}
+	switch {
+	case markupTokens[token]:
+		return NoisyMarkup
+	case letters == 0 && digits > 0:
+		return NoisyNumber
+	case letters+digits == 1:
+		return NoisyShort
+	case letters > 0 && digits > 0 && len(token) >= 8:
+		return NoisyIdentifier
+	}
+	return ""
+}
+
+// suggestTextAnalysis suggests the stemmer and stop words of the language
+// most of the sample is in, and the frequent words that aren't already its
+// stop words
+func suggestTextAnalysis(report *TextReport) *models.TextAnalysisSettings {
+	suggested := &models.TextAnalysisSettings{}
+	language := ""
+	if len(report.Languages) > 0 && report.Languages[0].Share >= languageShare {
+		language = report.Languages[0].Language
+		suggested.Stemmer = language
+		suggested.StopWordsLanguage = language
+	}
+	for _, stat := range report.StopWords {
+		if !slices.Contains(stat.StopWordOf, language) {
+			suggested.StopWords = append(suggested.StopWords, stat.Token)
+		}
+	}
+	if language == "" && len(suggested.StopWords) == 0 {
+		return nil
+	}
+	return suggested
+}
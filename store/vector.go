@@ -0,0 +1,19 @@
+//go:build vectors
+
+package store
+
+import (
+	"bright/models"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+)
+
+// vectorFieldMapping returns the field mapping of a vector field
+func vectorFieldMapping(settings models.VectorSettings) (*mapping.FieldMapping, error) {
+	fieldMapping := bleve.NewVectorFieldMapping()
+	fieldMapping.Dims = settings.Dims
+	fieldMapping.Similarity = settings.Similarity
+	fieldMapping.VectorIndexOptimizedFor = settings.OptimizedFor
+	return fieldMapping, nil
+}
@@ -0,0 +1,15 @@
+//go:build !vectors
+
+package store
+
+import (
+	"bright/models"
+	"bright/search"
+
+	"github.com/blevesearch/bleve/v2/mapping"
+)
+
+// vectorFieldMapping fails without vector support
+func vectorFieldMapping(settings models.VectorSettings) (*mapping.FieldMapping, error) {
+	return nil, search.ErrVectorsUnsupported
+}
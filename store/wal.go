@@ -0,0 +1,214 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/bytedance/sonic"
+)
+
+// walFile is the name of the write-ahead log inside the data directory
+const walFile = "wal.log"
+
+// walCompactThreshold is the log size after which a fully committed log is truncated
+const walCompactThreshold = 64 << 20
+
+const (
+	walEntryAdd    = "add"
+	walEntryCommit = "commit"
+)
+
+// walEntry is a single record of the write-ahead log. A batch is journaled
+// as an "add" record and acknowledged with a "commit" record of the same
+// sequence number once bleve has committed it.
+type walEntry struct {
+	Seq        uint64           `json:"seq"`
+	Type       string           `json:"type"`
+	IndexID    string           `json:"indexId,omitempty"`
+	PrimaryKey string           `json:"primaryKey,omitempty"`
+	Documents  []map[string]any `json:"documents,omitempty"`
+}
+
+// WAL journals accepted document batches in single-node mode so they
+// survive a crash between being accepted and being committed to bleve.
+// In Raft mode the Raft log already provides this guarantee.
+type WAL struct {
+	file    *os.File
+	path    string
+	seq     uint64
+	pending map[uint64]struct{}
+	size    int64
+	mu      sync.Mutex
+}
+
+// openWAL opens (or creates) the log and returns the uncommitted entries
+func openWAL(dataDir string) (*WAL, []walEntry, error) {
+	path := filepath.Join(dataDir, walFile)
+
+	entries, lastSeq, err := readWAL(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open write-ahead log: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("failed to stat write-ahead log: %w", err)
+	}
+
+	return &WAL{
+		file:    file,
+		path:    path,
+		seq:     lastSeq,
+		pending: make(map[uint64]struct{}),
+		size:    info.Size(),
+	}, entries, nil
+}
+
+// readWAL returns the uncommitted entries of a log file in order, along
+// with the highest sequence number seen. A torn final record (from a crash
+// mid-write) is ignored.
+func readWAL(path string) ([]walEntry, uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, nil
+		}
+		return nil, 0, fmt.Errorf("failed to open write-ahead log: %w", err)
+	}
+	defer file.Close()
+
+	var (
+		adds      []walEntry
+		committed = make(map[uint64]bool)
+		lastSeq   uint64
+	)
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 && err == nil {
+			var entry walEntry
+			if err := sonic.Unmarshal(line, &entry); err != nil {
+				return nil, 0, fmt.Errorf("corrupt write-ahead log record: %w", err)
+			}
+			if entry.Seq > lastSeq {
+				lastSeq = entry.Seq
+			}
+			switch entry.Type {
+			case walEntryAdd:
+				adds = append(adds, entry)
+			case walEntryCommit:
+				committed[entry.Seq] = true
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read write-ahead log: %w", err)
+		}
+	}
+
+	pending := adds[:0]
+	for _, entry := range adds {
+		if !committed[entry.Seq] {
+			pending = append(pending, entry)
+		}
+	}
+
+	return pending, lastSeq, nil
+}
+
+// append writes and fsyncs a record (must be called with lock held)
+func (w *WAL) append(entry walEntry) error {
+	data, err := sonic.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal write-ahead log record: %w", err)
+	}
+	data = append(data, '\n')
+
+	n, err := w.file.Write(data)
+	w.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write write-ahead log: %w", err)
+	}
+
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync write-ahead log: %w", err)
+	}
+
+	return nil
+}
+
+// Journal durably records a batch before it is indexed and returns its sequence number
+func (w *WAL) Journal(indexID, primaryKey string, documents []map[string]any) (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.seq++
+	seq := w.seq
+
+	if err := w.append(walEntry{
+		Seq:        seq,
+		Type:       walEntryAdd,
+		IndexID:    indexID,
+		PrimaryKey: primaryKey,
+		Documents:  documents,
+	}); err != nil {
+		return 0, err
+	}
+
+	w.pending[seq] = struct{}{}
+	return seq, nil
+}
+
+// Commit acknowledges that a journaled batch has been committed to its index
+func (w *WAL) Commit(seq uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.pending, seq)
+
+	// Nothing is in flight, so the whole log can be dropped
+	if len(w.pending) == 0 && w.size >= walCompactThreshold {
+		return w.reset()
+	}
+
+	return w.append(walEntry{Seq: seq, Type: walEntryCommit})
+}
+
+// Discard forgets a journaled batch that failed to index, so it is not replayed
+func (w *WAL) Discard(seq uint64) error {
+	return w.Commit(seq)
+}
+
+// reset truncates the log (must be called with lock held)
+func (w *WAL) reset() error {
+	if err := w.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate write-ahead log: %w", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync write-ahead log: %w", err)
+	}
+	w.size = 0
+	return nil
+}
+
+// Close closes the log file
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.file.Close()
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"bright/models"
+	"bright/search"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// WarmupResult describes the warmup of a single index
+type WarmupResult struct {
+	IndexID   string        `json:"indexId"`
+	BytesRead int64         `json:"bytesRead"`
+	Queries   int           `json:"queries"`
+	Duration  time.Duration `json:"duration"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// Warmup prepares indexes for the first queries after a restart: every
+// index file is read once so segments and dictionaries are in the page
+// cache, then the index's configured warmup queries are executed.
+func (s *IndexStore) Warmup(ctx context.Context) []WarmupResult {
+	configs := s.GetAllConfigs()
+
+	ids := make([]string, 0, len(configs))
+	for id := range configs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	results := make([]WarmupResult, 0, len(ids))
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		results = append(results, s.warmupIndex(ctx, id, configs[id]))
+	}
+
+	return results
+}
+
+// warmupIndex reads an index's files and runs its warmup queries
+func (s *IndexStore) warmupIndex(ctx context.Context, id string, config *models.IndexConfig) WarmupResult {
+	start := time.Now()
+	result := WarmupResult{IndexID: id}
+
+	index, _, err := s.GetIndex(id)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.BytesRead, err = readDir(ctx, filepath.Join(s.dataDir, id))
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	for _, q := range config.WarmupQueries {
+		if ctx.Err() != nil {
+			break
+		}
+		if _, err := search.Execute(index, models.SearchRequest{Query: q}); err != nil {
+			result.Error = err.Error()
+			continue
+		}
+		result.Queries++
+	}
+
+	result.Duration = time.Since(start)
+	return result
+}
+
+// readDir reads every file below dir, discarding the content, and returns the bytes read
+func readDir(ctx context.Context, dir string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		n, err := io.Copy(io.Discard, file)
+		total += n
+		return err
+	})
+	return total, err
+}